@@ -0,0 +1,358 @@
+// Package accrual charges interest and late-payment fees on the unpaid
+// balances of the accounts, once a day. The charges are posted through the
+// transaction repository with the accrued day as their event date, which is
+// also how a run finds the charges of an earlier one, so running again for
+// the same day posts nothing twice.
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// Day-count conventions, deciding how many days of interest each day
+// accrues.
+const (
+	// DayCountActual accrues one day of interest every day.
+	DayCountActual = "actual"
+	// DayCount30360 accrues 30 days of interest every month, whatever its
+	// length: the 31st accrues nothing and the last day of February accrues
+	// the days up to the 30th.
+	DayCount30360 = "30/360"
+)
+
+// pollInterval is how often the previous day is checked for accrual, so a
+// day missed while the service was down is accrued once it is back.
+const pollInterval = time.Hour
+
+// maxAttempts is how many times the charges of an account are computed
+// again when a transaction was posted to its stream in the meantime.
+const maxAttempts = 3
+
+// Config sets the charges. A zero DailyRate or LateFee disables the
+// interest or the late fees.
+type Config struct {
+	// DailyRate is the interest charged per day on the unpaid balance, such
+	// as 0.0005 for 0.05%.
+	DailyRate float64
+	DayCount  string
+	// GracePeriod is how many days a debt goes without interest: only the
+	// part of the balance already owed that many days earlier accrues it.
+	GracePeriod int
+	// LateFee is charged when an account owing money LatePaymentPeriod days
+	// ago has made no payment since, at most once every LatePaymentPeriod
+	// days. It is taken in the currency of each account.
+	LateFee           float64
+	LatePaymentPeriod int
+}
+
+// Validate reports the first setting out of range.
+func (c Config) Validate() error {
+	switch {
+	case c.DailyRate < 0:
+		return errors.New("the daily interest rate must not be negative")
+	case c.DayCount != DayCountActual && c.DayCount != DayCount30360:
+		return fmt.Errorf("unknown day-count convention %q, use %q or %q", c.DayCount, DayCountActual, DayCount30360)
+	case c.GracePeriod < 0:
+		return errors.New("the grace period must not be negative")
+	case c.LateFee < 0:
+		return errors.New("the late fee must not be negative")
+	case c.LateFee > 0 && c.LatePaymentPeriod <= 0:
+		return errors.New("the late payment period must be at least one day")
+	}
+
+	return nil
+}
+
+// Enabled reports whether any charge is set.
+func (c Config) Enabled() bool {
+	return c.DailyRate > 0 || c.LateFee > 0
+}
+
+// Report lists the charges of a run, posted unless it was a dry run, in
+// which case they have no IDs. Skipped counts the accounts left out for
+// being blocked.
+type Report struct {
+	Day     time.Time
+	DryRun  bool
+	Charges []model.Transaction
+	Skipped int
+}
+
+type Accruer struct {
+	accounts     repository.AccountRepository
+	transactions repository.TransactionRepository
+	config       Config
+	lastDay      time.Time
+}
+
+func NewAccruer(accounts repository.AccountRepository, transactions repository.TransactionRepository, config Config) *Accruer {
+	return &Accruer{
+		accounts:     accounts,
+		transactions: transactions,
+		config:       config,
+	}
+}
+
+// Start accrues the previous day, in UTC, until ctx is done.
+func (a *Accruer) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		day := Day(time.Now()).AddDate(0, 0, -1)
+
+		if !day.Equal(a.lastDay) {
+			report, err := a.Run(ctx, day, false)
+
+			if err != nil {
+				log.Printf("Accruer#Start: Accruing %s failed: %s", day.Format(time.DateOnly), err)
+			} else {
+				log.Printf("Accruer#Start: Accrued %s with %d charges posted and %d accounts skipped", day.Format(time.DateOnly), len(report.Charges), report.Skipped)
+
+				a.lastDay = day
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Day returns the UTC day t falls in.
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Run accrues day for every account, from the balances as of the end of
+// the day. With dryRun the charges are only reported. An account failing
+// stops the run, and running it again resumes with the accounts not
+// charged yet.
+func (a *Accruer) Run(ctx context.Context, day time.Time, dryRun bool) (*Report, error) {
+	report := &Report{Day: Day(day), DryRun: dryRun, Charges: []model.Transaction{}}
+
+	if !a.config.Enabled() {
+		return report, nil
+	}
+
+	page := repository.Page{}
+
+	for {
+		accounts, err := a.accounts.ListAccounts(repository.AccountFilter{}, page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, account := range accounts {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			charges, err := a.accrueAccount(ctx, account, report.Day, dryRun)
+
+			if errors.Is(err, repository.ErrAccountBlocked) {
+				report.Skipped++
+				continue
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("accruing account %d: %w", account.AccountId, err)
+			}
+
+			report.Charges = append(report.Charges, charges...)
+		}
+
+		if len(accounts) < page.EffectiveLimit() {
+			return report, nil
+		}
+
+		page.AfterId = accounts[len(accounts)-1].AccountId
+	}
+}
+
+// accrueAccount posts the charges of the account for day, computing them
+// again when its stream moves while they are posted. It returns
+// repository.ErrAccountBlocked for blocked accounts, which take no
+// charges.
+func (a *Accruer) accrueAccount(ctx context.Context, account model.Account, day time.Time, dryRun bool) ([]model.Transaction, error) {
+	for attempt := 1; ; attempt++ {
+		latest, err := a.accounts.FindBalance(account.AccountId, time.Time{})
+
+		// Not projected yet, so it has no balance to charge.
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if latest.Blocked {
+			return nil, repository.ErrAccountBlocked
+		}
+
+		charges, err := a.charges(account, day)
+
+		if err != nil || len(charges) == 0 || dryRun {
+			return charges, err
+		}
+
+		posted, err := a.transactions.CreateTransactions(repository.WithExpectedVersion(ctx, latest.Version), charges)
+
+		if errors.Is(err, repository.ErrVersionConflict) && attempt < maxAttempts {
+			continue
+		}
+
+		return posted, err
+	}
+}
+
+// charges returns the interest and late fee the account owes for day,
+// leaving out the ones already posted. Both are in the currency of the
+// account, rounded to its minor units.
+func (a *Accruer) charges(account model.Account, day time.Time) ([]model.Transaction, error) {
+	accountId := account.AccountId
+	end := day.AddDate(0, 0, 1)
+
+	owed, err := a.owed(accountId, end)
+	if err != nil || owed == 0 {
+		return nil, err
+	}
+
+	charges := []model.Transaction{}
+
+	interest, err := a.interest(accountId, account.Currency, day, owed)
+	if err != nil {
+		return nil, err
+	}
+
+	if interest.Sign() > 0 {
+		charges = append(charges, model.Transaction{AccountId: accountId, OperationTypeId: model.INTEREST, Amount: interest.Neg(), EventDate: day})
+	}
+
+	late, err := a.late(accountId, end)
+	if err != nil {
+		return nil, err
+	}
+
+	if late {
+		fee, err := model.AmountFromFloat(model.RoundCurrencyAmount(account.Currency, a.config.LateFee), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		charges = append(charges, model.Transaction{AccountId: accountId, OperationTypeId: model.LATE_FEE, Amount: fee.Neg(), EventDate: day})
+	}
+
+	return charges, nil
+}
+
+// owed returns what the account owed right before asOf, zero when its
+// balance was not negative or it was not opened yet.
+func (a *Accruer) owed(accountId uint64, asOf time.Time) (float64, error) {
+	balance, err := a.accounts.FindBalance(accountId, asOf)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return math.Max(0, -balance.Balance), nil
+}
+
+// interest returns the interest of day on the part of owed past the grace
+// period, rounded to the minor units of currency, or zero when it was
+// already charged.
+func (a *Accruer) interest(accountId uint64, currency string, day time.Time, owed float64) (model.Amount, error) {
+	if a.config.DailyRate == 0 {
+		return model.Amount{}, nil
+	}
+
+	if a.config.GracePeriod > 0 {
+		owedBefore, err := a.owed(accountId, day.AddDate(0, 0, 1-a.config.GracePeriod))
+		if err != nil {
+			return model.Amount{}, err
+		}
+
+		owed = math.Min(owed, owedBefore)
+	}
+
+	interest := model.RoundCurrencyAmount(currency, owed*a.config.DailyRate*float64(dayCount(a.config.DayCount, day)))
+
+	if interest == 0 {
+		return model.Amount{}, nil
+	}
+
+	charged, err := a.charged(accountId, model.INTEREST, day, day.AddDate(0, 0, 1))
+	if err != nil || charged {
+		return model.Amount{}, err
+	}
+
+	return model.AmountFromFloat(interest, 64)
+}
+
+// late reports whether the account owed money LatePaymentPeriod days
+// before end and has neither paid nor been charged a late fee since.
+func (a *Accruer) late(accountId uint64, end time.Time) (bool, error) {
+	if a.config.LateFee == 0 {
+		return false, nil
+	}
+
+	due := end.AddDate(0, 0, -a.config.LatePaymentPeriod)
+
+	owed, err := a.owed(accountId, due)
+	if err != nil || owed == 0 {
+		return false, err
+	}
+
+	for _, operationTypeId := range []uint32{model.PAYMENT, model.LATE_FEE} {
+		found, err := a.charged(accountId, operationTypeId, due, end)
+		if err != nil || found {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// charged reports whether the account has a transaction of the operation
+// type that took place from from, included, to to, excluded.
+func (a *Accruer) charged(accountId uint64, operationTypeId uint32, from time.Time, to time.Time) (bool, error) {
+	filter := repository.TransactionFilter{AccountId: accountId, OperationTypeId: operationTypeId, EventDateFrom: from, EventDateTo: to}
+
+	transactions, err := a.transactions.ListTransactions(filter, repository.Page{Limit: 1})
+
+	return len(transactions) > 0, err
+}
+
+// dayCount returns how many days of interest day accrues under the
+// convention.
+func dayCount(convention string, day time.Time) int {
+	if convention != DayCount30360 {
+		return 1
+	}
+
+	switch {
+	case day.Day() == 31:
+		return 0
+	case day.Month() == time.February && day.AddDate(0, 0, 1).Month() == time.March:
+		return 30 - day.Day() + 1
+	}
+
+	return 1
+}
@@ -0,0 +1,147 @@
+package accrual
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+type fixture struct {
+	accounts     repository.AccountRepository
+	transactions repository.TransactionRepository
+}
+
+// newFixture opens an account per amount, owing it, and returns the day
+// after the current one, whose end is after every transaction posted so the
+// balances of the accounts as of then include them.
+func newFixture(t *testing.T, amounts ...string) (fixture, time.Time) {
+	store := memory.NewStore()
+
+	f := fixture{
+		accounts:     memory.NewAccountRepositoryMemory(store),
+		transactions: memory.NewTransactionRepositoryMemory(store),
+	}
+
+	for n, amount := range amounts {
+		account, err := f.accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: uint64(n + 1)})
+		require.NoError(t, err)
+
+		_, err = f.transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount)})
+		require.NoError(t, err)
+	}
+
+	return f, Day(time.Now()).AddDate(0, 0, 1)
+}
+
+func (f fixture) balance(t *testing.T, accountId uint64) float64 {
+	balance, err := f.accounts.FindBalance(accountId, time.Time{})
+	require.NoError(t, err)
+
+	return balance.Balance
+}
+
+func TestRunChargesInterestAndLateFees(t *testing.T) {
+	f, day := newFixture(t, "-1000")
+
+	accruer := NewAccruer(f.accounts, f.transactions, Config{DailyRate: 0.001, DayCount: DayCountActual, LateFee: 25, LatePaymentPeriod: 1})
+
+	report, err := accruer.Run(context.Background(), day, false)
+	require.NoError(t, err)
+	require.Len(t, report.Charges, 2)
+
+	assert.Equal(t, uint32(model.INTEREST), report.Charges[0].OperationTypeId)
+	assert.Equal(t, model.MustParseAmount("-1"), report.Charges[0].Amount)
+	assert.Equal(t, day, report.Charges[0].EventDate)
+	assert.Equal(t, uint32(model.LATE_FEE), report.Charges[1].OperationTypeId)
+	assert.Equal(t, model.MustParseAmount("-25"), report.Charges[1].Amount)
+	assert.Equal(t, -1026.0, f.balance(t, 1))
+
+	// The charges of the day are found and not posted again.
+	report, err = accruer.Run(context.Background(), day, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Charges)
+	assert.Equal(t, -1026.0, f.balance(t, 1))
+}
+
+func TestRunDryRunPostsNothing(t *testing.T) {
+	f, day := newFixture(t, "-1000", "0.5")
+
+	report, err := NewAccruer(f.accounts, f.transactions, Config{DailyRate: 0.001, DayCount: DayCountActual}).Run(context.Background(), day, true)
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []model.Transaction{{AccountId: 1, OperationTypeId: model.INTEREST, Amount: model.MustParseAmount("-1"), EventDate: day}}, report.Charges)
+	assert.Equal(t, -1000.0, f.balance(t, 1))
+}
+
+func TestRunLeavesOutTheGracePeriod(t *testing.T) {
+	f, day := newFixture(t, "-1000")
+
+	// The purchase was not owed yet at the end of the day before.
+	report, err := NewAccruer(f.accounts, f.transactions, Config{DailyRate: 0.001, DayCount: DayCountActual, GracePeriod: 2}).Run(context.Background(), day, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Charges)
+
+	report, err = NewAccruer(f.accounts, f.transactions, Config{DailyRate: 0.001, DayCount: DayCountActual, GracePeriod: 1}).Run(context.Background(), day, false)
+	require.NoError(t, err)
+	assert.Len(t, report.Charges, 1)
+}
+
+func TestRunChargesNoLateFeeAfterAPayment(t *testing.T) {
+	f, day := newFixture(t, "-1000")
+
+	_, err := f.transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), EventDate: day.Add(time.Hour)})
+	require.NoError(t, err)
+
+	report, err := NewAccruer(f.accounts, f.transactions, Config{DayCount: DayCountActual, LateFee: 25, LatePaymentPeriod: 1}).Run(context.Background(), day, false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Charges)
+}
+
+func TestRunSkipsBlockedAccounts(t *testing.T) {
+	f, day := newFixture(t, "-1000", "-1000")
+
+	_, err := f.accounts.BlockAccount(context.Background(), 1)
+	require.NoError(t, err)
+
+	report, err := NewAccruer(f.accounts, f.transactions, Config{DailyRate: 0.001, DayCount: DayCountActual}).Run(context.Background(), day, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Skipped)
+	require.Len(t, report.Charges, 1)
+	assert.Equal(t, uint64(2), report.Charges[0].AccountId)
+}
+
+func TestDayCount30360(t *testing.T) {
+	for day, expected := range map[string]int{
+		"2023-01-15": 1,
+		"2023-01-30": 1,
+		"2023-01-31": 0,
+		"2023-02-28": 3,
+		"2024-02-28": 1,
+		"2024-02-29": 2,
+		"2023-03-01": 1,
+		"2023-12-31": 0,
+	} {
+		parsed, err := time.Parse(time.DateOnly, day)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected, dayCount(DayCount30360, parsed), day)
+		assert.Equal(t, 1, dayCount(DayCountActual, parsed), day)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{DayCount: DayCountActual}.Validate())
+	assert.NoError(t, Config{DailyRate: 0.001, DayCount: DayCount30360, LateFee: 25, LatePaymentPeriod: 30}.Validate())
+	assert.Error(t, Config{DayCount: "actual/365"}.Validate())
+	assert.Error(t, Config{DayCount: DayCountActual, DailyRate: -1}.Validate())
+	assert.Error(t, Config{DayCount: DayCountActual, LateFee: 25}.Validate())
+}
@@ -0,0 +1,172 @@
+// Package api assembles the REST router, so the application and the tests
+// of the client package serve the exact same routes.
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/felipedsi/pismo-test/graphqlapi"
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/openapi"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// idempotencyTTL is how long a response is replayed for the same
+// Idempotency-Key.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyMaxEntries and idempotencyMaxBytes bound the responses kept
+// for replay, so clients sending new keys cannot exhaust the memory.
+const idempotencyMaxEntries = 100_000
+const idempotencyMaxBytes = 64 << 20
+
+// Repositories are the storage the routes are served from.
+type Repositories struct {
+	Accounts       repository.AccountRepository
+	Transactions   repository.TransactionRepository
+	OperationTypes repository.OperationTypeRepository
+	Imports        repository.ImportRepository
+	Exports        repository.ExportRepository
+	Audit          repository.AuditRepository
+	Events         repository.EventRepository
+	Schedules      repository.ScheduleRepository
+	FxRates        repository.FxRateRepository
+	Cards          repository.CardRepository
+	SpendRules     repository.SpendRuleRepository
+	Risk           repository.RiskRepository
+	Disputes       repository.DisputeRepository
+	Holders        repository.HolderRepository
+}
+
+// Options tune the optional behaviour of the router.
+type Options struct {
+	// ValidateOpenAPI checks requests and responses against the OpenAPI
+	// document.
+	ValidateOpenAPI bool
+	// Importer queues the files sent to POST /imports.
+	Importer handler.ImportSubmitter
+	// Exporter renders the statements too large to be streamed.
+	Exporter handler.ExportRunner
+	// ExportStreamLimit is the largest statement, in transactions, streamed
+	// in the response. Zero means handler.DefaultExportStreamLimit.
+	ExportStreamLimit uint64
+	// Risk assesses the transactions sent to POST /transactions, to
+	// POST /transactions:batch and to the GraphQL mutation. Nil lets every
+	// transaction through.
+	Risk risk.Assessor
+}
+
+// NewRouter registers every route of the API. Routes serving the API itself
+// must be described in openapi/openapi.json, router_test.go checks they
+// match.
+func NewRouter(repositories Repositories, options Options) (chi.Router, error) {
+	accountHandler := handler.NewAccountHandler(repositories.Accounts)
+	transactionHandler := handler.NewTransactionHandler(repositories.Transactions, options.Risk)
+	transactionBatchHandler := handler.NewTransactionBatchHandler(repositories.Transactions, repositories.Accounts, options.Risk)
+	operationTypeHandler := handler.NewOperationTypeHandler(repositories.OperationTypes)
+	importHandler := handler.NewImportHandler(repositories.Imports, options.Importer)
+	exportHandler := handler.NewExportHandler(repositories.Accounts, repositories.Exports, options.Exporter, options.ExportStreamLimit)
+	auditHandler := handler.NewAuditHandler(repositories.Audit)
+	eventHandler := handler.NewEventHandler(repositories.Events)
+	scheduleHandler := handler.NewScheduleHandler(repositories.Schedules)
+	fxRateHandler := handler.NewFxRateHandler(repositories.FxRates)
+	cardHandler := handler.NewCardHandler(repositories.Cards)
+	spendRuleHandler := handler.NewSpendRuleHandler(repositories.SpendRules)
+	riskHandler := handler.NewRiskHandler(repositories.Risk)
+	disputeHandler := handler.NewDisputeHandler(repositories.Disputes)
+	holderHandler := handler.NewHolderHandler(repositories.Holders)
+
+	graphqlHandler, err := graphqlapi.NewHandler(repositories.Accounts, repositories.Transactions, options.Risk)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotency := handler.NewIdempotencyCache(idempotencyTTL, idempotencyMaxEntries, idempotencyMaxBytes)
+
+	middlewares := []func(http.Handler) http.Handler{idempotency.Middleware}
+
+	if options.ValidateOpenAPI {
+		doc, err := openapi.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		validator, err := handler.NewOpenAPIValidator(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		middlewares = append(middlewares, validator)
+	}
+
+	r := chi.NewRouter()
+
+	r.Use(middleware.RequestID, middleware.Logger, handler.AuditMetadata)
+
+	r.Get("/openapi.json", openapi.SpecHandler)
+	r.Get("/docs", openapi.DocsHandler)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middlewares...)
+
+		r.Post("/holders", holderHandler.CreateHolder)
+		r.Get("/holders", holderHandler.ListHolders)
+		r.Get("/holders/{holderId}", holderHandler.GetHolder)
+		r.Put("/holders/{holderId}", holderHandler.UpdateHolder)
+		r.Delete("/holders/{holderId}", holderHandler.DeleteHolder)
+		r.Post("/accounts", accountHandler.CreateAccount)
+		r.Get("/accounts", accountHandler.ListAccounts)
+		r.Get("/accounts/{accountId}", accountHandler.GetAccount)
+		r.Post("/accounts/{accountId}/block", accountHandler.BlockAccount)
+		r.Get("/accounts/{accountId}/balance", accountHandler.GetBalance)
+		r.Get("/accounts/{accountId}/events", eventHandler.ListAccountEvents)
+		r.Get("/accounts/{accountId}/transactions/export", exportHandler.ExportTransactions)
+		r.Post("/accounts/{accountId}/cards", cardHandler.IssueCard)
+		r.Get("/accounts/{accountId}/cards", cardHandler.ListCards)
+		r.Get("/cards/{cardId}", cardHandler.GetCard)
+		r.Post("/cards/{cardId}/block", cardHandler.BlockCard)
+		r.Post("/cards/{cardId}/unblock", cardHandler.UnblockCard)
+		r.Post("/cards/{cardId}/replacement", cardHandler.ReplaceCard)
+		r.Put("/cards/{cardId}/limits", cardHandler.UpdateCardLimits)
+		r.Post("/accounts/{accountId}/spend-rules", spendRuleHandler.CreateSpendRule)
+		r.Get("/accounts/{accountId}/spend-rules", spendRuleHandler.ListSpendRules)
+		r.Delete("/spend-rules/{spendRuleId}", spendRuleHandler.DeleteSpendRule)
+		r.Post("/risk-rules", riskHandler.CreateRiskRule)
+		r.Get("/risk-rules", riskHandler.ListRiskRules)
+		r.Delete("/risk-rules/{riskRuleId}", riskHandler.DeleteRiskRule)
+		r.Get("/risk-decisions", riskHandler.ListRiskDecisions)
+		r.Post("/transactions", transactionHandler.CreateTransaction)
+		r.Get("/transactions", transactionHandler.ListTransactions)
+		r.Post("/transactions:batch", transactionBatchHandler.CreateTransactions)
+		r.Post("/transactions/{transactionId}/reversal", transactionHandler.ReverseTransaction)
+		r.Post("/disputes", disputeHandler.OpenDispute)
+		r.Get("/disputes", disputeHandler.ListDisputes)
+		r.Get("/disputes/{disputeId}", disputeHandler.GetDispute)
+		r.Get("/disputes/{disputeId}/history", disputeHandler.ListDisputeChanges)
+		r.Post("/disputes/{disputeId}/provisional-credit", disputeHandler.IssueProvisionalCredit)
+		r.Post("/disputes/{disputeId}/review", disputeHandler.StartReview)
+		r.Post("/disputes/{disputeId}/resolution", disputeHandler.ResolveDispute)
+		r.Get("/operation-types", operationTypeHandler.ListOperationTypes)
+		r.Post("/imports", importHandler.CreateImport)
+		r.Get("/imports/{importId}", importHandler.GetImport)
+		r.Get("/imports/{importId}/rejections", importHandler.ListImportRejections)
+		r.Get("/exports/{exportId}", exportHandler.GetExport)
+		r.Get("/exports/{exportId}/download", exportHandler.DownloadExport)
+		r.Post("/schedules", scheduleHandler.CreateSchedule)
+		r.Get("/schedules", scheduleHandler.ListSchedules)
+		r.Get("/schedules/{scheduleId}", scheduleHandler.GetSchedule)
+		r.Put("/schedules/{scheduleId}", scheduleHandler.UpdateSchedule)
+		r.Delete("/schedules/{scheduleId}", scheduleHandler.DeleteSchedule)
+		r.Post("/fx-rates", fxRateHandler.CreateFxRates)
+		r.Get("/fx-rates", fxRateHandler.ListFxRates)
+		r.Get("/audit-log", auditHandler.ListAuditEntries)
+		r.Method(http.MethodPost, "/graphql", graphqlHandler)
+	})
+
+	return r, nil
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/felipedsi/pismo-test/openapi"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+// Routes that serve the documentation itself and are not part of the API.
+var undocumentedRoutes = map[string]bool{
+	"GET /openapi.json": true,
+	"GET /docs":         true,
+}
+
+func TestRoutesMatchOpenAPIDocument(t *testing.T) {
+	store := memory.NewStore()
+
+	router, err := NewRouter(Repositories{
+		Accounts:       memory.NewAccountRepositoryMemory(store),
+		Transactions:   memory.NewTransactionRepositoryMemory(store),
+		OperationTypes: memory.NewOperationTypeRepositoryMemory(store),
+		Imports:        memory.NewImportRepositoryMemory(store),
+		Exports:        memory.NewExportRepositoryMemory(store),
+		Audit:          memory.NewAuditRepositoryMemory(store),
+	}, Options{ValidateOpenAPI: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var routes []string
+
+	err = chi.Walk(router, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if !undocumentedRoutes[method+" "+route] {
+			routes = append(routes, method+" "+route)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documented []string
+
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+
+	if strings.Join(routes, "\n") != strings.Join(documented, "\n") {
+		t.Errorf("The chi routes and the OpenAPI document are out of sync.\nRoutes:\n%s\n\nDocumented:\n%s", strings.Join(routes, "\n"), strings.Join(documented, "\n"))
+	}
+}
@@ -0,0 +1,170 @@
+// Package audit builds the entries of the audit log and the hash chain that
+// links them. The repository adapters append the entries in the same
+// database transaction as the change they record, with the metadata of the
+// request found in its context.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// SystemActor is the actor of the changes made outside of a request, such
+// as the rows saved by an import.
+const SystemActor = "system"
+
+// AnonymousActor is the actor of the requests that neither name an actor
+// nor carry an API key.
+const AnonymousActor = "anonymous"
+
+// Metadata identifies who made a request and from where.
+type Metadata struct {
+	Actor     string
+	ClientIP  string
+	RequestId string
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the metadata of a request.
+func NewContext(ctx context.Context, metadata Metadata) context.Context {
+	return context.WithValue(ctx, contextKey{}, metadata)
+}
+
+// FromContext returns the metadata carried by ctx, the system actor when
+// there is none.
+func FromContext(ctx context.Context) Metadata {
+	metadata, ok := ctx.Value(contextKey{}).(Metadata)
+
+	if !ok || metadata.Actor == "" {
+		metadata.Actor = SystemActor
+	}
+
+	return metadata
+}
+
+// ResolveActor returns the actor of a request from the actor it names, if
+// any, or else from the fingerprint of the bearer token in authorization, so
+// the key itself is never stored.
+func ResolveActor(actor string, authorization string) string {
+	if actor != "" {
+		return actor
+	}
+
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	token = strings.TrimSpace(token)
+
+	if !ok || token == "" {
+		return AnonymousActor
+	}
+
+	sum := sha256.Sum256([]byte(token))
+
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+// NewEntry returns the entry of a change made by the request of ctx. Either
+// snapshot may be nil, and is then recorded as null.
+func NewEntry(ctx context.Context, action string, entityType string, entityId uint64, before interface{}, after interface{}) (model.AuditEntry, error) {
+	metadata := FromContext(ctx)
+
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return model.AuditEntry{}, err
+	}
+
+	return model.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityId,
+		Actor:      metadata.Actor,
+		ClientIP:   metadata.ClientIP,
+		RequestId:  metadata.RequestId,
+		Before:     beforeJSON,
+		After:      afterJSON,
+		// Milliseconds are kept by every storage, so the time read back is
+		// the one that was hashed.
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}, nil
+}
+
+// Hash returns the SHA-256 of the entry chained to its PrevHash, hex
+// encoded. It covers everything but the ID, which is only assigned once
+// stored, and the hash itself: each field as stored, the time in
+// milliseconds, prefixed by its length in bytes so no two entries hash the
+// same content. The migrations compute it the same way in SQL.
+func Hash(entry model.AuditEntry) string {
+	fields := []string{
+		entry.PrevHash,
+		entry.Action,
+		entry.EntityType,
+		strconv.FormatUint(entry.EntityId, 10),
+		entry.Actor,
+		entry.ClientIP,
+		entry.RequestId,
+		string(entry.Before),
+		string(entry.After),
+		strconv.FormatInt(entry.CreatedAt.UnixMilli(), 10),
+	}
+
+	sum := sha256.New()
+
+	for _, field := range fields {
+		fmt.Fprintf(sum, "%d:%s", len(field), field)
+	}
+
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Chain links the entries to the last hash of the log, empty when the log
+// is empty, and to each other in order.
+func Chain(lastHash string, entries []model.AuditEntry) {
+	for i := range entries {
+		entries[i].PrevHash = lastHash
+		entries[i].Hash = Hash(entries[i])
+
+		lastHash = entries[i].Hash
+	}
+}
+
+// ChainError reports the first entry breaking the chain.
+type ChainError struct {
+	AuditEntryId uint64
+	Reason       string
+}
+
+func (c *ChainError) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", c.AuditEntryId, c.Reason)
+}
+
+// Verify checks that the entries, in the order they were appended, follow
+// lastHash and each other, and that none was changed. It returns the hash
+// of the last entry so a long log can be verified a page at a time.
+func Verify(lastHash string, entries []model.AuditEntry) (string, error) {
+	for _, entry := range entries {
+		if entry.PrevHash != lastHash {
+			return "", &ChainError{AuditEntryId: entry.AuditEntryId, Reason: "does not follow the previous entry, which was removed or changed"}
+		}
+
+		if Hash(entry) != entry.Hash {
+			return "", &ChainError{AuditEntryId: entry.AuditEntryId, Reason: "does not match its hash, it was changed"}
+		}
+
+		lastHash = entry.Hash
+	}
+
+	return lastHash, nil
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+func newChain(t *testing.T, count int) []model.AuditEntry {
+	ctx := NewContext(context.Background(), Metadata{Actor: "alice", ClientIP: "10.0.0.1", RequestId: "req-1"})
+
+	entries := []model.AuditEntry{}
+
+	for id := 1; id <= count; id++ {
+		entry, err := NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_ACCOUNT, uint64(id), nil, model.Account{AccountId: uint64(id)})
+		require.NoError(t, err)
+
+		entry.AuditEntryId = uint64(id)
+		entries = append(entries, entry)
+	}
+
+	Chain("", entries)
+
+	return entries
+}
+
+func TestNewEntry(t *testing.T) {
+	entries := newChain(t, 1)
+
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, "10.0.0.1", entries[0].ClientIP)
+	assert.Equal(t, "req-1", entries[0].RequestId)
+	assert.JSONEq(t, "null", string(entries[0].Before))
+	assert.JSONEq(t, `{"account_id":1}`, string(entries[0].After))
+	assert.Empty(t, entries[0].PrevHash)
+	assert.Len(t, entries[0].Hash, 64)
+}
+
+func TestFromContextDefaultsToSystem(t *testing.T) {
+	assert.Equal(t, Metadata{Actor: SystemActor}, FromContext(context.Background()))
+}
+
+func TestResolveActor(t *testing.T) {
+	assert.Equal(t, "alice", ResolveActor("alice", "Bearer secret"))
+	assert.Equal(t, AnonymousActor, ResolveActor("", ""))
+	assert.Equal(t, AnonymousActor, ResolveActor("", "Basic c2VjcmV0"))
+
+	actor := ResolveActor("", "Bearer secret")
+
+	assert.Regexp(t, "^key:[0-9a-f]{12}$", actor)
+	assert.Equal(t, actor, ResolveActor("", "Bearer secret"))
+	assert.NotEqual(t, actor, ResolveActor("", "Bearer other"))
+}
+
+func TestVerify(t *testing.T) {
+	entries := newChain(t, 4)
+
+	// A long log can be verified a page at a time.
+	lastHash, err := Verify("", entries[:2])
+	require.NoError(t, err)
+
+	lastHash, err = Verify(lastHash, entries[2:])
+	require.NoError(t, err)
+	assert.Equal(t, entries[3].Hash, lastHash)
+
+	scenarios := []struct {
+		name       string
+		tamper     func([]model.AuditEntry) []model.AuditEntry
+		expectedId uint64
+	}{
+		{
+			name:       "ChangedEntry",
+			tamper:     func(entries []model.AuditEntry) []model.AuditEntry { entries[1].Actor = "mallory"; return entries },
+			expectedId: 2,
+		},
+		{
+			name: "ReformattedSnapshot",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].After = []byte(`{ "account_id": 2 }`)
+				return entries
+			},
+			expectedId: 2,
+		},
+		{
+			name: "MovedFieldBoundary",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].Actor, entries[1].ClientIP = "alice10.0.0.1", ""
+				return entries
+			},
+			expectedId: 2,
+		},
+		{
+			name:       "RemovedEntry",
+			tamper:     func(entries []model.AuditEntry) []model.AuditEntry { return append(entries[:1], entries[2:]...) },
+			expectedId: 3,
+		},
+		{
+			name: "RehashedEntry",
+			tamper: func(entries []model.AuditEntry) []model.AuditEntry {
+				entries[1].Actor = "mallory"
+				entries[1].Hash = Hash(entries[1])
+				return entries
+			},
+			expectedId: 3,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			_, err := Verify("", scenario.tamper(newChain(t, 4)))
+
+			var chainError *ChainError
+
+			require.True(t, errors.As(err, &chainError))
+			assert.Equal(t, scenario.expectedId, chainError.AuditEntryId)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package cardcontrol applies the status and the spend limits of the cards
+// to the transactions made with them. The adapters of the transaction
+// repository check the transactions as they post them, once converted to
+// the currency of their account, with the cards and spending of their
+// storage.
+package cardcontrol
+
+import (
+	"errors"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// CardFinder returns the card, or repository.ErrNotFound when there is
+// none.
+type CardFinder func(cardId uint64) (*model.Card, error)
+
+// SpendFinder returns the amount of the purchases and withdraws made with
+// the card on day, in UTC, that were not reversed.
+type SpendFinder func(card model.Card, day time.Time) (model.Amount, error)
+
+// spendKey is the spending of a card on a day.
+type spendKey struct {
+	cardId uint64
+	day    time.Time
+}
+
+// CheckTransactions fails with repository.ErrForeignKeyViolation when the
+// card of a transaction does not exist or is of another account. The
+// purchases and withdraws made with a card, the transactions of negative
+// amount, fail with repository.ErrCardInactive when the card is not active
+// or expired at their event date, and with repository.ErrCardLimitExceeded
+// when they go over a limit of the card, counting the ones before them.
+func CheckTransactions(transactions []model.Transaction, findCard CardFinder, findSpend SpendFinder) error {
+	cards := map[uint64]*model.Card{}
+	spent := map[spendKey]model.Amount{}
+
+	for _, transaction := range transactions {
+		if transaction.CardId == 0 {
+			continue
+		}
+
+		card, ok := cards[transaction.CardId]
+
+		if !ok {
+			found, err := findCard(transaction.CardId)
+
+			if errors.Is(err, repository.ErrNotFound) {
+				return repository.ErrForeignKeyViolation
+			}
+
+			if err != nil {
+				return err
+			}
+
+			card = found
+			cards[transaction.CardId] = card
+		}
+
+		if card.AccountId != transaction.AccountId {
+			return repository.ErrForeignKeyViolation
+		}
+
+		if transaction.Amount.Sign() >= 0 {
+			continue
+		}
+
+		at := transaction.EventDate
+
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		if card.Status != model.CARD_STATUS_ACTIVE || card.Expired(at) {
+			return repository.ErrCardInactive
+		}
+
+		amount := transaction.Amount.Neg()
+
+		if card.TransactionLimit.Sign() > 0 && amount.Cmp(card.TransactionLimit) > 0 {
+			return repository.ErrCardLimitExceeded
+		}
+
+		if card.DailyLimit.Sign() <= 0 {
+			continue
+		}
+
+		key := spendKey{cardId: card.CardId, day: at.UTC().Truncate(24 * time.Hour)}
+
+		if _, ok := spent[key]; !ok {
+			daySpend, err := findSpend(*card, key.day)
+			if err != nil {
+				return err
+			}
+
+			spent[key] = daySpend
+		}
+
+		spent[key] = spent[key].Add(amount)
+
+		if spent[key].Cmp(card.DailyLimit) > 0 {
+			return repository.ErrCardLimitExceeded
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package cardcontrol
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+var day = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func findCards(cards ...model.Card) CardFinder {
+	return func(cardId uint64) (*model.Card, error) {
+		for _, card := range cards {
+			if card.CardId == cardId {
+				return &card, nil
+			}
+		}
+
+		return nil, repository.ErrNotFound
+	}
+}
+
+func spending(amount string) SpendFinder {
+	return func(card model.Card, day time.Time) (model.Amount, error) {
+		return model.MustParseAmount(amount), nil
+	}
+}
+
+func purchase(cardId uint64, amount string) model.Transaction {
+	return model.Transaction{AccountId: 1, CardId: cardId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount), EventDate: day}
+}
+
+func TestCheckTransactions(t *testing.T) {
+	active := model.Card{CardId: 1, AccountId: 1, ExpiryMonth: 3, ExpiryYear: 2024, Status: model.CARD_STATUS_ACTIVE, TransactionLimit: model.MustParseAmount("100"), DailyLimit: model.MustParseAmount("150.3")}
+	blocked := model.Card{CardId: 2, AccountId: 1, ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_BLOCKED}
+	expired := model.Card{CardId: 3, AccountId: 1, ExpiryMonth: 2, ExpiryYear: 2024, Status: model.CARD_STATUS_ACTIVE}
+	other := model.Card{CardId: 4, AccountId: 2, ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE}
+
+	findCard := findCards(active, blocked, expired, other)
+
+	payment := purchase(2, "50")
+	payment.OperationTypeId = model.PAYMENT
+
+	tests := []struct {
+		name         string
+		transactions []model.Transaction
+		spent        string
+		err          error
+	}{
+		{"without a card", []model.Transaction{{AccountId: 1, Amount: model.MustParseAmount("-1000")}}, "0", nil},
+		{"within the limits", []model.Transaction{purchase(1, "-100"), purchase(1, "-50.3")}, "0", nil},
+		{"unknown card", []model.Transaction{purchase(9, "-1")}, "0", repository.ErrForeignKeyViolation},
+		{"card of another account", []model.Transaction{purchase(4, "-1")}, "0", repository.ErrForeignKeyViolation},
+		{"payment with a blocked card", []model.Transaction{payment}, "0", nil},
+		{"blocked card", []model.Transaction{purchase(2, "-1")}, "0", repository.ErrCardInactive},
+		{"expired card", []model.Transaction{purchase(3, "-1")}, "0", repository.ErrCardInactive},
+		{"over the transaction limit", []model.Transaction{purchase(1, "-100.01")}, "0", repository.ErrCardLimitExceeded},
+		{"over the daily limit in the batch", []model.Transaction{purchase(1, "-100"), purchase(1, "-50.31")}, "0", repository.ErrCardLimitExceeded},
+		{"over the daily limit with the day spending", []model.Transaction{purchase(1, "-0.01")}, "150.3", repository.ErrCardLimitExceeded},
+		{"up to the daily limit with the day spending", []model.Transaction{purchase(1, "-50.1")}, "100.2", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckTransactions(test.transactions, findCard, spending(test.spent))
+
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
+
+func TestCheckTransactionsCountsEachDay(t *testing.T) {
+	card := model.Card{CardId: 1, AccountId: 1, ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, DailyLimit: model.MustParseAmount("100")}
+
+	var days []time.Time
+
+	findSpend := func(card model.Card, day time.Time) (model.Amount, error) {
+		days = append(days, day)
+		return model.Amount{}, nil
+	}
+
+	next := purchase(1, "-100")
+	next.EventDate = day.AddDate(0, 0, 1)
+
+	err := CheckTransactions([]model.Transaction{purchase(1, "-100"), next}, findCards(card), findSpend)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)}, days)
+}
+
+func TestCheckTransactionsFails(t *testing.T) {
+	card := model.Card{CardId: 1, AccountId: 1, ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, DailyLimit: model.MustParseAmount("100")}
+	failure := errors.New("Error!")
+
+	err := CheckTransactions([]model.Transaction{purchase(1, "-1")}, findCards(card), func(card model.Card, day time.Time) (model.Amount, error) {
+		return model.Amount{}, failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type AccountList struct {
+	Accounts []model.Account `json:"accounts"`
+	// NextPageToken is empty on the last page.
+	NextPageToken string `json:"next_page_token"`
+}
+
+// ListAccountsParams narrows ListAccounts, zero fields are ignored.
+type ListAccountsParams struct {
+	DocumentNumber uint64
+	HolderId       uint64
+	PageSize       int
+	PageToken      string
+}
+
+func (c *Client) CreateAccount(ctx context.Context, documentNumber uint64) (*model.Account, error) {
+	account := &model.Account{}
+
+	err := c.do(ctx, http.MethodPost, "/accounts", nil, model.Account{DocumentNumber: documentNumber}, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (c *Client) GetAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	account := &model.Account{}
+
+	err := c.do(ctx, http.MethodGet, "/accounts/"+strconv.FormatUint(accountId, 10), nil, nil, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (c *Client) ListAccounts(ctx context.Context, params ListAccountsParams) (*AccountList, error) {
+	query := url.Values{}
+
+	if params.DocumentNumber > 0 {
+		query.Set("document_number", strconv.FormatUint(params.DocumentNumber, 10))
+	}
+
+	if params.HolderId > 0 {
+		query.Set("holder_id", strconv.FormatUint(params.HolderId, 10))
+	}
+
+	setPage(query, params.PageSize, params.PageToken)
+
+	list := &AccountList{}
+
+	err := c.do(ctx, http.MethodGet, "/accounts", query, nil, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func setPage(query url.Values, pageSize int, pageToken string) {
+	if pageSize > 0 {
+		query.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	if pageToken != "" {
+		query.Set("page_token", pageToken)
+	}
+}
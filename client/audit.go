@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type AuditEntryList struct {
+	AuditEntries []model.AuditEntry `json:"audit_entries"`
+	// NextPageToken is empty on the last page.
+	NextPageToken string `json:"next_page_token"`
+}
+
+// ListAuditEntriesParams narrows ListAuditEntries, zero fields are ignored.
+type ListAuditEntriesParams struct {
+	EntityType string
+	EntityId   uint64
+	Actor      string
+	RequestId  string
+	PageSize   int
+	PageToken  string
+}
+
+func (c *Client) ListAuditEntries(ctx context.Context, params ListAuditEntriesParams) (*AuditEntryList, error) {
+	query := url.Values{}
+
+	if params.EntityType != "" {
+		query.Set("entity_type", params.EntityType)
+	}
+
+	if params.EntityId > 0 {
+		query.Set("entity_id", strconv.FormatUint(params.EntityId, 10))
+	}
+
+	if params.Actor != "" {
+		query.Set("actor", params.Actor)
+	}
+
+	if params.RequestId != "" {
+		query.Set("request_id", params.RequestId)
+	}
+
+	setPage(query, params.PageSize, params.PageToken)
+
+	list := &AuditEntryList{}
+
+	err := c.do(ctx, http.MethodGet, "/audit-log", query, nil, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,185 @@
+// Package client is a typed Go client for the REST API, meant to be
+// imported by any service that talks to it instead of redeclaring the
+// request and response types.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const userAgent = "pismo-test-client"
+
+type Client struct {
+	baseURL     *url.URL
+	httpClient  *http.Client
+	apiKey      string
+	retryPolicy RetryPolicy
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient, to set timeouts or transports.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey sends the key as a bearer token on every request.
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// NewClient returns a client for the API served at baseURL, such as
+// http://localhost:3000.
+func NewClient(baseURL string, options ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: the scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		baseURL:     parsed,
+		httpClient:  http.DefaultClient,
+		retryPolicy: DefaultRetryPolicy,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// do sends the request and decodes a successful response into out, or the
+// problem document of a failed one into an *Error. POST requests carry an
+// Idempotency-Key, so every request is safe to retry.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	endpoint := *c.baseURL
+	endpoint.Path += path
+	endpoint.RawQuery = query.Encode()
+
+	contentType, newBody, err := requestBody(body)
+	if err != nil {
+		return err
+	}
+
+	var key string
+
+	if method == http.MethodPost {
+		key, err = idempotencyKey(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	for retry := 0; ; retry++ {
+		reader, err := newBody()
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.send(ctx, method, endpoint.String(), contentType, reader, key)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if retry < c.retryPolicy.MaxRetries && shouldRetry(resp, err) {
+			delay := c.retryPolicy.delay(retry, resp)
+
+			if resp != nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return decodeError(resp)
+		}
+
+		if out == nil {
+			return nil
+		}
+
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+
+// upload is a request body sent as is instead of encoded as JSON. It is
+// rewound before every attempt.
+type upload struct {
+	contentType string
+	content     io.ReadSeeker
+}
+
+// requestBody returns the content type of body and a function returning a
+// fresh reader of it for every attempt.
+func requestBody(body interface{}) (string, func() (io.Reader, error), error) {
+	switch body := body.(type) {
+	case nil:
+		return "", func() (io.Reader, error) { return nil, nil }, nil
+	case *upload:
+		return body.contentType, func() (io.Reader, error) {
+			_, err := body.content.Seek(0, io.SeekStart)
+
+			return body.content, err
+		}, nil
+	default:
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return "", nil, err
+		}
+
+		return "application/json", func() (io.Reader, error) { return bytes.NewReader(encoded), nil }, nil
+	}
+}
+
+func (c *Client) send(ctx context.Context, method string, endpoint string, contentType string, body io.Reader, idempotencyKey string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	return c.httpClient.Do(req)
+}
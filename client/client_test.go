@@ -0,0 +1,76 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+func TestNewClientRejectsInvalidURL(t *testing.T) {
+	_, err := NewClient("localhost:3000")
+
+	assert.Error(t, err)
+}
+
+func TestClientSendsAPIKeyAndDecodesResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/accounts/1", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"account_id":1,"document_number":12345678}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL, WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	account, err := c.GetAccount(context.Background(), 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, &model.Account{AccountId: 1, DocumentNumber: 12345678}, account)
+}
+
+func TestClientDecodesProblem(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"type":"/problems/not_found","title":"Not found","status":404,"detail":"No account found for the provided account ID.","instance":"/accounts/9","code":"not_found"}`))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL)
+	require.NoError(t, err)
+
+	_, err = c.GetAccount(context.Background(), 9)
+
+	var apiError *Error
+	require.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusNotFound, apiError.StatusCode)
+	assert.Equal(t, "not_found", apiError.Code)
+	assert.Equal(t, "No account found for the provided account ID.", apiError.Detail)
+}
+
+func TestClientDecodesNonProblemError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(server.URL)
+	require.NoError(t, err)
+
+	_, err = c.ListOperationTypes(context.Background())
+
+	var apiError *Error
+	require.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusBadGateway, apiError.StatusCode)
+	assert.Equal(t, "502 Bad Gateway", apiError.Error())
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Errors matched by errors.Is against an *Error with the same code, such as
+// errors.Is(err, client.ErrNotFound).
+var (
+	ErrInvalidRequest       = errors.New("invalid request")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrPayloadTooLarge      = errors.New("payload too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrInvalidReference     = errors.New("invalid reference")
+	ErrBatchAborted         = errors.New("batch aborted")
+	ErrAccountBlocked       = errors.New("account blocked")
+	ErrFxRateNotFound       = errors.New("fx rate not found")
+	ErrInvalidAmount        = errors.New("invalid amount")
+	ErrCardInactive         = errors.New("card inactive")
+	ErrCardLimitExceeded    = errors.New("card limit exceeded")
+	ErrMerchantDenied       = errors.New("merchant denied")
+	ErrMerchantNotAllowed   = errors.New("merchant not allowed")
+	ErrCategoryCapExceeded  = errors.New("category limit exceeded")
+	ErrRiskDeclined         = errors.New("risk declined")
+	ErrNotDisputable        = errors.New("not disputable")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrServiceUnavailable   = errors.New("service unavailable")
+	ErrTimeout              = errors.New("timeout")
+	ErrInternal             = errors.New("internal error")
+)
+
+// codeErrors maps every code of handler.Codes to its error.
+var codeErrors = map[string]error{
+	"invalid_request":         ErrInvalidRequest,
+	"not_found":               ErrNotFound,
+	"conflict":                ErrConflict,
+	"payload_too_large":       ErrPayloadTooLarge,
+	"unsupported_media_type":  ErrUnsupportedMediaType,
+	"invalid_reference":       ErrInvalidReference,
+	"batch_aborted":           ErrBatchAborted,
+	"account_blocked":         ErrAccountBlocked,
+	"fx_rate_not_found":       ErrFxRateNotFound,
+	"invalid_amount":          ErrInvalidAmount,
+	"card_inactive":           ErrCardInactive,
+	"card_limit_exceeded":     ErrCardLimitExceeded,
+	"merchant_denied":         ErrMerchantDenied,
+	"merchant_not_allowed":    ErrMerchantNotAllowed,
+	"category_limit_exceeded": ErrCategoryCapExceeded,
+	"risk_declined":           ErrRiskDeclined,
+	"not_disputable":          ErrNotDisputable,
+	"precondition_failed":     ErrPreconditionFailed,
+	"service_unavailable":     ErrServiceUnavailable,
+	"timeout":                 ErrTimeout,
+	"internal_error":          ErrInternal,
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error is the problem document sent by the API when a request fails. Code
+// holds the stable error code, such as not_found or invalid_request.
+type Error struct {
+	StatusCode int          `json:"status"`
+	Type       string       `json:"type"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail"`
+	Instance   string       `json:"instance"`
+	Code       string       `json:"code"`
+	Errors     []FieldError `json:"errors,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%s (%s): %s", e.Title, e.Code, e.Detail)
+}
+
+func (e *Error) Is(target error) bool {
+	return target != nil && codeErrors[e.Code] == target
+}
+
+// decodeError falls back to the status when the body is not a problem
+// document, as proxies in front of the API may answer with anything.
+func decodeError(resp *http.Response) error {
+	apiError := &Error{}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil {
+		json.Unmarshal(body, apiError)
+	}
+
+	apiError.StatusCode = resp.StatusCode
+
+	return apiError
+}
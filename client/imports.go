@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// importContentTypes are the content types files of each format are sent
+// with.
+var importContentTypes = map[string]string{
+	model.IMPORT_FORMAT_CSV:   "text/csv",
+	model.IMPORT_FORMAT_JSONL: "application/x-ndjson",
+}
+
+type ImportRejectionList struct {
+	Rejections []model.ImportRejection `json:"rejections"`
+	// NextPageToken is empty on the last page.
+	NextPageToken string `json:"next_page_token"`
+}
+
+// CreateImport uploads a file of transactions in format, csv or jsonl, and
+// returns the import queued for it. The file is imported in the background,
+// poll GetImport until its status is completed or failed.
+func (c *Client) CreateImport(ctx context.Context, format string, file io.ReadSeeker) (*model.Import, error) {
+	contentType, ok := importContentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("client: unknown import format %q", format)
+	}
+
+	imp := &model.Import{}
+
+	err := c.do(ctx, http.MethodPost, "/imports", nil, &upload{contentType: contentType, content: file}, imp)
+	if err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+func (c *Client) GetImport(ctx context.Context, importId uint64) (*model.Import, error) {
+	imp := &model.Import{}
+
+	err := c.do(ctx, http.MethodGet, "/imports/"+strconv.FormatUint(importId, 10), nil, nil, imp)
+	if err != nil {
+		return nil, err
+	}
+
+	return imp, nil
+}
+
+func (c *Client) ListImportRejections(ctx context.Context, importId uint64, pageSize int, pageToken string) (*ImportRejectionList, error) {
+	query := url.Values{}
+
+	setPage(query, pageSize, pageToken)
+
+	list := &ImportRejectionList{}
+
+	err := c.do(ctx, http.MethodGet, "/imports/"+strconv.FormatUint(importId, 10)+"/rejections", query, nil, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Requests are
+// retried on network errors, 429 and 5xx responses, waiting a random delay
+// between zero and BaseDelay doubled on every attempt, up to MaxDelay. A
+// Retry-After header sent by the server takes precedence, still capped at
+// MaxDelay so a server cannot hold the caller for longer.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  100 * time.Millisecond,
+	MaxDelay:   2 * time.Second,
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. Use a zero RetryPolicy to
+// disable retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// delay returns how long to wait before the given retry, starting from 0.
+func (p RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			retryAfter := time.Duration(seconds) * time.Second
+
+			if retryAfter > p.MaxDelay {
+				return p.MaxDelay
+			}
+
+			return retryAfter
+		}
+	}
+
+	backoff := p.BaseDelay << retry
+
+	if backoff <= 0 || backoff > p.MaxDelay {
+		backoff = p.MaxDelay
+	}
+
+	if backoff <= 0 {
+		return 0
+	}
+
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(backoff)))
+	if err != nil {
+		return backoff
+	}
+
+	return time.Duration(jitter.Int64())
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type idempotencyKeyContextKey struct{}
+
+// WithIdempotencyKey sets the Idempotency-Key sent with the POST requests
+// made with ctx. By default every call gets a random key, which is reused by
+// its retries. Set it to retry a call safely across restarts of the caller.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+func idempotencyKey(ctx context.Context) (string, error) {
+	if key, ok := ctx.Value(idempotencyKeyContextKey{}).(string); ok && key != "" {
+		return key, nil
+	}
+
+	random := make([]byte, 16)
+
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(random), nil
+}
@@ -0,0 +1,341 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/api"
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/importer"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+var testRetryPolicy = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+// newRouterServer serves the real router backed by the memory repositories.
+// wrap can be used to inject failures in front of it.
+func newRouterServer(t *testing.T, wrap func(http.Handler) http.Handler) *Client {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepositoryMemory(store)
+	imports := memory.NewImportRepositoryMemory(store)
+	runner := importer.NewImporter(imports, accounts, nil, t.TempDir(), importer.DefaultBatchSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	go runner.Start(ctx)
+
+	router, err := api.NewRouter(api.Repositories{
+		Accounts:       accounts,
+		Transactions:   memory.NewTransactionRepositoryMemory(store),
+		OperationTypes: memory.NewOperationTypeRepositoryMemory(store),
+		Imports:        imports,
+		Exports:        memory.NewExportRepositoryMemory(store),
+		Audit:          memory.NewAuditRepositoryMemory(store),
+	}, api.Options{
+		ValidateOpenAPI: true,
+		Importer:        runner,
+	})
+	require.NoError(t, err)
+
+	var handler http.Handler = router
+
+	if wrap != nil {
+		handler = wrap(router)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := NewClient(server.URL, WithRetryPolicy(testRetryPolicy))
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestClientAccounts(t *testing.T) {
+	ctx := context.Background()
+	c := newRouterServer(t, nil)
+
+	created, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+	assert.Equal(t, &model.Account{AccountId: 1, DocumentNumber: 12345678, Currency: "BRL"}, created)
+
+	found, err := c.GetAccount(ctx, created.AccountId)
+	require.NoError(t, err)
+	assert.Equal(t, created, found)
+
+	for _, documentNumber := range []uint64{12345678, 87654321} {
+		_, err := c.CreateAccount(ctx, documentNumber)
+		require.NoError(t, err)
+	}
+
+	page, err := c.ListAccounts(ctx, ListAccountsParams{PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, page.Accounts, 2)
+	assert.Equal(t, "2", page.NextPageToken)
+
+	page, err = c.ListAccounts(ctx, ListAccountsParams{PageSize: 2, PageToken: page.NextPageToken})
+	require.NoError(t, err)
+	assert.Equal(t, []model.Account{{AccountId: 3, DocumentNumber: 87654321, Currency: "BRL"}}, page.Accounts)
+	assert.Empty(t, page.NextPageToken)
+
+	page, err = c.ListAccounts(ctx, ListAccountsParams{DocumentNumber: 12345678})
+	require.NoError(t, err)
+	assert.Len(t, page.Accounts, 2)
+}
+
+func TestClientTransactions(t *testing.T) {
+	ctx := context.Background()
+	c := newRouterServer(t, nil)
+
+	account, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+
+	transaction, err := c.CreateTransaction(ctx, CreateTransactionParams{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("123.45")})
+	require.NoError(t, err)
+	assert.Equal(t, &model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("123.45"), Currency: "BRL", EventDate: transaction.CreatedAt, CreatedAt: transaction.CreatedAt}, transaction)
+	assert.WithinDuration(t, time.Now(), transaction.CreatedAt, time.Minute)
+
+	reversed, err := c.ReverseTransaction(ctx, transaction.TransactionId)
+	require.NoError(t, err)
+	assert.True(t, reversed.Reversed)
+
+	_, err = c.ReverseTransaction(ctx, transaction.TransactionId)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	list, err := c.ListTransactions(ctx, ListTransactionsParams{AccountId: account.AccountId})
+	require.NoError(t, err)
+	assert.Equal(t, []model.Transaction{*reversed}, list.Transactions)
+
+	operationTypes, err := c.ListOperationTypes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, operationTypes, 7)
+}
+
+func TestClientDecodesErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newRouterServer(t, nil)
+
+	_, err := c.GetAccount(ctx, 42)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.CreateTransaction(ctx, CreateTransactionParams{AccountId: 42, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	_, err = c.CreateTransaction(ctx, CreateTransactionParams{AccountId: 42, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("10")})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	var apiError *Error
+	require.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusBadRequest, apiError.StatusCode)
+	assert.Equal(t, []FieldError{{
+		Field:   "amount",
+		Code:    "invalid_amount_sign",
+		Message: "Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.",
+	}}, apiError.Errors)
+}
+
+func TestEveryHandlerCodeIsMapped(t *testing.T) {
+	for _, code := range handler.Codes {
+		err := &Error{Code: code}
+
+		assert.Contains(t, codeErrors, code)
+		assert.ErrorIs(t, err, codeErrors[code], code)
+	}
+}
+
+// failFirst answers the first n requests with status before they reach the
+// router, recording the Idempotency-Key of every request.
+type failFirst struct {
+	mu     sync.Mutex
+	n      int
+	status int
+	after  bool
+	keys   []string
+}
+
+func (f *failFirst) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.keys = append(f.keys, r.Header.Get("Idempotency-Key"))
+		fail := len(f.keys) <= f.n
+		f.mu.Unlock()
+
+		if !fail {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Failing after the router ran simulates a response lost on its way
+		// back, so the retry must be replayed instead of applied again.
+		if f.after {
+			next.ServeHTTP(httptest.NewRecorder(), r)
+		}
+
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(f.status)
+	})
+}
+
+func TestClientRetriesWithTheSameIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			failures := &failFirst{n: 2, status: status}
+			c := newRouterServer(t, failures.wrap)
+
+			account, err := c.CreateAccount(ctx, 12345678)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(1), account.AccountId)
+
+			require.Len(t, failures.keys, 3)
+			assert.NotEmpty(t, failures.keys[0])
+			assert.Equal(t, failures.keys[0], failures.keys[1])
+			assert.Equal(t, failures.keys[0], failures.keys[2])
+		})
+	}
+}
+
+func TestClientRetryIsReplayedWhenResponseWasLost(t *testing.T) {
+	ctx := context.Background()
+	failures := &failFirst{n: 1, status: http.StatusBadGateway, after: true}
+	c := newRouterServer(t, failures.wrap)
+
+	account, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), account.AccountId)
+
+	list, err := c.ListAccounts(ctx, ListAccountsParams{})
+	require.NoError(t, err)
+	assert.Len(t, list.Accounts, 1)
+}
+
+func TestClientGivesUpAfterMaxRetries(t *testing.T) {
+	failures := &failFirst{n: 10, status: http.StatusServiceUnavailable}
+	c := newRouterServer(t, failures.wrap)
+
+	_, err := c.GetAccount(context.Background(), 1)
+
+	var apiError *Error
+	require.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusServiceUnavailable, apiError.StatusCode)
+	assert.Len(t, failures.keys, testRetryPolicy.MaxRetries+1)
+	assert.Empty(t, failures.keys[0], "GET requests carry no idempotency key")
+}
+
+func TestClientUsesIdempotencyKeyFromContext(t *testing.T) {
+	failures := &failFirst{}
+	c := newRouterServer(t, failures.wrap)
+
+	ctx := WithIdempotencyKey(context.Background(), "import-42")
+
+	first, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+
+	second, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, []string{"import-42", "import-42"}, failures.keys)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+	for retry := 0; retry < 10; retry++ {
+		delay := policy.delay(retry, nil)
+
+		assert.GreaterOrEqual(t, delay, time.Duration(0))
+		assert.Less(t, delay, policy.MaxDelay)
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"0"}}}
+	assert.Equal(t, time.Duration(0), policy.delay(0, resp))
+
+	resp = &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	assert.Equal(t, policy.MaxDelay, policy.delay(0, resp), "Retry-After is capped at MaxDelay")
+
+	policy.MaxDelay = 5 * time.Second
+	assert.Equal(t, 3*time.Second, policy.delay(0, resp))
+}
+
+func TestClientImports(t *testing.T) {
+	failures := &failFirst{n: 1, status: http.StatusServiceUnavailable}
+	c := newRouterServer(t, failures.wrap)
+
+	ctx := WithIdempotencyKey(context.Background(), "import-1")
+
+	// The first attempt fails, so the file must be sent again from the start.
+	file := strings.NewReader("account_id,operation_type_id,amount\n1,4,10\n1,4,-10\n")
+
+	imp, err := c.CreateImport(ctx, model.IMPORT_FORMAT_CSV, file)
+	require.NoError(t, err)
+	assert.Equal(t, model.IMPORT_PENDING, imp.Status)
+
+	replayed, err := c.CreateImport(ctx, model.IMPORT_FORMAT_CSV, file)
+	require.NoError(t, err)
+	assert.Equal(t, imp.ImportId, replayed.ImportId)
+
+	require.Eventually(t, func() bool {
+		imp, err = c.GetImport(context.Background(), imp.ImportId)
+
+		return err == nil && imp.Status == model.IMPORT_COMPLETED
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, uint64(3), imp.ProcessedLines)
+	assert.Equal(t, uint64(2), imp.RejectedRows)
+
+	rejections, err := c.ListImportRejections(context.Background(), imp.ImportId, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, []model.ImportRejection{
+		{Line: 2, Field: "account_id", Code: "unknown_account", Message: "The account_id does not match any account."},
+	}, rejections.Rejections)
+
+	rejections, err = c.ListImportRejections(context.Background(), imp.ImportId, 1, rejections.NextPageToken)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), rejections.Rejections[0].Line)
+
+	_, err = c.GetImport(context.Background(), imp.ImportId+1)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClientAuditLog(t *testing.T) {
+	c := newRouterServer(t, nil)
+	ctx := context.Background()
+
+	account, err := c.CreateAccount(ctx, 12345678)
+	require.NoError(t, err)
+
+	_, err = c.CreateTransaction(ctx, CreateTransactionParams{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+	require.NoError(t, err)
+
+	list, err := c.ListAuditEntries(ctx, ListAuditEntriesParams{})
+	require.NoError(t, err)
+	require.Len(t, list.AuditEntries, 2)
+
+	_, err = audit.Verify("", list.AuditEntries)
+	assert.NoError(t, err)
+
+	assert.Equal(t, audit.AnonymousActor, list.AuditEntries[0].Actor)
+	assert.Equal(t, "127.0.0.1", list.AuditEntries[0].ClientIP)
+	assert.NotEmpty(t, list.AuditEntries[0].RequestId)
+	assert.NotEqual(t, list.AuditEntries[0].RequestId, list.AuditEntries[1].RequestId)
+
+	list, err = c.ListAuditEntries(ctx, ListAuditEntriesParams{EntityType: model.AUDIT_ENTITY_TRANSACTION})
+	require.NoError(t, err)
+	require.Len(t, list.AuditEntries, 1)
+	assert.Equal(t, uint64(1), list.AuditEntries[0].EntityId)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type TransactionList struct {
+	Transactions []model.Transaction `json:"transactions"`
+	// NextPageToken is empty on the last page.
+	NextPageToken string `json:"next_page_token"`
+}
+
+type CreateTransactionParams struct {
+	AccountId       uint64       `json:"account_id"`
+	OperationTypeId uint32       `json:"operation_type_id"`
+	Amount          model.Amount `json:"amount"`
+	// Currency is the one of Amount when other than the currency of the
+	// account.
+	Currency string `json:"currency,omitempty"`
+}
+
+// ListTransactionsParams narrows ListTransactions, zero fields are ignored.
+type ListTransactionsParams struct {
+	AccountId uint64
+	PageSize  int
+	PageToken string
+}
+
+func (c *Client) CreateTransaction(ctx context.Context, params CreateTransactionParams) (*model.Transaction, error) {
+	transaction := &model.Transaction{}
+
+	err := c.do(ctx, http.MethodPost, "/transactions", nil, params, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// ReverseTransaction cancels the amount of the transaction, which stays
+// listed, marked as reversed. It fails with ErrConflict when the transaction
+// is already reversed.
+func (c *Client) ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	transaction := &model.Transaction{}
+
+	err := c.do(ctx, http.MethodPost, "/transactions/"+strconv.FormatUint(transactionId, 10)+"/reversal", nil, nil, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (c *Client) ListTransactions(ctx context.Context, params ListTransactionsParams) (*TransactionList, error) {
+	query := url.Values{}
+
+	if params.AccountId > 0 {
+		query.Set("account_id", strconv.FormatUint(params.AccountId, 10))
+	}
+
+	setPage(query, params.PageSize, params.PageToken)
+
+	list := &TransactionList{}
+
+	err := c.do(ctx, http.MethodGet, "/transactions", query, nil, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (c *Client) ListOperationTypes(ctx context.Context) ([]model.OperationType, error) {
+	list := &struct {
+		OperationTypes []model.OperationType `json:"operation_types"`
+	}{}
+
+	err := c.do(ctx, http.MethodGet, "/operation-types", nil, nil, list)
+	if err != nil {
+		return nil, err
+	}
+
+	return list.OperationTypes, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/client"
+)
+
+// auditVerifyPageSize is the page size audit verify reads the log with, the
+// largest the API serves.
+const auditVerifyPageSize = 1000
+
+func listAuditEntries(e *env, args []string) error {
+	flags := newFlagSet(e, "audit list")
+	entityType := flags.String("entity-type", "", "only list the changes made to this kind of entity: account, transaction, import or export")
+	entityId := flags.Uint64("entity-id", 0, "only list the changes made to the entities with this ID")
+	actor := flags.String("actor", "", "only list the changes made by this actor")
+	requestId := flags.String("request-id", "", "only list the changes made by this request")
+	pageSize := flags.Int("page-size", 0, "maximum number of entries to list")
+	pageToken := flags.String("page-token", "", "next page token printed by the previous call")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := e.client.ListAuditEntries(e.ctx, client.ListAuditEntriesParams{
+		EntityType: *entityType,
+		EntityId:   *entityId,
+		Actor:      *actor,
+		RequestId:  *requestId,
+		PageSize:   *pageSize,
+		PageToken:  *pageToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	printNextPageToken(e, list.NextPageToken)
+
+	t := table{header: []string{"audit_entry_id", "created_at", "action", "entity_type", "entity_id", "actor", "client_ip", "request_id"}, value: list}
+
+	for _, entry := range list.AuditEntries {
+		t.rows = append(t.rows, []string{
+			strconv.FormatUint(entry.AuditEntryId, 10),
+			entry.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+			entry.Action,
+			entry.EntityType,
+			strconv.FormatUint(entry.EntityId, 10),
+			entry.Actor,
+			entry.ClientIP,
+			entry.RequestId,
+		})
+	}
+
+	return printTable(e.stdout, e.output, t)
+}
+
+// verifyAuditLog reads the whole log and checks its hash chain, failing on
+// the first entry that was changed or follows a removed one. The last hash
+// printed can be kept to check later that the log was not rewritten.
+func verifyAuditLog(e *env, args []string) error {
+	flags := newFlagSet(e, "audit verify")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var lastHash, pageToken string
+	var entries uint64
+
+	for {
+		list, err := e.client.ListAuditEntries(e.ctx, client.ListAuditEntriesParams{PageSize: auditVerifyPageSize, PageToken: pageToken})
+		if err != nil {
+			return err
+		}
+
+		lastHash, err = audit.Verify(lastHash, list.AuditEntries)
+		if err != nil {
+			return err
+		}
+
+		entries += uint64(len(list.AuditEntries))
+
+		if list.NextPageToken == "" {
+			break
+		}
+
+		pageToken = list.NextPageToken
+	}
+
+	result := struct {
+		Entries  uint64 `json:"entries"`
+		LastHash string `json:"last_hash"`
+	}{entries, lastHash}
+
+	return printTable(e.stdout, e.output, table{
+		header: []string{"entries", "last_hash"},
+		value:  result,
+		rows:   [][]string{{strconv.FormatUint(entries, 10), lastHash}},
+	})
+}
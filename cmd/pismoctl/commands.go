@@ -0,0 +1,188 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/client"
+	"github.com/felipedsi/pismo-test/model"
+)
+
+func newFlagSet(e *env, name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+
+	return flags
+}
+
+func createAccount(e *env, args []string) error {
+	flags := newFlagSet(e, "accounts create")
+	documentNumber := flags.Uint64("document-number", 0, "document number of the account holder")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *documentNumber == 0 {
+		return fmt.Errorf("%w: -document-number is required", errUsage)
+	}
+
+	account, err := e.client.CreateAccount(e.ctx, *documentNumber)
+	if err != nil {
+		return err
+	}
+
+	return printTable(e.stdout, e.output, accountsTable(account, []model.Account{*account}))
+}
+
+func getAccount(e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: accounts get ACCOUNT_ID", errUsage)
+	}
+
+	accountId, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid account ID %q", args[0])
+	}
+
+	account, err := e.client.GetAccount(e.ctx, accountId)
+	if err != nil {
+		return err
+	}
+
+	return printTable(e.stdout, e.output, accountsTable(account, []model.Account{*account}))
+}
+
+func listAccounts(e *env, args []string) error {
+	flags := newFlagSet(e, "accounts list")
+	documentNumber := flags.Uint64("document-number", 0, "only list the accounts with this document number")
+	pageSize := flags.Int("page-size", 0, "maximum number of accounts to list")
+	pageToken := flags.String("page-token", "", "next page token printed by the previous call")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := e.client.ListAccounts(e.ctx, client.ListAccountsParams{DocumentNumber: *documentNumber, PageSize: *pageSize, PageToken: *pageToken})
+	if err != nil {
+		return err
+	}
+
+	printNextPageToken(e, list.NextPageToken)
+
+	return printTable(e.stdout, e.output, accountsTable(list, list.Accounts))
+}
+
+func createTransaction(e *env, args []string) error {
+	flags := newFlagSet(e, "transactions create")
+	accountId := flags.Uint64("account-id", 0, "account of the transaction")
+	operationTypeId := flags.Uint("operation-type-id", 0, "operation type, see operation-types list")
+	amount := model.Amount{}
+	flags.Func("amount", "amount, negative for purchases and withdraws", func(value string) (err error) {
+		amount, err = model.ParseAmount(value)
+		return err
+	})
+	currency := flags.String("currency", "", "currency of the amount, when other than the account's")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	transaction, err := e.client.CreateTransaction(e.ctx, client.CreateTransactionParams{
+		AccountId:       *accountId,
+		OperationTypeId: uint32(*operationTypeId),
+		Amount:          amount,
+		Currency:        *currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	return printTable(e.stdout, e.output, transactionsTable(transaction, []model.Transaction{*transaction}))
+}
+
+func reverseTransaction(e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: transactions reverse TRANSACTION_ID", errUsage)
+	}
+
+	transactionId, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid transaction ID %q", args[0])
+	}
+
+	transaction, err := e.client.ReverseTransaction(e.ctx, transactionId)
+	if err != nil {
+		return err
+	}
+
+	return printTable(e.stdout, e.output, transactionsTable(transaction, []model.Transaction{*transaction}))
+}
+
+func listTransactions(e *env, args []string) error {
+	flags := newFlagSet(e, "transactions list")
+	accountId := flags.Uint64("account-id", 0, "only list the transactions of this account")
+	pageSize := flags.Int("page-size", 0, "maximum number of transactions to list")
+	pageToken := flags.String("page-token", "", "next page token printed by the previous call")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	list, err := e.client.ListTransactions(e.ctx, client.ListTransactionsParams{AccountId: *accountId, PageSize: *pageSize, PageToken: *pageToken})
+	if err != nil {
+		return err
+	}
+
+	printNextPageToken(e, list.NextPageToken)
+
+	return printTable(e.stdout, e.output, transactionsTable(list, list.Transactions))
+}
+
+func listOperationTypes(e *env, args []string) error {
+	operationTypes, err := e.client.ListOperationTypes(e.ctx)
+	if err != nil {
+		return err
+	}
+
+	t := table{header: []string{"operation_type_id", "description"}, value: operationTypes}
+
+	for _, operationType := range operationTypes {
+		t.rows = append(t.rows, []string{strconv.FormatUint(uint64(operationType.OperationTypeId), 10), operationType.Description})
+	}
+
+	return printTable(e.stdout, e.output, t)
+}
+
+// printNextPageToken goes to stderr, so the output can still be piped.
+func printNextPageToken(e *env, token string) {
+	if token != "" {
+		fmt.Fprintf(e.stderr, "More results available, use -page-token %s\n", token)
+	}
+}
+
+func accountsTable(value interface{}, accounts []model.Account) table {
+	t := table{header: []string{"account_id", "document_number"}, value: value}
+
+	for _, account := range accounts {
+		t.rows = append(t.rows, []string{strconv.FormatUint(account.AccountId, 10), strconv.FormatUint(account.DocumentNumber, 10)})
+	}
+
+	return t
+}
+
+func transactionsTable(value interface{}, transactions []model.Transaction) table {
+	t := table{header: []string{"transaction_id", "account_id", "operation_type_id", "amount"}, value: value}
+
+	for _, transaction := range transactions {
+		t.rows = append(t.rows, []string{
+			strconv.FormatUint(transaction.TransactionId, 10),
+			strconv.FormatUint(transaction.AccountId, 10),
+			strconv.FormatUint(uint64(transaction.OperationTypeId), 10),
+			strconv.FormatFloat(transaction.Amount.Float64(), 'f', max(2, transaction.Amount.Decimals()), 64),
+		})
+	}
+
+	return t
+}
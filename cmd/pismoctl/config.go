@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const defaultURL = "http://localhost:3000"
+
+// Profile holds the settings of one environment. Flags and environment
+// variables take precedence over it.
+type Profile struct {
+	URL    string `json:"url"`
+	APIKey string `json:"api_key"`
+	Output string `json:"output"`
+}
+
+// Config is the file read by pismoctl, by default
+// ~/.config/pismoctl/config.json:
+//
+//	{
+//	  "profiles": {
+//	    "default": {"url": "http://localhost:3000"},
+//	    "staging": {"url": "https://pismo.staging.example.com", "api_key": "..."}
+//	  }
+//	}
+type Config struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "pismoctl", "config.json")
+}
+
+// loadProfile reads the named profile from the config file. A missing file
+// is only an error when a profile other than the default one is requested.
+func loadProfile(path string, name string) (Profile, error) {
+	profile := Profile{URL: defaultURL, Output: "table"}
+
+	content, err := os.ReadFile(path)
+
+	if path == "" || errors.Is(err, fs.ErrNotExist) {
+		if name != "default" {
+			return Profile{}, fmt.Errorf("profile %q not found: no config file at %s", name, path)
+		}
+
+		return profile, nil
+	}
+
+	if err != nil {
+		return Profile{}, err
+	}
+
+	config := Config{}
+
+	if err := json.Unmarshal(content, &config); err != nil {
+		return Profile{}, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	configured, ok := config.Profiles[name]
+
+	if !ok {
+		if name != "default" {
+			return Profile{}, fmt.Errorf("profile %q not found in %s", name, path)
+		}
+
+		return profile, nil
+	}
+
+	if configured.URL != "" {
+		profile.URL = configured.URL
+	}
+
+	if configured.Output != "" {
+		profile.Output = configured.Output
+	}
+
+	profile.APIKey = configured.APIKey
+
+	return profile, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// importPollInterval is how often imports create -wait checks the import.
+var importPollInterval = time.Second
+
+// importFormatsByExtension guess the format of a file when -format is not
+// given.
+var importFormatsByExtension = map[string]string{
+	".csv":    model.IMPORT_FORMAT_CSV,
+	".jsonl":  model.IMPORT_FORMAT_JSONL,
+	".ndjson": model.IMPORT_FORMAT_JSONL,
+}
+
+func createImport(e *env, args []string) error {
+	flags := newFlagSet(e, "imports create")
+	path := flags.String("file", "", "CSV or JSONL file of transactions")
+	format := flags.String("format", "", "format of the file: csv or jsonl, guessed from its extension by default")
+	wait := flags.Bool("wait", false, "wait until the import is finished")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *path == "" {
+		return fmt.Errorf("%w: -file is required", errUsage)
+	}
+
+	if *format == "" {
+		*format = importFormatsByExtension[strings.ToLower(filepath.Ext(*path))]
+	}
+
+	if *format == "" {
+		return fmt.Errorf("%w: -format is required when the file is not .csv or .jsonl", errUsage)
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	imp, err := e.client.CreateImport(e.ctx, *format, file)
+	if err != nil {
+		return err
+	}
+
+	for *wait && (imp.Status == model.IMPORT_PENDING || imp.Status == model.IMPORT_RUNNING) {
+		select {
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		case <-time.After(importPollInterval):
+		}
+
+		imp, err = e.client.GetImport(e.ctx, imp.ImportId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return printImport(e, imp)
+}
+
+func getImport(e *env, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: imports get IMPORT_ID", errUsage)
+	}
+
+	importId, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid import ID %q", args[0])
+	}
+
+	imp, err := e.client.GetImport(e.ctx, importId)
+	if err != nil {
+		return err
+	}
+
+	return printImport(e, imp)
+}
+
+func listImportRejections(e *env, args []string) error {
+	flags := newFlagSet(e, "imports rejections")
+	pageSize := flags.Int("page-size", 0, "maximum number of rejections to list")
+	pageToken := flags.String("page-token", "", "next page token printed by the previous call")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: imports rejections [-page-size N] [-page-token T] IMPORT_ID", errUsage)
+	}
+
+	importId, err := strconv.ParseUint(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid import ID %q", flags.Arg(0))
+	}
+
+	list, err := e.client.ListImportRejections(e.ctx, importId, *pageSize, *pageToken)
+	if err != nil {
+		return err
+	}
+
+	printNextPageToken(e, list.NextPageToken)
+
+	t := table{header: []string{"line", "field", "code", "message"}, value: list}
+
+	for _, rejection := range list.Rejections {
+		t.rows = append(t.rows, []string{strconv.FormatUint(rejection.Line, 10), rejection.Field, rejection.Code, rejection.Message})
+	}
+
+	return printTable(e.stdout, e.output, t)
+}
+
+func printImport(e *env, imp *model.Import) error {
+	t := table{
+		header: []string{"import_id", "format", "status", "processed_lines", "imported_rows", "rejected_rows", "error"},
+		value:  imp,
+		rows: [][]string{{
+			strconv.FormatUint(imp.ImportId, 10),
+			imp.Format,
+			imp.Status,
+			strconv.FormatUint(imp.ProcessedLines, 10),
+			strconv.FormatUint(imp.ImportedRows, 10),
+			strconv.FormatUint(imp.RejectedRows, 10),
+			imp.Error,
+		}},
+	}
+
+	return printTable(e.stdout, e.output, t)
+}
@@ -0,0 +1,156 @@
+// Command pismoctl manages accounts and transactions through the REST API.
+//
+//	pismoctl [-profile name] [-url url] [-api-key key] [-output table|json|csv] <command> <action> [flags]
+//
+// Run pismoctl without arguments to list the commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/client"
+)
+
+// env holds what every command needs once the global flags are parsed.
+type env struct {
+	ctx    context.Context
+	client *client.Client
+	output string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"accounts create":      {"accounts create -document-number N", createAccount},
+	"accounts get":         {"accounts get ACCOUNT_ID", getAccount},
+	"accounts list":        {"accounts list [-document-number N] [-page-size N] [-page-token T]", listAccounts},
+	"transactions create":  {"transactions create -account-id N -operation-type-id N -amount X [-currency C]", createTransaction},
+	"transactions list":    {"transactions list [-account-id N] [-page-size N] [-page-token T]", listTransactions},
+	"transactions reverse": {"transactions reverse TRANSACTION_ID", reverseTransaction},
+	"operation-types list": {"operation-types list", listOperationTypes},
+	"imports create":       {"imports create -file PATH [-format csv|jsonl] [-wait]", createImport},
+	"imports get":          {"imports get IMPORT_ID", getImport},
+	"imports rejections":   {"imports rejections [-page-size N] [-page-token T] IMPORT_ID", listImportRejections},
+	"audit list":           {"audit list [-entity-type T] [-entity-id N] [-actor A] [-request-id R] [-page-size N] [-page-token T]", listAuditEntries},
+	"audit verify":         {"audit verify", verifyAuditLog},
+}
+
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr)
+
+	if err == nil {
+		return
+	}
+
+	// The usage was already printed for a bare errUsage.
+	if err != errUsage && err != flag.ErrHelp {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+
+	if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+
+	os.Exit(1)
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) error {
+	flags := flag.NewFlagSet("pismoctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { printUsage(flags) }
+
+	configPath := flags.String("config", getEnv("PISMOCTL_CONFIG", defaultConfigPath()), "path of the config file")
+	profileName := flags.String("profile", getEnv("PISMOCTL_PROFILE", "default"), "profile of the config file to use")
+	baseURL := flags.String("url", os.Getenv("PISMOCTL_URL"), "base URL of the API, overrides the profile")
+	apiKey := flags.String("api-key", os.Getenv("PISMOCTL_API_KEY"), "API key, overrides the profile")
+	output := flags.String("output", "", "output format: table, json or csv, overrides the profile")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 2 {
+		flags.Usage()
+		return errUsage
+	}
+
+	name := flags.Arg(0) + " " + flags.Arg(1)
+	cmd, ok := commands[name]
+
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q.\n", name)
+		flags.Usage()
+		return errUsage
+	}
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		return err
+	}
+
+	if *baseURL != "" {
+		profile.URL = *baseURL
+	}
+
+	if *apiKey != "" {
+		profile.APIKey = *apiKey
+	}
+
+	if *output != "" {
+		profile.Output = *output
+	}
+
+	options := []client.Option{}
+
+	if profile.APIKey != "" {
+		options = append(options, client.WithAPIKey(profile.APIKey))
+	}
+
+	apiClient, err := client.NewClient(profile.URL, options...)
+	if err != nil {
+		return err
+	}
+
+	return cmd.run(&env{ctx: ctx, client: apiClient, output: profile.Output, stdout: stdout, stderr: stderr}, flags.Args()[2:])
+}
+
+func printUsage(flags *flag.FlagSet) {
+	w := flags.Output()
+
+	fmt.Fprintln(w, "Usage: pismoctl [flags] <command> <action> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+
+	usages := make([]string, 0, len(commands))
+
+	for _, cmd := range commands {
+		usages = append(usages, cmd.usage)
+	}
+
+	sort.Strings(usages)
+	fmt.Fprintln(w, "  "+strings.Join(usages, "\n  "))
+
+	fmt.Fprintln(w, "\nFlags:")
+	flags.PrintDefaults()
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+
+	return fallback
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/client"
+	"github.com/felipedsi/pismo-test/model"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/accounts":
+			assert.Equal(t, "456", r.URL.Query().Get("document_number"))
+			w.Write([]byte(`{"accounts":[{"account_id":1,"document_number":456},{"account_id":2,"document_number":456}],"next_page_token":"2"}`))
+		case "/imports":
+			assert.Equal(t, "text/csv", r.Header.Get("Content-Type"))
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"import_id":1,"format":"csv","status":"pending","processed_lines":0,"imported_rows":0,"rejected_rows":0}`))
+		case "/imports/1":
+			w.Write([]byte(`{"import_id":1,"format":"csv","status":"completed","processed_lines":3,"imported_rows":1,"rejected_rows":1}`))
+		case "/transactions/3/reversal":
+			assert.Equal(t, http.MethodPost, r.Method)
+			w.Write([]byte(`{"transaction_id":3,"account_id":1,"operation_type_id":4,"amount":10,"reversed":true}`))
+		case "/operation-types":
+			assert.Equal(t, "Bearer from-profile", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"operation_types":[{"operation_type_id":1,"description":"COMPRA A VISTA"}]}`))
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"title":"Not found","status":404,"detail":"No account found for the provided account ID.","code":"not_found"}`))
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRunPrintsFormats(t *testing.T) {
+	server := newTestServer(t)
+
+	scenarios := []struct {
+		output   string
+		expected string
+	}{
+		{"table", "ACCOUNT_ID  DOCUMENT_NUMBER\n1           456\n2           456\n"},
+		{"csv", "account_id,document_number\n1,456\n2,456\n"},
+		{"json", "{\n  \"accounts\": [\n    {\n      \"account_id\": 1,\n      \"document_number\": 456\n    },\n    {\n      \"account_id\": 2,\n      \"document_number\": 456\n    }\n  ],\n  \"next_page_token\": \"2\"\n}\n"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.output, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			err := run(context.Background(), []string{"-config", "", "-url", server.URL, "-output", scenario.output, "accounts", "list", "-document-number", "456"}, &stdout, &stderr)
+			require.NoError(t, err)
+
+			assert.Equal(t, scenario.expected, stdout.String())
+			assert.Equal(t, "More results available, use -page-token 2\n", stderr.String())
+		})
+	}
+}
+
+func TestRunReadsProfile(t *testing.T) {
+	server := newTestServer(t)
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"profiles": {"staging": {"url": "` + server.URL + `", "api_key": "from-profile", "output": "csv"}}}`
+	require.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", path, "-profile", "staging", "operation-types", "list"}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "operation_type_id,description\n1,COMPRA A VISTA\n", stdout.String())
+}
+
+func TestRunFailsWithUnknownProfile(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", filepath.Join(t.TempDir(), "missing.json"), "-profile", "staging", "operation-types", "list"}, &stdout, &stderr)
+
+	assert.ErrorContains(t, err, `profile "staging" not found`)
+}
+
+func TestRunReturnsAPIErrors(t *testing.T) {
+	server := newTestServer(t)
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", "", "-url", server.URL, "accounts", "get", "9"}, &stdout, &stderr)
+
+	assert.EqualError(t, err, "Not found (not_found): No account found for the provided account ID.")
+}
+
+func TestRunReversesTransaction(t *testing.T) {
+	server := newTestServer(t)
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", "", "-url", server.URL, "-output", "csv", "transactions", "reverse", "3"}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "transaction_id,account_id,operation_type_id,amount\n3,1,4,10.00\n", stdout.String())
+
+	err = run(context.Background(), []string{"-config", "", "-url", server.URL, "transactions", "reverse", "x"}, &stdout, &stderr)
+	assert.EqualError(t, err, `invalid transaction ID "x"`)
+}
+
+func TestRunFailsWithUnknownCommand(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"accounts", "delete"}, &stdout, &stderr)
+
+	assert.ErrorIs(t, err, errUsage)
+	assert.Contains(t, stderr.String(), `Unknown command "accounts delete"`)
+}
+
+func TestRunWaitsForImport(t *testing.T) {
+	server := newTestServer(t)
+	importPollInterval = time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "transactions.csv")
+	require.NoError(t, os.WriteFile(path, []byte("account_id,operation_type_id,amount\n1,4,10\n9,4,10\n"), 0o600))
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", "", "-url", server.URL, "-output", "csv", "imports", "create", "-file", path, "-wait"}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "import_id,format,status,processed_lines,imported_rows,rejected_rows,error\n1,csv,completed,3,1,1,\n", stdout.String())
+}
+
+// newAuditLogServer serves entries as the audit log, one per page.
+func newAuditLogServer(t *testing.T, entries []model.AuditEntry) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		afterId, _ := strconv.ParseUint(r.URL.Query().Get("page_token"), 10, 64)
+
+		list := client.AuditEntryList{AuditEntries: []model.AuditEntry{}}
+
+		if afterId < uint64(len(entries)) {
+			list.AuditEntries = append(list.AuditEntries, entries[afterId])
+			list.NextPageToken = strconv.FormatUint(afterId+1, 10)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRunVerifiesAuditLog(t *testing.T) {
+	entries := []model.AuditEntry{}
+
+	for id := uint64(1); id <= 3; id++ {
+		entries = append(entries, model.AuditEntry{
+			AuditEntryId: id,
+			Action:       model.AUDIT_ACTION_CREATE,
+			EntityType:   model.AUDIT_ENTITY_ACCOUNT,
+			EntityId:     id,
+			Actor:        "alice",
+			Before:       json.RawMessage("null"),
+			After:        json.RawMessage(`{"account_id":` + strconv.FormatUint(id, 10) + `}`),
+			CreatedAt:    time.Date(2024, 1, 1, 0, 0, int(id), 0, time.UTC),
+		})
+	}
+
+	audit.Chain("", entries)
+
+	var stdout, stderr bytes.Buffer
+
+	err := run(context.Background(), []string{"-config", "", "-url", newAuditLogServer(t, entries).URL, "-output", "csv", "audit", "verify"}, &stdout, &stderr)
+	require.NoError(t, err)
+
+	assert.Equal(t, "entries,last_hash\n3,"+entries[2].Hash+"\n", stdout.String())
+
+	entries[1].Actor = "mallory"
+
+	err = run(context.Background(), []string{"-config", "", "-url", newAuditLogServer(t, entries).URL, "audit", "verify"}, &stdout, &stderr)
+
+	assert.EqualError(t, err, "audit entry 2: does not match its hash, it was changed")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// table is what a command prints: the rows for the table and CSV formats,
+// and the raw API response for the JSON one.
+type table struct {
+	header []string
+	rows   [][]string
+	value  interface{}
+}
+
+func printTable(w io.Writer, format string, t table) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(t.value)
+	case "csv":
+		writer := csv.NewWriter(w)
+
+		if err := writer.Write(t.header); err != nil {
+			return err
+		}
+
+		if err := writer.WriteAll(t.rows); err != nil {
+			return err
+		}
+
+		return writer.Error()
+	case "table":
+		writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(writer, strings.ToUpper(strings.Join(t.header, "\t")))
+
+		for _, row := range t.rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+
+		return writer.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json or csv", format)
+	}
+}
@@ -0,0 +1,8 @@
+// Package db embeds the SQL migrations so single-node deployments can migrate
+// their database on startup without the migrate CLI.
+package db
+
+import "embed"
+
+//go:embed migrations/sqlite/*.up.sql
+var SQLiteMigrations embed.FS
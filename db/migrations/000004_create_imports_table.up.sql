@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS "imports" (
+    "import_id" SERIAL PRIMARY KEY,
+    "format" TEXT NOT NULL,
+    "status" TEXT NOT NULL,
+    "processed_lines" BIGINT NOT NULL DEFAULT 0,
+    "imported_rows" BIGINT NOT NULL DEFAULT 0,
+    "rejected_rows" BIGINT NOT NULL DEFAULT 0,
+    "error" TEXT NOT NULL DEFAULT '',
+    "idempotency_key" TEXT UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS "import_rejections" (
+    "import_rejection_id" SERIAL PRIMARY KEY,
+    "import_id" INT NOT NULL,
+    "line" BIGINT NOT NULL,
+    "field" TEXT NOT NULL,
+    "code" TEXT NOT NULL,
+    "message" TEXT NOT NULL,
+    CONSTRAINT fk_import
+      FOREIGN KEY(import_id)
+	  REFERENCES imports(import_id)
+);
+
+CREATE INDEX IF NOT EXISTS "import_rejections_import_id_idx" ON "import_rejections" ("import_id", "import_rejection_id");
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "transactions_account_id_created_at_idx";
+
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "created_at";
@@ -0,0 +1,3 @@
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "created_at" TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX IF NOT EXISTS "transactions_account_id_created_at_idx" ON "transactions" ("account_id", "created_at", "transaction_id");
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "exports" (
+    "export_id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "format" TEXT NOT NULL,
+    "period_from" TIMESTAMPTZ,
+    "period_to" TIMESTAMPTZ,
+    "status" TEXT NOT NULL,
+    "rows" BIGINT NOT NULL DEFAULT 0,
+    "error" TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+	  REFERENCES accounts(account_id)
+);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "audit_entry_id" BIGSERIAL PRIMARY KEY,
+    "action" TEXT NOT NULL,
+    "entity_type" TEXT NOT NULL,
+    "entity_id" BIGINT NOT NULL,
+    "actor" TEXT NOT NULL,
+    "client_ip" TEXT NOT NULL DEFAULT '',
+    "request_id" TEXT NOT NULL DEFAULT '',
+    "snapshot_before" TEXT NOT NULL,
+    "snapshot_after" TEXT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL,
+    -- An entry can only follow one other, so the chain never forks.
+    "prev_hash" TEXT NOT NULL UNIQUE,
+    "hash" TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_entity_idx" ON "audit_log" ("entity_type", "entity_id", "audit_entry_id");
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "transactions_account_id_event_date_idx";
+
+DELETE FROM operation_types WHERE operation_type_id IN (5, 6);
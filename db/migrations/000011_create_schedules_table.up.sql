@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS "schedules" (
+    "schedule_id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "operation_type_id" INT NOT NULL,
+    "amount" NUMERIC(12, 4) NOT NULL,
+    "recurrence" TEXT NOT NULL,
+    "start_date" TIMESTAMPTZ NOT NULL,
+    "end_date" TIMESTAMPTZ,
+    "next_run_at" TIMESTAMPTZ,
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+	  REFERENCES accounts(account_id),
+    CONSTRAINT fk_operation_type
+      FOREIGN KEY(operation_type_id)
+	  REFERENCES operation_types(operation_type_id)
+);
+
+CREATE INDEX IF NOT EXISTS "schedules_next_run_at_idx" ON "schedules" ("next_run_at") WHERE "next_run_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "schedules_account_id_idx" ON "schedules" ("account_id", "schedule_id");
+
+-- The keys of the postings made at least once, written along with their
+-- events so a posting retried is found, see repository.WithDedupKey.
+CREATE TABLE IF NOT EXISTS "transaction_dedup_keys" (
+    "dedup_key" TEXT PRIMARY KEY,
+    "transaction_id" BIGINT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
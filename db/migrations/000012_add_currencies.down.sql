@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "fx_rates";
+
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "fx_rate_id";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "fx_rate";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "original_currency";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "original_amount";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "currency";
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "currency";
@@ -0,0 +1,22 @@
+-- The accounts opened so far are billed in reais, see model.DEFAULT_CURRENCY.
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "currency" CHAR(3) NOT NULL DEFAULT 'BRL';
+
+-- The amount of a transaction is in the currency of its account. The ones
+-- made in another currency keep their original amount and the rate they
+-- were converted at.
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "currency" CHAR(3) NOT NULL DEFAULT 'BRL';
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "original_amount" NUMERIC(12, 4);
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "original_currency" CHAR(3);
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "fx_rate" NUMERIC(18, 8);
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "fx_rate_id" BIGINT;
+
+-- One unit of base_currency is worth rate units of quote_currency from
+-- effective_at until the next rate of the pair.
+CREATE TABLE IF NOT EXISTS "fx_rates" (
+    "fx_rate_id" BIGSERIAL PRIMARY KEY,
+    "base_currency" CHAR(3) NOT NULL,
+    "quote_currency" CHAR(3) NOT NULL,
+    "rate" NUMERIC(18, 8) NOT NULL CHECK ("rate" > 0),
+    "effective_at" TIMESTAMPTZ NOT NULL,
+    UNIQUE ("base_currency", "quote_currency", "effective_at")
+);
@@ -0,0 +1,26 @@
+-- The PAN of the cards is never stored, pan_token stands for it. Limits of
+-- zero cap nothing.
+CREATE TABLE IF NOT EXISTS "cards" (
+    "card_id" SERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "pan_token" TEXT NOT NULL UNIQUE,
+    "last_four" CHAR(4) NOT NULL,
+    "expiry_month" SMALLINT NOT NULL CHECK ("expiry_month" BETWEEN 1 AND 12),
+    "expiry_year" SMALLINT NOT NULL,
+    "status" TEXT NOT NULL,
+    "type" TEXT NOT NULL,
+    "transaction_limit" NUMERIC(12, 4) NOT NULL DEFAULT 0,
+    "daily_limit" NUMERIC(12, 4) NOT NULL DEFAULT 0,
+    "replaced_by" INT,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+	  REFERENCES accounts(account_id),
+    CONSTRAINT fk_replaced_by
+      FOREIGN KEY(replaced_by)
+	  REFERENCES cards(card_id)
+);
+
+CREATE INDEX IF NOT EXISTS "cards_account_id_idx" ON "cards" ("account_id", "card_id");
+
+ALTER TABLE "transactions" ADD COLUMN IF NOT EXISTS "card_id" INT;
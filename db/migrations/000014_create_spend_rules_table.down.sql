@@ -0,0 +1,7 @@
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "category";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "merchant_country";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "mcc";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "merchant_id";
+ALTER TABLE "transactions" DROP COLUMN IF EXISTS "merchant_name";
+
+DROP TABLE IF EXISTS "spend_rules";
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "risk_counters";
+DROP TABLE IF EXISTS "risk_decisions";
+DROP TABLE IF EXISTS "risk_rules";
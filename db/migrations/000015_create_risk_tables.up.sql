@@ -0,0 +1,41 @@
+-- The risk rules stored besides the ones of the configuration.
+CREATE TABLE IF NOT EXISTS "risk_rules" (
+    "risk_rule_id" SERIAL PRIMARY KEY,
+    "name" TEXT NOT NULL UNIQUE,
+    "expression" TEXT NOT NULL,
+    "action" TEXT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Every decision on a transaction, rules holding the JSON array of the
+-- names of the rules that fired.
+CREATE TABLE IF NOT EXISTS "risk_decisions" (
+    "risk_decision_id" BIGSERIAL PRIMARY KEY,
+    "account_id" INT NOT NULL,
+    "operation_type_id" INT NOT NULL,
+    "amount" NUMERIC(12, 4) NOT NULL,
+    "merchant_country" TEXT NOT NULL DEFAULT '',
+    "outcome" TEXT NOT NULL,
+    "rules" TEXT NOT NULL,
+    "created_at" TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+	  REFERENCES accounts(account_id)
+);
+
+CREATE INDEX IF NOT EXISTS "risk_decisions_account_id_idx" ON "risk_decisions" ("account_id", "risk_decision_id");
+
+-- The transactions let through in the current minute and day of each
+-- account. A row only holds the period starting at period_start, the
+-- counts start over with the next one.
+CREATE TABLE IF NOT EXISTS "risk_counters" (
+    "account_id" INT NOT NULL,
+    "period" TEXT NOT NULL,
+    "period_start" TIMESTAMPTZ NOT NULL,
+    "transactions" INT NOT NULL,
+    "spent" NUMERIC(16, 4) NOT NULL,
+    PRIMARY KEY ("account_id", "period"),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+	  REFERENCES accounts(account_id)
+);
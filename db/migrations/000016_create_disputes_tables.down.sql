@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS "dispute_changes";
+DROP TABLE IF EXISTS "disputes";
+
+DELETE FROM operation_types WHERE operation_type_id = 7;
@@ -0,0 +1,22 @@
+-- The personal data encrypted under the master keys cannot be read back
+-- before them, nor decrypted by SQL, so this refuses to run while any is
+-- left rather than losing it.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM "accounts" WHERE "document_number_ciphertext" IS NOT NULL) THEN
+        RAISE EXCEPTION 'accounts hold encrypted document numbers, which would be lost';
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM "holders" WHERE "pii_key_id" IS NOT NULL) THEN
+        RAISE EXCEPTION 'holders hold personal data encrypted under the master keys, which would be lost';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS "accounts_document_number_index_idx";
+
+ALTER TABLE "holders" DROP COLUMN IF EXISTS "pii_key_id";
+
+ALTER TABLE "accounts" ALTER COLUMN "document_number" SET NOT NULL;
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "pii_key_id";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "document_number_index";
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "document_number_ciphertext";
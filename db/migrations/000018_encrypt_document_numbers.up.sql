@@ -0,0 +1,91 @@
+-- The document numbers are encrypted by the application, along with the
+-- blind index they are looked up by and the master key they are encrypted
+-- under. The ones stored before stay in "document_number" until the
+-- rotator of the application encrypts them, which sets it to NULL.
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "document_number_ciphertext" TEXT;
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "document_number_index" TEXT;
+ALTER TABLE "accounts" ADD COLUMN IF NOT EXISTS "pii_key_id" TEXT;
+ALTER TABLE "accounts" ALTER COLUMN "document_number" DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "accounts_document_number_index_idx" ON "accounts" ("document_number_index", "account_id");
+
+-- The master key the personal data of the holder is encrypted under, NULL
+-- for the key the holders were encrypted with before the master keys.
+ALTER TABLE "holders" ADD COLUMN IF NOT EXISTS "pii_key_id" TEXT;
+
+-- The AccountOpened events and the snapshots of the accounts in the audit
+-- log no longer carry the document number.
+UPDATE "events" SET "data" = ("data"::JSONB - 'document_number')::TEXT
+    WHERE "event_type" = 'AccountOpened' AND "data"::JSONB ? 'document_number';
+
+ALTER TABLE "audit_log" DISABLE TRIGGER "audit_log_append_only";
+
+UPDATE "audit_log" SET "snapshot_before" = ("snapshot_before"::JSONB - 'document_number')::TEXT
+    WHERE "entity_type" = 'account' AND "snapshot_before"::JSONB ? 'document_number';
+
+UPDATE "audit_log" SET "snapshot_after" = ("snapshot_after"::JSONB - 'document_number')::TEXT
+    WHERE "entity_type" = 'account' AND "snapshot_after"::JSONB ? 'document_number';
+
+-- The hash of an entry as computed by audit.Hash: each field prefixed by
+-- its length in bytes, the time in milliseconds.
+CREATE FUNCTION pg_temp.audit_hash(entry "audit_log", prev_hash TEXT) RETURNS TEXT AS $$
+    SELECT encode(sha256(convert_to(string_agg(octet_length(field) || ':' || field, '' ORDER BY n), 'UTF8')), 'hex')
+    FROM unnest(ARRAY[
+        prev_hash,
+        entry."action",
+        entry."entity_type",
+        entry."entity_id"::TEXT,
+        entry."actor",
+        entry."client_ip",
+        entry."request_id",
+        entry."snapshot_before",
+        entry."snapshot_after",
+        floor(extract(EPOCH FROM entry."created_at") * 1000)::BIGINT::TEXT
+    ]) WITH ORDINALITY AS f(field, n)
+$$ LANGUAGE SQL;
+
+-- The snapshots changed, so the whole chain is hashed again and a reanchor
+-- entry appended, recording the hash the log ended on before along with
+-- the one its last entry has now.
+DO $$
+DECLARE
+    entry "audit_log";
+    previous TEXT;
+    head TEXT := '';
+    head_id BIGINT;
+    old_hash TEXT;
+    anchor "audit_log";
+BEGIN
+    -- The hashes are unique, so the old ones are moved out of the way,
+    -- keeping the one to record, before being set again.
+    UPDATE "audit_log" SET "prev_hash" = 'rehash:' || "audit_entry_id", "hash" = 'rehash:' || "audit_entry_id" || ':' || "hash";
+
+    FOR entry IN SELECT * FROM "audit_log" ORDER BY "audit_entry_id" LOOP
+        old_hash := substr(entry."hash", length('rehash:' || entry."audit_entry_id" || ':') + 1);
+        head_id := entry."audit_entry_id";
+        previous := head;
+        head := pg_temp.audit_hash(entry, previous);
+
+        UPDATE "audit_log" SET "prev_hash" = previous, "hash" = head WHERE "audit_entry_id" = entry."audit_entry_id";
+    END LOOP;
+
+    IF head_id IS NULL THEN
+        RETURN;
+    END IF;
+
+    anchor."action" := 'reanchor';
+    anchor."entity_type" := 'audit_log';
+    anchor."entity_id" := head_id;
+    anchor."actor" := 'system';
+    anchor."client_ip" := '';
+    anchor."request_id" := '';
+    anchor."snapshot_before" := jsonb_build_object('hash', old_hash)::TEXT;
+    anchor."snapshot_after" := jsonb_build_object('hash', head)::TEXT;
+    anchor."created_at" := date_trunc('milliseconds', now());
+
+    INSERT INTO "audit_log" ("action", "entity_type", "entity_id", "actor", "client_ip", "request_id", "snapshot_before", "snapshot_after", "created_at", "prev_hash", "hash")
+        VALUES (anchor."action", anchor."entity_type", anchor."entity_id", anchor."actor", anchor."client_ip", anchor."request_id",
+            anchor."snapshot_before", anchor."snapshot_after", anchor."created_at", head, pg_temp.audit_hash(anchor, head));
+END $$;
+
+ALTER TABLE "audit_log" ENABLE TRIGGER "audit_log_append_only";
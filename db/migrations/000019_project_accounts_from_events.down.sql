@@ -0,0 +1,7 @@
+ALTER TABLE "events" ADD CONSTRAINT "fk_account" FOREIGN KEY ("account_id") REFERENCES "accounts" ("account_id");
+
+ALTER TABLE "projections" DROP COLUMN IF EXISTS "position_xid";
+
+DROP INDEX IF EXISTS "events_transaction_xid_idx";
+
+ALTER TABLE "events" DROP COLUMN IF EXISTS "transaction_xid";
@@ -0,0 +1,17 @@
+-- Commands append to the events table without locking it, so an event can
+-- be committed after one with a higher ID. The projections apply the
+-- events in the order of the ID of the transaction that appended them,
+-- once no transaction started before is still running. The events
+-- appended before get the ID of this transaction, which the projections
+-- resume from.
+ALTER TABLE "events" ADD COLUMN IF NOT EXISTS "transaction_xid" XID8 NOT NULL DEFAULT pg_current_xact_id();
+
+CREATE INDEX IF NOT EXISTS "events_transaction_xid_idx" ON "events" ("transaction_xid", "event_id");
+
+ALTER TABLE "projections" ADD COLUMN IF NOT EXISTS "position_xid" XID8 NOT NULL DEFAULT '0';
+
+UPDATE "projections" SET "position_xid" = pg_current_xact_id();
+
+-- The accounts are projected from their AccountOpened events, which are
+-- appended before.
+ALTER TABLE "events" DROP CONSTRAINT IF EXISTS "fk_account";
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS "accounts" (
+    "account_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "document_number" INTEGER NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "operation_types";
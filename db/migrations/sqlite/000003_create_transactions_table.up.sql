@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "transactions" (
+    "transaction_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "operation_type_id" INTEGER NOT NULL,
+    "amount" NUMERIC(12, 4) NOT NULL,
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id),
+    CONSTRAINT fk_operation_type
+      FOREIGN KEY(operation_type_id)
+      REFERENCES operation_types(operation_type_id)
+);
@@ -0,0 +1,24 @@
+-- SQLite cannot add a column with a non-constant default, so the table is
+-- rebuilt. Timestamps are kept as UTC text, which sorts chronologically.
+CREATE TABLE "transactions_new" (
+    "transaction_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "operation_type_id" INTEGER NOT NULL,
+    "amount" NUMERIC(12, 4) NOT NULL,
+    "created_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id),
+    CONSTRAINT fk_operation_type
+      FOREIGN KEY(operation_type_id)
+      REFERENCES operation_types(operation_type_id)
+);
+
+INSERT INTO "transactions_new" ("transaction_id", "account_id", "operation_type_id", "amount")
+    SELECT "transaction_id", "account_id", "operation_type_id", "amount" FROM "transactions";
+
+DROP TABLE "transactions";
+
+ALTER TABLE "transactions_new" RENAME TO "transactions";
+
+CREATE INDEX IF NOT EXISTS "transactions_account_id_created_at_idx" ON "transactions" ("account_id", "created_at", "transaction_id");
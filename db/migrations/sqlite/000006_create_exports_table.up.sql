@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS "exports" (
+    "export_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "format" TEXT NOT NULL,
+    "period_from" TEXT,
+    "period_to" TEXT,
+    "status" TEXT NOT NULL,
+    "rows" INTEGER NOT NULL DEFAULT 0,
+    "error" TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id)
+);
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS "audit_log" (
+    "audit_entry_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "action" TEXT NOT NULL,
+    "entity_type" TEXT NOT NULL,
+    "entity_id" INTEGER NOT NULL,
+    "actor" TEXT NOT NULL,
+    "client_ip" TEXT NOT NULL DEFAULT '',
+    "request_id" TEXT NOT NULL DEFAULT '',
+    "snapshot_before" TEXT NOT NULL,
+    "snapshot_after" TEXT NOT NULL,
+    "created_at" TEXT NOT NULL,
+    -- An entry can only follow one other, so the chain never forks.
+    "prev_hash" TEXT NOT NULL UNIQUE,
+    "hash" TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS "audit_log_entity_idx" ON "audit_log" ("entity_type", "entity_id", "audit_entry_id");
+
+CREATE TRIGGER IF NOT EXISTS "audit_log_no_update" BEFORE UPDATE ON "audit_log"
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS "audit_log_no_delete" BEFORE DELETE ON "audit_log"
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "projections";
+
+DROP TABLE IF EXISTS "account_balances";
+
+DROP TABLE IF EXISTS "events";
+
+ALTER TABLE "transactions" DROP COLUMN "reversed";
+
+ALTER TABLE "accounts" DROP COLUMN "blocked";
@@ -0,0 +1,61 @@
+CREATE TABLE IF NOT EXISTS "events" (
+    "event_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "version" INTEGER NOT NULL,
+    "event_type" TEXT NOT NULL,
+    "transaction_id" INTEGER,
+    "data" TEXT NOT NULL,
+    "created_at" TEXT NOT NULL,
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id),
+    -- Two commands appending to a stream at the same version conflict.
+    CONSTRAINT events_stream_version_key UNIQUE (account_id, version)
+);
+
+-- A transaction is posted and reversed at most once.
+CREATE UNIQUE INDEX IF NOT EXISTS "events_transaction_idx" ON "events" ("transaction_id", "event_type") WHERE "transaction_id" IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "events_blocked_idx" ON "events" ("account_id") WHERE "event_type" = 'AccountBlocked';
+
+CREATE TABLE IF NOT EXISTS "account_balances" (
+    "account_id" INTEGER PRIMARY KEY,
+    "balance" NUMERIC(16, 4) NOT NULL,
+    "version" INTEGER NOT NULL,
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id)
+);
+
+-- The position of the last event applied to each read model.
+CREATE TABLE IF NOT EXISTS "projections" (
+    "name" TEXT PRIMARY KEY,
+    "position" INTEGER NOT NULL
+);
+
+ALTER TABLE "accounts" ADD COLUMN "blocked" BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE "transactions" ADD COLUMN "reversed" BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- The existing accounts and transactions become the first events of their
+-- streams, and the read models start after them.
+INSERT INTO "events" ("account_id", "version", "event_type", "transaction_id", "data", "created_at")
+    SELECT "account_id", "version", "event_type", "transaction_id", "data", "created_at" FROM (
+        SELECT a."account_id", 1 AS "version", 'AccountOpened' AS "event_type", NULL AS "transaction_id",
+            json_object('account_id', a."account_id", 'document_number', a."document_number") AS "data",
+            COALESCE((SELECT MIN(t."created_at") FROM "transactions" t WHERE t."account_id" = a."account_id"), strftime('%Y-%m-%d %H:%M:%f', 'now')) AS "created_at"
+        FROM "accounts" a
+        UNION ALL
+        SELECT t."account_id", 1 + ROW_NUMBER() OVER (PARTITION BY t."account_id" ORDER BY t."transaction_id"), 'TransactionPosted', t."transaction_id",
+            json_object('transaction_id', t."transaction_id", 'account_id', t."account_id", 'operation_type_id', t."operation_type_id", 'amount', t."amount"),
+            t."created_at"
+        FROM "transactions" t
+    ) ORDER BY "account_id", "version";
+
+INSERT INTO "account_balances" ("account_id", "balance", "version")
+    SELECT a."account_id", COALESCE(SUM(t."amount"), 0), 1 + COUNT(t."transaction_id")
+    FROM "accounts" a LEFT JOIN "transactions" t ON t."account_id" = a."account_id"
+    GROUP BY a."account_id";
+
+INSERT INTO "projections" ("name", "position")
+    SELECT "column1", (SELECT COALESCE(MAX("event_id"), 0) FROM "events") FROM (VALUES ('accounts'), ('transactions'));
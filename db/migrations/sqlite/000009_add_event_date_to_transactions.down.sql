@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "events_account_id_created_at_idx";
+
+DROP TABLE IF EXISTS "balance_snapshots";
+
+ALTER TABLE "transactions" DROP COLUMN "event_date";
@@ -0,0 +1,21 @@
+-- SQLite cannot add a NOT NULL column without a default, the column is
+-- always written by the projection instead.
+ALTER TABLE "transactions" ADD COLUMN "event_date" TEXT;
+
+-- The transactions posted so far took place when they were posted.
+UPDATE "transactions" SET "event_date" = "created_at" WHERE "event_date" IS NULL;
+
+-- A snapshot of the balance of an account at a version of its stream,
+-- created_at being the time of the event at that version.
+CREATE TABLE IF NOT EXISTS "balance_snapshots" (
+    "account_id" INTEGER NOT NULL,
+    "version" INTEGER NOT NULL,
+    "balance" NUMERIC(16, 4) NOT NULL,
+    "created_at" TEXT NOT NULL,
+    PRIMARY KEY ("account_id", "version"),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id)
+);
+
+CREATE INDEX IF NOT EXISTS "events_account_id_created_at_idx" ON "events" ("account_id", "created_at");
@@ -0,0 +1,5 @@
+-- Posted by the accrual engine only, see model.INTEREST and model.LATE_FEE.
+INSERT INTO operation_types (operation_type_id, description) VALUES (5, 'JUROS') ON CONFLICT (operation_type_id) DO NOTHING;
+INSERT INTO operation_types (operation_type_id, description) VALUES (6, 'MULTA POR ATRASO') ON CONFLICT (operation_type_id) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS "transactions_account_id_event_date_idx" ON "transactions" ("account_id", "event_date");
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "transaction_dedup_keys";
+
+DROP INDEX IF EXISTS "schedules_account_id_idx";
+
+DROP INDEX IF EXISTS "schedules_next_run_at_idx";
+
+DROP TABLE IF EXISTS "schedules";
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "fx_rates";
+
+ALTER TABLE "transactions" DROP COLUMN "fx_rate_id";
+ALTER TABLE "transactions" DROP COLUMN "fx_rate";
+ALTER TABLE "transactions" DROP COLUMN "original_currency";
+ALTER TABLE "transactions" DROP COLUMN "original_amount";
+ALTER TABLE "transactions" DROP COLUMN "currency";
+
+ALTER TABLE "accounts" DROP COLUMN "currency";
@@ -0,0 +1,22 @@
+-- The accounts opened so far are billed in reais, see model.DEFAULT_CURRENCY.
+ALTER TABLE "accounts" ADD COLUMN "currency" TEXT NOT NULL DEFAULT 'BRL';
+
+-- The amount of a transaction is in the currency of its account. The ones
+-- made in another currency keep their original amount and the rate they
+-- were converted at.
+ALTER TABLE "transactions" ADD COLUMN "currency" TEXT NOT NULL DEFAULT 'BRL';
+ALTER TABLE "transactions" ADD COLUMN "original_amount" NUMERIC(12, 4);
+ALTER TABLE "transactions" ADD COLUMN "original_currency" TEXT;
+ALTER TABLE "transactions" ADD COLUMN "fx_rate" NUMERIC(18, 8);
+ALTER TABLE "transactions" ADD COLUMN "fx_rate_id" INTEGER;
+
+-- One unit of base_currency is worth rate units of quote_currency from
+-- effective_at until the next rate of the pair.
+CREATE TABLE IF NOT EXISTS "fx_rates" (
+    "fx_rate_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "base_currency" TEXT NOT NULL,
+    "quote_currency" TEXT NOT NULL,
+    "rate" NUMERIC(18, 8) NOT NULL CHECK ("rate" > 0),
+    "effective_at" TEXT NOT NULL,
+    UNIQUE ("base_currency", "quote_currency", "effective_at")
+);
@@ -0,0 +1,5 @@
+ALTER TABLE "transactions" DROP COLUMN "card_id";
+
+DROP INDEX IF EXISTS "cards_account_id_idx";
+
+DROP TABLE IF EXISTS "cards";
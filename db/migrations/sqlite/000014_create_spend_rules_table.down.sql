@@ -0,0 +1,7 @@
+ALTER TABLE "transactions" DROP COLUMN "category";
+ALTER TABLE "transactions" DROP COLUMN "merchant_country";
+ALTER TABLE "transactions" DROP COLUMN "mcc";
+ALTER TABLE "transactions" DROP COLUMN "merchant_id";
+ALTER TABLE "transactions" DROP COLUMN "merchant_name";
+
+DROP TABLE IF EXISTS "spend_rules";
@@ -0,0 +1,23 @@
+-- Allow and deny rules name either an mcc or a category, cap rules a
+-- category, an amount_limit and a period. Empty strings stand for the ones
+-- left out so the unique constraint holds.
+CREATE TABLE IF NOT EXISTS "spend_rules" (
+    "spend_rule_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "type" TEXT NOT NULL,
+    "mcc" TEXT NOT NULL DEFAULT '',
+    "category" TEXT NOT NULL DEFAULT '',
+    "amount_limit" NUMERIC(12, 4) NOT NULL DEFAULT 0,
+    "period" TEXT NOT NULL DEFAULT '',
+    "created_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id),
+    CONSTRAINT spend_rules_unique UNIQUE ("account_id", "type", "mcc", "category", "period")
+);
+
+ALTER TABLE "transactions" ADD COLUMN "merchant_name" TEXT;
+ALTER TABLE "transactions" ADD COLUMN "merchant_id" TEXT;
+ALTER TABLE "transactions" ADD COLUMN "mcc" TEXT;
+ALTER TABLE "transactions" ADD COLUMN "merchant_country" TEXT;
+ALTER TABLE "transactions" ADD COLUMN "category" TEXT;
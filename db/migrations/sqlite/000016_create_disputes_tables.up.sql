@@ -0,0 +1,38 @@
+-- Posted by the disputes only, see model.DISPUTE_CREDIT.
+INSERT INTO operation_types (operation_type_id, description) VALUES (7, 'CREDITO PROVISORIO') ON CONFLICT (operation_type_id) DO NOTHING;
+
+-- A transaction is disputed at most once. deadline_at is NULL once the
+-- dispute is won or lost.
+CREATE TABLE IF NOT EXISTS "disputes" (
+    "dispute_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "transaction_id" INTEGER NOT NULL UNIQUE,
+    "account_id" INTEGER NOT NULL,
+    "amount" NUMERIC(12, 4) NOT NULL,
+    "reason" TEXT NOT NULL,
+    "status" TEXT NOT NULL,
+    "credit_transaction_id" INTEGER,
+    "deadline_at" TEXT,
+    "escalated" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    "updated_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id)
+);
+
+CREATE INDEX IF NOT EXISTS "disputes_deadline_at_idx" ON "disputes" ("deadline_at") WHERE NOT "escalated";
+
+-- Every status a dispute moved to, and every escalation.
+CREATE TABLE IF NOT EXISTS "dispute_changes" (
+    "dispute_change_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "dispute_id" INTEGER NOT NULL,
+    "status" TEXT NOT NULL,
+    "escalated" BOOLEAN NOT NULL DEFAULT FALSE,
+    "note" TEXT NOT NULL DEFAULT '',
+    "created_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CONSTRAINT fk_dispute
+      FOREIGN KEY(dispute_id)
+      REFERENCES disputes(dispute_id)
+);
+
+CREATE INDEX IF NOT EXISTS "dispute_changes_dispute_id_idx" ON "dispute_changes" ("dispute_id", "dispute_change_id");
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "cards_holder_id_idx";
+DROP INDEX IF EXISTS "accounts_holder_id_idx";
+
+ALTER TABLE "cards" DROP COLUMN "holder_id";
+ALTER TABLE "accounts" DROP COLUMN "holder_id";
+
+DROP TABLE IF EXISTS "holders";
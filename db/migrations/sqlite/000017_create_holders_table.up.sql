@@ -0,0 +1,19 @@
+-- The holders of the accounts. Every column but the ID and the timestamps
+-- holds personal data, encrypted by the application, the address as JSON.
+CREATE TABLE IF NOT EXISTS "holders" (
+    "holder_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "name" TEXT NOT NULL,
+    "birth_date" TEXT NOT NULL,
+    "email" TEXT NOT NULL,
+    "phone" TEXT NOT NULL,
+    "address" TEXT NOT NULL,
+    "created_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    "updated_at" TEXT NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+-- The holder owning the account, and the one a card is issued to.
+ALTER TABLE "accounts" ADD COLUMN "holder_id" INTEGER REFERENCES holders(holder_id);
+ALTER TABLE "cards" ADD COLUMN "holder_id" INTEGER REFERENCES holders(holder_id);
+
+CREATE INDEX IF NOT EXISTS "accounts_holder_id_idx" ON "accounts" ("holder_id", "account_id");
+CREATE INDEX IF NOT EXISTS "cards_holder_id_idx" ON "cards" ("holder_id");
@@ -0,0 +1,24 @@
+-- The personal data encrypted under the master keys cannot be read back
+-- before them, nor decrypted by SQL, so this refuses to run while any is
+-- left rather than losing it. SQLite only raises errors from triggers.
+DROP TABLE IF EXISTS temp."encrypted_personal_data";
+CREATE TEMP TABLE "encrypted_personal_data" ("rows" INTEGER NOT NULL);
+
+CREATE TEMP TRIGGER "refuse_encrypted_personal_data" BEFORE INSERT ON "encrypted_personal_data" WHEN NEW."rows" > 0
+BEGIN
+    SELECT RAISE(ABORT, 'accounts or holders hold personal data encrypted under the master keys, which would be lost');
+END;
+
+INSERT INTO "encrypted_personal_data"
+    SELECT (SELECT COUNT(*) FROM "accounts" WHERE "document_number_ciphertext" IS NOT NULL)
+        + (SELECT COUNT(*) FROM "holders" WHERE "pii_key_id" IS NOT NULL);
+
+DROP TABLE "encrypted_personal_data";
+
+DROP INDEX IF EXISTS "accounts_document_number_index_idx";
+
+ALTER TABLE "holders" DROP COLUMN "pii_key_id";
+
+ALTER TABLE "accounts" DROP COLUMN "pii_key_id";
+ALTER TABLE "accounts" DROP COLUMN "document_number_index";
+ALTER TABLE "accounts" DROP COLUMN "document_number_ciphertext";
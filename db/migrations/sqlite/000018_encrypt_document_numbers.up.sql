@@ -0,0 +1,67 @@
+-- The document numbers are encrypted by the application, along with the
+-- blind index they are looked up by and the master key they are encrypted
+-- under. The ones stored before stay in "document_number" until the
+-- rotator of the application encrypts them, which sets it to 0: SQLite
+-- cannot drop its NOT NULL.
+ALTER TABLE "accounts" ADD COLUMN "document_number_ciphertext" TEXT;
+ALTER TABLE "accounts" ADD COLUMN "document_number_index" TEXT;
+ALTER TABLE "accounts" ADD COLUMN "pii_key_id" TEXT;
+
+CREATE INDEX IF NOT EXISTS "accounts_document_number_index_idx" ON "accounts" ("document_number_index", "account_id");
+
+-- The master key the personal data of the holder is encrypted under, NULL
+-- for the key the holders were encrypted with before the master keys.
+ALTER TABLE "holders" ADD COLUMN "pii_key_id" TEXT;
+
+-- The AccountOpened events and the snapshots of the accounts in the audit
+-- log no longer carry the document number.
+UPDATE "events" SET "data" = json_remove("data", '$.document_number')
+    WHERE "event_type" = 'AccountOpened' AND json_type("data", '$.document_number') IS NOT NULL;
+
+DROP TRIGGER IF EXISTS "audit_log_no_update";
+
+UPDATE "audit_log" SET "snapshot_before" = json_remove("snapshot_before", '$.document_number')
+    WHERE "entity_type" = 'account' AND json_type("snapshot_before", '$.document_number') IS NOT NULL;
+
+UPDATE "audit_log" SET "snapshot_after" = json_remove("snapshot_after", '$.document_number')
+    WHERE "entity_type" = 'account' AND json_type("snapshot_after", '$.document_number') IS NOT NULL;
+
+-- The snapshots changed, so the whole chain is hashed again, by the
+-- audit_hash function registered by the application, and a reanchor entry
+-- appended, recording the hash the log ended on before along with the one
+-- its last entry has now.
+DROP TABLE IF EXISTS temp."audit_rehash";
+
+CREATE TEMP TABLE "audit_rehash" AS
+    WITH RECURSIVE "entries" AS (
+        SELECT *, ROW_NUMBER() OVER (ORDER BY "audit_entry_id") AS "n" FROM "audit_log"
+    ), "chain" ("n", "audit_entry_id", "old_hash", "prev_hash", "hash") AS (
+        SELECT "n", "audit_entry_id", "hash", '',
+            audit_hash('', "action", "entity_type", "entity_id", "actor", "client_ip", "request_id", "snapshot_before", "snapshot_after", "created_at")
+        FROM "entries" WHERE "n" = 1
+        UNION ALL
+        SELECT e."n", e."audit_entry_id", e."hash", c."hash",
+            audit_hash(c."hash", e."action", e."entity_type", e."entity_id", e."actor", e."client_ip", e."request_id", e."snapshot_before", e."snapshot_after", e."created_at")
+        FROM "entries" e JOIN "chain" c ON e."n" = c."n" + 1
+    )
+    SELECT * FROM "chain";
+
+UPDATE "audit_log" SET "prev_hash" = r."prev_hash", "hash" = r."hash"
+    FROM "audit_rehash" r WHERE r."audit_entry_id" = "audit_log"."audit_entry_id";
+
+INSERT INTO "audit_log" ("action", "entity_type", "entity_id", "actor", "snapshot_before", "snapshot_after", "created_at", "prev_hash", "hash")
+    SELECT "action", "entity_type", "entity_id", "actor", "snapshot_before", "snapshot_after", "created_at", "prev_hash",
+        audit_hash("prev_hash", "action", "entity_type", "entity_id", "actor", '', '', "snapshot_before", "snapshot_after", "created_at")
+    FROM (
+        SELECT 'reanchor' AS "action", 'audit_log' AS "entity_type", "audit_entry_id" AS "entity_id", 'system' AS "actor",
+            json_object('hash', "old_hash") AS "snapshot_before", json_object('hash', "hash") AS "snapshot_after",
+            strftime('%Y-%m-%d %H:%M:%f', 'now') AS "created_at", "hash" AS "prev_hash"
+        FROM "audit_rehash" ORDER BY "n" DESC LIMIT 1
+    );
+
+DROP TABLE "audit_rehash";
+
+CREATE TRIGGER IF NOT EXISTS "audit_log_no_update" BEFORE UPDATE ON "audit_log"
+BEGIN
+    SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
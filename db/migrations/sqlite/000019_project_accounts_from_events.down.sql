@@ -0,0 +1,26 @@
+CREATE TABLE "events_new" (
+    "event_id" INTEGER PRIMARY KEY AUTOINCREMENT,
+    "account_id" INTEGER NOT NULL,
+    "version" INTEGER NOT NULL,
+    "event_type" TEXT NOT NULL,
+    "transaction_id" INTEGER,
+    "data" TEXT NOT NULL,
+    "created_at" TEXT NOT NULL,
+    CONSTRAINT fk_account
+      FOREIGN KEY(account_id)
+      REFERENCES accounts(account_id),
+    CONSTRAINT events_stream_version_key UNIQUE (account_id, version)
+);
+
+INSERT INTO "events_new" ("event_id", "account_id", "version", "event_type", "transaction_id", "data", "created_at")
+    SELECT "event_id", "account_id", "version", "event_type", "transaction_id", "data", "created_at" FROM "events";
+
+DROP TABLE "events";
+
+ALTER TABLE "events_new" RENAME TO "events";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "events_transaction_idx" ON "events" ("transaction_id", "event_type") WHERE "transaction_id" IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS "events_blocked_idx" ON "events" ("account_id") WHERE "event_type" = 'AccountBlocked';
+
+CREATE INDEX IF NOT EXISTS "events_account_id_created_at_idx" ON "events" ("account_id", "created_at");
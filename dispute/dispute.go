@@ -0,0 +1,171 @@
+// Package dispute holds the rules of the disputes the repositories apply,
+// and the sweeper escalating the disputes left past their deadline.
+package dispute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// pollInterval is how often the overdue disputes are looked for.
+const pollInterval = time.Minute
+
+// batchSize is how many overdue disputes are loaded at once.
+const batchSize = 100
+
+// Open returns dispute opened at now on transaction, for the whole amount
+// of the transaction unless dispute has an Amount. It returns
+// repository.ErrNotDisputable when transaction is not a purchase or
+// withdraw, was reversed or is for less than the amount, and
+// repository.ErrInvalidAmount when the amount has more decimals than the
+// currency of the transaction.
+func Open(dispute model.Dispute, transaction model.Transaction, now time.Time) (*model.Dispute, error) {
+	switch transaction.OperationTypeId {
+	case model.CASH_PURCHASE, model.INSTALLMENT_PURCHASE, model.WITHDRAW:
+	default:
+		return nil, repository.ErrNotDisputable
+	}
+
+	if transaction.Reversed {
+		return nil, repository.ErrNotDisputable
+	}
+
+	if dispute.Amount.IsZero() {
+		dispute.Amount = transaction.Amount.Neg()
+	}
+
+	if dispute.Amount.Sign() < 0 || dispute.Amount.Cmp(transaction.Amount.Neg()) > 0 {
+		return nil, repository.ErrNotDisputable
+	}
+
+	if !model.ValidateCurrencyAmount(transaction.Currency, dispute.Amount) {
+		return nil, repository.ErrInvalidAmount
+	}
+
+	dispute.AccountId = transaction.AccountId
+	dispute.Status = model.DISPUTE_STATUS_OPENED
+	dispute.CreditTransactionId = 0
+	dispute.DeadlineAt = model.DisputeDeadline(dispute.Status, now)
+	dispute.Escalated = false
+	dispute.CreatedAt = now
+	dispute.UpdatedAt = now
+
+	return &dispute, nil
+}
+
+// Transition returns dispute moved to status at now, with the deadline of
+// status and no longer escalated. It returns repository.ErrConflict when
+// the dispute cannot move to status.
+func Transition(dispute model.Dispute, status string, now time.Time) (*model.Dispute, error) {
+	if !model.ValidateDisputeTransition(dispute.Status, status) {
+		return nil, repository.ErrConflict
+	}
+
+	dispute.Status = status
+	dispute.DeadlineAt = model.DisputeDeadline(status, now)
+	dispute.Escalated = false
+	dispute.UpdatedAt = now
+
+	return &dispute, nil
+}
+
+// Escalate returns dispute escalated at now. It returns
+// repository.ErrConflict when the dispute is not overdue at now.
+func Escalate(dispute model.Dispute, now time.Time) (*model.Dispute, error) {
+	if !Overdue(dispute, now) {
+		return nil, repository.ErrConflict
+	}
+
+	dispute.Escalated = true
+	dispute.UpdatedAt = now
+
+	return &dispute, nil
+}
+
+// Overdue reports whether dispute is past its deadline at now and not
+// escalated yet.
+func Overdue(dispute model.Dispute, now time.Time) bool {
+	return !dispute.Escalated && dispute.DeadlineAt != nil && !dispute.DeadlineAt.After(now)
+}
+
+// Credit returns the provisional credit of dispute, to be posted as it
+// moves to model.DISPUTE_STATUS_CREDITED.
+func Credit(dispute model.Dispute) model.Transaction {
+	return model.Transaction{
+		AccountId:       dispute.AccountId,
+		OperationTypeId: model.DISPUTE_CREDIT,
+		Amount:          dispute.Amount,
+	}
+}
+
+type Sweeper struct {
+	disputes repository.DisputeRepository
+}
+
+func NewSweeper(disputes repository.DisputeRepository) *Sweeper {
+	return &Sweeper{
+		disputes: disputes,
+	}
+}
+
+// Start escalates the overdue disputes until ctx is done.
+func (s *Sweeper) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		escalated, err := s.Sweep(ctx, time.Now())
+
+		if err != nil {
+			log.Printf("Sweeper#Start: Escalating the overdue disputes failed: %s", err)
+		}
+
+		if escalated > 0 {
+			log.Printf("Sweeper#Start: Escalated %d disputes", escalated)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep escalates every dispute past its deadline at now and returns how
+// many were escalated. A dispute that moved on in the meantime is skipped.
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	escalated := 0
+
+	for {
+		overdue, err := s.disputes.ListOverdueDisputes(now, batchSize)
+		if err != nil {
+			return escalated, err
+		}
+
+		for _, dispute := range overdue {
+			_, err := s.disputes.EscalateDispute(ctx, dispute.DisputeId, now)
+
+			switch {
+			case err == nil:
+				escalated++
+			case errors.Is(err, repository.ErrConflict):
+				log.Printf("Sweeper#Sweep: Dispute %d changed before it was escalated", dispute.DisputeId)
+			default:
+				return escalated, fmt.Errorf("dispute %d: %w", dispute.DisputeId, err)
+			}
+		}
+
+		// The disputes escalated are no longer overdue, so the next batch
+		// holds the ones left.
+		if len(overdue) < batchSize {
+			return escalated, nil
+		}
+	}
+}
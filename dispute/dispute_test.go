@@ -0,0 +1,168 @@
+package dispute
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+var now = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// stubRepository escalates its disputes as the repositories do, but for
+// the one that moved, which is turned down as it moved on in the meantime.
+type stubRepository struct {
+	repository.DisputeRepository
+
+	disputes []model.Dispute
+	moved    uint64
+}
+
+func (s *stubRepository) ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error) {
+	overdue := []model.Dispute{}
+
+	for _, d := range s.disputes {
+		if Overdue(d, now) && len(overdue) < limit {
+			overdue = append(overdue, d)
+		}
+	}
+
+	return overdue, nil
+}
+
+func (s *stubRepository) EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error) {
+	d := &s.disputes[disputeId-1]
+
+	if disputeId == s.moved {
+		d.Status = model.DISPUTE_STATUS_CREDITED
+		d.DeadlineAt = model.DisputeDeadline(d.Status, now)
+
+		return nil, repository.ErrConflict
+	}
+
+	escalated, err := Escalate(*d, now)
+	if err != nil {
+		return nil, err
+	}
+
+	*d = *escalated
+
+	return escalated, nil
+}
+
+func TestOpen(t *testing.T) {
+	purchase := model.Transaction{TransactionId: 3, AccountId: 1, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50"), Currency: "BRL"}
+
+	opened, err := Open(model.Dispute{TransactionId: 3, Reason: "not received"}, purchase, now)
+	require.NoError(t, err)
+
+	deadline := now.AddDate(0, 0, 10)
+
+	assert.Equal(t, model.Dispute{TransactionId: 3, AccountId: 1, Amount: model.MustParseAmount("50"), Reason: "not received", Status: model.DISPUTE_STATUS_OPENED, DeadlineAt: &deadline, CreatedAt: now, UpdatedAt: now}, *opened)
+
+	opened, err = Open(model.Dispute{TransactionId: 3, Amount: model.MustParseAmount("12.5")}, purchase, now)
+	require.NoError(t, err)
+	assert.Equal(t, model.MustParseAmount("12.5"), opened.Amount)
+
+	for _, scenario := range []struct {
+		dispute     model.Dispute
+		transaction model.Transaction
+		expected    error
+	}{
+		{model.Dispute{Amount: model.MustParseAmount("50.01")}, purchase, repository.ErrNotDisputable},
+		{model.Dispute{Amount: model.MustParseAmount("-1")}, purchase, repository.ErrNotDisputable},
+		{model.Dispute{}, model.Transaction{OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("50"), Currency: "BRL"}, repository.ErrNotDisputable},
+		{model.Dispute{}, model.Transaction{OperationTypeId: model.DISPUTE_CREDIT, Amount: model.MustParseAmount("50"), Currency: "BRL"}, repository.ErrNotDisputable},
+		{model.Dispute{}, model.Transaction{OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-50"), Currency: "BRL", Reversed: true}, repository.ErrNotDisputable},
+		{model.Dispute{Amount: model.MustParseAmount("12.5")}, model.Transaction{OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-50"), Currency: "JPY"}, repository.ErrInvalidAmount},
+	} {
+		_, err := Open(scenario.dispute, scenario.transaction, now)
+		assert.ErrorIs(t, err, scenario.expected, scenario)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	deadline := now
+
+	d := model.Dispute{Status: model.DISPUTE_STATUS_OPENED, DeadlineAt: &deadline, Escalated: true}
+
+	later := now.Add(time.Hour)
+
+	credited, err := Transition(d, model.DISPUTE_STATUS_CREDITED, later)
+	require.NoError(t, err)
+	assert.Equal(t, model.DISPUTE_STATUS_CREDITED, credited.Status)
+	assert.Equal(t, later.AddDate(0, 0, 5), *credited.DeadlineAt)
+	assert.False(t, credited.Escalated)
+	assert.Equal(t, later, credited.UpdatedAt)
+
+	_, err = Transition(*credited, model.DISPUTE_STATUS_WON, later)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	review, err := Transition(*credited, model.DISPUTE_STATUS_UNDER_REVIEW, later)
+	require.NoError(t, err)
+
+	won, err := Transition(*review, model.DISPUTE_STATUS_WON, later)
+	require.NoError(t, err)
+	assert.Nil(t, won.DeadlineAt)
+
+	_, err = Transition(*won, model.DISPUTE_STATUS_LOST, later)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+}
+
+func TestEscalate(t *testing.T) {
+	deadline := now
+
+	d := model.Dispute{Status: model.DISPUTE_STATUS_UNDER_REVIEW, DeadlineAt: &deadline}
+
+	_, err := Escalate(d, now.Add(-time.Second))
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	escalated, err := Escalate(d, now)
+	require.NoError(t, err)
+	assert.True(t, escalated.Escalated)
+
+	_, err = Escalate(*escalated, now)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+
+	_, err = Escalate(model.Dispute{Status: model.DISPUTE_STATUS_WON}, now)
+	assert.ErrorIs(t, err, repository.ErrConflict)
+}
+
+func TestSweepEscalatesOverdueDisputes(t *testing.T) {
+	stub := &stubRepository{}
+
+	for n := 0; n < batchSize+2; n++ {
+		deadline := now.Add(time.Duration(n) * time.Minute)
+
+		stub.disputes = append(stub.disputes, model.Dispute{DisputeId: uint64(n + 1), Status: model.DISPUTE_STATUS_OPENED, DeadlineAt: &deadline})
+	}
+
+	// One of them moves on as it is swept.
+	stub.moved = 2
+
+	sweeper := NewSweeper(stub)
+
+	escalated, err := sweeper.Sweep(context.Background(), now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, escalated)
+
+	at := now.Add(time.Duration(batchSize) * time.Minute)
+
+	escalated, err = sweeper.Sweep(context.Background(), at)
+	require.NoError(t, err)
+	assert.Equal(t, batchSize, escalated)
+
+	// Escalated disputes are not escalated again.
+	escalated, err = sweeper.Sweep(context.Background(), at)
+	require.NoError(t, err)
+	assert.Equal(t, 0, escalated)
+
+	assert.False(t, stub.disputes[1].Escalated)
+	assert.True(t, stub.disputes[batchSize].Escalated)
+	assert.False(t, stub.disputes[batchSize+1].Escalated)
+}
@@ -0,0 +1,173 @@
+// Package exporter renders in the background the statements too large to be
+// streamed in a response. Every export is written to a file in a directory
+// of its own, from where it is downloaded once completed.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/statement"
+)
+
+// pollInterval is how often pending exports are looked for, which also
+// picks up the ones left behind by another instance.
+const pollInterval = 30 * time.Second
+
+type Exporter struct {
+	exports  repository.ExportRepository
+	accounts repository.AccountRepository
+	dir      string
+	wake     chan struct{}
+}
+
+// NewExporter keeps the rendered statements in dir. The directory must
+// survive restarts for completed exports to stay downloadable.
+func NewExporter(exports repository.ExportRepository, accounts repository.AccountRepository, dir string) *Exporter {
+	return &Exporter{
+		exports:  exports,
+		accounts: accounts,
+		dir:      dir,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+func (e *Exporter) path(export model.Export) string {
+	return filepath.Join(e.dir, fmt.Sprintf("%d.%s", export.ExportId, export.Format))
+}
+
+// Submit queues the export of a statement.
+func (e *Exporter) Submit(ctx context.Context, export model.Export) (*model.Export, error) {
+	export.Status = model.EXPORT_PENDING
+
+	created, err := e.exports.CreateExport(ctx, export)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+
+	return created, nil
+}
+
+// Open returns the file of a completed export.
+func (e *Exporter) Open(export model.Export) (io.ReadSeekCloser, error) {
+	return os.Open(e.path(export))
+}
+
+// Start runs the pending exports one at a time until ctx is done.
+func (e *Exporter) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.RunPending(ctx); err != nil {
+			log.Printf("Exporter#Start: Running exports failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending runs every pending export, oldest first.
+func (e *Exporter) RunPending(ctx context.Context) error {
+	exports, err := e.exports.ListPendingExports()
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := e.Run(export); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run renders the statement of export. It is written to a temporary file
+// first, so a statement is only found under its final name once complete.
+// Errors reaching the database are returned and leave the export pending,
+// to be run again later, while problems with the file fail it.
+func (e *Exporter) Run(export model.Export) error {
+	account, err := e.accounts.FindAccount(export.AccountId)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return e.fail(export, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	filter := repository.ExportFilter(export)
+	header := statement.Header{
+		Account:     *account,
+		From:        filter.From,
+		To:          filter.To,
+		GeneratedAt: time.Now(),
+	}
+
+	file, err := os.CreateTemp(e.dir, "export-*")
+	if err != nil {
+		return e.fail(export, err)
+	}
+
+	defer os.Remove(file.Name())
+
+	rows, err := statement.Export(file, export.Format, header, filter, e.exports)
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	var pathErr *os.PathError
+
+	if errors.As(err, &pathErr) {
+		return e.fail(export, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(file.Name(), e.path(export)); err != nil {
+		return e.fail(export, err)
+	}
+
+	if _, err := e.exports.FinishExport(export.ExportId, model.EXPORT_COMPLETED, rows, ""); err != nil {
+		return err
+	}
+
+	log.Printf("Exporter#Run: Export %d completed with %d rows", export.ExportId, rows)
+
+	return nil
+}
+
+func (e *Exporter) fail(export model.Export, err error) error {
+	log.Printf("Exporter#Run: Export %d failed: %s", export.ExportId, err)
+
+	_, err = e.exports.FinishExport(export.ExportId, model.EXPORT_FAILED, 0, "The statement could not be written.")
+
+	return err
+}
@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+// failingExportRepository fails to read the statements, like a database
+// going away in the middle of an export.
+type failingExportRepository struct {
+	repository.ExportRepository
+}
+
+func (f *failingExportRepository) StreamStatementLines(filter repository.StatementFilter, fn func(model.StatementLine) error) error {
+	return repository.ErrUnavailable
+}
+
+type fixture struct {
+	exports  repository.ExportRepository
+	accounts repository.AccountRepository
+	dir      string
+	exporter *Exporter
+}
+
+func newFixture(t *testing.T) *fixture {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepositoryMemory(store)
+
+	account, err := accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+	require.NoError(t, err)
+
+	transactions := memory.NewTransactionRepositoryMemory(store)
+
+	for _, amount := range []model.Amount{model.MustParseAmount("-10"), model.MustParseAmount("25")} {
+		operationTypeId := uint32(model.CASH_PURCHASE)
+
+		if amount.Sign() > 0 {
+			operationTypeId = model.PAYMENT
+		}
+
+		_, err := transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: operationTypeId, Amount: amount})
+		require.NoError(t, err)
+	}
+
+	exports := memory.NewExportRepositoryMemory(store)
+	dir := t.TempDir()
+
+	return &fixture{
+		exports:  exports,
+		accounts: accounts,
+		dir:      dir,
+		exporter: NewExporter(exports, accounts, dir),
+	}
+}
+
+func (f *fixture) find(t *testing.T, exportId uint64) *model.Export {
+	export, err := f.exports.FindExport(exportId)
+	require.NoError(t, err)
+
+	return export
+}
+
+func TestRunExportsStatement(t *testing.T) {
+	f := newFixture(t)
+
+	export, err := f.exporter.Submit(context.Background(), model.Export{AccountId: 1, Format: model.EXPORT_FORMAT_CSV})
+	require.NoError(t, err)
+	assert.Equal(t, model.EXPORT_PENDING, export.Status)
+
+	require.NoError(t, f.exporter.Run(*export))
+
+	export = f.find(t, export.ExportId)
+	assert.Equal(t, model.EXPORT_COMPLETED, export.Status)
+	assert.Equal(t, uint64(2), export.Rows)
+
+	file, err := f.exporter.Open(*export)
+	require.NoError(t, err)
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasSuffix(lines[1], ",-10.00"))
+	assert.True(t, strings.HasSuffix(lines[2], ",25.00"))
+
+	// Only the statement is left behind.
+	entries, err := os.ReadDir(f.dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1.csv", entries[0].Name())
+}
+
+func TestRunLeavesExportPendingWhenTheDatabaseFails(t *testing.T) {
+	f := newFixture(t)
+	exporter := NewExporter(&failingExportRepository{f.exports}, f.accounts, f.dir)
+
+	export, err := exporter.Submit(context.Background(), model.Export{AccountId: 1, Format: model.EXPORT_FORMAT_PDF})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, exporter.Run(*export), repository.ErrUnavailable)
+	assert.Equal(t, model.EXPORT_PENDING, f.find(t, export.ExportId).Status)
+
+	// The next run picks it up.
+	require.NoError(t, f.exporter.RunPending(context.Background()))
+	assert.Equal(t, model.EXPORT_COMPLETED, f.find(t, export.ExportId).Status)
+}
+
+func TestRunFailsExportWhenTheFileCannotBeWritten(t *testing.T) {
+	f := newFixture(t)
+	exporter := NewExporter(f.exports, f.accounts, f.dir+"/missing")
+
+	export, err := exporter.Submit(context.Background(), model.Export{AccountId: 1, Format: model.EXPORT_FORMAT_OFX})
+	require.NoError(t, err)
+
+	require.NoError(t, exporter.Run(*export))
+
+	export = f.find(t, export.ExportId)
+	assert.Equal(t, model.EXPORT_FAILED, export.Status)
+	assert.NotEmpty(t, export.Error)
+}
+
+func TestSubmitRejectsUnknownAccount(t *testing.T) {
+	f := newFixture(t)
+
+	_, err := f.exporter.Submit(context.Background(), model.Export{AccountId: 99, Format: model.EXPORT_FORMAT_CSV})
+
+	assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+}
@@ -0,0 +1,102 @@
+// Package fx bills the transactions in the currency of their account. A
+// transaction made in another currency is converted at the rate of the pair
+// effective when it took place, and keeps its original amount and the rate
+// it was converted at. The adapters of the transaction repository convert
+// the transactions as they post them, with the rates of their storage.
+package fx
+
+import (
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// RateFinder returns the rate of base in quote effective at a time, or
+// repository.ErrFxRateNotFound when there is none.
+type RateFinder func(base string, quote string, at time.Time) (*model.FxRate, error)
+
+// Convert returns amount, in the base currency of rate, in its quote
+// currency, rounded half away from zero to the minor units of the quote
+// currency, see ConvertMinorUnits.
+func Convert(amount model.Amount, rate model.FxRate) (model.Amount, error) {
+	return model.AmountFromMinorUnits(ConvertMinorUnits(amount, rate), rate.QuoteCurrency)
+}
+
+// ConvertMinorUnits returns amount, in the base currency of rate, in minor
+// units of its quote currency, rounded half away from zero. The rate is
+// taken as written, not as the float closest to it, and multiplied exactly
+// by the amount, so large amounts lose no minor unit.
+func ConvertMinorUnits(amount model.Amount, rate model.FxRate) *big.Int {
+	original, _ := new(big.Rat).SetString(amount.String())
+	price, _ := new(big.Rat).SetString(strconv.FormatFloat(rate.Rate, 'f', -1, 64))
+
+	product := new(big.Rat).Mul(original, price)
+	product.Mul(product, new(big.Rat).SetInt(minorUnitScale(rate.QuoteCurrency)))
+
+	units, remainder := new(big.Int).QuoRem(product.Num(), product.Denom(), new(big.Int))
+
+	// The quotient is truncated towards zero, so a remainder of at least
+	// half the denominator rounds it away from zero.
+	if new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(product.Denom()) >= 0 {
+		units.Add(units, big.NewInt(int64(product.Num().Sign())))
+	}
+
+	return units
+}
+
+// minorUnitScale returns the number of minor units in a unit of currency.
+func minorUnitScale(currency string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(model.MinorUnits(currency))), nil)
+}
+
+// ConvertTransactions sets the currency of the transactions to the one of
+// their account, found in currencies, converting the OriginalAmount of those
+// with an OriginalCurrency other than it. Amounts with more decimals than
+// their currency takes fail with repository.ErrInvalidAmount.
+func ConvertTransactions(transactions []model.Transaction, currencies map[uint64]string, findRate RateFinder) error {
+	for n := range transactions {
+		transaction := &transactions[n]
+		transaction.Currency = currencies[transaction.AccountId]
+
+		if transaction.OriginalCurrency == "" || transaction.OriginalCurrency == transaction.Currency {
+			transaction.OriginalAmount = model.Amount{}
+			transaction.OriginalCurrency = ""
+			transaction.FxRate = 0
+			transaction.FxRateId = 0
+
+			if !model.ValidateCurrencyAmount(transaction.Currency, transaction.Amount) {
+				return repository.ErrInvalidAmount
+			}
+
+			continue
+		}
+
+		if !model.ValidateCurrencyAmount(transaction.OriginalCurrency, transaction.OriginalAmount) {
+			return repository.ErrInvalidAmount
+		}
+
+		at := transaction.EventDate
+
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		rate, err := findRate(transaction.OriginalCurrency, transaction.Currency, at)
+		if err != nil {
+			return err
+		}
+
+		transaction.Amount, err = Convert(transaction.OriginalAmount, *rate)
+		if err != nil {
+			return repository.ErrInvalidAmount
+		}
+
+		transaction.FxRate = rate.Rate
+		transaction.FxRateId = rate.FxRateId
+	}
+
+	return nil
+}
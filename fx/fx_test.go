@@ -0,0 +1,117 @@
+package fx
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+func TestConvertRoundsToMinorUnits(t *testing.T) {
+	scenarios := []struct {
+		amount   string
+		rate     model.FxRate
+		expected string
+	}{
+		{"-10.15", model.FxRate{BaseCurrency: "USD", QuoteCurrency: "JPY", Rate: 150.02}, "-1523"},
+		{"10", model.FxRate{BaseCurrency: "USD", QuoteCurrency: "BHD", Rate: 0.37665}, "3.767"},
+		{"10.1", model.FxRate{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5.005}, "50.55"},
+	}
+
+	for _, scenario := range scenarios {
+		converted, err := Convert(model.MustParseAmount(scenario.amount), scenario.rate)
+
+		require.NoError(t, err)
+		assert.Equal(t, model.MustParseAmount(scenario.expected), converted)
+	}
+}
+
+func TestConvertLargeAmounts(t *testing.T) {
+	kwd := model.FxRate{BaseCurrency: "USD", QuoteCurrency: "KWD", Rate: 0.30712}
+	bhd := model.FxRate{BaseCurrency: "USD", QuoteCurrency: "BHD", Rate: 0.4999}
+
+	// The minor units of the three decimal currencies are kept, which the
+	// float32 closest to the amount would lose.
+	assert.Equal(t, big.NewInt(12284803), ConvertMinorUnits(model.MustParseAmount("40000.01"), kwd))
+
+	converted, err := Convert(model.MustParseAmount("40000.01"), kwd)
+	require.NoError(t, err)
+	assert.Equal(t, "12284.803", converted.String())
+
+	assert.Equal(t, big.NewInt(-12497750), ConvertMinorUnits(model.MustParseAmount("-25000.5"), bhd))
+
+	converted, err = Convert(model.MustParseAmount("-25000.5"), bhd)
+	require.NoError(t, err)
+	assert.Equal(t, "-12497.75", converted.String())
+
+	converted, err = Convert(model.MustParseAmount("99999999.99"), bhd)
+	require.NoError(t, err)
+	assert.Equal(t, "49989999.995", converted.String())
+
+	_, err = Convert(model.MustParseAmount("99999999.99"), model.FxRate{BaseCurrency: "USD", QuoteCurrency: "JPY", Rate: 150})
+	assert.ErrorIs(t, err, model.ErrAmountRange)
+}
+
+func TestConvertTransactions(t *testing.T) {
+	eventDate := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	transactions := []model.Transaction{
+		{AccountId: 1, Amount: model.MustParseAmount("-10"), OriginalAmount: model.MustParseAmount("-10"), OriginalCurrency: "USD", EventDate: eventDate},
+		{AccountId: 1, Amount: model.MustParseAmount("20.5")},
+		{AccountId: 2, Amount: model.MustParseAmount("5"), OriginalAmount: model.MustParseAmount("5"), OriginalCurrency: "JPY"},
+	}
+
+	var found []time.Time
+
+	findRate := func(base string, quote string, at time.Time) (*model.FxRate, error) {
+		found = append(found, at)
+
+		assert.Equal(t, "USD", base)
+		assert.Equal(t, "BRL", quote)
+
+		return &model.FxRate{FxRateId: 4, BaseCurrency: base, QuoteCurrency: quote, Rate: 4.9876}, nil
+	}
+
+	err := ConvertTransactions(transactions, map[uint64]string{1: "BRL", 2: "JPY"}, findRate)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{eventDate}, found)
+
+	assert.Equal(t, model.Transaction{AccountId: 1, Currency: "BRL", Amount: model.MustParseAmount("-49.88"), OriginalAmount: model.MustParseAmount("-10"), OriginalCurrency: "USD", FxRate: 4.9876, FxRateId: 4, EventDate: eventDate}, transactions[0])
+	assert.Equal(t, model.Transaction{AccountId: 1, Currency: "BRL", Amount: model.MustParseAmount("20.5")}, transactions[1])
+	assert.Equal(t, model.Transaction{AccountId: 2, Currency: "JPY", Amount: model.MustParseAmount("5")}, transactions[2])
+}
+
+func TestConvertTransactionsFails(t *testing.T) {
+	noRate := func(base string, quote string, at time.Time) (*model.FxRate, error) {
+		return nil, repository.ErrFxRateNotFound
+	}
+
+	currencies := map[uint64]string{1: "JPY", 2: "BHD"}
+
+	tests := []struct {
+		name        string
+		transaction model.Transaction
+		err         error
+	}{
+		{"decimals in JPY", model.Transaction{AccountId: 1, Amount: model.MustParseAmount("-10.5")}, repository.ErrInvalidAmount},
+		{"four decimals in BHD", model.Transaction{AccountId: 2, Amount: model.MustParseAmount("-1.2345")}, repository.ErrInvalidAmount},
+		{"three decimals in USD", model.Transaction{AccountId: 1, OriginalAmount: model.MustParseAmount("-1.125"), OriginalCurrency: "USD"}, repository.ErrInvalidAmount},
+		{"no rate", model.Transaction{AccountId: 1, OriginalAmount: model.MustParseAmount("-1.25"), OriginalCurrency: "USD"}, repository.ErrFxRateNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ConvertTransactions([]model.Transaction{test.transaction}, currencies, noRate)
+
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+
+	assert.NoError(t, ConvertTransactions([]model.Transaction{{AccountId: 2, Amount: model.MustParseAmount("-1.234")}}, currencies, noRate))
+}
@@ -0,0 +1,56 @@
+package graphqlapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const maxFirst = 100
+
+type PageInfo struct {
+	HasNextPage bool
+	EndCursor   *string
+}
+
+// encodeCursor builds the opaque cursor of a record, which holds its type so
+// a cursor of one connection cannot be used in another.
+func encodeCursor(kind string, id uint64) string {
+	return base64.StdEncoding.EncodeToString([]byte(kind + ":" + strconv.FormatUint(id, 10)))
+}
+
+// newPage turns the first and after arguments of a connection into a page
+// one record larger than requested, so hasNextPage can be answered without
+// an extra query.
+func newPage(kind string, first *int32, after *string) (repository.Page, int, error) {
+	limit := repository.DefaultPageLimit
+
+	if first != nil {
+		if *first < 0 || *first > maxFirst {
+			return repository.Page{}, 0, newError(fmt.Sprintf("The first argument must be between 0 and %d.", maxFirst), codeInvalidRequest)
+		}
+
+		limit = int(*first)
+	}
+
+	page := repository.Page{Limit: limit + 1}
+
+	if after != nil {
+		decoded, err := base64.StdEncoding.DecodeString(*after)
+		prefix := kind + ":"
+
+		if err != nil || !strings.HasPrefix(string(decoded), prefix) {
+			return repository.Page{}, 0, newError("The after cursor is invalid.", codeInvalidRequest)
+		}
+
+		page.AfterId, err = strconv.ParseUint(strings.TrimPrefix(string(decoded), prefix), 10, 64)
+		if err != nil {
+			return repository.Page{}, 0, newError("The after cursor is invalid.", codeInvalidRequest)
+		}
+	}
+
+	return page, limit, nil
+}
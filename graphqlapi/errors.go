@@ -0,0 +1,81 @@
+package graphqlapi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+const codeInvalidRequest = handler.CodeInvalidRequest
+
+// resolverError is sent in the errors of the GraphQL response, with the
+// same code the REST API uses in its extensions.
+type resolverError struct {
+	message string
+	code    string
+	fields  handler.ValidationErrors
+}
+
+func newError(message string, code string) *resolverError {
+	return &resolverError{message: message, code: code}
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+
+	if len(e.fields) > 0 {
+		extensions["errors"] = e.fields
+	}
+
+	return extensions
+}
+
+func errorValidation(err error) error {
+	var validationErrors handler.ValidationErrors
+
+	if !errors.As(err, &validationErrors) {
+		return newError(err.Error(), codeInvalidRequest)
+	}
+
+	return &resolverError{message: validationErrors.Error(), code: codeInvalidRequest, fields: validationErrors}
+}
+
+func errorRepository(err error, message string) error {
+	log.Printf("Repository error: %s, %s", err, message)
+
+	var declined *risk.DeclinedError
+
+	switch {
+	case errors.As(err, &declined):
+		return newError(fmt.Sprintf("The transaction was declined by the risk rules: %s.", strings.Join(declined.Decision.Rules, ", ")), handler.CodeRiskDeclined)
+	case errors.Is(err, repository.ErrNotFound):
+		return newError(message, handler.CodeNotFound)
+	case errors.Is(err, repository.ErrConflict):
+		return newError(message, handler.CodeConflict)
+	case errors.Is(err, repository.ErrForeignKeyViolation):
+		return newError(message, handler.CodeInvalidReference)
+	case errors.Is(err, repository.ErrAccountBlocked):
+		return newError("The account is blocked and takes no more transactions.", handler.CodeAccountBlocked)
+	case errors.Is(err, repository.ErrFxRateNotFound):
+		return newError("No exchange rate from the currency of the transaction to the one of its account was effective at its event date.", handler.CodeFxRateNotFound)
+	case errors.Is(err, repository.ErrInvalidAmount):
+		return newError("The amount has more decimals than the currency of the account takes.", handler.CodeInvalidAmount)
+	case errors.Is(err, repository.ErrVersionConflict):
+		return newError("The account changed since the expected version.", handler.CodePreconditionFailed)
+	case errors.Is(err, repository.ErrUnavailable):
+		return newError("The service is temporarily unavailable. Please try again later.", handler.CodeUnavailable)
+	case errors.Is(err, repository.ErrTimeout):
+		return newError("The request took too long to complete. Please try again later.", handler.CodeTimeout)
+	default:
+		return newError("An unexpected error occurred.", handler.CodeInternalError)
+	}
+}
@@ -0,0 +1,52 @@
+// Package graphqlapi serves a GraphQL endpoint for accounts and their
+// transactions on top of the repositories used by the REST API.
+package graphqlapi
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// batchWait is how long a loader waits for sibling resolvers to request
+// their keys before hitting the repository.
+const batchWait = 2 * time.Millisecond
+
+type loadersKey struct{}
+
+// NewHandler returns the /graphql handler. Every request gets its own
+// loaders, so batches never mix keys of different requests. The transactions
+// are assessed with risk, which may be nil, like in the REST API.
+func NewHandler(accountRepository repository.AccountRepository, transactionRepository repository.TransactionRepository, risk risk.Assessor) (http.Handler, error) {
+	parsed, err := graphql.ParseSchema(
+		schema,
+		&Resolver{accounts: accountRepository, transactions: transactionRepository, risk: risk},
+		graphql.UseFieldResolvers(),
+		graphql.MaxParallelism(repository.DefaultPageLimit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &relay.Handler{Schema: parsed}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), loadersKey{}, newTransactionLoader(transactionRepository, batchWait))
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}), nil
+}
+
+func transactionLoaderFrom(ctx context.Context) *transactionLoader {
+	return ctx.Value(loadersKey{}).(*transactionLoader)
+}
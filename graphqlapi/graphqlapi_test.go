@@ -0,0 +1,141 @@
+package graphqlapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+// countingTransactionRepository counts the batched reads, to prove the
+// transactions of many accounts are loaded with a single call.
+type countingTransactionRepository struct {
+	repository.TransactionRepository
+	calls int32
+}
+
+func (r *countingTransactionRepository) ListTransactionsByAccounts(accountIds []uint64, page repository.Page) (map[uint64][]model.Transaction, error) {
+	atomic.AddInt32(&r.calls, 1)
+
+	return r.TransactionRepository.ListTransactionsByAccounts(accountIds, page)
+}
+
+type response struct {
+	Data   map[string]interface{}
+	Errors []struct {
+		Message    string
+		Extensions map[string]interface{}
+	}
+}
+
+func newTestHandler(t *testing.T) (http.Handler, *countingTransactionRepository) {
+	store := memory.NewStore()
+	transactions := &countingTransactionRepository{TransactionRepository: memory.NewTransactionRepositoryMemory(store)}
+
+	handler, err := NewHandler(memory.NewAccountRepositoryMemory(store), transactions, nil)
+	require.NoError(t, err)
+
+	return handler, transactions
+}
+
+func execute(t *testing.T, handler http.Handler, query string) response {
+	body, err := json.Marshal(map[string]string{"query": query})
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var result response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &result))
+
+	return result
+}
+
+func TestAccountsWithTransactionsAreBatched(t *testing.T) {
+	handler, transactions := newTestHandler(t)
+
+	for i := 1; i <= 3; i++ {
+		result := execute(t, handler, fmt.Sprintf(`mutation { createAccount(input: {documentNumber: "1234567%d"}) { id } }`, i))
+		require.Empty(t, result.Errors)
+
+		result = execute(t, handler, fmt.Sprintf(`mutation { createTransaction(input: {accountId: "%d", operationTypeId: 4, amount: 10.5}) { id } }`, i))
+		require.Empty(t, result.Errors)
+	}
+
+	result := execute(t, handler, `{ accounts(first: 2) { edges { node { id transactions { edges { node { id amount } } } } } pageInfo { hasNextPage endCursor } } }`)
+	require.Empty(t, result.Errors)
+
+	connection := result.Data["accounts"].(map[string]interface{})
+	edges := connection["edges"].([]interface{})
+
+	assert.Len(t, edges, 2)
+	assert.Equal(t, true, connection["pageInfo"].(map[string]interface{})["hasNextPage"])
+
+	for _, edge := range edges {
+		node := edge.(map[string]interface{})["node"].(map[string]interface{})
+		txEdges := node["transactions"].(map[string]interface{})["edges"].([]interface{})
+
+		assert.Len(t, txEdges, 1)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&transactions.calls))
+
+	endCursor := connection["pageInfo"].(map[string]interface{})["endCursor"].(string)
+	result = execute(t, handler, fmt.Sprintf(`{ accounts(after: %q) { edges { node { id } } pageInfo { hasNextPage } } }`, endCursor))
+	require.Empty(t, result.Errors)
+
+	edges = result.Data["accounts"].(map[string]interface{})["edges"].([]interface{})
+	require.Len(t, edges, 1)
+	assert.Equal(t, "3", edges[0].(map[string]interface{})["node"].(map[string]interface{})["id"])
+}
+
+func TestAccountQuery(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	result := execute(t, handler, `mutation { createAccount(input: {documentNumber: "12345678"}) { id documentNumber } }`)
+	require.Empty(t, result.Errors)
+
+	result = execute(t, handler, `{ account(id: "1") { id documentNumber } }`)
+	require.Empty(t, result.Errors)
+	assert.Equal(t, map[string]interface{}{"id": "1", "documentNumber": "12345678"}, result.Data["account"])
+
+	result = execute(t, handler, `{ account(id: "42") { id } }`)
+	require.Empty(t, result.Errors)
+	assert.Nil(t, result.Data["account"])
+}
+
+func TestErrorsCarryCodes(t *testing.T) {
+	handler, _ := newTestHandler(t)
+
+	tests := []struct {
+		name  string
+		query string
+		code  string
+	}{
+		{"invalid document number", `mutation { createAccount(input: {documentNumber: "abc"}) { id } }`, "invalid_request"},
+		{"unknown account", `mutation { createTransaction(input: {accountId: "42", operationTypeId: 4, amount: 10}) { id } }`, "invalid_reference"},
+		{"invalid amount sign", `mutation { createTransaction(input: {accountId: "1", operationTypeId: 1, amount: 10}) { id } }`, "invalid_request"},
+		{"invalid cursor", `{ accounts(after: "bogus") { edges { cursor } } }`, "invalid_request"},
+		{"first out of range", `{ accounts(first: 1000) { edges { cursor } } }`, "invalid_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := execute(t, handler, tt.query)
+
+			require.Len(t, result.Errors, 1)
+			assert.Equal(t, tt.code, result.Errors[0].Extensions["code"])
+		})
+	}
+}
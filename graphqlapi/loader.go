@@ -0,0 +1,69 @@
+package graphqlapi
+
+import (
+	"sync"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// transactionLoader collects the accounts whose transactions are requested
+// while a query is resolved and loads them with a single repository call,
+// avoiding one query per account.
+type transactionLoader struct {
+	repository repository.TransactionRepository
+	wait       time.Duration
+
+	mu      sync.Mutex
+	batches map[repository.Page]*transactionBatch
+}
+
+type transactionBatch struct {
+	accountIds []uint64
+	done       chan struct{}
+
+	transactions map[uint64][]model.Transaction
+	err          error
+}
+
+func newTransactionLoader(transactions repository.TransactionRepository, wait time.Duration) *transactionLoader {
+	return &transactionLoader{
+		repository: transactions,
+		wait:       wait,
+		batches:    map[repository.Page]*transactionBatch{},
+	}
+}
+
+// Load blocks until the batch holding accountId is dispatched. Keys are
+// batched by page, as every account in a batch is read with the same one.
+func (l *transactionLoader) Load(accountId uint64, page repository.Page) ([]model.Transaction, error) {
+	l.mu.Lock()
+
+	batch, ok := l.batches[page]
+
+	if !ok {
+		batch = &transactionBatch{done: make(chan struct{})}
+		l.batches[page] = batch
+
+		time.AfterFunc(l.wait, func() { l.dispatch(page, batch) })
+	}
+
+	batch.accountIds = append(batch.accountIds, accountId)
+
+	l.mu.Unlock()
+
+	<-batch.done
+
+	return batch.transactions[accountId], batch.err
+}
+
+func (l *transactionLoader) dispatch(page repository.Page, batch *transactionBatch) {
+	l.mu.Lock()
+	delete(l.batches, page)
+	l.mu.Unlock()
+
+	batch.transactions, batch.err = l.repository.ListTransactionsByAccounts(batch.accountIds, page)
+
+	close(batch.done)
+}
@@ -0,0 +1,192 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// Resolver is the root resolver of both the queries and the mutations.
+type Resolver struct {
+	accounts     repository.AccountRepository
+	transactions repository.TransactionRepository
+	risk         risk.Assessor
+}
+
+func (r *Resolver) Account(ctx context.Context, args struct{ Id graphql.ID }) (*AccountResolver, error) {
+	accountId, err := parseId(args.Id, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := r.accounts.FindAccount(accountId)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errorRepository(err, "An error occurred when fetching the account.")
+	}
+
+	return &AccountResolver{account: *account}, nil
+}
+
+type accountsArgs struct {
+	Filter *struct{ DocumentNumber *string }
+	First  *int32
+	After  *string
+}
+
+func (r *Resolver) Accounts(ctx context.Context, args accountsArgs) (*AccountConnection, error) {
+	page, limit, err := newPage("account", args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.AccountFilter{}
+
+	if args.Filter != nil && args.Filter.DocumentNumber != nil {
+		filter.DocumentNumber, err = strconv.ParseUint(*args.Filter.DocumentNumber, 10, 64)
+		if err != nil {
+			return nil, newError("The documentNumber filter must be a valid positive integer.", codeInvalidRequest)
+		}
+	}
+
+	accounts, err := r.accounts.ListAccounts(filter, page)
+
+	if err != nil {
+		return nil, errorRepository(err, "An error occurred when listing the accounts.")
+	}
+
+	connection := &AccountConnection{PageInfo: PageInfo{HasNextPage: len(accounts) > limit}}
+
+	if len(accounts) > limit {
+		accounts = accounts[:limit]
+	}
+
+	for _, account := range accounts {
+		connection.Edges = append(connection.Edges, &AccountEdge{
+			Cursor: encodeCursor("account", account.AccountId),
+			Node:   &AccountResolver{account: account},
+		})
+	}
+
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+type createAccountArgs struct {
+	Input struct {
+		DocumentNumber string
+		Currency       *string
+	}
+}
+
+func (r *Resolver) CreateAccount(ctx context.Context, args createAccountArgs) (*AccountResolver, error) {
+	documentNumber, err := strconv.ParseUint(args.Input.DocumentNumber, 10, 64)
+	if err != nil {
+		return nil, errorValidation(handler.ValidationErrors{{
+			Field:   "documentNumber",
+			Code:    handler.FieldCodeInvalidPositiveInteger,
+			Message: "The document_number must be a valid positive integer.",
+		}})
+	}
+
+	payload := &handler.AccountPayload{DocumentNumber: documentNumber}
+
+	if args.Input.Currency != nil {
+		payload.Currency = *args.Input.Currency
+	}
+
+	if err := payload.Validate(); err != nil {
+		return nil, errorValidation(err)
+	}
+
+	account, err := r.accounts.CreateAccount(ctx, model.Account{DocumentNumber: payload.DocumentNumber, Currency: payload.Currency})
+
+	if err != nil {
+		return nil, errorRepository(err, "An account with the provided data already exists.")
+	}
+
+	return &AccountResolver{account: *account}, nil
+}
+
+type createTransactionArgs struct {
+	Input struct {
+		AccountId       graphql.ID
+		OperationTypeId int32
+		Amount          float64
+		Currency        *string
+	}
+}
+
+func (r *Resolver) CreateTransaction(ctx context.Context, args createTransactionArgs) (*TransactionResolver, error) {
+	accountId, err := parseId(args.Input.AccountId, "accountId")
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Input.OperationTypeId < 0 {
+		return nil, errorValidation(handler.ValidationErrors{{
+			Field:   "operationTypeId",
+			Code:    handler.FieldCodeNegativeNumber,
+			Message: "The operation_type_id must not be negative.",
+		}})
+	}
+
+	amount, err := handler.FloatAmount("amount", args.Input.Amount, 64)
+	if err != nil {
+		return nil, errorValidation(err)
+	}
+
+	payload := &handler.TransactionPayload{
+		AccountId:       accountId,
+		OperationTypeId: uint32(args.Input.OperationTypeId),
+		Amount:          amount,
+	}
+
+	if args.Input.Currency != nil {
+		payload.Currency = *args.Input.Currency
+	}
+
+	if err := payload.Validate(); err != nil {
+		return nil, errorValidation(err)
+	}
+
+	if err := risk.Check(ctx, r.risk, payload.Transaction()); err != nil {
+		return nil, errorRepository(err, "The transaction could not be assessed against the risk rules.")
+	}
+
+	transaction, err := r.transactions.CreateTransaction(ctx, payload.Transaction())
+
+	if err != nil {
+		return nil, errorRepository(err, "The provided account does not exist.")
+	}
+
+	return &TransactionResolver{transaction: *transaction}, nil
+}
+
+func parseId(id graphql.ID, field string) (uint64, error) {
+	value, err := strconv.ParseUint(string(id), 10, 64)
+
+	if err != nil || value == 0 {
+		return 0, errorValidation(handler.ValidationErrors{{
+			Field:   field,
+			Code:    handler.FieldCodeInvalidPositiveInteger,
+			Message: "The " + field + " must be a valid positive integer.",
+		}})
+	}
+
+	return value, nil
+}
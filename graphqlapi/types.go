@@ -0,0 +1,144 @@
+package graphqlapi
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type AccountResolver struct {
+	account model.Account
+}
+
+func (a *AccountResolver) Id() graphql.ID {
+	return graphql.ID(strconv.FormatUint(a.account.AccountId, 10))
+}
+
+func (a *AccountResolver) DocumentNumber() string {
+	return strconv.FormatUint(a.account.DocumentNumber, 10)
+}
+
+func (a *AccountResolver) Currency() string {
+	return a.account.Currency
+}
+
+type transactionsArgs struct {
+	First *int32
+	After *string
+}
+
+// Transactions goes through the request loader, so listing many accounts
+// with their transactions costs a single transactions query.
+func (a *AccountResolver) Transactions(ctx context.Context, args transactionsArgs) (*TransactionConnection, error) {
+	page, limit, err := newPage("transaction", args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := transactionLoaderFrom(ctx).Load(a.account.AccountId, page)
+
+	if err != nil {
+		return nil, errorRepository(err, "An error occurred when listing the transactions.")
+	}
+
+	connection := &TransactionConnection{PageInfo: PageInfo{HasNextPage: len(transactions) > limit}}
+
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+
+	for _, transaction := range transactions {
+		connection.Edges = append(connection.Edges, &TransactionEdge{
+			Cursor: encodeCursor("transaction", transaction.TransactionId),
+			Node:   &TransactionResolver{transaction: transaction},
+		})
+	}
+
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+
+	return connection, nil
+}
+
+type TransactionResolver struct {
+	transaction model.Transaction
+}
+
+func (t *TransactionResolver) Id() graphql.ID {
+	return graphql.ID(strconv.FormatUint(t.transaction.TransactionId, 10))
+}
+
+func (t *TransactionResolver) AccountId() graphql.ID {
+	return graphql.ID(strconv.FormatUint(t.transaction.AccountId, 10))
+}
+
+func (t *TransactionResolver) OperationTypeId() int32 {
+	return int32(t.transaction.OperationTypeId)
+}
+
+func (t *TransactionResolver) Amount() float64 {
+	return t.transaction.Amount.Float64()
+}
+
+func (t *TransactionResolver) Currency() string {
+	return t.transaction.Currency
+}
+
+func (t *TransactionResolver) OriginalAmount() *float64 {
+	if t.transaction.OriginalCurrency == "" {
+		return nil
+	}
+
+	amount := t.transaction.OriginalAmount.Float64()
+
+	return &amount
+}
+
+func (t *TransactionResolver) OriginalCurrency() *string {
+	if t.transaction.OriginalCurrency == "" {
+		return nil
+	}
+
+	return &t.transaction.OriginalCurrency
+}
+
+func (t *TransactionResolver) FxRate() *float64 {
+	if t.transaction.OriginalCurrency == "" {
+		return nil
+	}
+
+	return &t.transaction.FxRate
+}
+
+func (t *TransactionResolver) EventDate() string {
+	return t.transaction.EventDate.Format(time.RFC3339Nano)
+}
+
+func (t *TransactionResolver) CreatedAt() string {
+	return t.transaction.CreatedAt.Format(time.RFC3339Nano)
+}
+
+type AccountConnection struct {
+	Edges    []*AccountEdge
+	PageInfo PageInfo
+}
+
+type AccountEdge struct {
+	Cursor string
+	Node   *AccountResolver
+}
+
+type TransactionConnection struct {
+	Edges    []*TransactionEdge
+	PageInfo PageInfo
+}
+
+type TransactionEdge struct {
+	Cursor string
+	Node   *TransactionResolver
+}
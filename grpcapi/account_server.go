@@ -0,0 +1,86 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/grpcapi/pismov1"
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type AccountServer struct {
+	pismov1.UnimplementedAccountServiceServer
+
+	repository repository.AccountRepository
+}
+
+func NewAccountServer(repository repository.AccountRepository) *AccountServer {
+	return &AccountServer{
+		repository: repository,
+	}
+}
+
+func (s *AccountServer) CreateAccount(ctx context.Context, req *pismov1.CreateAccountRequest) (*pismov1.Account, error) {
+	payload := &handler.AccountPayload{DocumentNumber: req.DocumentNumber}
+
+	if err := payload.Validate(); err != nil {
+		return nil, errorValidation(err)
+	}
+
+	account, err := s.repository.CreateAccount(ctx, model.Account{
+		DocumentNumber: payload.DocumentNumber,
+	})
+
+	if err != nil {
+		return nil, errorRepository(err, "An account with the provided data already exists.")
+	}
+
+	return toAccountMessage(*account), nil
+}
+
+func (s *AccountServer) GetAccount(ctx context.Context, req *pismov1.GetAccountRequest) (*pismov1.Account, error) {
+	if req.AccountId == 0 {
+		return nil, errorInvalidArgument("The account_id must be a valid positive integer.")
+	}
+
+	account, err := s.repository.FindAccount(req.AccountId)
+
+	if err != nil {
+		return nil, errorRepository(err, "No account found for the provided account ID.")
+	}
+
+	return toAccountMessage(*account), nil
+}
+
+func (s *AccountServer) ListAccounts(ctx context.Context, req *pismov1.ListAccountsRequest) (*pismov1.ListAccountsResponse, error) {
+	page, err := newPage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.repository.ListAccounts(repository.AccountFilter{DocumentNumber: req.DocumentNumber}, page)
+
+	if err != nil {
+		return nil, errorRepository(err, "An error occurred when listing the accounts.")
+	}
+
+	response := &pismov1.ListAccountsResponse{}
+
+	for _, account := range accounts {
+		response.Accounts = append(response.Accounts, toAccountMessage(account))
+	}
+
+	if len(accounts) > 0 {
+		response.NextPageToken = nextPageToken(page, len(accounts), accounts[len(accounts)-1].AccountId)
+	}
+
+	return response, nil
+}
+
+func toAccountMessage(account model.Account) *pismov1.Account {
+	return &pismov1.Account{
+		AccountId:      account.AccountId,
+		DocumentNumber: account.DocumentNumber,
+	}
+}
@@ -0,0 +1,47 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/handler"
+)
+
+// auditMetadata puts the actor, client IP and request ID of each call in its
+// context, read from the same headers as the REST API, for the repositories
+// to record along with the changes it makes.
+func auditMetadata(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	get := func(key string) string {
+		if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+			return values[0]
+		}
+
+		return ""
+	}
+
+	clientIP := ""
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		clientIP = p.Addr.String()
+
+		if host, _, err := net.SplitHostPort(clientIP); err == nil {
+			clientIP = host
+		}
+	}
+
+	ctx = audit.NewContext(ctx, audit.Metadata{
+		Actor:     audit.ResolveActor(get(handler.ActorHeader), get("Authorization")),
+		ClientIP:  clientIP,
+		RequestId: get(handler.RequestIdHeader),
+	})
+
+	return next(ctx, req)
+}
@@ -0,0 +1,109 @@
+package grpcapi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// errorDomain is sent in the ErrorInfo detail of every error, whose reason
+// holds the same code the REST API sends in its problem documents.
+const errorDomain = "pismo-test"
+
+func newStatus(grpcCode codes.Code, code string, message string, details ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(grpcCode, message)
+
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: errorDomain})
+	if err != nil {
+		return st.Err()
+	}
+
+	if len(details) > 0 {
+		withBadRequest, err := withDetails.WithDetails(&errdetails.BadRequest{FieldViolations: details})
+		if err == nil {
+			withDetails = withBadRequest
+		}
+	}
+
+	return withDetails.Err()
+}
+
+// errorValidation reports every field that failed validation as a
+// BadRequest detail, the same way the REST API lists them in errors.
+func errorValidation(err error) error {
+	var validationErrors handler.ValidationErrors
+
+	if !errors.As(err, &validationErrors) {
+		return newStatus(codes.InvalidArgument, handler.CodeInvalidRequest, err.Error())
+	}
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(validationErrors))
+
+	for i, fieldError := range validationErrors {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       fieldError.Field,
+			Description: fieldError.Message,
+		}
+	}
+
+	return newStatus(codes.InvalidArgument, handler.CodeInvalidRequest, validationErrors.Error(), violations...)
+}
+
+func errorInvalidArgument(message string) error {
+	return newStatus(codes.InvalidArgument, handler.CodeInvalidRequest, message)
+}
+
+// errorRepository maps the repository error kinds to the gRPC codes of the
+// HTTP statuses used by the REST API: InvalidArgument for 422, and
+// Unavailable for 503, the same code for the same error on both APIs.
+func errorRepository(err error, message string) error {
+	log.Printf("Repository error: %s, %s", err, message)
+
+	var declined *risk.DeclinedError
+
+	switch {
+	case errors.As(err, &declined):
+		return newStatus(codes.InvalidArgument, handler.CodeRiskDeclined, fmt.Sprintf("The transaction was declined by the risk rules: %s.", strings.Join(declined.Decision.Rules, ", ")))
+	case errors.Is(err, repository.ErrNotFound):
+		return newStatus(codes.NotFound, handler.CodeNotFound, message)
+	case errors.Is(err, repository.ErrConflict):
+		return newStatus(codes.AlreadyExists, handler.CodeConflict, message)
+	case errors.Is(err, repository.ErrForeignKeyViolation):
+		return newStatus(codes.InvalidArgument, handler.CodeInvalidReference, message)
+	case errors.Is(err, repository.ErrAccountBlocked):
+		return newStatus(codes.InvalidArgument, handler.CodeAccountBlocked, "The account is blocked and takes no more transactions.")
+	case errors.Is(err, repository.ErrFxRateNotFound):
+		return newStatus(codes.InvalidArgument, handler.CodeFxRateNotFound, "No exchange rate from the currency of the transaction to the one of its account was effective at its event date.")
+	case errors.Is(err, repository.ErrInvalidAmount):
+		return newStatus(codes.InvalidArgument, handler.CodeInvalidAmount, "The amount has more decimals than the currency of the account takes.")
+	case errors.Is(err, repository.ErrCardInactive):
+		return newStatus(codes.InvalidArgument, handler.CodeCardInactive, "The card is blocked, replaced or expired and takes no more purchases or withdraws.")
+	case errors.Is(err, repository.ErrCardLimitExceeded):
+		return newStatus(codes.InvalidArgument, handler.CodeCardLimitExceeded, "The amount exceeds the transaction or daily limit of the card.")
+	case errors.Is(err, repository.ErrMerchantDenied):
+		return newStatus(codes.InvalidArgument, handler.CodeMerchantDenied, "The account denies purchases and withdraws at the merchant category code, or the category, of the transaction.")
+	case errors.Is(err, repository.ErrMerchantNotAllowed):
+		return newStatus(codes.InvalidArgument, handler.CodeMerchantNotAllowed, "The account only allows purchases and withdraws at some merchant category codes or categories, and the transaction is at none of them.")
+	case errors.Is(err, repository.ErrCategoryCapExceeded):
+		return newStatus(codes.InvalidArgument, handler.CodeCategoryCapExceeded, "The amount exceeds the spend cap of the account for the category of the transaction.")
+	case errors.Is(err, repository.ErrNotDisputable):
+		return newStatus(codes.InvalidArgument, handler.CodeNotDisputable, "Only purchases and withdraws that were not reversed can be disputed, for up to their amount.")
+	case errors.Is(err, repository.ErrVersionConflict):
+		return newStatus(codes.Aborted, handler.CodePreconditionFailed, "The account changed since the expected version.")
+	case errors.Is(err, repository.ErrUnavailable):
+		return newStatus(codes.Unavailable, handler.CodeUnavailable, "The service is temporarily unavailable. Please try again later.")
+	case errors.Is(err, repository.ErrTimeout):
+		return newStatus(codes.Unavailable, handler.CodeTimeout, "The request took too long to complete. Please try again later.")
+	default:
+		return newStatus(codes.Internal, handler.CodeInternalError, "An unexpected error occurred.")
+	}
+}
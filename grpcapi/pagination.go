@@ -0,0 +1,41 @@
+package grpcapi
+
+import (
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const maxPageSize = 1000
+
+// newPage turns the page size and token of a List request into a
+// repository.Page. Page tokens are the last ID of the previous page and
+// should be treated as opaque by clients.
+func newPage(pageSize int32, pageToken string) (repository.Page, error) {
+	if pageSize < 0 || pageSize > maxPageSize {
+		return repository.Page{}, errorInvalidArgument("The page_size must be between 0 and 1000.")
+	}
+
+	page := repository.Page{Limit: int(pageSize)}
+
+	if pageToken != "" {
+		afterId, err := strconv.ParseUint(pageToken, 10, 64)
+		if err != nil {
+			return repository.Page{}, errorInvalidArgument("The page_token is invalid.")
+		}
+
+		page.AfterId = afterId
+	}
+
+	return page, nil
+}
+
+// nextPageToken returns an empty token when the page was not filled, as
+// there is nothing left to list.
+func nextPageToken(page repository.Page, count int, lastId uint64) string {
+	if count < page.EffectiveLimit() {
+		return ""
+	}
+
+	return strconv.FormatUint(lastId, 10)
+}
@@ -0,0 +1,35 @@
+// Package grpcapi serves accounts and transactions over gRPC, on top of the
+// same repositories as the REST API.
+package grpcapi
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/felipedsi/pismo-test/grpcapi/pismov1"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// NewServer registers the account and transaction services along with the
+// standard health and reflection services. The transactions are assessed
+// with risk, which may be nil, like in the REST API.
+func NewServer(accountRepository repository.AccountRepository, transactionRepository repository.TransactionRepository, risk risk.Assessor) *grpc.Server {
+	server := grpc.NewServer(grpc.UnaryInterceptor(auditMetadata))
+
+	pismov1.RegisterAccountServiceServer(server, NewAccountServer(accountRepository))
+	pismov1.RegisterTransactionServiceServer(server, NewTransactionServer(transactionRepository, risk))
+
+	healthServer := health.NewServer()
+
+	for service := range server.GetServiceInfo() {
+		healthServer.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+
+	return server
+}
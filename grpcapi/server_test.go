@@ -0,0 +1,236 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/felipedsi/pismo-test/grpcapi/pismov1"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+func newTestConnection(t *testing.T) *grpc.ClientConn {
+	return dialTestServer(t, memory.NewStore(), nil)
+}
+
+func dialTestServer(t *testing.T, store *memory.Store, assessor risk.Assessor) *grpc.ClientConn {
+	server := NewServer(memory.NewAccountRepositoryMemory(store), memory.NewTransactionRepositoryMemory(store), assessor)
+
+	listener := bufconn.Listen(1 << 20)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+
+	return ""
+}
+
+func TestAccountService(t *testing.T) {
+	ctx := context.Background()
+	client := pismov1.NewAccountServiceClient(newTestConnection(t))
+
+	created, err := client.CreateAccount(ctx, &pismov1.CreateAccountRequest{DocumentNumber: 123})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), created.AccountId)
+
+	found, err := client.GetAccount(ctx, &pismov1.GetAccountRequest{AccountId: created.AccountId})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(123), found.DocumentNumber)
+
+	_, err = client.GetAccount(ctx, &pismov1.GetAccountRequest{AccountId: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "not_found", errorReason(err))
+
+	_, err = client.CreateAccount(ctx, &pismov1.CreateAccountRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid_request", errorReason(err))
+}
+
+func TestListAccountsPaginates(t *testing.T) {
+	ctx := context.Background()
+	client := pismov1.NewAccountServiceClient(newTestConnection(t))
+
+	for _, documentNumber := range []uint64{1, 2, 3} {
+		_, err := client.CreateAccount(ctx, &pismov1.CreateAccountRequest{DocumentNumber: documentNumber})
+		require.NoError(t, err)
+	}
+
+	firstPage, err := client.ListAccounts(ctx, &pismov1.ListAccountsRequest{PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, firstPage.Accounts, 2)
+	assert.NotEmpty(t, firstPage.NextPageToken)
+
+	secondPage, err := client.ListAccounts(ctx, &pismov1.ListAccountsRequest{PageSize: 2, PageToken: firstPage.NextPageToken})
+	require.NoError(t, err)
+	assert.Len(t, secondPage.Accounts, 1)
+	assert.Empty(t, secondPage.NextPageToken)
+
+	_, err = client.ListAccounts(ctx, &pismov1.ListAccountsRequest{PageToken: "invalid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestTransactionService(t *testing.T) {
+	ctx := context.Background()
+	conn := newTestConnection(t)
+	accounts := pismov1.NewAccountServiceClient(conn)
+	client := pismov1.NewTransactionServiceClient(conn)
+
+	account, err := accounts.CreateAccount(ctx, &pismov1.CreateAccountRequest{DocumentNumber: 123})
+	require.NoError(t, err)
+
+	for _, amount := range []float32{-10, -20, -30} {
+		_, err := client.CreateTransaction(ctx, &pismov1.CreateTransactionRequest{AccountId: account.AccountId, OperationTypeId: 1, Amount: amount})
+		require.NoError(t, err)
+	}
+
+	_, err = client.CreateTransaction(ctx, &pismov1.CreateTransactionRequest{AccountId: 999, OperationTypeId: 1, Amount: -10})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "invalid_reference", errorReason(err))
+
+	_, err = client.CreateTransaction(ctx, &pismov1.CreateTransactionRequest{AccountId: account.AccountId, OperationTypeId: 9, Amount: 10})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	var violations []string
+
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, violation := range badRequest.FieldViolations {
+				violations = append(violations, violation.Field)
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"operation_type_id"}, violations)
+
+	listed, err := client.ListTransactions(ctx, &pismov1.ListTransactionsRequest{AccountId: account.AccountId})
+	require.NoError(t, err)
+	assert.Len(t, listed.Transactions, 3)
+	assert.Empty(t, listed.NextPageToken)
+
+	stream, err := client.StreamTransactions(ctx, &pismov1.StreamTransactionsRequest{AccountId: account.AccountId, AfterTransactionId: 1})
+	require.NoError(t, err)
+
+	var streamed []float32
+
+	for {
+		transaction, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		streamed = append(streamed, transaction.Amount)
+	}
+
+	assert.Equal(t, []float32{-20, -30}, streamed)
+}
+
+func TestTransactionServiceAssessesRisk(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+
+	rule, err := risk.CompileRule(model.RiskRule{Name: "large", Expression: "amount > 1000", Action: model.RISK_OUTCOME_DECLINE})
+	require.NoError(t, err)
+
+	conn := dialTestServer(t, store, risk.NewEngine(memory.NewRiskRepositoryMemory(store), []risk.Rule{rule}))
+	accounts := pismov1.NewAccountServiceClient(conn)
+	client := pismov1.NewTransactionServiceClient(conn)
+
+	account, err := accounts.CreateAccount(ctx, &pismov1.CreateAccountRequest{DocumentNumber: 123})
+	require.NoError(t, err)
+
+	_, err = client.CreateTransaction(ctx, &pismov1.CreateTransactionRequest{AccountId: account.AccountId, OperationTypeId: 1, Amount: -5000})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "risk_declined", errorReason(err))
+	assert.Equal(t, "The transaction was declined by the risk rules: large.", status.Convert(err).Message())
+
+	_, err = client.CreateTransaction(ctx, &pismov1.CreateTransactionRequest{AccountId: account.AccountId, OperationTypeId: 1, Amount: -20})
+	require.NoError(t, err)
+
+	listed, err := client.ListTransactions(ctx, &pismov1.ListTransactionsRequest{AccountId: account.AccountId})
+	require.NoError(t, err)
+	require.Len(t, listed.Transactions, 1)
+	assert.Equal(t, float32(-20), listed.Transactions[0].Amount)
+}
+
+func TestHealthService(t *testing.T) {
+	client := healthpb.NewHealthClient(newTestConnection(t))
+
+	for _, service := range []string{"", "pismo.v1.AccountService", "pismo.v1.TransactionService"} {
+		response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+	}
+}
+
+func TestChangesAreAuditedWithTheCallMetadata(t *testing.T) {
+	store := memory.NewStore()
+	client := pismov1.NewAccountServiceClient(dialTestServer(t, store, nil))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-actor", "alice", "x-request-id", "req-1")
+
+	account, err := client.CreateAccount(ctx, &pismov1.CreateAccountRequest{DocumentNumber: 123})
+	require.NoError(t, err)
+
+	entries, err := memory.NewAuditRepositoryMemory(store).ListAuditEntries(repository.AuditFilter{}, repository.Page{})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	assert.Equal(t, account.AccountId, entries[0].EntityId)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, "req-1", entries[0].RequestId)
+}
+
+func TestErrorRepositoryMatchesTheRESTStatuses(t *testing.T) {
+	for err, expected := range map[error]codes.Code{
+		repository.ErrNotFound:            codes.NotFound,
+		repository.ErrConflict:            codes.AlreadyExists,
+		repository.ErrForeignKeyViolation: codes.InvalidArgument,
+		repository.ErrAccountBlocked:      codes.InvalidArgument,
+		repository.ErrFxRateNotFound:      codes.InvalidArgument,
+		repository.ErrInvalidAmount:       codes.InvalidArgument,
+		repository.ErrCardInactive:        codes.InvalidArgument,
+		repository.ErrCardLimitExceeded:   codes.InvalidArgument,
+		repository.ErrMerchantDenied:      codes.InvalidArgument,
+		repository.ErrMerchantNotAllowed:  codes.InvalidArgument,
+		repository.ErrCategoryCapExceeded: codes.InvalidArgument,
+		repository.ErrNotDisputable:       codes.InvalidArgument,
+		repository.ErrUnavailable:         codes.Unavailable,
+		repository.ErrTimeout:             codes.Unavailable,
+	} {
+		assert.Equal(t, expected, status.Code(errorRepository(err, "")), err.Error())
+	}
+}
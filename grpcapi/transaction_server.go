@@ -0,0 +1,119 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/grpcapi/pismov1"
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+type TransactionServer struct {
+	pismov1.UnimplementedTransactionServiceServer
+
+	repository repository.TransactionRepository
+	risk       risk.Assessor
+}
+
+func NewTransactionServer(repository repository.TransactionRepository, risk risk.Assessor) *TransactionServer {
+	return &TransactionServer{
+		repository: repository,
+		risk:       risk,
+	}
+}
+
+func (s *TransactionServer) CreateTransaction(ctx context.Context, req *pismov1.CreateTransactionRequest) (*pismov1.Transaction, error) {
+	amount, err := handler.FloatAmount("amount", float64(req.Amount), 32)
+	if err != nil {
+		return nil, errorValidation(err)
+	}
+
+	payload := &handler.TransactionPayload{
+		AccountId:       req.AccountId,
+		OperationTypeId: req.OperationTypeId,
+		Amount:          amount,
+	}
+
+	if err := payload.Validate(); err != nil {
+		return nil, errorValidation(err)
+	}
+
+	if err := risk.Check(ctx, s.risk, payload.Transaction()); err != nil {
+		return nil, errorRepository(err, "The transaction could not be assessed against the risk rules.")
+	}
+
+	transaction, err := s.repository.CreateTransaction(ctx, payload.Transaction())
+
+	if err != nil {
+		return nil, errorRepository(err, "The provided account does not exist.")
+	}
+
+	return toTransactionMessage(*transaction), nil
+}
+
+func (s *TransactionServer) ListTransactions(ctx context.Context, req *pismov1.ListTransactionsRequest) (*pismov1.ListTransactionsResponse, error) {
+	page, err := newPage(req.PageSize, req.PageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions, err := s.repository.ListTransactions(repository.TransactionFilter{AccountId: req.AccountId}, page)
+
+	if err != nil {
+		return nil, errorRepository(err, "An error occurred when listing the transactions.")
+	}
+
+	response := &pismov1.ListTransactionsResponse{}
+
+	for _, transaction := range transactions {
+		response.Transactions = append(response.Transactions, toTransactionMessage(transaction))
+	}
+
+	if len(transactions) > 0 {
+		response.NextPageToken = nextPageToken(page, len(transactions), transactions[len(transactions)-1].TransactionId)
+	}
+
+	return response, nil
+}
+
+// StreamTransactions reads the transactions one page at a time, so memory
+// use stays flat no matter how many transactions are sent.
+func (s *TransactionServer) StreamTransactions(req *pismov1.StreamTransactionsRequest, stream pismov1.TransactionService_StreamTransactionsServer) error {
+	filter := repository.TransactionFilter{AccountId: req.AccountId}
+	page := repository.Page{AfterId: req.AfterTransactionId}
+
+	for {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+
+		transactions, err := s.repository.ListTransactions(filter, page)
+
+		if err != nil {
+			return errorRepository(err, "An error occurred when listing the transactions.")
+		}
+
+		for _, transaction := range transactions {
+			if err := stream.Send(toTransactionMessage(transaction)); err != nil {
+				return err
+			}
+		}
+
+		if len(transactions) < page.EffectiveLimit() {
+			return nil
+		}
+
+		page.AfterId = transactions[len(transactions)-1].TransactionId
+	}
+}
+
+func toTransactionMessage(transaction model.Transaction) *pismov1.Transaction {
+	return &pismov1.Transaction{
+		TransactionId:   transaction.TransactionId,
+		AccountId:       transaction.AccountId,
+		OperationTypeId: transaction.OperationTypeId,
+		Amount:          float32(transaction.Amount.Float64()),
+	}
+}
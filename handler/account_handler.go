@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"database/sql"
 	"net/http"
 	"strconv"
 
@@ -11,6 +10,19 @@ import (
 	"github.com/go-chi/render"
 )
 
+// parseAccountId reads the account ID of the path, rendering the error when it
+// is not valid.
+func parseAccountId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	accountId, err := strconv.ParseUint(chi.URLParam(r, "accountId"), 10, 64)
+
+	if (err != nil) || (accountId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The account_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return accountId, true
+}
+
 type AccountHandler struct {
 	repository repository.AccountRepository
 }
@@ -26,17 +38,19 @@ func (c *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 
 	err := render.Bind(r, payload)
 
-	if (err != nil) || (payload.DocumentNumber <= 0) {
-		render.Render(w, r, errorInvalidRequest(err, "The document_number must be a valid positive integer."))
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
 		return
 	}
 
-	account, err := c.repository.CreateAccount(model.Account{
+	account, err := c.repository.CreateAccount(r.Context(), model.Account{
 		DocumentNumber: payload.DocumentNumber,
+		Currency:       payload.Currency,
+		HolderId:       payload.HolderId,
 	})
 
 	if err != nil {
-		render.Render(w, r, errorInvalidRequest(err, "An error occurred when creating the account."))
+		render.Render(w, r, errorRepository(err, "An account with the provided data already exists, or the provided holder does not."))
 		return
 	}
 
@@ -45,23 +59,39 @@ func (c *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
-	accountIdParam := chi.URLParam(r, "accountId")
-	accountId, err := strconv.ParseUint(accountIdParam, 10, 64)
-
-	if (err != nil) || (accountId <= 0) {
-		render.Render(w, r, errorInvalidRequest(err, "The account_id must be a valid positive integer."))
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
 		return
 	}
 
 	account, err := c.repository.FindAccount(accountId)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			render.Render(w, r, errorNotFound(err, "No account found for the provided account ID."))
-			return
-		}
+		render.Render(w, r, errorRepository(err, "No account found for the provided account ID."))
+		return
+	}
 
-		render.Render(w, r, errorInvalidRequest(err, "An error occurred when fetching the account from the database."))
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, account)
+}
+
+// BlockAccount stops the account from taking more transactions. It honours
+// If-Match like CreateTransaction.
+func (c *AccountHandler) BlockAccount(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := c.repository.BlockAccount(ctx, accountId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The account does not exist or is already blocked."))
 		return
 	}
 
@@ -69,13 +99,101 @@ func (c *AccountHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, account)
 }
 
+// GetBalance returns the balance along with the version of the account in
+// the ETag, to be sent back in If-Match. Given as_of, it returns the balance
+// made of the transactions posted before then instead, with no ETag as it is
+// not the latest version.
+func (c *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	asOf := parseDate(v, r.URL.Query().Get("as_of"), "as_of", true)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	balance, err := c.repository.FindBalance(accountId, asOf)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No account found for the provided account ID, or it was not opened yet."))
+		return
+	}
+
+	if asOf.IsZero() {
+		w.Header().Set("ETag", versionETag(balance.Version))
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, balance)
+}
+
+func (c *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+
+	filter := repository.AccountFilter{
+		DocumentNumber: parseIdFilter(r, v, "document_number"),
+		HolderId:       parseIdFilter(r, v, "holder_id"),
+	}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	accounts, err := c.repository.ListAccounts(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the accounts."))
+		return
+	}
+
+	response := &AccountList{Accounts: accounts}
+
+	if len(accounts) > 0 {
+		response.NextPageToken = nextPageToken(page, len(accounts), accounts[len(accounts)-1].AccountId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+type AccountList struct {
+	Accounts      []model.Account `json:"accounts"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+}
+
+func (a *AccountList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// AccountPayload takes an optional currency the account is billed in,
+// model.DEFAULT_CURRENCY when left out, and the optional holder who owns it.
 type AccountPayload struct {
 	AccountId      uint64 `json:"account_id,omitempty"`
-	DocumentNumber uint64 `json:"document_number"`
+	DocumentNumber uint64 `json:"document_number" validate:"required"`
+	Currency       string `json:"currency,omitempty"`
+	HolderId       uint64 `json:"holder_id,omitempty"`
 }
 
 func (a *AccountPayload) Bind(r *http.Request) error {
-	return nil
+	return a.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (a *AccountPayload) Validate() error {
+	v := &validator{}
+
+	v.check(a.DocumentNumber > 0, "document_number", FieldCodeInvalidPositiveInteger, "The document_number must be a valid positive integer.")
+
+	v.check(a.Currency == "" || model.ValidateCurrency(a.Currency), "currency", FieldCodeInvalidCurrency, "The currency must be a supported ISO 4217 code, such as BRL or USD.")
+
+	return v.err()
 }
 
 func (a *AccountPayload) Render(w http.ResponseWriter, r *http.Request) error {
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,19 +11,21 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
 )
 
 type MockAccountRepository struct {
 	mock.Mock
 }
 
-func (m *MockAccountRepository) CreateAccount(account model.Account) (*model.Account, error) {
+func (m *MockAccountRepository) CreateAccount(ctx context.Context, account model.Account) (*model.Account, error) {
 	args := m.Called(account)
 	return args.Get(0).(*model.Account), args.Error(1)
 }
@@ -34,6 +35,26 @@ func (m *MockAccountRepository) FindAccount(accountId uint64) (*model.Account, e
 	return args.Get(0).(*model.Account), args.Error(1)
 }
 
+func (m *MockAccountRepository) FindAccounts(accountIds []uint64) ([]model.Account, error) {
+	args := m.Called(accountIds)
+	return args.Get(0).([]model.Account), args.Error(1)
+}
+
+func (m *MockAccountRepository) ListAccounts(filter repository.AccountFilter, page repository.Page) ([]model.Account, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.Account), args.Error(1)
+}
+
+func (m *MockAccountRepository) BlockAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	args := m.Called(accountId)
+	return args.Get(0).(*model.Account), args.Error(1)
+}
+
+func (m *MockAccountRepository) FindBalance(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	args := m.Called(accountId, asOf)
+	return args.Get(0).(*model.Balance), args.Error(1)
+}
+
 func TestGetAccount(t *testing.T) {
 	mockRepo := new(MockAccountRepository)
 	accountHandler := NewAccountHandler(mockRepo)
@@ -79,13 +100,23 @@ func TestGetAccountFailsWhenInvalidRequest(t *testing.T) {
 		},
 		{
 			"999",
-			sql.ErrNoRows,
+			repository.ErrNotFound,
 			http.StatusNotFound,
 		},
+		{
+			"2",
+			repository.ErrUnavailable,
+			http.StatusServiceUnavailable,
+		},
+		{
+			"3",
+			repository.ErrTimeout,
+			http.StatusServiceUnavailable,
+		},
 		{
 			"1",
 			errors.New("Database error!"),
-			http.StatusBadRequest,
+			http.StatusInternalServerError,
 		},
 	}
 
@@ -136,8 +167,8 @@ func TestCreateAccount(t *testing.T) {
 	expectedResponse := `{"account_id":1,"document_number":123456789}`
 	actualResponse := w.Body.String()
 
-	expectedResponseJson := map[string]string{}
-	actualResponseJson := map[string]string{}
+	expectedResponseJson := map[string]interface{}{}
+	actualResponseJson := map[string]interface{}{}
 
 	json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
 	json.Unmarshal([]byte(actualResponse), &actualResponseJson)
@@ -148,33 +179,59 @@ func TestCreateAccount(t *testing.T) {
 }
 
 func TestCreateAccountWhenAccountCreationFails(t *testing.T) {
-	mockRepo := new(MockAccountRepository)
+	var scenarios = []struct {
+		repositoryError    error
+		expectedResponse   string
+		expectedStatusCode int
+	}{
+		{
+			repository.ErrConflict,
+			`{"type":"/problems/conflict","title":"Conflict","status":409,"detail":"An account with the provided data already exists, or the provided holder does not.","instance":"/accounts","code":"conflict"}`,
+			http.StatusConflict,
+		},
+		{
+			repository.ErrForeignKeyViolation,
+			`{"type":"/problems/invalid_reference","title":"Unprocessable entity","status":422,"detail":"An account with the provided data already exists, or the provided holder does not.","instance":"/accounts","code":"invalid_reference"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrUnavailable,
+			`{"type":"/problems/service_unavailable","title":"Service unavailable","status":503,"detail":"The service is temporarily unavailable. Please try again later.","instance":"/accounts","code":"service_unavailable"}`,
+			http.StatusServiceUnavailable,
+		},
+		{
+			errors.New("Error!"),
+			`{"type":"/problems/internal_error","title":"Internal server error","status":500,"detail":"An unexpected error occurred.","instance":"/accounts","code":"internal_error"}`,
+			http.StatusInternalServerError,
+		},
+	}
 
-	payload := `{"document_number": 123456789}`
-	req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(payload))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+	for _, scenario := range scenarios {
+		mockRepo := new(MockAccountRepository)
 
-	mockRepo.On("CreateAccount", mock.AnythingOfType("model.Account")).Return(&model.Account{}, errors.New("Error!"))
+		payload := `{"document_number": 123456789}`
+		req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
 
-	handler := &AccountHandler{repository: mockRepo}
-	handler.CreateAccount(w, req)
+		mockRepo.On("CreateAccount", mock.AnythingOfType("model.Account")).Return(&model.Account{}, scenario.repositoryError)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected status code %d but got %d", http.StatusCreated, w.Code)
-	}
+		handler := &AccountHandler{repository: mockRepo}
+		handler.CreateAccount(w, req)
 
-	expectedResponse := `{"status": "Invalid request","error":"An error occurred when creating the account."}`
-	actualResponse := w.Body.String()
+		if w.Code != scenario.expectedStatusCode {
+			t.Errorf("Expected status code %d but got %d", scenario.expectedStatusCode, w.Code)
+		}
 
-	expectedResponseJson := map[string]string{}
-	actualResponseJson := map[string]string{}
+		expectedResponseJson := map[string]interface{}{}
+		actualResponseJson := map[string]interface{}{}
 
-	json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
-	json.Unmarshal([]byte(actualResponse), &actualResponseJson)
+		json.Unmarshal([]byte(scenario.expectedResponse), &expectedResponseJson)
+		json.Unmarshal(w.Body.Bytes(), &actualResponseJson)
 
-	if !reflect.DeepEqual(expectedResponseJson, actualResponseJson) {
-		t.Errorf("Expected response body %s but got %s", expectedResponse, w.Body.String())
+		if !reflect.DeepEqual(expectedResponseJson, actualResponseJson) {
+			t.Errorf("Expected response body %s but got %s", scenario.expectedResponse, w.Body.String())
+		}
 	}
 }
 
@@ -186,22 +243,52 @@ func TestCreateAccountFailsWhenInvalidRequest(t *testing.T) {
 	}{
 		{
 			`{"document_number": "invalid"}`,
-			`{"status":"Invalid request","error":"The document_number must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number must be a valid positive integer.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"invalid_positive_integer","message":"The document_number must be a valid positive integer."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"document_number": ""}`,
-			`{"status":"Invalid request","error":"The document_number must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number must be a valid positive integer.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"invalid_positive_integer","message":"The document_number must be a valid positive integer."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"document_number": null}`,
-			`{"status":"Invalid request","error":"The document_number must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number is required.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"required","message":"The document_number is required."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"document_number": -1}`,
-			`{"status":"Invalid request","error":"The document_number must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number must not be negative.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"negative_number","message":"The document_number must not be negative."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number is required.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"required","message":"The document_number is required."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"document_number": 0}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number must be a valid positive integer.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"invalid_positive_integer","message":"The document_number must be a valid positive integer."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"document_number": 123, "documentnumber": 123}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The documentnumber field is not allowed.","instance":"/accounts","code":"invalid_request","errors":[{"field":"documentnumber","code":"unknown_field","message":"The documentnumber field is not allowed."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"document_number": 18446744073709551616}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The document_number is too large.","instance":"/accounts","code":"invalid_request","errors":[{"field":"document_number","code":"out_of_range","message":"The document_number is too large."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"document_number": 123} {"document_number": 456}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The request body must be a single valid JSON object.","instance":"/accounts","code":"invalid_request"}`,
+			http.StatusBadRequest,
+		},
+		{
+			`[{"document_number": 123}]`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The request body must be a single valid JSON object.","instance":"/accounts","code":"invalid_request"}`,
 			http.StatusBadRequest,
 		},
 	}
@@ -223,8 +310,8 @@ func TestCreateAccountFailsWhenInvalidRequest(t *testing.T) {
 		expectedResponse := scenario.expectedResponse
 		actualResponse := w.Body.String()
 
-		expectedResponseJson := map[string]string{}
-		actualResponseJson := map[string]string{}
+		expectedResponseJson := map[string]interface{}{}
+		actualResponseJson := map[string]interface{}{}
 
 		json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
 		json.Unmarshal([]byte(actualResponse), &actualResponseJson)
@@ -235,6 +322,100 @@ func TestCreateAccountFailsWhenInvalidRequest(t *testing.T) {
 	}
 }
 
+func TestCreateAccountFailsWhenBodyIsNotAcceptable(t *testing.T) {
+	var scenarios = []struct {
+		contentType        string
+		payload            string
+		expectedCode       string
+		expectedStatusCode int
+	}{
+		{
+			"text/plain",
+			`{"document_number": 123456789}`,
+			CodeUnsupportedMediaType,
+			http.StatusUnsupportedMediaType,
+		},
+		{
+			"",
+			`{"document_number": 123456789}`,
+			CodeUnsupportedMediaType,
+			http.StatusUnsupportedMediaType,
+		},
+		{
+			"application/json; charset=utf-8",
+			`{"document_number": 123456789, "padding": "` + strings.Repeat("x", MaxPayloadSize) + `"}`,
+			CodePayloadTooLarge,
+			http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		mockRepo := new(MockAccountRepository)
+
+		req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(scenario.payload))
+		req.Header.Set("Content-Type", scenario.contentType)
+		w := httptest.NewRecorder()
+
+		handler := &AccountHandler{repository: mockRepo}
+		handler.CreateAccount(w, req)
+
+		if w.Code != scenario.expectedStatusCode {
+			t.Errorf("Expected status code %d but got %d", scenario.expectedStatusCode, w.Code)
+		}
+
+		response := &ErrorResponse{}
+		json.Unmarshal(w.Body.Bytes(), response)
+
+		if response.Code != scenario.expectedCode {
+			t.Errorf("Expected error code %s but got %s", scenario.expectedCode, response.Code)
+		}
+
+		mockRepo.AssertNotCalled(t, "CreateAccount", mock.Anything)
+	}
+}
+
+func TestListAccounts(t *testing.T) {
+	mockRepo := new(MockAccountRepository)
+	accountHandler := NewAccountHandler(mockRepo)
+
+	accounts := []model.Account{{AccountId: 3, DocumentNumber: 456}, {AccountId: 4, DocumentNumber: 456}}
+
+	mockRepo.On("ListAccounts", repository.AccountFilter{DocumentNumber: 456}, repository.Page{AfterId: 2, Limit: 2}).Return(accounts, nil)
+
+	req := httptest.NewRequest("GET", "/accounts?document_number=456&page_size=2&page_token=2", nil)
+	w := httptest.NewRecorder()
+
+	accountHandler.ListAccounts(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"accounts":[{"account_id":3,"document_number":456},{"account_id":4,"document_number":456}],"next_page_token":"4"}`, w.Body.String())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListAccountsFailsWhenInvalidRequest(t *testing.T) {
+	mockRepo := new(MockAccountRepository)
+	accountHandler := NewAccountHandler(mockRepo)
+
+	req := httptest.NewRequest("GET", "/accounts?document_number=abc&page_size=5000&page_token=x", nil)
+	w := httptest.NewRecorder()
+
+	accountHandler.ListAccounts(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	response := &ErrorResponse{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), response))
+	assert.Equal(t, CodeInvalidRequest, response.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "document_number", Code: FieldCodeInvalidPositiveInteger, Message: "The document_number must be a valid positive integer."},
+		{Field: "page_size", Code: FieldCodeOutOfRange, Message: "The page_size must be between 0 and 1000."},
+		{Field: "page_token", Code: FieldCodeInvalidPageToken, Message: "The page_token is invalid."},
+	}, response.Errors)
+
+	mockRepo.AssertNotCalled(t, "ListAccounts", mock.Anything, mock.Anything)
+}
+
 func TestNewAccountHandler(t *testing.T) {
 	repository := &MockAccountRepository{}
 	handler := NewAccountHandler(repository)
@@ -243,3 +424,97 @@ func TestNewAccountHandler(t *testing.T) {
 		t.Errorf("The repository field for the handler wasn't assigned. Expect %s but got %s", repository, handler.repository)
 	}
 }
+
+func TestGetBalanceSendsTheVersionAsETag(t *testing.T) {
+	mockRepo := new(MockAccountRepository)
+
+	mockRepo.On("FindBalance", uint64(7), time.Time{}).Return(&model.Balance{AccountId: 7, Balance: -12.5, Version: 4}, nil)
+
+	req := httptest.NewRequest("GET", "/accounts/7/balance", nil)
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("accountId", "7")
+
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+	w := httptest.NewRecorder()
+	NewAccountHandler(mockRepo).GetBalance(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	assert.JSONEq(t, `{"account_id":7,"balance":-12.5,"version":4}`, w.Body.String())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetBalanceAsOf(t *testing.T) {
+	mockRepo := new(MockAccountRepository)
+
+	endOfDay := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	mockRepo.On("FindBalance", uint64(7), endOfDay).Return(&model.Balance{AccountId: 7, Balance: 30, Version: 3, AsOf: &endOfDay}, nil)
+
+	for query, expectedStatusCode := range map[string]int{"as_of=2024-03-01": http.StatusOK, "as_of=yesterday": http.StatusBadRequest} {
+		req := httptest.NewRequest("GET", "/accounts/7/balance?"+query, nil)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("accountId", "7")
+
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		NewAccountHandler(mockRepo).GetBalance(w, req)
+
+		assert.Equal(t, expectedStatusCode, w.Code, query)
+
+		if expectedStatusCode == http.StatusOK {
+			// A past balance is not the version If-Match expects.
+			assert.Empty(t, w.Header().Get("ETag"))
+			assert.JSONEq(t, `{"account_id":7,"balance":30,"version":3,"as_of":"2024-03-02T00:00:00Z"}`, w.Body.String())
+		}
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBlockAccount(t *testing.T) {
+	var scenarios = []struct {
+		name               string
+		ifMatch            string
+		repositoryError    error
+		expectedStatusCode int
+		expectedCode       string
+	}{
+		{name: "Blocked", expectedStatusCode: http.StatusOK},
+		{name: "AlreadyBlocked", repositoryError: repository.ErrConflict, expectedStatusCode: http.StatusConflict, expectedCode: CodeConflict},
+		{name: "StaleVersion", ifMatch: `"3"`, repositoryError: repository.ErrVersionConflict, expectedStatusCode: http.StatusPreconditionFailed, expectedCode: CodePreconditionFailed},
+		{name: "InvalidIfMatch", ifMatch: "*", expectedStatusCode: http.StatusBadRequest, expectedCode: CodeInvalidRequest},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			mockRepo := new(MockAccountRepository)
+
+			mockRepo.On("BlockAccount", uint64(7)).Return(&model.Account{AccountId: 7, DocumentNumber: 111, Blocked: true}, scenario.repositoryError)
+
+			req := httptest.NewRequest("POST", "/accounts/7/block", nil)
+			req.Header.Set("If-Match", scenario.ifMatch)
+
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("accountId", "7")
+
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			w := httptest.NewRecorder()
+			NewAccountHandler(mockRepo).BlockAccount(w, req)
+
+			assert.Equal(t, scenario.expectedStatusCode, w.Code)
+
+			if scenario.expectedCode != "" {
+				assert.Contains(t, w.Body.String(), `"code":"`+scenario.expectedCode+`"`)
+			} else {
+				assert.JSONEq(t, `{"account_id":7,"document_number":111,"blocked":true}`, w.Body.String())
+			}
+		})
+	}
+}
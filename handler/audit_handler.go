@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// ActorHeader names who makes a request, recorded in the audit log. Without
+// it the actor is derived from the API key.
+const ActorHeader = "X-Actor"
+
+// RequestIdHeader carries the ID of a request, as sent by the client or
+// generated by middleware.RequestID, so it can be looked up in the audit log.
+const RequestIdHeader = "X-Request-Id"
+
+// AuditMetadata puts the actor, client IP and request ID of each request in
+// its context, for the repositories to record along with the changes it
+// makes. It must run after middleware.RequestID.
+func AuditMetadata(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := middleware.GetReqID(r.Context())
+
+		if requestId != "" {
+			w.Header().Set(RequestIdHeader, requestId)
+		}
+
+		clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientIP = r.RemoteAddr
+		}
+
+		ctx := audit.NewContext(r.Context(), audit.Metadata{
+			Actor:     audit.ResolveActor(r.Header.Get(ActorHeader), r.Header.Get("Authorization")),
+			ClientIP:  clientIP,
+			RequestId: requestId,
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type AuditHandler struct {
+	repository repository.AuditRepository
+}
+
+func NewAuditHandler(repository repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		repository: repository,
+	}
+}
+
+func (c *AuditHandler) ListAuditEntries(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+	query := r.URL.Query()
+
+	filter := repository.AuditFilter{
+		EntityType: query.Get("entity_type"),
+		EntityId:   parseIdFilter(r, v, "entity_id"),
+		Actor:      query.Get("actor"),
+		RequestId:  query.Get("request_id"),
+	}
+
+	if filter.EntityType != "" {
+		v.check(model.ValidateAuditEntityType(filter.EntityType), "entity_type", FieldCodeInvalidEntityType, "The entity_type must be one of the following valid values: account, transaction, import, export.")
+	}
+
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	entries, err := c.repository.ListAuditEntries(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the audit log."))
+		return
+	}
+
+	response := &AuditEntryList{AuditEntries: entries}
+
+	if len(entries) > 0 {
+		response.NextPageToken = nextPageToken(page, len(entries), entries[len(entries)-1].AuditEntryId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+type AuditEntryList struct {
+	AuditEntries  []model.AuditEntry `json:"audit_entries"`
+	NextPageToken string             `json:"next_page_token,omitempty"`
+}
+
+func (a *AuditEntryList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
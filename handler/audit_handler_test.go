@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) ListAuditEntries(filter repository.AuditFilter, page repository.Page) ([]model.AuditEntry, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.AuditEntry), args.Error(1)
+}
+
+func TestAuditMetadata(t *testing.T) {
+	scenarios := []struct {
+		name          string
+		actor         string
+		authorization string
+		expectedActor string
+	}{
+		{name: "NamedActor", actor: "alice", authorization: "Bearer secret", expectedActor: "alice"},
+		{name: "APIKey", authorization: "Bearer secret", expectedActor: audit.ResolveActor("", "Bearer secret")},
+		{name: "Anonymous", expectedActor: audit.AnonymousActor},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			var metadata audit.Metadata
+
+			h := middleware.RequestID(AuditMetadata(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				metadata = audit.FromContext(r.Context())
+			})))
+
+			req := httptest.NewRequest("POST", "/accounts", nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			req.Header.Set(RequestIdHeader, "req-1")
+			req.Header.Set(ActorHeader, scenario.actor)
+			req.Header.Set("Authorization", scenario.authorization)
+
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			assert.Equal(t, audit.Metadata{Actor: scenario.expectedActor, ClientIP: "203.0.113.7", RequestId: "req-1"}, metadata)
+			assert.Equal(t, "req-1", w.Header().Get(RequestIdHeader))
+		})
+	}
+
+	// The key itself is never recorded.
+	assert.NotContains(t, audit.ResolveActor("", "Bearer secret"), "secret")
+}
+
+func TestListAuditEntries(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+
+	entries := []model.AuditEntry{{AuditEntryId: 3, Action: model.AUDIT_ACTION_CREATE, EntityType: model.AUDIT_ENTITY_ACCOUNT, EntityId: 7, Actor: "alice"}}
+
+	mockRepo.On("ListAuditEntries", repository.AuditFilter{EntityType: "account", EntityId: 7, Actor: "alice"}, repository.Page{AfterId: 2, Limit: 1}).Return(entries, nil)
+
+	req := httptest.NewRequest("GET", "/audit-log?entity_type=account&entity_id=7&actor=alice&page_size=1&page_token=2", nil)
+	w := httptest.NewRecorder()
+
+	NewAuditHandler(mockRepo).ListAuditEntries(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := AuditEntryList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Len(t, response.AuditEntries, 1)
+	assert.Equal(t, "3", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListAuditEntriesValidatesQuery(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+
+	req := httptest.NewRequest("GET", "/audit-log?entity_type=widget&entity_id=x", nil)
+	w := httptest.NewRecorder()
+
+	NewAuditHandler(mockRepo).ListAuditEntries(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidEntityType)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidPositiveInteger)
+
+	mockRepo.AssertNotCalled(t, "ListAuditEntries", mock.Anything, mock.Anything)
+}
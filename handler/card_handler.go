@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseCardId reads the card ID of the path, rendering the error when it is
+// not valid.
+func parseCardId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	cardId, err := strconv.ParseUint(chi.URLParam(r, "cardId"), 10, 64)
+
+	if (err != nil) || (cardId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The card_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return cardId, true
+}
+
+// CardHandler manages the cards of the accounts. The PAN sent to issue or
+// replace a card is tokenized here and goes no further: only its token and
+// last four digits are stored.
+type CardHandler struct {
+	repository repository.CardRepository
+}
+
+func NewCardHandler(repository repository.CardRepository) *CardHandler {
+	return &CardHandler{
+		repository: repository,
+	}
+}
+
+func (c *CardHandler) IssueCard(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &CardPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	card, err := payload.Card()
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The card could not be issued."))
+		return
+	}
+
+	card.AccountId = accountId
+
+	created, err := c.repository.CreateCard(r.Context(), card)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The provided account or holder does not exist."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, created)
+}
+
+func (c *CardHandler) ListCards(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	cards, err := c.repository.ListCards(accountId, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the cards."))
+		return
+	}
+
+	response := &CardList{Cards: cards}
+
+	if len(cards) > 0 {
+		response.NextPageToken = nextPageToken(page, len(cards), cards[len(cards)-1].CardId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+func (c *CardHandler) GetCard(w http.ResponseWriter, r *http.Request) {
+	cardId, ok := parseCardId(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := c.repository.FindCard(cardId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No card found for the provided card ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, card)
+}
+
+// BlockCard stops the card from making purchases and withdraws until it is
+// unblocked. Payments made with it are still taken.
+func (c *CardHandler) BlockCard(w http.ResponseWriter, r *http.Request) {
+	c.updateStatus(w, r, model.CARD_STATUS_ACTIVE, model.CARD_STATUS_BLOCKED, "No card found for the provided card ID, or it is not active.")
+}
+
+func (c *CardHandler) UnblockCard(w http.ResponseWriter, r *http.Request) {
+	c.updateStatus(w, r, model.CARD_STATUS_BLOCKED, model.CARD_STATUS_ACTIVE, "No card found for the provided card ID, or it is not blocked.")
+}
+
+func (c *CardHandler) updateStatus(w http.ResponseWriter, r *http.Request, from string, status string, errorText string) {
+	cardId, ok := parseCardId(w, r)
+	if !ok {
+		return
+	}
+
+	card, err := c.repository.UpdateCardStatus(r.Context(), cardId, []string{from}, status)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, errorText))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, card)
+}
+
+// ReplaceCard issues a card with a new PAN and expiry in place of the card,
+// such as a lost or expiring one, keeping its type and limits. The card it
+// replaces takes no more purchases or withdraws.
+func (c *CardHandler) ReplaceCard(w http.ResponseWriter, r *http.Request) {
+	cardId, ok := parseCardId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &CardReplacementPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	replacement, err := payload.Card()
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The card could not be replaced."))
+		return
+	}
+
+	created, err := c.repository.ReplaceCard(r.Context(), cardId, replacement)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No card found for the provided card ID, or it was replaced already."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, created)
+}
+
+func (c *CardHandler) UpdateCardLimits(w http.ResponseWriter, r *http.Request) {
+	cardId, ok := parseCardId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &CardLimitsPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	card, err := c.repository.UpdateCardLimits(r.Context(), cardId, payload.TransactionLimit, payload.DailyLimit)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No card found for the provided card ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, card)
+}
+
+type CardList struct {
+	Cards         []model.Card `json:"cards"`
+	NextPageToken string       `json:"next_page_token,omitempty"`
+}
+
+func (c *CardList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// CardReplacementPayload carries the PAN and expiry of a card as printed on
+// it. The PAN is only kept long enough to be tokenized.
+type CardReplacementPayload struct {
+	Pan         string `json:"pan" validate:"required"`
+	ExpiryMonth int    `json:"expiry_month" validate:"required"`
+	ExpiryYear  int    `json:"expiry_year" validate:"required"`
+}
+
+// Card returns the card to store, with the PAN tokenized.
+func (c *CardReplacementPayload) Card() (model.Card, error) {
+	token, lastFour, err := model.TokenizePan(c.Pan)
+	if err != nil {
+		return model.Card{}, err
+	}
+
+	return model.Card{
+		PanToken:    token,
+		LastFour:    lastFour,
+		ExpiryMonth: c.ExpiryMonth,
+		ExpiryYear:  c.ExpiryYear,
+		Status:      model.CARD_STATUS_ACTIVE,
+	}, nil
+}
+
+func (c *CardReplacementPayload) Bind(r *http.Request) error {
+	return c.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule. The
+// messages never quote the PAN.
+func (c *CardReplacementPayload) Validate() error {
+	v := &validator{}
+	c.validate(v)
+
+	return v.err()
+}
+
+func (c *CardReplacementPayload) validate(v *validator) {
+	v.check(model.ValidatePan(c.Pan), "pan", FieldCodeInvalidPan, "The pan must be made of 12 to 19 digits and pass the Luhn check.")
+
+	if v.check(c.ExpiryMonth >= 1 && c.ExpiryMonth <= 12, "expiry_month", FieldCodeOutOfRange, "The expiry_month must be between 1 and 12.") {
+		card := model.Card{ExpiryMonth: c.ExpiryMonth, ExpiryYear: c.ExpiryYear}
+
+		v.check(!card.Expired(time.Now()), "expiry_year", FieldCodeInvalidExpiry, "The card must not be expired.")
+	}
+}
+
+func (c *CardReplacementPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// CardLimitsPayload caps the purchases and withdraws of a card, in the
+// currency of its account. Zero, or leaving a limit out, removes the cap.
+type CardLimitsPayload struct {
+	TransactionLimit model.Amount `json:"transaction_limit,omitzero"`
+	DailyLimit       model.Amount `json:"daily_limit,omitzero"`
+}
+
+func (c *CardLimitsPayload) Bind(r *http.Request) error {
+	return c.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (c *CardLimitsPayload) Validate() error {
+	v := &validator{}
+	c.validate(v)
+
+	return v.err()
+}
+
+func (c *CardLimitsPayload) validate(v *validator) {
+	v.check(c.TransactionLimit.Sign() >= 0, "transaction_limit", FieldCodeNegativeNumber, "The transaction_limit must not be negative.")
+
+	v.check(c.DailyLimit.Sign() >= 0, "daily_limit", FieldCodeNegativeNumber, "The daily_limit must not be negative.")
+}
+
+func (c *CardLimitsPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// CardPayload issues a card of the given type, virtual or physical, with
+// optional spend limits, to the optional holder it names.
+type CardPayload struct {
+	Type             string       `json:"type" validate:"required"`
+	Pan              string       `json:"pan" validate:"required"`
+	ExpiryMonth      int          `json:"expiry_month" validate:"required"`
+	ExpiryYear       int          `json:"expiry_year" validate:"required"`
+	TransactionLimit model.Amount `json:"transaction_limit,omitzero"`
+	DailyLimit       model.Amount `json:"daily_limit,omitzero"`
+	HolderId         uint64       `json:"holder_id,omitempty"`
+}
+
+func (c *CardPayload) replacement() *CardReplacementPayload {
+	return &CardReplacementPayload{Pan: c.Pan, ExpiryMonth: c.ExpiryMonth, ExpiryYear: c.ExpiryYear}
+}
+
+func (c *CardPayload) limits() *CardLimitsPayload {
+	return &CardLimitsPayload{TransactionLimit: c.TransactionLimit, DailyLimit: c.DailyLimit}
+}
+
+// Card returns the card to store, with the PAN tokenized.
+func (c *CardPayload) Card() (model.Card, error) {
+	card, err := c.replacement().Card()
+	if err != nil {
+		return model.Card{}, err
+	}
+
+	card.Type = c.Type
+	card.TransactionLimit = c.TransactionLimit
+	card.DailyLimit = c.DailyLimit
+	card.HolderId = c.HolderId
+
+	return card, nil
+}
+
+func (c *CardPayload) Bind(r *http.Request) error {
+	return c.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (c *CardPayload) Validate() error {
+	v := &validator{}
+
+	v.check(model.ValidateCardType(c.Type), "type", FieldCodeInvalidCardType, "The type must be one of the following valid values: virtual, physical")
+
+	c.replacement().validate(v)
+	c.limits().validate(v)
+
+	return v.err()
+}
+
+func (c *CardPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockCardRepository struct {
+	mock.Mock
+}
+
+func (m *MockCardRepository) CreateCard(ctx context.Context, card model.Card) (*model.Card, error) {
+	args := m.Called(card)
+	return args.Get(0).(*model.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) FindCard(cardId uint64) (*model.Card, error) {
+	args := m.Called(cardId)
+	return args.Get(0).(*model.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) ListCards(accountId uint64, page repository.Page) ([]model.Card, error) {
+	args := m.Called(accountId, page)
+	return args.Get(0).([]model.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdateCardStatus(ctx context.Context, cardId uint64, from []string, status string) (*model.Card, error) {
+	args := m.Called(cardId, from, status)
+	return args.Get(0).(*model.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdateCardLimits(ctx context.Context, cardId uint64, transactionLimit model.Amount, dailyLimit model.Amount) (*model.Card, error) {
+	args := m.Called(cardId, transactionLimit, dailyLimit)
+	return args.Get(0).(*model.Card), args.Error(1)
+}
+
+func (m *MockCardRepository) ReplaceCard(ctx context.Context, cardId uint64, replacement model.Card) (*model.Card, error) {
+	args := m.Called(cardId, replacement)
+	return args.Get(0).(*model.Card), args.Error(1)
+}
+
+func cardRouter(mockRepo *MockCardRepository) http.Handler {
+	cardHandler := NewCardHandler(mockRepo)
+
+	r := chi.NewRouter()
+	r.Post("/accounts/{accountId}/cards", cardHandler.IssueCard)
+	r.Get("/accounts/{accountId}/cards", cardHandler.ListCards)
+	r.Get("/cards/{cardId}", cardHandler.GetCard)
+	r.Post("/cards/{cardId}/block", cardHandler.BlockCard)
+	r.Post("/cards/{cardId}/unblock", cardHandler.UnblockCard)
+	r.Post("/cards/{cardId}/replacement", cardHandler.ReplaceCard)
+	r.Put("/cards/{cardId}/limits", cardHandler.UpdateCardLimits)
+
+	return r
+}
+
+func TestIssueCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	// The PAN is replaced by a random token before reaching the repository.
+	issued := mock.MatchedBy(func(card model.Card) bool {
+		return card.AccountId == 1 && card.LastFour == "1111" && strings.HasPrefix(card.PanToken, "tok_") &&
+			card.ExpiryMonth == 12 && card.ExpiryYear == 2099 && card.Status == model.CARD_STATUS_ACTIVE &&
+			card.Type == model.CARD_TYPE_VIRTUAL && card.TransactionLimit == model.MustParseAmount("500") && card.DailyLimit.IsZero()
+	})
+
+	mockRepo.On("CreateCard", issued).Return(&model.Card{CardId: 3, AccountId: 1, PanToken: "tok_1", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL, TransactionLimit: model.MustParseAmount("500")}, nil)
+
+	payload := `{"type": "virtual", "pan": "4111111111111111", "expiry_month": 12, "expiry_year": 2099, "transaction_limit": 500}`
+
+	req := httptest.NewRequest("POST", "/accounts/1/cards", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "4111111111111111")
+
+	response := model.Card{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint64(3), response.CardId)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIssueCardValidatesPayload(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	payload := `{"type": "plastic", "pan": "4111111111111112", "expiry_month": 1, "expiry_year": 2020, "daily_limit": -1}`
+
+	req := httptest.NewRequest("POST", "/accounts/1/cards", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidCardType)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidPan)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidExpiry)
+	assert.Contains(t, w.Body.String(), FieldCodeNegativeNumber)
+	assert.NotContains(t, w.Body.String(), "4111111111111112")
+
+	mockRepo.AssertNotCalled(t, "CreateCard", mock.Anything)
+}
+
+func TestIssueCardUnknownAccount(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	mockRepo.On("CreateCard", mock.Anything).Return((*model.Card)(nil), repository.ErrForeignKeyViolation)
+
+	payload := `{"type": "physical", "pan": "4111111111111111", "expiry_month": 12, "expiry_year": 2099}`
+
+	req := httptest.NewRequest("POST", "/accounts/9/cards", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeInvalidReference)
+}
+
+func TestListCards(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	mockRepo.On("ListCards", uint64(1), repository.Page{Limit: 1}).Return([]model.Card{{CardId: 4, AccountId: 1}}, nil)
+
+	req := httptest.NewRequest("GET", "/accounts/1/cards?page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := CardList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Cards, 1)
+	assert.Equal(t, "4", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetCardNotFound(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	mockRepo.On("FindCard", uint64(7)).Return((*model.Card)(nil), repository.ErrNotFound)
+
+	req := httptest.NewRequest("GET", "/cards/7", nil)
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBlockAndUnblockCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	mockRepo.On("UpdateCardStatus", uint64(1), []string{model.CARD_STATUS_ACTIVE}, model.CARD_STATUS_BLOCKED).Return(&model.Card{CardId: 1, Status: model.CARD_STATUS_BLOCKED}, nil)
+	mockRepo.On("UpdateCardStatus", uint64(1), []string{model.CARD_STATUS_BLOCKED}, model.CARD_STATUS_ACTIVE).Return((*model.Card)(nil), repository.ErrConflict)
+
+	req := httptest.NewRequest("POST", "/cards/1/block", nil)
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"blocked"`)
+
+	req = httptest.NewRequest("POST", "/cards/1/unblock", nil)
+	w = httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReplaceCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	replacement := mock.MatchedBy(func(card model.Card) bool {
+		return card.LastFour == "4444" && strings.HasPrefix(card.PanToken, "tok_") && card.ExpiryMonth == 6 && card.ExpiryYear == 2099
+	})
+
+	mockRepo.On("ReplaceCard", uint64(1), replacement).Return(&model.Card{CardId: 2, LastFour: "4444"}, nil)
+
+	payload := `{"pan": "5555555555554444", "expiry_month": 6, "expiry_year": 2099}`
+
+	req := httptest.NewRequest("POST", "/cards/1/replacement", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateCardLimits(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+
+	mockRepo.On("UpdateCardLimits", uint64(1), model.MustParseAmount("0"), model.MustParseAmount("300")).Return(&model.Card{CardId: 1, DailyLimit: model.MustParseAmount("300")}, nil)
+
+	req := httptest.NewRequest("PUT", "/cards/1/limits", strings.NewReader(`{"daily_limit": 300}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	cardRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// MaxPayloadSize is the largest request body accepted by the JSON decoder.
+const MaxPayloadSize = 1 << 20
+
+var (
+	errUnsupportedMediaType = errors.New("request content type is not application/json")
+	errPayloadTooLarge      = errors.New("request body is too large")
+	errMalformedPayload     = errors.New("request body is not a valid JSON object")
+)
+
+func init() {
+	render.Decode = decodeStrict
+}
+
+// decodeStrict replaces the chi render decoder. Besides decoding the JSON
+// body into v it rejects unknown fields and fields marked with
+// `validate:"required"` that are missing or null, in the nested objects
+// too, reporting every offending field at once as ValidationErrors.
+func decodeStrict(r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, MaxPayloadSize))
+
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+
+		if errors.As(err, &maxBytesErr) {
+			return errPayloadTooLarge
+		}
+
+		return err
+	}
+
+	return DecodeStrict(body, v)
+}
+
+// DecodeStrict decodes the JSON object in data into the struct pointed to by
+// v with the same rules as request bodies, so rows of an import are
+// reported exactly like a single request would be.
+func DecodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	fields := map[string]json.RawMessage{}
+
+	if err := decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("%w: %s", errMalformedPayload, err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("%w: unexpected data after the JSON object", errMalformedPayload)
+	}
+
+	validator := &validator{}
+
+	decodeFields(validator, "", fields, reflect.ValueOf(v).Elem())
+
+	return validator.err()
+}
+
+var (
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	amountType      = reflect.TypeOf(model.Amount{})
+)
+
+// decodeFields decodes the fields of a JSON object into the struct target,
+// checking them like DecodeStrict does. The nested objects, and the arrays
+// of them, are checked the same way, their fields named after the path to
+// them, such as address.street or items[0].amount.
+func decodeFields(validator *validator, prefix string, fields map[string]json.RawMessage, target reflect.Value) {
+	targetType := target.Type()
+	known := map[string]bool{}
+
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if name == "" || name == "-" {
+			continue
+		}
+
+		known[name] = true
+		path := prefix + name
+
+		raw, present := fields[name]
+
+		if !present || string(raw) == "null" {
+			validator.check(field.Tag.Get("validate") != "required", path, FieldCodeRequired, fmt.Sprintf("The %s is required.", path))
+			continue
+		}
+
+		decodeValue(validator, path, raw, target.Field(i))
+	}
+
+	var unknown []string
+
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	for _, name := range unknown {
+		validator.check(false, prefix+name, FieldCodeUnknownField, fmt.Sprintf("The %s field is not allowed.", prefix+name))
+	}
+}
+
+// decodeValue decodes raw into target, the value at path, looking into the
+// objects and arrays of objects it holds.
+func decodeValue(validator *validator, path string, raw json.RawMessage, target reflect.Value) {
+	switch {
+	case isObject(target.Type()):
+		fields := map[string]json.RawMessage{}
+
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			validator.check(false, path, FieldCodeInvalidType, fmt.Sprintf("The %s has an invalid type.", path))
+			return
+		}
+
+		if target.Kind() == reflect.Pointer {
+			target.Set(reflect.New(target.Type().Elem()))
+			target = target.Elem()
+		}
+
+		decodeFields(validator, path+".", fields, target)
+	case target.Kind() == reflect.Slice && isObject(target.Type().Elem()):
+		var items []json.RawMessage
+
+		if err := json.Unmarshal(raw, &items); err != nil {
+			validator.check(false, path, FieldCodeInvalidType, fmt.Sprintf("The %s has an invalid type.", path))
+			return
+		}
+
+		target.Set(reflect.MakeSlice(target.Type(), len(items), len(items)))
+
+		for n, item := range items {
+			decodeValue(validator, fmt.Sprintf("%s[%d]", path, n), item, target.Index(n))
+		}
+	default:
+		if err := json.Unmarshal(raw, target.Addr().Interface()); err != nil {
+			code, message := describeTypeError(path, target.Type(), raw)
+			validator.check(false, path, code, message)
+		}
+	}
+}
+
+// isObject reports whether values of valueType, or what it points to, are
+// decoded field by field: the structs decoding themselves, such as
+// time.Time, are not.
+func isObject(valueType reflect.Type) bool {
+	if valueType.Kind() == reflect.Pointer {
+		valueType = valueType.Elem()
+	}
+
+	return valueType.Kind() == reflect.Struct && !reflect.PointerTo(valueType).Implements(unmarshalerType)
+}
+
+// describeTypeError explains why raw could not be decoded into a field of
+// fieldType, telling apart negative and overflowing numbers for unsigned
+// fields, and overflowing or too precise amounts, from values of the wrong
+// type.
+func describeTypeError(name string, fieldType reflect.Type, raw json.RawMessage) (string, string) {
+	var number json.Number
+	isNumber := json.Unmarshal(raw, &number) == nil && !bytes.HasPrefix(raw, []byte(`"`))
+
+	if fieldType == amountType {
+		_, err := model.ParseAmount(string(raw))
+
+		return describeAmountError(name, err)
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case isNumber && strings.HasPrefix(number.String(), "-"):
+			return FieldCodeNegativeNumber, fmt.Sprintf("The %s must not be negative.", name)
+		case isNumber && !strings.ContainsAny(number.String(), ".eE"):
+			return FieldCodeOutOfRange, fmt.Sprintf("The %s is too large.", name)
+		default:
+			return FieldCodeInvalidPositiveInteger, fmt.Sprintf("The %s must be a valid positive integer.", name)
+		}
+	case reflect.Float32, reflect.Float64:
+		if isNumber {
+			return FieldCodeOutOfRange, fmt.Sprintf("The %s is too large.", name)
+		}
+
+		return FieldCodeInvalidDecimal, fmt.Sprintf("The %s must be a valid decimal.", name)
+	default:
+		return FieldCodeInvalidType, fmt.Sprintf("The %s has an invalid type.", name)
+	}
+}
+
+// describeAmountError explains why an amount could not be read, err being
+// what model.ParseAmount returned.
+func describeAmountError(name string, err error) (string, string) {
+	switch {
+	case errors.Is(err, model.ErrAmountRange):
+		return FieldCodeOutOfRange, fmt.Sprintf("The %s is too large.", name)
+	case errors.Is(err, model.ErrAmountPrecision):
+		return FieldCodeInvalidDecimal, fmt.Sprintf("The %s must not have more than %d decimals.", name, model.AMOUNT_DECIMALS)
+	default:
+		return FieldCodeInvalidDecimal, fmt.Sprintf("The %s must be a valid decimal.", name)
+	}
+}
+
+// FloatAmount reads the amount sent as a float of bitSize bits by the APIs
+// with no decimal type, failing with ValidationErrors for field when it is
+// not a valid amount.
+func FloatAmount(field string, amount float64, bitSize int) (model.Amount, error) {
+	parsed, err := model.AmountFromFloat(amount, bitSize)
+	if err != nil {
+		code, message := describeAmountError(field, err)
+
+		return model.Amount{}, ValidationErrors{{Field: field, Code: code, Message: message}}
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeStrictChecksNestedObjects(t *testing.T) {
+	type item struct {
+		Amount float32 `json:"amount" validate:"required"`
+	}
+
+	type payload struct {
+		At    time.Time `json:"at"`
+		Items []item    `json:"items" validate:"required"`
+		Last  *item     `json:"last,omitempty"`
+	}
+
+	decoded := payload{}
+
+	require.NoError(t, DecodeStrict([]byte(`{"at": "2024-01-01T00:00:00Z", "items": [{"amount": 1}, {"amount": 2}], "last": {"amount": 3}}`), &decoded))
+	assert.Equal(t, []item{{Amount: 1}, {Amount: 2}}, decoded.Items)
+	assert.Equal(t, &item{Amount: 3}, decoded.Last)
+
+	err := DecodeStrict([]byte(`{"items": [{"amount": 1, "note": "x"}, {}], "last": {"amount": "3"}}`), &payload{})
+
+	assert.Equal(t, ValidationErrors{
+		{Field: "items[0].note", Code: FieldCodeUnknownField, Message: "The items[0].note field is not allowed."},
+		{Field: "items[1].amount", Code: FieldCodeRequired, Message: "The items[1].amount is required."},
+		{Field: "last.amount", Code: FieldCodeInvalidDecimal, Message: "The last.amount must be a valid decimal."},
+	}, err)
+}
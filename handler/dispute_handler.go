@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseDisputeId reads the dispute ID of the path, rendering the error when
+// it is not valid.
+func parseDisputeId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	disputeId, err := strconv.ParseUint(chi.URLParam(r, "disputeId"), 10, 64)
+
+	if (err != nil) || (disputeId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The dispute_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return disputeId, true
+}
+
+// DisputeHandler opens the disputes on purchases and withdraws and moves
+// them through their workflow, see model.Dispute.
+type DisputeHandler struct {
+	repository repository.DisputeRepository
+}
+
+func NewDisputeHandler(repository repository.DisputeRepository) *DisputeHandler {
+	return &DisputeHandler{
+		repository: repository,
+	}
+}
+
+func (c *DisputeHandler) OpenDispute(w http.ResponseWriter, r *http.Request) {
+	payload := &DisputePayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	opened, err := c.repository.OpenDispute(r.Context(), payload.Dispute())
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The transaction does not exist, or it is disputed already."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, opened)
+}
+
+// ListDisputes lists the disputes, optionally of an account, in a status or
+// escalated only, such as the ones to look into first.
+func (c *DisputeHandler) ListDisputes(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+
+	filter := repository.DisputeFilter{
+		AccountId: parseIdFilter(r, v, "account_id"),
+		Status:    r.URL.Query().Get("status"),
+	}
+
+	if filter.Status != "" {
+		v.check(model.ValidateDisputeStatus(filter.Status), "status", FieldCodeInvalidDisputeStatus, "The status must be one of the following valid values: opened, provisional_credit_issued, under_review, won, lost")
+	}
+
+	if escalated := r.URL.Query().Get("escalated"); escalated != "" {
+		var err error
+
+		filter.Escalated, err = strconv.ParseBool(escalated)
+		v.check(err == nil, "escalated", FieldCodeInvalidType, "The escalated must be true or false.")
+	}
+
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	disputes, err := c.repository.ListDisputes(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the disputes."))
+		return
+	}
+
+	response := &DisputeList{Disputes: disputes}
+
+	if len(disputes) > 0 {
+		response.NextPageToken = nextPageToken(page, len(disputes), disputes[len(disputes)-1].DisputeId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+func (c *DisputeHandler) GetDispute(w http.ResponseWriter, r *http.Request) {
+	disputeId, ok := parseDisputeId(w, r)
+	if !ok {
+		return
+	}
+
+	found, err := c.repository.FindDispute(disputeId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No dispute found for the provided dispute ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, found)
+}
+
+// ListDisputeChanges lists the history of the dispute, from the oldest
+// change.
+func (c *DisputeHandler) ListDisputeChanges(w http.ResponseWriter, r *http.Request) {
+	disputeId, ok := parseDisputeId(w, r)
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	if _, err := c.repository.FindDispute(disputeId); err != nil {
+		render.Render(w, r, errorRepository(err, "No dispute found for the provided dispute ID."))
+		return
+	}
+
+	changes, err := c.repository.ListDisputeChanges(disputeId, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the changes of the dispute."))
+		return
+	}
+
+	response := &DisputeChangeList{Changes: changes}
+
+	if len(changes) > 0 {
+		response.NextPageToken = nextPageToken(page, len(changes), changes[len(changes)-1].DisputeChangeId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// IssueProvisionalCredit credits the amount of the dispute to its account
+// while it is looked into.
+func (c *DisputeHandler) IssueProvisionalCredit(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, model.DISPUTE_STATUS_CREDITED, "", "No dispute found for the provided dispute ID, or it is not opened.")
+}
+
+func (c *DisputeHandler) StartReview(w http.ResponseWriter, r *http.Request) {
+	c.transition(w, r, model.DISPUTE_STATUS_UNDER_REVIEW, "", "No dispute found for the provided dispute ID, or its provisional credit was not issued.")
+}
+
+// ResolveDispute closes the dispute under review. The provisional credit is
+// kept when it is won and reversed when it is lost.
+func (c *DisputeHandler) ResolveDispute(w http.ResponseWriter, r *http.Request) {
+	payload := &DisputeResolutionPayload{}
+
+	if err := render.Bind(r, payload); err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	c.transition(w, r, payload.Outcome, payload.Note, "No dispute found for the provided dispute ID, or it is not under review.")
+}
+
+func (c *DisputeHandler) transition(w http.ResponseWriter, r *http.Request, status string, note string, errorText string) {
+	disputeId, ok := parseDisputeId(w, r)
+	if !ok {
+		return
+	}
+
+	moved, err := c.repository.TransitionDispute(r.Context(), disputeId, status, note)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, errorText))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, moved)
+}
+
+type DisputeList struct {
+	Disputes      []model.Dispute `json:"disputes"`
+	NextPageToken string          `json:"next_page_token,omitempty"`
+}
+
+func (s *DisputeList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type DisputeChangeList struct {
+	Changes       []model.DisputeChange `json:"changes"`
+	NextPageToken string                `json:"next_page_token,omitempty"`
+}
+
+func (s *DisputeChangeList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// MaxDisputeTextLength bounds the reasons of the disputes and the notes of
+// their changes.
+const MaxDisputeTextLength = 500
+
+// DisputePayload disputes Amount of the transaction, its whole amount when
+// it is left out.
+type DisputePayload struct {
+	TransactionId uint64       `json:"transaction_id" validate:"required"`
+	Amount        model.Amount `json:"amount"`
+	Reason        string       `json:"reason" validate:"required"`
+}
+
+func (s *DisputePayload) Dispute() model.Dispute {
+	return model.Dispute{
+		TransactionId: s.TransactionId,
+		Amount:        s.Amount,
+		Reason:        s.Reason,
+	}
+}
+
+func (s *DisputePayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *DisputePayload) Validate() error {
+	v := &validator{}
+
+	v.check(s.TransactionId > 0, "transaction_id", FieldCodeInvalidPositiveInteger, "The transaction_id must be a valid positive integer.")
+
+	v.check(s.Amount.Sign() >= 0, "amount", FieldCodeNegativeNumber, "The amount must not be negative.")
+
+	v.check(s.Reason != "" && len(s.Reason) <= MaxDisputeTextLength, "reason", FieldCodeOutOfRange, "The reason must have from 1 to 500 characters.")
+
+	return v.err()
+}
+
+func (s *DisputePayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// DisputeResolutionPayload closes a dispute with its outcome, won or lost.
+type DisputeResolutionPayload struct {
+	Outcome string `json:"outcome" validate:"required"`
+	Note    string `json:"note"`
+}
+
+func (s *DisputeResolutionPayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *DisputeResolutionPayload) Validate() error {
+	v := &validator{}
+
+	v.check(s.Outcome == model.DISPUTE_STATUS_WON || s.Outcome == model.DISPUTE_STATUS_LOST, "outcome", FieldCodeInvalidDisputeOutcome, "The outcome must be one of the following valid values: won, lost")
+
+	v.check(len(s.Note) <= MaxDisputeTextLength, "note", FieldCodeOutOfRange, "The note must have up to 500 characters.")
+
+	return v.err()
+}
+
+func (s *DisputeResolutionPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockDisputeRepository struct {
+	mock.Mock
+}
+
+func (m *MockDisputeRepository) OpenDispute(ctx context.Context, dispute model.Dispute) (*model.Dispute, error) {
+	args := m.Called(dispute)
+	return args.Get(0).(*model.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) FindDispute(disputeId uint64) (*model.Dispute, error) {
+	args := m.Called(disputeId)
+	return args.Get(0).(*model.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) ListDisputes(filter repository.DisputeFilter, page repository.Page) ([]model.Dispute, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) ListDisputeChanges(disputeId uint64, page repository.Page) ([]model.DisputeChange, error) {
+	args := m.Called(disputeId, page)
+	return args.Get(0).([]model.DisputeChange), args.Error(1)
+}
+
+func (m *MockDisputeRepository) TransitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error) {
+	args := m.Called(disputeId, status, note)
+	return args.Get(0).(*model.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]model.Dispute), args.Error(1)
+}
+
+func (m *MockDisputeRepository) EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error) {
+	args := m.Called(disputeId, now)
+	return args.Get(0).(*model.Dispute), args.Error(1)
+}
+
+func disputeRouter(mockRepo *MockDisputeRepository) http.Handler {
+	disputeHandler := NewDisputeHandler(mockRepo)
+
+	r := chi.NewRouter()
+	r.Post("/disputes", disputeHandler.OpenDispute)
+	r.Get("/disputes", disputeHandler.ListDisputes)
+	r.Get("/disputes/{disputeId}", disputeHandler.GetDispute)
+	r.Get("/disputes/{disputeId}/history", disputeHandler.ListDisputeChanges)
+	r.Post("/disputes/{disputeId}/provisional-credit", disputeHandler.IssueProvisionalCredit)
+	r.Post("/disputes/{disputeId}/review", disputeHandler.StartReview)
+	r.Post("/disputes/{disputeId}/resolution", disputeHandler.ResolveDispute)
+
+	return r
+}
+
+func TestOpenDispute(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	opened := model.Dispute{DisputeId: 1, TransactionId: 3, AccountId: 7, Amount: model.MustParseAmount("50"), Reason: "not received", Status: model.DISPUTE_STATUS_OPENED}
+
+	mockRepo.On("OpenDispute", model.Dispute{TransactionId: 3, Reason: "not received"}).Return(&opened, nil)
+
+	req := httptest.NewRequest("POST", "/disputes", strings.NewReader(`{"transaction_id": 3, "reason": "not received"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := model.Dispute{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, opened, response)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOpenDisputeValidatesPayload(t *testing.T) {
+	for payload, expectedCode := range map[string]string{
+		`{"transaction_id": 3}`: FieldCodeRequired,
+		`{"transaction_id": 3, "amount": -1, "reason": "not received"}`:       FieldCodeNegativeNumber,
+		`{"transaction_id": 3, "reason": "` + strings.Repeat("a", 501) + `"}`: FieldCodeOutOfRange,
+	} {
+		mockRepo := new(MockDisputeRepository)
+
+		req := httptest.NewRequest("POST", "/disputes", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		disputeRouter(mockRepo).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`, payload)
+
+		mockRepo.AssertNotCalled(t, "OpenDispute", mock.Anything)
+	}
+}
+
+func TestOpenDisputeNotDisputable(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	mockRepo.On("OpenDispute", mock.Anything).Return((*model.Dispute)(nil), repository.ErrNotDisputable)
+
+	req := httptest.NewRequest("POST", "/disputes", strings.NewReader(`{"transaction_id": 4, "reason": "not received"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), CodeNotDisputable)
+}
+
+func TestListDisputes(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	disputes := []model.Dispute{{DisputeId: 2, TransactionId: 3, AccountId: 7, Amount: model.MustParseAmount("50"), Status: model.DISPUTE_STATUS_UNDER_REVIEW, Escalated: true}}
+
+	mockRepo.On("ListDisputes", repository.DisputeFilter{AccountId: 7, Status: model.DISPUTE_STATUS_UNDER_REVIEW, Escalated: true}, repository.Page{Limit: 1}).Return(disputes, nil)
+
+	req := httptest.NewRequest("GET", "/disputes?account_id=7&status=under_review&escalated=true&page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := DisputeList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, disputes, response.Disputes)
+	assert.Equal(t, "2", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListDisputesValidatesFilters(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	req := httptest.NewRequest("GET", "/disputes?status=closed&escalated=maybe", nil)
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"`+FieldCodeInvalidDisputeStatus+`"`)
+	assert.Contains(t, w.Body.String(), `"field":"escalated"`)
+
+	mockRepo.AssertNotCalled(t, "ListDisputes", mock.Anything, mock.Anything)
+}
+
+func TestGetDispute(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	mockRepo.On("FindDispute", uint64(2)).Return(&model.Dispute{DisputeId: 2, Status: model.DISPUTE_STATUS_OPENED}, nil)
+	mockRepo.On("FindDispute", uint64(3)).Return((*model.Dispute)(nil), repository.ErrNotFound)
+
+	req := httptest.NewRequest("GET", "/disputes/2", nil)
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest("GET", "/disputes/3", nil)
+	w = httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListDisputeChanges(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	changes := []model.DisputeChange{{DisputeChangeId: 4, DisputeId: 2, Status: model.DISPUTE_STATUS_CREDITED}}
+
+	mockRepo.On("FindDispute", uint64(2)).Return(&model.Dispute{DisputeId: 2}, nil)
+	mockRepo.On("FindDispute", uint64(3)).Return((*model.Dispute)(nil), repository.ErrNotFound)
+	mockRepo.On("ListDisputeChanges", uint64(2), repository.Page{Limit: 1}).Return(changes, nil)
+
+	req := httptest.NewRequest("GET", "/disputes/2/history?page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := DisputeChangeList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, changes, response.Changes)
+	assert.Equal(t, "4", response.NextPageToken)
+
+	req = httptest.NewRequest("GET", "/disputes/3/history", nil)
+	w = httptest.NewRecorder()
+
+	disputeRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDisputeTransitions(t *testing.T) {
+	mockRepo := new(MockDisputeRepository)
+
+	mockRepo.On("TransitionDispute", uint64(2), model.DISPUTE_STATUS_CREDITED, "").Return(&model.Dispute{DisputeId: 2, Status: model.DISPUTE_STATUS_CREDITED, CreditTransactionId: 9}, nil)
+	mockRepo.On("TransitionDispute", uint64(2), model.DISPUTE_STATUS_UNDER_REVIEW, "").Return((*model.Dispute)(nil), repository.ErrConflict)
+	mockRepo.On("TransitionDispute", uint64(2), model.DISPUTE_STATUS_LOST, "delivery proven").Return(&model.Dispute{DisputeId: 2, Status: model.DISPUTE_STATUS_LOST}, nil)
+
+	for _, scenario := range []struct {
+		path         string
+		payload      string
+		expectedCode int
+	}{
+		{"/disputes/2/provisional-credit", "", http.StatusOK},
+		{"/disputes/2/review", "", http.StatusConflict},
+		{"/disputes/2/resolution", `{"outcome": "lost", "note": "delivery proven"}`, http.StatusOK},
+		{"/disputes/2/resolution", `{"outcome": "under_review"}`, http.StatusBadRequest},
+		{"/disputes/x/review", "", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("POST", scenario.path, strings.NewReader(scenario.payload))
+
+		if scenario.payload != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		w := httptest.NewRecorder()
+
+		disputeRouter(mockRepo).ServeHTTP(w, req)
+
+		assert.Equal(t, scenario.expectedCode, w.Code, scenario.path)
+	}
+
+	mockRepo.AssertExpectations(t)
+}
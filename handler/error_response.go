@@ -1,43 +1,219 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
 	"github.com/go-chi/render"
 )
 
+// Codes sent in the code field of every error response. Clients branch on
+// them, so they must never change once released.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInvalidReference     = "invalid_reference"
+	CodeBatchAborted         = "batch_aborted"
+	CodeAccountBlocked       = "account_blocked"
+	CodeFxRateNotFound       = "fx_rate_not_found"
+	CodeInvalidAmount        = "invalid_amount"
+	CodeCardInactive         = "card_inactive"
+	CodeCardLimitExceeded    = "card_limit_exceeded"
+	CodeMerchantDenied       = "merchant_denied"
+	CodeMerchantNotAllowed   = "merchant_not_allowed"
+	CodeCategoryCapExceeded  = "category_limit_exceeded"
+	CodeRiskDeclined         = "risk_declined"
+	CodeNotDisputable        = "not_disputable"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeUnavailable          = "service_unavailable"
+	CodeTimeout              = "timeout"
+	CodeInternalError        = "internal_error"
+)
+
+// Codes lists every code above, so the clients can check they know them all.
+// Add the new codes to it.
+var Codes = []string{
+	CodeInvalidRequest,
+	CodeNotFound,
+	CodeConflict,
+	CodePayloadTooLarge,
+	CodeUnsupportedMediaType,
+	CodeInvalidReference,
+	CodeBatchAborted,
+	CodeAccountBlocked,
+	CodeFxRateNotFound,
+	CodeInvalidAmount,
+	CodeCardInactive,
+	CodeCardLimitExceeded,
+	CodeMerchantDenied,
+	CodeMerchantNotAllowed,
+	CodeCategoryCapExceeded,
+	CodeRiskDeclined,
+	CodeNotDisputable,
+	CodePreconditionFailed,
+	CodeUnavailable,
+	CodeTimeout,
+	CodeInternalError,
+}
+
+const ProblemContentType = "application/problem+json"
+
+// ErrorResponse is an RFC 7807 problem details document. The code and errors
+// members are extensions carrying the machine-readable error code and the
+// fields that failed validation.
 type ErrorResponse struct {
-	Err            error `json:"-"` // low-level runtime error
-	HTTPStatusCode int   `json:"-"` // http response status code
+	Err error `json:"-"` // low-level runtime error
 
-	StatusText string `json:"status"`          // user-level status message
-	ErrorText  string `json:"error,omitempty"` // application-level error message
+	Type     string       `json:"type"`               // URI reference identifying the problem type
+	Title    string       `json:"title"`              // user-level status message
+	Status   int          `json:"status"`             // http response status code
+	Detail   string       `json:"detail,omitempty"`   // application-level error message
+	Instance string       `json:"instance,omitempty"` // path of the request that failed
+	Code     string       `json:"code"`               // machine-readable error code
+	Errors   []FieldError `json:"errors,omitempty"`   // fields that failed validation
 }
 
 func (e *ErrorResponse) Render(w http.ResponseWriter, r *http.Request) error {
-	render.Status(r, e.HTTPStatusCode)
+	e.Instance = r.URL.Path
+
+	render.Status(r, e.Status)
 	return nil
 }
 
+func init() {
+	render.Respond = respond
+}
+
+// respond sends error responses as problem+json and leaves every other
+// payload to the default chi responder.
+func respond(w http.ResponseWriter, r *http.Request, v interface{}) {
+	problem, ok := v.(*ErrorResponse)
+
+	if !ok {
+		render.DefaultResponder(w, r, v)
+		return
+	}
+
+	body, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
+
+func newErrorResponse(err error, status int, title string, code string, detail string) *ErrorResponse {
+	return &ErrorResponse{
+		Err:    err,
+		Type:   "/problems/" + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// errorRiskDeclined reports a transaction the risk rules declined, naming
+// the rules that fired.
+func errorRiskDeclined(decision *model.RiskDecision) render.Renderer {
+	log.Printf("Risk decision %d declined the transaction: %v", decision.RiskDecisionId, decision.Rules)
+
+	return newErrorResponse(nil, 422, "Unprocessable entity", CodeRiskDeclined, fmt.Sprintf("The transaction was declined by the risk rules: %s.", strings.Join(decision.Rules, ", ")))
+}
+
 func errorInvalidRequest(err error, errorText string) render.Renderer {
 	log.Printf("Invalid request error: %s, %s", err, errorText)
 
-	return &ErrorResponse{
-		Err:            err,
-		HTTPStatusCode: 400,
-		StatusText:     "Invalid request",
-		ErrorText:      errorText,
+	return newErrorResponse(err, 400, "Invalid request", CodeInvalidRequest, errorText)
+}
+
+// errorValidation reports every field that failed validation, with their
+// messages joined in the detail for clients that only display it.
+func errorValidation(err ValidationErrors) render.Renderer {
+	log.Printf("Validation error: %s", err)
+
+	response := newErrorResponse(err, 400, "Invalid request", CodeInvalidRequest, err.Error())
+	response.Errors = err
+
+	return response
+}
+
+// errorBinding reports a payload that render.Bind rejected, either because
+// the body could not be decoded or because it failed validation.
+func errorBinding(err error) render.Renderer {
+	var validationErrors ValidationErrors
+
+	switch {
+	case errors.As(err, &validationErrors):
+		return errorValidation(validationErrors)
+	case errors.Is(err, errUnsupportedMediaType):
+		log.Printf("Invalid request error: %s", err)
+
+		return newErrorResponse(err, 415, "Unsupported media type", CodeUnsupportedMediaType, "The request body must be sent as application/json.")
+	case errors.Is(err, errPayloadTooLarge):
+		log.Printf("Invalid request error: %s", err)
+
+		return newErrorResponse(err, 413, "Payload too large", CodePayloadTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", MaxPayloadSize))
+	default:
+		return errorInvalidRequest(err, "The request body must be a single valid JSON object.")
 	}
 }
 
-func errorNotFound(err error, errorText string) render.Renderer {
-	log.Printf("Not found error: %s, %s", err, errorText)
+// errorRepository maps the repository error kinds to a response. The
+// errorText is only shown for failures caused by the request itself, storage
+// failures get a generic message so no internals leak to the client.
+func errorRepository(err error, errorText string) render.Renderer {
+	log.Printf("Repository error: %s, %s", err, errorText)
 
-	return &ErrorResponse{
-		Err:            err,
-		HTTPStatusCode: 404,
-		StatusText:     "Not found",
-		ErrorText:      errorText,
+	var declined *risk.DeclinedError
+
+	switch {
+	case errors.As(err, &declined):
+		return errorRiskDeclined(declined.Decision)
+	case errors.Is(err, repository.ErrNotFound):
+		return newErrorResponse(err, 404, "Not found", CodeNotFound, errorText)
+	case errors.Is(err, repository.ErrConflict):
+		return newErrorResponse(err, 409, "Conflict", CodeConflict, errorText)
+	case errors.Is(err, repository.ErrForeignKeyViolation):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeInvalidReference, errorText)
+	case errors.Is(err, repository.ErrAccountBlocked):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeAccountBlocked, "The account is blocked and takes no more transactions.")
+	case errors.Is(err, repository.ErrFxRateNotFound):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeFxRateNotFound, "No exchange rate from the currency of the transaction to the one of its account was effective at its event_date.")
+	case errors.Is(err, repository.ErrInvalidAmount):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeInvalidAmount, "The amount has more decimals than the currency of the account takes.")
+	case errors.Is(err, repository.ErrCardInactive):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeCardInactive, "The card is blocked, replaced or expired and takes no more purchases or withdraws.")
+	case errors.Is(err, repository.ErrCardLimitExceeded):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeCardLimitExceeded, "The amount exceeds the transaction or daily limit of the card.")
+	case errors.Is(err, repository.ErrMerchantDenied):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeMerchantDenied, "The account denies purchases and withdraws at the merchant category code, or the category, of the transaction.")
+	case errors.Is(err, repository.ErrMerchantNotAllowed):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeMerchantNotAllowed, "The account only allows purchases and withdraws at some merchant category codes or categories, and the transaction is at none of them.")
+	case errors.Is(err, repository.ErrCategoryCapExceeded):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeCategoryCapExceeded, "The amount exceeds the spend cap of the account for the category of the transaction.")
+	case errors.Is(err, repository.ErrNotDisputable):
+		return newErrorResponse(err, 422, "Unprocessable entity", CodeNotDisputable, "Only purchases and withdraws that were not reversed can be disputed, for up to their amount.")
+	case errors.Is(err, repository.ErrVersionConflict):
+		return newErrorResponse(err, 412, "Precondition failed", CodePreconditionFailed, "The account changed since the version in If-Match.")
+	case errors.Is(err, repository.ErrUnavailable):
+		return newErrorResponse(err, 503, "Service unavailable", CodeUnavailable, "The service is temporarily unavailable. Please try again later.")
+	case errors.Is(err, repository.ErrTimeout):
+		return newErrorResponse(err, 503, "Service unavailable", CodeTimeout, "The request took too long to complete. Please try again later.")
+	default:
+		return newErrorResponse(err, 500, "Internal server error", CodeInternalError, "An unexpected error occurred.")
 	}
 }
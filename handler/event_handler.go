@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// versionETag is the ETag of the version of an account, as returned by
+// GET /accounts/{accountId}/balance.
+func versionETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// ifMatch returns the context of the request expecting the version of the
+// account sent in If-Match, if any, rendering the error when it is not an
+// ETag returned by the API.
+func ifMatch(w http.ResponseWriter, r *http.Request) (context.Context, bool) {
+	header := r.Header.Get("If-Match")
+
+	if header == "" {
+		return r.Context(), true
+	}
+
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+
+	if err != nil {
+		render.Render(w, r, errorInvalidRequest(err, "The If-Match header must hold the ETag of the balance of the account."))
+		return nil, false
+	}
+
+	return repository.WithExpectedVersion(r.Context(), version), true
+}
+
+type EventHandler struct {
+	repository repository.EventRepository
+}
+
+func NewEventHandler(repository repository.EventRepository) *EventHandler {
+	return &EventHandler{
+		repository: repository,
+	}
+}
+
+func (c *EventHandler) ListAccountEvents(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	events, err := c.repository.ListEvents(repository.EventFilter{AccountId: accountId}, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the events."))
+		return
+	}
+
+	response := &EventList{Events: events}
+
+	if len(events) > 0 {
+		response.NextPageToken = nextPageToken(page, len(events), events[len(events)-1].EventId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+type EventList struct {
+	Events        []model.Event `json:"events"`
+	NextPageToken string        `json:"next_page_token,omitempty"`
+}
+
+func (e *EventList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/statement"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// DefaultExportStreamLimit is the largest statement, in transactions,
+// rendered in the response of the export request. Larger ones are exported
+// in the background.
+const DefaultExportStreamLimit = 10000
+
+// exportBufferSize is how much of a streamed statement is held before the
+// response starts. An error met before that can still be reported as a
+// problem instead of a truncated file.
+const exportBufferSize = 32 << 10
+
+const dateLayout = "2006-01-02"
+
+// ExportRunner renders statements in the background and gives back the
+// completed ones.
+type ExportRunner interface {
+	Submit(ctx context.Context, export model.Export) (*model.Export, error)
+	Open(export model.Export) (io.ReadSeekCloser, error)
+}
+
+type ExportHandler struct {
+	accounts    repository.AccountRepository
+	exports     repository.ExportRepository
+	runner      ExportRunner
+	streamLimit uint64
+}
+
+// NewExportHandler streams the statements of up to streamLimit transactions
+// and hands the larger ones to runner.
+func NewExportHandler(accounts repository.AccountRepository, exports repository.ExportRepository, runner ExportRunner, streamLimit uint64) *ExportHandler {
+	if streamLimit == 0 {
+		streamLimit = DefaultExportStreamLimit
+	}
+
+	return &ExportHandler{
+		accounts:    accounts,
+		exports:     exports,
+		runner:      runner,
+		streamLimit: streamLimit,
+	}
+}
+
+func (c *ExportHandler) ExportTransactions(w http.ResponseWriter, r *http.Request) {
+	accountId, err := strconv.ParseUint(chi.URLParam(r, "accountId"), 10, 64)
+
+	if (err != nil) || (accountId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The account_id must be a valid positive integer."))
+		return
+	}
+
+	v := &validator{}
+	query := r.URL.Query()
+	format := query.Get("format")
+
+	v.check(statement.ContentType(format) != "", "format", FieldCodeInvalidExportFormat, "The format must be csv, ofx or pdf.")
+
+	filter := repository.StatementFilter{
+		AccountId: accountId,
+		From:      parseDate(v, query.Get("from"), "from", false),
+		To:        parseDate(v, query.Get("to"), "to", true),
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() {
+		v.check(filter.From.Before(filter.To), "to", FieldCodeInvalidPeriod, "The to date must be after the from date.")
+	}
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	account, err := c.accounts.FindAccount(accountId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No account found for the provided account ID."))
+		return
+	}
+
+	count, err := c.exports.CountStatementLines(filter)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when counting the transactions."))
+		return
+	}
+
+	if count > c.streamLimit {
+		c.submit(w, r, format, filter)
+		return
+	}
+
+	header := statement.Header{
+		Account:     *account,
+		From:        filter.From,
+		To:          filter.To,
+		GeneratedAt: time.Now(),
+	}
+
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.FileName(header, format)))
+
+	response := &startedWriter{w: w}
+	buffered := bufio.NewWriterSize(response, exportBufferSize)
+
+	_, err = statement.Export(buffered, format, header, filter, c.exports)
+
+	if err == nil {
+		err = buffered.Flush()
+	}
+
+	if err == nil {
+		return
+	}
+
+	if response.started {
+		// The status was already sent, aborting is the only way left to
+		// tell the client the statement is incomplete.
+		log.Printf("ExportHandler#ExportTransactions: Streaming the statement of account %d failed: %s", accountId, err)
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Del("Content-Disposition")
+	render.Render(w, r, errorRepository(err, "An error occurred when exporting the transactions."))
+}
+
+// submit queues the export of a statement too large to be streamed.
+func (c *ExportHandler) submit(w http.ResponseWriter, r *http.Request, format string, filter repository.StatementFilter) {
+	export := model.Export{AccountId: filter.AccountId, Format: format}
+
+	if !filter.From.IsZero() {
+		export.From = &filter.From
+	}
+
+	if !filter.To.IsZero() {
+		export.To = &filter.To
+	}
+
+	created, err := c.runner.Submit(r.Context(), export)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The provided account does not exist."))
+		return
+	}
+
+	w.Header().Set("Location", "/exports/"+strconv.FormatUint(created.ExportId, 10))
+
+	render.Status(r, http.StatusAccepted)
+	render.Render(w, r, created)
+}
+
+func (c *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	export, ok := c.findExport(w, r)
+
+	if !ok {
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, export)
+}
+
+func (c *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+	export, ok := c.findExport(w, r)
+
+	if !ok {
+		return
+	}
+
+	if export.Status != model.EXPORT_COMPLETED {
+		render.Render(w, r, newErrorResponse(errors.New("export not completed"), 409, "Conflict", CodeConflict, "The export is "+export.Status+" and has no statement to download."))
+		return
+	}
+
+	file, err := c.runner.Open(*export)
+
+	if err != nil {
+		log.Printf("ExportHandler#DownloadExport: Opening export %d failed: %s", export.ExportId, err)
+
+		render.Render(w, r, newErrorResponse(err, 500, "Internal server error", CodeInternalError, "An unexpected error occurred."))
+		return
+	}
+
+	defer file.Close()
+
+	filter := repository.ExportFilter(*export)
+	header := statement.Header{Account: model.Account{AccountId: export.AccountId}, From: filter.From, To: filter.To}
+
+	w.Header().Set("Content-Type", statement.ContentType(export.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statement.FileName(header, export.Format)))
+
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
+
+func (c *ExportHandler) findExport(w http.ResponseWriter, r *http.Request) (*model.Export, bool) {
+	exportId, err := strconv.ParseUint(chi.URLParam(r, "exportId"), 10, 64)
+
+	if (err != nil) || (exportId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The export_id must be a valid positive integer."))
+		return nil, false
+	}
+
+	export, err := c.exports.FindExport(exportId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No export found for the provided export ID."))
+		return nil, false
+	}
+
+	return export, true
+}
+
+// parseDate reads a date filter given either as an RFC 3339 timestamp or as
+// a calendar day in UTC. A day given as the end of the period is included
+// in it whole.
+func parseDate(v *validator, param string, name string, end bool) time.Time {
+	if param == "" {
+		return time.Time{}
+	}
+
+	if t, err := time.Parse(time.RFC3339, param); err == nil {
+		return t
+	}
+
+	day, err := time.Parse(dateLayout, param)
+
+	if !v.check(err == nil, name, FieldCodeInvalidDate, "The "+name+" must be a date (YYYY-MM-DD) or an RFC 3339 timestamp.") {
+		return time.Time{}
+	}
+
+	if end {
+		return day.AddDate(0, 0, 1)
+	}
+
+	return day
+}
+
+// startedWriter records whether anything reached the client yet.
+type startedWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *startedWriter) Write(p []byte) (int, error) {
+	s.started = true
+
+	return s.w.Write(p)
+}
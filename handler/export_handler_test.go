@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockExportRepository struct {
+	mock.Mock
+}
+
+func (m *MockExportRepository) CountStatementLines(filter repository.StatementFilter) (uint64, error) {
+	args := m.Called(filter)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+// StreamStatementLines hands the lines given to Return to fn.
+func (m *MockExportRepository) StreamStatementLines(filter repository.StatementFilter, fn func(model.StatementLine) error) error {
+	args := m.Called(filter)
+
+	for _, line := range args.Get(0).([]model.StatementLine) {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return args.Error(1)
+}
+
+func (m *MockExportRepository) CreateExport(ctx context.Context, export model.Export) (*model.Export, error) {
+	args := m.Called(export)
+	return args.Get(0).(*model.Export), args.Error(1)
+}
+
+func (m *MockExportRepository) FindExport(exportId uint64) (*model.Export, error) {
+	args := m.Called(exportId)
+	return args.Get(0).(*model.Export), args.Error(1)
+}
+
+func (m *MockExportRepository) ListPendingExports() ([]model.Export, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Export), args.Error(1)
+}
+
+func (m *MockExportRepository) FinishExport(exportId uint64, status string, rows uint64, message string) (*model.Export, error) {
+	args := m.Called(exportId, status, rows, message)
+	return args.Get(0).(*model.Export), args.Error(1)
+}
+
+type MockExportRunner struct {
+	mock.Mock
+}
+
+func (m *MockExportRunner) Submit(ctx context.Context, export model.Export) (*model.Export, error) {
+	args := m.Called(export)
+	return args.Get(0).(*model.Export), args.Error(1)
+}
+
+func (m *MockExportRunner) Open(export model.Export) (io.ReadSeekCloser, error) {
+	args := m.Called(export)
+	return args.Get(0).(io.ReadSeekCloser), args.Error(1)
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
+
+func withURLParam(req *http.Request, name string, value string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(name, value)
+
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestExportTransactionsStreamsStatement(t *testing.T) {
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccount", uint64(1)).Return(&model.Account{AccountId: 1, DocumentNumber: 111}, nil)
+
+	filter := repository.StatementFilter{
+		AccountId: 1,
+		From:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	lines := []model.StatementLine{{
+		Transaction: model.Transaction{TransactionId: 5, AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("12.5")},
+		Description: "PAGAMENTO",
+		CreatedAt:   time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC),
+	}}
+
+	exports := new(MockExportRepository)
+	exports.On("CountStatementLines", filter).Return(uint64(1), nil)
+	exports.On("StreamStatementLines", filter).Return(lines, nil)
+
+	req := withURLParam(httptest.NewRequest("GET", "/accounts/1/transactions/export?format=csv&from=2024-01-01&to=2024-01-31", nil), "accountId", "1")
+	w := httptest.NewRecorder()
+
+	NewExportHandler(accounts, exports, new(MockExportRunner), 0).ExportTransactions(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="account-1-statement.csv"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "transaction_id,created_at,operation_type_id,description,amount\n5,2024-01-31T10:00:00Z,4,PAGAMENTO,12.50\n", w.Body.String())
+}
+
+func TestExportTransactionsSubmitsLargeStatements(t *testing.T) {
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccount", uint64(1)).Return(&model.Account{AccountId: 1}, nil)
+
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	exports := new(MockExportRepository)
+	exports.On("CountStatementLines", repository.StatementFilter{AccountId: 1, From: from}).Return(uint64(11), nil)
+
+	runner := new(MockExportRunner)
+	runner.On("Submit", model.Export{AccountId: 1, Format: model.EXPORT_FORMAT_PDF, From: &from}).
+		Return(&model.Export{ExportId: 3, AccountId: 1, Format: model.EXPORT_FORMAT_PDF, From: &from, Status: model.EXPORT_PENDING}, nil)
+
+	req := withURLParam(httptest.NewRequest("GET", "/accounts/1/transactions/export?format=pdf&from=2024-01-01T12:00:00Z", nil), "accountId", "1")
+	w := httptest.NewRecorder()
+
+	NewExportHandler(accounts, exports, runner, 10).ExportTransactions(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, "/exports/3", w.Header().Get("Location"))
+	assert.JSONEq(t, `{"export_id":3,"account_id":1,"format":"pdf","from":"2024-01-01T12:00:00Z","status":"pending","rows":0}`, w.Body.String())
+	exports.AssertNotCalled(t, "StreamStatementLines", mock.Anything)
+}
+
+func TestExportTransactionsValidatesQuery(t *testing.T) {
+	scenarios := []struct {
+		name          string
+		query         string
+		expectedField string
+		expectedCode  string
+	}{
+		{"missing format", "", "format", FieldCodeInvalidExportFormat},
+		{"unknown format", "format=xlsx", "format", FieldCodeInvalidExportFormat},
+		{"invalid date", "format=csv&from=yesterday", "from", FieldCodeInvalidDate},
+		{"empty period", "format=csv&from=2024-02-01&to=2024-01-01", "to", FieldCodeInvalidPeriod},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			req := withURLParam(httptest.NewRequest("GET", "/accounts/1/transactions/export?"+scenario.query, nil), "accountId", "1")
+			w := httptest.NewRecorder()
+
+			NewExportHandler(new(MockAccountRepository), new(MockExportRepository), new(MockExportRunner), 0).ExportTransactions(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `{"field":"`+scenario.expectedField+`","code":"`+scenario.expectedCode+`"`)
+		})
+	}
+}
+
+func TestExportTransactionsReportsErrorsBeforeStreaming(t *testing.T) {
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccount", uint64(1)).Return(&model.Account{AccountId: 1}, nil)
+
+	exports := new(MockExportRepository)
+	exports.On("CountStatementLines", repository.StatementFilter{AccountId: 1}).Return(uint64(1), nil)
+	exports.On("StreamStatementLines", repository.StatementFilter{AccountId: 1}).Return([]model.StatementLine{}, repository.ErrUnavailable)
+
+	req := withURLParam(httptest.NewRequest("GET", "/accounts/1/transactions/export?format=ofx", nil), "accountId", "1")
+	w := httptest.NewRecorder()
+
+	NewExportHandler(accounts, exports, new(MockExportRunner), 0).ExportTransactions(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+}
+
+func TestDownloadExport(t *testing.T) {
+	scenarios := []struct {
+		name               string
+		status             string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{"completed export", model.EXPORT_COMPLETED, http.StatusOK, "statement"},
+		{"pending export", model.EXPORT_PENDING, http.StatusConflict, `"code":"conflict"`},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			export := &model.Export{ExportId: 3, AccountId: 1, Format: model.EXPORT_FORMAT_CSV, Status: scenario.status}
+
+			exports := new(MockExportRepository)
+			exports.On("FindExport", uint64(3)).Return(export, nil)
+
+			runner := new(MockExportRunner)
+			runner.On("Open", *export).Return(nopSeekCloser{strings.NewReader("statement")}, nil)
+
+			req := withURLParam(httptest.NewRequest("GET", "/exports/3/download", nil), "exportId", "3")
+			w := httptest.NewRecorder()
+
+			NewExportHandler(new(MockAccountRepository), exports, runner, 0).DownloadExport(w, req)
+
+			assert.Equal(t, scenario.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), scenario.expectedBody)
+		})
+	}
+}
+
+func TestGetExportNotFound(t *testing.T) {
+	exports := new(MockExportRepository)
+	exports.On("FindExport", uint64(3)).Return((*model.Export)(nil), repository.ErrNotFound)
+
+	req := withURLParam(httptest.NewRequest("GET", "/exports/3", nil), "exportId", "3")
+	w := httptest.NewRecorder()
+
+	NewExportHandler(new(MockAccountRepository), exports, new(MockExportRunner), 0).GetExport(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
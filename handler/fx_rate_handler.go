@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/render"
+)
+
+// MaxFxRatesSize is the largest file accepted by POST /fx-rates.
+const MaxFxRatesSize = 10 << 20
+
+// fxRateColumns are the columns of a file of rates, in any order.
+var fxRateColumns = []string{"base_currency", "quote_currency", "rate", "effective_at"}
+
+type FxRateHandler struct {
+	repository repository.FxRateRepository
+}
+
+func NewFxRateHandler(repository repository.FxRateRepository) *FxRateHandler {
+	return &FxRateHandler{
+		repository: repository,
+	}
+}
+
+// CreateFxRates loads a CSV file of rates. The file is stored whole or, when
+// any line is invalid, not at all.
+func (c *FxRateHandler) CreateFxRates(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil || mediaType != "text/csv" {
+		log.Printf("Invalid request error: unsupported rates content type %q", r.Header.Get("Content-Type"))
+
+		render.Render(w, r, newErrorResponse(errUnsupportedMediaType, 415, "Unsupported media type", CodeUnsupportedMediaType, "The rates must be sent as text/csv."))
+		return
+	}
+
+	rates, err := ReadFxRates(http.MaxBytesReader(w, r.Body, MaxFxRatesSize))
+
+	var maxBytesErr *http.MaxBytesError
+	var validationErrors ValidationErrors
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		log.Printf("Invalid request error: %s", err)
+
+		render.Render(w, r, newErrorResponse(err, 413, "Payload too large", CodePayloadTooLarge, fmt.Sprintf("The file must not be larger than %d bytes.", MaxFxRatesSize)))
+		return
+	case errors.As(err, &validationErrors):
+		render.Render(w, r, errorValidation(validationErrors))
+		return
+	case err != nil:
+		render.Render(w, r, errorInvalidRequest(err, fmt.Sprintf("The file could not be read: %s.", err)))
+		return
+	}
+
+	created, err := c.repository.CreateFxRates(r.Context(), rates)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "A rate of one of the pairs already takes effect at the same time."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, &FxRateList{FxRates: created})
+}
+
+func (c *FxRateHandler) ListFxRates(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+	query := r.URL.Query()
+
+	filter := repository.FxRateFilter{
+		BaseCurrency:  parseCurrencyFilter(v, query.Get("base_currency"), "base_currency"),
+		QuoteCurrency: parseCurrencyFilter(v, query.Get("quote_currency"), "quote_currency"),
+	}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	rates, err := c.repository.ListFxRates(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the rates."))
+		return
+	}
+
+	response := &FxRateList{FxRates: rates}
+
+	if len(rates) > 0 {
+		response.NextPageToken = nextPageToken(page, len(rates), rates[len(rates)-1].FxRateId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// parseCurrencyFilter reads an optional currency query parameter.
+func parseCurrencyFilter(v *validator, value string, name string) string {
+	if value == "" || !v.check(model.ValidateCurrency(value), name, FieldCodeInvalidCurrency, "The "+name+" must be a supported ISO 4217 code, such as BRL or USD.") {
+		return ""
+	}
+
+	return value
+}
+
+type FxRateList struct {
+	FxRates       []model.FxRate `json:"fx_rates"`
+	NextPageToken string         `json:"next_page_token,omitempty"`
+}
+
+func (f *FxRateList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ReadFxRates reads a CSV file of rates, whose header names the columns
+// base_currency, quote_currency, rate and effective_at, the last one in
+// RFC 3339. Every invalid line is reported in the returned
+// ValidationErrors, with its number in the message.
+func ReadFxRates(r io.Reader) ([]model.FxRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("the CSV file is empty, it must start with a header")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	positions := map[string]int{}
+
+	for i, column := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF")))] = i
+	}
+
+	for _, column := range fxRateColumns {
+		if _, ok := positions[column]; !ok || len(header) != len(fxRateColumns) {
+			return nil, fmt.Errorf("the CSV header must have the columns %s", strings.Join(fxRateColumns, ", "))
+		}
+	}
+
+	rates := []model.FxRate{}
+	v := &validator{}
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		prefix := fmt.Sprintf("Line %d: ", line)
+
+		rate := model.FxRate{
+			BaseCurrency:  strings.TrimSpace(record[positions["base_currency"]]),
+			QuoteCurrency: strings.TrimSpace(record[positions["quote_currency"]]),
+		}
+
+		v.check(model.ValidateCurrency(rate.BaseCurrency), "base_currency", FieldCodeInvalidCurrency, prefix+"The base_currency must be a supported ISO 4217 code, such as BRL or USD.")
+
+		v.check(model.ValidateCurrency(rate.QuoteCurrency) && rate.QuoteCurrency != rate.BaseCurrency, "quote_currency", FieldCodeInvalidCurrency, prefix+"The quote_currency must be a supported ISO 4217 code other than the base_currency.")
+
+		rate.Rate, err = strconv.ParseFloat(strings.TrimSpace(record[positions["rate"]]), 64)
+
+		v.check(err == nil && rate.Rate > 0, "rate", FieldCodeInvalidDecimal, prefix+"The rate must be a positive decimal.")
+
+		rate.EffectiveAt, err = time.Parse(time.RFC3339, strings.TrimSpace(record[positions["effective_at"]]))
+
+		v.check(err == nil, "effective_at", FieldCodeInvalidDate, prefix+"The effective_at must be a date-time in RFC 3339, such as 2024-03-01T00:00:00Z.")
+
+		rates = append(rates, rate)
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("the CSV file has no rates")
+	}
+
+	return rates, nil
+}
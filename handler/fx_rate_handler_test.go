@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockFxRateRepository struct {
+	mock.Mock
+}
+
+func (m *MockFxRateRepository) CreateFxRates(ctx context.Context, rates []model.FxRate) ([]model.FxRate, error) {
+	args := m.Called(rates)
+	return args.Get(0).([]model.FxRate), args.Error(1)
+}
+
+func (m *MockFxRateRepository) ListFxRates(filter repository.FxRateFilter, page repository.Page) ([]model.FxRate, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.FxRate), args.Error(1)
+}
+
+func (m *MockFxRateRepository) FindFxRate(base string, quote string, at time.Time) (*model.FxRate, error) {
+	args := m.Called(base, quote, at)
+	return args.Get(0).(*model.FxRate), args.Error(1)
+}
+
+func TestCreateFxRates(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	effectiveAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	expected := []model.FxRate{
+		{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 4.9876, EffectiveAt: effectiveAt},
+		{BaseCurrency: "JPY", QuoteCurrency: "BRL", Rate: 0.0332, EffectiveAt: effectiveAt},
+	}
+
+	created := []model.FxRate{expected[0], expected[1]}
+	created[0].FxRateId = 1
+	created[1].FxRateId = 2
+
+	mockRepo.On("CreateFxRates", expected).Return(created, nil)
+
+	body := "rate,base_currency,quote_currency,effective_at\n4.9876,USD,BRL,2024-03-01T00:00:00Z\n0.0332, JPY, BRL, 2024-03-01T00:00:00Z\n"
+
+	req := httptest.NewRequest("POST", "/fx-rates", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).CreateFxRates(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := FxRateList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, created, response.FxRates)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateFxRatesReportsInvalidLines(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	body := "base_currency,quote_currency,rate,effective_at\nUSD,USD,1,2024-03-01T00:00:00Z\nXYZ,BRL,-2,yesterday\n"
+
+	req := httptest.NewRequest("POST", "/fx-rates", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).CreateFxRates(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Line 2: The quote_currency")
+	assert.Contains(t, w.Body.String(), "Line 3: The base_currency")
+	assert.Contains(t, w.Body.String(), "Line 3: The rate")
+	assert.Contains(t, w.Body.String(), "Line 3: The effective_at")
+
+	mockRepo.AssertNotCalled(t, "CreateFxRates", mock.Anything)
+}
+
+func TestCreateFxRatesConflict(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	mockRepo.On("CreateFxRates", mock.Anything).Return([]model.FxRate(nil), repository.ErrConflict)
+
+	req := httptest.NewRequest("POST", "/fx-rates", strings.NewReader("base_currency,quote_currency,rate,effective_at\nUSD,BRL,5,2024-03-01T00:00:00Z\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).CreateFxRates(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateFxRatesRequiresCSV(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	req := httptest.NewRequest("POST", "/fx-rates", strings.NewReader(`{"base_currency": "USD"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).CreateFxRates(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	mockRepo.AssertNotCalled(t, "CreateFxRates", mock.Anything)
+}
+
+func TestListFxRates(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	rates := []model.FxRate{{FxRateId: 5, BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5}}
+
+	mockRepo.On("ListFxRates", repository.FxRateFilter{BaseCurrency: "USD", QuoteCurrency: "BRL"}, repository.Page{AfterId: 4, Limit: 1}).Return(rates, nil)
+
+	req := httptest.NewRequest("GET", "/fx-rates?base_currency=USD&quote_currency=BRL&page_size=1&page_token=4", nil)
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).ListFxRates(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := FxRateList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, rates, response.FxRates)
+	assert.Equal(t, "5", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListFxRatesValidatesCurrencies(t *testing.T) {
+	mockRepo := new(MockFxRateRepository)
+
+	req := httptest.NewRequest("GET", "/fx-rates?base_currency=usd", nil)
+	w := httptest.NewRecorder()
+
+	NewFxRateHandler(mockRepo).ListFxRates(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidCurrency)
+
+	mockRepo.AssertNotCalled(t, "ListFxRates", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseHolderId reads the holder ID of the path, rendering the error when it
+// is not valid.
+func parseHolderId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	holderId, err := strconv.ParseUint(chi.URLParam(r, "holderId"), 10, 64)
+
+	if (err != nil) || (holderId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The holder_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return holderId, true
+}
+
+// HolderHandler manages the people who own the accounts and hold their
+// cards.
+type HolderHandler struct {
+	repository repository.HolderRepository
+}
+
+func NewHolderHandler(repository repository.HolderRepository) *HolderHandler {
+	return &HolderHandler{
+		repository: repository,
+	}
+}
+
+func (c *HolderHandler) CreateHolder(w http.ResponseWriter, r *http.Request) {
+	payload := &HolderPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	created, err := c.repository.CreateHolder(r.Context(), payload.Holder())
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The holder could not be created."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, created)
+}
+
+func (c *HolderHandler) ListHolders(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	holders, err := c.repository.ListHolders(page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the holders."))
+		return
+	}
+
+	response := &HolderList{Holders: holders}
+
+	if len(holders) > 0 {
+		response.NextPageToken = nextPageToken(page, len(holders), holders[len(holders)-1].HolderId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+func (c *HolderHandler) GetHolder(w http.ResponseWriter, r *http.Request) {
+	holderId, ok := parseHolderId(w, r)
+	if !ok {
+		return
+	}
+
+	holder, err := c.repository.FindHolder(holderId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No holder found for the provided holder ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, holder)
+}
+
+// UpdateHolder replaces the personal data of the holder with the payload.
+func (c *HolderHandler) UpdateHolder(w http.ResponseWriter, r *http.Request) {
+	holderId, ok := parseHolderId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &HolderPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	holder := payload.Holder()
+	holder.HolderId = holderId
+
+	updated, err := c.repository.UpdateHolder(r.Context(), holder)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No holder found for the provided holder ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, updated)
+}
+
+// DeleteHolder erases the holder, which must own no account and hold no
+// card any more.
+func (c *HolderHandler) DeleteHolder(w http.ResponseWriter, r *http.Request) {
+	holderId, ok := parseHolderId(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.repository.DeleteHolder(r.Context(), holderId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No holder found for the provided holder ID, or it still owns accounts or holds cards."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type HolderList struct {
+	Holders       []model.Holder `json:"holders"`
+	NextPageToken string         `json:"next_page_token,omitempty"`
+}
+
+func (s *HolderList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// MaxHolderFieldLength bounds the name of the holders and the parts of their
+// address.
+const MaxHolderFieldLength = 100
+
+// HolderPayload creates a holder, or replaces all of its personal data.
+type HolderPayload struct {
+	Name      string        `json:"name" validate:"required"`
+	BirthDate string        `json:"birth_date" validate:"required"`
+	Email     string        `json:"email" validate:"required"`
+	Phone     string        `json:"phone" validate:"required"`
+	Address   model.Address `json:"address" validate:"required"`
+}
+
+func (s *HolderPayload) Holder() model.Holder {
+	return model.Holder{
+		Name:      s.Name,
+		BirthDate: s.BirthDate,
+		Email:     s.Email,
+		Phone:     s.Phone,
+		Address:   s.Address,
+	}
+}
+
+func (s *HolderPayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *HolderPayload) Validate() error {
+	v := &validator{}
+
+	v.check(s.Name != "" && len(s.Name) <= MaxHolderFieldLength, "name", FieldCodeOutOfRange, "The name must have from 1 to 100 characters.")
+
+	v.check(model.ValidateBirthDate(s.BirthDate, time.Now().UTC()), "birth_date", FieldCodeInvalidDate, "The birth_date must be a date (YYYY-MM-DD) from 1900 up to today.")
+
+	v.check(model.ValidateEmail(s.Email), "email", FieldCodeInvalidEmail, "The email must be a valid address, such as alice@example.com.")
+
+	v.check(model.ValidatePhone(s.Phone), "phone", FieldCodeInvalidPhone, "The phone must be an E.164 number, such as +5511987654321.")
+
+	for _, field := range []struct {
+		name     string
+		value    string
+		required bool
+	}{
+		{"address.street", s.Address.Street, true},
+		{"address.number", s.Address.Number, true},
+		{"address.complement", s.Address.Complement, false},
+		{"address.city", s.Address.City, true},
+		{"address.state", s.Address.State, false},
+	} {
+		if field.required && field.value == "" {
+			v.check(false, field.name, FieldCodeRequired, "The "+field.name+" is required.")
+			continue
+		}
+
+		v.check(len(field.value) <= MaxHolderFieldLength, field.name, FieldCodeOutOfRange, "The "+field.name+" must be at most 100 characters long.")
+	}
+
+	v.check(model.ValidatePostalCode(s.Address.PostalCode), "address.postal_code", FieldCodeInvalidPostalCode, "The address.postal_code must have from 3 to 10 letters, digits, spaces or hyphens.")
+
+	v.check(model.ValidateCountry(s.Address.Country), "address.country", FieldCodeInvalidCountry, "The address.country must be an ISO 3166-1 alpha-2 code, such as BR or US.")
+
+	return v.err()
+}
+
+func (s *HolderPayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockHolderRepository struct {
+	mock.Mock
+}
+
+func (m *MockHolderRepository) CreateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	args := m.Called(holder)
+	return args.Get(0).(*model.Holder), args.Error(1)
+}
+
+func (m *MockHolderRepository) FindHolder(holderId uint64) (*model.Holder, error) {
+	args := m.Called(holderId)
+	return args.Get(0).(*model.Holder), args.Error(1)
+}
+
+func (m *MockHolderRepository) ListHolders(page repository.Page) ([]model.Holder, error) {
+	args := m.Called(page)
+	return args.Get(0).([]model.Holder), args.Error(1)
+}
+
+func (m *MockHolderRepository) UpdateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	args := m.Called(holder)
+	return args.Get(0).(*model.Holder), args.Error(1)
+}
+
+func (m *MockHolderRepository) DeleteHolder(ctx context.Context, holderId uint64) error {
+	args := m.Called(holderId)
+	return args.Error(0)
+}
+
+func holderRouter(mockRepo *MockHolderRepository) http.Handler {
+	holderHandler := NewHolderHandler(mockRepo)
+
+	r := chi.NewRouter()
+	r.Post("/holders", holderHandler.CreateHolder)
+	r.Get("/holders", holderHandler.ListHolders)
+	r.Get("/holders/{holderId}", holderHandler.GetHolder)
+	r.Put("/holders/{holderId}", holderHandler.UpdateHolder)
+	r.Delete("/holders/{holderId}", holderHandler.DeleteHolder)
+
+	return r
+}
+
+const holderPayload = `{"name": "Alice", "birth_date": "1990-02-28", "email": "alice@example.com", "phone": "+5511987654321", "address": {"street": "Av. Paulista", "number": "1000", "city": "Sao Paulo", "state": "SP", "postal_code": "01310-100", "country": "BR"}}`
+
+var alice = model.Holder{
+	Name:      "Alice",
+	BirthDate: "1990-02-28",
+	Email:     "alice@example.com",
+	Phone:     "+5511987654321",
+	Address:   model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", State: "SP", PostalCode: "01310-100", Country: "BR"},
+}
+
+func TestCreateHolder(t *testing.T) {
+	mockRepo := new(MockHolderRepository)
+
+	created := alice
+	created.HolderId = 3
+
+	mockRepo.On("CreateHolder", alice).Return(&created, nil)
+
+	req := httptest.NewRequest("POST", "/holders", strings.NewReader(holderPayload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := model.Holder{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, created, response)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateHolderValidatesPayload(t *testing.T) {
+	for payload, expectedCode := range map[string]string{
+		strings.Replace(holderPayload, `"Alice"`, `"`+strings.Repeat("a", 101)+`"`, 1):                           FieldCodeOutOfRange,
+		strings.Replace(holderPayload, "1990-02-28", "2990-02-28", 1):                                            FieldCodeInvalidDate,
+		strings.Replace(holderPayload, "1990-02-28", "28/02/1990", 1):                                            FieldCodeInvalidDate,
+		strings.Replace(holderPayload, "alice@example.com", "alice", 1):                                          FieldCodeInvalidEmail,
+		strings.Replace(holderPayload, "+5511987654321", "11 98765-4321", 1):                                     FieldCodeInvalidPhone,
+		strings.Replace(holderPayload, `"street": "Av. Paulista", `, "", 1):                                      FieldCodeRequired,
+		strings.Replace(holderPayload, "01310-100", "01310_100", 1):                                              FieldCodeInvalidPostalCode,
+		strings.Replace(holderPayload, `"country": "BR"`, `"country": "Brazil"`, 1):                              FieldCodeInvalidCountry,
+		strings.Replace(holderPayload, `"country": "BR"`, `"country": "BR", "floor": 3`, 1):                      FieldCodeUnknownField,
+		strings.Replace(holderPayload, `"street": "Av. Paulista"`, `"street": 1000`, 1):                          FieldCodeInvalidType,
+		`{"name": "Alice", "birth_date": "1990-02-28", "email": "alice@example.com", "phone": "+5511987654321"}`: FieldCodeRequired,
+	} {
+		mockRepo := new(MockHolderRepository)
+
+		req := httptest.NewRequest("POST", "/holders", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		holderRouter(mockRepo).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`, payload)
+
+		mockRepo.AssertNotCalled(t, "CreateHolder", mock.Anything)
+	}
+}
+
+func TestGetHolder(t *testing.T) {
+	mockRepo := new(MockHolderRepository)
+
+	found := alice
+	found.HolderId = 3
+
+	mockRepo.On("FindHolder", uint64(3)).Return(&found, nil)
+	mockRepo.On("FindHolder", uint64(4)).Return((*model.Holder)(nil), repository.ErrNotFound)
+
+	req := httptest.NewRequest("GET", "/holders/3", nil)
+	w := httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := model.Holder{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, found, response)
+
+	req = httptest.NewRequest("GET", "/holders/4", nil)
+	w = httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req = httptest.NewRequest("GET", "/holders/x", nil)
+	w = httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListHolders(t *testing.T) {
+	mockRepo := new(MockHolderRepository)
+
+	found := alice
+	found.HolderId = 3
+
+	mockRepo.On("ListHolders", repository.Page{Limit: 1}).Return([]model.Holder{found}, nil)
+
+	req := httptest.NewRequest("GET", "/holders?page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := HolderList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []model.Holder{found}, response.Holders)
+	assert.Equal(t, "3", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateHolder(t *testing.T) {
+	mockRepo := new(MockHolderRepository)
+
+	holder := alice
+	holder.HolderId = 3
+
+	mockRepo.On("UpdateHolder", holder).Return(&holder, nil)
+
+	req := httptest.NewRequest("PUT", "/holders/3", strings.NewReader(holderPayload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteHolder(t *testing.T) {
+	mockRepo := new(MockHolderRepository)
+
+	mockRepo.On("DeleteHolder", uint64(3)).Return(nil)
+	mockRepo.On("DeleteHolder", uint64(4)).Return(repository.ErrConflict)
+
+	req := httptest.NewRequest("DELETE", "/holders/3", nil)
+	w := httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/holders/4", nil)
+	w = httptest.NewRecorder()
+
+	holderRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
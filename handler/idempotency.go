@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// IdempotencyKeyHeader lets clients retry a POST without applying it twice.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencySweepInterval bounds how often expired entries are removed.
+const idempotencySweepInterval = time.Minute
+
+// IdempotencyCache remembers the responses of POST requests sent with an
+// Idempotency-Key header, and replays them when the same key is sent again.
+// Keys are scoped to the method, the path and the caller, so callers cannot
+// get the responses of each other by guessing their keys. Entries are kept
+// in memory, so the guarantee only holds for requests served by the same
+// instance. At most maxEntries responses of maxBytes in all are kept, the
+// oldest ones being forgotten first, before their time, once either is
+// reached.
+type IdempotencyCache struct {
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	stored  *list.List
+	bytes   int
+	sweptAt time.Time
+}
+
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	expiresAt   time.Time
+	done        bool
+
+	status int
+	header http.Header
+	body   []byte
+
+	// element is the place of the entry among the stored ones, in the
+	// order they were completed, and size what it counts for maxBytes.
+	element *list.Element
+	size    int
+}
+
+func NewIdempotencyCache(ttl time.Duration, maxEntries int, maxBytes int) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    map[string]*idempotencyEntry{},
+		stored:     list.New(),
+	}
+}
+
+// Middleware replays the stored response of a key. Server errors are not
+// stored, so the client can retry them with the same key.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+
+		// Uploads such as imports can be much larger than a JSON payload,
+		// so their handlers keep track of the key themselves.
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if r.Method != http.MethodPost || key == "" || mediaType != "application/json" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			render.Render(w, r, errorInvalidRequest(errors.New("idempotency key too long"), "The Idempotency-Key header must not be longer than 255 characters."))
+			return
+		}
+
+		// Reading one byte past the limit keeps oversized bodies oversized,
+		// so the decoder still rejects them.
+		body, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadSize+1))
+		if err != nil {
+			render.Render(w, r, errorBinding(errMalformedPayload))
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key = idempotencyScope(r) + key
+		fingerprint := sha256.Sum256(body)
+
+		entry, found := c.reserve(key, fingerprint)
+
+		switch {
+		case found && entry.fingerprint != fingerprint:
+			render.Render(w, r, errorInvalidRequest(errors.New("idempotency key reused"), "The Idempotency-Key was already used with a different request."))
+			return
+		case found && !entry.done:
+			render.Render(w, r, newErrorResponse(errors.New("idempotency key in use"), 409, "Conflict", CodeConflict, "A request with the same Idempotency-Key is still being processed."))
+			return
+		case found:
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		// A panicking handler must not leave the key pending forever.
+		defer c.release(key, entry)
+
+		next.ServeHTTP(recorder, r)
+
+		c.complete(key, entry, recorder)
+
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	})
+}
+
+// idempotencyScope returns the prefix of the keys sent with r: its method,
+// its path and the fingerprint of its caller, as named by its Authorization
+// and X-Actor headers.
+func idempotencyScope(r *http.Request) string {
+	caller := sha256.Sum256([]byte(r.Header.Get("Authorization") + "\n" + r.Header.Get(ActorHeader)))
+
+	return r.Method + " " + r.URL.Path + " " + hex.EncodeToString(caller[:]) + " "
+}
+
+// reserve returns the live entry of the key, or stores a pending one for the
+// caller to complete.
+func (c *IdempotencyCache) reserve(key string, fingerprint [sha256.Size]byte) (*idempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(c.sweptAt) > idempotencySweepInterval {
+		for k, entry := range c.entries {
+			if entry.done && now.After(entry.expiresAt) {
+				c.remove(k, entry)
+			}
+		}
+
+		c.sweptAt = now
+	}
+
+	if entry, ok := c.entries[key]; ok {
+		if !(entry.done && now.After(entry.expiresAt)) {
+			return entry, true
+		}
+
+		c.remove(key, entry)
+	}
+
+	entry := &idempotencyEntry{fingerprint: fingerprint}
+	c.entries[key] = entry
+
+	return entry, false
+}
+
+// release drops the entry when the request did not complete it.
+func (c *IdempotencyCache) release(key string, entry *idempotencyEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !entry.done && c.entries[key] == entry {
+		delete(c.entries, key)
+	}
+}
+
+func (c *IdempotencyCache) complete(key string, entry *idempotencyEntry, recorder *responseRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if recorder.status >= 500 {
+		delete(c.entries, key)
+		return
+	}
+
+	entry.done = true
+	entry.expiresAt = time.Now().Add(c.ttl)
+	entry.status = recorder.status
+	entry.header = recorder.Header().Clone()
+	entry.body = bytes.Clone(recorder.body.Bytes())
+	entry.size = len(key) + len(entry.body)
+
+	for name, values := range entry.header {
+		for _, value := range values {
+			entry.size += len(name) + len(value)
+		}
+	}
+
+	entry.element = c.stored.PushBack(key)
+	c.bytes += entry.size
+
+	for c.stored.Len() > c.maxEntries || c.bytes > c.maxBytes {
+		oldest := c.stored.Front().Value.(string)
+
+		c.remove(oldest, c.entries[oldest])
+	}
+}
+
+// remove drops the entry of the key, along with what it counts for the
+// limits once stored.
+func (c *IdempotencyCache) remove(key string, entry *idempotencyEntry) {
+	delete(c.entries, key)
+
+	if entry.element != nil {
+		c.stored.Remove(entry.element)
+		c.bytes -= entry.size
+	}
+}
@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdempotentHandler(status int, calls *int32) http.Handler {
+	return newIdempotentHandlerOf(NewIdempotencyCache(time.Hour, 100, 1<<20), status, calls)
+}
+
+func newIdempotentHandlerOf(cache *IdempotencyCache, status int, calls *int32) http.Handler {
+	return cache.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(calls, 1)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	}))
+}
+
+func sendIdempotent(handler http.Handler, key string, body string) *httptest.ResponseRecorder {
+	return sendIdempotentAs(handler, "", "/accounts", key, body)
+}
+
+func sendIdempotentAs(handler http.Handler, authorization string, path string, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusCreated, &calls)
+
+	first := sendIdempotent(handler, "key-1", `{"document_number": 1}`)
+	second := sendIdempotent(handler, "key-1", `{"document_number": 1}`)
+
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+
+	sendIdempotent(handler, "key-2", `{"document_number": 1}`)
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyRejectsKeyReusedWithDifferentRequest(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusCreated, &calls)
+
+	sendIdempotent(handler, "key-1", `{"document_number": 1}`)
+	w := sendIdempotent(handler, "key-1", `{"document_number": 2}`)
+
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "The Idempotency-Key was already used with a different request.")
+}
+
+func TestIdempotencyScopesKeysToTheCallerAndPath(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusCreated, &calls)
+
+	first := sendIdempotentAs(handler, "Bearer first", "/accounts", "key-1", `{"document_number": 1}`)
+	second := sendIdempotentAs(handler, "Bearer second", "/accounts", "key-1", `{"document_number": 1}`)
+
+	assert.Equal(t, int32(2), calls)
+	assert.NotEqual(t, first.Body.String(), second.Body.String())
+	assert.Empty(t, second.Header().Get("Idempotent-Replayed"))
+
+	sendIdempotentAs(handler, "Bearer first", "/holders", "key-1", `{"document_number": 1}`)
+	assert.Equal(t, int32(3), calls)
+
+	replayed := sendIdempotentAs(handler, "Bearer first", "/accounts", "key-1", `{"document_number": 1}`)
+	assert.Equal(t, int32(3), calls)
+	assert.Equal(t, first.Body.String(), replayed.Body.String())
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusServiceUnavailable, &calls)
+
+	sendIdempotent(handler, "key-1", `{"document_number": 1}`)
+	sendIdempotent(handler, "key-1", `{"document_number": 1}`)
+
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyIgnoresRequestsWithoutKey(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusCreated, &calls)
+
+	sendIdempotent(handler, "", `{"document_number": 1}`)
+	sendIdempotent(handler, "", `{"document_number": 1}`)
+
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyLeavesUploadsToTheHandler(t *testing.T) {
+	var calls int32
+	handler := newIdempotentHandler(http.StatusAccepted, &calls)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/imports", strings.NewReader("account_id,operation_type_id,amount\n"))
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, int32(2), calls)
+}
+
+func TestIdempotencyForgetsTheOldestResponsesPastTheLimits(t *testing.T) {
+	for _, limit := range []string{"entries", "bytes"} {
+		cache := NewIdempotencyCache(time.Hour, 100, 1<<20)
+
+		var calls int32
+		handler := newIdempotentHandlerOf(cache, http.StatusCreated, &calls)
+
+		sendIdempotent(handler, "key-1", `{}`)
+
+		if limit == "entries" {
+			cache.maxEntries = 2
+		} else {
+			// Every response takes the same room as the first one.
+			cache.maxBytes = 2 * cache.bytes
+		}
+
+		sendIdempotent(handler, "key-2", `{}`)
+		sendIdempotent(handler, "key-3", `{}`)
+
+		assert.Equal(t, 2, cache.stored.Len(), limit)
+
+		// The first key was forgotten, the last two are still replayed.
+		sendIdempotent(handler, "key-3", `{}`)
+		sendIdempotent(handler, "key-2", `{}`)
+		assert.Equal(t, int32(3), calls, limit)
+
+		sendIdempotent(handler, "key-1", `{}`)
+		assert.Equal(t, int32(4), calls, limit)
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// MaxImportSize is the largest file accepted by POST /imports.
+const MaxImportSize = 100 << 20
+
+// importFormats maps the accepted content types to the format of the file.
+var importFormats = map[string]string{
+	"text/csv":             model.IMPORT_FORMAT_CSV,
+	"application/x-ndjson": model.IMPORT_FORMAT_JSONL,
+	"application/jsonl":    model.IMPORT_FORMAT_JSONL,
+}
+
+// ImportSubmitter stores an uploaded file and queues its import. created is
+// false when the idempotency key was already used, and the existing import
+// is returned instead.
+type ImportSubmitter interface {
+	Submit(ctx context.Context, format string, idempotencyKey string, body io.Reader) (imp *model.Import, created bool, err error)
+}
+
+type ImportHandler struct {
+	repository repository.ImportRepository
+	submitter  ImportSubmitter
+}
+
+func NewImportHandler(repository repository.ImportRepository, submitter ImportSubmitter) *ImportHandler {
+	return &ImportHandler{
+		repository: repository,
+		submitter:  submitter,
+	}
+}
+
+func (c *ImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := importFormats[mediaType]
+
+	if err != nil || !ok {
+		log.Printf("Invalid request error: unsupported import content type %q", r.Header.Get("Content-Type"))
+
+		render.Render(w, r, newErrorResponse(errUnsupportedMediaType, 415, "Unsupported media type", CodeUnsupportedMediaType, "The file must be sent as text/csv or application/x-ndjson."))
+		return
+	}
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+
+	if len(key) > maxIdempotencyKeyLength {
+		render.Render(w, r, errorInvalidRequest(errors.New("idempotency key too long"), "The Idempotency-Key header must not be longer than 255 characters."))
+		return
+	}
+
+	imp, created, err := c.submitter.Submit(r.Context(), format, key, http.MaxBytesReader(w, r.Body, MaxImportSize))
+
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		log.Printf("Invalid request error: %s", err)
+
+		render.Render(w, r, newErrorResponse(err, 413, "Payload too large", CodePayloadTooLarge, fmt.Sprintf("The file must not be larger than %d bytes.", MaxImportSize)))
+		return
+	}
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when storing the file."))
+		return
+	}
+
+	w.Header().Set("Location", "/imports/"+strconv.FormatUint(imp.ImportId, 10))
+
+	if created {
+		render.Status(r, http.StatusAccepted)
+	} else {
+		w.Header().Set("Idempotent-Replayed", "true")
+		render.Status(r, http.StatusOK)
+	}
+
+	render.Render(w, r, imp)
+}
+
+func (c *ImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	importId, ok := parseImportId(w, r)
+
+	if !ok {
+		return
+	}
+
+	imp, err := c.repository.FindImport(importId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No import found for the provided import ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, imp)
+}
+
+func (c *ImportHandler) ListImportRejections(w http.ResponseWriter, r *http.Request) {
+	importId, ok := parseImportId(w, r)
+
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	if _, err := c.repository.FindImport(importId); err != nil {
+		render.Render(w, r, errorRepository(err, "No import found for the provided import ID."))
+		return
+	}
+
+	rejections, err := c.repository.ListImportRejections(importId, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the rejected rows."))
+		return
+	}
+
+	response := &ImportRejectionList{Rejections: rejections}
+
+	if len(rejections) > 0 {
+		response.NextPageToken = nextPageToken(page, len(rejections), rejections[len(rejections)-1].ImportRejectionId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+func parseImportId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	importId, err := strconv.ParseUint(chi.URLParam(r, "importId"), 10, 64)
+
+	if (err != nil) || (importId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The import_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return importId, true
+}
+
+type ImportRejectionList struct {
+	Rejections    []model.ImportRejection `json:"rejections"`
+	NextPageToken string                  `json:"next_page_token,omitempty"`
+}
+
+func (i *ImportRejectionList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockImportRepository struct {
+	mock.Mock
+}
+
+func (m *MockImportRepository) CreateImport(ctx context.Context, imp model.Import) (*model.Import, error) {
+	args := m.Called(imp)
+	return args.Get(0).(*model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) FindImport(importId uint64) (*model.Import, error) {
+	args := m.Called(importId)
+	return args.Get(0).(*model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) FindImportByIdempotencyKey(idempotencyKey string) (*model.Import, error) {
+	args := m.Called(idempotencyKey)
+	return args.Get(0).(*model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) ListUnfinishedImports() ([]model.Import, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) SaveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	args := m.Called(importId, batch)
+	return args.Get(0).(*model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) FinishImport(importId uint64, status string, message string) (*model.Import, error) {
+	args := m.Called(importId, status, message)
+	return args.Get(0).(*model.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) ListImportRejections(importId uint64, page repository.Page) ([]model.ImportRejection, error) {
+	args := m.Called(importId, page)
+	return args.Get(0).([]model.ImportRejection), args.Error(1)
+}
+
+type MockImportSubmitter struct {
+	mock.Mock
+}
+
+func (m *MockImportSubmitter) Submit(ctx context.Context, format string, idempotencyKey string, body io.Reader) (*model.Import, bool, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	args := m.Called(format, idempotencyKey, string(content))
+	return args.Get(0).(*model.Import), args.Bool(1), args.Error(2)
+}
+
+func withImportId(req *http.Request, importId string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("importId", importId)
+
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestCreateImport(t *testing.T) {
+	pending := &model.Import{ImportId: 7, Format: model.IMPORT_FORMAT_JSONL, Status: model.IMPORT_PENDING}
+
+	scenarios := []struct {
+		name               string
+		contentType        string
+		created            bool
+		expectedStatusCode int
+		expectedReplayed   string
+	}{
+		{"new import", "application/x-ndjson", true, http.StatusAccepted, ""},
+		{"jsonl content type", "application/jsonl; charset=utf-8", true, http.StatusAccepted, ""},
+		{"replayed import", "application/x-ndjson", false, http.StatusOK, "true"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			submitter := new(MockImportSubmitter)
+			submitter.On("Submit", model.IMPORT_FORMAT_JSONL, "key-1", "{}\n").Return(pending, scenario.created, nil)
+
+			req := httptest.NewRequest("POST", "/imports", strings.NewReader("{}\n"))
+			req.Header.Set("Content-Type", scenario.contentType)
+			req.Header.Set(IdempotencyKeyHeader, "key-1")
+
+			w := httptest.NewRecorder()
+			NewImportHandler(new(MockImportRepository), submitter).CreateImport(w, req)
+
+			assert.Equal(t, scenario.expectedStatusCode, w.Code)
+			assert.Equal(t, "/imports/7", w.Header().Get("Location"))
+			assert.Equal(t, scenario.expectedReplayed, w.Header().Get("Idempotent-Replayed"))
+			assert.JSONEq(t, `{"import_id":7,"format":"jsonl","status":"pending","processed_lines":0,"imported_rows":0,"rejected_rows":0}`, w.Body.String())
+		})
+	}
+}
+
+func TestCreateImportRejectsUnsupportedContentType(t *testing.T) {
+	submitter := new(MockImportSubmitter)
+
+	req := httptest.NewRequest("POST", "/imports", strings.NewReader(`{"account_id": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	NewImportHandler(new(MockImportRepository), submitter).CreateImport(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"unsupported_media_type"`)
+	submitter.AssertNotCalled(t, "Submit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListImportRejections(t *testing.T) {
+	mockRepo := new(MockImportRepository)
+	mockRepo.On("FindImport", uint64(7)).Return(&model.Import{ImportId: 7}, nil)
+	mockRepo.On("ListImportRejections", uint64(7), repository.Page{Limit: 1}).Return([]model.ImportRejection{
+		{ImportRejectionId: 12, Line: 3, Field: "account_id", Code: "unknown_account", Message: "The account_id does not match any account."},
+	}, nil)
+
+	req := withImportId(httptest.NewRequest("GET", "/imports/7/rejections?page_size=1", nil), "7")
+
+	w := httptest.NewRecorder()
+	NewImportHandler(mockRepo, new(MockImportSubmitter)).ListImportRejections(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"rejections":[{"line":3,"field":"account_id","code":"unknown_account","message":"The account_id does not match any account."}],"next_page_token":"12"}`, w.Body.String())
+}
+
+func TestListImportRejectionsOfUnknownImport(t *testing.T) {
+	mockRepo := new(MockImportRepository)
+	mockRepo.On("FindImport", uint64(9)).Return((*model.Import)(nil), repository.ErrNotFound)
+
+	req := withImportId(httptest.NewRequest("GET", "/imports/9/rejections", nil), "9")
+
+	w := httptest.NewRecorder()
+	NewImportHandler(mockRepo, new(MockImportSubmitter)).ListImportRejections(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertNotCalled(t, "ListImportRejections", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/go-chi/render"
+)
+
+const FieldCodeSchemaViolation = "schema_violation"
+
+// NewOpenAPIValidator returns a middleware that rejects requests that do not
+// match the OpenAPI document and logs the responses that do not match it.
+// Requests to routes missing from the document are let through untouched.
+func NewOpenAPIValidator(doc *openapi3.T) (func(http.Handler) http.Handler, error) {
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, pathParams, err := router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Unsupported content types are left for the handlers, which
+			// answer them with a 415 instead of a validation error.
+			if r.ContentLength != 0 {
+				mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+				if err != nil || mediaType != "application/json" {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, MaxPayloadSize)
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    &openapi3filter.Options{MultiError: true},
+			}
+
+			err = openapi3filter.ValidateRequest(r.Context(), input)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+
+				if errors.As(err, &maxBytesErr) {
+					render.Render(w, r, errorBinding(errPayloadTooLarge))
+					return
+				}
+
+				var validationErrors ValidationErrors
+				collectOpenAPIErrors(err, "", &validationErrors)
+
+				render.Render(w, r, errorValidation(validationErrors))
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.passthrough {
+				return
+			}
+
+			validateResponse(input, route, recorder)
+
+			w.WriteHeader(recorder.status)
+			w.Write(recorder.body.Bytes())
+		})
+	}, nil
+}
+
+func validateResponse(input *openapi3filter.RequestValidationInput, route *routers.Route, recorder *responseRecorder) {
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 recorder.status,
+		Header:                 recorder.Header(),
+		Options:                &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true},
+	}
+
+	err := openapi3filter.ValidateResponse(input.Request.Context(), responseInput.SetBodyBytes(recorder.body.Bytes()))
+
+	if err != nil {
+		log.Printf("OpenAPI response validation failed for %s %s (%d): %s", route.Method, route.Path, recorder.status, err)
+	}
+}
+
+// collectOpenAPIErrors flattens the errors returned by the validator into
+// field errors, using the parameter name or the JSON pointer of the failing
+// body property as the field.
+func collectOpenAPIErrors(err error, field string, out *ValidationErrors) {
+	var multiErr openapi3.MultiError
+	var requestErr *openapi3filter.RequestError
+	var schemaErr *openapi3.SchemaError
+
+	switch {
+	case errors.As(err, &multiErr):
+		for _, err := range multiErr {
+			collectOpenAPIErrors(err, field, out)
+		}
+	case errors.As(err, &requestErr):
+		if requestErr.Parameter != nil {
+			field = requestErr.Parameter.Name
+		}
+
+		if requestErr.Err == nil {
+			*out = append(*out, FieldError{Field: field, Code: FieldCodeSchemaViolation, Message: requestErr.Reason})
+			return
+		}
+
+		collectOpenAPIErrors(requestErr.Err, field, out)
+	case errors.As(err, &schemaErr):
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			field = strings.Join(pointer, ".")
+		}
+
+		*out = append(*out, FieldError{Field: field, Code: FieldCodeSchemaViolation, Message: schemaErr.Reason})
+	default:
+		*out = append(*out, FieldError{Field: field, Code: FieldCodeSchemaViolation, Message: err.Error()})
+	}
+}
+
+// responseRecorder holds the JSON responses back so they can be validated
+// before being sent to the client. Any other content, such as a streamed
+// statement, goes through as it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+	passthrough bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.wroteHeader = true
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header().Get("Content-Type"))
+
+	if mediaType != "" && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		r.passthrough = true
+		r.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (r *responseRecorder) Write(body []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	if r.passthrough {
+		return r.ResponseWriter.Write(body)
+	}
+
+	return r.body.Write(body)
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/openapi"
+)
+
+func newValidatedRouter(t *testing.T, mockRepo *MockTransactionRepository) http.Handler {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := NewOpenAPIValidator(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(validator)
+	r.Post("/transactions", NewTransactionHandler(mockRepo, nil).CreateTransaction)
+
+	return r
+}
+
+func TestOpenAPIValidatorAcceptsValidRequest(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	expectedTransaction := &model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("100"), EventDate: postedAt, CreatedAt: postedAt}
+	mockRepo.On("CreateTransaction", mock.AnythingOfType("model.Transaction")).Return(expectedTransaction, nil)
+
+	payload := `{"account_id": 1, "operation_type_id": 4, "amount": 100.0}`
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	newValidatedRouter(t, mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"transaction_id":1,"account_id":1,"operation_type_id":4,"amount":100,"event_date":"2024-03-01T12:00:00Z","created_at":"2024-03-01T12:00:00Z"}`, w.Body.String())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOpenAPIValidatorRejectsInvalidRequest(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	payload := `{"account_id": 1, "operation_type_id": 9}`
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	newValidatedRouter(t, mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+	response := &ErrorResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), response)
+	assert.NoError(t, err)
+
+	fields := map[string]string{}
+
+	for _, fieldError := range response.Errors {
+		fields[fieldError.Field] = fieldError.Code
+	}
+
+	assert.Equal(t, map[string]string{
+		"amount":            FieldCodeSchemaViolation,
+		"operation_type_id": FieldCodeSchemaViolation,
+	}, fields)
+
+	mockRepo.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+}
+
+func TestOpenAPIValidatorLeavesUnsupportedContentTypeToHandler(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	req := httptest.NewRequest("POST", "/transactions", strings.NewReader("account_id=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	newValidatedRouter(t, mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestOpenAPIValidatorStreamsNonJSONResponses(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator, err := NewOpenAPIValidator(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(validator)
+	r.Get("/exports/{exportId}/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("transaction_id\n"))
+
+		// The first write already reached the client.
+		assert.Equal(t, "transaction_id\n", w.(*responseRecorder).ResponseWriter.(*httptest.ResponseRecorder).Body.String())
+	})
+
+	req := httptest.NewRequest("GET", "/exports/1/download", nil)
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "transaction_id\n", w.Body.String())
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/render"
+)
+
+type OperationTypeHandler struct {
+	repository repository.OperationTypeRepository
+}
+
+func NewOperationTypeHandler(repository repository.OperationTypeRepository) *OperationTypeHandler {
+	return &OperationTypeHandler{
+		repository: repository,
+	}
+}
+
+func (c *OperationTypeHandler) ListOperationTypes(w http.ResponseWriter, r *http.Request) {
+	operationTypes, err := c.repository.ListOperationTypes()
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the operation types."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, &OperationTypeList{OperationTypes: operationTypes})
+}
+
+type OperationTypeList struct {
+	OperationTypes []model.OperationType `json:"operation_types"`
+}
+
+func (o *OperationTypeList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockOperationTypeRepository struct {
+	mock.Mock
+}
+
+func (m *MockOperationTypeRepository) ListOperationTypes() ([]model.OperationType, error) {
+	args := m.Called()
+	return args.Get(0).([]model.OperationType), args.Error(1)
+}
+
+func TestListOperationTypes(t *testing.T) {
+	mockRepo := new(MockOperationTypeRepository)
+	mockRepo.On("ListOperationTypes").Return([]model.OperationType{{OperationTypeId: 1, Description: "COMPRA A VISTA"}}, nil)
+
+	w := httptest.NewRecorder()
+	NewOperationTypeHandler(mockRepo).ListOperationTypes(w, httptest.NewRequest("GET", "/operation-types", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"operation_types":[{"operation_type_id":1,"description":"COMPRA A VISTA"}]}`, w.Body.String())
+}
+
+func TestListOperationTypesWhenRepositoryFails(t *testing.T) {
+	mockRepo := new(MockOperationTypeRepository)
+	mockRepo.On("ListOperationTypes").Return([]model.OperationType(nil), fmt.Errorf("%w: %w", repository.ErrUnavailable, errors.New("connection refused")))
+
+	w := httptest.NewRecorder()
+	NewOperationTypeHandler(mockRepo).ListOperationTypes(w, httptest.NewRequest("GET", "/operation-types", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// MaxPageSize is the largest page a list endpoint returns.
+const MaxPageSize = 1000
+
+// parsePage reads the page_size and page_token query parameters. Page
+// tokens are the last ID of the previous page and should be treated as
+// opaque by clients.
+func parsePage(r *http.Request, v *validator) repository.Page {
+	page := repository.Page{}
+	query := r.URL.Query()
+
+	if pageSize := query.Get("page_size"); pageSize != "" {
+		limit, err := strconv.Atoi(pageSize)
+
+		if v.check(err == nil && limit >= 0 && limit <= MaxPageSize, "page_size", FieldCodeOutOfRange, "The page_size must be between 0 and 1000.") {
+			page.Limit = limit
+		}
+	}
+
+	if pageToken := query.Get("page_token"); pageToken != "" {
+		afterId, err := strconv.ParseUint(pageToken, 10, 64)
+
+		if v.check(err == nil, "page_token", FieldCodeInvalidPageToken, "The page_token is invalid.") {
+			page.AfterId = afterId
+		}
+	}
+
+	return page
+}
+
+// parseIdFilter reads an optional positive integer query parameter.
+func parseIdFilter(r *http.Request, v *validator, name string) uint64 {
+	param := r.URL.Query().Get(name)
+
+	if param == "" {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(param, 10, 64)
+
+	if !v.check(err == nil && id > 0, name, FieldCodeInvalidPositiveInteger, "The "+name+" must be a valid positive integer.") {
+		return 0
+	}
+
+	return id
+}
+
+// nextPageToken returns an empty token when the page was not filled, as
+// there is nothing left to list.
+func nextPageToken(page repository.Page, count int, lastId uint64) string {
+	if count < page.EffectiveLimit() {
+		return ""
+	}
+
+	return strconv.FormatUint(lastId, 10)
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseRiskRuleId reads the risk rule ID of the path, rendering the error
+// when it is not valid.
+func parseRiskRuleId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	riskRuleId, err := strconv.ParseUint(chi.URLParam(r, "riskRuleId"), 10, 64)
+
+	if (err != nil) || (riskRuleId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The risk_rule_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return riskRuleId, true
+}
+
+// RiskHandler manages the risk rules the transactions are assessed with,
+// and lists the decisions made with them.
+type RiskHandler struct {
+	repository repository.RiskRepository
+}
+
+func NewRiskHandler(repository repository.RiskRepository) *RiskHandler {
+	return &RiskHandler{
+		repository: repository,
+	}
+}
+
+func (c *RiskHandler) CreateRiskRule(w http.ResponseWriter, r *http.Request) {
+	payload := &RiskRulePayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	created, err := c.repository.CreateRiskRule(r.Context(), payload.RiskRule())
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "A risk rule has the same name already."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, created)
+}
+
+func (c *RiskHandler) ListRiskRules(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	rules, err := c.repository.ListRiskRules(page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the risk rules."))
+		return
+	}
+
+	response := &RiskRuleList{RiskRules: rules}
+
+	if len(rules) > 0 {
+		response.NextPageToken = nextPageToken(page, len(rules), rules[len(rules)-1].RiskRuleId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// DeleteRiskRule lifts the rule. The decisions made with it are kept.
+func (c *RiskHandler) DeleteRiskRule(w http.ResponseWriter, r *http.Request) {
+	riskRuleId, ok := parseRiskRuleId(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.repository.DeleteRiskRule(r.Context(), riskRuleId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No risk rule found for the provided risk rule ID."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRiskDecisions lists the decisions, optionally of an account or with an
+// outcome, such as the transactions held for review.
+func (c *RiskHandler) ListRiskDecisions(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+
+	filter := repository.RiskDecisionFilter{
+		AccountId: parseIdFilter(r, v, "account_id"),
+		Outcome:   r.URL.Query().Get("outcome"),
+	}
+
+	if filter.Outcome != "" {
+		v.check(model.ValidateRiskOutcome(filter.Outcome), "outcome", FieldCodeInvalidRiskOutcome, "The outcome must be one of the following valid values: approve, review, decline")
+	}
+
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	decisions, err := c.repository.ListRiskDecisions(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the risk decisions."))
+		return
+	}
+
+	response := &RiskDecisionList{RiskDecisions: decisions}
+
+	if len(decisions) > 0 {
+		response.NextPageToken = nextPageToken(page, len(decisions), decisions[len(decisions)-1].RiskDecisionId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+type RiskRuleList struct {
+	RiskRules     []model.RiskRule `json:"risk_rules"`
+	NextPageToken string           `json:"next_page_token,omitempty"`
+}
+
+func (s *RiskRuleList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+type RiskDecisionList struct {
+	RiskDecisions []model.RiskDecision `json:"risk_decisions"`
+	NextPageToken string               `json:"next_page_token,omitempty"`
+}
+
+func (s *RiskDecisionList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// MaxRiskRuleNameLength bounds the names of the rules, listed in every
+// decision they fire in.
+const MaxRiskRuleNameLength = 64
+
+// RiskRulePayload declines or holds for review the transactions its
+// expression holds for.
+type RiskRulePayload struct {
+	Name       string `json:"name" validate:"required"`
+	Expression string `json:"expression" validate:"required"`
+	Action     string `json:"action" validate:"required"`
+}
+
+func (s *RiskRulePayload) RiskRule() model.RiskRule {
+	return model.RiskRule{
+		Name:       s.Name,
+		Expression: s.Expression,
+		Action:     s.Action,
+	}
+}
+
+func (s *RiskRulePayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *RiskRulePayload) Validate() error {
+	v := &validator{}
+
+	v.check(s.Name != "" && len(s.Name) <= MaxRiskRuleNameLength, "name", FieldCodeOutOfRange, "The name must have from 1 to 64 characters.")
+
+	v.check(model.ValidateRiskAction(s.Action), "action", FieldCodeInvalidRiskAction, "The action must be one of the following valid values: review, decline")
+
+	if _, err := risk.Compile(s.Expression); err != nil {
+		v.check(false, "expression", FieldCodeInvalidExpression, "The expression is not valid: "+err.Error()+".")
+	}
+
+	return v.err()
+}
+
+func (s *RiskRulePayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockRiskRepository struct {
+	mock.Mock
+}
+
+func (m *MockRiskRepository) CreateRiskRule(ctx context.Context, rule model.RiskRule) (*model.RiskRule, error) {
+	args := m.Called(rule)
+	return args.Get(0).(*model.RiskRule), args.Error(1)
+}
+
+func (m *MockRiskRepository) ListRiskRules(page repository.Page) ([]model.RiskRule, error) {
+	args := m.Called(page)
+	return args.Get(0).([]model.RiskRule), args.Error(1)
+}
+
+func (m *MockRiskRepository) DeleteRiskRule(ctx context.Context, riskRuleId uint64) error {
+	args := m.Called(riskRuleId)
+	return args.Error(0)
+}
+
+func (m *MockRiskRepository) DecideRisk(ctx context.Context, decision model.RiskDecision, decide repository.RiskDecider) (*model.RiskDecision, error) {
+	args := m.Called(decision)
+	return args.Get(0).(*model.RiskDecision), args.Error(1)
+}
+
+func (m *MockRiskRepository) ListRiskDecisions(filter repository.RiskDecisionFilter, page repository.Page) ([]model.RiskDecision, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.RiskDecision), args.Error(1)
+}
+
+func riskRouter(mockRepo *MockRiskRepository) http.Handler {
+	riskHandler := NewRiskHandler(mockRepo)
+
+	r := chi.NewRouter()
+	r.Post("/risk-rules", riskHandler.CreateRiskRule)
+	r.Get("/risk-rules", riskHandler.ListRiskRules)
+	r.Delete("/risk-rules/{riskRuleId}", riskHandler.DeleteRiskRule)
+	r.Get("/risk-decisions", riskHandler.ListRiskDecisions)
+
+	return r
+}
+
+func TestCreateRiskRule(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	rule := model.RiskRule{Name: "velocity", Expression: "transactions_this_minute > 5", Action: model.RISK_OUTCOME_DECLINE}
+
+	created := rule
+	created.RiskRuleId = 2
+
+	mockRepo.On("CreateRiskRule", rule).Return(&created, nil)
+
+	payload := `{"name": "velocity", "expression": "transactions_this_minute > 5", "action": "decline"}`
+
+	req := httptest.NewRequest("POST", "/risk-rules", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := model.RiskRule{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, created.RiskRuleId, response.RiskRuleId)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRiskRuleValidatesPayload(t *testing.T) {
+	for payload, expectedCode := range map[string]string{
+		`{"name": "velocity", "expression": "amount > 5", "action": "block"}`:                         FieldCodeInvalidRiskAction,
+		`{"name": "velocity", "expression": "amount > ", "action": "review"}`:                         FieldCodeInvalidExpression,
+		`{"name": "velocity", "expression": "amount + 5", "action": "review"}`:                        FieldCodeInvalidExpression,
+		`{"name": "velocity", "expression": "balance > 5", "action": "review"}`:                       FieldCodeInvalidExpression,
+		`{"name": "` + strings.Repeat("a", 65) + `", "expression": "amount > 5", "action": "review"}`: FieldCodeOutOfRange,
+		`{"expression": "amount > 5", "action": "review"}`:                                            FieldCodeRequired,
+	} {
+		mockRepo := new(MockRiskRepository)
+
+		req := httptest.NewRequest("POST", "/risk-rules", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		riskRouter(mockRepo).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`, payload)
+
+		mockRepo.AssertNotCalled(t, "CreateRiskRule", mock.Anything)
+	}
+}
+
+func TestCreateRiskRuleConflict(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	mockRepo.On("CreateRiskRule", mock.Anything).Return((*model.RiskRule)(nil), repository.ErrConflict)
+
+	req := httptest.NewRequest("POST", "/risk-rules", strings.NewReader(`{"name": "velocity", "expression": "amount > 5", "action": "review"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CodeConflict)
+}
+
+func TestListRiskRules(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	mockRepo.On("ListRiskRules", repository.Page{Limit: 1}).Return([]model.RiskRule{{RiskRuleId: 4, Name: "velocity", Expression: "transactions_this_minute > 5", Action: model.RISK_OUTCOME_DECLINE}}, nil)
+
+	req := httptest.NewRequest("GET", "/risk-rules?page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := RiskRuleList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.RiskRules, 1)
+	assert.Equal(t, "4", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteRiskRule(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	mockRepo.On("DeleteRiskRule", uint64(4)).Return(nil)
+	mockRepo.On("DeleteRiskRule", uint64(5)).Return(repository.ErrNotFound)
+
+	req := httptest.NewRequest("DELETE", "/risk-rules/4", nil)
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/risk-rules/5", nil)
+	w = httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListRiskDecisions(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	decisions := []model.RiskDecision{{RiskDecisionId: 3, AccountId: 7, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-900"), Outcome: model.RISK_OUTCOME_REVIEW, Rules: []string{"large"}}}
+
+	mockRepo.On("ListRiskDecisions", repository.RiskDecisionFilter{AccountId: 7, Outcome: model.RISK_OUTCOME_REVIEW}, repository.Page{Limit: 1}).Return(decisions, nil)
+
+	req := httptest.NewRequest("GET", "/risk-decisions?account_id=7&outcome=review&page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := RiskDecisionList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, decisions, response.RiskDecisions)
+	assert.Equal(t, "3", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListRiskDecisionsValidatesOutcome(t *testing.T) {
+	mockRepo := new(MockRiskRepository)
+
+	req := httptest.NewRequest("GET", "/risk-decisions?outcome=maybe", nil)
+	w := httptest.NewRecorder()
+
+	riskRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"`+FieldCodeInvalidRiskOutcome+`"`)
+
+	mockRepo.AssertNotCalled(t, "ListRiskDecisions", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/recurrence"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseScheduleId reads the schedule ID of the path, rendering the error
+// when it is not valid.
+func parseScheduleId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	scheduleId, err := strconv.ParseUint(chi.URLParam(r, "scheduleId"), 10, 64)
+
+	if (err != nil) || (scheduleId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The schedule_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return scheduleId, true
+}
+
+// ScheduleHandler manages the schedules, whose transactions are posted by
+// the scheduler package as they fall due.
+type ScheduleHandler struct {
+	repository repository.ScheduleRepository
+}
+
+func NewScheduleHandler(repository repository.ScheduleRepository) *ScheduleHandler {
+	return &ScheduleHandler{
+		repository: repository,
+	}
+}
+
+func (c *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	payload := &SchedulePayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	schedule, err := c.repository.CreateSchedule(r.Context(), payload.Schedule(time.Now()))
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The provided account does not exist."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, schedule)
+}
+
+func (c *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleId, ok := parseScheduleId(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := c.repository.FindSchedule(scheduleId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No schedule found for the provided schedule ID."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, schedule)
+}
+
+func (c *ScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+
+	filter := repository.ScheduleFilter{AccountId: parseIdFilter(r, v, "account_id")}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	schedules, err := c.repository.ListSchedules(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the schedules."))
+		return
+	}
+
+	response := &ScheduleList{Schedules: schedules}
+
+	if len(schedules) > 0 {
+		response.NextPageToken = nextPageToken(page, len(schedules), schedules[len(schedules)-1].ScheduleId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// UpdateSchedule replaces the schedule, whose next run is computed again
+// from the time of the update: occurrences before it are not posted.
+func (c *ScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleId, ok := parseScheduleId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &SchedulePayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	schedule := payload.Schedule(time.Now())
+	schedule.ScheduleId = scheduleId
+
+	updated, err := c.repository.UpdateSchedule(r.Context(), schedule)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No schedule found for the provided schedule ID, or the provided account does not exist."))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, updated)
+}
+
+// DeleteSchedule stops the schedule. The transactions it posted are kept.
+func (c *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleId, ok := parseScheduleId(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.repository.DeleteSchedule(r.Context(), scheduleId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No schedule found for the provided schedule ID."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ScheduleList struct {
+	Schedules     []model.Schedule `json:"schedules"`
+	NextPageToken string           `json:"next_page_token,omitempty"`
+}
+
+func (s *ScheduleList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// SchedulePayload takes a cron expression or an RRULE as its recurrence,
+// see the recurrence package, and an optional end_date after which nothing
+// is posted.
+type SchedulePayload struct {
+	AccountId       uint64       `json:"account_id" validate:"required"`
+	OperationTypeId uint32       `json:"operation_type_id" validate:"required"`
+	Amount          model.Amount `json:"amount" validate:"required"`
+	Recurrence      string       `json:"recurrence" validate:"required"`
+	StartDate       time.Time    `json:"start_date" validate:"required"`
+	EndDate         *time.Time   `json:"end_date,omitempty"`
+}
+
+// Schedule returns the schedule to store, due next at its first occurrence
+// after now.
+func (s *SchedulePayload) Schedule(now time.Time) model.Schedule {
+	schedule := model.Schedule{
+		AccountId:       s.AccountId,
+		OperationTypeId: s.OperationTypeId,
+		Amount:          s.Amount,
+		Recurrence:      s.Recurrence,
+		StartDate:       s.StartDate.UTC(),
+	}
+
+	if s.EndDate != nil {
+		end := s.EndDate.UTC()
+		schedule.EndDate = &end
+	}
+
+	if rule, err := recurrence.Parse(s.Recurrence, schedule.StartDate); err == nil {
+		schedule.NextRunAt = recurrence.Next(rule, now, schedule.EndDate)
+	}
+
+	return schedule
+}
+
+func (s *SchedulePayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *SchedulePayload) Validate() error {
+	v := &validator{}
+
+	v.check(s.AccountId > 0, "account_id", FieldCodeInvalidPositiveInteger, "The account_id must be a valid positive integer.")
+
+	v.check(model.ValidateScheduleOperationType(s.OperationTypeId), "operation_type_id", FieldCodeInvalidOperationType, "The operation_type_id must be one of the following valid values: 1, 2, 3, 4, 5, 6")
+
+	v.check(model.ValidateOperationTypeAmount(s.OperationTypeId, s.Amount), "amount", FieldCodeInvalidAmountSign, "Purchases, withdraw, interest and late fee operations must have a negative amount. Payment operations must have a positive amount.")
+
+	if _, err := recurrence.Parse(s.Recurrence, s.StartDate); err != nil {
+		v.check(false, "recurrence", FieldCodeInvalidRecurrence, "The recurrence must be a five-field cron expression or an RRULE that occurs at least once: "+err.Error()+".")
+	}
+
+	v.check(s.EndDate == nil || s.EndDate.After(s.StartDate), "end_date", FieldCodeInvalidPeriod, "The end_date must be after the start_date.")
+
+	return v.err()
+}
+
+func (s *SchedulePayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockScheduleRepository struct {
+	mock.Mock
+}
+
+func (m *MockScheduleRepository) CreateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	args := m.Called(schedule)
+	return args.Get(0).(*model.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) FindSchedule(scheduleId uint64) (*model.Schedule, error) {
+	args := m.Called(scheduleId)
+	return args.Get(0).(*model.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) ListSchedules(filter repository.ScheduleFilter, page repository.Page) ([]model.Schedule, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) UpdateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	args := m.Called(schedule)
+	return args.Get(0).(*model.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) DeleteSchedule(ctx context.Context, scheduleId uint64) error {
+	args := m.Called(scheduleId)
+	return args.Error(0)
+}
+
+func (m *MockScheduleRepository) ListDueSchedules(now time.Time, limit int) ([]model.Schedule, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]model.Schedule), args.Error(1)
+}
+
+func (m *MockScheduleRepository) AdvanceSchedule(scheduleId uint64, from time.Time, next *time.Time) error {
+	args := m.Called(scheduleId, from, next)
+	return args.Error(0)
+}
+
+func TestCreateSchedule(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+
+	start := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	end := start.AddDate(1, 0, 0)
+
+	// A daily rule occurs at the time of its start, which is its first
+	// occurrence after now.
+	expected := model.Schedule{
+		AccountId:       1,
+		OperationTypeId: model.PAYMENT,
+		Amount:          model.MustParseAmount("100"),
+		Recurrence:      "FREQ=DAILY",
+		StartDate:       start,
+		EndDate:         &end,
+		NextRunAt:       &start,
+	}
+
+	created := expected
+	created.ScheduleId = 9
+
+	mockRepo.On("CreateSchedule", expected).Return(&created, nil)
+
+	payload := `{"account_id": 1, "operation_type_id": 4, "amount": 100, "recurrence": "FREQ=DAILY", "start_date": "` + start.Format(time.RFC3339) + `", "end_date": "` + end.Format(time.RFC3339) + `"}`
+
+	req := httptest.NewRequest("POST", "/schedules", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	NewScheduleHandler(mockRepo).CreateSchedule(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := model.Schedule{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint64(9), response.ScheduleId)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateScheduleValidatesPayload(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+
+	payload := `{"account_id": 1, "operation_type_id": 5, "amount": 10, "recurrence": "0 9 31 2 *", "start_date": "2024-03-01T00:00:00Z", "end_date": "2024-02-01T00:00:00Z"}`
+
+	req := httptest.NewRequest("POST", "/schedules", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	NewScheduleHandler(mockRepo).CreateSchedule(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidAmountSign)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidRecurrence)
+	assert.Contains(t, w.Body.String(), FieldCodeInvalidPeriod)
+
+	mockRepo.AssertNotCalled(t, "CreateSchedule", mock.Anything)
+}
+
+func TestUpdateScheduleNotFound(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+
+	mockRepo.On("UpdateSchedule", mock.MatchedBy(func(schedule model.Schedule) bool { return schedule.ScheduleId == 7 })).Return((*model.Schedule)(nil), repository.ErrNotFound)
+
+	payload := `{"account_id": 1, "operation_type_id": 6, "amount": -25, "recurrence": "0 0 1 * *", "start_date": "2024-03-01T00:00:00Z"}`
+
+	req := withURLParam(httptest.NewRequest("PUT", "/schedules/7", strings.NewReader(payload)), "scheduleId", "7")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	NewScheduleHandler(mockRepo).UpdateSchedule(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListSchedules(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+
+	schedules := []model.Schedule{{ScheduleId: 3, AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), Recurrence: "0 9 1 * *"}}
+
+	mockRepo.On("ListSchedules", repository.ScheduleFilter{AccountId: 1}, repository.Page{AfterId: 2, Limit: 1}).Return(schedules, nil)
+
+	req := httptest.NewRequest("GET", "/schedules?account_id=1&page_size=1&page_token=2", nil)
+	w := httptest.NewRecorder()
+
+	NewScheduleHandler(mockRepo).ListSchedules(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := ScheduleList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Len(t, response.Schedules, 1)
+	assert.Equal(t, "3", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteSchedule(t *testing.T) {
+	mockRepo := new(MockScheduleRepository)
+
+	mockRepo.On("DeleteSchedule", uint64(7)).Return(nil)
+
+	req := withURLParam(httptest.NewRequest("DELETE", "/schedules/7", nil), "scheduleId", "7")
+	w := httptest.NewRecorder()
+
+	NewScheduleHandler(mockRepo).DeleteSchedule(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// parseSpendRuleId reads the spend rule ID of the path, rendering the error
+// when it is not valid.
+func parseSpendRuleId(w http.ResponseWriter, r *http.Request) (uint64, bool) {
+	spendRuleId, err := strconv.ParseUint(chi.URLParam(r, "spendRuleId"), 10, 64)
+
+	if (err != nil) || (spendRuleId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The spend_rule_id must be a valid positive integer."))
+		return 0, false
+	}
+
+	return spendRuleId, true
+}
+
+// SpendRuleHandler manages the rules limiting where the accounts make
+// purchases and withdraws, and how much they spend in each category.
+type SpendRuleHandler struct {
+	repository repository.SpendRuleRepository
+}
+
+func NewSpendRuleHandler(repository repository.SpendRuleRepository) *SpendRuleHandler {
+	return &SpendRuleHandler{
+		repository: repository,
+	}
+}
+
+func (c *SpendRuleHandler) CreateSpendRule(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	payload := &SpendRulePayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	rule := payload.SpendRule()
+	rule.AccountId = accountId
+
+	created, err := c.repository.CreateSpendRule(r.Context(), rule)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The provided account does not exist, or has the same rule already."))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, created)
+}
+
+func (c *SpendRuleHandler) ListSpendRules(w http.ResponseWriter, r *http.Request) {
+	accountId, ok := parseAccountId(w, r)
+	if !ok {
+		return
+	}
+
+	v := &validator{}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	rules, err := c.repository.ListSpendRules(accountId, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the spend rules."))
+		return
+	}
+
+	response := &SpendRuleList{SpendRules: rules}
+
+	if len(rules) > 0 {
+		response.NextPageToken = nextPageToken(page, len(rules), rules[len(rules)-1].SpendRuleId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+// DeleteSpendRule lifts the rule. The transactions it turned down stay so.
+func (c *SpendRuleHandler) DeleteSpendRule(w http.ResponseWriter, r *http.Request) {
+	spendRuleId, ok := parseSpendRuleId(w, r)
+	if !ok {
+		return
+	}
+
+	err := c.repository.DeleteSpendRule(r.Context(), spendRuleId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "No spend rule found for the provided spend rule ID."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SpendRuleList struct {
+	SpendRules    []model.SpendRule `json:"spend_rules"`
+	NextPageToken string            `json:"next_page_token,omitempty"`
+}
+
+func (s *SpendRuleList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// SpendRulePayload allows or denies an mcc or a category, or caps the
+// spend of a category over a period.
+type SpendRulePayload struct {
+	Type     string       `json:"type" validate:"required"`
+	Mcc      string       `json:"mcc,omitempty"`
+	Category string       `json:"category,omitempty"`
+	Limit    model.Amount `json:"limit,omitzero"`
+	Period   string       `json:"period,omitempty"`
+}
+
+func (s *SpendRulePayload) SpendRule() model.SpendRule {
+	return model.SpendRule{
+		Type:     s.Type,
+		Mcc:      s.Mcc,
+		Category: s.Category,
+		Limit:    s.Limit,
+		Period:   s.Period,
+	}
+}
+
+func (s *SpendRulePayload) Bind(r *http.Request) error {
+	return s.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (s *SpendRulePayload) Validate() error {
+	v := &validator{}
+
+	if !v.check(model.ValidateSpendRuleType(s.Type), "type", FieldCodeInvalidSpendRule, "The type must be one of the following valid values: allow, deny, cap") {
+		return v.err()
+	}
+
+	v.check(s.Mcc == "" || model.ValidateMcc(s.Mcc), "mcc", FieldCodeInvalidMcc, "The mcc must be a merchant category code of four digits.")
+
+	v.check(s.Category == "" || model.ValidateMerchantCategory(s.Category), "category", FieldCodeInvalidCategory, "The category must be one of the merchant categories, such as groceries or travel.")
+
+	if s.Type == model.SPEND_RULE_CAP {
+		v.check(s.Mcc == "", "mcc", FieldCodeInvalidSpendRule, "A cap rule names a category, not an mcc.")
+
+		v.check(s.Category != "", "category", FieldCodeRequired, "The category is required for a cap rule.")
+
+		v.check(s.Limit.Sign() > 0, "limit", FieldCodeOutOfRange, "The limit of a cap rule must be greater than zero.")
+
+		v.check(model.ValidateSpendPeriod(s.Period), "period", FieldCodeInvalidSpendRule, "The period must be one of the following valid values: day, month")
+
+		return v.err()
+	}
+
+	v.check((s.Mcc == "") != (s.Category == ""), "mcc", FieldCodeInvalidSpendRule, "An allow or deny rule names either an mcc or a category.")
+
+	v.check(s.Limit.IsZero(), "limit", FieldCodeInvalidSpendRule, "Only a cap rule takes a limit.")
+
+	v.check(s.Period == "", "period", FieldCodeInvalidSpendRule, "Only a cap rule takes a period.")
+
+	return v.err()
+}
+
+func (s *SpendRulePayload) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type MockSpendRuleRepository struct {
+	mock.Mock
+}
+
+func (m *MockSpendRuleRepository) CreateSpendRule(ctx context.Context, rule model.SpendRule) (*model.SpendRule, error) {
+	args := m.Called(rule)
+	return args.Get(0).(*model.SpendRule), args.Error(1)
+}
+
+func (m *MockSpendRuleRepository) ListSpendRules(accountId uint64, page repository.Page) ([]model.SpendRule, error) {
+	args := m.Called(accountId, page)
+	return args.Get(0).([]model.SpendRule), args.Error(1)
+}
+
+func (m *MockSpendRuleRepository) DeleteSpendRule(ctx context.Context, spendRuleId uint64) error {
+	args := m.Called(spendRuleId)
+	return args.Error(0)
+}
+
+func spendRuleRouter(mockRepo *MockSpendRuleRepository) http.Handler {
+	spendRuleHandler := NewSpendRuleHandler(mockRepo)
+
+	r := chi.NewRouter()
+	r.Post("/accounts/{accountId}/spend-rules", spendRuleHandler.CreateSpendRule)
+	r.Get("/accounts/{accountId}/spend-rules", spendRuleHandler.ListSpendRules)
+	r.Delete("/spend-rules/{spendRuleId}", spendRuleHandler.DeleteSpendRule)
+
+	return r
+}
+
+func TestCreateSpendRule(t *testing.T) {
+	mockRepo := new(MockSpendRuleRepository)
+
+	mockRepo.On("CreateSpendRule", model.SpendRule{AccountId: 1, Type: model.SPEND_RULE_CAP, Category: model.MERCHANT_CATEGORY_TRAVEL, Limit: model.MustParseAmount("500"), Period: model.SPEND_PERIOD_MONTH}).
+		Return(&model.SpendRule{SpendRuleId: 2, AccountId: 1, Type: model.SPEND_RULE_CAP, Category: model.MERCHANT_CATEGORY_TRAVEL, Limit: model.MustParseAmount("500"), Period: model.SPEND_PERIOD_MONTH}, nil)
+
+	payload := `{"type": "cap", "category": "travel", "limit": 500, "period": "month"}`
+
+	req := httptest.NewRequest("POST", "/accounts/1/spend-rules", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	response := model.SpendRule{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, uint64(2), response.SpendRuleId)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSpendRuleValidatesPayload(t *testing.T) {
+	for payload, expectedCode := range map[string]string{
+		`{"type": "block", "mcc": "7995"}`:                                     FieldCodeInvalidSpendRule,
+		`{"type": "deny", "mcc": "79"}`:                                        FieldCodeInvalidMcc,
+		`{"type": "allow", "category": "pets"}`:                                FieldCodeInvalidCategory,
+		`{"type": "allow", "mcc": "5411", "category": "groceries"}`:            FieldCodeInvalidSpendRule,
+		`{"type": "deny"}`:                                                     FieldCodeInvalidSpendRule,
+		`{"type": "deny", "mcc": "7995", "limit": 10}`:                         FieldCodeInvalidSpendRule,
+		`{"type": "cap", "limit": 10, "period": "day"}`:                        FieldCodeRequired,
+		`{"type": "cap", "category": "travel", "period": "day"}`:               FieldCodeOutOfRange,
+		`{"type": "cap", "category": "travel", "limit": 10, "period": "week"}`: FieldCodeInvalidSpendRule,
+	} {
+		mockRepo := new(MockSpendRuleRepository)
+
+		req := httptest.NewRequest("POST", "/accounts/1/spend-rules", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+		assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`, payload)
+
+		mockRepo.AssertNotCalled(t, "CreateSpendRule", mock.Anything)
+	}
+}
+
+func TestCreateSpendRuleConflict(t *testing.T) {
+	mockRepo := new(MockSpendRuleRepository)
+
+	mockRepo.On("CreateSpendRule", mock.Anything).Return((*model.SpendRule)(nil), repository.ErrConflict)
+
+	req := httptest.NewRequest("POST", "/accounts/1/spend-rules", strings.NewReader(`{"type": "deny", "mcc": "7995"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), CodeConflict)
+}
+
+func TestListSpendRules(t *testing.T) {
+	mockRepo := new(MockSpendRuleRepository)
+
+	mockRepo.On("ListSpendRules", uint64(1), repository.Page{Limit: 1}).Return([]model.SpendRule{{SpendRuleId: 4, AccountId: 1, Type: model.SPEND_RULE_DENY, Mcc: "7995"}}, nil)
+
+	req := httptest.NewRequest("GET", "/accounts/1/spend-rules?page_size=1", nil)
+	w := httptest.NewRecorder()
+
+	spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := SpendRuleList{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.SpendRules, 1)
+	assert.Equal(t, "4", response.NextPageToken)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteSpendRule(t *testing.T) {
+	mockRepo := new(MockSpendRuleRepository)
+
+	mockRepo.On("DeleteSpendRule", uint64(4)).Return(nil)
+	mockRepo.On("DeleteSpendRule", uint64(5)).Return(repository.ErrNotFound)
+
+	req := httptest.NewRequest("DELETE", "/spend-rules/4", nil)
+	w := httptest.NewRecorder()
+
+	spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", "/spend-rules/5", nil)
+	w = httptest.NewRecorder()
+
+	spendRuleRouter(mockRepo).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
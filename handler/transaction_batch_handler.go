@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+	"github.com/go-chi/render"
+)
+
+// MaxBatchSize is the largest number of transactions accepted by
+// POST /transactions:batch.
+const MaxBatchSize = 1000
+
+// Modes of a batch. An all-or-nothing batch creates no transaction when any
+// of them is rejected, a best-effort one creates the valid ones.
+const (
+	BatchModeAllOrNothing = "all_or_nothing"
+	BatchModeBestEffort   = "best_effort"
+)
+
+var errBatchAborted = errors.New("another transaction of the batch was rejected")
+
+// TransactionBatchHandler posts the transactions of a batch, assessing each
+// with risk first like TransactionHandler. A nil risk lets every transaction
+// through.
+type TransactionBatchHandler struct {
+	transactions repository.TransactionRepository
+	accounts     repository.AccountRepository
+	risk         risk.Assessor
+}
+
+func NewTransactionBatchHandler(transactions repository.TransactionRepository, accounts repository.AccountRepository, risk risk.Assessor) *TransactionBatchHandler {
+	return &TransactionBatchHandler{
+		transactions: transactions,
+		accounts:     accounts,
+		risk:         risk,
+	}
+}
+
+// CreateTransactions answers with a result per transaction, in the order
+// they were sent. Each result holds the status and the body CreateTransaction
+// would have answered for that transaction alone.
+func (c *TransactionBatchHandler) CreateTransactions(w http.ResponseWriter, r *http.Request) {
+	payload := &TransactionBatchPayload{}
+
+	err := render.Bind(r, payload)
+
+	if err != nil {
+		render.Render(w, r, errorBinding(err))
+		return
+	}
+
+	results := make([]TransactionBatchItem, len(payload.Transactions))
+	transactions := make([]model.Transaction, len(payload.Transactions))
+
+	var accountIds []uint64
+
+	for n, raw := range payload.Transactions {
+		item := &TransactionPayload{}
+
+		err := DecodeStrict(raw, item)
+
+		if err == nil {
+			err = item.Validate()
+		}
+
+		if err != nil {
+			results[n] = failedItem(r, errorBinding(err))
+			continue
+		}
+
+		transactions[n] = item.Transaction()
+		accountIds = append(accountIds, item.AccountId)
+	}
+
+	accounts, err := c.accounts.FindAccounts(accountIds)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when checking the accounts."))
+		return
+	}
+
+	found := map[uint64]model.Account{}
+
+	for _, account := range accounts {
+		found[account.AccountId] = account
+	}
+
+	var valid []int
+	failure := 0
+
+	// An invalid request takes precedence over an unknown reference.
+	fail := func(n int) {
+		if failure == 0 || results[n].Status < failure {
+			failure = results[n].Status
+		}
+	}
+
+	for n := range results {
+		switch {
+		case results[n].Error != nil:
+		case found[transactions[n].AccountId].AccountId == 0:
+			results[n] = failedItem(r, errorRepository(repository.ErrForeignKeyViolation, "The provided account does not exist."))
+		case found[transactions[n].AccountId].Blocked:
+			results[n] = failedItem(r, errorRepository(repository.ErrAccountBlocked, "The provided account is blocked."))
+		default:
+			valid = append(valid, n)
+			continue
+		}
+
+		fail(n)
+	}
+
+	var assessed []int
+
+	for i, n := range valid {
+		// An all-or-nothing batch already rejected creates nothing, so the
+		// rest of it is not assessed, recording no decision.
+		if payload.Mode == BatchModeAllOrNothing && failure != 0 {
+			assessed = append(assessed, valid[i:]...)
+			break
+		}
+
+		if err := risk.Check(r.Context(), c.risk, transactions[n]); err != nil {
+			results[n] = failedItem(r, errorRepository(err, "The transaction could not be assessed against the risk rules."))
+			fail(n)
+			continue
+		}
+
+		assessed = append(assessed, n)
+	}
+
+	valid = assessed
+
+	if payload.Mode == BatchModeAllOrNothing && failure != 0 {
+		for _, n := range valid {
+			results[n] = failedItem(r, newErrorResponse(errBatchAborted, 424, "Failed dependency", CodeBatchAborted, "The transaction was not created because another transaction of the batch was rejected."))
+		}
+
+		render.Status(r, failure)
+		render.Render(w, r, &TransactionBatchResult{Mode: payload.Mode, Results: results})
+		return
+	}
+
+	pending := make([]model.Transaction, len(valid))
+
+	for i, n := range valid {
+		pending[i] = transactions[n]
+	}
+
+	created, err := c.transactions.CreateTransactions(r.Context(), pending)
+
+	if err != nil && payload.Mode == BatchModeBestEffort {
+		// The batch is stored at once, so a single rejected transaction
+		// fails all of them: each is stored on its own to find out which.
+		for _, n := range valid {
+			results[n] = c.createTransaction(r, transactions[n])
+		}
+
+		render.Status(r, http.StatusMultiStatus)
+		render.Render(w, r, &TransactionBatchResult{Mode: payload.Mode, Results: results})
+		return
+	}
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "A transaction of the batch references an account that does not exist, or a card that is not one of its cards."))
+		return
+	}
+
+	for i, n := range valid {
+		results[n] = TransactionBatchItem{Status: http.StatusCreated, Transaction: &created[i]}
+	}
+
+	if payload.Mode == BatchModeAllOrNothing {
+		render.Status(r, http.StatusCreated)
+	} else {
+		render.Status(r, http.StatusMultiStatus)
+	}
+
+	render.Render(w, r, &TransactionBatchResult{Mode: payload.Mode, Results: results})
+}
+
+// createTransaction stores a transaction of a best-effort batch on its own,
+// returning its result.
+func (c *TransactionBatchHandler) createTransaction(r *http.Request, transaction model.Transaction) TransactionBatchItem {
+	created, err := c.transactions.CreateTransaction(r.Context(), transaction)
+
+	if err != nil {
+		return failedItem(r, errorRepository(err, "The transaction references an account that does not exist, or a card that is not one of its cards."))
+	}
+
+	return TransactionBatchItem{Status: http.StatusCreated, Transaction: created}
+}
+
+// failedItem turns an error response into the result of a transaction.
+// Nested responses are not rendered by chi, so the instance is set here.
+func failedItem(r *http.Request, renderer render.Renderer) TransactionBatchItem {
+	problem := renderer.(*ErrorResponse)
+	problem.Instance = r.URL.Path
+
+	return TransactionBatchItem{Status: problem.Status, Error: problem}
+}
+
+type TransactionBatchPayload struct {
+	Mode         string            `json:"mode" validate:"required"`
+	Transactions []json.RawMessage `json:"transactions" validate:"required"`
+}
+
+func (t *TransactionBatchPayload) Bind(r *http.Request) error {
+	return t.Validate()
+}
+
+// Validate only checks the batch itself, each transaction is validated on
+// its own so the others still get a result.
+func (t *TransactionBatchPayload) Validate() error {
+	v := &validator{}
+
+	v.check(t.Mode == BatchModeAllOrNothing || t.Mode == BatchModeBestEffort, "mode", FieldCodeInvalidBatchMode, "The mode must be all_or_nothing or best_effort.")
+
+	v.check(len(t.Transactions) > 0 && len(t.Transactions) <= MaxBatchSize, "transactions", FieldCodeOutOfRange, fmt.Sprintf("The transactions must hold between 1 and %d items.", MaxBatchSize))
+
+	return v.err()
+}
+
+type TransactionBatchItem struct {
+	Status      int                `json:"status"`
+	Transaction *model.Transaction `json:"transaction,omitempty"`
+	Error       *ErrorResponse     `json:"error,omitempty"`
+}
+
+type TransactionBatchResult struct {
+	Mode    string                 `json:"mode"`
+	Results []TransactionBatchItem `json:"results"`
+}
+
+func (t *TransactionBatchResult) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
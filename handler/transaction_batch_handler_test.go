@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+func sendBatch(handler *TransactionBatchHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/transactions:batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	handler.CreateTransactions(w, req)
+
+	return w
+}
+
+func batchStatuses(t *testing.T, w *httptest.ResponseRecorder) ([]int, TransactionBatchResult) {
+	result := TransactionBatchResult{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+
+	statuses := make([]int, len(result.Results))
+
+	for n, item := range result.Results {
+		statuses[n] = item.Status
+	}
+
+	return statuses, result
+}
+
+const mixedBatch = `[
+	{"account_id": 1, "operation_type_id": 4, "amount": 10},
+	{"account_id": 1, "operation_type_id": 1, "amount": 10},
+	{"account_id": 9, "operation_type_id": 4, "amount": 10},
+	"not a transaction"
+]`
+
+func TestCreateTransactionBatchBestEffort(t *testing.T) {
+	transactions := new(MockTransactionRepository)
+	transactions.On("CreateTransactions", []model.Transaction{{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}}).
+		Return([]model.Transaction{{TransactionId: 5, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}}, nil)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 9}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}}, nil)
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), `{"mode": "best_effort", "transactions": `+mixedBatch+`}`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	statuses, result := batchStatuses(t, w)
+	assert.Equal(t, []int{201, 400, 422, 400}, statuses)
+	assert.Equal(t, &model.Transaction{TransactionId: 5, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}, result.Results[0].Transaction)
+	assert.Equal(t, FieldCodeInvalidAmountSign, result.Results[1].Error.Errors[0].Code)
+	assert.Equal(t, CodeInvalidReference, result.Results[2].Error.Code)
+	assert.Equal(t, "/transactions:batch", result.Results[2].Error.Instance)
+	assert.Equal(t, CodeInvalidRequest, result.Results[3].Error.Code)
+}
+
+func TestCreateTransactionBatchBestEffortReportsStorageFailuresPerItem(t *testing.T) {
+	first := model.Transaction{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}
+	second := model.Transaction{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("20")}
+	third := model.Transaction{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("30")}
+
+	transactions := new(MockTransactionRepository)
+	transactions.On("CreateTransactions", []model.Transaction{first, second, third}).
+		Return([]model.Transaction{}, repository.ErrFxRateNotFound)
+	transactions.On("CreateTransaction", first).Return(&model.Transaction{TransactionId: 5, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}, nil)
+	transactions.On("CreateTransaction", second).Return((*model.Transaction)(nil), repository.ErrFxRateNotFound)
+	transactions.On("CreateTransaction", third).Return((*model.Transaction)(nil), repository.ErrUnavailable)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 1, 1}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}}, nil)
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), `{"mode": "best_effort", "transactions": [
+		{"account_id": 1, "operation_type_id": 4, "amount": 10},
+		{"account_id": 1, "operation_type_id": 4, "amount": 20},
+		{"account_id": 1, "operation_type_id": 4, "amount": 30}
+	]}`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	statuses, result := batchStatuses(t, w)
+	assert.Equal(t, []int{201, 422, 503}, statuses)
+	assert.Equal(t, uint64(5), result.Results[0].Transaction.TransactionId)
+	assert.Equal(t, CodeFxRateNotFound, result.Results[1].Error.Code)
+	assert.Equal(t, CodeUnavailable, result.Results[2].Error.Code)
+}
+
+func TestCreateTransactionBatchAllOrNothingRejectsTheWholeBatch(t *testing.T) {
+	transactions := new(MockTransactionRepository)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 9}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}}, nil)
+
+	batch := `{"mode": "all_or_nothing", "transactions": [
+		{"account_id": 1, "operation_type_id": 4, "amount": 10},
+		{"account_id": 9, "operation_type_id": 4, "amount": 10}
+	]}`
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), batch)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	statuses, result := batchStatuses(t, w)
+	assert.Equal(t, []int{424, 422}, statuses)
+	assert.Equal(t, CodeBatchAborted, result.Results[0].Error.Code)
+	transactions.AssertNotCalled(t, "CreateTransactions", mock.Anything)
+
+	w = sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), `{"mode": "all_or_nothing", "transactions": `+mixedBatch+`}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an invalid transaction takes precedence over an unknown account")
+}
+
+func TestCreateTransactionBatchAssessesRisk(t *testing.T) {
+	approved := model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20")}
+	declined := model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-5000")}
+
+	mockRisk := new(MockRiskAssessor)
+	mockRisk.On("Assess", approved).Return(&model.RiskDecision{RiskDecisionId: 1, Outcome: model.RISK_OUTCOME_APPROVE, Rules: []string{}}, nil)
+	mockRisk.On("Assess", declined).Return(&model.RiskDecision{RiskDecisionId: 2, Outcome: model.RISK_OUTCOME_DECLINE, Rules: []string{"large"}}, nil)
+
+	transactions := new(MockTransactionRepository)
+	transactions.On("CreateTransactions", []model.Transaction{approved}).
+		Return([]model.Transaction{{TransactionId: 5, AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20")}}, nil)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 1}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}}, nil)
+
+	batch := `[
+		{"account_id": 1, "operation_type_id": 1, "amount": -5000},
+		{"account_id": 1, "operation_type_id": 1, "amount": -20}
+	]`
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, mockRisk), `{"mode": "best_effort", "transactions": `+batch+`}`)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	statuses, result := batchStatuses(t, w)
+	assert.Equal(t, []int{422, 201}, statuses)
+	assert.Equal(t, CodeRiskDeclined, result.Results[0].Error.Code)
+	assert.Equal(t, "The transaction was declined by the risk rules: large.", result.Results[0].Error.Detail)
+
+	w = sendBatch(NewTransactionBatchHandler(transactions, accounts, mockRisk), `{"mode": "all_or_nothing", "transactions": `+batch+`}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	statuses, result = batchStatuses(t, w)
+	assert.Equal(t, []int{422, 424}, statuses)
+	assert.Equal(t, CodeRiskDeclined, result.Results[0].Error.Code)
+
+	// The rest of an aborted batch is not assessed.
+	mockRisk.AssertNumberOfCalls(t, "Assess", 3)
+	transactions.AssertNumberOfCalls(t, "CreateTransactions", 1)
+}
+
+func TestCreateTransactionBatchAllOrNothing(t *testing.T) {
+	transactions := new(MockTransactionRepository)
+	transactions.On("CreateTransactions", []model.Transaction{
+		{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")},
+		{AccountId: 1, OperationTypeId: 3, Amount: model.MustParseAmount("-5")},
+	}).Return([]model.Transaction{
+		{TransactionId: 1, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10"), EventDate: postedAt, CreatedAt: postedAt},
+		{TransactionId: 2, AccountId: 1, OperationTypeId: 3, Amount: model.MustParseAmount("-5"), EventDate: postedAt, CreatedAt: postedAt},
+	}, nil)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 1}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}}, nil)
+
+	batch := `{"mode": "all_or_nothing", "transactions": [
+		{"account_id": 1, "operation_type_id": 4, "amount": 10},
+		{"account_id": 1, "operation_type_id": 3, "amount": -5}
+	]}`
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), batch)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"mode": "all_or_nothing", "results": [
+		{"status": 201, "transaction": {"transaction_id": 1, "account_id": 1, "operation_type_id": 4, "amount": 10, "event_date": "2024-03-01T12:00:00Z", "created_at": "2024-03-01T12:00:00Z"}},
+		{"status": 201, "transaction": {"transaction_id": 2, "account_id": 1, "operation_type_id": 3, "amount": -5, "event_date": "2024-03-01T12:00:00Z", "created_at": "2024-03-01T12:00:00Z"}}
+	]}`, w.Body.String())
+}
+
+func TestCreateTransactionBatchRejectsBlockedAccounts(t *testing.T) {
+	transactions := new(MockTransactionRepository)
+	transactions.On("CreateTransactions", []model.Transaction{{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}}).
+		Return([]model.Transaction{{TransactionId: 5, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10")}}, nil)
+
+	accounts := new(MockAccountRepository)
+	accounts.On("FindAccounts", []uint64{1, 2}).Return([]model.Account{{AccountId: 1, DocumentNumber: 111}, {AccountId: 2, DocumentNumber: 222, Blocked: true}}, nil)
+
+	batch := `{"mode": "best_effort", "transactions": [
+		{"account_id": 1, "operation_type_id": 4, "amount": 10},
+		{"account_id": 2, "operation_type_id": 4, "amount": 10}
+	]}`
+
+	w := sendBatch(NewTransactionBatchHandler(transactions, accounts, nil), batch)
+
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	statuses, result := batchStatuses(t, w)
+	assert.Equal(t, []int{201, 422}, statuses)
+	assert.Equal(t, CodeAccountBlocked, result.Results[1].Error.Code)
+}
+
+func TestCreateTransactionBatchValidatesTheBatch(t *testing.T) {
+	handler := NewTransactionBatchHandler(new(MockTransactionRepository), new(MockAccountRepository), nil)
+
+	scenarios := []struct {
+		body          string
+		expectedField string
+		expectedCode  string
+	}{
+		{`{"mode": "atomic", "transactions": [{}]}`, "mode", FieldCodeInvalidBatchMode},
+		{`{"mode": "best_effort", "transactions": []}`, "transactions", FieldCodeOutOfRange},
+		{`{"transactions": [{}]}`, "mode", FieldCodeRequired},
+	}
+
+	for _, scenario := range scenarios {
+		w := sendBatch(handler, scenario.body)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"field":"`+scenario.expectedField+`","code":"`+scenario.expectedCode+`"`)
+	}
+}
@@ -2,20 +2,27 @@ package handler
 
 import (
 	"net/http"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/felipedsi/pismo-test/model"
 	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 )
 
+// TransactionHandler posts the transactions, assessing them with risk first.
+// A nil risk lets every transaction through.
 type TransactionHandler struct {
 	repository repository.TransactionRepository
+	risk       risk.Assessor
 }
 
-func NewTransactionHandler(repository repository.TransactionRepository) *TransactionHandler {
+func NewTransactionHandler(repository repository.TransactionRepository, risk risk.Assessor) *TransactionHandler {
 	return &TransactionHandler{
 		repository: repository,
+		risk:       risk,
 	}
 }
 
@@ -25,25 +32,24 @@ func (c *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	err := render.Bind(r, payload)
 
 	if err != nil {
-		render.Render(w, r, errorInvalidRequest(err, "The account_id and operation_type_id must be valid positive integers. The amount must be a valid decimal."))
+		render.Render(w, r, errorBinding(err))
 		return
 	}
 
-	payloadErrors := validatePayload(payload)
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
+	}
 
-	if len(payloadErrors) > 0 {
-		render.Render(w, r, errorInvalidRequest(err, strings.Join(payloadErrors, " ")))
+	if err := risk.Check(ctx, c.risk, payload.Transaction()); err != nil {
+		render.Render(w, r, errorRepository(err, "The transaction could not be assessed against the risk rules."))
 		return
 	}
 
-	transaction, err := c.repository.CreateTransaction(model.Transaction{
-		AccountId:       payload.AccountId,
-		OperationTypeId: payload.OperationTypeId,
-		Amount:          payload.Amount,
-	})
+	transaction, err := c.repository.CreateTransaction(ctx, payload.Transaction())
 
 	if err != nil {
-		render.Render(w, r, errorInvalidRequest(err, "The provided account does not exist."))
+		render.Render(w, r, errorRepository(err, "The provided account does not exist, or the card is not one of its cards."))
 		return
 	}
 
@@ -51,31 +57,164 @@ func (c *TransactionHandler) CreateTransaction(w http.ResponseWriter, r *http.Re
 	render.Render(w, r, transaction)
 }
 
-func validatePayload(payload *TransactionPayload) []string {
-	var errors []string
+// ReverseTransaction cancels the amount of the transaction, which is still
+// listed, marked as reversed.
+func (c *TransactionHandler) ReverseTransaction(w http.ResponseWriter, r *http.Request) {
+	transactionId, err := strconv.ParseUint(chi.URLParam(r, "transactionId"), 10, 64)
 
-	if payload.AccountId <= 0 {
-		errors = append(errors, "The account_id must be a valid positive integer.")
+	if (err != nil) || (transactionId <= 0) {
+		render.Render(w, r, errorInvalidRequest(err, "The transaction_id must be a valid positive integer."))
+		return
 	}
 
-	if !model.ValidateOperationType(payload.OperationTypeId) {
-		errors = append(errors, "The operation_type_id must be one of the following valid values: 1, 2, 3, 4")
+	ctx, ok := ifMatch(w, r)
+	if !ok {
+		return
 	}
 
-	if !model.ValidateOperationTypeAmount(payload.OperationTypeId, payload.Amount) {
-		errors = append(errors, "Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.")
+	transaction, err := c.repository.ReverseTransaction(ctx, transactionId)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "The transaction does not exist or is already reversed."))
+		return
 	}
 
-	return errors
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, transaction)
 }
 
+func (c *TransactionHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	v := &validator{}
+
+	filter := repository.TransactionFilter{AccountId: parseIdFilter(r, v, "account_id")}
+	page := parsePage(r, v)
+
+	if len(v.errors) > 0 {
+		render.Render(w, r, errorValidation(v.errors))
+		return
+	}
+
+	transactions, err := c.repository.ListTransactions(filter, page)
+
+	if err != nil {
+		render.Render(w, r, errorRepository(err, "An error occurred when listing the transactions."))
+		return
+	}
+
+	response := &TransactionList{Transactions: transactions}
+
+	if len(transactions) > 0 {
+		response.NextPageToken = nextPageToken(page, len(transactions), transactions[len(transactions)-1].TransactionId)
+	}
+
+	render.Status(r, http.StatusOK)
+	render.Render(w, r, response)
+}
+
+type TransactionList struct {
+	Transactions  []model.Transaction `json:"transactions"`
+	NextPageToken string              `json:"next_page_token,omitempty"`
+}
+
+func (t *TransactionList) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func validatePayload(payload *TransactionPayload) ValidationErrors {
+	v := &validator{}
+
+	v.check(payload.AccountId > 0, "account_id", FieldCodeInvalidPositiveInteger, "The account_id must be a valid positive integer.")
+
+	v.check(model.ValidateOperationType(payload.OperationTypeId), "operation_type_id", FieldCodeInvalidOperationType, "The operation_type_id must be one of the following valid values: 1, 2, 3, 4")
+
+	v.check(model.ValidateOperationTypeAmount(payload.OperationTypeId, payload.Amount), "amount", FieldCodeInvalidAmountSign, "Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.")
+
+	v.check(payload.EventDate == nil || !payload.EventDate.After(time.Now()), "event_date", FieldCodeFutureDate, "The event_date must not be in the future.")
+
+	if v.check(payload.Currency == "" || model.ValidateCurrency(payload.Currency), "currency", FieldCodeInvalidCurrency, "The currency must be a supported ISO 4217 code, such as BRL or USD.") && payload.Currency != "" {
+		v.check(model.ValidateCurrencyAmount(payload.Currency, payload.Amount), "amount", FieldCodeInvalidDecimal, "The amount must not have more decimals than its currency takes.")
+	}
+
+	merchant := payload.MerchantName != "" || payload.MerchantId != "" || payload.Mcc != "" || payload.MerchantCountry != ""
+
+	v.check(!merchant || model.IsMerchantOperationType(payload.OperationTypeId), "operation_type_id", FieldCodeInvalidMerchant, "Only purchases and withdraws are made at a merchant, payments take no merchant fields.")
+
+	v.check(payload.Mcc == "" || model.ValidateMcc(payload.Mcc), "mcc", FieldCodeInvalidMcc, "The mcc must be a merchant category code of four digits.")
+
+	v.check(payload.MerchantCountry == "" || model.ValidateCountry(payload.MerchantCountry), "merchant_country", FieldCodeInvalidCountry, "The merchant_country must be an ISO 3166-1 alpha-2 code, such as BR or US.")
+
+	v.check(len(payload.MerchantName) <= MaxMerchantNameLength, "merchant_name", FieldCodeOutOfRange, "The merchant_name must be at most 100 characters long.")
+
+	v.check(len(payload.MerchantId) <= MaxMerchantIdLength, "merchant_id", FieldCodeOutOfRange, "The merchant_id must be at most 64 characters long.")
+
+	return v.errors
+}
+
+// MaxMerchantNameLength and MaxMerchantIdLength bound the merchant fields of
+// the transactions, in bytes.
+const (
+	MaxMerchantNameLength = 100
+	MaxMerchantIdLength   = 64
+)
+
+// TransactionPayload takes an optional event_date, for transactions that
+// took place before they are posted. It defaults to the posting time.
+//
+// The amount is in the currency of the account unless another currency is
+// given, in which case it is converted when posted. A card_id, of a card of
+// the account, subjects the transaction to the spend controls of the card.
+//
+// Purchases and withdraws may name their merchant and its mcc, from which
+// their category is derived, and are then subject to the spend rules of the
+// account.
 type TransactionPayload struct {
-	AccountId       uint64  `json:"account_id"`
-	OperationTypeId uint32  `json:"operation_type_id"`
-	Amount          float32 `json:"amount"`
+	AccountId       uint64       `json:"account_id" validate:"required"`
+	CardId          uint64       `json:"card_id,omitempty"`
+	OperationTypeId uint32       `json:"operation_type_id" validate:"required"`
+	Amount          model.Amount `json:"amount" validate:"required"`
+	Currency        string       `json:"currency,omitempty"`
+	EventDate       *time.Time   `json:"event_date,omitempty"`
+	MerchantName    string       `json:"merchant_name,omitempty"`
+	MerchantId      string       `json:"merchant_id,omitempty"`
+	Mcc             string       `json:"mcc,omitempty"`
+	MerchantCountry string       `json:"merchant_country,omitempty"`
+}
+
+// Transaction returns the transaction to post.
+func (t *TransactionPayload) Transaction() model.Transaction {
+	transaction := model.Transaction{
+		AccountId:       t.AccountId,
+		CardId:          t.CardId,
+		OperationTypeId: t.OperationTypeId,
+		Amount:          t.Amount,
+		MerchantName:    t.MerchantName,
+		MerchantId:      t.MerchantId,
+		Mcc:             t.Mcc,
+		MerchantCountry: t.MerchantCountry,
+	}
+
+	if t.Currency != "" {
+		transaction.OriginalAmount = t.Amount
+		transaction.OriginalCurrency = t.Currency
+	}
+
+	if t.EventDate != nil {
+		transaction.EventDate = *t.EventDate
+	}
+
+	return transaction
 }
 
 func (t *TransactionPayload) Bind(r *http.Request) error {
+	return t.Validate()
+}
+
+// Validate returns ValidationErrors when the payload breaks any rule.
+func (t *TransactionPayload) Validate() error {
+	if errors := validatePayload(t); len(errors) > 0 {
+		return errors
+	}
+
 	return nil
 }
 
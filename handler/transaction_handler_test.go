@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -8,30 +9,57 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
 	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
 )
 
 type MockTransactionRepository struct {
 	mock.Mock
 }
 
-func (m *MockTransactionRepository) CreateTransaction(transaction model.Transaction) (*model.Transaction, error) {
+func (m *MockTransactionRepository) CreateTransaction(ctx context.Context, transaction model.Transaction) (*model.Transaction, error) {
 	args := m.Called(transaction)
 	return args.Get(0).(*model.Transaction), args.Error(1)
 }
 
+func (m *MockTransactionRepository) CreateTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error) {
+	args := m.Called(transactions)
+	return args.Get(0).([]model.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ListTransactions(filter repository.TransactionFilter, page repository.Page) ([]model.Transaction, error) {
+	args := m.Called(filter, page)
+	return args.Get(0).([]model.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ListTransactionsByAccounts(accountIds []uint64, page repository.Page) (map[uint64][]model.Transaction, error) {
+	args := m.Called(accountIds, page)
+	return args.Get(0).(map[uint64][]model.Transaction), args.Error(1)
+}
+
+func (m *MockTransactionRepository) ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	args := m.Called(transactionId)
+	return args.Get(0).(*model.Transaction), args.Error(1)
+}
+
+// postedAt stands for the time the repositories stamp transactions with.
+var postedAt = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
 func TestCreateTransaction(t *testing.T) {
 	mockRepo := new(MockTransactionRepository)
 
 	payload := `{"account_id": 123456789, "operation_type_id": 1, "amount": -100.0}`
-	req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(payload))
+	req, _ := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 
-	expectedTransaction := &model.Transaction{AccountId: 123456789, OperationTypeId: 1, Amount: 100.0}
+	expectedTransaction := &model.Transaction{AccountId: 123456789, OperationTypeId: 1, Amount: model.MustParseAmount("100"), EventDate: postedAt, CreatedAt: postedAt}
 	mockRepo.On("CreateTransaction", mock.AnythingOfType("model.Transaction")).Return(expectedTransaction, nil)
 
 	handler := &TransactionHandler{repository: mockRepo}
@@ -41,11 +69,11 @@ func TestCreateTransaction(t *testing.T) {
 		t.Errorf("Expected status code %d but got %d", http.StatusCreated, w.Code)
 	}
 
-	expectedResponse := `{"transaction_id":0,"account_id":123456789,"operation_type_id":1,"amount":100}`
+	expectedResponse := `{"transaction_id":0,"account_id":123456789,"operation_type_id":1,"amount":100,"event_date":"2024-03-01T12:00:00Z","created_at":"2024-03-01T12:00:00Z"}`
 	actualResponse := w.Body.String()
 
-	expectedResponseJson := map[string]string{}
-	actualResponseJson := map[string]string{}
+	expectedResponseJson := map[string]interface{}{}
+	actualResponseJson := map[string]interface{}{}
 
 	json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
 	json.Unmarshal([]byte(actualResponse), &actualResponseJson)
@@ -63,67 +91,97 @@ func TestCreateTransactionFailsWhenInvalidRequest(t *testing.T) {
 	}{
 		{
 			`{"account_id": 123456789, "operation_type_id": 1, "amount": 100.0}`,
-			`{"status":"Invalid request","error":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"invalid_amount_sign","message":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": 2, "amount": 100.0}`,
-			`{"status":"Invalid request","error":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"invalid_amount_sign","message":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": 3, "amount": 100.0}`,
-			`{"status":"Invalid request","error":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"invalid_amount_sign","message":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": 4, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"invalid_amount_sign","message":"Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": "invalid", "operation_type_id": 1, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id and operation_type_id must be valid positive integers. The amount must be a valid decimal."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id must be a valid positive integer.","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"invalid_positive_integer","message":"The account_id must be a valid positive integer."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": -1, "operation_type_id": 1, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id and operation_type_id must be valid positive integers. The amount must be a valid decimal."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id must not be negative.","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"negative_number","message":"The account_id must not be negative."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 0, "operation_type_id": 1, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id must be a valid positive integer.","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"invalid_positive_integer","message":"The account_id must be a valid positive integer."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": null, "operation_type_id": 1, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id must be a valid positive integer."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id is required.","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"required","message":"The account_id is required."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": 0, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"invalid_operation_type","message":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": 5, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"invalid_operation_type","message":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": -1, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id and operation_type_id must be valid positive integers. The amount must be a valid decimal."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id must not be negative.","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"negative_number","message":"The operation_type_id must not be negative."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": null, "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id is required.","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"required","message":"The operation_type_id is required."}]}`,
 			http.StatusBadRequest,
 		},
 		{
 			`{"account_id": 123456789, "operation_type_id": "invalid", "amount": -100.0}`,
-			`{"status":"Invalid request","error":"The account_id and operation_type_id must be valid positive integers. The amount must be a valid decimal."}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id must be a valid positive integer.","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"invalid_positive_integer","message":"The operation_type_id must be a valid positive integer."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 0, "operation_type_id": 5, "amount": -100.0}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id must be a valid positive integer. The operation_type_id must be one of the following valid values: 1, 2, 3, 4","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"invalid_positive_integer","message":"The account_id must be a valid positive integer."},{"field":"operation_type_id","code":"invalid_operation_type","message":"The operation_type_id must be one of the following valid values: 1, 2, 3, 4"}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 123456789, "operation_typeid": 1, "amount": -100.0}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The operation_type_id is required. The operation_typeid field is not allowed.","instance":"/transactions","code":"invalid_request","errors":[{"field":"operation_type_id","code":"required","message":"The operation_type_id is required."},{"field":"operation_typeid","code":"unknown_field","message":"The operation_typeid field is not allowed."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 123456789, "operation_type_id": 1}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The amount is required.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"required","message":"The amount is required."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 1.5, "operation_type_id": 4294967296, "amount": "-100.0"}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The account_id must be a valid positive integer. The operation_type_id is too large. The amount must be a valid decimal.","instance":"/transactions","code":"invalid_request","errors":[{"field":"account_id","code":"invalid_positive_integer","message":"The account_id must be a valid positive integer."},{"field":"operation_type_id","code":"out_of_range","message":"The operation_type_id is too large."},{"field":"amount","code":"invalid_decimal","message":"The amount must be a valid decimal."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 1, "operation_type_id": 1, "amount": -10.00001}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The amount must not have more than 4 decimals.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"invalid_decimal","message":"The amount must not have more than 4 decimals."}]}`,
+			http.StatusBadRequest,
+		},
+		{
+			`{"account_id": 1, "operation_type_id": 1, "amount": -1e9}`,
+			`{"type":"/problems/invalid_request","title":"Invalid request","status":400,"detail":"The amount is too large.","instance":"/transactions","code":"invalid_request","errors":[{"field":"amount","code":"out_of_range","message":"The amount is too large."}]}`,
 			http.StatusBadRequest,
 		},
 	}
@@ -132,7 +190,7 @@ func TestCreateTransactionFailsWhenInvalidRequest(t *testing.T) {
 		mockRepo := new(MockTransactionRepository)
 
 		payload := scenario.payload
-		req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(payload))
+		req, _ := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 
@@ -143,11 +201,15 @@ func TestCreateTransactionFailsWhenInvalidRequest(t *testing.T) {
 			t.Errorf("Expected status code %d but got %d", http.StatusCreated, w.Code)
 		}
 
+		if w.Header().Get("Content-Type") != ProblemContentType {
+			t.Errorf("Expected content type %s but got %s", ProblemContentType, w.Header().Get("Content-Type"))
+		}
+
 		expectedResponse := scenario.expectedResponse
 		actualResponse := w.Body.String()
 
-		expectedResponseJson := map[string]string{}
-		actualResponseJson := map[string]string{}
+		expectedResponseJson := map[string]interface{}{}
+		actualResponseJson := map[string]interface{}{}
 
 		json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
 		json.Unmarshal([]byte(actualResponse), &actualResponseJson)
@@ -159,39 +221,293 @@ func TestCreateTransactionFailsWhenInvalidRequest(t *testing.T) {
 }
 
 func TestCreateTransactionWhenTransactionCreatonFails(t *testing.T) {
+	var scenarios = []struct {
+		repositoryError    error
+		expectedResponse   string
+		expectedStatusCode int
+	}{
+		{
+			repository.ErrForeignKeyViolation,
+			`{"type":"/problems/invalid_reference","title":"Unprocessable entity","status":422,"detail":"The provided account does not exist, or the card is not one of its cards.","instance":"/transactions","code":"invalid_reference"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrAccountBlocked,
+			`{"type":"/problems/account_blocked","title":"Unprocessable entity","status":422,"detail":"The account is blocked and takes no more transactions.","instance":"/transactions","code":"account_blocked"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrFxRateNotFound,
+			`{"type":"/problems/fx_rate_not_found","title":"Unprocessable entity","status":422,"detail":"No exchange rate from the currency of the transaction to the one of its account was effective at its event_date.","instance":"/transactions","code":"fx_rate_not_found"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrCardInactive,
+			`{"type":"/problems/card_inactive","title":"Unprocessable entity","status":422,"detail":"The card is blocked, replaced or expired and takes no more purchases or withdraws.","instance":"/transactions","code":"card_inactive"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrCardLimitExceeded,
+			`{"type":"/problems/card_limit_exceeded","title":"Unprocessable entity","status":422,"detail":"The amount exceeds the transaction or daily limit of the card.","instance":"/transactions","code":"card_limit_exceeded"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrMerchantDenied,
+			`{"type":"/problems/merchant_denied","title":"Unprocessable entity","status":422,"detail":"The account denies purchases and withdraws at the merchant category code, or the category, of the transaction.","instance":"/transactions","code":"merchant_denied"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrMerchantNotAllowed,
+			`{"type":"/problems/merchant_not_allowed","title":"Unprocessable entity","status":422,"detail":"The account only allows purchases and withdraws at some merchant category codes or categories, and the transaction is at none of them.","instance":"/transactions","code":"merchant_not_allowed"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrCategoryCapExceeded,
+			`{"type":"/problems/category_limit_exceeded","title":"Unprocessable entity","status":422,"detail":"The amount exceeds the spend cap of the account for the category of the transaction.","instance":"/transactions","code":"category_limit_exceeded"}`,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			repository.ErrVersionConflict,
+			`{"type":"/problems/precondition_failed","title":"Precondition failed","status":412,"detail":"The account changed since the version in If-Match.","instance":"/transactions","code":"precondition_failed"}`,
+			http.StatusPreconditionFailed,
+		},
+		{
+			repository.ErrTimeout,
+			`{"type":"/problems/timeout","title":"Service unavailable","status":503,"detail":"The request took too long to complete. Please try again later.","instance":"/transactions","code":"timeout"}`,
+			http.StatusServiceUnavailable,
+		},
+		{
+			errors.New("Error!"),
+			`{"type":"/problems/internal_error","title":"Internal server error","status":500,"detail":"An unexpected error occurred.","instance":"/transactions","code":"internal_error"}`,
+			http.StatusInternalServerError,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		mockRepo := new(MockTransactionRepository)
+
+		payload := `{"account_id": 123456789, "operation_type_id": 1, "amount": -100.0}`
+		req, _ := http.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		mockRepo.On("CreateTransaction", mock.AnythingOfType("model.Transaction")).Return(&model.Transaction{}, scenario.repositoryError)
+
+		handler := &TransactionHandler{repository: mockRepo}
+		handler.CreateTransaction(w, req)
+
+		if w.Code != scenario.expectedStatusCode {
+			t.Errorf("Expected status code %d but got %d", scenario.expectedStatusCode, w.Code)
+		}
+
+		expectedResponseJson := map[string]interface{}{}
+		actualResponseJson := map[string]interface{}{}
+
+		json.Unmarshal([]byte(scenario.expectedResponse), &expectedResponseJson)
+		json.Unmarshal(w.Body.Bytes(), &actualResponseJson)
+
+		if !reflect.DeepEqual(expectedResponseJson, actualResponseJson) {
+			t.Errorf("Expected response body %s but got %s", scenario.expectedResponse, w.Body.String())
+		}
+	}
+}
+
+func TestCreateTransactionWithEventDate(t *testing.T) {
+	eventDate := time.Date(2024, 2, 28, 23, 30, 0, 0, time.UTC)
+
 	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("CreateTransaction", model.Transaction{AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10"), EventDate: eventDate}).
+		Return(&model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: 4, Amount: model.MustParseAmount("10"), EventDate: eventDate, CreatedAt: postedAt}, nil)
+
+	for payload, expectedStatusCode := range map[string]int{
+		`{"account_id": 1, "operation_type_id": 4, "amount": 10, "event_date": "2024-02-28T23:30:00Z"}`: http.StatusCreated,
+		`{"account_id": 1, "operation_type_id": 4, "amount": 10, "event_date": "2999-01-01T00:00:00Z"}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
 
-	payload := `{"account_id": 123456789, "operation_type_id": 1, "amount": -100.0}`
-	req, _ := http.NewRequest("POST", "/accounts", strings.NewReader(payload))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
+		w := httptest.NewRecorder()
+		NewTransactionHandler(mockRepo, nil).CreateTransaction(w, req)
+
+		assert.Equal(t, expectedStatusCode, w.Code, payload)
+
+		if expectedStatusCode == http.StatusBadRequest {
+			assert.Contains(t, w.Body.String(), `"code":"`+FieldCodeFutureDate+`"`)
+		}
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTransactionWithCurrency(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("CreateTransaction", model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-1500"), OriginalAmount: model.MustParseAmount("-1500"), OriginalCurrency: "JPY"}).
+		Return(&model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: 1, Currency: "BRL", Amount: model.MustParseAmount("-49.8"), OriginalAmount: model.MustParseAmount("-1500"), OriginalCurrency: "JPY", FxRate: 0.0332, FxRateId: 2, CreatedAt: postedAt}, nil)
+
+	for payload, expectedCode := range map[string]string{
+		`{"account_id": 1, "operation_type_id": 1, "amount": -1500, "currency": "JPY"}`: "",
+		`{"account_id": 1, "operation_type_id": 1, "amount": -15.5, "currency": "JPY"}`: FieldCodeInvalidDecimal,
+		`{"account_id": 1, "operation_type_id": 1, "amount": -1.5, "currency": "usd"}`:  FieldCodeInvalidCurrency,
+	} {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		NewTransactionHandler(mockRepo, nil).CreateTransaction(w, req)
+
+		if expectedCode == "" {
+			assert.Equal(t, http.StatusCreated, w.Code, payload)
+			assert.Contains(t, w.Body.String(), `"original_currency":"JPY","fx_rate":0.0332,"fx_rate_id":2`)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+			assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`)
+		}
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateTransactionWithMerchant(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("CreateTransaction", model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20"), MerchantName: "Cantina", MerchantId: "m-1", Mcc: "5812", MerchantCountry: "BR"}).
+		Return(&model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20"), MerchantName: "Cantina", MerchantId: "m-1", Mcc: "5812", MerchantCountry: "BR", Category: model.MERCHANT_CATEGORY_RESTAURANTS, CreatedAt: postedAt}, nil)
+
+	for payload, expectedCode := range map[string]string{
+		`{"account_id": 1, "operation_type_id": 1, "amount": -20, "merchant_name": "Cantina", "merchant_id": "m-1", "mcc": "5812", "merchant_country": "BR"}`: "",
+		`{"account_id": 1, "operation_type_id": 1, "amount": -20, "mcc": "581"}`:                                                                              FieldCodeInvalidMcc,
+		`{"account_id": 1, "operation_type_id": 1, "amount": -20, "merchant_country": "BRA"}`:                                                                 FieldCodeInvalidCountry,
+		`{"account_id": 1, "operation_type_id": 1, "amount": -20, "merchant_name": "` + strings.Repeat("a", 101) + `"}`:                                       FieldCodeOutOfRange,
+		`{"account_id": 1, "operation_type_id": 4, "amount": 20, "mcc": "5812"}`:                                                                              FieldCodeInvalidMerchant,
+	} {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		NewTransactionHandler(mockRepo, nil).CreateTransaction(w, req)
+
+		if expectedCode == "" {
+			assert.Equal(t, http.StatusCreated, w.Code, payload)
+			assert.Contains(t, w.Body.String(), `"merchant_name":"Cantina","merchant_id":"m-1","mcc":"5812","merchant_country":"BR","category":"restaurants"`)
+		} else {
+			assert.Equal(t, http.StatusBadRequest, w.Code, payload)
+			assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`)
+		}
+	}
+
+	mockRepo.AssertExpectations(t)
+}
+
+type MockRiskAssessor struct {
+	mock.Mock
+}
+
+func (m *MockRiskAssessor) Assess(ctx context.Context, transaction model.Transaction) (*model.RiskDecision, error) {
+	args := m.Called(transaction)
+	return args.Get(0).(*model.RiskDecision), args.Error(1)
+}
+
+func TestCreateTransactionAssessesRisk(t *testing.T) {
+	approved := model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20")}
+	reviewed := model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-900")}
+	declined := model.Transaction{AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-5000")}
+	unknown := model.Transaction{AccountId: 9, OperationTypeId: 1, Amount: model.MustParseAmount("-20")}
+
+	mockRisk := new(MockRiskAssessor)
+	mockRisk.On("Assess", approved).Return(&model.RiskDecision{RiskDecisionId: 1, Outcome: model.RISK_OUTCOME_APPROVE, Rules: []string{}}, nil)
+	mockRisk.On("Assess", reviewed).Return(&model.RiskDecision{RiskDecisionId: 2, Outcome: model.RISK_OUTCOME_REVIEW, Rules: []string{"large"}}, nil)
+	mockRisk.On("Assess", declined).Return(&model.RiskDecision{RiskDecisionId: 3, Outcome: model.RISK_OUTCOME_DECLINE, Rules: []string{"large", "new-account"}}, nil)
+	mockRisk.On("Assess", unknown).Return((*model.RiskDecision)(nil), repository.ErrForeignKeyViolation)
+
+	mockRepo := new(MockTransactionRepository)
+	mockRepo.On("CreateTransaction", approved).Return(&model.Transaction{TransactionId: 1, AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-20"), CreatedAt: postedAt}, nil)
+	mockRepo.On("CreateTransaction", reviewed).Return(&model.Transaction{TransactionId: 2, AccountId: 1, OperationTypeId: 1, Amount: model.MustParseAmount("-900"), CreatedAt: postedAt}, nil)
+
+	for payload, expectedCode := range map[string]string{
+		`{"account_id": 1, "operation_type_id": 1, "amount": -20}`:   "",
+		`{"account_id": 1, "operation_type_id": 1, "amount": -900}`:  "",
+		`{"account_id": 1, "operation_type_id": 1, "amount": -5000}`: CodeRiskDeclined,
+		`{"account_id": 9, "operation_type_id": 1, "amount": -20}`:   CodeInvalidReference,
+	} {
+		req := httptest.NewRequest("POST", "/transactions", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		NewTransactionHandler(mockRepo, mockRisk).CreateTransaction(w, req)
+
+		if expectedCode == "" {
+			assert.Equal(t, http.StatusCreated, w.Code, payload)
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code, payload)
+			assert.Contains(t, w.Body.String(), `"code":"`+expectedCode+`"`)
+		}
+	}
+
+	mockRisk.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNumberOfCalls(t, "CreateTransaction", 2)
+}
+
+func TestReverseTransaction(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	mockRepo.On("ReverseTransaction", uint64(3)).Return(&model.Transaction{TransactionId: 3, AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), Reversed: true, EventDate: postedAt, CreatedAt: postedAt}, nil)
+	mockRepo.On("ReverseTransaction", uint64(4)).Return(&model.Transaction{}, repository.ErrConflict)
+
+	for transactionId, expectedStatusCode := range map[string]int{"3": http.StatusOK, "4": http.StatusConflict, "x": http.StatusBadRequest} {
+		req := httptest.NewRequest("POST", "/transactions/"+transactionId+"/reversal", nil)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("transactionId", transactionId)
 
-	mockRepo.On("CreateTransaction", mock.AnythingOfType("model.Transaction")).Return(&model.Transaction{}, errors.New("Error!"))
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		w := httptest.NewRecorder()
+		NewTransactionHandler(mockRepo, nil).ReverseTransaction(w, req)
+
+		assert.Equal(t, expectedStatusCode, w.Code, "transaction %s", transactionId)
+
+		if expectedStatusCode == http.StatusOK {
+			assert.JSONEq(t, `{"transaction_id":3,"account_id":1,"operation_type_id":4,"amount":10,"reversed":true,"event_date":"2024-03-01T12:00:00Z","created_at":"2024-03-01T12:00:00Z"}`, w.Body.String())
+		}
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	mockRepo := new(MockTransactionRepository)
+
+	transactions := []model.Transaction{{TransactionId: 1, AccountId: 7, OperationTypeId: 4, Amount: model.MustParseAmount("10"), EventDate: postedAt, CreatedAt: postedAt}}
+
+	mockRepo.On("ListTransactions", repository.TransactionFilter{AccountId: 7}, repository.Page{}).Return(transactions, nil)
+
+	req := httptest.NewRequest("GET", "/transactions?account_id=7", nil)
+	w := httptest.NewRecorder()
 
 	handler := &TransactionHandler{repository: mockRepo}
-	handler.CreateTransaction(w, req)
+	handler.ListTransactions(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected status code %d but got %d", http.StatusCreated, w.Code)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status code %d but got %d", http.StatusOK, w.Code)
 	}
 
-	expectedResponse := `{"status":"Invalid request","error":"The provided account does not exist."}`
-	actualResponse := w.Body.String()
+	// The page was not filled, so there is no next page token.
+	expectedResponse := `{"transactions":[{"transaction_id":1,"account_id":7,"operation_type_id":4,"amount":10,"event_date":"2024-03-01T12:00:00Z","created_at":"2024-03-01T12:00:00Z"}]}`
 
-	expectedResponseJson := map[string]string{}
-	actualResponseJson := map[string]string{}
+	expectedResponseJson := map[string]interface{}{}
+	actualResponseJson := map[string]interface{}{}
 
 	json.Unmarshal([]byte(expectedResponse), &expectedResponseJson)
-	json.Unmarshal([]byte(actualResponse), &actualResponseJson)
+	json.Unmarshal(w.Body.Bytes(), &actualResponseJson)
 
 	if !reflect.DeepEqual(expectedResponseJson, actualResponseJson) {
 		t.Errorf("Expected response body %s but got %s", expectedResponse, w.Body.String())
 	}
+
+	mockRepo.AssertExpectations(t)
 }
 
 func TestNewTransactionHandler(t *testing.T) {
 	repository := &MockTransactionRepository{}
-	handler := NewTransactionHandler(repository)
+	handler := NewTransactionHandler(repository, nil)
 
 	if handler.repository != repository {
 		t.Errorf("The repository field for the handler wasn't assigned. Expect %s but got %s", repository, handler.repository)
@@ -0,0 +1,91 @@
+package handler
+
+import "strings"
+
+// Codes sent in the errors field of a validation problem, one per failed
+// rule. Like the response codes, they are part of the API contract.
+const (
+	FieldCodeRequired               = "required"
+	FieldCodeUnknownField           = "unknown_field"
+	FieldCodeInvalidType            = "invalid_type"
+	FieldCodeInvalidPositiveInteger = "invalid_positive_integer"
+	FieldCodeInvalidDecimal         = "invalid_decimal"
+	FieldCodeNegativeNumber         = "negative_number"
+	FieldCodeOutOfRange             = "out_of_range"
+	FieldCodeInvalidOperationType   = "invalid_operation_type"
+	FieldCodeInvalidAmountSign      = "invalid_amount_sign"
+	FieldCodeInvalidPageToken       = "invalid_page_token"
+	FieldCodeMalformedRow           = "malformed_row"
+	FieldCodeUnknownAccount         = "unknown_account"
+	FieldCodeBlockedAccount         = "blocked_account"
+	FieldCodeInvalidBatchMode       = "invalid_batch_mode"
+	FieldCodeInvalidExportFormat    = "invalid_export_format"
+	FieldCodeInvalidDate            = "invalid_date"
+	FieldCodeFutureDate             = "future_date"
+	FieldCodeInvalidPeriod          = "invalid_period"
+	FieldCodeInvalidEntityType      = "invalid_entity_type"
+	FieldCodeInvalidRecurrence      = "invalid_recurrence"
+	FieldCodeInvalidCurrency        = "invalid_currency"
+	FieldCodeInvalidCardType        = "invalid_card_type"
+	FieldCodeInvalidPan             = "invalid_pan"
+	FieldCodeInvalidExpiry          = "invalid_expiry"
+	FieldCodeInvalidMerchant        = "invalid_merchant"
+	FieldCodeInvalidMcc             = "invalid_mcc"
+	FieldCodeInvalidCountry         = "invalid_country"
+	FieldCodeInvalidCategory        = "invalid_category"
+	FieldCodeInvalidSpendRule       = "invalid_spend_rule"
+	FieldCodeInvalidRiskAction      = "invalid_risk_action"
+	FieldCodeInvalidRiskOutcome     = "invalid_risk_outcome"
+	FieldCodeInvalidExpression      = "invalid_expression"
+	FieldCodeInvalidDisputeStatus   = "invalid_dispute_status"
+	FieldCodeInvalidDisputeOutcome  = "invalid_dispute_outcome"
+	FieldCodeInvalidEmail           = "invalid_email"
+	FieldCodeInvalidPhone           = "invalid_phone"
+	FieldCodeInvalidPostalCode      = "invalid_postal_code"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors is returned by the Bind method of the payloads when they
+// break any rule, so render.Bind hands every failed field back at once.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+
+	for i, fieldError := range v {
+		messages[i] = fieldError.Message
+	}
+
+	return strings.Join(messages, " ")
+}
+
+// validator collects the rules a payload breaks. check reports whether the
+// rule held, so the caller can keep the value only when it is valid.
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) check(valid bool, field string, code string, message string) bool {
+	if !valid {
+		v.errors = append(v.errors, FieldError{
+			Field:   field,
+			Code:    code,
+			Message: message,
+		})
+	}
+
+	return valid
+}
+
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+
+	return v.errors
+}
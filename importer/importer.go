@@ -0,0 +1,413 @@
+// Package importer loads files of transactions in the background. Rows are
+// checked with the same rules as POST /transactions and saved in batches,
+// each one committed along with the last line it covers, so an import
+// interrupted by a crash resumes where it stopped without saving any row
+// twice.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// DefaultBatchSize is the number of rows saved per database transaction.
+const DefaultBatchSize = 1000
+
+// pollInterval is how often unfinished imports are looked for, which also
+// resumes the ones left behind by another instance.
+const pollInterval = 30 * time.Second
+
+// rowRejection returns the rejection of a row that could not be saved
+// because of err, or nil when err is not about the row itself, such as a
+// database outage, and running the import again may help.
+func rowRejection(err error) *model.ImportRejection {
+	switch {
+	case errors.Is(err, repository.ErrFxRateNotFound):
+		return &model.ImportRejection{Field: "currency", Code: handler.CodeFxRateNotFound, Message: "No exchange rate from the currency of the row to the currency of the account was effective at the event_date. Load the rates at POST /fx-rates and import the row again."}
+	case errors.Is(err, repository.ErrForeignKeyViolation):
+		return &model.ImportRejection{Field: "card_id", Code: handler.CodeInvalidReference, Message: "The card does not exist or is not one of the cards of the account."}
+	case errors.Is(err, repository.ErrCardInactive):
+		return &model.ImportRejection{Field: "card_id", Code: handler.CodeCardInactive, Message: "The card is blocked, replaced or expired and takes no more purchases or withdraws."}
+	case errors.Is(err, repository.ErrCardLimitExceeded):
+		return &model.ImportRejection{Field: "amount", Code: handler.CodeCardLimitExceeded, Message: "The amount exceeds the transaction or daily limit of the card."}
+	case errors.Is(err, repository.ErrMerchantDenied):
+		return &model.ImportRejection{Field: "mcc", Code: handler.CodeMerchantDenied, Message: "The account denies purchases and withdraws at the merchant category code, or the category, of the transaction."}
+	case errors.Is(err, repository.ErrMerchantNotAllowed):
+		return &model.ImportRejection{Field: "mcc", Code: handler.CodeMerchantNotAllowed, Message: "The account only allows purchases and withdraws at some merchant category codes or categories, and the transaction is at none of them."}
+	case errors.Is(err, repository.ErrCategoryCapExceeded):
+		return &model.ImportRejection{Field: "amount", Code: handler.CodeCategoryCapExceeded, Message: "The amount exceeds the spend cap of the account for the category of the transaction."}
+	case errors.Is(err, repository.ErrAccountBlocked):
+		return &model.ImportRejection{Field: "account_id", Code: handler.FieldCodeBlockedAccount, Message: "The account is blocked and takes no more transactions."}
+	case errors.Is(err, repository.ErrInvalidAmount):
+		return &model.ImportRejection{Field: "amount", Code: handler.FieldCodeInvalidDecimal, Message: "The amount has more decimals than the currency of the account takes."}
+	}
+
+	return nil
+}
+
+type Importer struct {
+	imports   repository.ImportRepository
+	accounts  repository.AccountRepository
+	risk      risk.Assessor
+	dir       string
+	batchSize int
+	wake      chan struct{}
+}
+
+// NewImporter keeps the uploaded files in dir until their import finishes.
+// The directory must survive restarts for imports to be resumed. The rows
+// are assessed with risk, which may be nil to let every one through.
+func NewImporter(imports repository.ImportRepository, accounts repository.AccountRepository, risk risk.Assessor, dir string, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	return &Importer{
+		imports:   imports,
+		accounts:  accounts,
+		risk:      risk,
+		dir:       dir,
+		batchSize: batchSize,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+func (i *Importer) path(imp model.Import) string {
+	return filepath.Join(i.dir, fmt.Sprintf("%d.%s", imp.ImportId, imp.Format))
+}
+
+// Submit stores the file and queues its import. When idempotencyKey was
+// already used, the existing import is returned instead and created is
+// false.
+func (i *Importer) Submit(ctx context.Context, format string, idempotencyKey string, body io.Reader) (imp *model.Import, created bool, err error) {
+	if idempotencyKey != "" {
+		imp, err := i.imports.FindImportByIdempotencyKey(idempotencyKey)
+
+		if err == nil {
+			return imp, false, nil
+		}
+
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	upload, err := os.CreateTemp(i.dir, "upload-*")
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer os.Remove(upload.Name())
+
+	_, err = io.Copy(upload, body)
+
+	if closeErr := upload.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	imp, err = i.imports.CreateImport(ctx, model.Import{Format: format, Status: model.IMPORT_PENDING, IdempotencyKey: idempotencyKey})
+
+	// Another upload with the same key won the race.
+	if errors.Is(err, repository.ErrConflict) && idempotencyKey != "" {
+		imp, err = i.imports.FindImportByIdempotencyKey(idempotencyKey)
+
+		return imp, false, err
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := os.Rename(upload.Name(), i.path(*imp)); err != nil {
+		i.imports.FinishImport(imp.ImportId, model.IMPORT_FAILED, "The uploaded file could not be stored.")
+
+		return nil, false, err
+	}
+
+	select {
+	case i.wake <- struct{}{}:
+	default:
+	}
+
+	return imp, true, nil
+}
+
+// Start runs the unfinished imports one at a time until ctx is done.
+func (i *Importer) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := i.RunPending(ctx); err != nil {
+			log.Printf("Importer#Start: Running imports failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-i.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending runs every unfinished import, oldest first.
+func (i *Importer) RunPending(ctx context.Context) error {
+	imports, err := i.imports.ListUnfinishedImports()
+	if err != nil {
+		return err
+	}
+
+	for _, imp := range imports {
+		if err := i.Run(ctx, imp); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run imports the file of imp, skipping the lines saved by a previous run.
+// Errors reaching the database are returned and leave the import
+// unfinished, to be resumed later, while problems with the file fail it.
+func (i *Importer) Run(ctx context.Context, imp model.Import) error {
+	file, err := os.Open(i.path(imp))
+
+	if errors.Is(err, os.ErrNotExist) {
+		return i.fail(imp, "The uploaded file was lost before it could be imported.")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	reader, err := newRowReader(imp.Format, file)
+	if err != nil {
+		return i.fail(imp, fmt.Sprintf("The file could not be read: %s.", err))
+	}
+
+	var rows []row
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r, err := reader.next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return i.fail(imp, fmt.Sprintf("The file could not be read: %s.", err))
+		}
+
+		if r.line <= imp.ProcessedLines {
+			continue
+		}
+
+		rows = append(rows, r)
+
+		if len(rows) == i.batchSize {
+			saved, err := i.save(ctx, imp, rows)
+
+			if errors.Is(err, repository.ErrConflict) {
+				log.Printf("Importer#Run: Import %d is being run somewhere else", imp.ImportId)
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			imp = *saved
+			rows = rows[:0]
+		}
+	}
+
+	if len(rows) > 0 {
+		saved, err := i.save(ctx, imp, rows)
+
+		if errors.Is(err, repository.ErrConflict) {
+			log.Printf("Importer#Run: Import %d is being run somewhere else", imp.ImportId)
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		imp = *saved
+	}
+
+	if _, err := i.imports.FinishImport(imp.ImportId, model.IMPORT_COMPLETED, ""); err != nil {
+		return err
+	}
+
+	log.Printf("Importer#Run: Import %d completed with %d rows imported and %d rejected", imp.ImportId, imp.ImportedRows, imp.RejectedRows)
+
+	return os.Remove(i.path(imp))
+}
+
+// save rejects the rows of unknown and blocked accounts, the amounts with
+// more decimals than the currency of their account takes and the rows the
+// risk rules decline, then saves the batch. The rows the repository turns
+// down, such as those with no exchange rate or over the limits of their
+// card, are rejected too, so only the errors of the database itself are
+// returned.
+func (i *Importer) save(ctx context.Context, imp model.Import, rows []row) (*model.Import, error) {
+	var accountIds []uint64
+
+	for _, r := range rows {
+		if r.errors == nil {
+			accountIds = append(accountIds, r.transaction.AccountId)
+		}
+	}
+
+	accounts, err := i.accounts.FindAccounts(accountIds)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[uint64]model.Account{}
+
+	for _, account := range accounts {
+		found[account.AccountId] = account
+	}
+
+	for n := range rows {
+		if err := i.check(ctx, &rows[n], found[rows[n].transaction.AccountId]); err != nil {
+			return nil, err
+		}
+	}
+
+	saved, err := i.imports.SaveImportBatch(imp.ImportId, newBatch(rows))
+
+	if rowRejection(err) == nil {
+		return saved, err
+	}
+
+	// A batch is saved at once, so a single row turned down fails all of
+	// them: each is saved on its own to find out which.
+	for _, r := range rows {
+		saved, err = i.imports.SaveImportBatch(imp.ImportId, newBatch([]row{r}))
+
+		if rejection := rowRejection(err); rejection != nil {
+			rejection.Line = r.line
+
+			log.Printf("Importer#save: Import %d rejected line %d: %s", imp.ImportId, r.line, err)
+
+			saved, err = i.imports.SaveImportBatch(imp.ImportId, repository.ImportBatch{
+				Rejections:     []model.ImportRejection{*rejection},
+				RejectedRows:   1,
+				ProcessedLines: r.line,
+			})
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return saved, nil
+}
+
+// check sets the errors of r when its account, zero when not found, takes
+// no transaction of it, or when the risk rules decline it.
+func (i *Importer) check(ctx context.Context, r *row, account model.Account) error {
+	switch {
+	case r.errors != nil:
+	case account.AccountId == 0:
+		r.errors = handler.ValidationErrors{{
+			Field:   "account_id",
+			Code:    handler.FieldCodeUnknownAccount,
+			Message: "The account_id does not match any account.",
+		}}
+	case account.Blocked:
+		r.errors = handler.ValidationErrors{{
+			Field:   "account_id",
+			Code:    handler.FieldCodeBlockedAccount,
+			Message: "The account is blocked and takes no more transactions.",
+		}}
+	case r.transaction.OriginalCurrency == "" && !model.ValidateCurrencyAmount(account.Currency, r.transaction.Amount):
+		r.errors = handler.ValidationErrors{{
+			Field:   "amount",
+			Code:    handler.FieldCodeInvalidDecimal,
+			Message: "The amount has more decimals than the currency of the account takes.",
+		}}
+	default:
+		var declined *risk.DeclinedError
+
+		err := risk.Check(ctx, i.risk, r.transaction)
+
+		if errors.As(err, &declined) {
+			r.errors = handler.ValidationErrors{{
+				Code:    handler.CodeRiskDeclined,
+				Message: fmt.Sprintf("The transaction was declined by the risk rules: %s.", strings.Join(declined.Decision.Rules, ", ")),
+			}}
+
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// newBatch returns the batch of rows, the ones with errors rejected.
+func newBatch(rows []row) repository.ImportBatch {
+	batch := repository.ImportBatch{ProcessedLines: rows[len(rows)-1].line}
+
+	for _, r := range rows {
+
+		if r.errors == nil {
+			batch.Transactions = append(batch.Transactions, r.transaction)
+			continue
+		}
+
+		batch.RejectedRows++
+
+		for _, fieldError := range r.errors {
+			batch.Rejections = append(batch.Rejections, model.ImportRejection{
+				Line:    r.line,
+				Field:   fieldError.Field,
+				Code:    fieldError.Code,
+				Message: fieldError.Message,
+			})
+		}
+	}
+
+	return batch
+}
+
+func (i *Importer) fail(imp model.Import, message string) error {
+	log.Printf("Importer#Run: Import %d failed: %s", imp.ImportId, message)
+
+	if _, err := i.imports.FinishImport(imp.ImportId, model.IMPORT_FAILED, message); err != nil {
+		return err
+	}
+
+	os.Remove(i.path(imp))
+
+	return nil
+}
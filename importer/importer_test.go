@@ -0,0 +1,276 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// failingImportRepository fails the batches after the first failAfter ones,
+// like a crash in the middle of an import.
+type failingImportRepository struct {
+	repository.ImportRepository
+	failAfter int
+	saved     int
+}
+
+func (f *failingImportRepository) SaveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	if f.saved == f.failAfter {
+		return nil, repository.ErrUnavailable
+	}
+
+	f.saved++
+
+	return f.ImportRepository.SaveImportBatch(importId, batch)
+}
+
+type fixture struct {
+	store        *memory.Store
+	imports      repository.ImportRepository
+	transactions repository.TransactionRepository
+	importer     *Importer
+}
+
+func newFixture(t *testing.T, batchSize int) *fixture {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepositoryMemory(store)
+
+	for _, documentNumber := range []uint64{111, 222} {
+		_, err := accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+		require.NoError(t, err)
+	}
+
+	imports := memory.NewImportRepositoryMemory(store)
+
+	return &fixture{
+		store:        store,
+		imports:      imports,
+		transactions: memory.NewTransactionRepositoryMemory(store),
+		importer:     NewImporter(imports, accounts, nil, t.TempDir(), batchSize),
+	}
+}
+
+func (f *fixture) rejections(t *testing.T, importId uint64) []model.ImportRejection {
+	rejections, err := f.imports.ListImportRejections(importId, repository.Page{})
+	require.NoError(t, err)
+
+	for i := range rejections {
+		rejections[i].ImportRejectionId = 0
+	}
+
+	return rejections
+}
+
+func (f *fixture) transactionCount(t *testing.T) int {
+	transactions, err := f.transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{Limit: 1000})
+	require.NoError(t, err)
+
+	return len(transactions)
+}
+
+func TestRunImportsCSV(t *testing.T) {
+	f := newFixture(t, 2)
+
+	file := strings.Join([]string{
+		"account_id,operation_type_id,amount",
+		"1,1,-10.5",
+		"2,4,20",
+		"1,1,10",
+		"9,4,5",
+		"abc,4,",
+		"1,4",
+		"2,3,-1",
+	}, "\n")
+
+	imp, created, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "", strings.NewReader(file))
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	finished, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.Import{
+		ImportId:       imp.ImportId,
+		Format:         model.IMPORT_FORMAT_CSV,
+		Status:         model.IMPORT_COMPLETED,
+		ProcessedLines: 8,
+		ImportedRows:   3,
+		RejectedRows:   4,
+	}, *finished)
+
+	assert.Equal(t, []model.ImportRejection{
+		{Line: 4, Field: "amount", Code: "invalid_amount_sign", Message: "Purchases and withdraw operations must have a negative amount. Payment operations must have a positive amount."},
+		{Line: 5, Field: "account_id", Code: "unknown_account", Message: "The account_id does not match any account."},
+		{Line: 6, Field: "account_id", Code: "invalid_positive_integer", Message: "The account_id must be a valid positive integer."},
+		{Line: 6, Field: "amount", Code: "required", Message: "The amount is required."},
+		{Line: 7, Field: "", Code: "malformed_row", Message: "The line has 2 fields but the header has 3."},
+	}, f.rejections(t, imp.ImportId))
+
+	assert.Equal(t, 3, f.transactionCount(t))
+
+	_, err = os.Stat(f.importer.path(*finished))
+	assert.True(t, os.IsNotExist(err), "the file of a completed import is removed")
+}
+
+func TestRunImportsJSONL(t *testing.T) {
+	f := newFixture(t, DefaultBatchSize)
+
+	file := strings.Join([]string{
+		`{"account_id": 1, "operation_type_id": 4, "amount": 10}`,
+		``,
+		`{"account_id": 1, "operation_type_id": 4, "amount": 10, "note": "x"}`,
+		`not json`,
+		`{"account_id": 2, "operation_type_id": 2, "amount": -5}`,
+	}, "\n")
+
+	imp, _, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_JSONL, "", strings.NewReader(file))
+	require.NoError(t, err)
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	finished, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.IMPORT_COMPLETED, finished.Status)
+	assert.Equal(t, uint64(2), finished.ImportedRows)
+	assert.Equal(t, uint64(2), finished.RejectedRows)
+
+	assert.Equal(t, []model.ImportRejection{
+		{Line: 3, Field: "note", Code: "unknown_field", Message: "The note field is not allowed."},
+		{Line: 4, Field: "", Code: "malformed_row", Message: "The line is not a valid JSON object."},
+	}, f.rejections(t, imp.ImportId))
+}
+
+func TestRunRejectsRowsTurnedDownByTheRepository(t *testing.T) {
+	f := newFixture(t, DefaultBatchSize)
+
+	file := strings.Join([]string{
+		`{"account_id": 1, "operation_type_id": 4, "amount": 10}`,
+		`{"account_id": 1, "operation_type_id": 1, "amount": -10, "currency": "USD"}`,
+		`{"account_id": 2, "operation_type_id": 4, "amount": 5}`,
+		`{"account_id": 2, "operation_type_id": 1, "amount": -5, "card_id": 9}`,
+		`{"account_id": 1, "operation_type_id": 4, "amount": 1}`,
+	}, "\n")
+
+	imp, _, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_JSONL, "", strings.NewReader(file))
+	require.NoError(t, err)
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	finished, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.IMPORT_COMPLETED, finished.Status)
+	assert.Equal(t, uint64(5), finished.ProcessedLines)
+	assert.Equal(t, uint64(3), finished.ImportedRows)
+	assert.Equal(t, uint64(2), finished.RejectedRows)
+	assert.Equal(t, 3, f.transactionCount(t))
+
+	rejections := f.rejections(t, imp.ImportId)
+	require.Len(t, rejections, 2)
+	assert.Equal(t, uint64(2), rejections[0].Line)
+	assert.Equal(t, "fx_rate_not_found", rejections[0].Code)
+	assert.Equal(t, uint64(4), rejections[1].Line)
+	assert.Equal(t, "invalid_reference", rejections[1].Code)
+}
+
+func TestRunRejectsRowsDeclinedByTheRiskRules(t *testing.T) {
+	f := newFixture(t, DefaultBatchSize)
+
+	rule, err := risk.CompileRule(model.RiskRule{Name: "large", Expression: "amount > 1000", Action: model.RISK_OUTCOME_DECLINE})
+	require.NoError(t, err)
+
+	f.importer.risk = risk.NewEngine(memory.NewRiskRepositoryMemory(f.store), []risk.Rule{rule})
+
+	file := strings.Join([]string{
+		"account_id,operation_type_id,amount",
+		"1,1,-5000",
+		"1,1,-20",
+	}, "\n")
+
+	imp, _, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "", strings.NewReader(file))
+	require.NoError(t, err)
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	finished, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+
+	assert.Equal(t, model.IMPORT_COMPLETED, finished.Status)
+	assert.Equal(t, uint64(1), finished.ImportedRows)
+	assert.Equal(t, uint64(1), finished.RejectedRows)
+
+	assert.Equal(t, []model.ImportRejection{
+		{Line: 2, Field: "", Code: "risk_declined", Message: "The transaction was declined by the risk rules: large."},
+	}, f.rejections(t, imp.ImportId))
+}
+
+func TestRunResumesAfterCrash(t *testing.T) {
+	f := newFixture(t, 2)
+
+	lines := []string{"account_id,operation_type_id,amount"}
+
+	for i := 0; i < 7; i++ {
+		lines = append(lines, "1,4,1")
+	}
+
+	imp, _, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "", strings.NewReader(strings.Join(lines, "\n")))
+	require.NoError(t, err)
+
+	crashing := *f.importer
+	crashing.imports = &failingImportRepository{ImportRepository: f.imports, failAfter: 2}
+
+	assert.ErrorIs(t, crashing.RunPending(context.Background()), repository.ErrUnavailable)
+
+	interrupted, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+	assert.Equal(t, model.IMPORT_RUNNING, interrupted.Status)
+	assert.Equal(t, uint64(5), interrupted.ProcessedLines)
+	assert.Equal(t, 4, f.transactionCount(t))
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	finished, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+	assert.Equal(t, model.IMPORT_COMPLETED, finished.Status)
+	assert.Equal(t, uint64(7), finished.ImportedRows)
+	assert.Equal(t, 7, f.transactionCount(t))
+}
+
+func TestRunFailsWithInvalidHeader(t *testing.T) {
+	f := newFixture(t, DefaultBatchSize)
+
+	imp, _, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "", strings.NewReader("account_id,amount,note\n1,10,x\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, f.importer.RunPending(context.Background()))
+
+	failed, err := f.imports.FindImport(imp.ImportId)
+	require.NoError(t, err)
+	assert.Equal(t, model.IMPORT_FAILED, failed.Status)
+	assert.Equal(t, `The file could not be read: unknown column "note" in the CSV header, expected account_id, operation_type_id, amount.`, failed.Error)
+}
+
+func TestSubmitReusesIdempotencyKey(t *testing.T) {
+	f := newFixture(t, DefaultBatchSize)
+
+	first, created, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "key-1", strings.NewReader("account_id,operation_type_id,amount\n"))
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	second, created, err := f.importer.Submit(context.Background(), model.IMPORT_FORMAT_CSV, "key-1", strings.NewReader("account_id,operation_type_id,amount\n"))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ImportId, second.ImportId)
+}
@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// columns are the fields of a transaction, as named in the REST API.
+var columns = []string{"account_id", "operation_type_id", "amount"}
+
+// row is a line of an import file. Rows breaking any rule have errors and
+// no transaction.
+type row struct {
+	line        uint64
+	transaction model.Transaction
+	errors      handler.ValidationErrors
+}
+
+type rowReader interface {
+	// next returns io.EOF after the last row.
+	next() (row, error)
+}
+
+func newRowReader(format string, r io.Reader) (rowReader, error) {
+	switch format {
+	case model.IMPORT_FORMAT_CSV:
+		return newCSVReader(r)
+	case model.IMPORT_FORMAT_JSONL:
+		return &jsonlReader{reader: bufio.NewReader(r)}, nil
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+// decodeRow checks a row as a JSON object with the same decoding and
+// validation rules as the body of POST /transactions.
+func decodeRow(line uint64, data []byte) row {
+	payload := &handler.TransactionPayload{}
+
+	err := handler.DecodeStrict(data, payload)
+
+	if err == nil {
+		err = payload.Validate()
+	}
+
+	var validationErrors handler.ValidationErrors
+
+	switch {
+	case err == nil:
+		return row{line: line, transaction: payload.Transaction()}
+	case errors.As(err, &validationErrors):
+		return row{line: line, errors: validationErrors}
+	default:
+		return malformedRow(line, "The line is not a valid JSON object.")
+	}
+}
+
+func malformedRow(line uint64, message string) row {
+	return row{line: line, errors: handler.ValidationErrors{{Code: handler.FieldCodeMalformedRow, Message: message}}}
+}
+
+type jsonlReader struct {
+	reader *bufio.Reader
+	line   uint64
+}
+
+func (j *jsonlReader) next() (row, error) {
+	for {
+		data, err := j.reader.ReadBytes('\n')
+
+		if err != nil && err != io.EOF {
+			return row{}, err
+		}
+
+		if len(data) == 0 && err == io.EOF {
+			return row{}, io.EOF
+		}
+
+		j.line++
+
+		if len(bytes.TrimSpace(data)) > 0 {
+			return decodeRow(j.line, data), nil
+		}
+	}
+}
+
+// csvReader turns every record into a JSON object, so CSV rows are checked
+// by the same decoder as JSON ones. Empty values are left out, and values
+// that are not numbers are sent as strings to be reported as invalid.
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("the CSV file is empty, it must start with a header")
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	known := map[string]bool{}
+
+	for _, column := range columns {
+		known[column] = true
+	}
+
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF")))
+
+		if !known[column] {
+			return nil, fmt.Errorf("unknown column %q in the CSV header, expected %s", column, strings.Join(columns, ", "))
+		}
+
+		delete(known, column)
+		header[i] = column
+	}
+
+	if len(known) > 0 {
+		return nil, fmt.Errorf("the CSV header must have the columns %s", strings.Join(columns, ", "))
+	}
+
+	return &csvReader{reader: reader, header: header}, nil
+}
+
+func (c *csvReader) next() (row, error) {
+	record, err := c.reader.Read()
+
+	var parseErr *csv.ParseError
+
+	if errors.As(err, &parseErr) {
+		return malformedRow(uint64(parseErr.StartLine), fmt.Sprintf("The line is not valid CSV: %s.", parseErr.Err)), nil
+	}
+
+	if err != nil {
+		return row{}, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+
+	if len(record) != len(c.header) {
+		return malformedRow(uint64(line), fmt.Sprintf("The line has %d fields but the header has %d.", len(record), len(c.header))), nil
+	}
+
+	fields := map[string]json.RawMessage{}
+
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+
+		if value == "" {
+			continue
+		}
+
+		var number json.Number
+
+		if json.Unmarshal([]byte(value), &number) == nil && !strings.HasPrefix(value, `"`) {
+			fields[c.header[i]] = json.RawMessage(value)
+		} else {
+			quoted, _ := json.Marshal(value)
+			fields[c.header[i]] = quoted
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return row{}, err
+	}
+
+	return decodeRow(uint64(line), data), nil
+}
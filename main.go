@@ -1,43 +1,374 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
 
 	_ "github.com/lib/pq"
 
 	"database/sql"
+	"errors"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
+	"github.com/felipedsi/pismo-test/accrual"
+	"github.com/felipedsi/pismo-test/api"
+	"github.com/felipedsi/pismo-test/dispute"
+	"github.com/felipedsi/pismo-test/exporter"
+	"github.com/felipedsi/pismo-test/grpcapi"
 	"github.com/felipedsi/pismo-test/handler"
+	"github.com/felipedsi/pismo-test/importer"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/projector"
+	"github.com/felipedsi/pismo-test/repository"
 	"github.com/felipedsi/pismo-test/repository/adapter"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+	"github.com/felipedsi/pismo-test/risk"
+	"github.com/felipedsi/pismo-test/scheduler"
 )
 
 func main() {
-	connStr := os.Getenv("POSTGRESQL_URL")
+	storage := flag.String("storage", getEnv("STORAGE_DRIVER", "postgres"), "storage driver to use: postgres, sqlite or memory")
+	sqlitePath := flag.String("sqlite-path", getEnv("SQLITE_PATH", "pismo.db"), "database file used by the sqlite storage driver")
+	grpcAddr := flag.String("grpc-addr", getEnv("GRPC_ADDR", ":3001"), "address the gRPC server listens on")
+	importsDir := flag.String("imports-dir", getEnv("IMPORTS_DIR", "imports"), "directory the uploaded import files are kept in until imported")
+	exportsDir := flag.String("exports-dir", getEnv("EXPORTS_DIR", "exports"), "directory the statements exported in the background are kept in")
+	exportStreamLimit := flag.Uint64("export-stream-limit", handler.DefaultExportStreamLimit, "largest statement, in transactions, streamed in the response instead of exported in the background")
+	replay := flag.Bool("replay", false, "rebuild the balances and the listing of the transactions from the events, then exit")
+	accrue := flag.String("accrue", "", "post the interest and late fees of this past day (YYYY-MM-DD, UTC), then exit")
+	dryRun := flag.Bool("dry-run", false, "with -accrue, report the charges instead of posting them")
+	accrualConfig := accrual.Config{}
+	flag.Float64Var(&accrualConfig.DailyRate, "interest-daily-rate", 0, "interest charged per day on unpaid balances, such as 0.0005, 0 to charge none")
+	flag.StringVar(&accrualConfig.DayCount, "interest-day-count", accrual.DayCountActual, "day-count convention of the interest: actual or 30/360")
+	flag.IntVar(&accrualConfig.GracePeriod, "interest-grace-days", 0, "days a debt goes without interest")
+	flag.Float64Var(&accrualConfig.LateFee, "late-fee", 0, "fee charged on accounts making no payment for -late-fee-days while owing money, 0 to charge none")
+	flag.IntVar(&accrualConfig.LatePaymentPeriod, "late-fee-days", 30, "days an account owing money has to make a payment before it is charged the late fee")
+	riskRulesFile := flag.String("risk-rules", getEnv("RISK_RULES_FILE", ""), "JSON file of risk rules the transactions are assessed with, along with the ones created through the API")
+	piiKeyFile := flag.String("pii-key-file", getEnv("PII_KEY_FILE", ""), "keyring file of the master keys the personal data is encrypted under, one per line with the current one last, required with the postgres and sqlite drivers")
+	piiIndexKeyFile := flag.String("pii-index-key-file", getEnv("PII_INDEX_KEY_FILE", ""), "file of the key the document numbers are indexed with, created by -rotate-pii-key when missing, required with the postgres and sqlite drivers")
+	rotatePIIKey := flag.Bool("rotate-pii-key", false, "add a master key to -pii-key-file for the personal data to be re-encrypted under once restarted, creating the key files when missing, then exit")
+	validateOpenAPI := flag.Bool("validate-openapi", getEnv("OPENAPI_VALIDATION", "false") == "true", "validate requests and responses against the OpenAPI document")
+	flag.Parse()
 
-	db, err := sql.Open("postgres", connStr)
+	if *rotatePIIKey {
+		requireKeyFiles(*piiKeyFile, *piiIndexKeyFile)
+
+		keyId, err := pii.AppendKey(*piiKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Added master key %s to %s", keyId, *piiKeyFile)
+
+		err = pii.CreateKeyFile(*piiIndexKeyFile)
+
+		switch {
+		case err == nil:
+			log.Printf("Created the index key in %s", *piiIndexKeyFile)
+		case !errors.Is(err, os.ErrExist):
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if err := accrualConfig.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	var riskRules []risk.Rule
+
+	if *riskRulesFile != "" {
+		rules, err := risk.LoadRules(*riskRulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		riskRules = rules
+	}
+
+	var accountRepository repository.AccountRepository
+	var transactionRepository repository.TransactionRepository
+	var operationTypeRepository repository.OperationTypeRepository
+	var importRepository repository.ImportRepository
+	var exportRepository repository.ExportRepository
+	var auditRepository repository.AuditRepository
+	var eventRepository repository.EventRepository
+	var projectionRepository repository.ProjectionRepository
+	var scheduleRepository repository.ScheduleRepository
+	var fxRateRepository repository.FxRateRepository
+	var cardRepository repository.CardRepository
+	var spendRuleRepository repository.SpendRuleRepository
+	var riskRepository repository.RiskRepository
+	var disputeRepository repository.DisputeRepository
+	var holderRepository repository.HolderRepository
+	var personalDataRepositories []repository.PersonalDataRepository
+
+	switch *storage {
+	case "postgres":
+		connStr := os.Getenv("POSTGRESQL_URL")
+
+		db, err := sql.Open("postgres", connStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer db.Close()
+
+		requireKeyFiles(*piiKeyFile, *piiIndexKeyFile)
+
+		cipher, index := loadCipher(*piiKeyFile), loadIndex(*piiIndexKeyFile)
+
+		accounts := adapter.NewAccountRepositoryPostgres(db, cipher, index)
+		holders := adapter.NewHolderRepositoryPostgres(db, cipher)
+
+		accountRepository = accounts
+		transactionRepository = adapter.NewTransactionRepositoryPostgres(db)
+		operationTypeRepository = adapter.NewOperationTypeRepositoryPostgres(db)
+		importRepository = adapter.NewImportRepositoryPostgres(db)
+		exportRepository = adapter.NewExportRepositoryPostgres(db)
+		auditRepository = adapter.NewAuditRepositoryPostgres(db)
+		eventRepository = adapter.NewEventRepositoryPostgres(db)
+		projectionRepository = adapter.NewProjectionRepositoryPostgres(db)
+		scheduleRepository = adapter.NewScheduleRepositoryPostgres(db)
+		fxRateRepository = adapter.NewFxRateRepositoryPostgres(db)
+		cardRepository = adapter.NewCardRepositoryPostgres(db)
+		spendRuleRepository = adapter.NewSpendRuleRepositoryPostgres(db)
+		riskRepository = adapter.NewRiskRepositoryPostgres(db)
+		disputeRepository = adapter.NewDisputeRepositoryPostgres(db)
+		holderRepository = holders
+		personalDataRepositories = []repository.PersonalDataRepository{accounts, holders}
+	case "sqlite":
+		db, err := adapter.OpenSQLite(*sqlitePath)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer db.Close()
+
+		requireKeyFiles(*piiKeyFile, *piiIndexKeyFile)
+
+		cipher, index := loadCipher(*piiKeyFile), loadIndex(*piiIndexKeyFile)
+
+		accounts := adapter.NewAccountRepositorySQLite(db, cipher, index)
+		holders := adapter.NewHolderRepositorySQLite(db, cipher)
+
+		accountRepository = accounts
+		transactionRepository = adapter.NewTransactionRepositorySQLite(db)
+		operationTypeRepository = adapter.NewOperationTypeRepositorySQLite(db)
+		importRepository = adapter.NewImportRepositorySQLite(db)
+		exportRepository = adapter.NewExportRepositorySQLite(db)
+		auditRepository = adapter.NewAuditRepositorySQLite(db)
+		eventRepository = adapter.NewEventRepositorySQLite(db)
+		projectionRepository = adapter.NewProjectionRepositorySQLite(db)
+		scheduleRepository = adapter.NewScheduleRepositorySQLite(db)
+		fxRateRepository = adapter.NewFxRateRepositorySQLite(db)
+		cardRepository = adapter.NewCardRepositorySQLite(db)
+		spendRuleRepository = adapter.NewSpendRuleRepositorySQLite(db)
+		riskRepository = adapter.NewRiskRepositorySQLite(db)
+		disputeRepository = adapter.NewDisputeRepositorySQLite(db)
+		holderRepository = holders
+		personalDataRepositories = []repository.PersonalDataRepository{accounts, holders}
+	case "memory":
+		store := memory.NewStore()
+
+		accountRepository = memory.NewAccountRepositoryMemory(store)
+		transactionRepository = memory.NewTransactionRepositoryMemory(store)
+		operationTypeRepository = memory.NewOperationTypeRepositoryMemory(store)
+		importRepository = memory.NewImportRepositoryMemory(store)
+		exportRepository = memory.NewExportRepositoryMemory(store)
+		auditRepository = memory.NewAuditRepositoryMemory(store)
+		eventRepository = memory.NewEventRepositoryMemory(store)
+		projectionRepository = memory.NewProjectionRepositoryMemory(store)
+		scheduleRepository = memory.NewScheduleRepositoryMemory(store)
+		fxRateRepository = memory.NewFxRateRepositoryMemory(store)
+		cardRepository = memory.NewCardRepositoryMemory(store)
+		spendRuleRepository = memory.NewSpendRuleRepositoryMemory(store)
+		riskRepository = memory.NewRiskRepositoryMemory(store)
+		disputeRepository = memory.NewDisputeRepositoryMemory(store)
+		holderRepository = memory.NewHolderRepositoryMemory(store)
+	default:
+		log.Fatalf("Unknown storage driver: %s", *storage)
+	}
+
+	eventProjector := projector.NewProjector(projectionRepository)
+
+	if *replay {
+		replayed, err := eventProjector.Replay(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("Replayed %d events", replayed)
+
+		return
+	}
+
+	accruer := accrual.NewAccruer(accountRepository, transactionRepository, accrualConfig)
+
+	if *accrue != "" {
+		day, err := time.Parse(time.DateOnly, *accrue)
+		if err != nil {
+			log.Fatalf("Invalid -accrue day: %s", err)
+		}
+
+		if !day.Before(accrual.Day(time.Now())) {
+			log.Fatal("Only past days can be accrued")
+		}
+
+		report, err := accruer.Run(context.Background(), day, *dryRun)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, charge := range report.Charges {
+			log.Printf("Account %d: operation type %d, amount %s", charge.AccountId, charge.OperationTypeId, charge.Amount)
+		}
+
+		if report.DryRun {
+			log.Printf("Would post %d charges for %s, %d blocked accounts skipped", len(report.Charges), *accrue, report.Skipped)
+		} else {
+			log.Printf("Posted %d charges for %s, %d blocked accounts skipped", len(report.Charges), *accrue, report.Skipped)
+		}
+
+		return
+	}
+
+	for _, dir := range []string{*importsDir, *exportsDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	riskEngine := risk.NewEngine(riskRepository, riskRules)
+	transactionImporter := importer.NewImporter(importRepository, accountRepository, riskEngine, *importsDir, importer.DefaultBatchSize)
+	statementExporter := exporter.NewExporter(exportRepository, accountRepository, *exportsDir)
+	transactionScheduler := scheduler.NewScheduler(scheduleRepository, transactionRepository, riskEngine)
+	disputeSweeper := dispute.NewSweeper(disputeRepository)
+	piiRotator := pii.NewRotator(personalDataRepositories...)
+
+	router, err := api.NewRouter(api.Repositories{
+		Accounts:       accountRepository,
+		Transactions:   transactionRepository,
+		OperationTypes: operationTypeRepository,
+		Imports:        importRepository,
+		Exports:        exportRepository,
+		Audit:          auditRepository,
+		Events:         eventRepository,
+		Schedules:      scheduleRepository,
+		FxRates:        fxRateRepository,
+		Cards:          cardRepository,
+		SpendRules:     spendRuleRepository,
+		Risk:           riskRepository,
+		Disputes:       disputeRepository,
+		Holders:        holderRepository,
+	}, api.Options{
+		ValidateOpenAPI:   *validateOpenAPI,
+		Importer:          transactionImporter,
+		Exporter:          statementExporter,
+		ExportStreamLimit: *exportStreamLimit,
+		Risk:              riskEngine,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer db.Close()
+	go transactionImporter.Start(context.Background())
+	go statementExporter.Start(context.Background())
+	go eventProjector.Start(context.Background())
+	go transactionScheduler.Start(context.Background())
+	go disputeSweeper.Start(context.Background())
 
-	accountRepositoryPostgres := adapter.NewAccountRepositoryPostgres(db)
-	transactionRepositoryPostgres := adapter.NewTransactionRepositoryPostgres(db)
+	if accrualConfig.Enabled() {
+		go accruer.Start(context.Background())
+	}
 
-	accountHandler := handler.NewAccountHandler(accountRepositoryPostgres)
-	transactionHandler := handler.NewTransactionHandler(transactionRepositoryPostgres)
+	if len(personalDataRepositories) > 0 {
+		go piiRotator.Start(context.Background())
+	}
+
+	go serveGRPC(*grpcAddr, accountRepository, transactionRepository, riskEngine)
+
+	http.ListenAndServe(":3000", router)
+}
 
-	r := chi.NewRouter()
+func serveGRPC(addr string, accountRepository repository.AccountRepository, transactionRepository repository.TransactionRepository, riskEngine *risk.Engine) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.Use(middleware.Logger)
+	err = grpcapi.NewServer(accountRepository, transactionRepository, riskEngine).Serve(listener)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	r.Post("/accounts", accountHandler.CreateAccount)
-	r.Get("/accounts/{accountId}", accountHandler.GetAccount)
-	r.Post("/transactions", transactionHandler.CreateTransaction)
+// requireKeyFiles stops unless the files of the keys the personal data is
+// encrypted and indexed with are given, as none is assumed.
+func requireKeyFiles(keyFile string, indexKeyFile string) {
+	if keyFile == "" {
+		log.Fatal("-pii-key-file is required")
+	}
+
+	if indexKeyFile == "" {
+		log.Fatal("-pii-index-key-file is required")
+	}
+}
+
+// loadCipher returns the cipher of the personal data under the master keys
+// in the keyring at path. Its first key is the one the holders were
+// encrypted with before the master keys.
+func loadCipher(path string) pii.Cipher {
+	keys, err := pii.LoadKeyring(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("%s, run with -rotate-pii-key to create it", err)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	kms, err := pii.NewLocalKMS(keys)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	legacy, err := pii.NewAESCipher(keys[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return pii.NewEnvelopeCipher(kms, legacy)
+}
+
+// loadIndex returns the blind index of the personal data with the key in
+// path, created by -rotate-pii-key.
+func loadIndex(path string) *pii.BlindIndex {
+	key, err := pii.LoadKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("%s, run with -rotate-pii-key to create it", err)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	index, err := pii.NewBlindIndex(key)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return index
+}
+
+func getEnv(key string, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
 
-	http.ListenAndServe(":3000", r)
+	return fallback
 }
@@ -0,0 +1,107 @@
+// Package merchantcontrol applies the spend rules of the accounts to their
+// purchases and withdraws. The adapters of the transaction repository check
+// the transactions as they post them, once converted to the currency of
+// their account, with the rules and spending of their storage.
+package merchantcontrol
+
+import (
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// RuleFinder returns the spend rules of the account.
+type RuleFinder func(accountId uint64) ([]model.SpendRule, error)
+
+// SpendFinder returns the amount of the purchases and withdraws of the
+// account in category that took place from from until to, in UTC, and were
+// not reversed.
+type SpendFinder func(accountId uint64, category string, from time.Time, to time.Time) (model.Amount, error)
+
+// spendKey is the spending of an account on a category over the period of
+// a cap rule.
+type spendKey struct {
+	spendRuleId uint64
+	from        time.Time
+}
+
+// CheckTransactions fails the purchases and withdraws at a merchant denied
+// to their account with repository.ErrMerchantDenied, the ones at merchants
+// none of the allow rules of their account name, or of no known merchant
+// category code, with repository.ErrMerchantNotAllowed, and the ones going
+// over a cap of their account, counting the ones before them, with
+// repository.ErrCategoryCapExceeded.
+func CheckTransactions(transactions []model.Transaction, findRules RuleFinder, findSpend SpendFinder) error {
+	rules := map[uint64][]model.SpendRule{}
+	spent := map[spendKey]model.Amount{}
+
+	for _, transaction := range transactions {
+		if !model.IsMerchantOperationType(transaction.OperationTypeId) {
+			continue
+		}
+
+		accountRules, ok := rules[transaction.AccountId]
+
+		if !ok {
+			found, err := findRules(transaction.AccountId)
+			if err != nil {
+				return err
+			}
+
+			accountRules = found
+			rules[transaction.AccountId] = accountRules
+		}
+
+		allowing, allowed := false, false
+
+		for _, rule := range accountRules {
+			switch rule.Type {
+			case model.SPEND_RULE_DENY:
+				if rule.Matches(transaction.Mcc) {
+					return repository.ErrMerchantDenied
+				}
+			case model.SPEND_RULE_ALLOW:
+				allowing = true
+				allowed = allowed || rule.Matches(transaction.Mcc)
+			}
+		}
+
+		if allowing && !allowed {
+			return repository.ErrMerchantNotAllowed
+		}
+
+		category := model.MerchantCategory(transaction.Mcc)
+		at := transaction.EventDate
+
+		if at.IsZero() {
+			at = time.Now()
+		}
+
+		for _, rule := range accountRules {
+			if rule.Type != model.SPEND_RULE_CAP || category == "" || rule.Category != category {
+				continue
+			}
+
+			from, to := rule.PeriodOf(at)
+			key := spendKey{spendRuleId: rule.SpendRuleId, from: from}
+
+			if _, ok := spent[key]; !ok {
+				periodSpend, err := findSpend(transaction.AccountId, category, from, to)
+				if err != nil {
+					return err
+				}
+
+				spent[key] = periodSpend
+			}
+
+			spent[key] = spent[key].Sub(transaction.Amount)
+
+			if spent[key].Cmp(rule.Limit) > 0 {
+				return repository.ErrCategoryCapExceeded
+			}
+		}
+	}
+
+	return nil
+}
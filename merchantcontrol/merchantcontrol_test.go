@@ -0,0 +1,82 @@
+package merchantcontrol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+var day = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func findRules(rules ...model.SpendRule) RuleFinder {
+	return func(accountId uint64) ([]model.SpendRule, error) {
+		var found []model.SpendRule
+
+		for _, rule := range rules {
+			if rule.AccountId == accountId {
+				found = append(found, rule)
+			}
+		}
+
+		return found, nil
+	}
+}
+
+func spending(amount string) SpendFinder {
+	return func(accountId uint64, category string, from time.Time, to time.Time) (model.Amount, error) {
+		return model.MustParseAmount(amount), nil
+	}
+}
+
+func purchase(accountId uint64, mcc string, amount string) model.Transaction {
+	return model.Transaction{AccountId: accountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount), EventDate: day, Mcc: mcc}
+}
+
+func TestCheckTransactions(t *testing.T) {
+	findRule := findRules(
+		model.SpendRule{SpendRuleId: 1, AccountId: 1, Type: model.SPEND_RULE_DENY, Mcc: "5813"},
+		model.SpendRule{SpendRuleId: 2, AccountId: 1, Type: model.SPEND_RULE_ALLOW, Category: model.MERCHANT_CATEGORY_RESTAURANTS},
+		model.SpendRule{SpendRuleId: 3, AccountId: 1, Type: model.SPEND_RULE_ALLOW, Mcc: "5411"},
+		model.SpendRule{SpendRuleId: 4, AccountId: 1, Type: model.SPEND_RULE_CAP, Category: model.MERCHANT_CATEGORY_RESTAURANTS, Limit: model.MustParseAmount("100.3"), Period: model.SPEND_PERIOD_DAY},
+		model.SpendRule{SpendRuleId: 5, AccountId: 2, Type: model.SPEND_RULE_DENY, Category: model.MERCHANT_CATEGORY_GAMBLING},
+	)
+
+	payment := purchase(1, "", "50")
+	payment.OperationTypeId = model.PAYMENT
+
+	tests := []struct {
+		name         string
+		transactions []model.Transaction
+		spent        string
+		err          error
+	}{
+		{"payment", []model.Transaction{payment}, "0", nil},
+		{"allowed mcc", []model.Transaction{purchase(1, "5411", "-1000")}, "0", nil},
+		{"allowed category", []model.Transaction{purchase(1, "5812", "-100"), purchase(1, "5814", "-0.3")}, "0", nil},
+		{"denied mcc of an allowed category", []model.Transaction{purchase(1, "5813", "-1")}, "0", repository.ErrMerchantDenied},
+		{"mcc allowed by no rule", []model.Transaction{purchase(1, "5541", "-1")}, "0", repository.ErrMerchantNotAllowed},
+		{"no mcc with allow rules", []model.Transaction{purchase(1, "", "-1")}, "0", repository.ErrMerchantNotAllowed},
+		{"denied category", []model.Transaction{purchase(2, "7995", "-1")}, "0", repository.ErrMerchantDenied},
+		{"no mcc without allow rules", []model.Transaction{purchase(2, "", "-1")}, "0", nil},
+		{"account without rules", []model.Transaction{purchase(3, "7995", "-1")}, "0", nil},
+		{"over the cap in the batch", []model.Transaction{purchase(1, "5812", "-100"), purchase(1, "5814", "-0.31")}, "0", repository.ErrCategoryCapExceeded},
+		{"over the cap with the period spending", []model.Transaction{purchase(1, "5812", "-0.01")}, "100.3", repository.ErrCategoryCapExceeded},
+		{"up to the cap with the period spending", []model.Transaction{purchase(1, "5812", "-50.1")}, "50.2", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckTransactions(test.transactions, findRule, spending(test.spent))
+
+			if test.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.err)
+			}
+		})
+	}
+}
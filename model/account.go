@@ -2,11 +2,25 @@ package model
 
 import "net/http"
 
+// Account is billed in Currency, the ISO 4217 currency of the amounts of
+// its transactions. HolderId is the holder owning the account, when it was
+// opened for one. DocumentNumber is personal data, see Redacted.
 type Account struct {
 	AccountId      uint64 `json:"account_id,omitempty"`
-	DocumentNumber uint64 `json:"document_number"`
+	DocumentNumber uint64 `json:"document_number,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+	Blocked        bool   `json:"blocked,omitempty"`
+	HolderId       uint64 `json:"holder_id,omitempty"`
 }
 
 func (a Account) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+// Redacted returns the account without its document number, as recorded in
+// the events and the audit log.
+func (a Account) Redacted() Account {
+	a.DocumentNumber = 0
+
+	return a
+}
@@ -0,0 +1,214 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AMOUNT_DECIMALS is the number of decimals an Amount is exact to, the
+// scale of the NUMERIC(12, 4) columns the amounts are stored in.
+const AMOUNT_DECIMALS = 4
+
+// amountScale is the number of units of an Amount in one.
+const amountScale = 10000
+
+// maxAmount is the largest amount the NUMERIC(12, 4) columns hold, in units.
+const maxAmount = 1e12 - 1
+
+var (
+	ErrAmountPrecision = fmt.Errorf("amount has more than %d decimals", AMOUNT_DECIMALS)
+	ErrAmountRange     = errors.New("amount is too large")
+	ErrAmountSyntax    = errors.New("amount is not a decimal number")
+)
+
+// amountSyntax is the grammar of the JSON numbers, with an exponent of up to
+// two digits, which already takes any amount held out of range.
+var amountSyntax = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]{1,2})?$`)
+
+// Amount is an amount of money, exact to AMOUNT_DECIMALS decimals. It is
+// read and written in JSON as a number and stored as a decimal, so an
+// amount never goes through a binary float on its way from the API to the
+// database. The zero value is zero.
+type Amount struct {
+	// units counts the ten-thousandths.
+	units int64
+}
+
+// ParseAmount reads the decimal number s, such as -10.15 or 1e3.
+func ParseAmount(s string) (Amount, error) {
+	if !amountSyntax.MatchString(s) {
+		return Amount{}, ErrAmountSyntax
+	}
+
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Amount{}, ErrAmountSyntax
+	}
+
+	value.Mul(value, new(big.Rat).SetInt64(amountScale))
+
+	if !value.IsInt() {
+		return Amount{}, ErrAmountPrecision
+	}
+
+	if value.Num().CmpAbs(big.NewInt(maxAmount)) > 0 {
+		return Amount{}, ErrAmountRange
+	}
+
+	return Amount{units: value.Num().Int64()}, nil
+}
+
+// MustParseAmount is ParseAmount for the amounts known to be valid, such as
+// constants. It panics on an invalid one.
+func MustParseAmount(s string) Amount {
+	amount, err := ParseAmount(s)
+	if err != nil {
+		panic(fmt.Sprintf("model: ParseAmount(%q): %s", s, err))
+	}
+
+	return amount
+}
+
+// AmountFromFloat returns the amount written as f, the shortest decimal
+// that reads back as f, such as 10.15 rather than the binary float closest
+// to it. bitSize is 32 for the amounts sent as a float32.
+func AmountFromFloat(f float64, bitSize int) (Amount, error) {
+	return ParseAmount(strconv.FormatFloat(f, 'f', -1, bitSize))
+}
+
+// AmountFromMinorUnits returns units, in minor units of currency, as an
+// amount.
+func AmountFromMinorUnits(units *big.Int, currency string) (Amount, error) {
+	scaled := new(big.Int).Mul(units, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(AMOUNT_DECIMALS-MinorUnits(currency))), nil))
+
+	if scaled.CmpAbs(big.NewInt(maxAmount)) > 0 {
+		return Amount{}, ErrAmountRange
+	}
+
+	return Amount{units: scaled.Int64()}, nil
+}
+
+// String writes the amount with no trailing zero, such as -50.62 or 60.
+func (a Amount) String() string {
+	units := a.units
+	sign := ""
+
+	if units < 0 {
+		sign, units = "-", -units
+	}
+
+	whole := strconv.FormatInt(units/amountScale, 10)
+	fraction := strings.TrimRight(fmt.Sprintf("%0*d", AMOUNT_DECIMALS, units%amountScale), "0")
+
+	if fraction == "" {
+		return sign + whole
+	}
+
+	return sign + whole + "." + fraction
+}
+
+// Decimals returns the number of decimals the amount is written with.
+func (a Amount) Decimals() int {
+	_, fraction, _ := strings.Cut(a.String(), ".")
+
+	return len(fraction)
+}
+
+// Float64 returns the float64 closest to the amount, for the computations
+// that need no exact result, such as the risk rules.
+func (a Amount) Float64() float64 {
+	return float64(a.units) / amountScale
+}
+
+// Sign returns -1, 0 or +1 as the amount is negative, zero or positive.
+func (a Amount) Sign() int {
+	switch {
+	case a.units < 0:
+		return -1
+	case a.units > 0:
+		return 1
+	}
+
+	return 0
+}
+
+func (a Amount) IsZero() bool {
+	return a.units == 0
+}
+
+// Cmp returns -1, 0 or +1 as a is less than, equal to or greater than b.
+func (a Amount) Cmp(b Amount) int {
+	return a.Sub(b).Sign()
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return Amount{units: a.units + b.units}
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return Amount{units: a.units - b.units}
+}
+
+func (a Amount) Neg() Amount {
+	return Amount{units: -a.units}
+}
+
+// Abs returns the amount without its sign.
+func (a Amount) Abs() Amount {
+	if a.units < 0 {
+		return a.Neg()
+	}
+
+	return a
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	amount, err := ParseAmount(string(data))
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+
+	return nil
+}
+
+// Value stores the amount as a decimal string, which the NUMERIC columns
+// take exactly.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// Scan reads a NUMERIC column, written as a decimal by Postgres and as an
+// integer or a float by SQLite.
+func (a *Amount) Scan(src interface{}) error {
+	var err error
+
+	switch value := src.(type) {
+	case []byte:
+		*a, err = ParseAmount(string(value))
+	case string:
+		*a, err = ParseAmount(value)
+	case int64:
+		*a, err = ParseAmount(strconv.FormatInt(value, 10))
+	case float64:
+		*a, err = ParseAmount(strconv.FormatFloat(value, 'f', AMOUNT_DECIMALS, 64))
+	default:
+		err = fmt.Errorf("cannot scan %T into an amount", src)
+	}
+
+	return err
+}
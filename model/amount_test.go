@@ -0,0 +1,110 @@
+package model
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	scenarios := []struct {
+		input    string
+		expected string
+		err      error
+	}{
+		{"-10.15", "-10.15", nil},
+		{"60.5000", "60.5", nil},
+		{"0.0001", "0.0001", nil},
+		{"1e3", "1000", nil},
+		{"-1.5E-2", "-0.015", nil},
+		{"99999999.9999", "99999999.9999", nil},
+		{"0.00001", "", ErrAmountPrecision},
+		{"1e-5", "", ErrAmountPrecision},
+		{"100000000", "", ErrAmountRange},
+		{"1e99", "", ErrAmountRange},
+		{"10.", "", ErrAmountSyntax},
+		{"+1", "", ErrAmountSyntax},
+		{"01", "", ErrAmountSyntax},
+		{`"1"`, "", ErrAmountSyntax},
+		{"1e999999999", "", ErrAmountSyntax},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.input, func(t *testing.T) {
+			amount, err := ParseAmount(scenario.input)
+
+			if scenario.err != nil {
+				assert.ErrorIs(t, err, scenario.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, scenario.expected, amount.String())
+		})
+	}
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	a, b := MustParseAmount("0.1"), MustParseAmount("0.2")
+
+	assert.Equal(t, MustParseAmount("0.3"), a.Add(b))
+	assert.Equal(t, MustParseAmount("-0.1"), a.Sub(b))
+	assert.Equal(t, MustParseAmount("0.1"), a.Sub(b).Abs())
+	assert.Equal(t, -1, a.Cmp(b))
+	assert.Equal(t, 0, a.Cmp(MustParseAmount("0.10")))
+	assert.Equal(t, 3, MustParseAmount("12284.803").Decimals())
+	assert.True(t, Amount{}.IsZero())
+}
+
+func TestAmountFromFloat(t *testing.T) {
+	amount, err := AmountFromFloat(float64(float32(-10.15)), 32)
+
+	require.NoError(t, err)
+	assert.Equal(t, MustParseAmount("-10.15"), amount)
+
+	_, err = AmountFromFloat(0.12345, 64)
+	assert.ErrorIs(t, err, ErrAmountPrecision)
+}
+
+func TestAmountFromMinorUnits(t *testing.T) {
+	amount, err := AmountFromMinorUnits(big.NewInt(12284803), "KWD")
+
+	require.NoError(t, err)
+	assert.Equal(t, MustParseAmount("12284.803"), amount)
+
+	_, err = AmountFromMinorUnits(big.NewInt(1e12), "JPY")
+	assert.ErrorIs(t, err, ErrAmountRange)
+}
+
+func TestAmountJSON(t *testing.T) {
+	var transaction Transaction
+
+	require.NoError(t, json.Unmarshal([]byte(`{"amount": -12284.803}`), &transaction))
+	assert.Equal(t, MustParseAmount("-12284.803"), transaction.Amount)
+
+	encoded, err := json.Marshal(transaction.Amount)
+
+	require.NoError(t, err)
+	assert.Equal(t, "-12284.803", string(encoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 0.00001}`), &transaction))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": "10"}`), &transaction))
+}
+
+func TestAmountScan(t *testing.T) {
+	for _, src := range []interface{}{[]byte("-50.6200"), "-50.62", -50.62, -50.620000000000005} {
+		var amount Amount
+
+		require.NoError(t, amount.Scan(src))
+		assert.Equal(t, MustParseAmount("-50.62"), amount)
+	}
+
+	var amount Amount
+
+	require.NoError(t, amount.Scan(int64(60)))
+	assert.Equal(t, MustParseAmount("60"), amount)
+	assert.Error(t, amount.Scan(nil))
+}
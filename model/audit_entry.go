@@ -0,0 +1,54 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const AUDIT_ACTION_CREATE = "create"
+const AUDIT_ACTION_UPDATE = "update"
+const AUDIT_ACTION_DELETE = "delete"
+const AUDIT_ACTION_REANCHOR = "reanchor"
+
+const AUDIT_ENTITY_ACCOUNT = "account"
+const AUDIT_ENTITY_TRANSACTION = "transaction"
+const AUDIT_ENTITY_IMPORT = "import"
+const AUDIT_ENTITY_EXPORT = "export"
+const AUDIT_ENTITY_SCHEDULE = "schedule"
+const AUDIT_ENTITY_FX_RATE = "fx_rate"
+const AUDIT_ENTITY_CARD = "card"
+const AUDIT_ENTITY_SPEND_RULE = "spend_rule"
+const AUDIT_ENTITY_RISK_RULE = "risk_rule"
+const AUDIT_ENTITY_DISPUTE = "dispute"
+const AUDIT_ENTITY_HOLDER = "holder"
+const AUDIT_ENTITY_AUDIT_LOG = "audit_log"
+
+// AuditEntry records a change made to an entity. Before and After are JSON
+// snapshots of the entity, Before being null for creations and After for
+// deletions. Every entry is chained to the previous one by PrevHash, so
+// changing or removing any of them breaks the chain. The chain is hashed
+// again only by a migration rewriting the entries, which then appends a
+// reanchor entry of the audit log, holding the hash it ended on before.
+type AuditEntry struct {
+	AuditEntryId uint64          `json:"audit_entry_id"`
+	Action       string          `json:"action"`
+	EntityType   string          `json:"entity_type"`
+	EntityId     uint64          `json:"entity_id"`
+	Actor        string          `json:"actor"`
+	ClientIP     string          `json:"client_ip,omitempty"`
+	RequestId    string          `json:"request_id,omitempty"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	CreatedAt    time.Time       `json:"created_at"`
+	PrevHash     string          `json:"prev_hash"`
+	Hash         string          `json:"hash"`
+}
+
+func ValidateAuditEntityType(entityType string) bool {
+	switch entityType {
+	case AUDIT_ENTITY_ACCOUNT, AUDIT_ENTITY_TRANSACTION, AUDIT_ENTITY_IMPORT, AUDIT_ENTITY_EXPORT, AUDIT_ENTITY_SCHEDULE, AUDIT_ENTITY_FX_RATE, AUDIT_ENTITY_CARD, AUDIT_ENTITY_SPEND_RULE, AUDIT_ENTITY_RISK_RULE, AUDIT_ENTITY_DISPUTE, AUDIT_ENTITY_HOLDER, AUDIT_ENTITY_AUDIT_LOG:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+// Balance is the sum of the amounts of the transactions of an account, less
+// the ones reversed, along with whether the account was blocked. Version is
+// the version of the stream of the account it was computed at, and AsOf the
+// instant it was computed for when it is not the latest balance.
+type Balance struct {
+	AccountId uint64     `json:"account_id"`
+	Balance   float64    `json:"balance"`
+	Blocked   bool       `json:"blocked,omitempty"`
+	Version   uint64     `json:"version"`
+	AsOf      *time.Time `json:"as_of,omitempty"`
+}
+
+func (b Balance) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const CARD_STATUS_ACTIVE = "active"
+const CARD_STATUS_BLOCKED = "blocked"
+const CARD_STATUS_REPLACED = "replaced"
+
+const CARD_TYPE_VIRTUAL = "virtual"
+const CARD_TYPE_PHYSICAL = "physical"
+
+// Card spends the balance of its account. The PAN is never kept: PanToken
+// stands for it and LastFour is all that is shown of it. A replaced card
+// points to its replacement by ReplacedBy and takes no more purchases.
+//
+// HolderId is the holder the card is issued to, when given. A holder other
+// than the one owning the account is an additional cardholder.
+//
+// TransactionLimit and DailyLimit cap the amount of a purchase or withdraw
+// made with the card and their sum over a day, in UTC, both in the currency
+// of the account. Zero means no cap.
+type Card struct {
+	CardId           uint64    `json:"card_id"`
+	AccountId        uint64    `json:"account_id"`
+	HolderId         uint64    `json:"holder_id,omitempty"`
+	PanToken         string    `json:"pan_token"`
+	LastFour         string    `json:"last_four"`
+	ExpiryMonth      int       `json:"expiry_month"`
+	ExpiryYear       int       `json:"expiry_year"`
+	Status           string    `json:"status"`
+	Type             string    `json:"type"`
+	TransactionLimit Amount    `json:"transaction_limit,omitzero"`
+	DailyLimit       Amount    `json:"daily_limit,omitzero"`
+	ReplacedBy       uint64    `json:"replaced_by,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+func (c Card) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Expired reports whether the card expired before at. Cards are valid up to
+// the end of their expiry month, in UTC.
+func (c Card) Expired(at time.Time) bool {
+	return !at.Before(time.Date(c.ExpiryYear, time.Month(c.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func ValidateCardType(cardType string) bool {
+	return cardType == CARD_TYPE_VIRTUAL || cardType == CARD_TYPE_PHYSICAL
+}
+
+// ValidatePan reports whether pan is made of 12 to 19 digits passing the
+// Luhn check.
+func ValidatePan(pan string) bool {
+	if len(pan) < 12 || len(pan) > 19 {
+		return false
+	}
+
+	sum := 0
+
+	for i := 0; i < len(pan); i++ {
+		digit := int(pan[len(pan)-1-i] - '0')
+
+		if digit < 0 || digit > 9 {
+			return false
+		}
+
+		if i%2 == 1 {
+			digit *= 2
+
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+// TokenizePan returns a random token standing for pan, which keeps nothing
+// of it, and its last four digits. The pan must be valid.
+func TokenizePan(pan string) (string, string, error) {
+	token := make([]byte, 16)
+
+	if _, err := rand.Read(token); err != nil {
+		return "", "", err
+	}
+
+	return "tok_" + hex.EncodeToString(token), pan[len(pan)-4:], nil
+}
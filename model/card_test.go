@@ -0,0 +1,38 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePan(t *testing.T) {
+	assert.True(t, ValidatePan("4111111111111111"))
+	assert.True(t, ValidatePan("5555555555554444"))
+	assert.False(t, ValidatePan("4111111111111112"))
+	assert.False(t, ValidatePan("4111 1111 1111 1111"))
+	assert.False(t, ValidatePan("00000000000"))
+}
+
+func TestCardExpired(t *testing.T) {
+	card := Card{ExpiryMonth: 12, ExpiryYear: 2024}
+
+	assert.False(t, card.Expired(time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)))
+	assert.True(t, card.Expired(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestTokenizePan(t *testing.T) {
+	token, lastFour, err := TokenizePan("4111111111111111")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1111", lastFour)
+	assert.True(t, strings.HasPrefix(token, "tok_"))
+	assert.NotContains(t, token, "411111")
+
+	other, _, err := TokenizePan("4111111111111111")
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
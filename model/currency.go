@@ -0,0 +1,51 @@
+package model
+
+import (
+	"math"
+	"strconv"
+)
+
+// DEFAULT_CURRENCY bills the accounts opened without a currency.
+const DEFAULT_CURRENCY = "BRL"
+
+// currencyMinorUnits holds the ISO 4217 currencies taken, with the number
+// of decimals of their amounts.
+var currencyMinorUnits = map[string]int{
+	"AED": 2, "ARS": 2, "AUD": 2, "BHD": 3, "BOB": 2, "BRL": 2, "CAD": 2,
+	"CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CZK": 2, "DKK": 2, "EGP": 2,
+	"EUR": 2, "GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "LYD": 3,
+	"MXN": 2, "MYR": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PEN": 2, "PHP": 2,
+	"PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "SAR": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TND": 3, "TRY": 2, "TWD": 2, "UGX": 0, "USD": 2, "UYU": 2,
+	"VND": 0, "XAF": 0, "XOF": 0, "ZAR": 2,
+}
+
+func ValidateCurrency(currency string) bool {
+	_, ok := currencyMinorUnits[currency]
+
+	return ok
+}
+
+// MinorUnits returns the number of decimals of the amounts in currency.
+func MinorUnits(currency string) int {
+	return currencyMinorUnits[currency]
+}
+
+// ValidateCurrencyAmount reports whether amount has no more decimals than
+// currency takes, such as none for JPY and three for BHD.
+func ValidateCurrencyAmount(currency string, amount Amount) bool {
+	return amount.Decimals() <= MinorUnits(currency)
+}
+
+// RoundCurrencyAmount rounds amount half away from zero to the minor units
+// of currency.
+func RoundCurrencyAmount(currency string, amount float64) float64 {
+	scale := math.Pow10(MinorUnits(currency))
+
+	// Drops the binary error of the product, such as 3766.4999999999995 for
+	// 10 * 0.37665, before rounding its half.
+	scaled, _ := strconv.ParseFloat(strconv.FormatFloat(amount*scale, 'f', 6, 64), 64)
+
+	return math.Round(scaled) / scale
+}
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+const DISPUTE_STATUS_OPENED = "opened"
+const DISPUTE_STATUS_CREDITED = "provisional_credit_issued"
+const DISPUTE_STATUS_UNDER_REVIEW = "under_review"
+const DISPUTE_STATUS_WON = "won"
+const DISPUTE_STATUS_LOST = "lost"
+
+// DisputeDeadlines are how long a dispute may stay in each status that is
+// not final before it is escalated: the provisional credit is due 10 days
+// after the dispute is opened, the review starts 5 days after the credit
+// and the dispute is decided 45 days into the review.
+var DisputeDeadlines = map[string]time.Duration{
+	DISPUTE_STATUS_OPENED:       10 * 24 * time.Hour,
+	DISPUTE_STATUS_CREDITED:     5 * 24 * time.Hour,
+	DISPUTE_STATUS_UNDER_REVIEW: 45 * 24 * time.Hour,
+}
+
+// disputeTransitions are the statuses a dispute can move to from each
+// status.
+var disputeTransitions = map[string][]string{
+	DISPUTE_STATUS_OPENED:       {DISPUTE_STATUS_CREDITED},
+	DISPUTE_STATUS_CREDITED:     {DISPUTE_STATUS_UNDER_REVIEW},
+	DISPUTE_STATUS_UNDER_REVIEW: {DISPUTE_STATUS_WON, DISPUTE_STATUS_LOST},
+}
+
+// Dispute contests Amount of a purchase or withdraw, its TransactionId,
+// made to AccountId. Issuing the provisional credit posts
+// CreditTransactionId, a DISPUTE_CREDIT of Amount, which is kept when the
+// dispute is won and reversed when it is lost.
+//
+// DeadlineAt is when the dispute is due to leave its status, nil once it is
+// won or lost. Escalated is set when it is still in the status past the
+// deadline, and cleared as it moves on.
+type Dispute struct {
+	DisputeId           uint64     `json:"dispute_id"`
+	TransactionId       uint64     `json:"transaction_id"`
+	AccountId           uint64     `json:"account_id"`
+	Amount              Amount     `json:"amount"`
+	Reason              string     `json:"reason"`
+	Status              string     `json:"status"`
+	CreditTransactionId uint64     `json:"credit_transaction_id,omitempty"`
+	DeadlineAt          *time.Time `json:"deadline_at,omitempty"`
+	Escalated           bool       `json:"escalated"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+func (d Dispute) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// DisputeChange is an entry of the history of a dispute: the status it
+// moved to, or was escalated in, with the note given for it.
+type DisputeChange struct {
+	DisputeChangeId uint64    `json:"dispute_change_id"`
+	DisputeId       uint64    `json:"dispute_id"`
+	Status          string    `json:"status"`
+	Escalated       bool      `json:"escalated"`
+	Note            string    `json:"note,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// DisputeDeadline returns the deadline of a dispute moving to status at at,
+// nil when the status is final.
+func DisputeDeadline(status string, at time.Time) *time.Time {
+	period, ok := DisputeDeadlines[status]
+	if !ok {
+		return nil
+	}
+
+	deadline := at.Add(period)
+
+	return &deadline
+}
+
+// ValidateDisputeTransition reports whether a dispute can move from the
+// status from to the status to.
+func ValidateDisputeTransition(from string, to string) bool {
+	for _, status := range disputeTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ValidateDisputeStatus(status string) bool {
+	switch status {
+	case DISPUTE_STATUS_OPENED, DISPUTE_STATUS_CREDITED, DISPUTE_STATUS_UNDER_REVIEW, DISPUTE_STATUS_WON, DISPUTE_STATUS_LOST:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const EVENT_ACCOUNT_OPENED = "AccountOpened"
+const EVENT_TRANSACTION_POSTED = "TransactionPosted"
+const EVENT_TRANSACTION_REVERSED = "TransactionReversed"
+const EVENT_ACCOUNT_BLOCKED = "AccountBlocked"
+
+// Event is a change to an account, appended to its stream. The events of a
+// stream are numbered by Version from 1, and EventId orders the events of
+// every stream in the order they were appended. Data holds the Account of
+// AccountOpened, the Transaction of TransactionPosted and the
+// TransactionReversal of TransactionReversed.
+type Event struct {
+	EventId       uint64          `json:"event_id"`
+	AccountId     uint64          `json:"account_id"`
+	Version       uint64          `json:"version"`
+	Type          string          `json:"type"`
+	TransactionId uint64          `json:"transaction_id,omitempty"`
+	Data          json.RawMessage `json:"data"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// TransactionReversal cancels the amount of a posted transaction.
+type TransactionReversal struct {
+	TransactionId uint64 `json:"transaction_id"`
+	Amount        Amount `json:"amount"`
+}
+
+// NewEvent returns an event of the stream of accountId, its version is only
+// assigned once appended. transactionId is zero for the events of the
+// account itself.
+func NewEvent(eventType string, accountId uint64, transactionId uint64, data interface{}) (Event, error) {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		AccountId:     accountId,
+		Type:          eventType,
+		TransactionId: transactionId,
+		Data:          content,
+		// Milliseconds are kept by every storage, so a replay projects the
+		// same times.
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}, nil
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+const EXPORT_FORMAT_CSV = "csv"
+const EXPORT_FORMAT_OFX = "ofx"
+const EXPORT_FORMAT_PDF = "pdf"
+
+const EXPORT_PENDING = "pending"
+const EXPORT_COMPLETED = "completed"
+const EXPORT_FAILED = "failed"
+
+// Export is a statement of an account rendered in the background because it
+// was too large to be streamed in the response. From and To are nil when the
+// period is open on that side.
+type Export struct {
+	ExportId  uint64     `json:"export_id"`
+	AccountId uint64     `json:"account_id"`
+	Format    string     `json:"format"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+	Status    string     `json:"status"`
+	Rows      uint64     `json:"rows"`
+	Error     string     `json:"error,omitempty"`
+}
+
+func (e Export) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// StatementLine is a transaction as shown in a statement, with the
+// description of its operation type and the time it was posted.
+type StatementLine struct {
+	Transaction
+	Description string
+	CreatedAt   time.Time
+}
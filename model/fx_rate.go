@@ -0,0 +1,20 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+// FxRate is the price of one unit of BaseCurrency in QuoteCurrency from
+// EffectiveAt until the next rate of the same pair takes effect.
+type FxRate struct {
+	FxRateId      uint64    `json:"fx_rate_id"`
+	BaseCurrency  string    `json:"base_currency"`
+	QuoteCurrency string    `json:"quote_currency"`
+	Rate          float64   `json:"rate"`
+	EffectiveAt   time.Time `json:"effective_at"`
+}
+
+func (f FxRate) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
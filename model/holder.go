@@ -0,0 +1,100 @@
+package model
+
+import (
+	"net/http"
+	"net/mail"
+	"time"
+)
+
+// Address is where a holder lives. Country is an ISO 3166-1 alpha-2 code.
+type Address struct {
+	Street     string `json:"street"`
+	Number     string `json:"number"`
+	Complement string `json:"complement,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// Holder is the person behind the accounts, apart from the accounts
+// themselves. A holder owns any number of accounts, see Account.HolderId,
+// and may hold cards on accounts of other holders as an additional
+// cardholder, see Card.HolderId. BirthDate is a date, YYYY-MM-DD, and Phone
+// an E.164 number.
+//
+// Everything but the ID and the timestamps is personal data, encrypted by
+// the repositories before it is stored and left out of the audit log, see
+// Redacted.
+type Holder struct {
+	HolderId  uint64    `json:"holder_id"`
+	Name      string    `json:"name"`
+	BirthDate string    `json:"birth_date"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Address   Address   `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (h Holder) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Redacted returns the holder without its personal data, as recorded in the
+// audit log.
+func (h Holder) Redacted() Holder {
+	return Holder{HolderId: h.HolderId, CreatedAt: h.CreatedAt, UpdatedAt: h.UpdatedAt}
+}
+
+// ValidateEmail reports whether email is a bare address, such as
+// alice@example.com, with no display name.
+func ValidateEmail(email string) bool {
+	if len(email) > 254 {
+		return false
+	}
+
+	address, err := mail.ParseAddress(email)
+
+	return err == nil && address.Name == "" && address.Address == email
+}
+
+// ValidatePhone reports whether phone is an E.164 number: a plus sign and 8
+// to 15 digits, the first of which is not zero.
+func ValidatePhone(phone string) bool {
+	if len(phone) < 9 || len(phone) > 16 || phone[0] != '+' || phone[1] == '0' {
+		return false
+	}
+
+	for _, c := range phone[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateBirthDate reports whether birthDate is a date, YYYY-MM-DD, from
+// 1900 up to now.
+func ValidateBirthDate(birthDate string, now time.Time) bool {
+	date, err := time.Parse(time.DateOnly, birthDate)
+
+	return err == nil && date.Year() >= 1900 && !date.After(now)
+}
+
+// ValidatePostalCode reports whether postalCode is made of 3 to 10 letters,
+// digits, spaces and hyphens, which the postal codes of every country are.
+func ValidatePostalCode(postalCode string) bool {
+	if len(postalCode) < 3 || len(postalCode) > 10 {
+		return false
+	}
+
+	for _, c := range postalCode {
+		if !(c >= '0' && c <= '9') && !(c >= 'A' && c <= 'Z') && !(c >= 'a' && c <= 'z') && c != ' ' && c != '-' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEmailAndPhone(t *testing.T) {
+	assert.True(t, ValidateEmail("alice@example.com"))
+	assert.False(t, ValidateEmail("Alice <alice@example.com>"))
+	assert.False(t, ValidateEmail("alice"))
+	assert.False(t, ValidateEmail("alice@"))
+
+	assert.True(t, ValidatePhone("+5511987654321"))
+	assert.False(t, ValidatePhone("5511987654321"))
+	assert.False(t, ValidatePhone("+0511987654321"))
+	assert.False(t, ValidatePhone("+55 11 98765"))
+	assert.False(t, ValidatePhone("+1234567"))
+}
+
+func TestValidateBirthDateAndPostalCode(t *testing.T) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, ValidateBirthDate("1990-02-28", now))
+	assert.True(t, ValidateBirthDate("2024-03-01", now))
+	assert.False(t, ValidateBirthDate("2024-03-02", now))
+	assert.False(t, ValidateBirthDate("1899-12-31", now))
+	assert.False(t, ValidateBirthDate("1990-02-30", now))
+	assert.False(t, ValidateBirthDate("28/02/1990", now))
+
+	assert.True(t, ValidatePostalCode("01310-100"))
+	assert.True(t, ValidatePostalCode("SW1A 1AA"))
+	assert.False(t, ValidatePostalCode("01"))
+	assert.False(t, ValidatePostalCode("01310_100"))
+}
+
+func TestHolderRedacted(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	holder := Holder{HolderId: 1, Name: "Alice", BirthDate: "1990-02-28", Email: "alice@example.com", Phone: "+5511987654321", Address: Address{Street: "Av. Paulista"}, CreatedAt: at, UpdatedAt: at}
+
+	assert.Equal(t, Holder{HolderId: 1, CreatedAt: at, UpdatedAt: at}, holder.Redacted())
+}
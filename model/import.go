@@ -0,0 +1,39 @@
+package model
+
+import "net/http"
+
+const IMPORT_PENDING = "pending"
+const IMPORT_RUNNING = "running"
+const IMPORT_COMPLETED = "completed"
+const IMPORT_FAILED = "failed"
+
+const IMPORT_FORMAT_CSV = "csv"
+const IMPORT_FORMAT_JSONL = "jsonl"
+
+// Import is a file of transactions loaded in the background. ProcessedLines
+// is the last line of the file whose rows were saved, so an interrupted
+// import resumes right after it.
+type Import struct {
+	ImportId       uint64 `json:"import_id"`
+	Format         string `json:"format"`
+	Status         string `json:"status"`
+	ProcessedLines uint64 `json:"processed_lines"`
+	ImportedRows   uint64 `json:"imported_rows"`
+	RejectedRows   uint64 `json:"rejected_rows"`
+	Error          string `json:"error,omitempty"`
+	IdempotencyKey string `json:"-"`
+}
+
+func (i Import) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ImportRejection is a rule broken by a row of an import, a row breaking
+// many rules has one rejection per rule.
+type ImportRejection struct {
+	ImportRejectionId uint64 `json:"-"`
+	Line              uint64 `json:"line"`
+	Field             string `json:"field"`
+	Code              string `json:"code"`
+	Message           string `json:"message"`
+}
@@ -0,0 +1,133 @@
+package model
+
+import "strconv"
+
+const MERCHANT_CATEGORY_TRAVEL = "travel"
+const MERCHANT_CATEGORY_TRANSPORTATION = "transportation"
+const MERCHANT_CATEGORY_UTILITIES = "utilities"
+const MERCHANT_CATEGORY_GROCERIES = "groceries"
+const MERCHANT_CATEGORY_RESTAURANTS = "restaurants"
+const MERCHANT_CATEGORY_FUEL = "fuel"
+const MERCHANT_CATEGORY_RETAIL = "retail"
+const MERCHANT_CATEGORY_HEALTH = "health"
+const MERCHANT_CATEGORY_CASH = "cash"
+const MERCHANT_CATEGORY_FINANCIAL = "financial"
+const MERCHANT_CATEGORY_ENTERTAINMENT = "entertainment"
+const MERCHANT_CATEGORY_GAMBLING = "gambling"
+const MERCHANT_CATEGORY_SERVICES = "services"
+const MERCHANT_CATEGORY_GOVERNMENT = "government"
+const MERCHANT_CATEGORY_OTHER = "other"
+
+// mccRange is a range of merchant category codes, both ends included.
+type mccRange struct {
+	from     int
+	to       int
+	category string
+}
+
+// mccRanges maps the ISO 18245 merchant category codes to the categories.
+// The first range holding a code wins, so the single codes come before the
+// broad ranges they are carved out of.
+var mccRanges = []mccRange{
+	{4511, 4511, MERCHANT_CATEGORY_TRAVEL},
+	{4722, 4722, MERCHANT_CATEGORY_TRAVEL},
+	{5122, 5122, MERCHANT_CATEGORY_HEALTH},
+	{5411, 5411, MERCHANT_CATEGORY_GROCERIES},
+	{5422, 5422, MERCHANT_CATEGORY_GROCERIES},
+	{5441, 5441, MERCHANT_CATEGORY_GROCERIES},
+	{5451, 5451, MERCHANT_CATEGORY_GROCERIES},
+	{5462, 5462, MERCHANT_CATEGORY_GROCERIES},
+	{5499, 5499, MERCHANT_CATEGORY_GROCERIES},
+	{5541, 5542, MERCHANT_CATEGORY_FUEL},
+	{5983, 5983, MERCHANT_CATEGORY_FUEL},
+	{5812, 5814, MERCHANT_CATEGORY_RESTAURANTS},
+	{5912, 5912, MERCHANT_CATEGORY_HEALTH},
+	{6010, 6011, MERCHANT_CATEGORY_CASH},
+	{6051, 6051, MERCHANT_CATEGORY_CASH},
+	{7800, 7802, MERCHANT_CATEGORY_GAMBLING},
+	{7995, 7995, MERCHANT_CATEGORY_GAMBLING},
+	{9406, 9406, MERCHANT_CATEGORY_GAMBLING},
+	{742, 2999, MERCHANT_CATEGORY_SERVICES},
+	{3000, 3999, MERCHANT_CATEGORY_TRAVEL},
+	{4000, 4799, MERCHANT_CATEGORY_TRANSPORTATION},
+	{4800, 4999, MERCHANT_CATEGORY_UTILITIES},
+	{5000, 5999, MERCHANT_CATEGORY_RETAIL},
+	{6000, 6999, MERCHANT_CATEGORY_FINANCIAL},
+	{7832, 7832, MERCHANT_CATEGORY_ENTERTAINMENT},
+	{7841, 7841, MERCHANT_CATEGORY_ENTERTAINMENT},
+	{7911, 7999, MERCHANT_CATEGORY_ENTERTAINMENT},
+	{8011, 8099, MERCHANT_CATEGORY_HEALTH},
+	{7000, 8999, MERCHANT_CATEGORY_SERVICES},
+	{9000, 9999, MERCHANT_CATEGORY_GOVERNMENT},
+}
+
+// MerchantCategory returns the category of the merchant category code, or
+// "" when there is no code.
+func MerchantCategory(mcc string) string {
+	if mcc == "" {
+		return ""
+	}
+
+	code, err := strconv.Atoi(mcc)
+	if err != nil {
+		return MERCHANT_CATEGORY_OTHER
+	}
+
+	for _, r := range mccRanges {
+		if code >= r.from && code <= r.to {
+			return r.category
+		}
+	}
+
+	return MERCHANT_CATEGORY_OTHER
+}
+
+func ValidateMerchantCategory(category string) bool {
+	switch category {
+	case MERCHANT_CATEGORY_TRAVEL, MERCHANT_CATEGORY_TRANSPORTATION, MERCHANT_CATEGORY_UTILITIES,
+		MERCHANT_CATEGORY_GROCERIES, MERCHANT_CATEGORY_RESTAURANTS, MERCHANT_CATEGORY_FUEL,
+		MERCHANT_CATEGORY_RETAIL, MERCHANT_CATEGORY_HEALTH, MERCHANT_CATEGORY_CASH,
+		MERCHANT_CATEGORY_FINANCIAL, MERCHANT_CATEGORY_ENTERTAINMENT, MERCHANT_CATEGORY_GAMBLING,
+		MERCHANT_CATEGORY_SERVICES, MERCHANT_CATEGORY_GOVERNMENT, MERCHANT_CATEGORY_OTHER:
+		return true
+	}
+
+	return false
+}
+
+// ValidateMcc reports whether mcc is a merchant category code, four digits.
+func ValidateMcc(mcc string) bool {
+	if len(mcc) != 4 {
+		return false
+	}
+
+	for _, c := range mcc {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateCountry reports whether country is shaped like an ISO 3166-1
+// alpha-2 code, two upper-case letters.
+func ValidateCountry(country string) bool {
+	if len(country) != 2 {
+		return false
+	}
+
+	for _, c := range country {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsMerchantOperationType reports whether the transactions of the operation
+// type are made at a merchant: purchases and withdraws.
+func IsMerchantOperationType(operationTypeId uint32) bool {
+	return operationTypeId == CASH_PURCHASE || operationTypeId == INSTALLMENT_PURCHASE || operationTypeId == WITHDRAW
+}
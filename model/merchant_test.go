@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerchantCategory(t *testing.T) {
+	assert.Equal(t, "", MerchantCategory(""))
+	assert.Equal(t, MERCHANT_CATEGORY_GROCERIES, MerchantCategory("5411"))
+	assert.Equal(t, MERCHANT_CATEGORY_RESTAURANTS, MerchantCategory("5812"))
+	assert.Equal(t, MERCHANT_CATEGORY_RETAIL, MerchantCategory("5311"))
+	assert.Equal(t, MERCHANT_CATEGORY_TRAVEL, MerchantCategory("3001"))
+	assert.Equal(t, MERCHANT_CATEGORY_GAMBLING, MerchantCategory("7995"))
+	assert.Equal(t, MERCHANT_CATEGORY_ENTERTAINMENT, MerchantCategory("7996"))
+	assert.Equal(t, MERCHANT_CATEGORY_CASH, MerchantCategory("6011"))
+	assert.Equal(t, MERCHANT_CATEGORY_OTHER, MerchantCategory("0001"))
+}
+
+func TestValidateMccAndCountry(t *testing.T) {
+	assert.True(t, ValidateMcc("0742"))
+	assert.False(t, ValidateMcc("742"))
+	assert.False(t, ValidateMcc("58a2"))
+
+	assert.True(t, ValidateCountry("BR"))
+	assert.False(t, ValidateCountry("br"))
+	assert.False(t, ValidateCountry("BRA"))
+}
+
+func TestSpendRuleMatches(t *testing.T) {
+	assert.True(t, SpendRule{Mcc: "5812"}.Matches("5812"))
+	assert.False(t, SpendRule{Mcc: "5812"}.Matches("5813"))
+	assert.True(t, SpendRule{Category: MERCHANT_CATEGORY_RESTAURANTS}.Matches("5813"))
+	assert.False(t, SpendRule{Category: MERCHANT_CATEGORY_RESTAURANTS}.Matches(""))
+}
+
+func TestSpendRulePeriodOf(t *testing.T) {
+	at := time.Date(2024, 2, 29, 23, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+
+	from, to := SpendRule{Period: SPEND_PERIOD_DAY}.PeriodOf(at)
+
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), to)
+
+	from, to = SpendRule{Period: SPEND_PERIOD_MONTH}.PeriodOf(at)
+
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(t, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), to)
+}
@@ -5,6 +5,15 @@ const INSTALLMENT_PURCHASE = 2
 const WITHDRAW = 3
 const PAYMENT = 4
 
+// INTEREST and LATE_FEE are only posted by the accrual engine, clients
+// cannot create transactions of these types.
+const INTEREST = 5
+const LATE_FEE = 6
+
+// DISPUTE_CREDIT is the provisional credit of a dispute, only posted by the
+// disputes, see Dispute.
+const DISPUTE_CREDIT = 7
+
 func ValidateOperationType(operationTypeId uint32) bool {
 	for _, operationType := range getOperationTypes() {
 		if operationType == operationTypeId {
@@ -15,14 +24,20 @@ func ValidateOperationType(operationTypeId uint32) bool {
 	return false
 }
 
-func ValidateOperationTypeAmount(operationTypeId uint32, amount float32) bool {
+// ValidateScheduleOperationType also takes the system operation types, as
+// schedules are set up by the operators to post fees as well.
+func ValidateScheduleOperationType(operationTypeId uint32) bool {
+	return ValidateOperationType(operationTypeId) || operationTypeId == INTEREST || operationTypeId == LATE_FEE
+}
+
+func ValidateOperationTypeAmount(operationTypeId uint32, amount Amount) bool {
 	switch operationTypeId {
-	case CASH_PURCHASE, INSTALLMENT_PURCHASE, WITHDRAW:
-		if amount >= 0 {
+	case CASH_PURCHASE, INSTALLMENT_PURCHASE, WITHDRAW, INTEREST, LATE_FEE:
+		if amount.Sign() >= 0 {
 			return false
 		}
-	case PAYMENT:
-		if amount <= 0 {
+	case PAYMENT, DISPUTE_CREDIT:
+		if amount.Sign() <= 0 {
 			return false
 		}
 	}
@@ -38,3 +53,8 @@ func getOperationTypes() []uint32 {
 		PAYMENT,
 	}
 }
+
+type OperationType struct {
+	OperationTypeId uint32 `json:"operation_type_id"`
+	Description     string `json:"description"`
+}
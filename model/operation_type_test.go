@@ -31,6 +31,10 @@ func TestValidateOperationType(t *testing.T) {
 			5,
 			false,
 		},
+		{
+			6,
+			false,
+		},
 	}
 
 	for _, scenario := range scenarios {
@@ -45,47 +49,57 @@ func TestValidateOperationType(t *testing.T) {
 func TestValidateOperationTypeAmount(t *testing.T) {
 	var scenarios = []struct {
 		operationTypeId  uint32
-		amount           float32
+		amount           Amount
 		expectedResponse bool
 	}{
 		{
 			1,
-			-100.0,
+			MustParseAmount("-100"),
 			true,
 		},
 		{
 			2,
-			-100.0,
+			MustParseAmount("-100"),
 			true,
 		},
 		{
 			3,
-			-100.0,
+			MustParseAmount("-100"),
 			true,
 		},
 		{
 			4,
-			100.0,
+			MustParseAmount("100"),
 			true,
 		},
 		{
 			1,
-			100.0,
+			MustParseAmount("100"),
 			false,
 		},
 		{
 			2,
-			100.0,
+			MustParseAmount("100"),
 			false,
 		},
 		{
 			3,
-			100.0,
+			MustParseAmount("100"),
 			false,
 		},
 		{
 			4,
-			-100.0,
+			MustParseAmount("-100"),
+			false,
+		},
+		{
+			5,
+			MustParseAmount("-100"),
+			true,
+		},
+		{
+			6,
+			MustParseAmount("100"),
 			false,
 		},
 	}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+const RISK_OUTCOME_APPROVE = "approve"
+const RISK_OUTCOME_REVIEW = "review"
+const RISK_OUTCOME_DECLINE = "decline"
+
+// RiskRule declines a transaction, or flags it for review, when Expression
+// holds for it. Rules loaded from the configuration have no RiskRuleId.
+type RiskRule struct {
+	RiskRuleId uint64    `json:"risk_rule_id,omitempty"`
+	Name       string    `json:"name"`
+	Expression string    `json:"expression"`
+	Action     string    `json:"action"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (rule RiskRule) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// RiskCounters are the recent activity of an account the risk rules are
+// evaluated against. TransactionsMinute and SpentDay count the transactions
+// posted to the account in the minute and the day, in UTC, of the one
+// assessed, along with it, SpentDay in the billing currency of the account.
+// LastCountry is the merchant country of the latest transaction the rules
+// let through made at one.
+type RiskCounters struct {
+	OpenedAt           time.Time
+	TransactionsMinute int
+	SpentDay           float64
+	LastCountry        string
+}
+
+// RiskDecision records the outcome of the risk rules for a transaction
+// before it was posted, and the names of the rules that fired. Amount is as
+// sent, in the currency of the transaction.
+type RiskDecision struct {
+	RiskDecisionId  uint64    `json:"risk_decision_id"`
+	AccountId       uint64    `json:"account_id"`
+	OperationTypeId uint32    `json:"operation_type_id"`
+	Amount          Amount    `json:"amount"`
+	MerchantCountry string    `json:"merchant_country,omitempty"`
+	Outcome         string    `json:"outcome"`
+	Rules           []string  `json:"rules"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func (d RiskDecision) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func ValidateRiskAction(action string) bool {
+	return action == RISK_OUTCOME_REVIEW || action == RISK_OUTCOME_DECLINE
+}
+
+func ValidateRiskOutcome(outcome string) bool {
+	return outcome == RISK_OUTCOME_APPROVE || ValidateRiskAction(outcome)
+}
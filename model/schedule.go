@@ -0,0 +1,25 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+// Schedule posts a transaction of OperationTypeId and Amount to its account
+// at every occurrence of Recurrence, a cron expression or an RRULE, from
+// StartDate on and until EndDate when set. NextRunAt is the next occurrence
+// to post, nil once there is none left.
+type Schedule struct {
+	ScheduleId      uint64     `json:"schedule_id"`
+	AccountId       uint64     `json:"account_id"`
+	OperationTypeId uint32     `json:"operation_type_id"`
+	Amount          Amount     `json:"amount"`
+	Recurrence      string     `json:"recurrence"`
+	StartDate       time.Time  `json:"start_date"`
+	EndDate         *time.Time `json:"end_date,omitempty"`
+	NextRunAt       *time.Time `json:"next_run_at,omitempty"`
+}
+
+func (s Schedule) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"net/http"
+	"time"
+)
+
+const SPEND_RULE_ALLOW = "allow"
+const SPEND_RULE_DENY = "deny"
+const SPEND_RULE_CAP = "cap"
+
+const SPEND_PERIOD_DAY = "day"
+const SPEND_PERIOD_MONTH = "month"
+
+// SpendRule limits where the purchases and withdraws of an account are
+// made. Allow and deny rules name either an Mcc or a Category: once an
+// account allows any, its purchases at merchants allowed by none are turned
+// down, and the ones at denied merchants always are. Cap rules limit the
+// spend of a Category over a Period, a day or a calendar month in UTC, to
+// Limit in the currency of the account.
+type SpendRule struct {
+	SpendRuleId uint64    `json:"spend_rule_id"`
+	AccountId   uint64    `json:"account_id"`
+	Type        string    `json:"type"`
+	Mcc         string    `json:"mcc,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Limit       Amount    `json:"limit,omitzero"`
+	Period      string    `json:"period,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (s SpendRule) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Matches reports whether the allow or deny rule names the merchant
+// category code, or its category.
+func (s SpendRule) Matches(mcc string) bool {
+	if s.Mcc != "" {
+		return s.Mcc == mcc
+	}
+
+	return s.Category != "" && s.Category == MerchantCategory(mcc)
+}
+
+// PeriodOf returns the start and the end, excluded, of the period of the
+// cap rule holding at, in UTC.
+func (s SpendRule) PeriodOf(at time.Time) (time.Time, time.Time) {
+	at = at.UTC()
+
+	if s.Period == SPEND_PERIOD_MONTH {
+		start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+
+	return start, start.AddDate(0, 0, 1)
+}
+
+func ValidateSpendRuleType(ruleType string) bool {
+	return ruleType == SPEND_RULE_ALLOW || ruleType == SPEND_RULE_DENY || ruleType == SPEND_RULE_CAP
+}
+
+func ValidateSpendPeriod(period string) bool {
+	return period == SPEND_PERIOD_DAY || period == SPEND_PERIOD_MONTH
+}
@@ -1,12 +1,41 @@
 package model
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
+// Transaction is posted at CreatedAt, while EventDate is when it took place,
+// which can be earlier, such as a purchase settled the next day. Both are
+// set when the transaction is posted, EventDate defaulting to CreatedAt.
+//
+// Amount is in Currency, the billing currency of the account. A transaction
+// made in another currency keeps its OriginalAmount and OriginalCurrency,
+// converted at FxRate, the rate with ID FxRateId effective at EventDate.
+//
+// CardId is the card of the account the transaction was made with, if any.
+// Purchases and withdraws may name the merchant they were made at, its Mcc,
+// merchant category code, and MerchantCountry. Category is derived from the
+// Mcc, see MerchantCategory.
 type Transaction struct {
-	TransactionId   uint64  `json:"transaction_id"`
-	AccountId       uint64  `json:"account_id"`
-	OperationTypeId uint32  `json:"operation_type_id"`
-	Amount          float32 `json:"amount"`
+	TransactionId    uint64    `json:"transaction_id"`
+	AccountId        uint64    `json:"account_id"`
+	CardId           uint64    `json:"card_id,omitempty"`
+	OperationTypeId  uint32    `json:"operation_type_id"`
+	Amount           Amount    `json:"amount"`
+	Currency         string    `json:"currency,omitempty"`
+	OriginalAmount   Amount    `json:"original_amount,omitzero"`
+	OriginalCurrency string    `json:"original_currency,omitempty"`
+	FxRate           float64   `json:"fx_rate,omitempty"`
+	FxRateId         uint64    `json:"fx_rate_id,omitempty"`
+	MerchantName     string    `json:"merchant_name,omitempty"`
+	MerchantId       string    `json:"merchant_id,omitempty"`
+	Mcc              string    `json:"mcc,omitempty"`
+	MerchantCountry  string    `json:"merchant_country,omitempty"`
+	Category         string    `json:"category,omitempty"`
+	Reversed         bool      `json:"reversed,omitempty"`
+	EventDate        time.Time `json:"event_date"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 func (t Transaction) Render(w http.ResponseWriter, r *http.Request) error {
@@ -0,0 +1,47 @@
+// Package openapi embeds the OpenAPI document describing the REST API and
+// serves it along with a small documentation page.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// Spec returns the raw OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// Load parses and validates the OpenAPI document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(docs)
+}
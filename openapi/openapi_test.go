@@ -0,0 +1,20 @@
+package openapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+
+	if err != nil {
+		t.Fatalf("Expected the OpenAPI document to be valid but got %s", err)
+	}
+
+	// The requests and responses are validated by the rules of JSON Schema
+	// 2020-12 only for OpenAPI 3.1 documents.
+	if !doc.IsOpenAPI31OrLater() || !strings.HasPrefix(doc.OpenAPI, "3.1.") {
+		t.Errorf("Expected the OpenAPI document to declare version 3.1 but got %s", doc.OpenAPI)
+	}
+}
@@ -0,0 +1,350 @@
+package pii
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KMS keeps the master keys the data keys are wrapped with, never handing
+// them out, such as a key management service or LocalKMS.
+type KMS interface {
+	// CurrentKeyId names the master key new data keys are wrapped with.
+	CurrentKeyId() string
+	WrapKey(keyId string, dataKey []byte) ([]byte, error)
+	// UnwrapKey returns an error when wrapped was not wrapped under the
+	// master key keyId, or the master key is gone.
+	UnwrapKey(keyId string, wrapped []byte) ([]byte, error)
+}
+
+// DataKeyUses is how many values are encrypted under a data key before the
+// next one is generated.
+const DataKeyUses = 1 << 20
+
+const envelopePrefix = "v2:"
+
+// EnvelopeCipher encrypts with AES-256-GCM under data keys it generates,
+// wrapped by the current master key of the KMS. Every ciphertext carries the
+// ID of the master key and the wrapped data key, then the nonce followed by
+// the sealed value, each in base64 and separated by dots, prefixed with the
+// version of the format.
+//
+// A data key is used for DataKeyUses values, and the data keys are kept
+// unwrapped in memory, so the KMS is called once per data key rather than
+// once per value. The ciphertexts of AESCipher, written before the master
+// keys, are decrypted with legacy when it is not nil.
+type EnvelopeCipher struct {
+	kms    KMS
+	legacy *AESCipher
+
+	mu        sync.Mutex
+	current   *dataKey
+	unwrapped map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	keyId   string
+	wrapped string
+	aead    cipher.AEAD
+	uses    int
+}
+
+func NewEnvelopeCipher(kms KMS, legacy *AESCipher) *EnvelopeCipher {
+	return &EnvelopeCipher{
+		kms:       kms,
+		legacy:    legacy,
+		unwrapped: map[string]cipher.AEAD{},
+	}
+}
+
+func (c *EnvelopeCipher) KeyId() string {
+	return c.kms.CurrentKeyId()
+}
+
+func (c *EnvelopeCipher) Encrypt(plaintext string) (string, error) {
+	key, err := c.dataKey()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := encode(key.aead.Seal(nonce, nonce, []byte(plaintext), nil))
+
+	return envelopePrefix + encode([]byte(key.keyId)) + "." + key.wrapped + "." + sealed, nil
+}
+
+// dataKey returns the data key to encrypt the next value under, generating
+// a new one when the current one was used up or the master key rotated.
+func (c *EnvelopeCipher) dataKey() (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keyId := c.kms.CurrentKeyId()
+
+	if c.current != nil && c.current.keyId == keyId && c.current.uses < DataKeyUses {
+		c.current.uses++
+
+		return c.current, nil
+	}
+
+	plaintext, err := generateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := c.kms.WrapKey(keyId, plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping a data key under master key %s: %w", keyId, err)
+	}
+
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	c.current = &dataKey{keyId: keyId, wrapped: encode(wrapped), aead: aead, uses: 1}
+	c.unwrapped[keyId+"."+c.current.wrapped] = aead
+
+	return c.current, nil
+}
+
+func (c *EnvelopeCipher) Decrypt(ciphertext string) (string, error) {
+	if strings.HasPrefix(ciphertext, aesPrefix) && c.legacy != nil {
+		return c.legacy.Decrypt(ciphertext)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(ciphertext, envelopePrefix), ".")
+
+	if !strings.HasPrefix(ciphertext, envelopePrefix) || len(parts) != 3 {
+		return "", ErrInvalidCiphertext
+	}
+
+	aead, err := c.unwrap(parts[0], parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := decode(parts[2])
+
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+// unwrap returns the data key wrapped under the master key, both as found
+// in a ciphertext, asking the KMS the first time only.
+func (c *EnvelopeCipher) unwrap(encodedKeyId string, wrapped string) (cipher.AEAD, error) {
+	keyId, err := decode(encodedKeyId)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if aead, ok := c.unwrapped[string(keyId)+"."+wrapped]; ok {
+		return aead, nil
+	}
+
+	decoded, err := decode(wrapped)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	plaintext, err := c.kms.UnwrapKey(string(keyId), decoded)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping a data key under master key %s: %w", keyId, err)
+	}
+
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	c.unwrapped[string(keyId)+"."+wrapped] = aead
+
+	return aead, nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
+
+// LocalKMS wraps the data keys with AES-256-GCM under master keys it is
+// given, as read from a keyring file by LoadKeyring. The master keys are
+// named after their position, from "1", and the last one is the current
+// one.
+type LocalKMS struct {
+	aeads []cipher.AEAD
+}
+
+func NewLocalKMS(keys [][]byte) (*LocalKMS, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no master key")
+	}
+
+	kms := &LocalKMS{}
+
+	for _, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		kms.aeads = append(kms.aeads, aead)
+	}
+
+	return kms, nil
+}
+
+func (k *LocalKMS) CurrentKeyId() string {
+	return strconv.Itoa(len(k.aeads))
+}
+
+func (k *LocalKMS) WrapKey(keyId string, dataKey []byte) ([]byte, error) {
+	aead, err := k.masterKey(keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, dataKey, []byte(keyId)), nil
+}
+
+func (k *LocalKMS) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	aead, err := k.masterKey(keyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyId))
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+
+	return dataKey, nil
+}
+
+func (k *LocalKMS) masterKey(keyId string) (cipher.AEAD, error) {
+	position, err := strconv.Atoi(keyId)
+
+	if err != nil || position < 1 || position > len(k.aeads) {
+		return nil, fmt.Errorf("no master key %q in the keyring", keyId)
+	}
+
+	return k.aeads[position-1], nil
+}
+
+// LoadKeyring reads the master keys kept in the keyring file at path, one
+// base64 key per line, the oldest first. A missing file is an error, as for
+// LoadKeyFile: the keyring is created by AppendKey only. A key file of
+// CreateKeyFile is a keyring of one key.
+func LoadKeyring(path string) ([][]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := [][]byte{}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+
+		key, err := decodeKey(path, line)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyring file %s holds no key", path)
+	}
+
+	return keys, nil
+}
+
+// AppendKey adds a random master key to the keyring file at path, creating
+// it readable by the owner only when missing, and returns the ID of the key,
+// which becomes the current one.
+func AppendKey(path string) (string, error) {
+	keys := 0
+
+	content, err := os.ReadFile(path)
+
+	switch {
+	case err == nil:
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.TrimSpace(line) != "" {
+				keys++
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	}
+
+	key, err := generateKey()
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	line := base64.StdEncoding.EncodeToString(key) + "\n"
+
+	// A keyring written by hand may miss its last line break.
+	if len(content) > 0 && !strings.HasSuffix(string(content), "\n") {
+		line = "\n" + line
+	}
+
+	if _, err := file.WriteString(line); err != nil {
+		file.Close()
+
+		return "", err
+	}
+
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(keys + 1), nil
+}
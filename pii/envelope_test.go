@@ -0,0 +1,151 @@
+package pii
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/pii/piitest"
+)
+
+func TestEnvelopeCipher(t *testing.T) {
+	kms := piitest.NewKMS()
+	c := NewEnvelopeCipher(kms, nil)
+
+	assert.Equal(t, "1", c.KeyId())
+
+	first, err := c.Encrypt("alice@example.com")
+	require.NoError(t, err)
+
+	second, err := c.Encrypt("alice@example.com")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.NotContains(t, first, "alice")
+
+	kms.Rotate()
+	assert.Equal(t, "2", c.KeyId())
+
+	rotated, err := c.Encrypt("alice@example.com")
+	require.NoError(t, err)
+
+	// A cipher starting afresh unwraps the data keys of both master keys.
+	restarted := NewEnvelopeCipher(kms, nil)
+
+	for _, ciphertext := range []string{first, second, rotated} {
+		plaintext, err := restarted.Decrypt(ciphertext)
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.com", plaintext)
+	}
+
+	for _, ciphertext := range []string{"alice@example.com", "v2:", "v2:MQ.%%.AAAA", first[:len(first)-4] + "AAAA"} {
+		_, err := c.Decrypt(ciphertext)
+		assert.ErrorIs(t, err, ErrInvalidCiphertext, ciphertext)
+	}
+
+	kms.Retire("1")
+
+	_, err = NewEnvelopeCipher(kms, nil).Decrypt(first)
+	assert.Error(t, err)
+
+	plaintext, err := NewEnvelopeCipher(kms, nil).Decrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", plaintext)
+}
+
+func TestEnvelopeCipherDecryptsLegacyValues(t *testing.T) {
+	legacy, err := NewAESCipher(make([]byte, KeySize))
+	require.NoError(t, err)
+
+	ciphertext, err := legacy.Encrypt("Alice")
+	require.NoError(t, err)
+
+	plaintext, err := NewEnvelopeCipher(piitest.NewKMS(), legacy).Decrypt(ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", plaintext)
+
+	_, err = NewEnvelopeCipher(piitest.NewKMS(), nil).Decrypt(ciphertext)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+func TestLocalKMS(t *testing.T) {
+	kms, err := NewLocalKMS([][]byte{make([]byte, KeySize), []byte(strings.Repeat("k", KeySize))})
+	require.NoError(t, err)
+
+	assert.Equal(t, "2", kms.CurrentKeyId())
+
+	wrapped, err := kms.WrapKey("1", []byte("data key"))
+	require.NoError(t, err)
+
+	dataKey, err := kms.UnwrapKey("1", wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data key"), dataKey)
+
+	for _, keyId := range []string{"2", "3", "x"} {
+		_, err := kms.UnwrapKey(keyId, wrapped)
+		assert.Error(t, err, keyId)
+	}
+
+	_, err = NewLocalKMS(nil)
+	assert.Error(t, err)
+
+	_, err = NewLocalKMS([][]byte{make([]byte, 16)})
+	assert.Error(t, err)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii.key")
+
+	_, err := LoadKeyring(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	keyId, err := AppendKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, "1", keyId)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	generated, err := LoadKeyring(path)
+	require.NoError(t, err)
+	require.Len(t, generated, 1)
+
+	keyId, err = AppendKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2", keyId)
+
+	loaded, err := LoadKeyring(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, generated[0], loaded[0])
+
+	// A key file of CreateKeyFile, with no line break, is a keyring of one.
+	keyFile := filepath.Join(t.TempDir(), "pii.key")
+	require.NoError(t, CreateKeyFile(keyFile))
+
+	key, err := LoadKeyFile(keyFile)
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.TrimSpace(string(content))), 0o600))
+
+	keyId, err = AppendKey(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "2", keyId)
+
+	loaded, err = LoadKeyring(keyFile)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, key, loaded[0])
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0o600))
+
+	_, err = LoadKeyring(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,38 @@
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// BlindIndex computes the HMAC-SHA256 of personal data under a key of its
+// own, stored along with the encrypted value so rows can be looked up by
+// the value, such as the accounts by their document number, with nothing
+// of it in the clear. The index of a value is the same whatever the master
+// key, so its key is never rotated: doing so means recomputing every index
+// from the decrypted values.
+type BlindIndex struct {
+	key []byte
+}
+
+func NewBlindIndex(key []byte) (*BlindIndex, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must have %d bytes, got %d", KeySize, len(key))
+	}
+
+	return &BlindIndex{key: key}, nil
+}
+
+// Compute returns the hex index of value in column, the column keeping
+// equal values of different columns from having the same index.
+func (b *BlindIndex) Compute(column string, value string) string {
+	mac := hmac.New(sha256.New, b.key)
+
+	mac.Write([]byte(column))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
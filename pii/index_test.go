@@ -0,0 +1,29 @@
+package pii
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlindIndex(t *testing.T) {
+	index, err := NewBlindIndex(make([]byte, KeySize))
+	require.NoError(t, err)
+
+	other, err := NewBlindIndex([]byte(strings.Repeat("k", KeySize)))
+	require.NoError(t, err)
+
+	computed := index.Compute("accounts.document_number", "12345678")
+
+	assert.Len(t, computed, 64)
+	assert.NotContains(t, computed, "12345678")
+	assert.Equal(t, computed, index.Compute("accounts.document_number", "12345678"))
+	assert.NotEqual(t, computed, index.Compute("accounts.document_number", "12345679"))
+	assert.NotEqual(t, computed, index.Compute("holders.document_number", "12345678"))
+	assert.NotEqual(t, computed, other.Compute("accounts.document_number", "12345678"))
+
+	_, err = NewBlindIndex(make([]byte, 16))
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+// Package pii encrypts the personal data, such as the holders and the
+// document numbers of the accounts, before the repository adapters store it,
+// so a copy of the database reveals none of it without the keys. Values are
+// encrypted under data keys wrapped by master keys, see EnvelopeCipher, and
+// looked up by their blind index, see BlindIndex. The Rotator moves them
+// under the current master key once it is rotated.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of the keys, in bytes, for AES-256.
+const KeySize = 32
+
+// ErrInvalidCiphertext is returned when a value was not encrypted by the
+// cipher, was changed since, or was encrypted under another key.
+var ErrInvalidCiphertext = errors.New("value cannot be decrypted")
+
+// Cipher encrypts the values of the personal data columns. Encrypting the
+// same value twice gives different ciphertexts. KeyId names the master key
+// Encrypt encrypts under, which the rows are stored along with so the ones
+// under another key can be found and re-encrypted.
+type Cipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(ciphertext string) (string, error)
+	KeyId() string
+}
+
+// AESCipher encrypts with AES-256-GCM under a single key. Its ciphertexts
+// are the base64 of the nonce followed by the sealed value, prefixed with
+// the version of the format. It was the cipher of the holders before the
+// master keys, and is kept for EnvelopeCipher to read their values.
+type AESCipher struct {
+	aead cipher.AEAD
+}
+
+const aesPrefix = "v1:"
+
+func NewAESCipher(key []byte) (*AESCipher, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AESCipher{aead: aead}, nil
+}
+
+// newAEAD returns AES-256-GCM under key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must have %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// generateKey returns a random key of KeySize bytes.
+func generateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (c *AESCipher) Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return aesPrefix + base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+func (c *AESCipher) Decrypt(ciphertext string) (string, error) {
+	if !strings.HasPrefix(ciphertext, aesPrefix) {
+		return "", ErrInvalidCiphertext
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, aesPrefix))
+
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	return string(plaintext), nil
+}
+
+// LoadKeyFile reads the base64 key kept in the file at path, such as the
+// key of the blind indexes. A missing file is an error rather than a new
+// key, as every value encrypted or indexed under the lost one would be
+// unreadable: the file is created by CreateKeyFile only.
+func LoadKeyFile(path string) ([]byte, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeKey(path, strings.TrimSpace(string(encoded)))
+}
+
+// CreateKeyFile writes a random base64 key to a new file at path, readable
+// by the owner only. It fails when the file exists, so a key is never
+// overwritten.
+func CreateKeyFile(path string) error {
+	key, err := generateKey()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
+
+// decodeKey decodes a base64 key read from the file at path.
+func decodeKey(path string, encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s is not base64: %w", path, err)
+	}
+
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key file %s must hold keys of %d bytes, got %d", path, KeySize, len(key))
+	}
+
+	return key, nil
+}
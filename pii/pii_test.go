@@ -0,0 +1,71 @@
+package pii
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAESCipher(t *testing.T) {
+	c, err := NewAESCipher(make([]byte, KeySize))
+	require.NoError(t, err)
+
+	first, err := c.Encrypt("alice@example.com")
+	require.NoError(t, err)
+
+	second, err := c.Encrypt("alice@example.com")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.NotContains(t, first, "alice")
+
+	plaintext, err := c.Decrypt(first)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", plaintext)
+
+	other, err := NewAESCipher([]byte(strings.Repeat("k", KeySize)))
+	require.NoError(t, err)
+
+	for _, ciphertext := range []string{"alice@example.com", "v1:", "v1:%%", first[:len(first)-4] + "AAAA"} {
+		_, err := c.Decrypt(ciphertext)
+		assert.ErrorIs(t, err, ErrInvalidCiphertext, ciphertext)
+	}
+
+	_, err = other.Decrypt(first)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = NewAESCipher(make([]byte, 16))
+	assert.Error(t, err)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pii-index.key")
+
+	_, err := LoadKeyFile(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	require.NoError(t, CreateKeyFile(path))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	created, err := LoadKeyFile(path)
+	require.NoError(t, err)
+	assert.Len(t, created, KeySize)
+
+	assert.ErrorIs(t, CreateKeyFile(path), os.ErrExist)
+
+	loaded, err := LoadKeyFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, created, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("c2hvcnQ=\n"), 0o600))
+
+	_, err = LoadKeyFile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+// Package piitest provides a KMS for the tests, keeping its master keys in
+// memory.
+package piitest
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// KMS "wraps" the data keys by prefixing them with the ID of the master
+// key, which is enough for the tests and fast. It starts with master key
+// "1".
+type KMS struct {
+	mu      sync.Mutex
+	current int
+	retired map[string]bool
+}
+
+func NewKMS() *KMS {
+	return &KMS{current: 1, retired: map[string]bool{}}
+}
+
+// Rotate makes a new master key the current one and returns its ID.
+func (k *KMS) Rotate() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.current++
+
+	return strconv.Itoa(k.current)
+}
+
+// Retire makes the data keys wrapped under the master key keyId fail to
+// unwrap, as once the master key is deleted.
+func (k *KMS) Retire(keyId string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.retired[keyId] = true
+}
+
+func (k *KMS) CurrentKeyId() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return strconv.Itoa(k.current)
+}
+
+func (k *KMS) WrapKey(keyId string, dataKey []byte) ([]byte, error) {
+	return append([]byte(keyId+":"), dataKey...), nil
+}
+
+func (k *KMS) UnwrapKey(keyId string, wrapped []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.retired[keyId] || !bytes.HasPrefix(wrapped, []byte(keyId+":")) {
+		return nil, errors.New("no such master key")
+	}
+
+	return wrapped[len(keyId)+1:], nil
+}
@@ -0,0 +1,81 @@
+package pii
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const (
+	// rotateInterval is how often the rows are checked for values under
+	// an old master key.
+	rotateInterval = time.Hour
+
+	// rotateBatchSize is how many rows are re-encrypted per transaction.
+	rotateBatchSize = 100
+)
+
+// Rotator re-encrypts the personal data left under an old master key once
+// the master key is rotated, along with the values stored before they were
+// encrypted, so the old master key can be retired once it is done.
+type Rotator struct {
+	repositories []repository.PersonalDataRepository
+}
+
+func NewRotator(repositories ...repository.PersonalDataRepository) *Rotator {
+	return &Rotator{
+		repositories: repositories,
+	}
+}
+
+// Start re-encrypts the personal data until ctx is done.
+func (r *Rotator) Start(ctx context.Context) {
+	ticker := time.NewTicker(rotateInterval)
+	defer ticker.Stop()
+
+	for {
+		reencrypted, err := r.Rotate(ctx)
+
+		if err != nil {
+			log.Printf("Rotator#Start: Re-encrypting the personal data failed: %s", err)
+		}
+
+		if reencrypted > 0 {
+			log.Printf("Rotator#Start: Re-encrypted %d rows of personal data", reencrypted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Rotate re-encrypts every row left under an old master key, or in the
+// clear, and returns how many were re-encrypted.
+func (r *Rotator) Rotate(ctx context.Context) (int, error) {
+	reencrypted := 0
+
+	for _, repository := range r.repositories {
+		for {
+			count, err := repository.ReencryptPersonalData(ctx, rotateBatchSize)
+
+			reencrypted += count
+
+			if err != nil {
+				return reencrypted, err
+			}
+
+			// The rows re-encrypted are under the current master key,
+			// so the next batch holds the ones left.
+			if count < rotateBatchSize {
+				break
+			}
+		}
+	}
+
+	return reencrypted, nil
+}
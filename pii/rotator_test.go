@@ -0,0 +1,58 @@
+package pii
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRepository re-encrypts its rows as the repositories do, failing once
+// failAt rows are left when it is set.
+type stubRepository struct {
+	left   int
+	failAt int
+	calls  int
+}
+
+func (s *stubRepository) ReencryptPersonalData(ctx context.Context, limit int) (int, error) {
+	s.calls++
+
+	if s.failAt > 0 && s.left <= s.failAt {
+		return 0, errors.New("decrypting failed")
+	}
+
+	reencrypted := limit
+
+	if s.left < limit {
+		reencrypted = s.left
+	}
+
+	s.left -= reencrypted
+
+	return reencrypted, nil
+}
+
+func TestRotator(t *testing.T) {
+	accounts := &stubRepository{left: 2*rotateBatchSize + 5}
+	holders := &stubRepository{left: rotateBatchSize}
+
+	reencrypted, err := NewRotator(accounts, holders).Rotate(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3*rotateBatchSize+5, reencrypted)
+	assert.Equal(t, 3, accounts.calls)
+	assert.Equal(t, 2, holders.calls)
+
+	reencrypted, err = NewRotator(accounts, holders).Rotate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, reencrypted)
+
+	failing := &stubRepository{left: rotateBatchSize + 5, failAt: 5}
+
+	reencrypted, err = NewRotator(failing, holders).Rotate(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, rotateBatchSize, reencrypted)
+}
@@ -0,0 +1,81 @@
+// Package projector keeps the read models up to date with the events. The
+// repositories project the events of a command as soon as it commits, so
+// the projector only catches up with the ones they failed to, and rebuilds
+// the read models from scratch when asked to replay.
+package projector
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// pollInterval is how often the events left behind are looked for.
+const pollInterval = 10 * time.Second
+
+// BatchSize is how many events are projected at once.
+const BatchSize = 1000
+
+type Projector struct {
+	projections repository.ProjectionRepository
+}
+
+func NewProjector(projections repository.ProjectionRepository) *Projector {
+	return &Projector{
+		projections: projections,
+	}
+}
+
+// Start projects the pending events until ctx is done.
+func (p *Projector) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.RunPending(ctx); err != nil {
+			log.Printf("Projector#Start: Projecting events failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending projects the events until the read models caught up, and
+// returns how many events it projected.
+func (p *Projector) RunPending(ctx context.Context) (int, error) {
+	total := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		applied, err := p.projections.ProjectEvents(BatchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += applied
+
+		if applied < BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// Replay empties the read models and projects every event again. The read
+// models are incomplete until it returns, so it is meant to run while the
+// API is down.
+func (p *Projector) Replay(ctx context.Context) (int, error) {
+	if err := p.projections.ResetProjections(); err != nil {
+		return 0, err
+	}
+
+	return p.RunPending(ctx)
+}
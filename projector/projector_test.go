@@ -0,0 +1,70 @@
+package projector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+func TestReplayRebuildsTheReadModels(t *testing.T) {
+	store := memory.NewStore()
+	accounts := memory.NewAccountRepositoryMemory(store)
+	transactions := memory.NewTransactionRepositoryMemory(store)
+
+	account, err := accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+	require.NoError(t, err)
+
+	for _, transaction := range []model.Transaction{
+		{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50")},
+		{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("80.5")},
+	} {
+		_, err := transactions.CreateTransaction(context.Background(), transaction)
+		require.NoError(t, err)
+	}
+
+	_, err = transactions.ReverseTransaction(context.Background(), 1)
+	require.NoError(t, err)
+
+	_, err = accounts.BlockAccount(context.Background(), account.AccountId)
+	require.NoError(t, err)
+
+	balance, err := accounts.FindBalance(account.AccountId, time.Time{})
+	require.NoError(t, err)
+
+	listed, err := transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+	require.NoError(t, err)
+
+	replayed, err := NewProjector(memory.NewProjectionRepositoryMemory(store)).Replay(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, replayed)
+
+	rebuilt, err := accounts.FindBalance(account.AccountId, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, balance, rebuilt)
+	assert.Equal(t, &model.Balance{AccountId: account.AccountId, Balance: 80.5, Blocked: true, Version: 5}, rebuilt)
+
+	relisted, err := transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, listed, relisted)
+	assert.True(t, relisted[0].Reversed)
+
+	found, err := accounts.FindAccount(account.AccountId)
+	require.NoError(t, err)
+	assert.True(t, found.Blocked)
+}
+
+func TestRunPendingStopsWhenCaughtUp(t *testing.T) {
+	store := memory.NewStore()
+
+	applied, err := NewProjector(memory.NewProjectionRepositoryMemory(store)).RunPending(context.Background())
+
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+}
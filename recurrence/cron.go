@@ -0,0 +1,149 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a five-field cron expression: minute, hour, day of the month,
+// month and day of the week, from 0 for Sunday to 7, Sunday again. Fields
+// take "*", values, ranges such as "1-5", lists of them and steps such as
+// "*/15". Like cron, a day matches either of the day fields when both are
+// restricted.
+type cron struct {
+	start      time.Time
+	minutes    set
+	hours      set
+	days       set
+	months     set
+	weekdays   set
+	anyDay     bool
+	anyWeekday bool
+}
+
+func parseCron(expr string, start time.Time) (*cron, error) {
+	fields := strings.Fields(expr)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("a cron expression has 5 fields, got %d", len(fields))
+	}
+
+	c := &cron{start: start, anyDay: fields[2] == "*", anyWeekday: fields[4] == "*"}
+
+	bounds := []struct {
+		name     string
+		min, max int
+		set      *set
+	}{
+		{"minute", 0, 59, &c.minutes},
+		{"hour", 0, 23, &c.hours},
+		{"day of the month", 1, 31, &c.days},
+		{"month", 1, 12, &c.months},
+		{"day of the week", 0, 7, &c.weekdays},
+	}
+
+	for n, field := range fields {
+		values, err := parseCronField(field, bounds[n].min, bounds[n].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", bounds[n].name, field, err)
+		}
+
+		*bounds[n].set = values
+	}
+
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+
+	return c, nil
+}
+
+func parseCronField(field string, min int, max int) (set, error) {
+	values := set{}
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			parsed, err := strconv.Atoi(part[slash+1:])
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part[slash+1:])
+			}
+
+			step = parsed
+			part = part[:slash]
+		}
+
+		from, to := min, max
+
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+
+			to = from
+
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return nil, fmt.Errorf("values must be from %d to %d", min, max)
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	if t.Before(c.start) {
+		t = c.start.Add(-time.Nanosecond)
+	}
+
+	return nextDay(t, func(day time.Time) []time.Time {
+		if !c.months[int(day.Month())] || !c.matchesDay(day) {
+			return nil
+		}
+
+		occurrences := []time.Time{}
+
+		for _, hour := range c.hours.values(0, 23) {
+			for _, minute := range c.minutes.values(0, 59) {
+				occurrences = append(occurrences, day.Add(time.Duration(hour)*time.Hour+time.Duration(minute)*time.Minute))
+			}
+		}
+
+		return occurrences
+	})
+}
+
+func (c *cron) matchesDay(day time.Time) bool {
+	dayMatches := c.days[day.Day()]
+	weekdayMatches := c.weekdays[int(day.Weekday())]
+
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekdayMatches
+	case c.anyWeekday:
+		return dayMatches
+	}
+
+	return dayMatches || weekdayMatches
+}
@@ -0,0 +1,100 @@
+// Package recurrence computes the occurrences of the recurrences of the
+// schedules, written either as a five-field cron expression, such as
+// "0 9 1 * *", or as an RFC 5545 RRULE, such as "FREQ=MONTHLY;BYMONTHDAY=1".
+// Every time is in UTC and occurrences fall on whole minutes, or on the
+// second of the start for RRULEs.
+package recurrence
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// horizon is how far after a time its next occurrence is looked for. A
+// recurrence matching nothing sooner, such as the 30th of February, has no
+// occurrence.
+const horizon = 10 * 366 * 24 * time.Hour
+
+// ErrNoOccurrence is returned for recurrences that can never occur.
+var ErrNoOccurrence = errors.New("the recurrence never occurs")
+
+// Rule yields the occurrences of a recurrence from its start.
+type Rule interface {
+	// Next returns the first occurrence after t, or the zero time when
+	// there is none.
+	Next(t time.Time) time.Time
+}
+
+// Parse reads expr as an RRULE when it sets FREQ, optionally prefixed with
+// "RRULE:", and as a cron expression otherwise. No occurrence is before
+// start.
+func Parse(expr string, start time.Time) (Rule, error) {
+	expr = strings.TrimSpace(expr)
+	start = start.UTC()
+
+	var rule Rule
+	var err error
+
+	if strings.HasPrefix(strings.ToUpper(expr), "RRULE:") || strings.Contains(strings.ToUpper(expr), "FREQ=") {
+		rule, err = parseRRule(strings.TrimPrefix(strings.TrimPrefix(expr, "RRULE:"), "rrule:"), start)
+	} else {
+		rule, err = parseCron(expr, start)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.Next(start.Add(-time.Nanosecond)).IsZero() {
+		return nil, ErrNoOccurrence
+	}
+
+	return rule, nil
+}
+
+// Next returns the first occurrence of rule after t that is not after end,
+// or nil when there is none. A nil end leaves the recurrence open.
+func Next(rule Rule, t time.Time, end *time.Time) *time.Time {
+	next := rule.Next(t)
+
+	if next.IsZero() || (end != nil && next.After(*end)) {
+		return nil
+	}
+
+	return &next
+}
+
+// nextDay walks the days from the one of t, in UTC, calling occurrences
+// for each until it returns one after t.
+func nextDay(t time.Time, occurrences func(day time.Time) []time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	limit := t.Add(horizon)
+
+	for ; day.Before(limit); day = day.AddDate(0, 0, 1) {
+		for _, occurrence := range occurrences(day) {
+			if occurrence.After(t) {
+				return occurrence
+			}
+		}
+	}
+
+	return time.Time{}
+}
+
+// set is the values a field of a recurrence takes.
+type set map[int]bool
+
+// values returns the members of the set within min and max, in order.
+func (s set) values(min int, max int) []int {
+	values := []int{}
+
+	for value := min; value <= max; value++ {
+		if s[value] {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,90 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 31, 9, 30, 0, 0, time.UTC)
+
+// occurrences returns the first count occurrences of expr.
+func occurrences(t *testing.T, expr string, count int) []string {
+	rule, err := Parse(expr, start)
+	require.NoError(t, err, expr)
+
+	found := []string{}
+
+	for next := start.Add(-time.Nanosecond); len(found) < count; {
+		next = rule.Next(next)
+		require.False(t, next.IsZero(), expr)
+
+		found = append(found, next.Format("2006-01-02 15:04 Mon"))
+	}
+
+	return found
+}
+
+func TestCron(t *testing.T) {
+	scenarios := map[string][]string{
+		"0 9 1 * *":       {"2024-02-01 09:00 Thu", "2024-03-01 09:00 Fri", "2024-04-01 09:00 Mon"},
+		"*/20 10 * * *":   {"2024-01-31 10:00 Wed", "2024-01-31 10:20 Wed", "2024-01-31 10:40 Wed", "2024-02-01 10:00 Thu"},
+		"30 9 * * 1-5":    {"2024-01-31 09:30 Wed", "2024-02-01 09:30 Thu", "2024-02-02 09:30 Fri", "2024-02-05 09:30 Mon"},
+		"0 0 29 2 *":      {"2024-02-29 00:00 Thu", "2028-02-29 00:00 Tue"},
+		"0 12 13 * 5":     {"2024-02-02 12:00 Fri", "2024-02-09 12:00 Fri", "2024-02-13 12:00 Tue"},
+		"15,45 8-9 * 1 7": {"2025-01-05 08:15 Sun", "2025-01-05 08:45 Sun", "2025-01-05 09:15 Sun"},
+	}
+
+	for expr, expected := range scenarios {
+		assert.Equal(t, expected, occurrences(t, expr, len(expected)), expr)
+	}
+}
+
+func TestRRule(t *testing.T) {
+	scenarios := map[string][]string{
+		"FREQ=DAILY;INTERVAL=2":                       {"2024-01-31 09:30 Wed", "2024-02-02 09:30 Fri", "2024-02-04 09:30 Sun"},
+		"FREQ=WEEKLY;BYDAY=MO,FR;BYHOUR=8;BYMINUTE=0": {"2024-02-02 08:00 Fri", "2024-02-05 08:00 Mon", "2024-02-09 08:00 Fri"},
+		"RRULE:FREQ=WEEKLY;INTERVAL=2":                {"2024-01-31 09:30 Wed", "2024-02-14 09:30 Wed"},
+		"FREQ=MONTHLY":                                {"2024-01-31 09:30 Wed", "2024-03-31 09:30 Sun", "2024-05-31 09:30 Fri"},
+		"FREQ=MONTHLY;BYMONTHDAY=-1":                  {"2024-01-31 09:30 Wed", "2024-02-29 09:30 Thu", "2024-03-31 09:30 Sun"},
+		"FREQ=MONTHLY;INTERVAL=3;BYMONTHDAY=15":       {"2024-04-15 09:30 Mon", "2024-07-15 09:30 Mon"},
+		"FREQ=YEARLY;BYMONTH=6;BYMONTHDAY=1":          {"2024-06-01 09:30 Sat", "2025-06-01 09:30 Sun"},
+	}
+
+	for expr, expected := range scenarios {
+		assert.Equal(t, expected, occurrences(t, expr, len(expected)), expr)
+	}
+}
+
+func TestParseRejectsInvalidRecurrences(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 9 * *",
+		"60 9 * * *",
+		"0 9 32 * *",
+		"0 9 * * MON",
+		"*/0 * * * *",
+		"0 0 30 2 *",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=3",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"INTERVAL=2",
+	} {
+		_, err := Parse(expr, start)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestNextStopsAtTheEnd(t *testing.T) {
+	rule, err := Parse("FREQ=DAILY", start)
+	require.NoError(t, err)
+
+	end := start.AddDate(0, 0, 1)
+
+	assert.Equal(t, &end, Next(rule, start, &end))
+	assert.Nil(t, Next(rule, end, &end))
+	assert.NotNil(t, Next(rule, end, nil))
+}
@@ -0,0 +1,232 @@
+package recurrence
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequencies of an RRULE.
+const (
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+	freqYearly  = "YEARLY"
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// rrule supports the FREQ, INTERVAL, BYMONTH, BYMONTHDAY, BYDAY, BYHOUR and
+// BYMINUTE parts of an RRULE, BYMONTHDAY taking negative days counted from
+// the end of the month. The start stands for DTSTART: it sets the parts
+// left out, such as the time of the occurrences or the day of the month of
+// a monthly rule, and the periods INTERVAL counts from. The end of the
+// schedules replaces COUNT and UNTIL.
+type rrule struct {
+	start     time.Time
+	freq      string
+	interval  int
+	months    set
+	monthDays set
+	weekdays  set
+	hours     []int
+	minutes   []int
+}
+
+func parseRRule(expr string, start time.Time) (*rrule, error) {
+	r := &rrule{start: start, interval: 1}
+
+	for _, part := range strings.Split(expr, ";") {
+		name, value, ok := strings.Cut(strings.ToUpper(strings.TrimSpace(part)), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid RRULE part %q", part)
+		}
+
+		var err error
+
+		switch name {
+		case "FREQ":
+			if value != freqDaily && value != freqWeekly && value != freqMonthly && value != freqYearly {
+				return nil, fmt.Errorf("unsupported FREQ %q, use DAILY, WEEKLY, MONTHLY or YEARLY", value)
+			}
+
+			r.freq = value
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+
+			if err == nil && r.interval <= 0 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "BYMONTH":
+			r.months, err = parseRRuleList(value, 1, 12)
+		case "BYMONTHDAY":
+			r.monthDays, err = parseRRuleList(value, -31, 31)
+		case "BYDAY":
+			r.weekdays = set{}
+
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					err = fmt.Errorf("unknown day %q, use MO, TU, WE, TH, FR, SA or SU", day)
+					break
+				}
+
+				r.weekdays[int(weekday)] = true
+			}
+		case "BYHOUR":
+			var hours set
+
+			hours, err = parseRRuleList(value, 0, 23)
+			r.hours = hours.values(0, 23)
+		case "BYMINUTE":
+			var minutes set
+
+			minutes, err = parseRRuleList(value, 0, 59)
+			r.minutes = minutes.values(0, 59)
+		case "COUNT", "UNTIL":
+			return nil, fmt.Errorf("%s is not supported, set the end date of the schedule instead", name)
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", name)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+	}
+
+	if r.freq == "" {
+		return nil, fmt.Errorf("an RRULE must set FREQ")
+	}
+
+	r.setDefaults()
+
+	return r, nil
+}
+
+func parseRRuleList(value string, min int, max int) (set, error) {
+	values := set{}
+
+	for _, item := range strings.Split(value, ",") {
+		parsed, err := strconv.Atoi(item)
+
+		if err != nil || parsed < min || parsed > max || parsed == 0 && min < 0 {
+			return nil, fmt.Errorf("values must be from %d to %d", min, max)
+		}
+
+		values[parsed] = true
+	}
+
+	return values, nil
+}
+
+// setDefaults takes the parts left out from the start, as RFC 5545 does.
+func (r *rrule) setDefaults() {
+	if r.hours == nil {
+		r.hours = []int{r.start.Hour()}
+	}
+
+	if r.minutes == nil {
+		r.minutes = []int{r.start.Minute()}
+	}
+
+	switch r.freq {
+	case freqWeekly:
+		if r.weekdays == nil {
+			r.weekdays = set{int(r.start.Weekday()): true}
+		}
+	case freqMonthly:
+		if r.weekdays == nil && r.monthDays == nil {
+			r.monthDays = set{r.start.Day(): true}
+		}
+	case freqYearly:
+		if r.months == nil {
+			r.months = set{int(r.start.Month()): true}
+		}
+
+		if r.weekdays == nil && r.monthDays == nil {
+			r.monthDays = set{r.start.Day(): true}
+		}
+	}
+}
+
+func (r *rrule) Next(t time.Time) time.Time {
+	if t.Before(r.start) {
+		t = r.start.Add(-time.Nanosecond)
+	}
+
+	return nextDay(t, func(day time.Time) []time.Time {
+		if !r.inPeriod(day) || !r.matchesDay(day) {
+			return nil
+		}
+
+		occurrences := []time.Time{}
+
+		for _, hour := range r.hours {
+			for _, minute := range r.minutes {
+				occurrence := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(r.start.Second())*time.Second)
+
+				if !occurrence.Before(r.start) {
+					occurrences = append(occurrences, occurrence)
+				}
+			}
+		}
+
+		return occurrences
+	})
+}
+
+// inPeriod reports whether day falls in a period INTERVAL periods apart
+// from the one of the start. Weeks start on Monday.
+func (r *rrule) inPeriod(day time.Time) bool {
+	if r.interval == 1 {
+		return true
+	}
+
+	startDay := time.Date(r.start.Year(), r.start.Month(), r.start.Day(), 0, 0, 0, 0, time.UTC)
+
+	var periods int
+
+	switch r.freq {
+	case freqDaily:
+		periods = int(day.Sub(startDay).Hours() / 24)
+	case freqWeekly:
+		periods = int(mondayOf(day).Sub(mondayOf(startDay)).Hours() / 24 / 7)
+	case freqMonthly:
+		periods = (day.Year()-startDay.Year())*12 + int(day.Month()) - int(startDay.Month())
+	case freqYearly:
+		periods = day.Year() - startDay.Year()
+	}
+
+	return periods%r.interval == 0
+}
+
+func mondayOf(day time.Time) time.Time {
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+func (r *rrule) matchesDay(day time.Time) bool {
+	if r.months != nil && !r.months[int(day.Month())] {
+		return false
+	}
+
+	if r.weekdays != nil && !r.weekdays[int(day.Weekday())] {
+		return false
+	}
+
+	if r.monthDays != nil {
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+		return r.monthDays[day.Day()] || r.monthDays[day.Day()-daysInMonth-1]
+	}
+
+	return true
+}
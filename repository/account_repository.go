@@ -1,8 +1,41 @@
 package repository
 
-import "github.com/felipedsi/pismo-test/model"
+import (
+	"context"
+	"time"
 
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// AccountFilter narrows ListAccounts, zero fields are ignored.
+type AccountFilter struct {
+	DocumentNumber uint64
+	HolderId       uint64
+}
+
+// AccountRepository records every change in the audit log, with the
+// metadata of the request found in ctx. Accounts are created along with
+// their event stream, see EventRepository.
 type AccountRepository interface {
-	CreateAccount(account model.Account) (*model.Account, error)
+	// CreateAccount returns ErrForeignKeyViolation when the holder of the
+	// account does not exist.
+	CreateAccount(ctx context.Context, account model.Account) (*model.Account, error)
 	FindAccount(accountId uint64) (*model.Account, error)
+	// FindAccounts returns the accounts that exist among accountIds, in no
+	// particular order.
+	FindAccounts(accountIds []uint64) ([]model.Account, error)
+	ListAccounts(filter AccountFilter, page Page) ([]model.Account, error)
+	// BlockAccount appends AccountBlocked to the stream of the account,
+	// which then takes no more transactions. It returns ErrConflict when the
+	// account is already blocked.
+	BlockAccount(ctx context.Context, accountId uint64) (*model.Account, error)
+	// FindBalance returns the latest balance when asOf is zero, else the
+	// balance made of the events posted before asOf. It returns ErrNotFound
+	// when the account was not opened yet.
+	FindBalance(accountId uint64, asOf time.Time) (*model.Balance, error)
 }
+
+// BalanceSnapshotInterval is how many events of an account separate two
+// snapshots of its balance, which the balances as of a past instant are
+// computed from.
+const BalanceSnapshotInterval = 100
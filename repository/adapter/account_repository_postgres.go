@@ -1,50 +1,479 @@
 package adapter
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"time"
 
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/audit"
 	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
 )
 
+const accountColumns = "account_id, document_number, document_number_ciphertext, currency, blocked, holder_id"
+
+// documentNumberColumn names the document numbers in their blind index.
+const documentNumberColumn = "accounts.document_number"
+
+// sealedDocumentNumber is the document number of an account as stored,
+// encrypted under the master key keyId and indexed by index.
+type sealedDocumentNumber struct {
+	ciphertext string
+	index      string
+	keyId      string
+}
+
+func sealDocumentNumber(c pii.Cipher, index *pii.BlindIndex, documentNumber uint64) (*sealedDocumentNumber, error) {
+	plaintext := strconv.FormatUint(documentNumber, 10)
+
+	// The key is read first, so a value encrypted as the master key
+	// rotates is recorded under the old one and re-encrypted.
+	sealed := &sealedDocumentNumber{keyId: c.KeyId(), index: index.Compute(documentNumberColumn, plaintext)}
+
+	var err error
+
+	if sealed.ciphertext, err = c.Encrypt(plaintext); err != nil {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
+// documentNumberIndex returns the blind index the accounts with
+// documentNumber are looked up by, empty when it is 0.
+func documentNumberIndex(index *pii.BlindIndex, documentNumber uint64) string {
+	if documentNumber == 0 {
+		return ""
+	}
+
+	return index.Compute(documentNumberColumn, strconv.FormatUint(documentNumber, 10))
+}
+
 type AccountRepositoryPostgres struct {
-	db *sql.DB
+	db          *sql.DB
+	cipher      pii.Cipher
+	index       *pii.BlindIndex
+	projections *ProjectionRepositoryPostgres
 }
 
-func NewAccountRepositoryPostgres(db *sql.DB) *AccountRepositoryPostgres {
+func NewAccountRepositoryPostgres(db *sql.DB, cipher pii.Cipher, index *pii.BlindIndex) *AccountRepositoryPostgres {
 	return &AccountRepositoryPostgres{
-		db: db,
+		db:          db,
+		cipher:      cipher,
+		index:       index,
+		projections: NewProjectionRepositoryPostgres(db),
 	}
 }
 
-func (a *AccountRepositoryPostgres) CreateAccount(account model.Account) (*model.Account, error) {
-	query := "INSERT INTO accounts (document_number) VALUES ($1) RETURNING account_id"
+// scanAccount decrypts the document number of the account with c, unless
+// it was stored before the document numbers were encrypted.
+func scanAccount(c pii.Cipher, row interface{ Scan(...interface{}) error }) (*model.Account, error) {
+	account := model.Account{}
 
-	err := a.db.QueryRow(query, account.DocumentNumber).Scan(&account.AccountId)
+	var documentNumber, holderId sql.NullInt64
+	var ciphertext sql.NullString
 
+	err := row.Scan(&account.AccountId, &documentNumber, &ciphertext, &account.Currency, &account.Blocked, &holderId)
 	if err != nil {
-		log.Printf("AccountRepositoryPostgres#CreateAccount: Database query (%s) failed: %s", query, err)
-
 		return nil, err
 	}
 
+	account.DocumentNumber = uint64(documentNumber.Int64)
+	account.HolderId = uint64(holderId.Int64)
+
+	if ciphertext.Valid {
+		plaintext, err := c.Decrypt(ciphertext.String)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting the document number of account %d: %w", account.AccountId, err)
+		}
+
+		if account.DocumentNumber, err = strconv.ParseUint(plaintext, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+
 	return &account, nil
 }
 
-func (a *AccountRepositoryPostgres) FindAccount(accountId uint64) (*model.Account, error) {
-	account := model.Account{}
+func (a *AccountRepositoryPostgres) CreateAccount(ctx context.Context, account model.Account) (*model.Account, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Beginning transaction failed: %s", err)
 
-	query := "SELECT account_id, document_number FROM accounts WHERE account_id=$1 LIMIT 1"
+		return nil, translatePostgresError(err)
+	}
 
-	result := a.db.QueryRow(query, accountId)
+	defer tx.Rollback()
 
-	err := result.Scan(&account.AccountId, &account.DocumentNumber)
+	if account.Currency == "" {
+		account.Currency = model.DEFAULT_CURRENCY
+	}
 
+	sealed, err := sealDocumentNumber(a.cipher, a.index, account.DocumentNumber)
 	if err != nil {
-		log.Printf("AccountRepositoryPostgres#FindAccount: Database query (%s) failed: %s", query, err)
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Encrypting the document number failed: %s", err)
 
 		return nil, err
 	}
 
+	if err := checkHolderPostgres(tx, account.HolderId); err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Reading the holder failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	// The account is added by the projection, from the event opening it.
+	query := "SELECT nextval(pg_get_serial_sequence('accounts', 'account_id'))"
+
+	if err := tx.QueryRow(query).Scan(&account.AccountId); err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	event, err := openedEvent(account, sealed)
+
+	if err == nil {
+		err = appendEventsPostgres(tx, map[uint64]streamState{}, []model.Event{event})
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Appending events failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_ACCOUNT, account.AccountId, nil, account.Redacted())
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositoryPostgres#CreateAccount: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	catchUpProjections(a.projections, "AccountRepositoryPostgres#CreateAccount")
+
 	return &account, nil
 }
+
+// checkHolderPostgres stands in for the foreign key of the accounts table,
+// which is only checked once projected. The holder is kept from being
+// deleted until tx ends.
+func checkHolderPostgres(tx *sql.Tx, holderId uint64) error {
+	if holderId == 0 {
+		return nil
+	}
+
+	err := tx.QueryRow("SELECT holder_id FROM holders WHERE holder_id = $1 FOR SHARE", holderId).Scan(&holderId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrForeignKeyViolation
+	}
+
+	return err
+}
+
+func (a *AccountRepositoryPostgres) FindAccount(accountId uint64) (*model.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id=$1 LIMIT 1"
+
+	account, err := scanAccount(a.cipher, a.db.QueryRow(query, accountId))
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#FindAccount: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return account, nil
+}
+
+func (a *AccountRepositoryPostgres) FindAccounts(accountIds []uint64) ([]model.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id = ANY($1)"
+
+	ids := make([]int64, len(accountIds))
+
+	for i, accountId := range accountIds {
+		ids[i] = int64(accountId)
+	}
+
+	rows, err := a.db.Query(query, pq.Array(ids))
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#FindAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositoryPostgres#FindAccounts: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositoryPostgres) ListAccounts(filter repository.AccountFilter, page repository.Page) ([]model.Account, error) {
+	// The document numbers stored before they were encrypted are matched
+	// as is until the rotator encrypts them.
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id > $1 AND ($2 = 0 OR document_number_index = $5 OR document_number = $2) AND ($3 = 0 OR holder_id = $3) ORDER BY account_id LIMIT $4"
+
+	rows, err := a.db.Query(query, page.AfterId, filter.DocumentNumber, filter.HolderId, page.EffectiveLimit(), documentNumberIndex(a.index, filter.DocumentNumber))
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#ListAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositoryPostgres#ListAccounts: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositoryPostgres) BlockAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	var account *model.Account
+
+	err := retryAppendPostgres(ctx, func() (err error) {
+		account, err = a.blockAccount(ctx, accountId)
+
+		return err
+	})
+
+	return account, err
+}
+
+func (a *AccountRepositoryPostgres) blockAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#BlockAccount: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	account, err := blockAccountPostgres(ctx, tx, a.cipher, accountId)
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#BlockAccount: Appending events failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := blockEntry(ctx, *account)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#BlockAccount: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositoryPostgres#BlockAccount: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	catchUpProjections(a.projections, "AccountRepositoryPostgres#BlockAccount")
+
+	return account, nil
+}
+
+// ReencryptPersonalData re-encrypts the document numbers under the current
+// master key, locking the accounts so concurrent rotators skip them.
+func (a *AccountRepositoryPostgres) ReencryptPersonalData(ctx context.Context, limit int) (int, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Beginning transaction failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	keyId := a.cipher.KeyId()
+
+	query := "SELECT " + accountColumns + " FROM accounts WHERE pii_key_id IS DISTINCT FROM $1 ORDER BY account_id LIMIT $2 FOR UPDATE SKIP LOCKED"
+
+	rows, err := tx.Query(query, keyId, limit)
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			rows.Close()
+
+			log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Reading rows failed: %s", err)
+
+			return 0, translatePostgresError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Reading rows failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	query = "UPDATE accounts SET document_number = NULL, document_number_ciphertext = $2, document_number_index = $3, pii_key_id = $4 WHERE account_id = $1"
+
+	for _, account := range accounts {
+		sealed, err := sealDocumentNumber(a.cipher, a.index, account.DocumentNumber)
+		if err != nil {
+			log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Encrypting the document number failed: %s", err)
+
+			return 0, err
+		}
+
+		if _, err := tx.Exec(query, account.AccountId, sealed.ciphertext, sealed.index, sealed.keyId); err != nil {
+			log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+			return 0, translatePostgresError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositoryPostgres#ReencryptPersonalData: Committing transaction failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	return len(accounts), nil
+}
+
+// blockEntry returns the audit entry of the blocked account.
+func blockEntry(ctx context.Context, account model.Account) (model.AuditEntry, error) {
+	before := account.Redacted()
+	before.Blocked = false
+
+	return audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_ACCOUNT, account.AccountId, before, account.Redacted())
+}
+
+func (a *AccountRepositoryPostgres) FindBalance(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	if !asOf.IsZero() {
+		return a.findBalanceAsOf(accountId, asOf.UTC())
+	}
+
+	balance := model.Balance{}
+
+	query := "SELECT b.account_id, b.balance, a.blocked, b.version FROM account_balances b JOIN accounts a ON a.account_id = b.account_id WHERE b.account_id=$1"
+
+	err := a.db.QueryRow(query, accountId).Scan(&balance.AccountId, &balance.Balance, &balance.Blocked, &balance.Version)
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return &balance, nil
+}
+
+// findBalanceAsOf starts from the last snapshot taken before asOf and folds
+// the events that follow it, along with the AccountBlocked event the
+// snapshot does not record. Events are never changed once appended, so the
+// balance as of a past instant stays the same.
+func (a *AccountRepositoryPostgres) findBalanceAsOf(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	balance := model.Balance{AccountId: accountId, AsOf: &asOf}
+
+	query := "SELECT version, balance FROM balance_snapshots WHERE account_id = $1 AND created_at < $2 ORDER BY version DESC LIMIT 1"
+
+	err := a.db.QueryRow(query, accountId, asOf).Scan(&balance.Version, &balance.Balance)
+
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("AccountRepositoryPostgres#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	query = "SELECT " + eventColumns + " FROM events WHERE account_id = $1 AND created_at < $2 AND (version > $3 OR event_type = $4) ORDER BY version"
+
+	rows, err := a.db.Query(query, accountId, asOf, balance.Version, model.EVENT_ACCOUNT_BLOCKED)
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	events, err := scanEventsPostgres(rows)
+
+	if err == nil {
+		err = foldBalance(&balance, events)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositoryPostgres#FindBalance: Reading events failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if balance.Version == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &balance, nil
+}
@@ -0,0 +1,392 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// AccountRepositorySQLite stores the encrypted document numbers as the
+// Postgres one does, but for leaving document_number as 0 rather than
+// NULL, SQLite being unable to drop its NOT NULL.
+type AccountRepositorySQLite struct {
+	db          *sql.DB
+	cipher      pii.Cipher
+	index       *pii.BlindIndex
+	projections *ProjectionRepositorySQLite
+}
+
+func NewAccountRepositorySQLite(db *sql.DB, cipher pii.Cipher, index *pii.BlindIndex) *AccountRepositorySQLite {
+	return &AccountRepositorySQLite{
+		db:          db,
+		cipher:      cipher,
+		index:       index,
+		projections: NewProjectionRepositorySQLite(db),
+	}
+}
+
+func (a *AccountRepositorySQLite) CreateAccount(ctx context.Context, account model.Account) (*model.Account, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	if account.Currency == "" {
+		account.Currency = model.DEFAULT_CURRENCY
+	}
+
+	sealed, err := sealDocumentNumber(a.cipher, a.index, account.DocumentNumber)
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Encrypting the document number failed: %s", err)
+
+		return nil, err
+	}
+
+	if err := checkHolderSQLite(tx, account.HolderId); err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Reading the holder failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	// The account is added by the projection, from the event opening it,
+	// and its ID follows the last one opened.
+	query := "SELECT MAX(COALESCE((SELECT MAX(account_id) FROM accounts), 0), COALESCE((SELECT MAX(account_id) FROM events), 0)) + 1"
+
+	if err := tx.QueryRow(query).Scan(&account.AccountId); err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	event, err := openedEvent(account, sealed)
+
+	if err == nil {
+		err = appendEventsSQLite(tx, map[uint64]streamState{}, []model.Event{event})
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Appending events failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_ACCOUNT, account.AccountId, nil, account.Redacted())
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositorySQLite#CreateAccount: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	catchUpProjections(a.projections, "AccountRepositorySQLite#CreateAccount")
+
+	return &account, nil
+}
+
+// checkHolderSQLite stands in for the foreign key of the accounts table,
+// which is only checked once projected.
+func checkHolderSQLite(tx *sql.Tx, holderId uint64) error {
+	if holderId == 0 {
+		return nil
+	}
+
+	err := tx.QueryRow("SELECT holder_id FROM holders WHERE holder_id = ?", holderId).Scan(&holderId)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return repository.ErrForeignKeyViolation
+	}
+
+	return err
+}
+
+func (a *AccountRepositorySQLite) FindAccount(accountId uint64) (*model.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id=? LIMIT 1"
+
+	account, err := scanAccount(a.cipher, a.db.QueryRow(query, accountId))
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#FindAccount: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return account, nil
+}
+
+func (a *AccountRepositorySQLite) FindAccounts(accountIds []uint64) ([]model.Account, error) {
+	if len(accountIds) == 0 {
+		return []model.Account{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(accountIds)), ", ")
+
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id IN (" + placeholders + ")"
+
+	args := []interface{}{}
+
+	for _, accountId := range accountIds {
+		args = append(args, accountId)
+	}
+
+	rows, err := a.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#FindAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositorySQLite#FindAccounts: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositorySQLite) ListAccounts(filter repository.AccountFilter, page repository.Page) ([]model.Account, error) {
+	query := "SELECT " + accountColumns + " FROM accounts WHERE account_id > ?1 AND (?2 = 0 OR document_number_index = ?5 OR document_number = ?2) AND (?3 = 0 OR holder_id = ?3) ORDER BY account_id LIMIT ?4"
+
+	rows, err := a.db.Query(query, page.AfterId, filter.DocumentNumber, filter.HolderId, page.EffectiveLimit(), documentNumberIndex(a.index, filter.DocumentNumber))
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#ListAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositorySQLite#ListAccounts: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositorySQLite) BlockAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#BlockAccount: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	account, err := blockAccountSQLite(ctx, tx, a.cipher, accountId)
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#BlockAccount: Appending events failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := blockEntry(ctx, *account)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#BlockAccount: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositorySQLite#BlockAccount: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	catchUpProjections(a.projections, "AccountRepositorySQLite#BlockAccount")
+
+	return account, nil
+}
+
+// ReencryptPersonalData re-encrypts the document numbers under the current
+// master key. Writes are serialized by SQLite, so no row is locked.
+func (a *AccountRepositorySQLite) ReencryptPersonalData(ctx context.Context, limit int) (int, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Beginning transaction failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	keyId := a.cipher.KeyId()
+
+	query := "SELECT " + accountColumns + " FROM accounts WHERE pii_key_id IS NOT ?1 ORDER BY account_id LIMIT ?2"
+
+	rows, err := tx.Query(query, keyId, limit)
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	accounts := []model.Account{}
+
+	for rows.Next() {
+		account, err := scanAccount(a.cipher, rows)
+		if err != nil {
+			rows.Close()
+
+			log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Reading rows failed: %s", err)
+
+			return 0, translateSQLiteError(err)
+		}
+
+		accounts = append(accounts, *account)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Reading rows failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	query = "UPDATE accounts SET document_number = 0, document_number_ciphertext = ?2, document_number_index = ?3, pii_key_id = ?4 WHERE account_id = ?1"
+
+	for _, account := range accounts {
+		sealed, err := sealDocumentNumber(a.cipher, a.index, account.DocumentNumber)
+		if err != nil {
+			log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Encrypting the document number failed: %s", err)
+
+			return 0, err
+		}
+
+		if _, err := tx.Exec(query, account.AccountId, sealed.ciphertext, sealed.index, sealed.keyId); err != nil {
+			log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+			return 0, translateSQLiteError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("AccountRepositorySQLite#ReencryptPersonalData: Committing transaction failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	return len(accounts), nil
+}
+
+func (a *AccountRepositorySQLite) FindBalance(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	if !asOf.IsZero() {
+		return a.findBalanceAsOf(accountId, asOf.UTC())
+	}
+
+	balance := model.Balance{}
+
+	query := "SELECT b.account_id, b.balance, a.blocked, b.version FROM account_balances b JOIN accounts a ON a.account_id = b.account_id WHERE b.account_id=?"
+
+	err := a.db.QueryRow(query, accountId).Scan(&balance.AccountId, &balance.Balance, &balance.Blocked, &balance.Version)
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return &balance, nil
+}
+
+// findBalanceAsOf works like the Postgres one, comparing the times as text
+// as they are stored in the same layout.
+func (a *AccountRepositorySQLite) findBalanceAsOf(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	balance := model.Balance{AccountId: accountId, AsOf: &asOf}
+
+	query := "SELECT version, balance FROM balance_snapshots WHERE account_id = ?1 AND created_at < ?2 ORDER BY version DESC LIMIT 1"
+
+	err := a.db.QueryRow(query, accountId, sqliteTime(asOf)).Scan(&balance.Version, &balance.Balance)
+
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("AccountRepositorySQLite#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	query = "SELECT " + eventColumns + " FROM events WHERE account_id = ?1 AND created_at < ?2 AND (version > ?3 OR event_type = ?4) ORDER BY version"
+
+	rows, err := a.db.Query(query, accountId, sqliteTime(asOf), balance.Version, model.EVENT_ACCOUNT_BLOCKED)
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#FindBalance: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	events, err := scanEventsSQLite(rows)
+
+	if err == nil {
+		err = foldBalance(&balance, events)
+	}
+
+	if err != nil {
+		log.Printf("AccountRepositorySQLite#FindBalance: Reading events failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if balance.Version == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &balance, nil
+}
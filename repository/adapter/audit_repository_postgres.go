@@ -0,0 +1,112 @@
+package adapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const auditColumns = "audit_entry_id, action, entity_type, entity_id, actor, client_ip, request_id, snapshot_before, snapshot_after, created_at, prev_hash, hash"
+
+// auditInsertColumns are the columns written when appending an entry, all
+// but the ID.
+var auditInsertColumns = []string{"action", "entity_type", "entity_id", "actor", "client_ip", "request_id", "snapshot_before", "snapshot_after", "created_at", "prev_hash", "hash"}
+
+type AuditRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewAuditRepositoryPostgres(db *sql.DB) *AuditRepositoryPostgres {
+	return &AuditRepositoryPostgres{
+		db: db,
+	}
+}
+
+func auditRow(entry model.AuditEntry, createdAt interface{}) []interface{} {
+	return []interface{}{
+		entry.Action,
+		entry.EntityType,
+		entry.EntityId,
+		entry.Actor,
+		entry.ClientIP,
+		entry.RequestId,
+		string(entry.Before),
+		string(entry.After),
+		createdAt,
+		entry.PrevHash,
+		entry.Hash,
+	}
+}
+
+// appendAuditPostgres chains the entries to the end of the log and stores
+// them in tx. The table is locked until tx ends so concurrent changes are
+// chained one after the other.
+func appendAuditPostgres(tx *sql.Tx, entries ...model.AuditEntry) error {
+	if _, err := tx.Exec("LOCK TABLE audit_log IN EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var lastHash string
+
+	err := tx.QueryRow("SELECT hash FROM audit_log ORDER BY audit_entry_id DESC LIMIT 1").Scan(&lastHash)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	audit.Chain(lastHash, entries)
+
+	return copyRows(tx, pq.CopyIn("audit_log", auditInsertColumns...), len(entries), func(n int) []interface{} {
+		return auditRow(entries[n], entries[n].CreatedAt)
+	})
+}
+
+func (a *AuditRepositoryPostgres) ListAuditEntries(filter repository.AuditFilter, page repository.Page) ([]model.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_log WHERE audit_entry_id > $1
+		AND ($2 = '' OR entity_type = $2) AND ($3 = 0 OR entity_id = $3) AND ($4 = '' OR actor = $4) AND ($5 = '' OR request_id = $5)
+		ORDER BY audit_entry_id LIMIT $6`
+
+	rows, err := a.db.Query(query, page.AfterId, filter.EntityType, filter.EntityId, filter.Actor, filter.RequestId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("AuditRepositoryPostgres#ListAuditEntries: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+
+	for rows.Next() {
+		entry := model.AuditEntry{}
+
+		var before, after string
+
+		err := rows.Scan(&entry.AuditEntryId, &entry.Action, &entry.EntityType, &entry.EntityId, &entry.Actor, &entry.ClientIP, &entry.RequestId, &before, &after, &entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		entry.Before = json.RawMessage(before)
+		entry.After = json.RawMessage(after)
+		entry.CreatedAt = entry.CreatedAt.UTC()
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AuditRepositoryPostgres#ListAuditEntries: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return entries, nil
+}
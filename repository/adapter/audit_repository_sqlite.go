@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type AuditRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewAuditRepositorySQLite(db *sql.DB) *AuditRepositorySQLite {
+	return &AuditRepositorySQLite{
+		db: db,
+	}
+}
+
+// appendAuditSQLite chains the entries to the end of the log and stores
+// them in tx. OpenSQLite makes tx hold the write lock until it ends, so the
+// last hash read cannot change before it commits.
+func appendAuditSQLite(tx *sql.Tx, entries ...model.AuditEntry) error {
+	var lastHash string
+
+	err := tx.QueryRow("SELECT hash FROM audit_log ORDER BY audit_entry_id DESC LIMIT 1").Scan(&lastHash)
+
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	audit.Chain(lastHash, entries)
+
+	return insertRows(tx, "audit_log", auditInsertColumns, len(entries), func(n int) []interface{} {
+		return auditRow(entries[n], sqliteTime(entries[n].CreatedAt))
+	})
+}
+
+func (a *AuditRepositorySQLite) ListAuditEntries(filter repository.AuditFilter, page repository.Page) ([]model.AuditEntry, error) {
+	query := `SELECT ` + auditColumns + ` FROM audit_log WHERE audit_entry_id > ?1
+		AND (?2 = '' OR entity_type = ?2) AND (?3 = 0 OR entity_id = ?3) AND (?4 = '' OR actor = ?4) AND (?5 = '' OR request_id = ?5)
+		ORDER BY audit_entry_id LIMIT ?6`
+
+	rows, err := a.db.Query(query, page.AfterId, filter.EntityType, filter.EntityId, filter.Actor, filter.RequestId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("AuditRepositorySQLite#ListAuditEntries: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+
+	for rows.Next() {
+		entry := model.AuditEntry{}
+
+		var before, after string
+		var createdAt sql.NullString
+
+		err := rows.Scan(&entry.AuditEntryId, &entry.Action, &entry.EntityType, &entry.EntityId, &entry.Actor, &entry.ClientIP, &entry.RequestId, &before, &after, &createdAt, &entry.PrevHash, &entry.Hash)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		parsed, err := parseSQLiteTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Before = json.RawMessage(before)
+		entry.After = json.RawMessage(after)
+		entry.CreatedAt = *parsed
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("AuditRepositorySQLite#ListAuditEntries: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return entries, nil
+}
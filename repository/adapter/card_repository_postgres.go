@@ -0,0 +1,305 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/cardcontrol"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const cardColumns = "card_id, account_id, holder_id, pan_token, last_four, expiry_month, expiry_year, status, type, transaction_limit, daily_limit, replaced_by, created_at"
+
+type CardRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewCardRepositoryPostgres(db *sql.DB) *CardRepositoryPostgres {
+	return &CardRepositoryPostgres{
+		db: db,
+	}
+}
+
+func scanCard(row interface{ Scan(...interface{}) error }) (*model.Card, error) {
+	card := model.Card{}
+
+	var holderId, replacedBy sql.NullInt64
+
+	err := row.Scan(&card.CardId, &card.AccountId, &holderId, &card.PanToken, &card.LastFour, &card.ExpiryMonth, &card.ExpiryYear, &card.Status, &card.Type, &card.TransactionLimit, &card.DailyLimit, &replacedBy, &card.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	card.HolderId = uint64(holderId.Int64)
+	card.ReplacedBy = uint64(replacedBy.Int64)
+	card.CreatedAt = card.CreatedAt.UTC()
+
+	return &card, nil
+}
+
+// insertCardPostgres stores card in tx as issued now.
+func insertCardPostgres(tx *sql.Tx, card model.Card) (*model.Card, error) {
+	query := `INSERT INTO cards (account_id, holder_id, pan_token, last_four, expiry_month, expiry_year, status, type, transaction_limit, daily_limit, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING ` + cardColumns
+
+	return scanCard(tx.QueryRow(query, card.AccountId, nullId(card.HolderId), card.PanToken, card.LastFour, card.ExpiryMonth, card.ExpiryYear, card.Status, card.Type, card.TransactionLimit, card.DailyLimit, time.Now().UTC().Truncate(time.Millisecond)))
+}
+
+func (c *CardRepositoryPostgres) CreateCard(ctx context.Context, card model.Card) (*model.Card, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#CreateCard: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	created, err := insertCardPostgres(tx, card)
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#CreateCard: Inserting the card failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_CARD, created.CardId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#CreateCard: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("CardRepositoryPostgres#CreateCard: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (c *CardRepositoryPostgres) FindCard(cardId uint64) (*model.Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE card_id=$1"
+
+	card, err := scanCard(c.db.QueryRow(query, cardId))
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#FindCard: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return card, nil
+}
+
+func (c *CardRepositoryPostgres) ListCards(accountId uint64, page repository.Page) ([]model.Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE account_id=$1 AND card_id > $2 ORDER BY card_id LIMIT $3"
+
+	rows, err := c.db.Query(query, accountId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#ListCards: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	cards := []model.Card{}
+
+	for rows.Next() {
+		card, err := scanCard(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		cards = append(cards, *card)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("CardRepositoryPostgres#ListCards: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return cards, nil
+}
+
+func (c *CardRepositoryPostgres) UpdateCardStatus(ctx context.Context, cardId uint64, from []string, status string) (*model.Card, error) {
+	return c.changeCard(ctx, "UpdateCardStatus", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		if !cardStatusIn(before.Status, from) {
+			return nil, nil, repository.ErrConflict
+		}
+
+		updated, err := scanCard(tx.QueryRow("UPDATE cards SET status=$2 WHERE card_id=$1 RETURNING "+cardColumns, cardId, status))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, cardId, before, updated)
+
+		return updated, []model.AuditEntry{entry}, err
+	})
+}
+
+func (c *CardRepositoryPostgres) UpdateCardLimits(ctx context.Context, cardId uint64, transactionLimit model.Amount, dailyLimit model.Amount) (*model.Card, error) {
+	return c.changeCard(ctx, "UpdateCardLimits", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		updated, err := scanCard(tx.QueryRow("UPDATE cards SET transaction_limit=$2, daily_limit=$3 WHERE card_id=$1 RETURNING "+cardColumns, cardId, transactionLimit, dailyLimit))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, cardId, before, updated)
+
+		return updated, []model.AuditEntry{entry}, err
+	})
+}
+
+func (c *CardRepositoryPostgres) ReplaceCard(ctx context.Context, cardId uint64, replacement model.Card) (*model.Card, error) {
+	return c.changeCard(ctx, "ReplaceCard", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		if before.Status == model.CARD_STATUS_REPLACED {
+			return nil, nil, repository.ErrConflict
+		}
+
+		created, err := insertCardPostgres(tx, replacementCard(*before, replacement))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		replaced, err := scanCard(tx.QueryRow("UPDATE cards SET status=$2, replaced_by=$3 WHERE card_id=$1 RETURNING "+cardColumns, cardId, model.CARD_STATUS_REPLACED, created.CardId))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entries, err := replacementEntries(ctx, before, replaced, created)
+
+		return created, entries, err
+	})
+}
+
+// cardStatusIn reports whether status is one of statuses.
+func cardStatusIn(status string, statuses []string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// replacementCard is replacement issued in place of card, to its account
+// and holder with its type and limits.
+func replacementCard(card model.Card, replacement model.Card) model.Card {
+	replacement.AccountId = card.AccountId
+	replacement.HolderId = card.HolderId
+	replacement.Type = card.Type
+	replacement.TransactionLimit = card.TransactionLimit
+	replacement.DailyLimit = card.DailyLimit
+	replacement.Status = model.CARD_STATUS_ACTIVE
+
+	return replacement
+}
+
+// replacementEntries records the card replaced, before and after, and its
+// replacement.
+func replacementEntries(ctx context.Context, before *model.Card, replaced *model.Card, created *model.Card) ([]model.AuditEntry, error) {
+	replacedEntry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, replaced.CardId, before, replaced)
+	if err != nil {
+		return nil, err
+	}
+
+	createdEntry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_CARD, created.CardId, nil, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return []model.AuditEntry{createdEntry, replacedEntry}, nil
+}
+
+// changeCard runs change on the card, locked in a transaction, and records
+// the entries it returns in the audit log.
+func (c *CardRepositoryPostgres) changeCard(ctx context.Context, method string, cardId uint64, change func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error)) (*model.Card, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#%s: Beginning transaction failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + cardColumns + " FROM cards WHERE card_id=$1 FOR UPDATE"
+
+	before, err := scanCard(tx.QueryRow(query, cardId))
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	changed, entries, err := change(tx, before)
+
+	if err != nil {
+		log.Printf("CardRepositoryPostgres#%s: Changing the card failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := appendAuditPostgres(tx, entries...); err != nil {
+		log.Printf("CardRepositoryPostgres#%s: Appending to the audit log failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("CardRepositoryPostgres#%s: Committing transaction failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return changed, nil
+}
+
+// checkCardsPostgres applies the spend controls of the cards of the
+// transactions, read in tx, see the cardcontrol package. The cards are
+// locked until tx ends, so they cannot be blocked or replaced before the
+// transactions are posted.
+func checkCardsPostgres(tx *sql.Tx, transactions []model.Transaction) error {
+	findCard := func(cardId uint64) (*model.Card, error) {
+		card, err := scanCard(tx.QueryRow("SELECT "+cardColumns+" FROM cards WHERE card_id=$1 FOR SHARE", cardId))
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		return card, nil
+	}
+
+	return cardcontrol.CheckTransactions(transactions, findCard, func(card model.Card, day time.Time) (model.Amount, error) {
+		var spent model.Amount
+
+		err := tx.QueryRow(daySpendQuery, card.AccountId, model.EVENT_TRANSACTION_POSTED, strconv.FormatUint(card.CardId, 10), day.Format(time.DateOnly), model.EVENT_TRANSACTION_REVERSED).Scan(&spent)
+
+		return spent, err
+	})
+}
+
+// daySpendQuery sums the purchases and withdraws of a card on a day, in
+// UTC, that were not reversed. They are read from the events, which the
+// projection may not have applied yet.
+const daySpendQuery = `SELECT COALESCE(SUM(-(p.data->>'amount')::NUMERIC), 0) FROM events p
+	WHERE p.account_id = $1 AND p.event_type = $2 AND p.data->>'card_id' = $3 AND (p.data->>'amount')::NUMERIC < 0
+	AND ((p.data->>'event_date')::TIMESTAMPTZ AT TIME ZONE 'UTC')::DATE = $4::DATE
+	AND NOT EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = $5)`
@@ -0,0 +1,261 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/cardcontrol"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type CardRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewCardRepositorySQLite(db *sql.DB) *CardRepositorySQLite {
+	return &CardRepositorySQLite{
+		db: db,
+	}
+}
+
+func scanCardSQLite(row interface{ Scan(...interface{}) error }) (*model.Card, error) {
+	card := model.Card{}
+
+	var holderId, replacedBy sql.NullInt64
+	var createdAt string
+
+	err := row.Scan(&card.CardId, &card.AccountId, &holderId, &card.PanToken, &card.LastFour, &card.ExpiryMonth, &card.ExpiryYear, &card.Status, &card.Type, &card.TransactionLimit, &card.DailyLimit, &replacedBy, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	card.HolderId = uint64(holderId.Int64)
+	card.ReplacedBy = uint64(replacedBy.Int64)
+
+	if card.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &card, nil
+}
+
+// insertCardSQLite stores card in tx as issued now.
+func insertCardSQLite(tx *sql.Tx, card model.Card) (*model.Card, error) {
+	query := `INSERT INTO cards (account_id, holder_id, pan_token, last_four, expiry_month, expiry_year, status, type, transaction_limit, daily_limit, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11) RETURNING ` + cardColumns
+
+	return scanCardSQLite(tx.QueryRow(query, card.AccountId, nullId(card.HolderId), card.PanToken, card.LastFour, card.ExpiryMonth, card.ExpiryYear, card.Status, card.Type, card.TransactionLimit, card.DailyLimit, sqliteTime(time.Now())))
+}
+
+func (c *CardRepositorySQLite) CreateCard(ctx context.Context, card model.Card) (*model.Card, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("CardRepositorySQLite#CreateCard: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	created, err := insertCardSQLite(tx, card)
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#CreateCard: Inserting the card failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_CARD, created.CardId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#CreateCard: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("CardRepositorySQLite#CreateCard: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (c *CardRepositorySQLite) FindCard(cardId uint64) (*model.Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE card_id=?1"
+
+	card, err := scanCardSQLite(c.db.QueryRow(query, cardId))
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#FindCard: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return card, nil
+}
+
+func (c *CardRepositorySQLite) ListCards(accountId uint64, page repository.Page) ([]model.Card, error) {
+	query := "SELECT " + cardColumns + " FROM cards WHERE account_id=?1 AND card_id > ?2 ORDER BY card_id LIMIT ?3"
+
+	rows, err := c.db.Query(query, accountId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#ListCards: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	cards := []model.Card{}
+
+	for rows.Next() {
+		card, err := scanCardSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		cards = append(cards, *card)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("CardRepositorySQLite#ListCards: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return cards, nil
+}
+
+func (c *CardRepositorySQLite) UpdateCardStatus(ctx context.Context, cardId uint64, from []string, status string) (*model.Card, error) {
+	return c.changeCard(ctx, "UpdateCardStatus", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		if !cardStatusIn(before.Status, from) {
+			return nil, nil, repository.ErrConflict
+		}
+
+		updated, err := scanCardSQLite(tx.QueryRow("UPDATE cards SET status=?2 WHERE card_id=?1 RETURNING "+cardColumns, cardId, status))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, cardId, before, updated)
+
+		return updated, []model.AuditEntry{entry}, err
+	})
+}
+
+func (c *CardRepositorySQLite) UpdateCardLimits(ctx context.Context, cardId uint64, transactionLimit model.Amount, dailyLimit model.Amount) (*model.Card, error) {
+	return c.changeCard(ctx, "UpdateCardLimits", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		updated, err := scanCardSQLite(tx.QueryRow("UPDATE cards SET transaction_limit=?2, daily_limit=?3 WHERE card_id=?1 RETURNING "+cardColumns, cardId, transactionLimit, dailyLimit))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, cardId, before, updated)
+
+		return updated, []model.AuditEntry{entry}, err
+	})
+}
+
+func (c *CardRepositorySQLite) ReplaceCard(ctx context.Context, cardId uint64, replacement model.Card) (*model.Card, error) {
+	return c.changeCard(ctx, "ReplaceCard", cardId, func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error) {
+		if before.Status == model.CARD_STATUS_REPLACED {
+			return nil, nil, repository.ErrConflict
+		}
+
+		created, err := insertCardSQLite(tx, replacementCard(*before, replacement))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		replaced, err := scanCardSQLite(tx.QueryRow("UPDATE cards SET status=?2, replaced_by=?3 WHERE card_id=?1 RETURNING "+cardColumns, cardId, model.CARD_STATUS_REPLACED, created.CardId))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entries, err := replacementEntries(ctx, before, replaced, created)
+
+		return created, entries, err
+	})
+}
+
+// changeCard runs change on the card in a transaction, which SQLite runs
+// alone, and records the entries it returns in the audit log.
+func (c *CardRepositorySQLite) changeCard(ctx context.Context, method string, cardId uint64, change func(tx *sql.Tx, before *model.Card) (*model.Card, []model.AuditEntry, error)) (*model.Card, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("CardRepositorySQLite#%s: Beginning transaction failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + cardColumns + " FROM cards WHERE card_id=?1"
+
+	before, err := scanCardSQLite(tx.QueryRow(query, cardId))
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	changed, entries, err := change(tx, before)
+
+	if err != nil {
+		log.Printf("CardRepositorySQLite#%s: Changing the card failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := appendAuditSQLite(tx, entries...); err != nil {
+		log.Printf("CardRepositorySQLite#%s: Appending to the audit log failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("CardRepositorySQLite#%s: Committing transaction failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return changed, nil
+}
+
+// checkCardsSQLite applies the spend controls of the cards of the
+// transactions, read in tx, see the cardcontrol package.
+func checkCardsSQLite(tx *sql.Tx, transactions []model.Transaction) error {
+	findCard := func(cardId uint64) (*model.Card, error) {
+		card, err := scanCardSQLite(tx.QueryRow("SELECT "+cardColumns+" FROM cards WHERE card_id=?1", cardId))
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		return card, nil
+	}
+
+	return cardcontrol.CheckTransactions(transactions, findCard, func(card model.Card, day time.Time) (model.Amount, error) {
+		query := `SELECT COALESCE(SUM(-json_extract(p.data, '$.amount')), 0) FROM events p
+			WHERE p.account_id = ?1 AND p.event_type = ?2 AND json_extract(p.data, '$.card_id') = ?3 AND json_extract(p.data, '$.amount') < 0
+			AND date(json_extract(p.data, '$.event_date')) = ?4
+			AND NOT EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = ?5)`
+
+		var spent model.Amount
+
+		err := tx.QueryRow(query, card.AccountId, model.EVENT_TRANSACTION_POSTED, card.CardId, day.Format(time.DateOnly), model.EVENT_TRANSACTION_REVERSED).Scan(&spent)
+
+		return spent, err
+	})
+}
@@ -0,0 +1,404 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/dispute"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const disputeColumns = "dispute_id, transaction_id, account_id, amount, reason, status, credit_transaction_id, deadline_at, escalated, created_at, updated_at"
+
+const disputeChangeColumns = "dispute_change_id, dispute_id, status, escalated, note, created_at"
+
+type DisputeRepositoryPostgres struct {
+	db          *sql.DB
+	projections *ProjectionRepositoryPostgres
+}
+
+func NewDisputeRepositoryPostgres(db *sql.DB) *DisputeRepositoryPostgres {
+	return &DisputeRepositoryPostgres{
+		db:          db,
+		projections: NewProjectionRepositoryPostgres(db),
+	}
+}
+
+func scanDisputePostgres(row interface{ Scan(...interface{}) error }) (*model.Dispute, error) {
+	d := model.Dispute{}
+
+	var creditTransactionId sql.NullInt64
+	var deadlineAt sql.NullTime
+
+	err := row.Scan(&d.DisputeId, &d.TransactionId, &d.AccountId, &d.Amount, &d.Reason, &d.Status, &creditTransactionId, &deadlineAt, &d.Escalated, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	d.CreditTransactionId = uint64(creditTransactionId.Int64)
+	d.DeadlineAt = timePointer(deadlineAt)
+
+	d.CreatedAt = d.CreatedAt.UTC()
+	d.UpdatedAt = d.UpdatedAt.UTC()
+
+	return &d, nil
+}
+
+func scanDisputeChangePostgres(row interface{ Scan(...interface{}) error }) (*model.DisputeChange, error) {
+	change := model.DisputeChange{}
+
+	err := row.Scan(&change.DisputeChangeId, &change.DisputeId, &change.Status, &change.Escalated, &change.Note, &change.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	change.CreatedAt = change.CreatedAt.UTC()
+
+	return &change, nil
+}
+
+// disputeChange returns the entry of the history recording that dispute
+// changed, as it is now.
+func disputeChange(d model.Dispute, note string) model.DisputeChange {
+	return model.DisputeChange{DisputeId: d.DisputeId, Status: d.Status, Escalated: d.Escalated, Note: note, CreatedAt: d.UpdatedAt}
+}
+
+func insertDisputeChangePostgres(tx *sql.Tx, change model.DisputeChange) error {
+	_, err := tx.Exec("INSERT INTO dispute_changes (dispute_id, status, escalated, note, created_at) VALUES ($1, $2, $3, $4, $5)",
+		change.DisputeId, change.Status, change.Escalated, change.Note, change.CreatedAt)
+
+	return err
+}
+
+// updateDisputePostgres stores the status, credit, deadline and escalation
+// of d in tx, along with its history and audit log.
+func updateDisputePostgres(ctx context.Context, tx *sql.Tx, before model.Dispute, d model.Dispute, note string) error {
+	_, err := tx.Exec("UPDATE disputes SET status=$2, credit_transaction_id=$3, deadline_at=$4, escalated=$5, updated_at=$6 WHERE dispute_id=$1",
+		d.DisputeId, d.Status, nullId(d.CreditTransactionId), nullTimePointer(d.DeadlineAt), d.Escalated, d.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertDisputeChangePostgres(tx, disputeChange(d, note)); err != nil {
+		return err
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_DISPUTE, d.DisputeId, before, d)
+	if err != nil {
+		return err
+	}
+
+	return appendAuditPostgres(tx, entry)
+}
+
+func (s *DisputeRepositoryPostgres) OpenDispute(ctx context.Context, d model.Dispute) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	var data string
+	var postedAt time.Time
+	var reversed bool
+
+	query := "SELECT data, created_at, EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = $3) FROM events p WHERE p.transaction_id = $1 AND p.event_type = $2"
+
+	err = tx.QueryRow(query, d.TransactionId, model.EVENT_TRANSACTION_POSTED, model.EVENT_TRANSACTION_REVERSED).Scan(&data, &postedAt, &reversed)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: No transaction found for ID %d", d.TransactionId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	transaction, err := postedTransaction([]byte(data), postedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Reversed = reversed
+
+	opened, err := dispute.Open(d, transaction, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO disputes (transaction_id, account_id, amount, reason, status, deadline_at, escalated, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING ` + disputeColumns
+
+	created, err := scanDisputePostgres(tx.QueryRow(query, opened.TransactionId, opened.AccountId, opened.Amount, opened.Reason, opened.Status, nullTimePointer(opened.DeadlineAt), opened.Escalated, opened.CreatedAt, opened.UpdatedAt))
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := insertDisputeChangePostgres(tx, disputeChange(*created, "")); err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Recording the change failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_DISPUTE, created.DisputeId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositoryPostgres#OpenDispute: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (s *DisputeRepositoryPostgres) FindDispute(disputeId uint64) (*model.Dispute, error) {
+	query := "SELECT " + disputeColumns + " FROM disputes WHERE dispute_id=$1"
+
+	found, err := scanDisputePostgres(s.db.QueryRow(query, disputeId))
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#FindDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return found, nil
+}
+
+func (s *DisputeRepositoryPostgres) ListDisputes(filter repository.DisputeFilter, page repository.Page) ([]model.Dispute, error) {
+	query := `SELECT ` + disputeColumns + ` FROM disputes WHERE dispute_id > $1
+		AND ($2 = 0 OR account_id = $2) AND ($3 = '' OR status = $3) AND (NOT $4 OR escalated) ORDER BY dispute_id LIMIT $5`
+
+	return s.listDisputes("ListDisputes", query, page.AfterId, filter.AccountId, filter.Status, filter.Escalated, page.EffectiveLimit())
+}
+
+func (s *DisputeRepositoryPostgres) ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error) {
+	query := "SELECT " + disputeColumns + " FROM disputes WHERE NOT escalated AND deadline_at <= $1 ORDER BY deadline_at, dispute_id LIMIT $2"
+
+	return s.listDisputes("ListOverdueDisputes", query, now, limit)
+}
+
+func (s *DisputeRepositoryPostgres) listDisputes(method string, query string, args ...interface{}) ([]model.Dispute, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	disputes := []model.Dispute{}
+
+	for rows.Next() {
+		d, err := scanDisputePostgres(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		disputes = append(disputes, *d)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("DisputeRepositoryPostgres#%s: Reading rows failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return disputes, nil
+}
+
+func (s *DisputeRepositoryPostgres) ListDisputeChanges(disputeId uint64, page repository.Page) ([]model.DisputeChange, error) {
+	query := "SELECT " + disputeChangeColumns + " FROM dispute_changes WHERE dispute_id=$1 AND dispute_change_id > $2 ORDER BY dispute_change_id LIMIT $3"
+
+	rows, err := s.db.Query(query, disputeId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#ListDisputeChanges: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	changes := []model.DisputeChange{}
+
+	for rows.Next() {
+		change, err := scanDisputeChangePostgres(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		changes = append(changes, *change)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("DisputeRepositoryPostgres#ListDisputeChanges: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return changes, nil
+}
+
+// TransitionDispute posts or reverses the provisional credit in the same
+// transaction as the change of status, so the credit is posted once.
+func (s *DisputeRepositoryPostgres) TransitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error) {
+	var moved *model.Dispute
+
+	err := retryAppendPostgres(ctx, func() (err error) {
+		moved, err = s.transitionDispute(ctx, disputeId, status, note)
+
+		return err
+	})
+
+	return moved, err
+}
+
+func (s *DisputeRepositoryPostgres) transitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#TransitionDispute: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	before, err := lockDisputePostgres(tx, disputeId)
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#TransitionDispute: Reading the dispute failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	moved, err := dispute.Transition(*before, status, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []model.AuditEntry
+
+	switch status {
+	case model.DISPUTE_STATUS_CREDITED:
+		created, err := postTransactionsPostgres(ctx, tx, []model.Transaction{dispute.Credit(*moved)})
+		if err != nil {
+			log.Printf("DisputeRepositoryPostgres#TransitionDispute: Posting the provisional credit failed: %s", err)
+
+			return nil, translatePostgresError(err)
+		}
+
+		moved.CreditTransactionId = created[0].TransactionId
+
+		entries, err = transactionEntries(ctx, created)
+		if err != nil {
+			return nil, err
+		}
+	case model.DISPUTE_STATUS_LOST:
+		reversed, err := reverseTransactionPostgres(ctx, tx, moved.CreditTransactionId)
+		if err != nil {
+			log.Printf("DisputeRepositoryPostgres#TransitionDispute: Reversing the provisional credit failed: %s", err)
+
+			return nil, translatePostgresError(err)
+		}
+
+		entry, err := reversalEntry(ctx, *reversed)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = updateDisputePostgres(ctx, tx, *before, *moved, note)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entries...)
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#TransitionDispute: Recording the change failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositoryPostgres#TransitionDispute: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if len(entries) > 0 {
+		catchUpProjections(s.projections, "DisputeRepositoryPostgres#TransitionDispute")
+	}
+
+	return moved, nil
+}
+
+func (s *DisputeRepositoryPostgres) EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#EscalateDispute: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	before, err := lockDisputePostgres(tx, disputeId)
+	if err != nil {
+		log.Printf("DisputeRepositoryPostgres#EscalateDispute: Reading the dispute failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	escalated, err := dispute.Escalate(*before, now.UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateDisputePostgres(ctx, tx, *before, *escalated, ""); err != nil {
+		log.Printf("DisputeRepositoryPostgres#EscalateDispute: Recording the change failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositoryPostgres#EscalateDispute: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return escalated, nil
+}
+
+// lockDisputePostgres reads the dispute in tx, locking it until tx ends.
+func lockDisputePostgres(tx *sql.Tx, disputeId uint64) (*model.Dispute, error) {
+	return scanDisputePostgres(tx.QueryRow("SELECT "+disputeColumns+" FROM disputes WHERE dispute_id=$1 FOR UPDATE", disputeId))
+}
@@ -0,0 +1,401 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/dispute"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type DisputeRepositorySQLite struct {
+	db          *sql.DB
+	projections *ProjectionRepositorySQLite
+}
+
+func NewDisputeRepositorySQLite(db *sql.DB) *DisputeRepositorySQLite {
+	return &DisputeRepositorySQLite{
+		db:          db,
+		projections: NewProjectionRepositorySQLite(db),
+	}
+}
+
+func scanDisputeSQLite(row interface{ Scan(...interface{}) error }) (*model.Dispute, error) {
+	d := model.Dispute{}
+
+	var creditTransactionId sql.NullInt64
+	var deadlineAt sql.NullString
+	var createdAt, updatedAt string
+
+	err := row.Scan(&d.DisputeId, &d.TransactionId, &d.AccountId, &d.Amount, &d.Reason, &d.Status, &creditTransactionId, &deadlineAt, &d.Escalated, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	d.CreditTransactionId = uint64(creditTransactionId.Int64)
+
+	if d.DeadlineAt, err = parseSQLiteTime(deadlineAt); err != nil {
+		return nil, err
+	}
+
+	if d.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	if d.UpdatedAt, err = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func scanDisputeChangeSQLite(row interface{ Scan(...interface{}) error }) (*model.DisputeChange, error) {
+	change := model.DisputeChange{}
+
+	var createdAt string
+
+	err := row.Scan(&change.DisputeChangeId, &change.DisputeId, &change.Status, &change.Escalated, &change.Note, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if change.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func insertDisputeChangeSQLite(tx *sql.Tx, change model.DisputeChange) error {
+	_, err := tx.Exec("INSERT INTO dispute_changes (dispute_id, status, escalated, note, created_at) VALUES (?, ?, ?, ?, ?)",
+		change.DisputeId, change.Status, change.Escalated, change.Note, sqliteTime(change.CreatedAt))
+
+	return err
+}
+
+// updateDisputeSQLite stores the status, credit, deadline and escalation
+// of d in tx, along with its history and audit log.
+func updateDisputeSQLite(ctx context.Context, tx *sql.Tx, before model.Dispute, d model.Dispute, note string) error {
+	_, err := tx.Exec("UPDATE disputes SET status=?2, credit_transaction_id=?3, deadline_at=?4, escalated=?5, updated_at=?6 WHERE dispute_id=?1",
+		d.DisputeId, d.Status, nullId(d.CreditTransactionId), sqliteTimePointer(d.DeadlineAt), d.Escalated, sqliteTime(d.UpdatedAt))
+	if err != nil {
+		return err
+	}
+
+	if err := insertDisputeChangeSQLite(tx, disputeChange(d, note)); err != nil {
+		return err
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_DISPUTE, d.DisputeId, before, d)
+	if err != nil {
+		return err
+	}
+
+	return appendAuditSQLite(tx, entry)
+}
+
+func (s *DisputeRepositorySQLite) OpenDispute(ctx context.Context, d model.Dispute) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	var data string
+	var postedAt sql.NullString
+	var reversed bool
+
+	query := "SELECT data, created_at, EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = ?3) FROM events p WHERE p.transaction_id = ?1 AND p.event_type = ?2"
+
+	err = tx.QueryRow(query, d.TransactionId, model.EVENT_TRANSACTION_POSTED, model.EVENT_TRANSACTION_REVERSED).Scan(&data, &postedAt, &reversed)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: No transaction found for ID %d", d.TransactionId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	posted, err := parseSQLiteTime(postedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := postedTransaction([]byte(data), *posted)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction.Reversed = reversed
+
+	opened, err := dispute.Open(d, transaction, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	query = `INSERT INTO disputes (transaction_id, account_id, amount, reason, status, deadline_at, escalated, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING ` + disputeColumns
+
+	created, err := scanDisputeSQLite(tx.QueryRow(query, opened.TransactionId, opened.AccountId, opened.Amount, opened.Reason, opened.Status, sqliteTimePointer(opened.DeadlineAt), opened.Escalated, sqliteTime(opened.CreatedAt), sqliteTime(opened.UpdatedAt)))
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := insertDisputeChangeSQLite(tx, disputeChange(*created, "")); err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Recording the change failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_DISPUTE, created.DisputeId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositorySQLite#OpenDispute: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (s *DisputeRepositorySQLite) FindDispute(disputeId uint64) (*model.Dispute, error) {
+	query := "SELECT " + disputeColumns + " FROM disputes WHERE dispute_id=?"
+
+	found, err := scanDisputeSQLite(s.db.QueryRow(query, disputeId))
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#FindDispute: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return found, nil
+}
+
+func (s *DisputeRepositorySQLite) ListDisputes(filter repository.DisputeFilter, page repository.Page) ([]model.Dispute, error) {
+	query := `SELECT ` + disputeColumns + ` FROM disputes WHERE dispute_id > ?1
+		AND (?2 = 0 OR account_id = ?2) AND (?3 = '' OR status = ?3) AND (NOT ?4 OR escalated) ORDER BY dispute_id LIMIT ?5`
+
+	return s.listDisputes("ListDisputes", query, page.AfterId, filter.AccountId, filter.Status, filter.Escalated, page.EffectiveLimit())
+}
+
+func (s *DisputeRepositorySQLite) ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error) {
+	query := "SELECT " + disputeColumns + " FROM disputes WHERE NOT escalated AND deadline_at <= ? ORDER BY deadline_at, dispute_id LIMIT ?"
+
+	return s.listDisputes("ListOverdueDisputes", query, sqliteTime(now), limit)
+}
+
+func (s *DisputeRepositorySQLite) listDisputes(method string, query string, args ...interface{}) ([]model.Dispute, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	disputes := []model.Dispute{}
+
+	for rows.Next() {
+		d, err := scanDisputeSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		disputes = append(disputes, *d)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("DisputeRepositorySQLite#%s: Reading rows failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return disputes, nil
+}
+
+func (s *DisputeRepositorySQLite) ListDisputeChanges(disputeId uint64, page repository.Page) ([]model.DisputeChange, error) {
+	query := "SELECT " + disputeChangeColumns + " FROM dispute_changes WHERE dispute_id=? AND dispute_change_id > ? ORDER BY dispute_change_id LIMIT ?"
+
+	rows, err := s.db.Query(query, disputeId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#ListDisputeChanges: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	changes := []model.DisputeChange{}
+
+	for rows.Next() {
+		change, err := scanDisputeChangeSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		changes = append(changes, *change)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("DisputeRepositorySQLite#ListDisputeChanges: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return changes, nil
+}
+
+// TransitionDispute posts or reverses the provisional credit in the same
+// transaction as the change of status, so the credit is posted once.
+func (s *DisputeRepositorySQLite) TransitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#TransitionDispute: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	before, err := lockDisputeSQLite(tx, disputeId)
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#TransitionDispute: Reading the dispute failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	moved, err := dispute.Transition(*before, status, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []model.AuditEntry
+
+	switch status {
+	case model.DISPUTE_STATUS_CREDITED:
+		created, err := postTransactionsSQLite(ctx, tx, []model.Transaction{dispute.Credit(*moved)})
+		if err != nil {
+			log.Printf("DisputeRepositorySQLite#TransitionDispute: Posting the provisional credit failed: %s", err)
+
+			return nil, translateSQLiteError(err)
+		}
+
+		moved.CreditTransactionId = created[0].TransactionId
+
+		entries, err = transactionEntries(ctx, created)
+		if err != nil {
+			return nil, err
+		}
+	case model.DISPUTE_STATUS_LOST:
+		reversed, err := reverseTransactionSQLite(ctx, tx, moved.CreditTransactionId)
+		if err != nil {
+			log.Printf("DisputeRepositorySQLite#TransitionDispute: Reversing the provisional credit failed: %s", err)
+
+			return nil, translateSQLiteError(err)
+		}
+
+		entry, err := reversalEntry(ctx, *reversed)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = updateDisputeSQLite(ctx, tx, *before, *moved, note)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entries...)
+	}
+
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#TransitionDispute: Recording the change failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositorySQLite#TransitionDispute: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if len(entries) > 0 {
+		catchUpProjections(s.projections, "DisputeRepositorySQLite#TransitionDispute")
+	}
+
+	return moved, nil
+}
+
+func (s *DisputeRepositorySQLite) EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#EscalateDispute: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	before, err := lockDisputeSQLite(tx, disputeId)
+	if err != nil {
+		log.Printf("DisputeRepositorySQLite#EscalateDispute: Reading the dispute failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	escalated, err := dispute.Escalate(*before, now.UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := updateDisputeSQLite(ctx, tx, *before, *escalated, ""); err != nil {
+		log.Printf("DisputeRepositorySQLite#EscalateDispute: Recording the change failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("DisputeRepositorySQLite#EscalateDispute: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return escalated, nil
+}
+
+// lockDisputeSQLite reads the dispute in tx. As OpenSQLite makes tx hold
+// the write lock, it cannot change before tx ends.
+func lockDisputeSQLite(tx *sql.Tx, disputeId uint64) (*model.Dispute, error) {
+	return scanDisputeSQLite(tx.QueryRow("SELECT "+disputeColumns+" FROM disputes WHERE dispute_id=?", disputeId))
+}
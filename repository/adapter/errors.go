@@ -0,0 +1,86 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// translatePostgresError wraps err with the matching repository error while
+// keeping the original one in the chain for logging and inspection.
+func translatePostgresError(err error) error {
+	var pqErr *pq.Error
+
+	if errors.As(err, &pqErr) {
+		switch {
+		// Another command appended to the stream at the same version.
+		case pqErr.Code == "23505" && pqErr.Constraint == "events_stream_version_key":
+			return wrapError(repository.ErrVersionConflict, err)
+		case pqErr.Code == "23505":
+			return wrapError(repository.ErrConflict, err)
+		case pqErr.Code == "23503":
+			return wrapError(repository.ErrForeignKeyViolation, err)
+		case pqErr.Code == "57014":
+			return wrapError(repository.ErrTimeout, err)
+		case pqErr.Code.Class() == "08", pqErr.Code.Class() == "53",
+			pqErr.Code == "57P01", pqErr.Code == "57P02", pqErr.Code == "57P03":
+			return wrapError(repository.ErrUnavailable, err)
+		}
+	}
+
+	return translateCommonError(err)
+}
+
+// translateSQLiteError gives SQLite failures the same meaning as their
+// Postgres counterparts.
+func translateSQLiteError(err error) error {
+	var sqliteErr *sqlite.Error
+
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return wrapError(repository.ErrConflict, err)
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return wrapError(repository.ErrForeignKeyViolation, err)
+		case sqlite3.SQLITE_INTERRUPT:
+			return wrapError(repository.ErrTimeout, err)
+		}
+
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED, sqlite3.SQLITE_CANTOPEN, sqlite3.SQLITE_FULL, sqlite3.SQLITE_IOERR:
+			return wrapError(repository.ErrUnavailable, err)
+		}
+	}
+
+	return translateCommonError(err)
+}
+
+func translateCommonError(err error) error {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return wrapError(repository.ErrNotFound, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return wrapError(repository.ErrTimeout, err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return wrapError(repository.ErrTimeout, err)
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &netErr):
+		return wrapError(repository.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+func wrapError(kind error, err error) error {
+	return fmt.Errorf("%w: %w", kind, err)
+}
@@ -0,0 +1,44 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+func TestTranslatePostgresError(t *testing.T) {
+	var scenarios = []struct {
+		err          error
+		expectedKind error
+	}{
+		{sql.ErrNoRows, repository.ErrNotFound},
+		{&pq.Error{Code: "23505"}, repository.ErrConflict},
+		{&pq.Error{Code: "23503"}, repository.ErrForeignKeyViolation},
+		{&pq.Error{Code: "57014"}, repository.ErrTimeout},
+		{&pq.Error{Code: "08006"}, repository.ErrUnavailable},
+		{&pq.Error{Code: "53300"}, repository.ErrUnavailable},
+		{&pq.Error{Code: "57P01"}, repository.ErrUnavailable},
+		{context.DeadlineExceeded, repository.ErrTimeout},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, repository.ErrUnavailable},
+	}
+
+	for _, scenario := range scenarios {
+		translated := translatePostgresError(scenario.err)
+
+		assert.ErrorIs(t, translated, scenario.expectedKind)
+		assert.ErrorIs(t, translated, scenario.err)
+	}
+}
+
+func TestTranslatePostgresErrorKeepsUnknownErrors(t *testing.T) {
+	err := &pq.Error{Code: "42601"}
+
+	assert.Same(t, err, translatePostgresError(err))
+}
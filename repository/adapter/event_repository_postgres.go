@@ -0,0 +1,631 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/fx"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const eventColumns = "event_id, account_id, version, event_type, transaction_id, data, created_at"
+
+// eventInsertColumns are the columns written when appending an event, all
+// but the ID.
+var eventInsertColumns = []string{"account_id", "version", "event_type", "transaction_id", "data", "created_at"}
+
+// streamState is what the commands decide on: the version of the stream of
+// an account, zero when the account does not exist, and whether it was
+// blocked.
+type streamState struct {
+	version uint64
+	blocked bool
+}
+
+type EventRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewEventRepositoryPostgres(db *sql.DB) *EventRepositoryPostgres {
+	return &EventRepositoryPostgres{
+		db: db,
+	}
+}
+
+// errStreamAhead is returned by loadStreamsPostgres when a stream was
+// appended to by a transaction with a higher ID than the one reading it.
+// The projections apply the events in the order of the IDs of their
+// transactions, so the events appended next would be applied before the
+// ones they follow.
+var errStreamAhead = fmt.Errorf("%w: stream was appended to by a later transaction", repository.ErrVersionConflict)
+
+// maxAppendAttempts is how many times retryAppendPostgres runs a command.
+const maxAppendAttempts = 5
+
+// retryAppendPostgres runs command, which appends events in a transaction
+// of its own, again when another one appended to the same streams first.
+// Commands given a version by ctx fail with ErrVersionConflict instead, for
+// the caller to read the stream again.
+func retryAppendPostgres(ctx context.Context, command func() error) error {
+	_, expected := repository.ExpectedVersion(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := command()
+
+		retry := errors.Is(err, errStreamAhead) || !expected && errors.Is(err, repository.ErrVersionConflict)
+
+		if !retry || attempt == maxAppendAttempts {
+			return err
+		}
+	}
+}
+
+// loadStreamsPostgres returns the state of the streams of accountIds. The
+// events are appended without locking the table, so the streams can still
+// move before tx ends, which the unique version of each stream catches.
+func loadStreamsPostgres(tx *sql.Tx, accountIds []uint64) (map[uint64]streamState, error) {
+	query := `SELECT a.account_id, COALESCE(s.version, 0), COALESCE(s.transaction_xid > pg_current_xact_id(), FALSE),
+			EXISTS (SELECT 1 FROM events b WHERE b.account_id = a.account_id AND b.event_type = $2)
+		FROM unnest($1::BIGINT[]) AS a(account_id)
+		LEFT JOIN LATERAL (SELECT version, transaction_xid FROM events e WHERE e.account_id = a.account_id ORDER BY version DESC LIMIT 1) s ON TRUE`
+
+	ids := make([]int64, len(accountIds))
+
+	for i, accountId := range accountIds {
+		ids[i] = int64(accountId)
+	}
+
+	rows, err := tx.Query(query, pq.Array(ids), model.EVENT_ACCOUNT_BLOCKED)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	streams := map[uint64]streamState{}
+
+	for rows.Next() {
+		var accountId uint64
+		var state streamState
+		var ahead bool
+
+		if err := rows.Scan(&accountId, &state.version, &ahead, &state.blocked); err != nil {
+			return nil, err
+		}
+
+		if ahead {
+			return nil, errStreamAhead
+		}
+
+		streams[accountId] = state
+	}
+
+	return streams, rows.Err()
+}
+
+// appendEventsPostgres numbers the events after the versions of streams,
+// which it moves forward, and stores them in tx.
+func appendEventsPostgres(tx *sql.Tx, streams map[uint64]streamState, events []model.Event) error {
+	numberEvents(streams, events)
+
+	return copyRows(tx, pq.CopyIn("events", eventInsertColumns...), len(events), func(n int) []interface{} {
+		return eventRow(events[n], events[n].CreatedAt)
+	})
+}
+
+// numberEvents gives the events the versions following the ones of their
+// streams. The unique version of each stream makes the append fail if
+// another one got there first.
+func numberEvents(streams map[uint64]streamState, events []model.Event) {
+	for n := range events {
+		state := streams[events[n].AccountId]
+		state.version++
+
+		if events[n].Type == model.EVENT_ACCOUNT_BLOCKED {
+			state.blocked = true
+		}
+
+		events[n].Version = state.version
+		streams[events[n].AccountId] = state
+	}
+}
+
+func eventRow(event model.Event, createdAt interface{}) []interface{} {
+	var transactionId interface{}
+
+	if event.TransactionId != 0 {
+		transactionId = event.TransactionId
+	}
+
+	return []interface{}{event.AccountId, event.Version, event.Type, transactionId, string(event.Data), createdAt}
+}
+
+// checkStreams fails when an account of accountIds does not exist, is
+// blocked, or when ctx expects a version its stream moved past.
+func checkStreams(ctx context.Context, streams map[uint64]streamState, accountIds []uint64) error {
+	for _, accountId := range accountIds {
+		state := streams[accountId]
+
+		if state.version == 0 {
+			return repository.ErrForeignKeyViolation
+		}
+
+		if state.blocked {
+			return repository.ErrAccountBlocked
+		}
+	}
+
+	return checkExpectedVersion(ctx, streams, accountIds)
+}
+
+// checkExpectedVersion applies WithExpectedVersion, which is only meant for
+// commands on a single stream.
+func checkExpectedVersion(ctx context.Context, streams map[uint64]streamState, accountIds []uint64) error {
+	expected, ok := repository.ExpectedVersion(ctx)
+
+	if ok && len(accountIds) == 1 && streams[accountIds[0]].version != expected {
+		return repository.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// postTransactionsPostgres appends a TransactionPosted event for each
+// transaction in tx and returns them with their IDs. The IDs are drawn from
+// the sequence of the transactions table, which is only written by the
+// projection.
+func postTransactionsPostgres(ctx context.Context, tx *sql.Tx, transactions []model.Transaction) ([]model.Transaction, error) {
+	accountIds := transactionAccountIds(transactions)
+
+	streams, err := loadStreamsPostgres(tx, accountIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStreams(ctx, streams, accountIds); err != nil {
+		return nil, err
+	}
+
+	if err := checkOperationTypesPostgres(tx, transactions); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT nextval(pg_get_serial_sequence('transactions', 'transaction_id')) FROM generate_series(1, $1)", len(transactions))
+	if err != nil {
+		return nil, err
+	}
+
+	transactionIds, err := scanIds(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	created := withTransactionIds(transactions, transactionIds)
+
+	if err := convertTransactionsPostgres(tx, created, accountIds); err != nil {
+		return nil, err
+	}
+
+	if err := checkCardsPostgres(tx, created); err != nil {
+		return nil, err
+	}
+
+	if err := checkSpendRulesPostgres(tx, created); err != nil {
+		return nil, err
+	}
+
+	if err := insertDedupKeyPostgres(ctx, tx, created[0].TransactionId); err != nil {
+		return nil, err
+	}
+
+	events, err := postedEvents(created)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsPostgres(tx, streams, events); err != nil {
+		return nil, err
+	}
+
+	if err := countRiskPostgres(tx, created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// convertTransactionsPostgres bills the transactions in the currency of
+// their account, converting them at the rates read in tx, see the fx
+// package.
+func convertTransactionsPostgres(tx *sql.Tx, transactions []model.Transaction, accountIds []uint64) error {
+	ids := make([]int64, len(accountIds))
+
+	for i, accountId := range accountIds {
+		ids[i] = int64(accountId)
+	}
+
+	rows, err := tx.Query("SELECT account_id, currency FROM accounts WHERE account_id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	currencies, err := scanCurrencies(rows)
+	if err != nil {
+		return err
+	}
+
+	return fx.ConvertTransactions(transactions, currencies, func(base string, quote string, at time.Time) (*model.FxRate, error) {
+		return findFxRatePostgres(tx, base, quote, at)
+	})
+}
+
+// scanCurrencies reads the currencies of the accounts by their IDs.
+func scanCurrencies(rows *sql.Rows) (map[uint64]string, error) {
+	defer rows.Close()
+
+	currencies := map[uint64]string{}
+
+	for rows.Next() {
+		var accountId uint64
+		var currency string
+
+		if err := rows.Scan(&accountId, &currency); err != nil {
+			return nil, err
+		}
+
+		currencies[accountId] = currency
+	}
+
+	return currencies, rows.Err()
+}
+
+// insertDedupKeyPostgres stores the dedup key of ctx, if any, with the first
+// transaction of the posting. A key stored before fails with ErrConflict.
+func insertDedupKeyPostgres(ctx context.Context, tx *sql.Tx, transactionId uint64) error {
+	key, ok := repository.DedupKey(ctx)
+	if !ok {
+		return nil
+	}
+
+	result, err := tx.Exec("INSERT INTO transaction_dedup_keys (dedup_key, transaction_id) VALUES ($1, $2) ON CONFLICT (dedup_key) DO NOTHING", key, transactionId)
+	if err != nil {
+		return err
+	}
+
+	return dedupKeyInserted(result)
+}
+
+// dedupKeyInserted returns ErrConflict when the insert of a dedup key found
+// it already stored.
+func dedupKeyInserted(result sql.Result) error {
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if inserted == 0 {
+		return repository.ErrConflict
+	}
+
+	return nil
+}
+
+// checkOperationTypesPostgres stands in for the foreign key of the
+// transactions table, which is only checked once projected.
+func checkOperationTypesPostgres(tx *sql.Tx, transactions []model.Transaction) error {
+	ids := []int64{}
+
+	for _, operationTypeId := range transactionOperationTypeIds(transactions) {
+		ids = append(ids, int64(operationTypeId))
+	}
+
+	var found int
+
+	err := tx.QueryRow("SELECT COUNT(*) FROM operation_types WHERE operation_type_id = ANY($1)", pq.Array(ids)).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found != len(ids) {
+		return repository.ErrForeignKeyViolation
+	}
+
+	return nil
+}
+
+// reverseTransactionPostgres appends TransactionReversed to the stream of
+// the account of the transaction in tx and returns the transaction as
+// reversed. Blocked accounts can still have their transactions reversed.
+func reverseTransactionPostgres(ctx context.Context, tx *sql.Tx, transactionId uint64) (*model.Transaction, error) {
+	var data string
+	var postedAt time.Time
+	var reversed bool
+
+	query := "SELECT data, created_at, EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = $3) FROM events p WHERE p.transaction_id = $1 AND p.event_type = $2"
+
+	err := tx.QueryRow(query, transactionId, model.EVENT_TRANSACTION_POSTED, model.EVENT_TRANSACTION_REVERSED).Scan(&data, &postedAt, &reversed)
+	if err != nil {
+		return nil, err
+	}
+
+	if reversed {
+		return nil, repository.ErrConflict
+	}
+
+	transaction, event, err := reversalEvent(data, postedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	streams, err := loadStreamsPostgres(tx, []uint64{transaction.AccountId})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExpectedVersion(ctx, streams, []uint64{transaction.AccountId}); err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsPostgres(tx, streams, []model.Event{event}); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// blockAccountPostgres appends AccountBlocked to the stream of the account
+// in tx and returns the account as blocked.
+func blockAccountPostgres(ctx context.Context, tx *sql.Tx, c pii.Cipher, accountId uint64) (*model.Account, error) {
+	streams, err := loadStreamsPostgres(tx, []uint64{accountId})
+	if err != nil {
+		return nil, err
+	}
+
+	if streams[accountId].version == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	if streams[accountId].blocked {
+		return nil, repository.ErrConflict
+	}
+
+	if err := checkExpectedVersion(ctx, streams, []uint64{accountId}); err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(c, tx.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE account_id = $1", accountId))
+	if err != nil {
+		return nil, err
+	}
+
+	account.Blocked = true
+
+	event, err := model.NewEvent(model.EVENT_ACCOUNT_BLOCKED, accountId, 0, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsPostgres(tx, streams, []model.Event{event}); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (e *EventRepositoryPostgres) ListEvents(filter repository.EventFilter, page repository.Page) ([]model.Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE event_id > $1 AND ($2 = 0 OR account_id = $2) ORDER BY event_id LIMIT $3"
+
+	rows, err := e.db.Query(query, page.AfterId, filter.AccountId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("EventRepositoryPostgres#ListEvents: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	events, err := scanEventsPostgres(rows)
+
+	if err != nil {
+		log.Printf("EventRepositoryPostgres#ListEvents: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return events, nil
+}
+
+func scanEventsPostgres(rows *sql.Rows) ([]model.Event, error) {
+	defer rows.Close()
+
+	events := []model.Event{}
+
+	for rows.Next() {
+		event := model.Event{}
+
+		var transactionId sql.NullInt64
+		var data string
+
+		err := rows.Scan(&event.EventId, &event.AccountId, &event.Version, &event.Type, &transactionId, &data, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.TransactionId = uint64(transactionId.Int64)
+		event.Data = []byte(data)
+		event.CreatedAt = event.CreatedAt.UTC()
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// transactionAccountIds returns the accounts of the transactions, each
+// once and in order.
+func transactionAccountIds(transactions []model.Transaction) []uint64 {
+	accountIds := []uint64{}
+	seen := map[uint64]bool{}
+
+	for _, transaction := range transactions {
+		if !seen[transaction.AccountId] {
+			accountIds = append(accountIds, transaction.AccountId)
+			seen[transaction.AccountId] = true
+		}
+	}
+
+	return accountIds
+}
+
+// transactionOperationTypeIds returns the operation types of the
+// transactions, each once.
+func transactionOperationTypeIds(transactions []model.Transaction) []uint32 {
+	operationTypeIds := []uint32{}
+	seen := map[uint32]bool{}
+
+	for _, transaction := range transactions {
+		if !seen[transaction.OperationTypeId] {
+			operationTypeIds = append(operationTypeIds, transaction.OperationTypeId)
+			seen[transaction.OperationTypeId] = true
+		}
+	}
+
+	return operationTypeIds
+}
+
+// accountOpened is the data of AccountOpened: the account without its
+// document number, which is only recorded sealed, so the accounts can be
+// projected again from their events.
+type accountOpened struct {
+	model.Account
+	DocumentNumberCiphertext string `json:"document_number_ciphertext,omitempty"`
+	DocumentNumberIndex      string `json:"document_number_index,omitempty"`
+	PiiKeyId                 string `json:"pii_key_id,omitempty"`
+}
+
+// openedEvent returns the event opening account, whose document number
+// was sealed by sealDocumentNumber.
+func openedEvent(account model.Account, sealed *sealedDocumentNumber) (model.Event, error) {
+	opened := accountOpened{
+		Account:                  account.Redacted(),
+		DocumentNumberCiphertext: sealed.ciphertext,
+		DocumentNumberIndex:      sealed.index,
+		PiiKeyId:                 sealed.keyId,
+	}
+
+	return model.NewEvent(model.EVENT_ACCOUNT_OPENED, account.AccountId, 0, opened)
+}
+
+// openedAccount reads the account opened with data. The events appended
+// before currencies hold none, and the ones appended before the accounts
+// were projected no sealed document number.
+func openedAccount(data []byte) (*accountOpened, error) {
+	opened := &accountOpened{}
+
+	if err := json.Unmarshal(data, opened); err != nil {
+		return nil, err
+	}
+
+	if opened.Currency == "" {
+		opened.Currency = model.DEFAULT_CURRENCY
+	}
+
+	return opened, nil
+}
+
+// postedEvents stamps the transactions with the time they are posted at,
+// which is also the time of their events, and returns the events posting
+// them.
+func postedEvents(transactions []model.Transaction) ([]model.Event, error) {
+	events := make([]model.Event, len(transactions))
+	postedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	for n := range transactions {
+		stampTransaction(&transactions[n], postedAt)
+
+		event, err := model.NewEvent(model.EVENT_TRANSACTION_POSTED, transactions[n].AccountId, transactions[n].TransactionId, transactions[n])
+		if err != nil {
+			return nil, err
+		}
+
+		event.CreatedAt = postedAt
+		events[n] = event
+	}
+
+	return events, nil
+}
+
+// stampTransaction sets when the transaction was posted, and when it took
+// place unless it was given, both to the millisecond every storage keeps,
+// along with the category of its merchant.
+func stampTransaction(transaction *model.Transaction, postedAt time.Time) {
+	transaction.CreatedAt = postedAt
+	transaction.Category = model.MerchantCategory(transaction.Mcc)
+
+	if transaction.EventDate.IsZero() {
+		transaction.EventDate = postedAt
+	}
+
+	transaction.EventDate = transaction.EventDate.UTC().Truncate(time.Millisecond)
+}
+
+// postedTransaction reads the transaction posted with data at postedAt. The
+// events appended before transactions had times only hold the time of the
+// event itself, and the ones appended before currencies none.
+func postedTransaction(data []byte, postedAt time.Time) (model.Transaction, error) {
+	transaction := model.Transaction{}
+
+	if err := json.Unmarshal(data, &transaction); err != nil {
+		return model.Transaction{}, err
+	}
+
+	if transaction.Currency == "" {
+		transaction.Currency = model.DEFAULT_CURRENCY
+	}
+
+	if transaction.CreatedAt.IsZero() {
+		stampTransaction(&transaction, postedAt)
+	}
+
+	return transaction, nil
+}
+
+// reversalEvent returns the transaction posted with data at postedAt, as
+// reversed, and the event reversing it.
+func reversalEvent(data string, postedAt time.Time) (*model.Transaction, model.Event, error) {
+	transaction, err := postedTransaction([]byte(data), postedAt)
+	if err != nil {
+		return nil, model.Event{}, err
+	}
+
+	reversal := model.TransactionReversal{TransactionId: transaction.TransactionId, Amount: transaction.Amount}
+
+	event, err := model.NewEvent(model.EVENT_TRANSACTION_REVERSED, transaction.AccountId, transaction.TransactionId, reversal)
+	if err != nil {
+		return nil, model.Event{}, err
+	}
+
+	transaction.Reversed = true
+
+	return &transaction, event, nil
+}
+
+// eventAmount reads the amount of a posted or reversed transaction as
+// written in the event.
+func eventAmount(event model.Event) (model.Amount, error) {
+	var data struct {
+		Amount model.Amount `json:"amount"`
+	}
+
+	err := json.Unmarshal(event.Data, &data)
+
+	return data.Amount, err
+}
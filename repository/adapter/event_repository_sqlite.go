@@ -0,0 +1,345 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/fx"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type EventRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewEventRepositorySQLite(db *sql.DB) *EventRepositorySQLite {
+	return &EventRepositorySQLite{
+		db: db,
+	}
+}
+
+// sqliteIdList passes a list of IDs as a single JSON parameter, read with
+// json_each, so it is not bound by the limit of parameters.
+func sqliteIdList(ids interface{}) (string, error) {
+	content, err := json.Marshal(ids)
+
+	return string(content), err
+}
+
+// loadStreamsSQLite returns the state of the streams of accountIds. As
+// OpenSQLite makes tx hold the write lock, it cannot change before tx ends.
+func loadStreamsSQLite(tx *sql.Tx, accountIds []uint64) (map[uint64]streamState, error) {
+	query := `SELECT a.value, (SELECT COALESCE(MAX(e.version), 0) FROM events e WHERE e.account_id = a.value),
+		EXISTS (SELECT 1 FROM events b WHERE b.account_id = a.value AND b.event_type = ?)
+		FROM json_each(?) a`
+
+	ids, err := sqliteIdList(accountIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(query, model.EVENT_ACCOUNT_BLOCKED, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	streams := map[uint64]streamState{}
+
+	for rows.Next() {
+		var accountId uint64
+		var state streamState
+
+		if err := rows.Scan(&accountId, &state.version, &state.blocked); err != nil {
+			return nil, err
+		}
+
+		streams[accountId] = state
+	}
+
+	return streams, rows.Err()
+}
+
+// appendEventsSQLite numbers the events after the versions of streams,
+// which it moves forward, and stores them in tx.
+func appendEventsSQLite(tx *sql.Tx, streams map[uint64]streamState, events []model.Event) error {
+	numberEvents(streams, events)
+
+	return insertRows(tx, "events", eventInsertColumns, len(events), func(n int) []interface{} {
+		return eventRow(events[n], sqliteTime(events[n].CreatedAt))
+	})
+}
+
+// postTransactionsSQLite appends a TransactionPosted event for each
+// transaction in tx and returns them with their IDs, which follow the last
+// one posted.
+func postTransactionsSQLite(ctx context.Context, tx *sql.Tx, transactions []model.Transaction) ([]model.Transaction, error) {
+	accountIds := transactionAccountIds(transactions)
+
+	streams, err := loadStreamsSQLite(tx, accountIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStreams(ctx, streams, accountIds); err != nil {
+		return nil, err
+	}
+
+	if err := checkOperationTypesSQLite(tx, transactions); err != nil {
+		return nil, err
+	}
+
+	var lastId uint64
+
+	err = tx.QueryRow("SELECT COALESCE(MAX(transaction_id), 0) FROM events WHERE transaction_id IS NOT NULL").Scan(&lastId)
+	if err != nil {
+		return nil, err
+	}
+
+	transactionIds := make([]uint64, len(transactions))
+
+	for n := range transactions {
+		transactionIds[n] = lastId + uint64(n) + 1
+	}
+
+	created := withTransactionIds(transactions, transactionIds)
+
+	if err := convertTransactionsSQLite(tx, created, accountIds); err != nil {
+		return nil, err
+	}
+
+	if err := checkCardsSQLite(tx, created); err != nil {
+		return nil, err
+	}
+
+	if err := checkSpendRulesSQLite(tx, created); err != nil {
+		return nil, err
+	}
+
+	if err := insertDedupKeySQLite(ctx, tx, created[0].TransactionId); err != nil {
+		return nil, err
+	}
+
+	events, err := postedEvents(created)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsSQLite(tx, streams, events); err != nil {
+		return nil, err
+	}
+
+	if err := countRiskSQLite(tx, created); err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// convertTransactionsSQLite bills the transactions in the currency of
+// their account, converting them at the rates read in tx, see the fx
+// package.
+func convertTransactionsSQLite(tx *sql.Tx, transactions []model.Transaction, accountIds []uint64) error {
+	ids, err := sqliteIdList(accountIds)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT account_id, currency FROM accounts WHERE account_id IN (SELECT value FROM json_each(?))", ids)
+	if err != nil {
+		return err
+	}
+
+	currencies, err := scanCurrencies(rows)
+	if err != nil {
+		return err
+	}
+
+	return fx.ConvertTransactions(transactions, currencies, func(base string, quote string, at time.Time) (*model.FxRate, error) {
+		return findFxRateSQLite(tx, base, quote, at)
+	})
+}
+
+// insertDedupKeySQLite stores the dedup key of ctx, if any, with the first
+// transaction of the posting. A key stored before fails with ErrConflict.
+func insertDedupKeySQLite(ctx context.Context, tx *sql.Tx, transactionId uint64) error {
+	key, ok := repository.DedupKey(ctx)
+	if !ok {
+		return nil
+	}
+
+	result, err := tx.Exec("INSERT INTO transaction_dedup_keys (dedup_key, transaction_id) VALUES (?, ?) ON CONFLICT (dedup_key) DO NOTHING", key, transactionId)
+	if err != nil {
+		return err
+	}
+
+	return dedupKeyInserted(result)
+}
+
+// checkOperationTypesSQLite stands in for the foreign key of the
+// transactions table, which is only checked once projected.
+func checkOperationTypesSQLite(tx *sql.Tx, transactions []model.Transaction) error {
+	operationTypeIds := transactionOperationTypeIds(transactions)
+
+	ids, err := sqliteIdList(operationTypeIds)
+	if err != nil {
+		return err
+	}
+
+	var found int
+
+	err = tx.QueryRow("SELECT COUNT(*) FROM operation_types WHERE operation_type_id IN (SELECT value FROM json_each(?))", ids).Scan(&found)
+	if err != nil {
+		return err
+	}
+
+	if found != len(operationTypeIds) {
+		return repository.ErrForeignKeyViolation
+	}
+
+	return nil
+}
+
+// reverseTransactionSQLite appends TransactionReversed to the stream of the
+// account of the transaction in tx and returns the transaction as reversed.
+// Blocked accounts can still have their transactions reversed.
+func reverseTransactionSQLite(ctx context.Context, tx *sql.Tx, transactionId uint64) (*model.Transaction, error) {
+	var data string
+	var createdAt sql.NullString
+	var reversed bool
+
+	query := "SELECT data, created_at, EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = ?3) FROM events p WHERE p.transaction_id = ?1 AND p.event_type = ?2"
+
+	err := tx.QueryRow(query, transactionId, model.EVENT_TRANSACTION_POSTED, model.EVENT_TRANSACTION_REVERSED).Scan(&data, &createdAt, &reversed)
+	if err != nil {
+		return nil, err
+	}
+
+	if reversed {
+		return nil, repository.ErrConflict
+	}
+
+	postedAt, err := parseSQLiteTime(createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, event, err := reversalEvent(data, *postedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	streams, err := loadStreamsSQLite(tx, []uint64{transaction.AccountId})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkExpectedVersion(ctx, streams, []uint64{transaction.AccountId}); err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsSQLite(tx, streams, []model.Event{event}); err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// blockAccountSQLite appends AccountBlocked to the stream of the account in
+// tx and returns the account as blocked.
+func blockAccountSQLite(ctx context.Context, tx *sql.Tx, c pii.Cipher, accountId uint64) (*model.Account, error) {
+	streams, err := loadStreamsSQLite(tx, []uint64{accountId})
+	if err != nil {
+		return nil, err
+	}
+
+	if streams[accountId].version == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	if streams[accountId].blocked {
+		return nil, repository.ErrConflict
+	}
+
+	if err := checkExpectedVersion(ctx, streams, []uint64{accountId}); err != nil {
+		return nil, err
+	}
+
+	account, err := scanAccount(c, tx.QueryRow("SELECT "+accountColumns+" FROM accounts WHERE account_id = ?", accountId))
+	if err != nil {
+		return nil, err
+	}
+
+	account.Blocked = true
+
+	event, err := model.NewEvent(model.EVENT_ACCOUNT_BLOCKED, accountId, 0, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := appendEventsSQLite(tx, streams, []model.Event{event}); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (e *EventRepositorySQLite) ListEvents(filter repository.EventFilter, page repository.Page) ([]model.Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE event_id > ?1 AND (?2 = 0 OR account_id = ?2) ORDER BY event_id LIMIT ?3"
+
+	rows, err := e.db.Query(query, page.AfterId, filter.AccountId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("EventRepositorySQLite#ListEvents: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	events, err := scanEventsSQLite(rows)
+
+	if err != nil {
+		log.Printf("EventRepositorySQLite#ListEvents: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return events, nil
+}
+
+func scanEventsSQLite(rows *sql.Rows) ([]model.Event, error) {
+	defer rows.Close()
+
+	events := []model.Event{}
+
+	for rows.Next() {
+		event := model.Event{}
+
+		var transactionId sql.NullInt64
+		var data string
+		var createdAt sql.NullString
+
+		err := rows.Scan(&event.EventId, &event.AccountId, &event.Version, &event.Type, &transactionId, &data, &createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed, err := parseSQLiteTime(createdAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.TransactionId = uint64(transactionId.Int64)
+		event.Data = []byte(data)
+		event.CreatedAt = *parsed
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,216 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const exportColumns = "export_id, account_id, format, period_from, period_to, status, rows, error"
+
+type ExportRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewExportRepositoryPostgres(db *sql.DB) *ExportRepositoryPostgres {
+	return &ExportRepositoryPostgres{
+		db: db,
+	}
+}
+
+// nullTime stores the zero time as NULL, as it stands for an open period.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+func timePointer(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	utc := t.Time.UTC()
+
+	return &utc
+}
+
+func scanExport(row interface{ Scan(...interface{}) error }) (*model.Export, error) {
+	export := model.Export{}
+
+	var from, to sql.NullTime
+
+	err := row.Scan(&export.ExportId, &export.AccountId, &export.Format, &from, &to, &export.Status, &export.Rows, &export.Error)
+	if err != nil {
+		return nil, err
+	}
+
+	export.From = timePointer(from)
+	export.To = timePointer(to)
+
+	return &export, nil
+}
+
+func (e *ExportRepositoryPostgres) CountStatementLines(filter repository.StatementFilter) (uint64, error) {
+	query := `SELECT COUNT(*) FROM transactions
+		WHERE account_id=$1 AND ($2::timestamptz IS NULL OR created_at >= $2) AND ($3::timestamptz IS NULL OR created_at < $3)`
+
+	var count uint64
+
+	err := e.db.QueryRow(query, filter.AccountId, nullTime(filter.From), nullTime(filter.To)).Scan(&count)
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#CountStatementLines: Database query (%s) failed: %s", query, err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	return count, nil
+}
+
+func (e *ExportRepositoryPostgres) StreamStatementLines(filter repository.StatementFilter, fn func(model.StatementLine) error) error {
+	query := `SELECT t.transaction_id, t.account_id, t.operation_type_id, t.amount, o.description, t.created_at
+		FROM transactions t JOIN operation_types o ON o.operation_type_id = t.operation_type_id
+		WHERE t.account_id=$1 AND ($2::timestamptz IS NULL OR t.created_at >= $2) AND ($3::timestamptz IS NULL OR t.created_at < $3)
+		ORDER BY t.created_at, t.transaction_id`
+
+	rows, err := e.db.Query(query, filter.AccountId, nullTime(filter.From), nullTime(filter.To))
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#StreamStatementLines: Database query (%s) failed: %s", query, err)
+
+		return translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		line := model.StatementLine{}
+
+		err := rows.Scan(&line.TransactionId, &line.AccountId, &line.OperationTypeId, &line.Amount, &line.Description, &line.CreatedAt)
+		if err != nil {
+			return translatePostgresError(err)
+		}
+
+		line.CreatedAt = line.CreatedAt.UTC()
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ExportRepositoryPostgres#StreamStatementLines: Reading rows failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
+
+func (e *ExportRepositoryPostgres) CreateExport(ctx context.Context, export model.Export) (*model.Export, error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#CreateExport: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO exports (account_id, format, period_from, period_to, status) VALUES ($1, $2, $3, $4, $5) RETURNING " + exportColumns
+
+	filter := repository.ExportFilter(export)
+
+	created, err := scanExport(tx.QueryRow(query, export.AccountId, export.Format, nullTime(filter.From), nullTime(filter.To), export.Status))
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#CreateExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_EXPORT, created.ExportId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#CreateExport: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ExportRepositoryPostgres#CreateExport: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (e *ExportRepositoryPostgres) FindExport(exportId uint64) (*model.Export, error) {
+	query := "SELECT " + exportColumns + " FROM exports WHERE export_id=$1"
+
+	export, err := scanExport(e.db.QueryRow(query, exportId))
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#FindExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return export, nil
+}
+
+func (e *ExportRepositoryPostgres) ListPendingExports() ([]model.Export, error) {
+	query := "SELECT " + exportColumns + " FROM exports WHERE status=$1 ORDER BY export_id"
+
+	rows, err := e.db.Query(query, model.EXPORT_PENDING)
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#ListPendingExports: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	exports := []model.Export{}
+
+	for rows.Next() {
+		export, err := scanExport(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		exports = append(exports, *export)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ExportRepositoryPostgres#ListPendingExports: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return exports, nil
+}
+
+func (e *ExportRepositoryPostgres) FinishExport(exportId uint64, status string, rows uint64, message string) (*model.Export, error) {
+	query := "UPDATE exports SET status=$2, rows=$3, error=$4 WHERE export_id=$1 RETURNING " + exportColumns
+
+	export, err := scanExport(e.db.QueryRow(query, exportId, status, rows, message))
+
+	if err != nil {
+		log.Printf("ExportRepositoryPostgres#FinishExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return export, nil
+}
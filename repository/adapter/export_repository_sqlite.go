@@ -0,0 +1,238 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ExportRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewExportRepositorySQLite(db *sql.DB) *ExportRepositorySQLite {
+	return &ExportRepositorySQLite{
+		db: db,
+	}
+}
+
+// sqliteTimeLayout matches the strftime format of the created_at default.
+// Timestamps are stored as UTC text in this layout, so they compare in
+// chronological order.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+// sqliteTime stores the zero time as NULL, as it stands for an open period.
+func sqliteTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func parseSQLiteTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation(sqliteTimeLayout, value.String, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func scanExportSQLite(row interface{ Scan(...interface{}) error }) (*model.Export, error) {
+	export := model.Export{}
+
+	var from, to sql.NullString
+
+	err := row.Scan(&export.ExportId, &export.AccountId, &export.Format, &from, &to, &export.Status, &export.Rows, &export.Error)
+	if err != nil {
+		return nil, err
+	}
+
+	if export.From, err = parseSQLiteTime(from); err != nil {
+		return nil, err
+	}
+
+	if export.To, err = parseSQLiteTime(to); err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (e *ExportRepositorySQLite) CountStatementLines(filter repository.StatementFilter) (uint64, error) {
+	query := `SELECT COUNT(*) FROM transactions
+		WHERE account_id=?1 AND (?2 IS NULL OR created_at >= ?2) AND (?3 IS NULL OR created_at < ?3)`
+
+	var count uint64
+
+	err := e.db.QueryRow(query, filter.AccountId, sqliteTime(filter.From), sqliteTime(filter.To)).Scan(&count)
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#CountStatementLines: Database query (%s) failed: %s", query, err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	return count, nil
+}
+
+func (e *ExportRepositorySQLite) StreamStatementLines(filter repository.StatementFilter, fn func(model.StatementLine) error) error {
+	query := `SELECT t.transaction_id, t.account_id, t.operation_type_id, t.amount, o.description, t.created_at
+		FROM transactions t JOIN operation_types o ON o.operation_type_id = t.operation_type_id
+		WHERE t.account_id=?1 AND (?2 IS NULL OR t.created_at >= ?2) AND (?3 IS NULL OR t.created_at < ?3)
+		ORDER BY t.created_at, t.transaction_id`
+
+	rows, err := e.db.Query(query, filter.AccountId, sqliteTime(filter.From), sqliteTime(filter.To))
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#StreamStatementLines: Database query (%s) failed: %s", query, err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		line := model.StatementLine{}
+
+		var createdAt sql.NullString
+
+		err := rows.Scan(&line.TransactionId, &line.AccountId, &line.OperationTypeId, &line.Amount, &line.Description, &createdAt)
+		if err != nil {
+			return translateSQLiteError(err)
+		}
+
+		posted, err := parseSQLiteTime(createdAt)
+		if err != nil {
+			return err
+		}
+
+		line.CreatedAt = *posted
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ExportRepositorySQLite#StreamStatementLines: Reading rows failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
+
+func (e *ExportRepositorySQLite) CreateExport(ctx context.Context, export model.Export) (*model.Export, error) {
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#CreateExport: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO exports (account_id, format, period_from, period_to, status) VALUES (?, ?, ?, ?, ?) RETURNING " + exportColumns
+
+	filter := repository.ExportFilter(export)
+
+	created, err := scanExportSQLite(tx.QueryRow(query, export.AccountId, export.Format, sqliteTime(filter.From), sqliteTime(filter.To), export.Status))
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#CreateExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_EXPORT, created.ExportId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#CreateExport: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ExportRepositorySQLite#CreateExport: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (e *ExportRepositorySQLite) FindExport(exportId uint64) (*model.Export, error) {
+	query := "SELECT " + exportColumns + " FROM exports WHERE export_id=?"
+
+	export, err := scanExportSQLite(e.db.QueryRow(query, exportId))
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#FindExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return export, nil
+}
+
+func (e *ExportRepositorySQLite) ListPendingExports() ([]model.Export, error) {
+	query := "SELECT " + exportColumns + " FROM exports WHERE status=? ORDER BY export_id"
+
+	rows, err := e.db.Query(query, model.EXPORT_PENDING)
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#ListPendingExports: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	exports := []model.Export{}
+
+	for rows.Next() {
+		export, err := scanExportSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		exports = append(exports, *export)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ExportRepositorySQLite#ListPendingExports: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return exports, nil
+}
+
+func (e *ExportRepositorySQLite) FinishExport(exportId uint64, status string, rows uint64, message string) (*model.Export, error) {
+	query := "UPDATE exports SET status=?2, rows=?3, error=?4 WHERE export_id=?1 RETURNING " + exportColumns
+
+	export, err := scanExportSQLite(e.db.QueryRow(query, exportId, status, rows, message))
+
+	if err != nil {
+		log.Printf("ExportRepositorySQLite#FinishExport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return export, nil
+}
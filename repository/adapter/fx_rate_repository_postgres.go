@@ -0,0 +1,152 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const fxRateColumns = "fx_rate_id, base_currency, quote_currency, rate, effective_at"
+
+type FxRateRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewFxRateRepositoryPostgres(db *sql.DB) *FxRateRepositoryPostgres {
+	return &FxRateRepositoryPostgres{
+		db: db,
+	}
+}
+
+func scanFxRate(row interface{ Scan(...interface{}) error }) (*model.FxRate, error) {
+	rate := model.FxRate{}
+
+	err := row.Scan(&rate.FxRateId, &rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate, &rate.EffectiveAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rate.EffectiveAt = rate.EffectiveAt.UTC()
+
+	return &rate, nil
+}
+
+func (f *FxRateRepositoryPostgres) CreateFxRates(ctx context.Context, rates []model.FxRate) ([]model.FxRate, error) {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("FxRateRepositoryPostgres#CreateFxRates: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO fx_rates (base_currency, quote_currency, rate, effective_at) VALUES ($1, $2, $3, $4) RETURNING " + fxRateColumns
+
+	created := make([]model.FxRate, 0, len(rates))
+	entries := make([]model.AuditEntry, 0, len(rates))
+
+	for _, rate := range rates {
+		stored, err := scanFxRate(tx.QueryRow(query, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate, rate.EffectiveAt.UTC()))
+
+		if err != nil {
+			log.Printf("FxRateRepositoryPostgres#CreateFxRates: Database query (%s) failed: %s", query, err)
+
+			return nil, translatePostgresError(err)
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_FX_RATE, stored.FxRateId, nil, stored)
+		if err != nil {
+			return nil, err
+		}
+
+		created = append(created, *stored)
+		entries = append(entries, entry)
+	}
+
+	if err := appendAuditPostgres(tx, entries...); err != nil {
+		log.Printf("FxRateRepositoryPostgres#CreateFxRates: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("FxRateRepositoryPostgres#CreateFxRates: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (f *FxRateRepositoryPostgres) ListFxRates(filter repository.FxRateFilter, page repository.Page) ([]model.FxRate, error) {
+	query := `SELECT ` + fxRateColumns + ` FROM fx_rates WHERE fx_rate_id > $1
+		AND ($2 = '' OR base_currency = $2) AND ($3 = '' OR quote_currency = $3) ORDER BY fx_rate_id LIMIT $4`
+
+	rows, err := f.db.Query(query, page.AfterId, filter.BaseCurrency, filter.QuoteCurrency, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("FxRateRepositoryPostgres#ListFxRates: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	rates := []model.FxRate{}
+
+	for rows.Next() {
+		rate, err := scanFxRate(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		rates = append(rates, *rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("FxRateRepositoryPostgres#ListFxRates: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return rates, nil
+}
+
+func (f *FxRateRepositoryPostgres) FindFxRate(base string, quote string, at time.Time) (*model.FxRate, error) {
+	rate, err := findFxRatePostgres(f.db, base, quote, at)
+
+	if err != nil && !errors.Is(err, repository.ErrFxRateNotFound) {
+		log.Printf("FxRateRepositoryPostgres#FindFxRate: Finding the rate failed: %s", err)
+	}
+
+	return rate, err
+}
+
+// fxRateQuery selects the latest rate of a pair to take effect up to a time.
+const fxRateQuery = `SELECT ` + fxRateColumns + ` FROM fx_rates
+	WHERE base_currency = $1 AND quote_currency = $2 AND effective_at <= $3 ORDER BY effective_at DESC LIMIT 1`
+
+// findFxRatePostgres finds the rate with q, which may be the transaction
+// posting the transactions converted at it.
+func findFxRatePostgres(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, base string, quote string, at time.Time) (*model.FxRate, error) {
+	rate, err := scanFxRate(q.QueryRow(fxRateQuery, base, quote, at.UTC()))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrFxRateNotFound
+	}
+
+	if err != nil {
+		return nil, translatePostgresError(err)
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,153 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type FxRateRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewFxRateRepositorySQLite(db *sql.DB) *FxRateRepositorySQLite {
+	return &FxRateRepositorySQLite{
+		db: db,
+	}
+}
+
+func scanFxRateSQLite(row interface{ Scan(...interface{}) error }) (*model.FxRate, error) {
+	rate := model.FxRate{}
+
+	var effectiveAt string
+
+	err := row.Scan(&rate.FxRateId, &rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate, &effectiveAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rate.EffectiveAt, err = time.ParseInLocation(sqliteTimeLayout, effectiveAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (f *FxRateRepositorySQLite) CreateFxRates(ctx context.Context, rates []model.FxRate) ([]model.FxRate, error) {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("FxRateRepositorySQLite#CreateFxRates: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO fx_rates (base_currency, quote_currency, rate, effective_at) VALUES (?1, ?2, ?3, ?4) RETURNING " + fxRateColumns
+
+	created := make([]model.FxRate, 0, len(rates))
+	entries := make([]model.AuditEntry, 0, len(rates))
+
+	for _, rate := range rates {
+		stored, err := scanFxRateSQLite(tx.QueryRow(query, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate, sqliteTime(rate.EffectiveAt)))
+
+		if err != nil {
+			log.Printf("FxRateRepositorySQLite#CreateFxRates: Database query (%s) failed: %s", query, err)
+
+			return nil, translateSQLiteError(err)
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_FX_RATE, stored.FxRateId, nil, stored)
+		if err != nil {
+			return nil, err
+		}
+
+		created = append(created, *stored)
+		entries = append(entries, entry)
+	}
+
+	if err := appendAuditSQLite(tx, entries...); err != nil {
+		log.Printf("FxRateRepositorySQLite#CreateFxRates: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("FxRateRepositorySQLite#CreateFxRates: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (f *FxRateRepositorySQLite) ListFxRates(filter repository.FxRateFilter, page repository.Page) ([]model.FxRate, error) {
+	query := `SELECT ` + fxRateColumns + ` FROM fx_rates WHERE fx_rate_id > ?1
+		AND (?2 = '' OR base_currency = ?2) AND (?3 = '' OR quote_currency = ?3) ORDER BY fx_rate_id LIMIT ?4`
+
+	rows, err := f.db.Query(query, page.AfterId, filter.BaseCurrency, filter.QuoteCurrency, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("FxRateRepositorySQLite#ListFxRates: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	rates := []model.FxRate{}
+
+	for rows.Next() {
+		rate, err := scanFxRateSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		rates = append(rates, *rate)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("FxRateRepositorySQLite#ListFxRates: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return rates, nil
+}
+
+func (f *FxRateRepositorySQLite) FindFxRate(base string, quote string, at time.Time) (*model.FxRate, error) {
+	rate, err := findFxRateSQLite(f.db, base, quote, at)
+
+	if err != nil && !errors.Is(err, repository.ErrFxRateNotFound) {
+		log.Printf("FxRateRepositorySQLite#FindFxRate: Finding the rate failed: %s", err)
+	}
+
+	return rate, err
+}
+
+// findFxRateSQLite finds the rate with q, which may be the transaction
+// posting the transactions converted at it.
+func findFxRateSQLite(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, base string, quote string, at time.Time) (*model.FxRate, error) {
+	query := `SELECT ` + fxRateColumns + ` FROM fx_rates
+		WHERE base_currency = ?1 AND quote_currency = ?2 AND effective_at <= ?3 ORDER BY effective_at DESC LIMIT 1`
+
+	rate, err := scanFxRateSQLite(q.QueryRow(query, base, quote, sqliteTime(at)))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrFxRateNotFound
+	}
+
+	if err != nil {
+		return nil, translateSQLiteError(err)
+	}
+
+	return rate, nil
+}
@@ -0,0 +1,398 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const holderColumns = "holder_id, name, birth_date, email, phone, address, created_at, updated_at"
+
+// sealedHolder is the personal data of a holder as stored, encrypted with
+// the cipher of the repository under the master key keyId, the address as
+// JSON.
+type sealedHolder struct {
+	name      string
+	birthDate string
+	email     string
+	phone     string
+	address   string
+	keyId     string
+}
+
+func sealHolder(c pii.Cipher, holder model.Holder) (*sealedHolder, error) {
+	address, err := json.Marshal(holder.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := &sealedHolder{keyId: c.KeyId()}
+
+	for _, field := range []struct {
+		plaintext  string
+		ciphertext *string
+	}{
+		{holder.Name, &sealed.name},
+		{holder.BirthDate, &sealed.birthDate},
+		{holder.Email, &sealed.email},
+		{holder.Phone, &sealed.phone},
+		{string(address), &sealed.address},
+	} {
+		if *field.ciphertext, err = c.Encrypt(field.plaintext); err != nil {
+			return nil, err
+		}
+	}
+
+	return sealed, nil
+}
+
+// open decrypts the personal data into holder.
+func (s *sealedHolder) open(c pii.Cipher, holder *model.Holder) error {
+	var address string
+	var err error
+
+	for _, field := range []struct {
+		ciphertext string
+		plaintext  *string
+	}{
+		{s.name, &holder.Name},
+		{s.birthDate, &holder.BirthDate},
+		{s.email, &holder.Email},
+		{s.phone, &holder.Phone},
+		{s.address, &address},
+	} {
+		if *field.plaintext, err = c.Decrypt(field.ciphertext); err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal([]byte(address), &holder.Address)
+}
+
+type HolderRepositoryPostgres struct {
+	db     *sql.DB
+	cipher pii.Cipher
+}
+
+func NewHolderRepositoryPostgres(db *sql.DB, cipher pii.Cipher) *HolderRepositoryPostgres {
+	return &HolderRepositoryPostgres{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+func (h *HolderRepositoryPostgres) scanHolder(row interface{ Scan(...interface{}) error }) (*model.Holder, error) {
+	holder := model.Holder{}
+	sealed := sealedHolder{}
+
+	err := row.Scan(&holder.HolderId, &sealed.name, &sealed.birthDate, &sealed.email, &sealed.phone, &sealed.address, &holder.CreatedAt, &holder.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sealed.open(h.cipher, &holder); err != nil {
+		return nil, err
+	}
+
+	holder.CreatedAt = holder.CreatedAt.UTC()
+	holder.UpdatedAt = holder.UpdatedAt.UTC()
+
+	return &holder, nil
+}
+
+func (h *HolderRepositoryPostgres) CreateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	sealed, err := sealHolder(h.cipher, holder)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#CreateHolder: Encrypting the holder failed: %s", err)
+
+		return nil, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#CreateHolder: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	holder.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	holder.UpdatedAt = holder.CreatedAt
+
+	query := "INSERT INTO holders (name, birth_date, email, phone, address, pii_key_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING holder_id"
+
+	err = tx.QueryRow(query, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId, holder.CreatedAt, holder.UpdatedAt).Scan(&holder.HolderId)
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#CreateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, nil, holder.Redacted())
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#CreateHolder: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositoryPostgres#CreateHolder: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return &holder, nil
+}
+
+func (h *HolderRepositoryPostgres) FindHolder(holderId uint64) (*model.Holder, error) {
+	query := "SELECT " + holderColumns + " FROM holders WHERE holder_id=$1"
+
+	holder, err := h.scanHolder(h.db.QueryRow(query, holderId))
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#FindHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return holder, nil
+}
+
+func (h *HolderRepositoryPostgres) ListHolders(page repository.Page) ([]model.Holder, error) {
+	query := "SELECT " + holderColumns + " FROM holders WHERE holder_id > $1 ORDER BY holder_id LIMIT $2"
+
+	rows, err := h.db.Query(query, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#ListHolders: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	holders := []model.Holder{}
+
+	for rows.Next() {
+		holder, err := h.scanHolder(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		holders = append(holders, *holder)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("HolderRepositoryPostgres#ListHolders: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return holders, nil
+}
+
+func (h *HolderRepositoryPostgres) UpdateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	sealed, err := sealHolder(h.cipher, holder)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Encrypting the holder failed: %s", err)
+
+		return nil, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	before := model.Holder{HolderId: holder.HolderId}
+
+	query := "SELECT created_at, updated_at FROM holders WHERE holder_id=$1 FOR UPDATE"
+
+	err = tx.QueryRow(query, holder.HolderId).Scan(&before.CreatedAt, &before.UpdatedAt)
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	before.CreatedAt = before.CreatedAt.UTC()
+	before.UpdatedAt = before.UpdatedAt.UTC()
+
+	holder.CreatedAt = before.CreatedAt
+	holder.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	query = "UPDATE holders SET name=$2, birth_date=$3, email=$4, phone=$5, address=$6, pii_key_id=$7, updated_at=$8 WHERE holder_id=$1"
+
+	_, err = tx.Exec(query, holder.HolderId, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId, holder.UpdatedAt)
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, before, holder.Redacted())
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositoryPostgres#UpdateHolder: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return &holder, nil
+}
+
+// ReencryptPersonalData re-encrypts the personal data of the holders under
+// the current master key, leaving their updated_at as is, and locking them
+// so concurrent rotators skip them.
+func (h *HolderRepositoryPostgres) ReencryptPersonalData(ctx context.Context, limit int) (int, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Beginning transaction failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + holderColumns + " FROM holders WHERE pii_key_id IS DISTINCT FROM $1 ORDER BY holder_id LIMIT $2 FOR UPDATE SKIP LOCKED"
+
+	rows, err := tx.Query(query, h.cipher.KeyId(), limit)
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	holders := []model.Holder{}
+
+	for rows.Next() {
+		holder, err := h.scanHolder(rows)
+		if err != nil {
+			rows.Close()
+
+			log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Reading rows failed: %s", err)
+
+			return 0, translatePostgresError(err)
+		}
+
+		holders = append(holders, *holder)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Reading rows failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	query = "UPDATE holders SET name=$2, birth_date=$3, email=$4, phone=$5, address=$6, pii_key_id=$7 WHERE holder_id=$1"
+
+	for _, holder := range holders {
+		sealed, err := sealHolder(h.cipher, holder)
+		if err != nil {
+			log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Encrypting the holder failed: %s", err)
+
+			return 0, err
+		}
+
+		_, err = tx.Exec(query, holder.HolderId, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId)
+
+		if err != nil {
+			log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+			return 0, translatePostgresError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositoryPostgres#ReencryptPersonalData: Committing transaction failed: %s", err)
+
+		return 0, translatePostgresError(err)
+	}
+
+	return len(holders), nil
+}
+
+func (h *HolderRepositoryPostgres) DeleteHolder(ctx context.Context, holderId uint64) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#DeleteHolder: Beginning transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM holders WHERE holder_id=$1 RETURNING " + holderColumns
+
+	deleted, err := h.scanHolder(tx.QueryRow(query, holderId))
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#DeleteHolder: Database query (%s) failed: %s", query, err)
+
+		return deleteHolderError(translatePostgresError(err))
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_HOLDER, holderId, deleted.Redacted(), nil)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositoryPostgres#DeleteHolder: Appending to the audit log failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositoryPostgres#DeleteHolder: Committing transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
+
+// deleteHolderError reports the holders still referenced by accounts or
+// cards as conflicting with them.
+func deleteHolderError(err error) error {
+	if errors.Is(err, repository.ErrForeignKeyViolation) {
+		return wrapError(repository.ErrConflict, err)
+	}
+
+	return err
+}
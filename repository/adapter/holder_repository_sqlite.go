@@ -0,0 +1,336 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type HolderRepositorySQLite struct {
+	db     *sql.DB
+	cipher pii.Cipher
+}
+
+func NewHolderRepositorySQLite(db *sql.DB, cipher pii.Cipher) *HolderRepositorySQLite {
+	return &HolderRepositorySQLite{
+		db:     db,
+		cipher: cipher,
+	}
+}
+
+func (h *HolderRepositorySQLite) scanHolder(row interface{ Scan(...interface{}) error }) (*model.Holder, error) {
+	holder := model.Holder{}
+	sealed := sealedHolder{}
+
+	var createdAt, updatedAt string
+
+	err := row.Scan(&holder.HolderId, &sealed.name, &sealed.birthDate, &sealed.email, &sealed.phone, &sealed.address, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sealed.open(h.cipher, &holder); err != nil {
+		return nil, err
+	}
+
+	if holder.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	if holder.UpdatedAt, err = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &holder, nil
+}
+
+func (h *HolderRepositorySQLite) CreateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	sealed, err := sealHolder(h.cipher, holder)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#CreateHolder: Encrypting the holder failed: %s", err)
+
+		return nil, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#CreateHolder: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	holder.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	holder.UpdatedAt = holder.CreatedAt
+
+	query := "INSERT INTO holders (name, birth_date, email, phone, address, pii_key_id, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8) RETURNING holder_id"
+
+	err = tx.QueryRow(query, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId, sqliteTime(holder.CreatedAt), sqliteTime(holder.UpdatedAt)).Scan(&holder.HolderId)
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#CreateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, nil, holder.Redacted())
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#CreateHolder: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositorySQLite#CreateHolder: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return &holder, nil
+}
+
+func (h *HolderRepositorySQLite) FindHolder(holderId uint64) (*model.Holder, error) {
+	query := "SELECT " + holderColumns + " FROM holders WHERE holder_id=?"
+
+	holder, err := h.scanHolder(h.db.QueryRow(query, holderId))
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#FindHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return holder, nil
+}
+
+func (h *HolderRepositorySQLite) ListHolders(page repository.Page) ([]model.Holder, error) {
+	query := "SELECT " + holderColumns + " FROM holders WHERE holder_id > ?1 ORDER BY holder_id LIMIT ?2"
+
+	rows, err := h.db.Query(query, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#ListHolders: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	holders := []model.Holder{}
+
+	for rows.Next() {
+		holder, err := h.scanHolder(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		holders = append(holders, *holder)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("HolderRepositorySQLite#ListHolders: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return holders, nil
+}
+
+func (h *HolderRepositorySQLite) UpdateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	sealed, err := sealHolder(h.cipher, holder)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Encrypting the holder failed: %s", err)
+
+		return nil, err
+	}
+
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	before := model.Holder{HolderId: holder.HolderId}
+
+	var createdAt, updatedAt string
+
+	query := "SELECT created_at, updated_at FROM holders WHERE holder_id=?"
+
+	err = tx.QueryRow(query, holder.HolderId).Scan(&createdAt, &updatedAt)
+
+	if err == nil {
+		before.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC)
+	}
+
+	if err == nil {
+		before.UpdatedAt, err = time.ParseInLocation(sqliteTimeLayout, updatedAt, time.UTC)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	holder.CreatedAt = before.CreatedAt
+	holder.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	query = "UPDATE holders SET name=?2, birth_date=?3, email=?4, phone=?5, address=?6, pii_key_id=?7, updated_at=?8 WHERE holder_id=?1"
+
+	_, err = tx.Exec(query, holder.HolderId, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId, sqliteTime(holder.UpdatedAt))
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, before, holder.Redacted())
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositorySQLite#UpdateHolder: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return &holder, nil
+}
+
+// ReencryptPersonalData re-encrypts the personal data of the holders under
+// the current master key, leaving their updated_at as is.
+func (h *HolderRepositorySQLite) ReencryptPersonalData(ctx context.Context, limit int) (int, error) {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Beginning transaction failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + holderColumns + " FROM holders WHERE pii_key_id IS NOT ?1 ORDER BY holder_id LIMIT ?2"
+
+	rows, err := tx.Query(query, h.cipher.KeyId(), limit)
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	holders := []model.Holder{}
+
+	for rows.Next() {
+		holder, err := h.scanHolder(rows)
+		if err != nil {
+			rows.Close()
+
+			log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Reading rows failed: %s", err)
+
+			return 0, translateSQLiteError(err)
+		}
+
+		holders = append(holders, *holder)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Reading rows failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	query = "UPDATE holders SET name=?2, birth_date=?3, email=?4, phone=?5, address=?6, pii_key_id=?7 WHERE holder_id=?1"
+
+	for _, holder := range holders {
+		sealed, err := sealHolder(h.cipher, holder)
+		if err != nil {
+			log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Encrypting the holder failed: %s", err)
+
+			return 0, err
+		}
+
+		_, err = tx.Exec(query, holder.HolderId, sealed.name, sealed.birthDate, sealed.email, sealed.phone, sealed.address, sealed.keyId)
+
+		if err != nil {
+			log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Database query (%s) failed: %s", query, err)
+
+			return 0, translateSQLiteError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositorySQLite#ReencryptPersonalData: Committing transaction failed: %s", err)
+
+		return 0, translateSQLiteError(err)
+	}
+
+	return len(holders), nil
+}
+
+func (h *HolderRepositorySQLite) DeleteHolder(ctx context.Context, holderId uint64) error {
+	tx, err := h.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#DeleteHolder: Beginning transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM holders WHERE holder_id=? RETURNING " + holderColumns
+
+	deleted, err := h.scanHolder(tx.QueryRow(query, holderId))
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#DeleteHolder: Database query (%s) failed: %s", query, err)
+
+		return deleteHolderError(translateSQLiteError(err))
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_HOLDER, holderId, deleted.Redacted(), nil)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("HolderRepositorySQLite#DeleteHolder: Appending to the audit log failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("HolderRepositorySQLite#DeleteHolder: Committing transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,292 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const importColumns = "import_id, format, status, processed_lines, imported_rows, rejected_rows, error, COALESCE(idempotency_key, '')"
+
+type ImportRepositoryPostgres struct {
+	db          *sql.DB
+	projections *ProjectionRepositoryPostgres
+}
+
+func NewImportRepositoryPostgres(db *sql.DB) *ImportRepositoryPostgres {
+	return &ImportRepositoryPostgres{
+		db:          db,
+		projections: NewProjectionRepositoryPostgres(db),
+	}
+}
+
+func scanImport(row interface{ Scan(...interface{}) error }) (*model.Import, error) {
+	imp := model.Import{}
+
+	err := row.Scan(&imp.ImportId, &imp.Format, &imp.Status, &imp.ProcessedLines, &imp.ImportedRows, &imp.RejectedRows, &imp.Error, &imp.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imp, nil
+}
+
+func (i *ImportRepositoryPostgres) CreateImport(ctx context.Context, imp model.Import) (*model.Import, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#CreateImport: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO imports (format, status, idempotency_key) VALUES ($1, $2, NULLIF($3, '')) RETURNING " + importColumns
+
+	created, err := scanImport(tx.QueryRow(query, imp.Format, imp.Status, imp.IdempotencyKey))
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#CreateImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_IMPORT, created.ImportId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#CreateImport: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ImportRepositoryPostgres#CreateImport: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (i *ImportRepositoryPostgres) FindImport(importId uint64) (*model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE import_id=$1"
+
+	imp, err := scanImport(i.db.QueryRow(query, importId))
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#FindImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositoryPostgres) FindImportByIdempotencyKey(idempotencyKey string) (*model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE idempotency_key=$1"
+
+	imp, err := scanImport(i.db.QueryRow(query, idempotencyKey))
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#FindImportByIdempotencyKey: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositoryPostgres) ListUnfinishedImports() ([]model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE status IN ($1, $2) ORDER BY import_id"
+
+	rows, err := i.db.Query(query, model.IMPORT_PENDING, model.IMPORT_RUNNING)
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#ListUnfinishedImports: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	imports := []model.Import{}
+
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		imports = append(imports, *imp)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ImportRepositoryPostgres#ListUnfinishedImports: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return imports, nil
+}
+
+// SaveImportBatch moves the import forward first, which locks its row until
+// the transactions are posted, the rejections copied and the batch is
+// committed.
+func (i *ImportRepositoryPostgres) SaveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	var imp *model.Import
+
+	err := retryAppendPostgres(context.Background(), func() (err error) {
+		imp, err = i.saveImportBatch(importId, batch)
+
+		return err
+	})
+
+	return imp, err
+}
+
+func (i *ImportRepositoryPostgres) saveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	tx, err := i.db.Begin()
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#SaveImportBatch: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `UPDATE imports SET processed_lines = $2, imported_rows = imported_rows + $3, rejected_rows = rejected_rows + $4, status = $5
+		WHERE import_id = $1 AND processed_lines < $2 AND status IN ($5, $6) RETURNING ` + importColumns
+
+	imp, err := scanImport(tx.QueryRow(query, importId, batch.ProcessedLines, len(batch.Transactions), batch.RejectedRows, model.IMPORT_RUNNING, model.IMPORT_PENDING))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := i.FindImport(importId); err != nil {
+			return nil, err
+		}
+
+		log.Printf("ImportRepositoryPostgres#SaveImportBatch: Import %d already went past line %d", importId, batch.ProcessedLines)
+
+		return nil, repository.ErrConflict
+	}
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#SaveImportBatch: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if len(batch.Transactions) > 0 {
+		if _, err := postTransactionsPostgres(context.Background(), tx, batch.Transactions); err != nil {
+			log.Printf("ImportRepositoryPostgres#SaveImportBatch: Appending events failed: %s", err)
+
+			return nil, translatePostgresError(err)
+		}
+	}
+
+	err = copyRows(tx, pq.CopyIn("import_rejections", "import_id", "line", "field", "code", "message"), len(batch.Rejections), func(n int) []interface{} {
+		rejection := batch.Rejections[n]
+
+		return []interface{}{importId, rejection.Line, rejection.Field, rejection.Code, rejection.Message}
+	})
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#SaveImportBatch: Copying rejections failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ImportRepositoryPostgres#SaveImportBatch: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	catchUpProjections(i.projections, "ImportRepositoryPostgres#SaveImportBatch")
+
+	return imp, nil
+}
+
+// copyRows streams count rows into a COPY statement, which is much faster
+// than inserting them one by one.
+func copyRows(tx *sql.Tx, statement string, count int, row func(n int) []interface{}) error {
+	if count == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(statement)
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for n := 0; n < count; n++ {
+		if _, err := stmt.Exec(row(n)...); err != nil {
+			return err
+		}
+	}
+
+	_, err = stmt.Exec()
+
+	return err
+}
+
+func (i *ImportRepositoryPostgres) FinishImport(importId uint64, status string, message string) (*model.Import, error) {
+	query := "UPDATE imports SET status=$2, error=$3 WHERE import_id=$1 RETURNING " + importColumns
+
+	imp, err := scanImport(i.db.QueryRow(query, importId, status, message))
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#FinishImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositoryPostgres) ListImportRejections(importId uint64, page repository.Page) ([]model.ImportRejection, error) {
+	query := "SELECT import_rejection_id, line, field, code, message FROM import_rejections WHERE import_id=$1 AND import_rejection_id > $2 ORDER BY import_rejection_id LIMIT $3"
+
+	rows, err := i.db.Query(query, importId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("ImportRepositoryPostgres#ListImportRejections: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	rejections := []model.ImportRejection{}
+
+	for rows.Next() {
+		rejection := model.ImportRejection{}
+
+		err := rows.Scan(&rejection.ImportRejectionId, &rejection.Line, &rejection.Field, &rejection.Code, &rejection.Message)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		rejections = append(rejections, rejection)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ImportRepositoryPostgres#ListImportRejections: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return rejections, nil
+}
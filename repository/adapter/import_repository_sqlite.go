@@ -0,0 +1,281 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ImportRepositorySQLite struct {
+	db          *sql.DB
+	projections *ProjectionRepositorySQLite
+}
+
+func NewImportRepositorySQLite(db *sql.DB) *ImportRepositorySQLite {
+	return &ImportRepositorySQLite{
+		db:          db,
+		projections: NewProjectionRepositorySQLite(db),
+	}
+}
+
+func (i *ImportRepositorySQLite) CreateImport(ctx context.Context, imp model.Import) (*model.Import, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#CreateImport: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO imports (format, status, idempotency_key) VALUES (?, ?, NULLIF(?, '')) RETURNING " + importColumns
+
+	created, err := scanImport(tx.QueryRow(query, imp.Format, imp.Status, imp.IdempotencyKey))
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#CreateImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_IMPORT, created.ImportId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#CreateImport: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ImportRepositorySQLite#CreateImport: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (i *ImportRepositorySQLite) FindImport(importId uint64) (*model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE import_id=?"
+
+	imp, err := scanImport(i.db.QueryRow(query, importId))
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#FindImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositorySQLite) FindImportByIdempotencyKey(idempotencyKey string) (*model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE idempotency_key=?"
+
+	imp, err := scanImport(i.db.QueryRow(query, idempotencyKey))
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#FindImportByIdempotencyKey: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositorySQLite) ListUnfinishedImports() ([]model.Import, error) {
+	query := "SELECT " + importColumns + " FROM imports WHERE status IN (?, ?) ORDER BY import_id"
+
+	rows, err := i.db.Query(query, model.IMPORT_PENDING, model.IMPORT_RUNNING)
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#ListUnfinishedImports: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	imports := []model.Import{}
+
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		imports = append(imports, *imp)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ImportRepositorySQLite#ListUnfinishedImports: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return imports, nil
+}
+
+// SaveImportBatch moves the import forward first, which takes the write lock
+// of the database until the batch is committed.
+func (i *ImportRepositorySQLite) SaveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	tx, err := i.db.Begin()
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#SaveImportBatch: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `UPDATE imports SET processed_lines = ?2, imported_rows = imported_rows + ?3, rejected_rows = rejected_rows + ?4, status = ?5
+		WHERE import_id = ?1 AND processed_lines < ?2 AND status IN (?5, ?6) RETURNING ` + importColumns
+
+	imp, err := scanImport(tx.QueryRow(query, importId, batch.ProcessedLines, len(batch.Transactions), batch.RejectedRows, model.IMPORT_RUNNING, model.IMPORT_PENDING))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := i.FindImport(importId); err != nil {
+			return nil, err
+		}
+
+		log.Printf("ImportRepositorySQLite#SaveImportBatch: Import %d already went past line %d", importId, batch.ProcessedLines)
+
+		return nil, repository.ErrConflict
+	}
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#SaveImportBatch: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if len(batch.Transactions) > 0 {
+		if _, err := postTransactionsSQLite(context.Background(), tx, batch.Transactions); err != nil {
+			log.Printf("ImportRepositorySQLite#SaveImportBatch: Appending events failed: %s", err)
+
+			return nil, translateSQLiteError(err)
+		}
+	}
+
+	err = insertRows(tx, "import_rejections", []string{"import_id", "line", "field", "code", "message"}, len(batch.Rejections), func(n int) []interface{} {
+		rejection := batch.Rejections[n]
+
+		return []interface{}{importId, rejection.Line, rejection.Field, rejection.Code, rejection.Message}
+	})
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#SaveImportBatch: Inserting rejections failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ImportRepositorySQLite#SaveImportBatch: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	catchUpProjections(i.projections, "ImportRepositorySQLite#SaveImportBatch")
+
+	return imp, nil
+}
+
+// insertRowsChunk keeps the number of parameters of a statement under the
+// limit of older SQLite versions for rows of up to 9 columns.
+const insertRowsChunk = 100
+
+// sqliteMaxParameters is the limit of older SQLite versions.
+const sqliteMaxParameters = 999
+
+// insertRows inserts count rows with multi-row INSERT statements, as SQLite
+// has no COPY.
+func insertRows(tx *sql.Tx, table string, columns []string, count int, row func(n int) []interface{}) error {
+	chunk := insertRowsChunk
+
+	if chunk*len(columns) > sqliteMaxParameters {
+		chunk = sqliteMaxParameters / len(columns)
+	}
+
+	for start := 0; start < count; start += chunk {
+		end := start + chunk
+
+		if end > count {
+			end = count
+		}
+
+		placeholder := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+		values := strings.TrimSuffix(strings.Repeat(placeholder+", ", end-start), ", ")
+
+		args := []interface{}{}
+
+		for n := start; n < end; n++ {
+			args = append(args, row(n)...)
+		}
+
+		_, err := tx.Exec("INSERT INTO "+table+" ("+strings.Join(columns, ", ")+") VALUES "+values, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *ImportRepositorySQLite) FinishImport(importId uint64, status string, message string) (*model.Import, error) {
+	query := "UPDATE imports SET status=?2, error=?3 WHERE import_id=?1 RETURNING " + importColumns
+
+	imp, err := scanImport(i.db.QueryRow(query, importId, status, message))
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#FinishImport: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return imp, nil
+}
+
+func (i *ImportRepositorySQLite) ListImportRejections(importId uint64, page repository.Page) ([]model.ImportRejection, error) {
+	query := "SELECT import_rejection_id, line, field, code, message FROM import_rejections WHERE import_id=? AND import_rejection_id > ? ORDER BY import_rejection_id LIMIT ?"
+
+	rows, err := i.db.Query(query, importId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("ImportRepositorySQLite#ListImportRejections: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	rejections := []model.ImportRejection{}
+
+	for rows.Next() {
+		rejection := model.ImportRejection{}
+
+		err := rows.Scan(&rejection.ImportRejectionId, &rejection.Line, &rejection.Field, &rejection.Code, &rejection.Message)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		rejections = append(rejections, rejection)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ImportRepositorySQLite#ListImportRejections: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return rejections, nil
+}
@@ -0,0 +1,174 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type AccountRepositoryMemory struct {
+	store *Store
+}
+
+func NewAccountRepositoryMemory(store *Store) *AccountRepositoryMemory {
+	return &AccountRepositoryMemory{
+		store: store,
+	}
+}
+
+func (a *AccountRepositoryMemory) CreateAccount(ctx context.Context, account model.Account) (*model.Account, error) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	if _, ok := a.store.holders[account.HolderId]; account.HolderId != 0 && !ok {
+		log.Printf("AccountRepositoryMemory#CreateAccount: No holder found for ID %d", account.HolderId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	account.AccountId = a.store.accountSequence + 1
+
+	if account.Currency == "" {
+		account.Currency = model.DEFAULT_CURRENCY
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_ACCOUNT, account.AccountId, nil, account.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := model.NewEvent(model.EVENT_ACCOUNT_OPENED, account.AccountId, 0, account.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	// The event holds no document number, so the account projected is
+	// kept along with the state of the streams.
+	a.store.accountSequence++
+	a.store.opened[account.AccountId] = account
+	a.store.appendEvents(event)
+	a.store.appendAudit(entry)
+
+	return &account, nil
+}
+
+func (a *AccountRepositoryMemory) FindAccount(accountId uint64) (*model.Account, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	account, ok := a.store.accounts[accountId]
+
+	if !ok {
+		log.Printf("AccountRepositoryMemory#FindAccount: No account found for ID %d", accountId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &account, nil
+}
+
+func (a *AccountRepositoryMemory) FindAccounts(accountIds []uint64) ([]model.Account, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	accounts := []model.Account{}
+	seen := map[uint64]bool{}
+
+	for _, accountId := range accountIds {
+		if account, ok := a.store.accounts[accountId]; ok && !seen[accountId] {
+			accounts = append(accounts, account)
+			seen[accountId] = true
+		}
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositoryMemory) ListAccounts(filter repository.AccountFilter, page repository.Page) ([]model.Account, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	accounts := []model.Account{}
+
+	for accountId := page.AfterId + 1; accountId <= a.store.accountSequence && len(accounts) < page.EffectiveLimit(); accountId++ {
+		account, ok := a.store.accounts[accountId]
+
+		if !ok || (filter.DocumentNumber != 0 && account.DocumentNumber != filter.DocumentNumber) || (filter.HolderId != 0 && account.HolderId != filter.HolderId) {
+			continue
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (a *AccountRepositoryMemory) BlockAccount(ctx context.Context, accountId uint64) (*model.Account, error) {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	account, ok := a.store.accounts[accountId]
+
+	if !ok {
+		log.Printf("AccountRepositoryMemory#BlockAccount: No account found for ID %d", accountId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	if a.store.blocked[accountId] {
+		log.Printf("AccountRepositoryMemory#BlockAccount: Account %d is already blocked", accountId)
+
+		return nil, repository.ErrConflict
+	}
+
+	if err := a.store.checkExpectedVersion(ctx, accountId); err != nil {
+		return nil, err
+	}
+
+	before := account
+	before.Blocked = false
+	account.Blocked = true
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_ACCOUNT, accountId, before.Redacted(), account.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := model.NewEvent(model.EVENT_ACCOUNT_BLOCKED, accountId, 0, struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	a.store.appendEvents(event)
+	a.store.appendAudit(entry)
+
+	return &account, nil
+}
+
+func (a *AccountRepositoryMemory) FindBalance(accountId uint64, asOf time.Time) (*model.Balance, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	balance, ok := a.store.balances[accountId]
+	balance.Blocked = a.store.accounts[accountId].Blocked
+
+	if !asOf.IsZero() {
+		asOf = asOf.UTC()
+
+		balance = a.store.balanceAsOf(accountId, asOf)
+		balance.AsOf = &asOf
+		ok = balance.Version > 0
+	}
+
+	if !ok {
+		log.Printf("AccountRepositoryMemory#FindBalance: No balance found for account %d", accountId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &balance, nil
+}
@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type AuditRepositoryMemory struct {
+	store *Store
+}
+
+func NewAuditRepositoryMemory(store *Store) *AuditRepositoryMemory {
+	return &AuditRepositoryMemory{
+		store: store,
+	}
+}
+
+func (a *AuditRepositoryMemory) ListAuditEntries(filter repository.AuditFilter, page repository.Page) ([]model.AuditEntry, error) {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+
+	entries := []model.AuditEntry{}
+
+	for _, entry := range a.store.auditLog {
+		if len(entries) == page.EffectiveLimit() {
+			break
+		}
+
+		if entry.AuditEntryId <= page.AfterId ||
+			(filter.EntityType != "" && entry.EntityType != filter.EntityType) ||
+			(filter.EntityId != 0 && entry.EntityId != filter.EntityId) ||
+			(filter.Actor != "" && entry.Actor != filter.Actor) ||
+			(filter.RequestId != "" && entry.RequestId != filter.RequestId) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
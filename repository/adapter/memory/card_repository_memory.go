@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type CardRepositoryMemory struct {
+	store *Store
+}
+
+func NewCardRepositoryMemory(store *Store) *CardRepositoryMemory {
+	return &CardRepositoryMemory{
+		store: store,
+	}
+}
+
+// issueCard enforces the foreign key and the unique token of the table,
+// then assigns the card the next ID. The caller must hold the write lock.
+func (c *CardRepositoryMemory) issueCard(card model.Card) (model.Card, error) {
+	if _, ok := c.store.accounts[card.AccountId]; !ok {
+		return model.Card{}, repository.ErrForeignKeyViolation
+	}
+
+	if _, ok := c.store.holders[card.HolderId]; card.HolderId != 0 && !ok {
+		return model.Card{}, repository.ErrForeignKeyViolation
+	}
+
+	for _, issued := range c.store.cards {
+		if issued.PanToken == card.PanToken {
+			return model.Card{}, repository.ErrConflict
+		}
+	}
+
+	card.CardId = c.store.cardSequence + 1
+	card.ReplacedBy = 0
+	card.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	return card, nil
+}
+
+func (c *CardRepositoryMemory) CreateCard(ctx context.Context, card model.Card) (*model.Card, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	card, err := c.issueCard(card)
+	if err != nil {
+		log.Printf("CardRepositoryMemory#CreateCard: Issuing the card failed: %s", err)
+
+		return nil, err
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_CARD, card.CardId, nil, card)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.cardSequence++
+	c.store.cards[card.CardId] = card
+	c.store.appendAudit(entry)
+
+	return &card, nil
+}
+
+func (c *CardRepositoryMemory) FindCard(cardId uint64) (*model.Card, error) {
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+
+	card, ok := c.store.cards[cardId]
+
+	if !ok {
+		log.Printf("CardRepositoryMemory#FindCard: No card found for ID %d", cardId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &card, nil
+}
+
+func (c *CardRepositoryMemory) ListCards(accountId uint64, page repository.Page) ([]model.Card, error) {
+	c.store.mu.RLock()
+	defer c.store.mu.RUnlock()
+
+	cards := []model.Card{}
+
+	for cardId := page.AfterId + 1; cardId <= c.store.cardSequence && len(cards) < page.EffectiveLimit(); cardId++ {
+		if card := c.store.cards[cardId]; card.AccountId == accountId {
+			cards = append(cards, card)
+		}
+	}
+
+	return cards, nil
+}
+
+func (c *CardRepositoryMemory) UpdateCardStatus(ctx context.Context, cardId uint64, from []string, status string) (*model.Card, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	before, ok := c.store.cards[cardId]
+
+	if !ok {
+		log.Printf("CardRepositoryMemory#UpdateCardStatus: No card found for ID %d", cardId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	allowed := false
+
+	for _, s := range from {
+		allowed = allowed || s == before.Status
+	}
+
+	if !allowed {
+		log.Printf("CardRepositoryMemory#UpdateCardStatus: Card %d is %s", cardId, before.Status)
+
+		return nil, repository.ErrConflict
+	}
+
+	updated := before
+	updated.Status = status
+
+	return c.updateCard(ctx, before, updated)
+}
+
+func (c *CardRepositoryMemory) UpdateCardLimits(ctx context.Context, cardId uint64, transactionLimit model.Amount, dailyLimit model.Amount) (*model.Card, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	before, ok := c.store.cards[cardId]
+
+	if !ok {
+		log.Printf("CardRepositoryMemory#UpdateCardLimits: No card found for ID %d", cardId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	updated := before
+	updated.TransactionLimit = transactionLimit
+	updated.DailyLimit = dailyLimit
+
+	return c.updateCard(ctx, before, updated)
+}
+
+// updateCard stores updated in place of before. The caller must hold the
+// write lock.
+func (c *CardRepositoryMemory) updateCard(ctx context.Context, before model.Card, updated model.Card) (*model.Card, error) {
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, updated.CardId, before, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.cards[updated.CardId] = updated
+	c.store.appendAudit(entry)
+
+	return &updated, nil
+}
+
+func (c *CardRepositoryMemory) ReplaceCard(ctx context.Context, cardId uint64, replacement model.Card) (*model.Card, error) {
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	before, ok := c.store.cards[cardId]
+
+	if !ok {
+		log.Printf("CardRepositoryMemory#ReplaceCard: No card found for ID %d", cardId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	if before.Status == model.CARD_STATUS_REPLACED {
+		log.Printf("CardRepositoryMemory#ReplaceCard: Card %d was already replaced", cardId)
+
+		return nil, repository.ErrConflict
+	}
+
+	replacement.AccountId = before.AccountId
+	replacement.HolderId = before.HolderId
+	replacement.Type = before.Type
+	replacement.TransactionLimit = before.TransactionLimit
+	replacement.DailyLimit = before.DailyLimit
+	replacement.Status = model.CARD_STATUS_ACTIVE
+
+	created, err := c.issueCard(replacement)
+	if err != nil {
+		log.Printf("CardRepositoryMemory#ReplaceCard: Issuing the replacement failed: %s", err)
+
+		return nil, err
+	}
+
+	replaced := before
+	replaced.Status = model.CARD_STATUS_REPLACED
+	replaced.ReplacedBy = created.CardId
+
+	createdEntry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_CARD, created.CardId, nil, created)
+	if err != nil {
+		return nil, err
+	}
+
+	replacedEntry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_CARD, cardId, before, replaced)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store.cardSequence++
+	c.store.cards[created.CardId] = created
+	c.store.cards[cardId] = replaced
+	c.store.appendAudit(createdEntry, replacedEntry)
+
+	return &created, nil
+}
+
+// daySpend returns the amount of the purchases and withdraws made with the
+// card on day that were not reversed. The caller must hold the lock.
+func (s *Store) daySpend(card model.Card, day time.Time) (model.Amount, error) {
+	spent := model.Amount{}
+
+	for _, event := range s.events {
+		if event.Type != model.EVENT_TRANSACTION_POSTED || event.AccountId != card.AccountId || s.reversals[event.TransactionId] {
+			continue
+		}
+
+		transaction := s.posted[event.TransactionId]
+		amount := eventAmount(event)
+
+		if transaction.CardId == card.CardId && amount.Sign() < 0 && transaction.EventDate.UTC().Truncate(24*time.Hour).Equal(day) {
+			spent = spent.Sub(amount)
+		}
+	}
+
+	return spent, nil
+}
+
+// findCard returns the card, the caller must hold the lock.
+func (s *Store) findCard(cardId uint64) (*model.Card, error) {
+	card, ok := s.cards[cardId]
+
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &card, nil
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/dispute"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type DisputeRepositoryMemory struct {
+	store        *Store
+	transactions *TransactionRepositoryMemory
+}
+
+func NewDisputeRepositoryMemory(store *Store) *DisputeRepositoryMemory {
+	return &DisputeRepositoryMemory{
+		store:        store,
+		transactions: NewTransactionRepositoryMemory(store),
+	}
+}
+
+// OpenDispute enforces the unique transaction of the table.
+func (s *DisputeRepositoryMemory) OpenDispute(ctx context.Context, d model.Dispute) (*model.Dispute, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	transaction, ok := s.store.posted[d.TransactionId]
+
+	if !ok {
+		log.Printf("DisputeRepositoryMemory#OpenDispute: No transaction found for ID %d", d.TransactionId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	transaction.Reversed = s.store.reversals[d.TransactionId]
+
+	opened, err := dispute.Open(d, transaction, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, existing := range s.store.disputes {
+		if existing.TransactionId == opened.TransactionId {
+			log.Printf("DisputeRepositoryMemory#OpenDispute: Transaction %d is disputed already", opened.TransactionId)
+
+			return nil, repository.ErrConflict
+		}
+	}
+
+	opened.DisputeId = s.store.disputeSequence + 1
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_DISPUTE, opened.DisputeId, nil, opened)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.disputeSequence++
+	s.store.disputes[opened.DisputeId] = *opened
+	s.store.appendDisputeChange(*opened, "")
+	s.store.appendAudit(entry)
+
+	return opened, nil
+}
+
+func (s *DisputeRepositoryMemory) FindDispute(disputeId uint64) (*model.Dispute, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	found, ok := s.store.disputes[disputeId]
+
+	if !ok {
+		log.Printf("DisputeRepositoryMemory#FindDispute: No dispute found for ID %d", disputeId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &found, nil
+}
+
+func (s *DisputeRepositoryMemory) ListDisputes(filter repository.DisputeFilter, page repository.Page) ([]model.Dispute, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	disputes := []model.Dispute{}
+
+	for disputeId := page.AfterId + 1; disputeId <= s.store.disputeSequence && len(disputes) < page.EffectiveLimit(); disputeId++ {
+		d, ok := s.store.disputes[disputeId]
+
+		if !ok || (filter.AccountId != 0 && d.AccountId != filter.AccountId) || (filter.Status != "" && d.Status != filter.Status) || (filter.Escalated && !d.Escalated) {
+			continue
+		}
+
+		disputes = append(disputes, d)
+	}
+
+	return disputes, nil
+}
+
+func (s *DisputeRepositoryMemory) ListDisputeChanges(disputeId uint64, page repository.Page) ([]model.DisputeChange, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	changes := []model.DisputeChange{}
+
+	for _, change := range s.store.disputeChanges {
+		if change.DisputeId == disputeId && change.DisputeChangeId > page.AfterId && len(changes) < page.EffectiveLimit() {
+			changes = append(changes, change)
+		}
+	}
+
+	return changes, nil
+}
+
+func (s *DisputeRepositoryMemory) TransitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	before, ok := s.store.disputes[disputeId]
+
+	if !ok {
+		log.Printf("DisputeRepositoryMemory#TransitionDispute: No dispute found for ID %d", disputeId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	moved, err := dispute.Transition(before, status, time.Now().UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	// The credit is checked before anything is stored, like a rolled back
+	// statement.
+	switch status {
+	case model.DISPUTE_STATUS_CREDITED:
+		credit := []model.Transaction{dispute.Credit(*moved)}
+
+		if err := s.store.checkTransactions(ctx, credit); err != nil {
+			log.Printf("DisputeRepositoryMemory#TransitionDispute: Checking the provisional credit failed: %s", err)
+
+			return nil, err
+		}
+
+		created, err := s.transactions.insertTransactions(ctx, credit)
+		if err != nil {
+			log.Printf("DisputeRepositoryMemory#TransitionDispute: Posting the provisional credit failed: %s", err)
+
+			return nil, err
+		}
+
+		moved.CreditTransactionId = created[0].TransactionId
+	case model.DISPUTE_STATUS_LOST:
+		if _, err := s.transactions.reverseTransaction(ctx, moved.CreditTransactionId); err != nil {
+			log.Printf("DisputeRepositoryMemory#TransitionDispute: Reversing the provisional credit failed: %s", err)
+
+			return nil, err
+		}
+	}
+
+	if err := s.store.updateDispute(ctx, before, *moved, note); err != nil {
+		return nil, err
+	}
+
+	return moved, nil
+}
+
+func (s *DisputeRepositoryMemory) ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	disputes := []model.Dispute{}
+
+	for _, d := range s.store.disputes {
+		if dispute.Overdue(d, now) {
+			disputes = append(disputes, d)
+		}
+	}
+
+	sort.Slice(disputes, func(i, j int) bool {
+		if !disputes[i].DeadlineAt.Equal(*disputes[j].DeadlineAt) {
+			return disputes[i].DeadlineAt.Before(*disputes[j].DeadlineAt)
+		}
+
+		return disputes[i].DisputeId < disputes[j].DisputeId
+	})
+
+	if len(disputes) > limit {
+		disputes = disputes[:limit]
+	}
+
+	return disputes, nil
+}
+
+func (s *DisputeRepositoryMemory) EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	before, ok := s.store.disputes[disputeId]
+
+	if !ok {
+		log.Printf("DisputeRepositoryMemory#EscalateDispute: No dispute found for ID %d", disputeId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	escalated, err := dispute.Escalate(before, now.UTC().Truncate(time.Millisecond))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.updateDispute(ctx, before, *escalated, ""); err != nil {
+		return nil, err
+	}
+
+	return escalated, nil
+}
+
+// updateDispute stores d along with its history and audit entry. The
+// caller must hold the write lock.
+func (s *Store) updateDispute(ctx context.Context, before model.Dispute, d model.Dispute, note string) error {
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_DISPUTE, d.DisputeId, before, d)
+	if err != nil {
+		return err
+	}
+
+	s.disputes[d.DisputeId] = d
+	s.appendDisputeChange(d, note)
+	s.appendAudit(entry)
+
+	return nil
+}
+
+// appendDisputeChange records that d changed, as it is now. The caller must
+// hold the write lock.
+func (s *Store) appendDisputeChange(d model.Dispute, note string) {
+	s.disputeChanges = append(s.disputeChanges, model.DisputeChange{
+		DisputeChangeId: uint64(len(s.disputeChanges)) + 1,
+		DisputeId:       d.DisputeId,
+		Status:          d.Status,
+		Escalated:       d.Escalated,
+		Note:            note,
+		CreatedAt:       d.UpdatedAt,
+	})
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type EventRepositoryMemory struct {
+	store *Store
+}
+
+func NewEventRepositoryMemory(store *Store) *EventRepositoryMemory {
+	return &EventRepositoryMemory{
+		store: store,
+	}
+}
+
+func (e *EventRepositoryMemory) ListEvents(filter repository.EventFilter, page repository.Page) ([]model.Event, error) {
+	e.store.mu.RLock()
+	defer e.store.mu.RUnlock()
+
+	events := []model.Event{}
+
+	for n := int(page.AfterId); n < len(e.store.events) && len(events) < page.EffectiveLimit(); n++ {
+		event := e.store.events[n]
+
+		if filter.AccountId != 0 && event.AccountId != filter.AccountId {
+			continue
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// ProjectionRepositoryMemory lets the read models of the store be rebuilt.
+// The store projects the events as they are appended, so there is nothing
+// left to project otherwise.
+type ProjectionRepositoryMemory struct {
+	store *Store
+}
+
+func NewProjectionRepositoryMemory(store *Store) *ProjectionRepositoryMemory {
+	return &ProjectionRepositoryMemory{
+		store: store,
+	}
+}
+
+func (p *ProjectionRepositoryMemory) ProjectEvents(limit int) (int, error) {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	return p.store.projectEvents(limit), nil
+}
+
+func (p *ProjectionRepositoryMemory) ResetProjections() error {
+	p.store.mu.Lock()
+	defer p.store.mu.Unlock()
+
+	p.store.resetProjections()
+
+	return nil
+}
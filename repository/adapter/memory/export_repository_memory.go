@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ExportRepositoryMemory struct {
+	store *Store
+}
+
+func NewExportRepositoryMemory(store *Store) *ExportRepositoryMemory {
+	return &ExportRepositoryMemory{
+		store: store,
+	}
+}
+
+// statementLines returns the matching lines in the order they were posted,
+// which in memory is the order of their IDs.
+func (e *ExportRepositoryMemory) statementLines(filter repository.StatementFilter) []model.StatementLine {
+	e.store.mu.RLock()
+	defer e.store.mu.RUnlock()
+
+	lines := []model.StatementLine{}
+
+	for transactionId := uint64(1); transactionId <= e.store.transactionSequence; transactionId++ {
+		transaction, ok := e.store.transactions[transactionId]
+
+		if !ok || transaction.AccountId != filter.AccountId || !inPeriod(transaction.CreatedAt, filter) {
+			continue
+		}
+
+		lines = append(lines, model.StatementLine{
+			Transaction: transaction,
+			Description: e.store.operationTypes[transaction.OperationTypeId],
+			CreatedAt:   transaction.CreatedAt,
+		})
+	}
+
+	return lines
+}
+
+func inPeriod(t time.Time, filter repository.StatementFilter) bool {
+	return (filter.From.IsZero() || !t.Before(filter.From)) && (filter.To.IsZero() || t.Before(filter.To))
+}
+
+func (e *ExportRepositoryMemory) CountStatementLines(filter repository.StatementFilter) (uint64, error) {
+	return uint64(len(e.statementLines(filter))), nil
+}
+
+// StreamStatementLines copies the lines before calling fn, so a slow reader
+// never holds the store lock.
+func (e *ExportRepositoryMemory) StreamStatementLines(filter repository.StatementFilter, fn func(model.StatementLine) error) error {
+	for _, line := range e.statementLines(filter) {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *ExportRepositoryMemory) CreateExport(ctx context.Context, export model.Export) (*model.Export, error) {
+	e.store.mu.Lock()
+	defer e.store.mu.Unlock()
+
+	if _, ok := e.store.accounts[export.AccountId]; !ok {
+		log.Printf("ExportRepositoryMemory#CreateExport: No account found for ID %d", export.AccountId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	export.ExportId = e.store.exportSequence + 1
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_EXPORT, export.ExportId, nil, export)
+	if err != nil {
+		return nil, err
+	}
+
+	e.store.exportSequence++
+	e.store.exports[export.ExportId] = export
+	e.store.appendAudit(entry)
+
+	return &export, nil
+}
+
+func (e *ExportRepositoryMemory) FindExport(exportId uint64) (*model.Export, error) {
+	e.store.mu.RLock()
+	defer e.store.mu.RUnlock()
+
+	export, ok := e.store.exports[exportId]
+
+	if !ok {
+		log.Printf("ExportRepositoryMemory#FindExport: No export found for ID %d", exportId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &export, nil
+}
+
+func (e *ExportRepositoryMemory) ListPendingExports() ([]model.Export, error) {
+	e.store.mu.RLock()
+	defer e.store.mu.RUnlock()
+
+	exports := []model.Export{}
+
+	for exportId := uint64(1); exportId <= e.store.exportSequence; exportId++ {
+		if export := e.store.exports[exportId]; export.Status == model.EXPORT_PENDING {
+			exports = append(exports, export)
+		}
+	}
+
+	return exports, nil
+}
+
+func (e *ExportRepositoryMemory) FinishExport(exportId uint64, status string, rows uint64, message string) (*model.Export, error) {
+	e.store.mu.Lock()
+	defer e.store.mu.Unlock()
+
+	export, ok := e.store.exports[exportId]
+
+	if !ok {
+		log.Printf("ExportRepositoryMemory#FinishExport: No export found for ID %d", exportId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	export.Status = status
+	export.Rows = rows
+	export.Error = message
+
+	e.store.exports[exportId] = export
+
+	return &export, nil
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type FxRateRepositoryMemory struct {
+	store *Store
+}
+
+func NewFxRateRepositoryMemory(store *Store) *FxRateRepositoryMemory {
+	return &FxRateRepositoryMemory{
+		store: store,
+	}
+}
+
+func (f *FxRateRepositoryMemory) CreateFxRates(ctx context.Context, rates []model.FxRate) ([]model.FxRate, error) {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+
+	created := make([]model.FxRate, len(rates))
+	entries := make([]model.AuditEntry, len(rates))
+	pairs := map[model.FxRate]bool{}
+
+	for n, rate := range rates {
+		rate.FxRateId = f.store.fxRateSequence + uint64(n) + 1
+		rate.EffectiveAt = rate.EffectiveAt.UTC().Truncate(time.Millisecond)
+
+		pair := model.FxRate{BaseCurrency: rate.BaseCurrency, QuoteCurrency: rate.QuoteCurrency, EffectiveAt: rate.EffectiveAt}
+
+		if pairs[pair] || f.store.hasFxRate(pair) {
+			log.Printf("FxRateRepositoryMemory#CreateFxRates: A rate of %s in %s already takes effect at %s", rate.BaseCurrency, rate.QuoteCurrency, rate.EffectiveAt)
+
+			return nil, repository.ErrConflict
+		}
+
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_FX_RATE, rate.FxRateId, nil, rate)
+		if err != nil {
+			return nil, err
+		}
+
+		pairs[pair] = true
+		created[n] = rate
+		entries[n] = entry
+	}
+
+	for _, rate := range created {
+		f.store.fxRates[rate.FxRateId] = rate
+	}
+
+	f.store.fxRateSequence += uint64(len(created))
+	f.store.appendAudit(entries...)
+
+	return created, nil
+}
+
+func (f *FxRateRepositoryMemory) ListFxRates(filter repository.FxRateFilter, page repository.Page) ([]model.FxRate, error) {
+	f.store.mu.RLock()
+	defer f.store.mu.RUnlock()
+
+	rates := []model.FxRate{}
+
+	for fxRateId := page.AfterId + 1; fxRateId <= f.store.fxRateSequence && len(rates) < page.EffectiveLimit(); fxRateId++ {
+		rate := f.store.fxRates[fxRateId]
+
+		if (filter.BaseCurrency != "" && rate.BaseCurrency != filter.BaseCurrency) || (filter.QuoteCurrency != "" && rate.QuoteCurrency != filter.QuoteCurrency) {
+			continue
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+func (f *FxRateRepositoryMemory) FindFxRate(base string, quote string, at time.Time) (*model.FxRate, error) {
+	f.store.mu.RLock()
+	defer f.store.mu.RUnlock()
+
+	return f.store.findFxRate(base, quote, at)
+}
+
+// hasFxRate reports whether a rate of the pair takes effect at the time of
+// pair, like the unique constraint of the database. The caller must hold
+// the lock.
+func (s *Store) hasFxRate(pair model.FxRate) bool {
+	for _, rate := range s.fxRates {
+		if rate.BaseCurrency == pair.BaseCurrency && rate.QuoteCurrency == pair.QuoteCurrency && rate.EffectiveAt.Equal(pair.EffectiveAt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findFxRate returns the latest rate of base in quote to take effect up to
+// at. The caller must hold the lock.
+func (s *Store) findFxRate(base string, quote string, at time.Time) (*model.FxRate, error) {
+	var found *model.FxRate
+
+	for _, rate := range s.fxRates {
+		if rate.BaseCurrency != base || rate.QuoteCurrency != quote || rate.EffectiveAt.After(at) {
+			continue
+		}
+
+		if found == nil || rate.EffectiveAt.After(found.EffectiveAt) {
+			rate := rate
+			found = &rate
+		}
+	}
+
+	if found == nil {
+		return nil, repository.ErrFxRateNotFound
+	}
+
+	return found, nil
+}
@@ -0,0 +1,139 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// HolderRepositoryMemory keeps the personal data as is, as nothing it holds
+// is ever written to disk.
+type HolderRepositoryMemory struct {
+	store *Store
+}
+
+func NewHolderRepositoryMemory(store *Store) *HolderRepositoryMemory {
+	return &HolderRepositoryMemory{
+		store: store,
+	}
+}
+
+func (h *HolderRepositoryMemory) CreateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	holder.HolderId = h.store.holderSequence + 1
+	holder.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	holder.UpdatedAt = holder.CreatedAt
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, nil, holder.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	h.store.holderSequence++
+	h.store.holders[holder.HolderId] = holder
+	h.store.appendAudit(entry)
+
+	return &holder, nil
+}
+
+func (h *HolderRepositoryMemory) FindHolder(holderId uint64) (*model.Holder, error) {
+	h.store.mu.RLock()
+	defer h.store.mu.RUnlock()
+
+	holder, ok := h.store.holders[holderId]
+
+	if !ok {
+		log.Printf("HolderRepositoryMemory#FindHolder: No holder found for ID %d", holderId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &holder, nil
+}
+
+func (h *HolderRepositoryMemory) ListHolders(page repository.Page) ([]model.Holder, error) {
+	h.store.mu.RLock()
+	defer h.store.mu.RUnlock()
+
+	holders := []model.Holder{}
+
+	for holderId := page.AfterId + 1; holderId <= h.store.holderSequence && len(holders) < page.EffectiveLimit(); holderId++ {
+		if holder, ok := h.store.holders[holderId]; ok {
+			holders = append(holders, holder)
+		}
+	}
+
+	return holders, nil
+}
+
+func (h *HolderRepositoryMemory) UpdateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error) {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	before, ok := h.store.holders[holder.HolderId]
+
+	if !ok {
+		log.Printf("HolderRepositoryMemory#UpdateHolder: No holder found for ID %d", holder.HolderId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	holder.CreatedAt = before.CreatedAt
+	holder.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_HOLDER, holder.HolderId, before.Redacted(), holder.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	h.store.holders[holder.HolderId] = holder
+	h.store.appendAudit(entry)
+
+	return &holder, nil
+}
+
+// DeleteHolder enforces the foreign keys of the accounts and the cards.
+func (h *HolderRepositoryMemory) DeleteHolder(ctx context.Context, holderId uint64) error {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	deleted, ok := h.store.holders[holderId]
+
+	if !ok {
+		log.Printf("HolderRepositoryMemory#DeleteHolder: No holder found for ID %d", holderId)
+
+		return repository.ErrNotFound
+	}
+
+	for _, account := range h.store.accounts {
+		if account.HolderId == holderId {
+			log.Printf("HolderRepositoryMemory#DeleteHolder: Holder %d owns account %d", holderId, account.AccountId)
+
+			return repository.ErrConflict
+		}
+	}
+
+	for _, card := range h.store.cards {
+		if card.HolderId == holderId {
+			log.Printf("HolderRepositoryMemory#DeleteHolder: Holder %d holds card %d", holderId, card.CardId)
+
+			return repository.ErrConflict
+		}
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_HOLDER, holderId, deleted.Redacted(), nil)
+	if err != nil {
+		return err
+	}
+
+	delete(h.store.holders, holderId)
+	h.store.appendAudit(entry)
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"log"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ImportRepositoryMemory struct {
+	store *Store
+}
+
+func NewImportRepositoryMemory(store *Store) *ImportRepositoryMemory {
+	return &ImportRepositoryMemory{
+		store: store,
+	}
+}
+
+func (i *ImportRepositoryMemory) CreateImport(ctx context.Context, imp model.Import) (*model.Import, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	if imp.IdempotencyKey != "" {
+		for _, existing := range i.store.imports {
+			if existing.IdempotencyKey == imp.IdempotencyKey {
+				log.Printf("ImportRepositoryMemory#CreateImport: Idempotency key %s already used by import %d", imp.IdempotencyKey, existing.ImportId)
+
+				return nil, repository.ErrConflict
+			}
+		}
+	}
+
+	created := model.Import{
+		ImportId:       i.store.importSequence + 1,
+		Format:         imp.Format,
+		Status:         imp.Status,
+		IdempotencyKey: imp.IdempotencyKey,
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_IMPORT, created.ImportId, nil, created)
+	if err != nil {
+		return nil, err
+	}
+
+	i.store.importSequence++
+	i.store.imports[created.ImportId] = created
+	i.store.appendAudit(entry)
+
+	return &created, nil
+}
+
+func (i *ImportRepositoryMemory) FindImport(importId uint64) (*model.Import, error) {
+	i.store.mu.RLock()
+	defer i.store.mu.RUnlock()
+
+	imp, ok := i.store.imports[importId]
+
+	if !ok {
+		log.Printf("ImportRepositoryMemory#FindImport: No import found for ID %d", importId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &imp, nil
+}
+
+func (i *ImportRepositoryMemory) FindImportByIdempotencyKey(idempotencyKey string) (*model.Import, error) {
+	i.store.mu.RLock()
+	defer i.store.mu.RUnlock()
+
+	for _, imp := range i.store.imports {
+		if imp.IdempotencyKey == idempotencyKey {
+			return &imp, nil
+		}
+	}
+
+	log.Printf("ImportRepositoryMemory#FindImportByIdempotencyKey: No import found for key %s", idempotencyKey)
+
+	return nil, repository.ErrNotFound
+}
+
+func (i *ImportRepositoryMemory) ListUnfinishedImports() ([]model.Import, error) {
+	i.store.mu.RLock()
+	defer i.store.mu.RUnlock()
+
+	imports := []model.Import{}
+
+	for importId := uint64(1); importId <= i.store.importSequence; importId++ {
+		imp := i.store.imports[importId]
+
+		if imp.Status == model.IMPORT_PENDING || imp.Status == model.IMPORT_RUNNING {
+			imports = append(imports, imp)
+		}
+	}
+
+	return imports, nil
+}
+
+func (i *ImportRepositoryMemory) SaveImportBatch(importId uint64, batch repository.ImportBatch) (*model.Import, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	imp, ok := i.store.imports[importId]
+
+	if !ok {
+		log.Printf("ImportRepositoryMemory#SaveImportBatch: No import found for ID %d", importId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	if imp.ProcessedLines >= batch.ProcessedLines || (imp.Status != model.IMPORT_PENDING && imp.Status != model.IMPORT_RUNNING) {
+		log.Printf("ImportRepositoryMemory#SaveImportBatch: Import %d already went past line %d", importId, batch.ProcessedLines)
+
+		return nil, repository.ErrConflict
+	}
+
+	// The whole batch is checked before anything is stored, so a foreign key
+	// violation leaves the store untouched like a rolled back transaction.
+	if err := i.store.checkTransactions(context.Background(), batch.Transactions); err != nil {
+		log.Printf("ImportRepositoryMemory#SaveImportBatch: Checking the transactions failed: %s", err)
+
+		return nil, err
+	}
+
+	if _, err := i.store.postTransactions(batch.Transactions); err != nil {
+		return nil, err
+	}
+
+	for _, rejection := range batch.Rejections {
+		i.store.rejectionSequence++
+		rejection.ImportRejectionId = i.store.rejectionSequence
+
+		i.store.rejections[importId] = append(i.store.rejections[importId], rejection)
+	}
+
+	imp.Status = model.IMPORT_RUNNING
+	imp.ProcessedLines = batch.ProcessedLines
+	imp.ImportedRows += uint64(len(batch.Transactions))
+	imp.RejectedRows += batch.RejectedRows
+
+	i.store.imports[importId] = imp
+
+	return &imp, nil
+}
+
+func (i *ImportRepositoryMemory) FinishImport(importId uint64, status string, message string) (*model.Import, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+
+	imp, ok := i.store.imports[importId]
+
+	if !ok {
+		log.Printf("ImportRepositoryMemory#FinishImport: No import found for ID %d", importId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	imp.Status = status
+	imp.Error = message
+
+	i.store.imports[importId] = imp
+
+	return &imp, nil
+}
+
+func (i *ImportRepositoryMemory) ListImportRejections(importId uint64, page repository.Page) ([]model.ImportRejection, error) {
+	i.store.mu.RLock()
+	defer i.store.mu.RUnlock()
+
+	rejections := []model.ImportRejection{}
+
+	for _, rejection := range i.store.rejections[importId] {
+		if rejection.ImportRejectionId <= page.AfterId {
+			continue
+		}
+
+		if len(rejections) == page.EffectiveLimit() {
+			break
+		}
+
+		rejections = append(rejections, rejection)
+	}
+
+	return rejections, nil
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/felipedsi/pismo-test/repository/repositorytest"
+)
+
+func TestRepositoryContract(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		store := NewStore()
+
+		return repositorytest.Repositories{
+			Accounts:       NewAccountRepositoryMemory(store),
+			Transactions:   NewTransactionRepositoryMemory(store),
+			OperationTypes: NewOperationTypeRepositoryMemory(store),
+			Imports:        NewImportRepositoryMemory(store),
+			Exports:        NewExportRepositoryMemory(store),
+			Audit:          NewAuditRepositoryMemory(store),
+			Events:         NewEventRepositoryMemory(store),
+			Projections:    NewProjectionRepositoryMemory(store),
+			Schedules:      NewScheduleRepositoryMemory(store),
+			FxRates:        NewFxRateRepositoryMemory(store),
+			Cards:          NewCardRepositoryMemory(store),
+			SpendRules:     NewSpendRuleRepositoryMemory(store),
+			Risk:           NewRiskRepositoryMemory(store),
+			Disputes:       NewDisputeRepositoryMemory(store),
+			Holders:        NewHolderRepositoryMemory(store),
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type OperationTypeRepositoryMemory struct {
+	store *Store
+}
+
+func NewOperationTypeRepositoryMemory(store *Store) *OperationTypeRepositoryMemory {
+	return &OperationTypeRepositoryMemory{
+		store: store,
+	}
+}
+
+func (o *OperationTypeRepositoryMemory) ListOperationTypes() ([]model.OperationType, error) {
+	o.store.mu.RLock()
+	defer o.store.mu.RUnlock()
+
+	operationTypes := []model.OperationType{}
+
+	for operationTypeId, description := range o.store.operationTypes {
+		operationTypes = append(operationTypes, model.OperationType{OperationTypeId: operationTypeId, Description: description})
+	}
+
+	sort.Slice(operationTypes, func(i, j int) bool {
+		return operationTypes[i].OperationTypeId < operationTypes[j].OperationTypeId
+	})
+
+	return operationTypes, nil
+}
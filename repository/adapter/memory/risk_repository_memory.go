@@ -0,0 +1,211 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// riskCounterKey and riskCounter are a row of the risk_counters table: the
+// transactions and spending of an account in the minute or day starting at
+// periodStart.
+type riskCounterKey struct {
+	accountId uint64
+	day       bool
+}
+
+type riskCounter struct {
+	periodStart  time.Time
+	transactions int
+	spent        float64
+}
+
+type RiskRepositoryMemory struct {
+	store *Store
+}
+
+func NewRiskRepositoryMemory(store *Store) *RiskRepositoryMemory {
+	return &RiskRepositoryMemory{
+		store: store,
+	}
+}
+
+// CreateRiskRule enforces the unique name of the table.
+func (s *RiskRepositoryMemory) CreateRiskRule(ctx context.Context, rule model.RiskRule) (*model.RiskRule, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	for _, existing := range s.store.riskRules {
+		if existing.Name == rule.Name {
+			log.Printf("RiskRepositoryMemory#CreateRiskRule: A risk rule is named %q already", rule.Name)
+
+			return nil, repository.ErrConflict
+		}
+	}
+
+	rule.RiskRuleId = s.store.riskRuleSequence + 1
+	rule.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_RISK_RULE, rule.RiskRuleId, nil, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.riskRuleSequence++
+	s.store.riskRules[rule.RiskRuleId] = rule
+	s.store.appendAudit(entry)
+
+	return &rule, nil
+}
+
+func (s *RiskRepositoryMemory) ListRiskRules(page repository.Page) ([]model.RiskRule, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	rules := []model.RiskRule{}
+
+	for riskRuleId := page.AfterId + 1; riskRuleId <= s.store.riskRuleSequence && len(rules) < page.EffectiveLimit(); riskRuleId++ {
+		if rule, ok := s.store.riskRules[riskRuleId]; ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func (s *RiskRepositoryMemory) DeleteRiskRule(ctx context.Context, riskRuleId uint64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	deleted, ok := s.store.riskRules[riskRuleId]
+
+	if !ok {
+		log.Printf("RiskRepositoryMemory#DeleteRiskRule: No risk rule found for ID %d", riskRuleId)
+
+		return repository.ErrNotFound
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_RISK_RULE, riskRuleId, deleted, nil)
+	if err != nil {
+		return err
+	}
+
+	delete(s.store.riskRules, riskRuleId)
+	s.store.appendAudit(entry)
+
+	return nil
+}
+
+// DecideRisk holds the lock of the store while deciding, which makes the
+// decisions one at a time. The counters are only moved by the transactions
+// once posted, see countRisk.
+func (s *RiskRepositoryMemory) DecideRisk(ctx context.Context, decision model.RiskDecision, decide repository.RiskDecider) (*model.RiskDecision, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	openedAt, ok := s.store.openedAt(decision.AccountId)
+
+	if !ok {
+		log.Printf("RiskRepositoryMemory#DecideRisk: No account found for ID %d", decision.AccountId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	decision.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	minute := riskCounterKey{accountId: decision.AccountId}
+	day := riskCounterKey{accountId: decision.AccountId, day: true}
+	periods := map[riskCounterKey]time.Time{minute: risk.MinuteOf(decision.CreatedAt), day: risk.DayOf(decision.CreatedAt)}
+
+	counters := model.RiskCounters{
+		OpenedAt:           openedAt,
+		TransactionsMinute: 1,
+		SpentDay:           risk.Spend(decision.OperationTypeId, decision.Amount),
+	}
+
+	if counter := s.store.riskCounters[minute]; counter.periodStart.Equal(periods[minute]) {
+		counters.TransactionsMinute += counter.transactions
+	}
+
+	if counter := s.store.riskCounters[day]; counter.periodStart.Equal(periods[day]) {
+		counters.SpentDay += counter.spent
+	}
+
+	for n := len(s.store.riskDecisions) - 1; n >= 0; n-- {
+		previous := s.store.riskDecisions[n]
+
+		if previous.AccountId == decision.AccountId && previous.Outcome != model.RISK_OUTCOME_DECLINE && previous.MerchantCountry != "" {
+			counters.LastCountry = previous.MerchantCountry
+
+			break
+		}
+	}
+
+	var err error
+
+	if decision.Outcome, decision.Rules, err = decide(decision, counters); err != nil {
+		return nil, err
+	}
+
+	decision.RiskDecisionId = uint64(len(s.store.riskDecisions)) + 1
+	s.store.riskDecisions = append(s.store.riskDecisions, decision)
+
+	return &decision, nil
+}
+
+// countRisk adds the transactions, as posted, to the counters of their
+// accounts. The caller must hold the write lock.
+func (s *Store) countRisk(transactions []model.Transaction) {
+	for _, transaction := range transactions {
+		minute := riskCounterKey{accountId: transaction.AccountId}
+		day := riskCounterKey{accountId: transaction.AccountId, day: true}
+		periods := map[riskCounterKey]time.Time{minute: risk.MinuteOf(transaction.CreatedAt), day: risk.DayOf(transaction.CreatedAt)}
+
+		for key, periodStart := range periods {
+			counter := s.riskCounters[key]
+
+			if !counter.periodStart.Equal(periodStart) {
+				counter = riskCounter{periodStart: periodStart}
+			}
+
+			counter.transactions++
+			counter.spent += risk.Spend(transaction.OperationTypeId, transaction.Amount)
+
+			s.riskCounters[key] = counter
+		}
+	}
+}
+
+func (s *RiskRepositoryMemory) ListRiskDecisions(filter repository.RiskDecisionFilter, page repository.Page) ([]model.RiskDecision, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	decisions := []model.RiskDecision{}
+
+	for n := page.AfterId; n < uint64(len(s.store.riskDecisions)) && len(decisions) < page.EffectiveLimit(); n++ {
+		decision := s.store.riskDecisions[n]
+
+		if (filter.AccountId == 0 || decision.AccountId == filter.AccountId) && (filter.Outcome == "" || decision.Outcome == filter.Outcome) {
+			decisions = append(decisions, decision)
+		}
+	}
+
+	return decisions, nil
+}
+
+// openedAt returns when the account was opened, the time of the first event
+// of its stream. The caller must hold the lock.
+func (s *Store) openedAt(accountId uint64) (time.Time, bool) {
+	for _, event := range s.events {
+		if event.AccountId == accountId && event.Type == model.EVENT_ACCOUNT_OPENED {
+			return event.CreatedAt, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ScheduleRepositoryMemory struct {
+	store *Store
+}
+
+func NewScheduleRepositoryMemory(store *Store) *ScheduleRepositoryMemory {
+	return &ScheduleRepositoryMemory{
+		store: store,
+	}
+}
+
+// checkSchedule enforces the foreign keys of the tables.
+func (s *ScheduleRepositoryMemory) checkSchedule(schedule model.Schedule) error {
+	if _, ok := s.store.accounts[schedule.AccountId]; !ok {
+		return repository.ErrForeignKeyViolation
+	}
+
+	if _, ok := s.store.operationTypes[schedule.OperationTypeId]; !ok {
+		return repository.ErrForeignKeyViolation
+	}
+
+	return nil
+}
+
+func (s *ScheduleRepositoryMemory) CreateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	if err := s.checkSchedule(schedule); err != nil {
+		log.Printf("ScheduleRepositoryMemory#CreateSchedule: No account %d or operation type %d found", schedule.AccountId, schedule.OperationTypeId)
+
+		return nil, err
+	}
+
+	schedule.ScheduleId = s.store.scheduleSequence + 1
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SCHEDULE, schedule.ScheduleId, nil, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.scheduleSequence++
+	s.store.schedules[schedule.ScheduleId] = schedule
+	s.store.appendAudit(entry)
+
+	return &schedule, nil
+}
+
+func (s *ScheduleRepositoryMemory) FindSchedule(scheduleId uint64) (*model.Schedule, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	schedule, ok := s.store.schedules[scheduleId]
+
+	if !ok {
+		log.Printf("ScheduleRepositoryMemory#FindSchedule: No schedule found for ID %d", scheduleId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	return &schedule, nil
+}
+
+func (s *ScheduleRepositoryMemory) ListSchedules(filter repository.ScheduleFilter, page repository.Page) ([]model.Schedule, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	schedules := []model.Schedule{}
+
+	for scheduleId := page.AfterId + 1; scheduleId <= s.store.scheduleSequence && len(schedules) < page.EffectiveLimit(); scheduleId++ {
+		schedule, ok := s.store.schedules[scheduleId]
+
+		if !ok || (filter.AccountId != 0 && schedule.AccountId != filter.AccountId) {
+			continue
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+func (s *ScheduleRepositoryMemory) UpdateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	before, ok := s.store.schedules[schedule.ScheduleId]
+
+	if !ok {
+		log.Printf("ScheduleRepositoryMemory#UpdateSchedule: No schedule found for ID %d", schedule.ScheduleId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	if err := s.checkSchedule(schedule); err != nil {
+		log.Printf("ScheduleRepositoryMemory#UpdateSchedule: No account %d or operation type %d found", schedule.AccountId, schedule.OperationTypeId)
+
+		return nil, err
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_SCHEDULE, schedule.ScheduleId, before, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.schedules[schedule.ScheduleId] = schedule
+	s.store.appendAudit(entry)
+
+	return &schedule, nil
+}
+
+func (s *ScheduleRepositoryMemory) DeleteSchedule(ctx context.Context, scheduleId uint64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	deleted, ok := s.store.schedules[scheduleId]
+
+	if !ok {
+		log.Printf("ScheduleRepositoryMemory#DeleteSchedule: No schedule found for ID %d", scheduleId)
+
+		return repository.ErrNotFound
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SCHEDULE, scheduleId, deleted, nil)
+	if err != nil {
+		return err
+	}
+
+	delete(s.store.schedules, scheduleId)
+	s.store.appendAudit(entry)
+
+	return nil
+}
+
+func (s *ScheduleRepositoryMemory) ListDueSchedules(now time.Time, limit int) ([]model.Schedule, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	schedules := []model.Schedule{}
+
+	for _, schedule := range s.store.schedules {
+		if schedule.NextRunAt != nil && !schedule.NextRunAt.After(now) {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].NextRunAt.Equal(*schedules[j].NextRunAt) {
+			return schedules[i].NextRunAt.Before(*schedules[j].NextRunAt)
+		}
+
+		return schedules[i].ScheduleId < schedules[j].ScheduleId
+	})
+
+	if len(schedules) > limit {
+		schedules = schedules[:limit]
+	}
+
+	return schedules, nil
+}
+
+func (s *ScheduleRepositoryMemory) AdvanceSchedule(scheduleId uint64, from time.Time, next *time.Time) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	schedule, ok := s.store.schedules[scheduleId]
+
+	if !ok || schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(from) {
+		return repository.ErrConflict
+	}
+
+	schedule.NextRunAt = next
+	s.store.schedules[scheduleId] = schedule
+
+	return nil
+}
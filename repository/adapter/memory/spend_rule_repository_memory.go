@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type SpendRuleRepositoryMemory struct {
+	store *Store
+}
+
+func NewSpendRuleRepositoryMemory(store *Store) *SpendRuleRepositoryMemory {
+	return &SpendRuleRepositoryMemory{
+		store: store,
+	}
+}
+
+// CreateSpendRule enforces the foreign key and the unique rule per account
+// of the table.
+func (s *SpendRuleRepositoryMemory) CreateSpendRule(ctx context.Context, rule model.SpendRule) (*model.SpendRule, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	if _, ok := s.store.accounts[rule.AccountId]; !ok {
+		log.Printf("SpendRuleRepositoryMemory#CreateSpendRule: No account found for ID %d", rule.AccountId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	for _, existing := range s.store.spendRules {
+		if existing.AccountId == rule.AccountId && existing.Type == rule.Type && existing.Mcc == rule.Mcc && existing.Category == rule.Category && existing.Period == rule.Period {
+			log.Printf("SpendRuleRepositoryMemory#CreateSpendRule: Account %d has the rule already", rule.AccountId)
+
+			return nil, repository.ErrConflict
+		}
+	}
+
+	rule.SpendRuleId = s.store.spendRuleSequence + 1
+	rule.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SPEND_RULE, rule.SpendRuleId, nil, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store.spendRuleSequence++
+	s.store.spendRules[rule.SpendRuleId] = rule
+	s.store.appendAudit(entry)
+
+	return &rule, nil
+}
+
+func (s *SpendRuleRepositoryMemory) ListSpendRules(accountId uint64, page repository.Page) ([]model.SpendRule, error) {
+	s.store.mu.RLock()
+	defer s.store.mu.RUnlock()
+
+	rules := []model.SpendRule{}
+
+	for spendRuleId := page.AfterId + 1; spendRuleId <= s.store.spendRuleSequence && len(rules) < page.EffectiveLimit(); spendRuleId++ {
+		if rule, ok := s.store.spendRules[spendRuleId]; ok && rule.AccountId == accountId {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func (s *SpendRuleRepositoryMemory) DeleteSpendRule(ctx context.Context, spendRuleId uint64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+
+	deleted, ok := s.store.spendRules[spendRuleId]
+
+	if !ok {
+		log.Printf("SpendRuleRepositoryMemory#DeleteSpendRule: No spend rule found for ID %d", spendRuleId)
+
+		return repository.ErrNotFound
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SPEND_RULE, spendRuleId, deleted, nil)
+	if err != nil {
+		return err
+	}
+
+	delete(s.store.spendRules, spendRuleId)
+	s.store.appendAudit(entry)
+
+	return nil
+}
+
+// findSpendRules returns the spend rules of the account, the caller must
+// hold the lock.
+func (s *Store) findSpendRules(accountId uint64) ([]model.SpendRule, error) {
+	var rules []model.SpendRule
+
+	for spendRuleId := uint64(1); spendRuleId <= s.spendRuleSequence; spendRuleId++ {
+		if rule, ok := s.spendRules[spendRuleId]; ok && rule.AccountId == accountId {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// categorySpend returns the amount of the purchases and withdraws of the
+// account in category that took place from from until to and were not
+// reversed. The caller must hold the lock.
+func (s *Store) categorySpend(accountId uint64, category string, from time.Time, to time.Time) (model.Amount, error) {
+	spent := model.Amount{}
+
+	for _, event := range s.events {
+		if event.Type != model.EVENT_TRANSACTION_POSTED || event.AccountId != accountId || s.reversals[event.TransactionId] {
+			continue
+		}
+
+		transaction := s.posted[event.TransactionId]
+		amount := eventAmount(event)
+
+		if transaction.Category == category && amount.Sign() < 0 && !transaction.EventDate.Before(from) && transaction.EventDate.Before(to) {
+			spent = spent.Sub(amount)
+		}
+	}
+
+	return spent, nil
+}
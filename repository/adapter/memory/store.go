@@ -0,0 +1,392 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/cardcontrol"
+	"github.com/felipedsi/pismo-test/fx"
+	"github.com/felipedsi/pismo-test/merchantcontrol"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// Store holds the tables shared by the memory repositories so that foreign
+// keys between accounts and transactions can be checked the same way the
+// database does.
+//
+// Like the database, commands append to events and decide on the state of
+// the streams, while accounts, transactions and balances are read models
+// projected from the events.
+type Store struct {
+	mu sync.RWMutex
+
+	accounts       map[uint64]model.Account
+	transactions   map[uint64]model.Transaction
+	balances       map[uint64]model.Balance
+	snapshots      map[uint64][]snapshot
+	operationTypes map[uint32]string
+	imports        map[uint64]model.Import
+	rejections     map[uint64][]model.ImportRejection
+	exports        map[uint64]model.Export
+	schedules      map[uint64]model.Schedule
+	fxRates        map[uint64]model.FxRate
+	cards          map[uint64]model.Card
+	spendRules     map[uint64]model.SpendRule
+	riskRules      map[uint64]model.RiskRule
+	riskDecisions  []model.RiskDecision
+	riskCounters   map[riskCounterKey]riskCounter
+	disputes       map[uint64]model.Dispute
+	disputeChanges []model.DisputeChange
+	holders        map[uint64]model.Holder
+	dedupKeys      map[string]uint64
+	auditLog       []model.AuditEntry
+	events         []model.Event
+
+	// The state of the streams, kept as the events are appended.
+	versions  map[uint64]uint64
+	opened    map[uint64]model.Account
+	blocked   map[uint64]bool
+	posted    map[uint64]model.Transaction
+	reversals map[uint64]bool
+
+	// projected is how many events the read models applied.
+	projected int
+
+	accountSequence     uint64
+	transactionSequence uint64
+	importSequence      uint64
+	rejectionSequence   uint64
+	exportSequence      uint64
+	scheduleSequence    uint64
+	fxRateSequence      uint64
+	cardSequence        uint64
+	spendRuleSequence   uint64
+	riskRuleSequence    uint64
+	disputeSequence     uint64
+	holderSequence      uint64
+}
+
+// snapshot is the balance of an account at every
+// repository.BalanceSnapshotInterval versions of its stream, createdAt being
+// the time of the event at that version.
+type snapshot struct {
+	balance   model.Balance
+	createdAt time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		accounts:     map[uint64]model.Account{},
+		transactions: map[uint64]model.Transaction{},
+		balances:     map[uint64]model.Balance{},
+		snapshots:    map[uint64][]snapshot{},
+		versions:     map[uint64]uint64{},
+		opened:       map[uint64]model.Account{},
+		blocked:      map[uint64]bool{},
+		posted:       map[uint64]model.Transaction{},
+		reversals:    map[uint64]bool{},
+		imports:      map[uint64]model.Import{},
+		rejections:   map[uint64][]model.ImportRejection{},
+		exports:      map[uint64]model.Export{},
+		schedules:    map[uint64]model.Schedule{},
+		fxRates:      map[uint64]model.FxRate{},
+		cards:        map[uint64]model.Card{},
+		spendRules:   map[uint64]model.SpendRule{},
+		riskRules:    map[uint64]model.RiskRule{},
+		riskCounters: map[riskCounterKey]riskCounter{},
+		disputes:     map[uint64]model.Dispute{},
+		holders:      map[uint64]model.Holder{},
+		dedupKeys:    map[string]uint64{},
+		operationTypes: map[uint32]string{
+			model.CASH_PURCHASE:        "COMPRA A VISTA",
+			model.INSTALLMENT_PURCHASE: "COMPRA PARCELADA",
+			model.WITHDRAW:             "SAQUE",
+			model.PAYMENT:              "PAGAMENTO",
+			model.INTEREST:             "JUROS",
+			model.LATE_FEE:             "MULTA POR ATRASO",
+			model.DISPUTE_CREDIT:       "CREDITO PROVISORIO",
+		},
+	}
+}
+
+// checkTransactions fails like the database adapters when an account or
+// operation type of the transactions does not exist, an account is
+// blocked, ctx expects a version the stream of the account moved past or
+// carries a dedup key used before. The caller must hold the lock.
+func (s *Store) checkTransactions(ctx context.Context, transactions []model.Transaction) error {
+	accountIds := map[uint64]bool{}
+
+	for _, transaction := range transactions {
+		if s.versions[transaction.AccountId] == 0 {
+			return repository.ErrForeignKeyViolation
+		}
+
+		if _, ok := s.operationTypes[transaction.OperationTypeId]; !ok {
+			return repository.ErrForeignKeyViolation
+		}
+
+		if s.blocked[transaction.AccountId] {
+			return repository.ErrAccountBlocked
+		}
+
+		accountIds[transaction.AccountId] = true
+	}
+
+	if key, ok := repository.DedupKey(ctx); ok && s.dedupKeys[key] != 0 {
+		return repository.ErrConflict
+	}
+
+	if len(accountIds) == 1 {
+		return s.checkExpectedVersion(ctx, transactions[0].AccountId)
+	}
+
+	return nil
+}
+
+// checkExpectedVersion applies repository.WithExpectedVersion. The caller
+// must hold the lock.
+func (s *Store) checkExpectedVersion(ctx context.Context, accountId uint64) error {
+	if expected, ok := repository.ExpectedVersion(ctx); ok && s.versions[accountId] != expected {
+		return repository.ErrVersionConflict
+	}
+
+	return nil
+}
+
+// postTransactions appends the events posting the transactions and returns
+// them as posted. The caller must hold the write lock and have checked them.
+func (s *Store) postTransactions(transactions []model.Transaction) ([]model.Transaction, error) {
+	created, events, err := s.postedEvents(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	s.appendPostedEvents(events)
+	s.countRisk(created)
+
+	return created, nil
+}
+
+// postedEvents assigns the next IDs to the transactions, converts them to
+// the currency of their account, checks the spend controls of their cards
+// and the spend rules of their accounts, stamps them with the time they are posted at and returns them along with
+// the events posting them, storing nothing. The caller must hold the write
+// lock.
+func (s *Store) postedEvents(transactions []model.Transaction) ([]model.Transaction, []model.Event, error) {
+	transactions = append([]model.Transaction{}, transactions...)
+	currencies := map[uint64]string{}
+
+	for _, transaction := range transactions {
+		currencies[transaction.AccountId] = s.accounts[transaction.AccountId].Currency
+	}
+
+	if err := fx.ConvertTransactions(transactions, currencies, s.findFxRate); err != nil {
+		return nil, nil, err
+	}
+
+	if err := cardcontrol.CheckTransactions(transactions, s.findCard, s.daySpend); err != nil {
+		return nil, nil, err
+	}
+
+	if err := merchantcontrol.CheckTransactions(transactions, s.findSpendRules, s.categorySpend); err != nil {
+		return nil, nil, err
+	}
+
+	created := make([]model.Transaction, len(transactions))
+	events := make([]model.Event, len(transactions))
+	postedAt := time.Now().UTC().Truncate(time.Millisecond)
+
+	for n, transaction := range transactions {
+		transaction.TransactionId = s.transactionSequence + uint64(n) + 1
+		transaction.CreatedAt = postedAt
+		transaction.Category = model.MerchantCategory(transaction.Mcc)
+
+		if transaction.EventDate.IsZero() {
+			transaction.EventDate = postedAt
+		}
+
+		transaction.EventDate = transaction.EventDate.UTC().Truncate(time.Millisecond)
+
+		event, err := model.NewEvent(model.EVENT_TRANSACTION_POSTED, transaction.AccountId, transaction.TransactionId, transaction)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		event.CreatedAt = postedAt
+
+		created[n] = transaction
+		events[n] = event
+	}
+
+	return created, events, nil
+}
+
+// appendPostedEvents appends the events returned by postedEvents, moving
+// the transaction sequence past them. The caller must hold the write lock.
+func (s *Store) appendPostedEvents(events []model.Event) {
+	s.transactionSequence += uint64(len(events))
+	s.appendEvents(events...)
+}
+
+// appendEvents numbers the events after the versions of their streams,
+// stores them with the next IDs and projects them. The caller must hold the
+// write lock.
+func (s *Store) appendEvents(events ...model.Event) {
+	for _, event := range events {
+		s.versions[event.AccountId]++
+
+		event.EventId = uint64(len(s.events)) + 1
+		event.Version = s.versions[event.AccountId]
+
+		switch event.Type {
+		case model.EVENT_ACCOUNT_BLOCKED:
+			s.blocked[event.AccountId] = true
+		case model.EVENT_TRANSACTION_POSTED:
+			transaction := model.Transaction{}
+			json.Unmarshal(event.Data, &transaction)
+
+			s.posted[event.TransactionId] = transaction
+		case model.EVENT_TRANSACTION_REVERSED:
+			s.reversals[event.TransactionId] = true
+		}
+
+		s.events = append(s.events, event)
+	}
+
+	s.projectEvents(len(s.events))
+}
+
+// projectEvents applies up to limit events to the read models and returns
+// how many it applied. The caller must hold the write lock.
+func (s *Store) projectEvents(limit int) int {
+	applied := 0
+
+	for ; s.projected < len(s.events) && applied < limit; applied++ {
+		event := s.events[s.projected]
+		s.projected++
+
+		balance := s.balances[event.AccountId]
+		balance.AccountId = event.AccountId
+		balance.Version = event.Version
+
+		switch event.Type {
+		case model.EVENT_ACCOUNT_OPENED:
+			// A replay keeps the accounts still there, like the databases.
+			if _, ok := s.accounts[event.AccountId]; !ok {
+				s.accounts[event.AccountId] = s.opened[event.AccountId]
+			}
+		case model.EVENT_ACCOUNT_BLOCKED:
+			account := s.accounts[event.AccountId]
+			account.Blocked = true
+
+			s.accounts[event.AccountId] = account
+		case model.EVENT_TRANSACTION_POSTED:
+			transaction := s.posted[event.TransactionId]
+
+			s.transactions[event.TransactionId] = transaction
+			balance.Balance += eventAmount(event).Float64()
+		case model.EVENT_TRANSACTION_REVERSED:
+			transaction := s.transactions[event.TransactionId]
+			transaction.Reversed = true
+
+			s.transactions[event.TransactionId] = transaction
+			balance.Balance -= eventAmount(event).Float64()
+		}
+
+		s.balances[event.AccountId] = balance
+
+		if event.Version%repository.BalanceSnapshotInterval == 0 {
+			s.snapshots[event.AccountId] = append(s.snapshots[event.AccountId], snapshot{balance: balance, createdAt: event.CreatedAt})
+		}
+	}
+
+	return applied
+}
+
+// balanceAsOf starts from the last snapshot taken before asOf and applies
+// the events of the account that follow it. The caller must hold the lock.
+func (s *Store) balanceAsOf(accountId uint64, asOf time.Time) model.Balance {
+	balance := model.Balance{AccountId: accountId}
+	snapshots := s.snapshots[accountId]
+
+	for n := len(snapshots) - 1; n >= 0; n-- {
+		if snapshots[n].createdAt.Before(asOf) {
+			balance = snapshots[n].balance
+			break
+		}
+	}
+
+	balance.Blocked = false
+
+	for _, event := range s.events {
+		if event.AccountId != accountId || !event.CreatedAt.Before(asOf) {
+			continue
+		}
+
+		if event.Type == model.EVENT_ACCOUNT_BLOCKED {
+			balance.Blocked = true
+		}
+
+		if event.Version <= balance.Version {
+			continue
+		}
+
+		balance.Version = event.Version
+
+		switch event.Type {
+		case model.EVENT_TRANSACTION_POSTED:
+			balance.Balance += eventAmount(event).Float64()
+		case model.EVENT_TRANSACTION_REVERSED:
+			balance.Balance -= eventAmount(event).Float64()
+		}
+	}
+
+	return balance
+}
+
+// resetProjections empties the read models so the events are projected
+// again from the first one. The caller must hold the write lock.
+func (s *Store) resetProjections() {
+	s.transactions = map[uint64]model.Transaction{}
+	s.balances = map[uint64]model.Balance{}
+	s.snapshots = map[uint64][]snapshot{}
+	s.projected = 0
+
+	for accountId, account := range s.accounts {
+		account.Blocked = false
+		s.accounts[accountId] = account
+	}
+}
+
+// eventAmount reads the amount of a posted or reversed transaction as
+// written in the event, like the database adapters do.
+func eventAmount(event model.Event) model.Amount {
+	var data struct {
+		Amount model.Amount `json:"amount"`
+	}
+
+	json.Unmarshal(event.Data, &data)
+
+	return data.Amount
+}
+
+// appendAudit chains the entries to the end of the audit log and stores
+// them with the next IDs. The caller must hold the write lock.
+func (s *Store) appendAudit(entries ...model.AuditEntry) {
+	lastHash := ""
+
+	if len(s.auditLog) > 0 {
+		lastHash = s.auditLog[len(s.auditLog)-1].Hash
+	}
+
+	audit.Chain(lastHash, entries)
+
+	for _, entry := range entries {
+		entry.AuditEntryId = uint64(len(s.auditLog)) + 1
+		s.auditLog = append(s.auditLog, entry)
+	}
+}
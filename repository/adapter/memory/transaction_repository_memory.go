@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"log"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type TransactionRepositoryMemory struct {
+	store *Store
+}
+
+func NewTransactionRepositoryMemory(store *Store) *TransactionRepositoryMemory {
+	return &TransactionRepositoryMemory{
+		store: store,
+	}
+}
+
+func (t *TransactionRepositoryMemory) CreateTransaction(ctx context.Context, transaction model.Transaction) (*model.Transaction, error) {
+	created, err := t.CreateTransactions(ctx, []model.Transaction{transaction})
+	if err != nil {
+		return nil, err
+	}
+
+	return &created[0], nil
+}
+
+func (t *TransactionRepositoryMemory) CreateTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error) {
+	if len(transactions) == 0 {
+		return []model.Transaction{}, nil
+	}
+
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	// Every transaction is checked before any is stored, like a rolled back
+	// statement.
+	if err := t.store.checkTransactions(ctx, transactions); err != nil {
+		log.Printf("TransactionRepositoryMemory#CreateTransactions: Checking the transactions failed: %s", err)
+
+		return nil, err
+	}
+
+	return t.insertTransactions(ctx, transactions)
+}
+
+// insertTransactions posts the transactions along with their audit
+// entries, which are all built first so nothing is stored when one fails.
+// The caller must hold the write lock.
+func (t *TransactionRepositoryMemory) insertTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error) {
+	created, events, err := t.store.postedEvents(transactions)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]model.AuditEntry, len(created))
+
+	for n, transaction := range created {
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_TRANSACTION, transaction.TransactionId, nil, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[n] = entry
+	}
+
+	if key, ok := repository.DedupKey(ctx); ok {
+		t.store.dedupKeys[key] = created[0].TransactionId
+	}
+
+	t.store.appendPostedEvents(events)
+	t.store.countRisk(created)
+	t.store.appendAudit(entries...)
+
+	return created, nil
+}
+
+func (t *TransactionRepositoryMemory) ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	t.store.mu.Lock()
+	defer t.store.mu.Unlock()
+
+	return t.reverseTransaction(ctx, transactionId)
+}
+
+// reverseTransaction reverses the transaction along with its audit entry.
+// The caller must hold the write lock.
+func (t *TransactionRepositoryMemory) reverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	transaction, ok := t.store.posted[transactionId]
+
+	if !ok {
+		log.Printf("TransactionRepositoryMemory#ReverseTransaction: No transaction found for ID %d", transactionId)
+
+		return nil, repository.ErrNotFound
+	}
+
+	if t.store.reversals[transactionId] {
+		log.Printf("TransactionRepositoryMemory#ReverseTransaction: Transaction %d is already reversed", transactionId)
+
+		return nil, repository.ErrConflict
+	}
+
+	if err := t.store.checkExpectedVersion(ctx, transaction.AccountId); err != nil {
+		return nil, err
+	}
+
+	before := transaction
+	transaction.Reversed = true
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_TRANSACTION, transactionId, before, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal := model.TransactionReversal{TransactionId: transactionId, Amount: transaction.Amount}
+
+	event, err := model.NewEvent(model.EVENT_TRANSACTION_REVERSED, transaction.AccountId, transactionId, reversal)
+	if err != nil {
+		return nil, err
+	}
+
+	t.store.appendEvents(event)
+	t.store.appendAudit(entry)
+
+	return &transaction, nil
+}
+
+func (t *TransactionRepositoryMemory) ListTransactions(filter repository.TransactionFilter, page repository.Page) ([]model.Transaction, error) {
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+
+	transactions := []model.Transaction{}
+
+	for transactionId := page.AfterId + 1; transactionId <= t.store.transactionSequence && len(transactions) < page.EffectiveLimit(); transactionId++ {
+		transaction, ok := t.store.transactions[transactionId]
+
+		if !ok || !matchesTransactionFilter(transaction, filter) {
+			continue
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+func (t *TransactionRepositoryMemory) ListTransactionsByAccounts(accountIds []uint64, page repository.Page) (map[uint64][]model.Transaction, error) {
+	transactions := map[uint64][]model.Transaction{}
+
+	for _, accountId := range accountIds {
+		if _, ok := transactions[accountId]; ok {
+			continue
+		}
+
+		accountTransactions, err := t.ListTransactions(repository.TransactionFilter{AccountId: accountId}, page)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(accountTransactions) > 0 {
+			transactions[accountId] = accountTransactions
+		}
+	}
+
+	return transactions, nil
+}
+
+// matchesTransactionFilter applies the filter like the WHERE clauses of the
+// database adapters.
+func matchesTransactionFilter(transaction model.Transaction, filter repository.TransactionFilter) bool {
+	switch {
+	case filter.AccountId != 0 && transaction.AccountId != filter.AccountId:
+		return false
+	case filter.OperationTypeId != 0 && transaction.OperationTypeId != filter.OperationTypeId:
+		return false
+	case !filter.EventDateFrom.IsZero() && transaction.EventDate.Before(filter.EventDateFrom):
+		return false
+	case !filter.EventDateTo.IsZero() && !transaction.EventDate.Before(filter.EventDateTo):
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type OperationTypeRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewOperationTypeRepositoryPostgres(db *sql.DB) *OperationTypeRepositoryPostgres {
+	return &OperationTypeRepositoryPostgres{
+		db: db,
+	}
+}
+
+func (o *OperationTypeRepositoryPostgres) ListOperationTypes() ([]model.OperationType, error) {
+	query := "SELECT operation_type_id, description FROM operation_types ORDER BY operation_type_id"
+
+	rows, err := o.db.Query(query)
+
+	if err != nil {
+		log.Printf("OperationTypeRepositoryPostgres#ListOperationTypes: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	operationTypes := []model.OperationType{}
+
+	for rows.Next() {
+		operationType := model.OperationType{}
+
+		err := rows.Scan(&operationType.OperationTypeId, &operationType.Description)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		operationTypes = append(operationTypes, operationType)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("OperationTypeRepositoryPostgres#ListOperationTypes: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return operationTypes, nil
+}
@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type OperationTypeRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewOperationTypeRepositorySQLite(db *sql.DB) *OperationTypeRepositorySQLite {
+	return &OperationTypeRepositorySQLite{
+		db: db,
+	}
+}
+
+func (o *OperationTypeRepositorySQLite) ListOperationTypes() ([]model.OperationType, error) {
+	query := "SELECT operation_type_id, description FROM operation_types ORDER BY operation_type_id"
+
+	rows, err := o.db.Query(query)
+
+	if err != nil {
+		log.Printf("OperationTypeRepositorySQLite#ListOperationTypes: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	operationTypes := []model.OperationType{}
+
+	for rows.Next() {
+		operationType := model.OperationType{}
+
+		err := rows.Scan(&operationType.OperationTypeId, &operationType.Description)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		operationTypes = append(operationTypes, operationType)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("OperationTypeRepositorySQLite#ListOperationTypes: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return operationTypes, nil
+}
@@ -0,0 +1,60 @@
+package adapter
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/repository/repositorytest"
+)
+
+// The contract runs against a migrated database pointed to by POSTGRESQL_URL
+// and truncates its tables, so never point it at data you want to keep.
+func TestPostgresRepositoryContract(t *testing.T) {
+	connStr := os.Getenv("POSTGRESQL_URL")
+
+	if connStr == "" {
+		t.Skip("POSTGRESQL_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		_, err := db.Exec("TRUNCATE audit_log, exports, import_rejections, imports, account_balances, transactions, transaction_dedup_keys, schedules, fx_rates, cards, spend_rules, risk_rules, risk_decisions, risk_counters, dispute_changes, disputes, events, accounts, holders RESTART IDENTITY CASCADE")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The projections are seeded by the migrations, only their
+		// positions are reset.
+		_, err = db.Exec("UPDATE projections SET position = 0, position_xid = '0'")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repositorytest.Repositories{
+			Accounts:       NewAccountRepositoryPostgres(db, newTestCipher(t), newTestIndex(t)),
+			Transactions:   NewTransactionRepositoryPostgres(db),
+			OperationTypes: NewOperationTypeRepositoryPostgres(db),
+			Imports:        NewImportRepositoryPostgres(db),
+			Exports:        NewExportRepositoryPostgres(db),
+			Audit:          NewAuditRepositoryPostgres(db),
+			Events:         NewEventRepositoryPostgres(db),
+			Projections:    NewProjectionRepositoryPostgres(db),
+			Schedules:      NewScheduleRepositoryPostgres(db),
+			FxRates:        NewFxRateRepositoryPostgres(db),
+			Cards:          NewCardRepositoryPostgres(db),
+			SpendRules:     NewSpendRuleRepositoryPostgres(db),
+			Risk:           NewRiskRepositoryPostgres(db),
+			Disputes:       NewDisputeRepositoryPostgres(db),
+			Holders:        NewHolderRepositoryPostgres(db, newTestCipher(t)),
+		}
+	})
+}
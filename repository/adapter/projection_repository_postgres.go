@@ -0,0 +1,367 @@
+package adapter
+
+import (
+	"database/sql"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// projectionBatch is how many events a command projects at once when
+// catching up after committing.
+const projectionBatch = 1000
+
+// catchUpProjections projects the events a command appended before it
+// returns, so its changes can be read right away. A failure is left to the
+// projector, which applies the events later.
+func catchUpProjections(projections repository.ProjectionRepository, caller string) {
+	for {
+		applied, err := projections.ProjectEvents(projectionBatch)
+
+		if err != nil {
+			log.Printf("%s: Projecting events failed: %s", caller, err)
+
+			return
+		}
+
+		if applied < projectionBatch {
+			return
+		}
+	}
+}
+
+// accountChange is what a run of events does to an account and its balance.
+// opened is the account, when the run opens it.
+type accountChange struct {
+	opened    *accountOpened
+	blocked   bool
+	amount    model.Amount
+	version   uint64
+	snapshots []balanceSnapshot
+}
+
+// balanceSnapshot is due at every repository.BalanceSnapshotInterval
+// versions of a stream. amount is what the run of events added to the
+// balance up to that version, createdAt the time of the event at it.
+type balanceSnapshot struct {
+	version   uint64
+	amount    model.Amount
+	createdAt time.Time
+}
+
+// accountChanges sums up the events by account, returning the accounts in
+// ascending order so concurrent projections lock their rows in the same
+// order.
+func accountChanges(events []model.Event) ([]uint64, map[uint64]*accountChange, error) {
+	accountIds := []uint64{}
+	changes := map[uint64]*accountChange{}
+
+	for _, event := range events {
+		change, ok := changes[event.AccountId]
+
+		if !ok {
+			change = &accountChange{}
+			changes[event.AccountId] = change
+			accountIds = append(accountIds, event.AccountId)
+		}
+
+		change.version = event.Version
+
+		switch event.Type {
+		case model.EVENT_ACCOUNT_OPENED:
+			opened, err := openedAccount(event.Data)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			change.opened = opened
+		case model.EVENT_ACCOUNT_BLOCKED:
+			change.blocked = true
+		case model.EVENT_TRANSACTION_POSTED, model.EVENT_TRANSACTION_REVERSED:
+			amount, err := eventAmount(event)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if event.Type == model.EVENT_TRANSACTION_REVERSED {
+				amount = amount.Neg()
+			}
+
+			change.amount = change.amount.Add(amount)
+		}
+
+		if event.Version%repository.BalanceSnapshotInterval == 0 {
+			change.snapshots = append(change.snapshots, balanceSnapshot{version: event.Version, amount: change.amount, createdAt: event.CreatedAt})
+		}
+	}
+
+	sort.Slice(accountIds, func(i, j int) bool { return accountIds[i] < accountIds[j] })
+
+	return accountIds, changes, nil
+}
+
+// foldBalance moves balance, empty or taken from a snapshot, forward by
+// the events of its account that follow.
+func foldBalance(balance *model.Balance, events []model.Event) error {
+	_, changes, err := accountChanges(events)
+	if err != nil {
+		return err
+	}
+
+	change, ok := changes[balance.AccountId]
+
+	if !ok {
+		return nil
+	}
+
+	balance.Balance += change.amount.Float64()
+	balance.Blocked = balance.Blocked || change.blocked
+
+	if change.version > balance.Version {
+		balance.Version = change.version
+	}
+
+	return nil
+}
+
+// transactionChanges returns the transactions posted and the IDs of the
+// ones reversed by the events.
+func transactionChanges(events []model.Event) ([]model.Transaction, []uint64, error) {
+	posted := []model.Transaction{}
+	reversed := []uint64{}
+
+	for _, event := range events {
+		switch event.Type {
+		case model.EVENT_TRANSACTION_POSTED:
+			transaction, err := postedTransaction(event.Data, event.CreatedAt)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			posted = append(posted, transaction)
+		case model.EVENT_TRANSACTION_REVERSED:
+			reversed = append(reversed, event.TransactionId)
+		}
+	}
+
+	return posted, reversed, nil
+}
+
+// projectionPostgres is a read model kept by applying the events in order.
+// reset empties it before a replay.
+type projectionPostgres struct {
+	name  string
+	apply func(tx *sql.Tx, events []model.Event) error
+	reset string
+}
+
+var projectionsPostgres = []projectionPostgres{
+	// The accounts are referenced by other tables, so a replay adds the
+	// ones missing rather than emptying them.
+	{name: "accounts", apply: applyAccountsPostgres, reset: "DELETE FROM balance_snapshots; DELETE FROM account_balances; UPDATE accounts SET blocked = FALSE"},
+	{name: "transactions", apply: applyTransactionsPostgres, reset: "DELETE FROM transactions"},
+}
+
+// applyAccountsPostgres keeps the accounts, their balances and whether they
+// are blocked.
+func applyAccountsPostgres(tx *sql.Tx, events []model.Event) error {
+	accountIds, changes, err := accountChanges(events)
+	if err != nil {
+		return err
+	}
+
+	for _, accountId := range accountIds {
+		change := changes[accountId]
+
+		query := "UPDATE account_balances SET balance = balance + $2, version = $3 WHERE account_id = $1"
+
+		if change.opened != nil {
+			if err := insertAccountPostgres(tx, accountId, change.opened); err != nil {
+				return err
+			}
+
+			query = "INSERT INTO account_balances (account_id, balance, version) VALUES ($1, $2, $3)"
+		}
+
+		if _, err := tx.Exec(query, accountId, change.amount, change.version); err != nil {
+			return err
+		}
+
+		if change.blocked {
+			if _, err := tx.Exec("UPDATE accounts SET blocked = TRUE WHERE account_id = $1", accountId); err != nil {
+				return err
+			}
+		}
+
+		// The balance now includes the whole run, so each snapshot takes
+		// off what followed it.
+		for _, snapshot := range change.snapshots {
+			query := "INSERT INTO balance_snapshots (account_id, version, balance, created_at) SELECT account_id, $2, balance - $3, $4 FROM account_balances WHERE account_id = $1"
+
+			if _, err := tx.Exec(query, accountId, snapshot.version, change.amount.Sub(snapshot.amount), snapshot.createdAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// insertAccountPostgres adds the account opened, unless a replay finds it
+// still there: its document number may have been re-encrypted since.
+func insertAccountPostgres(tx *sql.Tx, accountId uint64, opened *accountOpened) error {
+	query := `INSERT INTO accounts (account_id, document_number_ciphertext, document_number_index, pii_key_id, currency, holder_id)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (account_id) DO NOTHING`
+
+	_, err := tx.Exec(query, accountId, nullString(opened.DocumentNumberCiphertext), nullString(opened.DocumentNumberIndex), nullString(opened.PiiKeyId), opened.Currency, nullId(opened.HolderId))
+
+	return err
+}
+
+// applyTransactionsPostgres keeps the transactions listed by the API.
+func applyTransactionsPostgres(tx *sql.Tx, events []model.Event) error {
+	posted, reversed, err := transactionChanges(events)
+	if err != nil {
+		return err
+	}
+
+	err = copyRows(tx, pq.CopyIn("transactions", "transaction_id", "account_id", "operation_type_id", "amount", "event_date", "created_at", "currency", "original_amount", "original_currency", "fx_rate", "fx_rate_id", "card_id", "merchant_name", "merchant_id", "mcc", "merchant_country", "category"), len(posted), func(n int) []interface{} {
+		transaction := posted[n]
+
+		row := []interface{}{transaction.TransactionId, transaction.AccountId, transaction.OperationTypeId, transaction.Amount, transaction.EventDate, transaction.CreatedAt, transaction.Currency}
+
+		row = append(append(row, transactionConversionRow(transaction)...), nullId(transaction.CardId))
+
+		return append(row, transactionMerchantRow(transaction)...)
+	})
+
+	if err != nil || len(reversed) == 0 {
+		return err
+	}
+
+	ids := make([]int64, len(reversed))
+
+	for i, transactionId := range reversed {
+		ids[i] = int64(transactionId)
+	}
+
+	_, err = tx.Exec("UPDATE transactions SET reversed = TRUE WHERE transaction_id = ANY($1)", pq.Array(ids))
+
+	return err
+}
+
+type ProjectionRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewProjectionRepositoryPostgres(db *sql.DB) *ProjectionRepositoryPostgres {
+	return &ProjectionRepositoryPostgres{
+		db: db,
+	}
+}
+
+// ProjectEvents applies each read model in its own transaction, which locks
+// its position so concurrent calls apply the events once.
+func (p *ProjectionRepositoryPostgres) ProjectEvents(limit int) (int, error) {
+	most := 0
+
+	for _, projection := range projectionsPostgres {
+		applied, err := p.project(projection, limit)
+
+		if err != nil {
+			log.Printf("ProjectionRepositoryPostgres#ProjectEvents: Projecting %s failed: %s", projection.name, err)
+
+			return 0, translatePostgresError(err)
+		}
+
+		if applied > most {
+			most = applied
+		}
+	}
+
+	return most, nil
+}
+
+func (p *ProjectionRepositoryPostgres) project(projection projectionPostgres, limit int) (int, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var position uint64
+	var positionXid string
+
+	err = tx.QueryRow("SELECT position, position_xid::TEXT FROM projections WHERE name = $1 FOR UPDATE", projection.name).Scan(&position, &positionXid)
+	if err != nil {
+		return 0, err
+	}
+
+	// The events are applied in the order of the IDs of the transactions
+	// that appended them, which cannot be committed after the oldest one
+	// still running starts: the ones from there on are left for later.
+	query := `SELECT ` + eventColumns + ` FROM events
+		WHERE (transaction_xid, event_id) > ($1::XID8, $2) AND transaction_xid < pg_snapshot_xmin(pg_current_snapshot())
+		ORDER BY transaction_xid, event_id LIMIT $3`
+
+	rows, err := tx.Query(query, positionXid, position, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := scanEventsPostgres(rows)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err := projection.apply(tx, events); err != nil {
+		return 0, err
+	}
+
+	query = "UPDATE projections SET position = e.event_id, position_xid = e.transaction_xid FROM events e WHERE name = $1 AND e.event_id = $2"
+
+	if _, err := tx.Exec(query, projection.name, events[len(events)-1].EventId); err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+func (p *ProjectionRepositoryPostgres) ResetProjections() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		log.Printf("ProjectionRepositoryPostgres#ResetProjections: Beginning transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE projections SET position = 0, position_xid = '0'"); err != nil {
+		log.Printf("ProjectionRepositoryPostgres#ResetProjections: Resetting positions failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	for _, projection := range projectionsPostgres {
+		if _, err := tx.Exec(projection.reset); err != nil {
+			log.Printf("ProjectionRepositoryPostgres#ResetProjections: Database query (%s) failed: %s", projection.reset, err)
+
+			return translatePostgresError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ProjectionRepositoryPostgres#ResetProjections: Committing transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
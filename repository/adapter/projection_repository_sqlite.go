@@ -0,0 +1,212 @@
+package adapter
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// projectionSQLite is a read model kept by applying the events in order.
+// reset empties it before a replay.
+type projectionSQLite struct {
+	name  string
+	apply func(tx *sql.Tx, events []model.Event) error
+	reset string
+}
+
+var projectionsSQLite = []projectionSQLite{
+	// The accounts are referenced by other tables, so a replay adds the
+	// ones missing rather than emptying them.
+	{name: "accounts", apply: applyAccountsSQLite, reset: "DELETE FROM balance_snapshots; DELETE FROM account_balances; UPDATE accounts SET blocked = FALSE"},
+	{name: "transactions", apply: applyTransactionsSQLite, reset: "DELETE FROM transactions"},
+}
+
+// applyAccountsSQLite keeps the accounts, their balances and whether they
+// are blocked.
+func applyAccountsSQLite(tx *sql.Tx, events []model.Event) error {
+	accountIds, changes, err := accountChanges(events)
+	if err != nil {
+		return err
+	}
+
+	for _, accountId := range accountIds {
+		change := changes[accountId]
+
+		query := "UPDATE account_balances SET balance = balance + ?2, version = ?3 WHERE account_id = ?1"
+
+		if change.opened != nil {
+			if err := insertAccountSQLite(tx, accountId, change.opened); err != nil {
+				return err
+			}
+
+			query = "INSERT INTO account_balances (account_id, balance, version) VALUES (?1, ?2, ?3)"
+		}
+
+		if _, err := tx.Exec(query, accountId, change.amount, change.version); err != nil {
+			return err
+		}
+
+		if change.blocked {
+			if _, err := tx.Exec("UPDATE accounts SET blocked = TRUE WHERE account_id = ?", accountId); err != nil {
+				return err
+			}
+		}
+
+		// The balance now includes the whole run, so each snapshot takes
+		// off what followed it.
+		for _, snapshot := range change.snapshots {
+			query := "INSERT INTO balance_snapshots (account_id, version, balance, created_at) SELECT account_id, ?2, balance - ?3, ?4 FROM account_balances WHERE account_id = ?1"
+
+			if _, err := tx.Exec(query, accountId, snapshot.version, change.amount.Sub(snapshot.amount), sqliteTime(snapshot.createdAt)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// insertAccountSQLite adds the account opened, unless a replay finds it
+// still there: its document number may have been re-encrypted since.
+func insertAccountSQLite(tx *sql.Tx, accountId uint64, opened *accountOpened) error {
+	query := `INSERT INTO accounts (account_id, document_number, document_number_ciphertext, document_number_index, pii_key_id, currency, holder_id)
+		VALUES (?1, 0, ?2, ?3, ?4, ?5, ?6) ON CONFLICT (account_id) DO NOTHING`
+
+	_, err := tx.Exec(query, accountId, nullString(opened.DocumentNumberCiphertext), nullString(opened.DocumentNumberIndex), nullString(opened.PiiKeyId), opened.Currency, nullId(opened.HolderId))
+
+	return err
+}
+
+// applyTransactionsSQLite keeps the transactions listed by the API.
+func applyTransactionsSQLite(tx *sql.Tx, events []model.Event) error {
+	posted, reversed, err := transactionChanges(events)
+	if err != nil {
+		return err
+	}
+
+	err = insertRows(tx, "transactions", []string{"transaction_id", "account_id", "operation_type_id", "amount", "event_date", "created_at", "currency", "original_amount", "original_currency", "fx_rate", "fx_rate_id", "card_id", "merchant_name", "merchant_id", "mcc", "merchant_country", "category"}, len(posted), func(n int) []interface{} {
+		transaction := posted[n]
+
+		row := []interface{}{transaction.TransactionId, transaction.AccountId, transaction.OperationTypeId, transaction.Amount, sqliteTime(transaction.EventDate), sqliteTime(transaction.CreatedAt), transaction.Currency}
+
+		row = append(append(row, transactionConversionRow(transaction)...), nullId(transaction.CardId))
+
+		return append(row, transactionMerchantRow(transaction)...)
+	})
+
+	if err != nil || len(reversed) == 0 {
+		return err
+	}
+
+	ids, err := sqliteIdList(reversed)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE transactions SET reversed = TRUE WHERE transaction_id IN (SELECT value FROM json_each(?))", ids)
+
+	return err
+}
+
+type ProjectionRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewProjectionRepositorySQLite(db *sql.DB) *ProjectionRepositorySQLite {
+	return &ProjectionRepositorySQLite{
+		db: db,
+	}
+}
+
+// ProjectEvents applies each read model in its own transaction, which holds
+// the write lock so concurrent calls apply the events once.
+func (p *ProjectionRepositorySQLite) ProjectEvents(limit int) (int, error) {
+	most := 0
+
+	for _, projection := range projectionsSQLite {
+		applied, err := p.project(projection, limit)
+
+		if err != nil {
+			log.Printf("ProjectionRepositorySQLite#ProjectEvents: Projecting %s failed: %s", projection.name, err)
+
+			return 0, translateSQLiteError(err)
+		}
+
+		if applied > most {
+			most = applied
+		}
+	}
+
+	return most, nil
+}
+
+func (p *ProjectionRepositorySQLite) project(projection projectionSQLite, limit int) (int, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer tx.Rollback()
+
+	var position uint64
+
+	err = tx.QueryRow("SELECT position FROM projections WHERE name = ?", projection.name).Scan(&position)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query("SELECT "+eventColumns+" FROM events WHERE event_id > ? ORDER BY event_id LIMIT ?", position, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := scanEventsSQLite(rows)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	if err := projection.apply(tx, events); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE projections SET position = ?2 WHERE name = ?1", projection.name, events[len(events)-1].EventId)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit()
+}
+
+func (p *ProjectionRepositorySQLite) ResetProjections() error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		log.Printf("ProjectionRepositorySQLite#ResetProjections: Beginning transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE projections SET position = 0"); err != nil {
+		log.Printf("ProjectionRepositorySQLite#ResetProjections: Resetting positions failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	for _, projection := range projectionsSQLite {
+		if _, err := tx.Exec(projection.reset); err != nil {
+			log.Printf("ProjectionRepositorySQLite#ResetProjections: Database query (%s) failed: %s", projection.reset, err)
+
+			return translateSQLiteError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ProjectionRepositorySQLite#ResetProjections: Committing transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,387 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+const riskRuleColumns = "risk_rule_id, name, expression, action, created_at"
+
+const riskDecisionColumns = "risk_decision_id, account_id, operation_type_id, amount, merchant_country, outcome, rules, created_at"
+
+// The periods of the risk counters.
+const (
+	riskPeriodMinute = "minute"
+	riskPeriodDay    = "day"
+)
+
+// riskCounter is a row of risk_counters.
+type riskCounter struct {
+	period       string
+	periodStart  time.Time
+	transactions int
+	spent        float64
+}
+
+// riskCountersOf returns the counters of the account of decision, made at
+// its CreatedAt, from its rows of risk_counters, counting decision along.
+// The rows of earlier periods count nothing.
+func riskCountersOf(decision model.RiskDecision, rows []riskCounter, openedAt time.Time, lastCountry string) model.RiskCounters {
+	counters := model.RiskCounters{
+		OpenedAt:           openedAt,
+		TransactionsMinute: 1,
+		SpentDay:           risk.Spend(decision.OperationTypeId, decision.Amount),
+		LastCountry:        lastCountry,
+	}
+
+	for _, row := range rows {
+		switch {
+		case row.period == riskPeriodMinute && row.periodStart.Equal(risk.MinuteOf(decision.CreatedAt)):
+			counters.TransactionsMinute += row.transactions
+		case row.period == riskPeriodDay && row.periodStart.Equal(risk.DayOf(decision.CreatedAt)):
+			counters.SpentDay += row.spent
+		}
+	}
+
+	return counters
+}
+
+// riskCountersFor returns what transaction, once posted, adds to the
+// counters of its account, in its billing currency.
+func riskCountersFor(transaction model.Transaction) []riskCounter {
+	spent := risk.Spend(transaction.OperationTypeId, transaction.Amount)
+
+	return []riskCounter{
+		{period: riskPeriodMinute, periodStart: risk.MinuteOf(transaction.CreatedAt), transactions: 1, spent: spent},
+		{period: riskPeriodDay, periodStart: risk.DayOf(transaction.CreatedAt), transactions: 1, spent: spent},
+	}
+}
+
+type RiskRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewRiskRepositoryPostgres(db *sql.DB) *RiskRepositoryPostgres {
+	return &RiskRepositoryPostgres{
+		db: db,
+	}
+}
+
+func scanRiskRule(row interface{ Scan(...interface{}) error }) (*model.RiskRule, error) {
+	rule := model.RiskRule{}
+
+	err := row.Scan(&rule.RiskRuleId, &rule.Name, &rule.Expression, &rule.Action, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.CreatedAt = rule.CreatedAt.UTC()
+
+	return &rule, nil
+}
+
+func scanRiskDecision(row interface{ Scan(...interface{}) error }) (*model.RiskDecision, error) {
+	decision := model.RiskDecision{}
+
+	var rules string
+
+	err := row.Scan(&decision.RiskDecisionId, &decision.AccountId, &decision.OperationTypeId, &decision.Amount, &decision.MerchantCountry, &decision.Outcome, &rules, &decision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(rules), &decision.Rules); err != nil {
+		return nil, err
+	}
+
+	decision.CreatedAt = decision.CreatedAt.UTC()
+
+	return &decision, nil
+}
+
+func (s *RiskRepositoryPostgres) CreateRiskRule(ctx context.Context, rule model.RiskRule) (*model.RiskRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#CreateRiskRule: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO risk_rules (name, expression, action, created_at) VALUES ($1, $2, $3, $4) RETURNING " + riskRuleColumns
+
+	created, err := scanRiskRule(tx.QueryRow(query, rule.Name, rule.Expression, rule.Action, time.Now().UTC().Truncate(time.Millisecond)))
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#CreateRiskRule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_RISK_RULE, created.RiskRuleId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#CreateRiskRule: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositoryPostgres#CreateRiskRule: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (s *RiskRepositoryPostgres) ListRiskRules(page repository.Page) ([]model.RiskRule, error) {
+	query := "SELECT " + riskRuleColumns + " FROM risk_rules WHERE risk_rule_id > $1 ORDER BY risk_rule_id LIMIT $2"
+
+	rows, err := s.db.Query(query, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#ListRiskRules: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	rules := []model.RiskRule{}
+
+	for rows.Next() {
+		rule, err := scanRiskRule(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("RiskRepositoryPostgres#ListRiskRules: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return rules, nil
+}
+
+func (s *RiskRepositoryPostgres) DeleteRiskRule(ctx context.Context, riskRuleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DeleteRiskRule: Beginning transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM risk_rules WHERE risk_rule_id=$1 RETURNING " + riskRuleColumns
+
+	deleted, err := scanRiskRule(tx.QueryRow(query, riskRuleId))
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DeleteRiskRule: Database query (%s) failed: %s", query, err)
+
+		return translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_RISK_RULE, riskRuleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DeleteRiskRule: Appending to the audit log failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositoryPostgres#DeleteRiskRule: Committing transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
+
+// DecideRisk locks the account for the length of the decision, so the
+// decisions on it are made one at a time. The counters are only moved by
+// the transactions once posted, see countRiskPostgres.
+func (s *RiskRepositoryPostgres) DecideRisk(ctx context.Context, decision model.RiskDecision, decide repository.RiskDecider) (*model.RiskDecision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DecideRisk: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	decision.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	counters, err := readRiskCountersPostgres(tx, decision)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("RiskRepositoryPostgres#DecideRisk: No account found for ID %d", decision.AccountId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DecideRisk: Reading the counters failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if decision.Outcome, decision.Rules, err = decide(decision, *counters); err != nil {
+		return nil, err
+	}
+
+	rules, err := json.Marshal(decision.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO risk_decisions (account_id, operation_type_id, amount, merchant_country, outcome, rules, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + riskDecisionColumns
+
+	recorded, err := scanRiskDecision(tx.QueryRow(query, decision.AccountId, decision.OperationTypeId, decision.Amount, decision.MerchantCountry, decision.Outcome, string(rules), decision.CreatedAt))
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#DecideRisk: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositoryPostgres#DecideRisk: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return recorded, nil
+}
+
+// countRiskPostgres adds the transactions to the counters of their
+// accounts in tx, the one posting them, so the transactions that fail to
+// post or are rolled back along with their batch count nothing.
+func countRiskPostgres(tx *sql.Tx, transactions []model.Transaction) error {
+	query := `INSERT INTO risk_counters (account_id, period, period_start, transactions, spent) VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (account_id, period) DO UPDATE SET
+		transactions = CASE WHEN risk_counters.period_start = EXCLUDED.period_start THEN risk_counters.transactions + 1 ELSE 1 END,
+		spent = CASE WHEN risk_counters.period_start = EXCLUDED.period_start THEN risk_counters.spent + EXCLUDED.spent ELSE EXCLUDED.spent END,
+		period_start = EXCLUDED.period_start`
+
+	for _, transaction := range transactions {
+		for _, counter := range riskCountersFor(transaction) {
+			if _, err := tx.Exec(query, transaction.AccountId, counter.period, counter.periodStart, counter.spent); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readRiskCountersPostgres locks the account of decision and reads its
+// counters in tx. It returns sql.ErrNoRows when the account does not exist.
+func readRiskCountersPostgres(tx *sql.Tx, decision model.RiskDecision) (*model.RiskCounters, error) {
+	if err := tx.QueryRow("SELECT account_id FROM accounts WHERE account_id=$1 FOR UPDATE", decision.AccountId).Scan(new(uint64)); err != nil {
+		return nil, err
+	}
+
+	var openedAt time.Time
+
+	err := tx.QueryRow("SELECT created_at FROM events WHERE account_id=$1 AND event_type=$2", decision.AccountId, model.EVENT_ACCOUNT_OPENED).Scan(&openedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastCountry string
+
+	err = tx.QueryRow(`SELECT merchant_country FROM risk_decisions WHERE account_id=$1 AND outcome <> $2 AND merchant_country <> ''
+		ORDER BY risk_decision_id DESC LIMIT 1`, decision.AccountId, model.RISK_OUTCOME_DECLINE).Scan(&lastCountry)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT period, period_start, transactions, spent FROM risk_counters WHERE account_id=$1", decision.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var counters []riskCounter
+
+	for rows.Next() {
+		counter := riskCounter{}
+
+		if err := rows.Scan(&counter.period, &counter.periodStart, &counter.transactions, &counter.spent); err != nil {
+			return nil, err
+		}
+
+		counters = append(counters, counter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := riskCountersOf(decision, counters, openedAt.UTC(), lastCountry)
+
+	return &result, nil
+}
+
+func (s *RiskRepositoryPostgres) ListRiskDecisions(filter repository.RiskDecisionFilter, page repository.Page) ([]model.RiskDecision, error) {
+	query := `SELECT ` + riskDecisionColumns + ` FROM risk_decisions WHERE risk_decision_id > $1
+		AND ($2 = 0 OR account_id = $2) AND ($3 = '' OR outcome = $3) ORDER BY risk_decision_id LIMIT $4`
+
+	rows, err := s.db.Query(query, page.AfterId, filter.AccountId, filter.Outcome, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("RiskRepositoryPostgres#ListRiskDecisions: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	decisions := []model.RiskDecision{}
+
+	for rows.Next() {
+		decision, err := scanRiskDecision(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		decisions = append(decisions, *decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("RiskRepositoryPostgres#ListRiskDecisions: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return decisions, nil
+}
@@ -0,0 +1,347 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type RiskRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewRiskRepositorySQLite(db *sql.DB) *RiskRepositorySQLite {
+	return &RiskRepositorySQLite{
+		db: db,
+	}
+}
+
+func scanRiskRuleSQLite(row interface{ Scan(...interface{}) error }) (*model.RiskRule, error) {
+	rule := model.RiskRule{}
+
+	var createdAt string
+
+	err := row.Scan(&rule.RiskRuleId, &rule.Name, &rule.Expression, &rule.Action, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func scanRiskDecisionSQLite(row interface{ Scan(...interface{}) error }) (*model.RiskDecision, error) {
+	decision := model.RiskDecision{}
+
+	var rules, createdAt string
+
+	err := row.Scan(&decision.RiskDecisionId, &decision.AccountId, &decision.OperationTypeId, &decision.Amount, &decision.MerchantCountry, &decision.Outcome, &rules, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(rules), &decision.Rules); err != nil {
+		return nil, err
+	}
+
+	if decision.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &decision, nil
+}
+
+func (s *RiskRepositorySQLite) CreateRiskRule(ctx context.Context, rule model.RiskRule) (*model.RiskRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#CreateRiskRule: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "INSERT INTO risk_rules (name, expression, action, created_at) VALUES (?1, ?2, ?3, ?4) RETURNING " + riskRuleColumns
+
+	created, err := scanRiskRuleSQLite(tx.QueryRow(query, rule.Name, rule.Expression, rule.Action, sqliteTime(time.Now())))
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#CreateRiskRule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_RISK_RULE, created.RiskRuleId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#CreateRiskRule: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositorySQLite#CreateRiskRule: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (s *RiskRepositorySQLite) ListRiskRules(page repository.Page) ([]model.RiskRule, error) {
+	query := "SELECT " + riskRuleColumns + " FROM risk_rules WHERE risk_rule_id > ?1 ORDER BY risk_rule_id LIMIT ?2"
+
+	rows, err := s.db.Query(query, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#ListRiskRules: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	rules := []model.RiskRule{}
+
+	for rows.Next() {
+		rule, err := scanRiskRuleSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("RiskRepositorySQLite#ListRiskRules: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return rules, nil
+}
+
+func (s *RiskRepositorySQLite) DeleteRiskRule(ctx context.Context, riskRuleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DeleteRiskRule: Beginning transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM risk_rules WHERE risk_rule_id=?1 RETURNING " + riskRuleColumns
+
+	deleted, err := scanRiskRuleSQLite(tx.QueryRow(query, riskRuleId))
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DeleteRiskRule: Database query (%s) failed: %s", query, err)
+
+		return translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_RISK_RULE, riskRuleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DeleteRiskRule: Appending to the audit log failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositorySQLite#DeleteRiskRule: Committing transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
+
+// DecideRisk makes the decisions one at a time, as SQLite runs its
+// transactions alone. The counters are only moved by the transactions once
+// posted, see countRiskSQLite.
+func (s *RiskRepositorySQLite) DecideRisk(ctx context.Context, decision model.RiskDecision, decide repository.RiskDecider) (*model.RiskDecision, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DecideRisk: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	decision.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	counters, err := readRiskCountersSQLite(tx, decision)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("RiskRepositorySQLite#DecideRisk: No account found for ID %d", decision.AccountId)
+
+		return nil, repository.ErrForeignKeyViolation
+	}
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DecideRisk: Reading the counters failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if decision.Outcome, decision.Rules, err = decide(decision, *counters); err != nil {
+		return nil, err
+	}
+
+	rules, err := json.Marshal(decision.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `INSERT INTO risk_decisions (account_id, operation_type_id, amount, merchant_country, outcome, rules, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7) RETURNING ` + riskDecisionColumns
+
+	recorded, err := scanRiskDecisionSQLite(tx.QueryRow(query, decision.AccountId, decision.OperationTypeId, decision.Amount, decision.MerchantCountry, decision.Outcome, string(rules), sqliteTime(decision.CreatedAt)))
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#DecideRisk: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("RiskRepositorySQLite#DecideRisk: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return recorded, nil
+}
+
+// countRiskSQLite adds the transactions to the counters of their accounts
+// in tx, the one posting them, so the transactions that fail to post or are
+// rolled back along with their batch count nothing.
+func countRiskSQLite(tx *sql.Tx, transactions []model.Transaction) error {
+	query := `INSERT INTO risk_counters (account_id, period, period_start, transactions, spent) VALUES (?1, ?2, ?3, 1, ?4)
+		ON CONFLICT (account_id, period) DO UPDATE SET
+		transactions = CASE WHEN risk_counters.period_start = excluded.period_start THEN risk_counters.transactions + 1 ELSE 1 END,
+		spent = CASE WHEN risk_counters.period_start = excluded.period_start THEN risk_counters.spent + excluded.spent ELSE excluded.spent END,
+		period_start = excluded.period_start`
+
+	for _, transaction := range transactions {
+		for _, counter := range riskCountersFor(transaction) {
+			if _, err := tx.Exec(query, transaction.AccountId, counter.period, sqliteTime(counter.periodStart), counter.spent); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readRiskCountersSQLite reads the counters of the account of decision in
+// tx. It returns sql.ErrNoRows when the account does not exist.
+func readRiskCountersSQLite(tx *sql.Tx, decision model.RiskDecision) (*model.RiskCounters, error) {
+	var openedAt string
+
+	err := tx.QueryRow("SELECT created_at FROM events WHERE account_id=?1 AND event_type=?2", decision.AccountId, model.EVENT_ACCOUNT_OPENED).Scan(&openedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	opened, err := time.ParseInLocation(sqliteTimeLayout, openedAt, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastCountry string
+
+	err = tx.QueryRow(`SELECT merchant_country FROM risk_decisions WHERE account_id=?1 AND outcome <> ?2 AND merchant_country <> ''
+		ORDER BY risk_decision_id DESC LIMIT 1`, decision.AccountId, model.RISK_OUTCOME_DECLINE).Scan(&lastCountry)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT period, period_start, transactions, spent FROM risk_counters WHERE account_id=?1", decision.AccountId)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var counters []riskCounter
+
+	for rows.Next() {
+		counter := riskCounter{}
+
+		var periodStart string
+
+		if err := rows.Scan(&counter.period, &periodStart, &counter.transactions, &counter.spent); err != nil {
+			return nil, err
+		}
+
+		if counter.periodStart, err = time.ParseInLocation(sqliteTimeLayout, periodStart, time.UTC); err != nil {
+			return nil, err
+		}
+
+		counters = append(counters, counter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := riskCountersOf(decision, counters, opened, lastCountry)
+
+	return &result, nil
+}
+
+func (s *RiskRepositorySQLite) ListRiskDecisions(filter repository.RiskDecisionFilter, page repository.Page) ([]model.RiskDecision, error) {
+	query := `SELECT ` + riskDecisionColumns + ` FROM risk_decisions WHERE risk_decision_id > ?1
+		AND (?2 = 0 OR account_id = ?2) AND (?3 = '' OR outcome = ?3) ORDER BY risk_decision_id LIMIT ?4`
+
+	rows, err := s.db.Query(query, page.AfterId, filter.AccountId, filter.Outcome, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("RiskRepositorySQLite#ListRiskDecisions: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	decisions := []model.RiskDecision{}
+
+	for rows.Next() {
+		decision, err := scanRiskDecisionSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		decisions = append(decisions, *decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("RiskRepositorySQLite#ListRiskDecisions: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return decisions, nil
+}
@@ -0,0 +1,271 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const scheduleColumns = "schedule_id, account_id, operation_type_id, amount, recurrence, start_date, end_date, next_run_at"
+
+type ScheduleRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewScheduleRepositoryPostgres(db *sql.DB) *ScheduleRepositoryPostgres {
+	return &ScheduleRepositoryPostgres{
+		db: db,
+	}
+}
+
+func scanSchedule(row interface{ Scan(...interface{}) error }) (*model.Schedule, error) {
+	schedule := model.Schedule{}
+
+	var endDate, nextRunAt sql.NullTime
+
+	err := row.Scan(&schedule.ScheduleId, &schedule.AccountId, &schedule.OperationTypeId, &schedule.Amount, &schedule.Recurrence, &schedule.StartDate, &endDate, &nextRunAt)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.StartDate = schedule.StartDate.UTC()
+	schedule.EndDate = timePointer(endDate)
+	schedule.NextRunAt = timePointer(nextRunAt)
+
+	return &schedule, nil
+}
+
+// nullTimePointer stores a nil time as NULL.
+func nullTimePointer(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return nullTime(*t)
+}
+
+func (s *ScheduleRepositoryPostgres) CreateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#CreateSchedule: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `INSERT INTO schedules (account_id, operation_type_id, amount, recurrence, start_date, end_date, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + scheduleColumns
+
+	created, err := scanSchedule(tx.QueryRow(query, schedule.AccountId, schedule.OperationTypeId, schedule.Amount, schedule.Recurrence, schedule.StartDate.UTC(), nullTimePointer(schedule.EndDate), nullTimePointer(schedule.NextRunAt)))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#CreateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SCHEDULE, created.ScheduleId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#CreateSchedule: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositoryPostgres#CreateSchedule: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (s *ScheduleRepositoryPostgres) FindSchedule(scheduleId uint64) (*model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id=$1"
+
+	schedule, err := scanSchedule(s.db.QueryRow(query, scheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#FindSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduleRepositoryPostgres) ListSchedules(filter repository.ScheduleFilter, page repository.Page) ([]model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id > $1 AND ($2 = 0 OR account_id = $2) ORDER BY schedule_id LIMIT $3"
+
+	return s.listSchedules("ListSchedules", query, page.AfterId, filter.AccountId, page.EffectiveLimit())
+}
+
+func (s *ScheduleRepositoryPostgres) ListDueSchedules(now time.Time, limit int) ([]model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE next_run_at <= $1 ORDER BY next_run_at, schedule_id LIMIT $2"
+
+	return s.listSchedules("ListDueSchedules", query, now.UTC(), limit)
+}
+
+func (s *ScheduleRepositoryPostgres) listSchedules(method string, query string, args ...interface{}) ([]model.Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		schedules = append(schedules, *schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ScheduleRepositoryPostgres#%s: Reading rows failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return schedules, nil
+}
+
+func (s *ScheduleRepositoryPostgres) UpdateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#UpdateSchedule: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id=$1 FOR UPDATE"
+
+	before, err := scanSchedule(tx.QueryRow(query, schedule.ScheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#UpdateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	query = `UPDATE schedules SET account_id=$2, operation_type_id=$3, amount=$4, recurrence=$5, start_date=$6, end_date=$7, next_run_at=$8
+		WHERE schedule_id=$1 RETURNING ` + scheduleColumns
+
+	updated, err := scanSchedule(tx.QueryRow(query, schedule.ScheduleId, schedule.AccountId, schedule.OperationTypeId, schedule.Amount, schedule.Recurrence, schedule.StartDate.UTC(), nullTimePointer(schedule.EndDate), nullTimePointer(schedule.NextRunAt)))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#UpdateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_SCHEDULE, updated.ScheduleId, before, updated)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#UpdateSchedule: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositoryPostgres#UpdateSchedule: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return updated, nil
+}
+
+func (s *ScheduleRepositoryPostgres) DeleteSchedule(ctx context.Context, scheduleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#DeleteSchedule: Beginning transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM schedules WHERE schedule_id=$1 RETURNING " + scheduleColumns
+
+	deleted, err := scanSchedule(tx.QueryRow(query, scheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#DeleteSchedule: Database query (%s) failed: %s", query, err)
+
+		return translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SCHEDULE, scheduleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#DeleteSchedule: Appending to the audit log failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositoryPostgres#DeleteSchedule: Committing transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
+
+func (s *ScheduleRepositoryPostgres) AdvanceSchedule(scheduleId uint64, from time.Time, next *time.Time) error {
+	query := "UPDATE schedules SET next_run_at=$3 WHERE schedule_id=$1 AND next_run_at=$2"
+
+	result, err := s.db.Exec(query, scheduleId, from.UTC(), nullTimePointer(next))
+
+	if err != nil {
+		log.Printf("ScheduleRepositoryPostgres#AdvanceSchedule: Database query (%s) failed: %s", query, err)
+
+		return translatePostgresError(err)
+	}
+
+	return scheduleAdvanced(result)
+}
+
+// scheduleAdvanced returns ErrConflict when the update advancing a schedule
+// found its next run moved.
+func scheduleAdvanced(result sql.Result) error {
+	advanced, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if advanced == 0 {
+		return repository.ErrConflict
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type ScheduleRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewScheduleRepositorySQLite(db *sql.DB) *ScheduleRepositorySQLite {
+	return &ScheduleRepositorySQLite{
+		db: db,
+	}
+}
+
+func scanScheduleSQLite(row interface{ Scan(...interface{}) error }) (*model.Schedule, error) {
+	schedule := model.Schedule{}
+
+	var startDate string
+	var endDate, nextRunAt sql.NullString
+
+	err := row.Scan(&schedule.ScheduleId, &schedule.AccountId, &schedule.OperationTypeId, &schedule.Amount, &schedule.Recurrence, &startDate, &endDate, &nextRunAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if schedule.StartDate, err = time.ParseInLocation(sqliteTimeLayout, startDate, time.UTC); err != nil {
+		return nil, err
+	}
+
+	if schedule.EndDate, err = parseSQLiteTime(endDate); err != nil {
+		return nil, err
+	}
+
+	if schedule.NextRunAt, err = parseSQLiteTime(nextRunAt); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// sqliteTimePointer stores a nil time as NULL.
+func sqliteTimePointer(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+
+	return sqliteTime(*t)
+}
+
+func (s *ScheduleRepositorySQLite) CreateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#CreateSchedule: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `INSERT INTO schedules (account_id, operation_type_id, amount, recurrence, start_date, end_date, next_run_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7) RETURNING ` + scheduleColumns
+
+	created, err := scanScheduleSQLite(tx.QueryRow(query, schedule.AccountId, schedule.OperationTypeId, schedule.Amount, schedule.Recurrence, sqliteTime(schedule.StartDate), sqliteTimePointer(schedule.EndDate), sqliteTimePointer(schedule.NextRunAt)))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#CreateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SCHEDULE, created.ScheduleId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#CreateSchedule: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositorySQLite#CreateSchedule: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (s *ScheduleRepositorySQLite) FindSchedule(scheduleId uint64) (*model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id=?"
+
+	schedule, err := scanScheduleSQLite(s.db.QueryRow(query, scheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#FindSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return schedule, nil
+}
+
+func (s *ScheduleRepositorySQLite) ListSchedules(filter repository.ScheduleFilter, page repository.Page) ([]model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id > ?1 AND (?2 = 0 OR account_id = ?2) ORDER BY schedule_id LIMIT ?3"
+
+	return s.listSchedules("ListSchedules", query, page.AfterId, filter.AccountId, page.EffectiveLimit())
+}
+
+func (s *ScheduleRepositorySQLite) ListDueSchedules(now time.Time, limit int) ([]model.Schedule, error) {
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE next_run_at <= ?1 ORDER BY next_run_at, schedule_id LIMIT ?2"
+
+	return s.listSchedules("ListDueSchedules", query, sqliteTime(now), limit)
+}
+
+func (s *ScheduleRepositorySQLite) listSchedules(method string, query string, args ...interface{}) ([]model.Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#%s: Database query (%s) failed: %s", method, query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	schedules := []model.Schedule{}
+
+	for rows.Next() {
+		schedule, err := scanScheduleSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		schedules = append(schedules, *schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("ScheduleRepositorySQLite#%s: Reading rows failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return schedules, nil
+}
+
+func (s *ScheduleRepositorySQLite) UpdateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#UpdateSchedule: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + scheduleColumns + " FROM schedules WHERE schedule_id=?"
+
+	before, err := scanScheduleSQLite(tx.QueryRow(query, schedule.ScheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#UpdateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	query = `UPDATE schedules SET account_id=?2, operation_type_id=?3, amount=?4, recurrence=?5, start_date=?6, end_date=?7, next_run_at=?8
+		WHERE schedule_id=?1 RETURNING ` + scheduleColumns
+
+	updated, err := scanScheduleSQLite(tx.QueryRow(query, schedule.ScheduleId, schedule.AccountId, schedule.OperationTypeId, schedule.Amount, schedule.Recurrence, sqliteTime(schedule.StartDate), sqliteTimePointer(schedule.EndDate), sqliteTimePointer(schedule.NextRunAt)))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#UpdateSchedule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_SCHEDULE, updated.ScheduleId, before, updated)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#UpdateSchedule: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositorySQLite#UpdateSchedule: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return updated, nil
+}
+
+func (s *ScheduleRepositorySQLite) DeleteSchedule(ctx context.Context, scheduleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#DeleteSchedule: Beginning transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM schedules WHERE schedule_id=?1 RETURNING " + scheduleColumns
+
+	deleted, err := scanScheduleSQLite(tx.QueryRow(query, scheduleId))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#DeleteSchedule: Database query (%s) failed: %s", query, err)
+
+		return translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SCHEDULE, scheduleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#DeleteSchedule: Appending to the audit log failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("ScheduleRepositorySQLite#DeleteSchedule: Committing transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
+
+func (s *ScheduleRepositorySQLite) AdvanceSchedule(scheduleId uint64, from time.Time, next *time.Time) error {
+	query := "UPDATE schedules SET next_run_at=?3 WHERE schedule_id=?1 AND next_run_at=?2"
+
+	result, err := s.db.Exec(query, scheduleId, sqliteTime(from), sqliteTimePointer(next))
+
+	if err != nil {
+		log.Printf("ScheduleRepositorySQLite#AdvanceSchedule: Database query (%s) failed: %s", query, err)
+
+		return translateSQLiteError(err)
+	}
+
+	return scheduleAdvanced(result)
+}
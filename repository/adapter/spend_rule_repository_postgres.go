@@ -0,0 +1,197 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/merchantcontrol"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+const spendRuleColumns = "spend_rule_id, account_id, type, mcc, category, amount_limit, period, created_at"
+
+type SpendRuleRepositoryPostgres struct {
+	db *sql.DB
+}
+
+func NewSpendRuleRepositoryPostgres(db *sql.DB) *SpendRuleRepositoryPostgres {
+	return &SpendRuleRepositoryPostgres{
+		db: db,
+	}
+}
+
+func scanSpendRule(row interface{ Scan(...interface{}) error }) (*model.SpendRule, error) {
+	rule := model.SpendRule{}
+
+	err := row.Scan(&rule.SpendRuleId, &rule.AccountId, &rule.Type, &rule.Mcc, &rule.Category, &rule.Limit, &rule.Period, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.CreatedAt = rule.CreatedAt.UTC()
+
+	return &rule, nil
+}
+
+func (s *SpendRuleRepositoryPostgres) CreateSpendRule(ctx context.Context, rule model.SpendRule) (*model.SpendRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#CreateSpendRule: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `INSERT INTO spend_rules (account_id, type, mcc, category, amount_limit, period, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + spendRuleColumns
+
+	created, err := scanSpendRule(tx.QueryRow(query, rule.AccountId, rule.Type, rule.Mcc, rule.Category, rule.Limit, rule.Period, time.Now().UTC().Truncate(time.Millisecond)))
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#CreateSpendRule: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SPEND_RULE, created.SpendRuleId, nil, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#CreateSpendRule: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#CreateSpendRule: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return created, nil
+}
+
+func (s *SpendRuleRepositoryPostgres) ListSpendRules(accountId uint64, page repository.Page) ([]model.SpendRule, error) {
+	query := "SELECT " + spendRuleColumns + " FROM spend_rules WHERE account_id=$1 AND spend_rule_id > $2 ORDER BY spend_rule_id LIMIT $3"
+
+	rows, err := s.db.Query(query, accountId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#ListSpendRules: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	rules, err := scanSpendRules(rows)
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#ListSpendRules: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return rules, nil
+}
+
+func scanSpendRules(rows *sql.Rows) ([]model.SpendRule, error) {
+	defer rows.Close()
+
+	rules := []model.SpendRule{}
+
+	for rows.Next() {
+		rule, err := scanSpendRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *SpendRuleRepositoryPostgres) DeleteSpendRule(ctx context.Context, spendRuleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#DeleteSpendRule: Beginning transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM spend_rules WHERE spend_rule_id=$1 RETURNING " + spendRuleColumns
+
+	deleted, err := scanSpendRule(tx.QueryRow(query, spendRuleId))
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#DeleteSpendRule: Database query (%s) failed: %s", query, err)
+
+		return translatePostgresError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SPEND_RULE, spendRuleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#DeleteSpendRule: Appending to the audit log failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("SpendRuleRepositoryPostgres#DeleteSpendRule: Committing transaction failed: %s", err)
+
+		return translatePostgresError(err)
+	}
+
+	return nil
+}
+
+// checkSpendRulesPostgres applies the spend rules of the accounts to the
+// transactions, read in tx. The streams of the accounts are locked, so their
+// spending cannot change until tx ends.
+func checkSpendRulesPostgres(tx *sql.Tx, transactions []model.Transaction) error {
+	findRules := func(accountId uint64) ([]model.SpendRule, error) {
+		rows, err := tx.Query("SELECT "+spendRuleColumns+" FROM spend_rules WHERE account_id=$1 ORDER BY spend_rule_id", accountId)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		rules, err := scanSpendRules(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		return rules, nil
+	}
+
+	return merchantcontrol.CheckTransactions(transactions, findRules, func(accountId uint64, category string, from time.Time, to time.Time) (model.Amount, error) {
+		var spent model.Amount
+
+		err := tx.QueryRow(categorySpendQuery, accountId, model.EVENT_TRANSACTION_POSTED, category, from.Format(time.DateOnly), to.Format(time.DateOnly), model.EVENT_TRANSACTION_REVERSED).Scan(&spent)
+
+		return spent, err
+	})
+}
+
+// categorySpendQuery sums the purchases and withdraws of an account in a
+// category over a period of whole days, in UTC, that were not reversed.
+// They are read from the events, which the projection may not have applied
+// yet.
+const categorySpendQuery = `SELECT COALESCE(SUM(-(p.data->>'amount')::NUMERIC), 0) FROM events p
+	WHERE p.account_id = $1 AND p.event_type = $2 AND p.data->>'category' = $3 AND (p.data->>'amount')::NUMERIC < 0
+	AND ((p.data->>'event_date')::TIMESTAMPTZ AT TIME ZONE 'UTC')::DATE >= $4::DATE
+	AND ((p.data->>'event_date')::TIMESTAMPTZ AT TIME ZONE 'UTC')::DATE < $5::DATE
+	AND NOT EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = $6)`
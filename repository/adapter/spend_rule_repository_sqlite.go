@@ -0,0 +1,193 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/merchantcontrol"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type SpendRuleRepositorySQLite struct {
+	db *sql.DB
+}
+
+func NewSpendRuleRepositorySQLite(db *sql.DB) *SpendRuleRepositorySQLite {
+	return &SpendRuleRepositorySQLite{
+		db: db,
+	}
+}
+
+func scanSpendRuleSQLite(row interface{ Scan(...interface{}) error }) (*model.SpendRule, error) {
+	rule := model.SpendRule{}
+
+	var createdAt string
+
+	err := row.Scan(&rule.SpendRuleId, &rule.AccountId, &rule.Type, &rule.Mcc, &rule.Category, &rule.Limit, &rule.Period, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rule.CreatedAt, err = time.ParseInLocation(sqliteTimeLayout, createdAt, time.UTC); err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func scanSpendRulesSQLite(rows *sql.Rows) ([]model.SpendRule, error) {
+	defer rows.Close()
+
+	rules := []model.SpendRule{}
+
+	for rows.Next() {
+		rule, err := scanSpendRuleSQLite(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *SpendRuleRepositorySQLite) CreateSpendRule(ctx context.Context, rule model.SpendRule) (*model.SpendRule, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#CreateSpendRule: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := `INSERT INTO spend_rules (account_id, type, mcc, category, amount_limit, period, created_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7) RETURNING ` + spendRuleColumns
+
+	created, err := scanSpendRuleSQLite(tx.QueryRow(query, rule.AccountId, rule.Type, rule.Mcc, rule.Category, rule.Limit, rule.Period, sqliteTime(time.Now())))
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#CreateSpendRule: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_SPEND_RULE, created.SpendRuleId, nil, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#CreateSpendRule: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("SpendRuleRepositorySQLite#CreateSpendRule: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return created, nil
+}
+
+func (s *SpendRuleRepositorySQLite) ListSpendRules(accountId uint64, page repository.Page) ([]model.SpendRule, error) {
+	query := "SELECT " + spendRuleColumns + " FROM spend_rules WHERE account_id=?1 AND spend_rule_id > ?2 ORDER BY spend_rule_id LIMIT ?3"
+
+	rows, err := s.db.Query(query, accountId, page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#ListSpendRules: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	rules, err := scanSpendRulesSQLite(rows)
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#ListSpendRules: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return rules, nil
+}
+
+func (s *SpendRuleRepositorySQLite) DeleteSpendRule(ctx context.Context, spendRuleId uint64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#DeleteSpendRule: Beginning transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	query := "DELETE FROM spend_rules WHERE spend_rule_id=?1 RETURNING " + spendRuleColumns
+
+	deleted, err := scanSpendRuleSQLite(tx.QueryRow(query, spendRuleId))
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#DeleteSpendRule: Database query (%s) failed: %s", query, err)
+
+		return translateSQLiteError(err)
+	}
+
+	entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_DELETE, model.AUDIT_ENTITY_SPEND_RULE, spendRuleId, deleted, nil)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("SpendRuleRepositorySQLite#DeleteSpendRule: Appending to the audit log failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("SpendRuleRepositorySQLite#DeleteSpendRule: Committing transaction failed: %s", err)
+
+		return translateSQLiteError(err)
+	}
+
+	return nil
+}
+
+// checkSpendRulesSQLite applies the spend rules of the accounts to the
+// transactions, read in tx, which SQLite runs alone.
+func checkSpendRulesSQLite(tx *sql.Tx, transactions []model.Transaction) error {
+	findRules := func(accountId uint64) ([]model.SpendRule, error) {
+		rows, err := tx.Query("SELECT "+spendRuleColumns+" FROM spend_rules WHERE account_id=?1 ORDER BY spend_rule_id", accountId)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		rules, err := scanSpendRulesSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		return rules, nil
+	}
+
+	return merchantcontrol.CheckTransactions(transactions, findRules, func(accountId uint64, category string, from time.Time, to time.Time) (model.Amount, error) {
+		query := `SELECT COALESCE(SUM(-json_extract(p.data, '$.amount')), 0) FROM events p
+			WHERE p.account_id = ?1 AND p.event_type = ?2 AND json_extract(p.data, '$.category') = ?3 AND json_extract(p.data, '$.amount') < 0
+			AND date(json_extract(p.data, '$.event_date')) >= ?4 AND date(json_extract(p.data, '$.event_date')) < ?5
+			AND NOT EXISTS (SELECT 1 FROM events r WHERE r.transaction_id = p.transaction_id AND r.event_type = ?6)`
+
+		var spent model.Amount
+
+		err := tx.QueryRow(query, accountId, model.EVENT_TRANSACTION_POSTED, category, from.Format(time.DateOnly), to.Format(time.DateOnly), model.EVENT_TRANSACTION_REVERSED).Scan(&spent)
+
+		return spent, err
+	})
+}
@@ -0,0 +1,164 @@
+package adapter
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/db"
+	"github.com/felipedsi/pismo-test/model"
+)
+
+func init() {
+	// The migrations rewriting the audit log hash its entries again with
+	// audit_hash(prev_hash, action, entity_type, entity_id, actor,
+	// client_ip, request_id, snapshot_before, snapshot_after, created_at).
+	sqlite.MustRegisterDeterministicScalarFunction("audit_hash", 10, sqliteAuditHash)
+}
+
+func sqliteAuditHash(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	fields := make([]string, len(args))
+
+	for n, arg := range args {
+		switch value := arg.(type) {
+		case string:
+			fields[n] = value
+		case []byte:
+			fields[n] = string(value)
+		case int64:
+			fields[n] = strconv.FormatInt(value, 10)
+		default:
+			return nil, fmt.Errorf("audit_hash: unexpected argument %d of type %T", n+1, arg)
+		}
+	}
+
+	entityId, err := strconv.ParseUint(fields[3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.ParseInLocation(sqliteTimeLayout, fields[9], time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Hash(model.AuditEntry{
+		PrevHash:   fields[0],
+		Action:     fields[1],
+		EntityType: fields[2],
+		EntityId:   entityId,
+		Actor:      fields[4],
+		ClientIP:   fields[5],
+		RequestId:  fields[6],
+		Before:     []byte(fields[7]),
+		After:      []byte(fields[8]),
+		CreatedAt:  createdAt,
+	}), nil
+}
+
+// OpenSQLite opens the database file at path in WAL mode with foreign keys
+// enforced and applies any pending migration. Transactions take the write
+// lock as they begin, so what they read cannot change before they commit.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate",
+		path,
+	)
+
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrateSQLite(conn)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func migrateSQLite(conn *sql.DB) error {
+	return migrateSQLiteTo(conn, -1)
+}
+
+// migrateSQLiteTo applies the pending migrations up to the version last,
+// all of them when it is negative.
+func migrateSQLiteTo(conn *sql.DB, last int) error {
+	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS "schema_migrations" ("version" INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(db.SQLiteMigrations, "migrations/sqlite/*.up.sql")
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := file[strings.LastIndex(file, "/")+1:]
+
+		version, err := strconv.Atoi(name[:strings.Index(name, "_")])
+		if err != nil {
+			return fmt.Errorf("invalid migration file name %s: %w", name, err)
+		}
+
+		if last >= 0 && version > last {
+			break
+		}
+
+		var applied int
+
+		err = conn.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE version=?`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			continue
+		}
+
+		content, err := fs.ReadFile(db.SQLiteMigrations, file)
+		if err != nil {
+			return err
+		}
+
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(string(content))
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version)
+		}
+
+		if err != nil {
+			tx.Rollback()
+
+			return fmt.Errorf("migration %s failed: %w", name, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Applied SQLite migration %s", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,365 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/pii"
+	"github.com/felipedsi/pismo-test/pii/piitest"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/repositorytest"
+)
+
+// newTestCipher returns a cipher whose master keys are kept in memory.
+func newTestCipher(t *testing.T) pii.Cipher {
+	return pii.NewEnvelopeCipher(piitest.NewKMS(), nil)
+}
+
+// newTestIndex returns a blind index with an all zeros key.
+func newTestIndex(t *testing.T) *pii.BlindIndex {
+	index, err := pii.NewBlindIndex(make([]byte, pii.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return index
+}
+
+func TestSQLiteRepositoryContract(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		db, err := OpenSQLite(filepath.Join(t.TempDir(), "pismo.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { db.Close() })
+
+		return repositorytest.Repositories{
+			Accounts:       NewAccountRepositorySQLite(db, newTestCipher(t), newTestIndex(t)),
+			Transactions:   NewTransactionRepositorySQLite(db),
+			OperationTypes: NewOperationTypeRepositorySQLite(db),
+			Imports:        NewImportRepositorySQLite(db),
+			Exports:        NewExportRepositorySQLite(db),
+			Audit:          NewAuditRepositorySQLite(db),
+			Events:         NewEventRepositorySQLite(db),
+			Projections:    NewProjectionRepositorySQLite(db),
+			Schedules:      NewScheduleRepositorySQLite(db),
+			FxRates:        NewFxRateRepositorySQLite(db),
+			Cards:          NewCardRepositorySQLite(db),
+			SpendRules:     NewSpendRuleRepositorySQLite(db),
+			Risk:           NewRiskRepositorySQLite(db),
+			Disputes:       NewDisputeRepositorySQLite(db),
+			Holders:        NewHolderRepositorySQLite(db, newTestCipher(t)),
+		}
+	})
+}
+
+func TestOpenSQLiteIsIdempotent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pismo.db")
+
+	for i := 0; i < 2; i++ {
+		db, err := OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("Expected migrations to apply cleanly on open #%d but got %s", i+1, err)
+		}
+
+		var journalMode string
+
+		err = db.QueryRow("PRAGMA journal_mode").Scan(&journalMode)
+		if err != nil || journalMode != "wal" {
+			t.Errorf("Expected journal_mode to be wal but got %q (%v)", journalMode, err)
+		}
+
+		db.Close()
+	}
+}
+
+func TestHolderRepositorySQLiteEncryptsPersonalData(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "pismo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	holder, err := NewHolderRepositorySQLite(db, newTestCipher(t)).CreateHolder(context.Background(), model.Holder{Name: "Alice", BirthDate: "1990-02-28", Email: "alice@example.com", Phone: "+5511987654321", Address: model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", PostalCode: "01310-100", Country: "BR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var name, birthDate, email, phone, address string
+
+	err = db.QueryRow("SELECT name, birth_date, email, phone, address FROM holders WHERE holder_id=?", holder.HolderId).Scan(&name, &birthDate, &email, &phone, &address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stored := range []string{name, birthDate, email, phone, address} {
+		for _, plaintext := range []string{"Alice", "1990-02-28", "alice@example.com", "+5511987654321", "Paulista"} {
+			if strings.Contains(stored, plaintext) {
+				t.Errorf("Expected %q to be stored encrypted but found it in %q", plaintext, stored)
+			}
+		}
+	}
+}
+
+func TestAccountRepositorySQLiteEncryptsDocumentNumbers(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "pismo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	accounts := NewAccountRepositorySQLite(db, newTestCipher(t), newTestIndex(t))
+
+	account, err := accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 12345678})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var documentNumber uint64
+	var ciphertext, index string
+
+	err = db.QueryRow("SELECT document_number, document_number_ciphertext, document_number_index FROM accounts WHERE account_id=?", account.AccountId).Scan(&documentNumber, &ciphertext, &index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stored := range []string{strconv.FormatUint(documentNumber, 10), ciphertext, index} {
+		if strings.Contains(stored, "12345678") {
+			t.Errorf("Expected the document number to be stored encrypted but found it in %q", stored)
+		}
+	}
+}
+
+func TestSQLiteProjectsTheAccountsFromTheirEvents(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "pismo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	accounts := NewAccountRepositorySQLite(db, newTestCipher(t), newTestIndex(t))
+	projections := NewProjectionRepositorySQLite(db)
+
+	account, err := accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 12345678, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data string
+
+	err = db.QueryRow("SELECT data FROM events WHERE account_id=?", account.AccountId).Scan(&data)
+	if err != nil || strings.Contains(data, "12345678") {
+		t.Errorf("Expected the event to hold the document number sealed but got %q (%v)", data, err)
+	}
+
+	// The accounts lost are added back by a replay.
+	_, err = db.Exec("DELETE FROM account_balances; DELETE FROM accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := projections.ResetProjections(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := projections.ProjectEvents(100); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := accounts.FindAccount(account.AccountId)
+	if err != nil || *found != *account {
+		t.Errorf("Expected the account to be projected again as %v but got %v (%v)", account, found, err)
+	}
+}
+
+func TestAccountRepositorySQLiteReencryptsPersonalData(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "pismo.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	kms := piitest.NewKMS()
+	cipher := pii.NewEnvelopeCipher(kms, nil)
+
+	accounts := NewAccountRepositorySQLite(db, cipher, newTestIndex(t))
+	holders := NewHolderRepositorySQLite(db, cipher)
+
+	_, err = accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holder, err := holders.CreateHolder(context.Background(), model.Holder{Name: "Alice", BirthDate: "1990-02-28", Email: "alice@example.com", Phone: "+5511987654321", Address: model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", PostalCode: "01310-100", Country: "BR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An account stored before the document numbers were encrypted.
+	_, err = db.Exec("INSERT INTO accounts (document_number, currency) VALUES (222, 'BRL')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := accounts.ListAccounts(repository.AccountFilter{DocumentNumber: 222}, repository.Page{})
+	if err != nil || len(legacy) != 1 {
+		t.Fatalf("Expected the plaintext document number to be found but got %v (%v)", legacy, err)
+	}
+
+	kms.Rotate()
+
+	for _, scenario := range []struct {
+		repository  repository.PersonalDataRepository
+		reencrypted int
+	}{
+		{accounts, 2},
+		{holders, 1},
+	} {
+		reencrypted, err := scenario.repository.ReencryptPersonalData(context.Background(), 10)
+		if err != nil || reencrypted != scenario.reencrypted {
+			t.Errorf("Expected %d rows to be re-encrypted but got %d (%v)", scenario.reencrypted, reencrypted, err)
+		}
+
+		reencrypted, err = scenario.repository.ReencryptPersonalData(context.Background(), 10)
+		if err != nil || reencrypted != 0 {
+			t.Errorf("Expected no row left to re-encrypt but got %d (%v)", reencrypted, err)
+		}
+	}
+
+	// The values under the old master key are gone.
+	kms.Retire("1")
+
+	for _, documentNumber := range []uint64{111, 222} {
+		found, err := accounts.ListAccounts(repository.AccountFilter{DocumentNumber: documentNumber}, repository.Page{})
+		if err != nil || len(found) != 1 || found[0].DocumentNumber != documentNumber {
+			t.Errorf("Expected the account with document number %d to be found but got %v (%v)", documentNumber, found, err)
+		}
+	}
+
+	var plaintext uint64
+
+	err = db.QueryRow("SELECT document_number FROM accounts WHERE account_id=2").Scan(&plaintext)
+	if err != nil || plaintext != 0 {
+		t.Errorf("Expected the plaintext document number to be cleared but got %d (%v)", plaintext, err)
+	}
+
+	found, err := holders.FindHolder(holder.HolderId)
+	if err != nil || found.Name != "Alice" {
+		t.Errorf("Expected the holder to be decrypted under the new master key but got %v (%v)", found, err)
+	}
+}
+
+func TestSQLiteScrubsDocumentNumbersRecordedBeforeTheyWereEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pismo.db")
+
+	legacy, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An account opened, and audited, before the document numbers were
+	// encrypted.
+	if err := migrateSQLiteTo(legacy, 17); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = legacy.Exec("INSERT INTO accounts (document_number, currency) VALUES (222, 'BRL')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = legacy.Exec(`INSERT INTO events (account_id, version, event_type, data, created_at)
+		VALUES (1, 1, 'AccountOpened', '{"account_id":1,"document_number":222,"currency":"BRL"}', '2024-01-01 00:00:00.000')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := audit.NewEntry(context.Background(), model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_ACCOUNT, 1, nil, model.Account{AccountId: 1, DocumentNumber: 222, Currency: "BRL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked, err := audit.NewEntry(context.Background(), model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_ACCOUNT, 1, model.Account{AccountId: 1, DocumentNumber: 222, Currency: "BRL"}, model.Account{AccountId: 1, DocumentNumber: 222, Currency: "BRL", Blocked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := legacy.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := appendAuditSQLite(tx, opened, blocked); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var oldHead string
+
+	if err := legacy.QueryRow("SELECT hash FROM audit_log ORDER BY audit_entry_id DESC LIMIT 1").Scan(&oldHead); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy.Close()
+
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var stored int
+
+	err = db.QueryRow(`SELECT (SELECT COUNT(*) FROM events WHERE data LIKE '%222%')
+		+ (SELECT COUNT(*) FROM audit_log WHERE snapshot_before LIKE '%222%' OR snapshot_after LIKE '%222%')`).Scan(&stored)
+	if err != nil || stored != 0 {
+		t.Errorf("Expected no document number to be left in the events and the audit log but got %d rows (%v)", stored, err)
+	}
+
+	entries, err := NewAuditRepositorySQLite(db).ListAuditEntries(repository.AuditFilter{}, repository.Page{})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected the two entries and the reanchor entry but got %v (%v)", entries, err)
+	}
+
+	if _, err := audit.Verify("", entries); err != nil {
+		t.Errorf("Expected the chain to be hashed again but got %v", err)
+	}
+
+	anchor := entries[2]
+
+	if anchor.Action != model.AUDIT_ACTION_REANCHOR || anchor.EntityType != model.AUDIT_ENTITY_AUDIT_LOG || anchor.EntityId != entries[1].AuditEntryId {
+		t.Errorf("Expected a reanchor entry of the audit log but got %+v", anchor)
+	}
+
+	if string(anchor.Before) != `{"hash":"`+oldHead+`"}` || string(anchor.After) != `{"hash":"`+entries[1].Hash+`"}` {
+		t.Errorf("Expected the reanchor entry to record the old and new hashes but got %s and %s", anchor.Before, anchor.After)
+	}
+
+	if _, err := NewAccountRepositorySQLite(db, newTestCipher(t), newTestIndex(t)).BlockAccount(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = NewAuditRepositorySQLite(db).ListAuditEntries(repository.AuditFilter{}, repository.Page{})
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("Expected the blocking to be audited but got %v (%v)", entries, err)
+	}
+
+	if _, err := audit.Verify("", entries); err != nil {
+		t.Errorf("Expected the entries appended after the migration to follow the reanchor entry but got %v", err)
+	}
+}
@@ -1,36 +1,379 @@
 package adapter
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"sort"
 
+	"github.com/lib/pq"
+
+	"github.com/felipedsi/pismo-test/audit"
 	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
 )
 
+const transactionColumns = "transaction_id, account_id, card_id, operation_type_id, amount, currency, original_amount, original_currency, fx_rate, fx_rate_id, merchant_name, merchant_id, mcc, merchant_country, category, reversed, event_date, created_at"
+
 type TransactionRepositoryPostgres struct {
-	db *sql.DB
+	db          *sql.DB
+	projections *ProjectionRepositoryPostgres
 }
 
 func NewTransactionRepositoryPostgres(db *sql.DB) *TransactionRepositoryPostgres {
 	return &TransactionRepositoryPostgres{
-		db: db,
+		db:          db,
+		projections: NewProjectionRepositoryPostgres(db),
+	}
+}
+
+func (t *TransactionRepositoryPostgres) CreateTransaction(ctx context.Context, transaction model.Transaction) (*model.Transaction, error) {
+	created, err := t.postTransactions(ctx, "CreateTransaction", []model.Transaction{transaction})
+	if err != nil {
+		return nil, err
 	}
+
+	return &created[0], nil
 }
 
-func (t *TransactionRepositoryPostgres) CreateTransaction(transaction model.Transaction) (*model.Transaction, error) {
-	query := "INSERT INTO transactions (account_id, operation_type_id, amount) VALUES ($1, $2, $3) RETURNING transaction_id"
+func (t *TransactionRepositoryPostgres) CreateTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error) {
+	if len(transactions) == 0 {
+		return []model.Transaction{}, nil
+	}
 
-	err := t.db.QueryRow(
-		query,
-		transaction.AccountId,
-		transaction.OperationTypeId,
-		transaction.Amount).Scan(&transaction.TransactionId)
+	return t.postTransactions(ctx, "CreateTransactions", transactions)
+}
 
+// postTransactions appends the events posting the transactions along with
+// their audit entries, then projects them.
+func (t *TransactionRepositoryPostgres) postTransactions(ctx context.Context, method string, transactions []model.Transaction) ([]model.Transaction, error) {
+	var created []model.Transaction
+
+	err := retryAppendPostgres(ctx, func() (err error) {
+		created, err = t.appendTransactions(ctx, method, transactions)
+
+		return err
+	})
+
+	return created, err
+}
+
+func (t *TransactionRepositoryPostgres) appendTransactions(ctx context.Context, method string, transactions []model.Transaction) ([]model.Transaction, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Printf("TransactionRepositoryPostgres#CreateTransaction: Database query (%s) failed: %s", query, err)
+		log.Printf("TransactionRepositoryPostgres#%s: Beginning transaction failed: %s", method, err)
 
-		return nil, err
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	created, err := postTransactionsPostgres(ctx, tx, transactions)
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#%s: Appending events failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entries, err := transactionEntries(ctx, created)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entries...)
+	}
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#%s: Appending to the audit log failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("TransactionRepositoryPostgres#%s: Committing transaction failed: %s", method, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	catchUpProjections(t.projections, "TransactionRepositoryPostgres#"+method)
+
+	return created, nil
+}
+
+func (t *TransactionRepositoryPostgres) ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	var transaction *model.Transaction
+
+	err := retryAppendPostgres(ctx, func() (err error) {
+		transaction, err = t.reverseTransaction(ctx, transactionId)
+
+		return err
+	})
+
+	return transaction, err
+}
+
+func (t *TransactionRepositoryPostgres) reverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#ReverseTransaction: Beginning transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer tx.Rollback()
+
+	transaction, err := reverseTransactionPostgres(ctx, tx, transactionId)
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#ReverseTransaction: Appending events failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	entry, err := reversalEntry(ctx, *transaction)
+
+	if err == nil {
+		err = appendAuditPostgres(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#ReverseTransaction: Appending to the audit log failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("TransactionRepositoryPostgres#ReverseTransaction: Committing transaction failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	catchUpProjections(t.projections, "TransactionRepositoryPostgres#ReverseTransaction")
+
+	return transaction, nil
+}
+
+func (t *TransactionRepositoryPostgres) ListTransactions(filter repository.TransactionFilter, page repository.Page) ([]model.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions
+		WHERE transaction_id > $1 AND ($2 = 0 OR account_id = $2) AND ($4 = 0 OR operation_type_id = $4)
+			AND ($5::timestamptz IS NULL OR event_date >= $5) AND ($6::timestamptz IS NULL OR event_date < $6)
+		ORDER BY transaction_id LIMIT $3`
+
+	rows, err := t.db.Query(query, page.AfterId, filter.AccountId, page.EffectiveLimit(), filter.OperationTypeId, nullTime(filter.EventDateFrom), nullTime(filter.EventDateTo))
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#ListTransactions: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	transactions := []model.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransactionPostgres(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		transactions = append(transactions, transaction)
 	}
 
-	return &transaction, nil
+	if err := rows.Err(); err != nil {
+		log.Printf("TransactionRepositoryPostgres#ListTransactions: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return transactions, nil
+}
+
+func (t *TransactionRepositoryPostgres) ListTransactionsByAccounts(accountIds []uint64, page repository.Page) (map[uint64][]model.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM (
+		SELECT ` + transactionColumns + `,
+			ROW_NUMBER() OVER (PARTITION BY account_id ORDER BY transaction_id) AS position
+		FROM transactions WHERE account_id = ANY($1) AND transaction_id > $2
+	) ranked WHERE position <= $3 ORDER BY account_id, transaction_id`
+
+	ids := make([]int64, len(accountIds))
+
+	for i, accountId := range accountIds {
+		ids[i] = int64(accountId)
+	}
+
+	rows, err := t.db.Query(query, pq.Array(ids), page.AfterId, page.EffectiveLimit())
+
+	if err != nil {
+		log.Printf("TransactionRepositoryPostgres#ListTransactionsByAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	defer rows.Close()
+
+	transactions := map[uint64][]model.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransactionPostgres(rows)
+		if err != nil {
+			return nil, translatePostgresError(err)
+		}
+
+		transactions[transaction.AccountId] = append(transactions[transaction.AccountId], transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("TransactionRepositoryPostgres#ListTransactionsByAccounts: Reading rows failed: %s", err)
+
+		return nil, translatePostgresError(err)
+	}
+
+	return transactions, nil
+}
+
+func scanTransactionPostgres(rows *sql.Rows) (model.Transaction, error) {
+	transaction := model.Transaction{}
+	conversion := transactionConversion{}
+	merchant := transactionMerchant{}
+
+	var cardId sql.NullInt64
+
+	err := rows.Scan(&transaction.TransactionId, &transaction.AccountId, &cardId, &transaction.OperationTypeId, &transaction.Amount, &transaction.Currency,
+		&conversion.originalAmount, &conversion.originalCurrency, &conversion.rate, &conversion.rateId,
+		&merchant.name, &merchant.id, &merchant.mcc, &merchant.country, &merchant.category, &transaction.Reversed, &transaction.EventDate, &transaction.CreatedAt)
+
+	conversion.apply(&transaction)
+	merchant.apply(&transaction)
+	transaction.CardId = uint64(cardId.Int64)
+	transaction.EventDate = transaction.EventDate.UTC()
+	transaction.CreatedAt = transaction.CreatedAt.UTC()
+
+	return transaction, err
+}
+
+// transactionConversion scans the columns of the conversion of a
+// transaction, NULL for the transactions made in the currency of their
+// account.
+type transactionConversion struct {
+	originalAmount   sql.Null[model.Amount]
+	originalCurrency sql.NullString
+	rate             sql.NullFloat64
+	rateId           sql.NullInt64
+}
+
+func (c transactionConversion) apply(transaction *model.Transaction) {
+	transaction.OriginalAmount = c.originalAmount.V
+	transaction.OriginalCurrency = c.originalCurrency.String
+	transaction.FxRate = c.rate.Float64
+	transaction.FxRateId = uint64(c.rateId.Int64)
+}
+
+// transactionConversionRow returns the values of the conversion columns of
+// the transaction.
+func transactionConversionRow(transaction model.Transaction) []interface{} {
+	if transaction.OriginalCurrency == "" {
+		return []interface{}{nil, nil, nil, nil}
+	}
+
+	return []interface{}{transaction.OriginalAmount, transaction.OriginalCurrency, transaction.FxRate, transaction.FxRateId}
+}
+
+// transactionMerchant scans the columns of the merchant of a transaction,
+// NULL for the transactions made at none.
+type transactionMerchant struct {
+	name     sql.NullString
+	id       sql.NullString
+	mcc      sql.NullString
+	country  sql.NullString
+	category sql.NullString
+}
+
+func (m transactionMerchant) apply(transaction *model.Transaction) {
+	transaction.MerchantName = m.name.String
+	transaction.MerchantId = m.id.String
+	transaction.Mcc = m.mcc.String
+	transaction.MerchantCountry = m.country.String
+	transaction.Category = m.category.String
+}
+
+// transactionMerchantRow returns the values of the merchant columns of the
+// transaction.
+func transactionMerchantRow(transaction model.Transaction) []interface{} {
+	return []interface{}{nullString(transaction.MerchantName), nullString(transaction.MerchantId), nullString(transaction.Mcc), nullString(transaction.MerchantCountry), nullString(transaction.Category)}
+}
+
+// nullString stores an empty string, the one of no value, as NULL.
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
+
+// nullId stores a zero ID, the one of no record, as NULL.
+func nullId(id uint64) interface{} {
+	if id == 0 {
+		return nil
+	}
+
+	return id
+}
+
+func scanIds(rows *sql.Rows) ([]uint64, error) {
+	defer rows.Close()
+
+	var ids []uint64
+
+	for rows.Next() {
+		var id uint64
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// withTransactionIds gives the IDs drawn from a sequence by a single
+// statement to the transactions. The rows returned do not promise to follow
+// the order the IDs were drawn in, so they are matched after sorting.
+func withTransactionIds(transactions []model.Transaction, transactionIds []uint64) []model.Transaction {
+	sort.Slice(transactionIds, func(i, j int) bool { return transactionIds[i] < transactionIds[j] })
+
+	created := make([]model.Transaction, len(transactions))
+
+	for n, transaction := range transactions {
+		transaction.TransactionId = transactionIds[n]
+		created[n] = transaction
+	}
+
+	return created
+}
+
+// transactionEntries returns the audit entries of the created transactions.
+func transactionEntries(ctx context.Context, transactions []model.Transaction) ([]model.AuditEntry, error) {
+	entries := make([]model.AuditEntry, len(transactions))
+
+	for n, transaction := range transactions {
+		entry, err := audit.NewEntry(ctx, model.AUDIT_ACTION_CREATE, model.AUDIT_ENTITY_TRANSACTION, transaction.TransactionId, nil, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[n] = entry
+	}
+
+	return entries, nil
+}
+
+// reversalEntry returns the audit entry of the reversed transaction.
+func reversalEntry(ctx context.Context, transaction model.Transaction) (model.AuditEntry, error) {
+	before := transaction
+	before.Reversed = false
+
+	return audit.NewEntry(ctx, model.AUDIT_ACTION_UPDATE, model.AUDIT_ENTITY_TRANSACTION, transaction.TransactionId, before, transaction)
 }
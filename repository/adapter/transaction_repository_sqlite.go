@@ -0,0 +1,253 @@
+package adapter
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+type TransactionRepositorySQLite struct {
+	db          *sql.DB
+	projections *ProjectionRepositorySQLite
+}
+
+func NewTransactionRepositorySQLite(db *sql.DB) *TransactionRepositorySQLite {
+	return &TransactionRepositorySQLite{
+		db:          db,
+		projections: NewProjectionRepositorySQLite(db),
+	}
+}
+
+func (t *TransactionRepositorySQLite) CreateTransaction(ctx context.Context, transaction model.Transaction) (*model.Transaction, error) {
+	created, err := t.postTransactions(ctx, "CreateTransaction", []model.Transaction{transaction})
+	if err != nil {
+		return nil, err
+	}
+
+	return &created[0], nil
+}
+
+func (t *TransactionRepositorySQLite) CreateTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error) {
+	if len(transactions) == 0 {
+		return []model.Transaction{}, nil
+	}
+
+	return t.postTransactions(ctx, "CreateTransactions", transactions)
+}
+
+// postTransactions appends the events posting the transactions along with
+// their audit entries, then projects them.
+func (t *TransactionRepositorySQLite) postTransactions(ctx context.Context, method string, transactions []model.Transaction) ([]model.Transaction, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#%s: Beginning transaction failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	created, err := postTransactionsSQLite(ctx, tx, transactions)
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#%s: Appending events failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entries, err := transactionEntries(ctx, created)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entries...)
+	}
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#%s: Appending to the audit log failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("TransactionRepositorySQLite#%s: Committing transaction failed: %s", method, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	catchUpProjections(t.projections, "TransactionRepositorySQLite#"+method)
+
+	return created, nil
+}
+
+func (t *TransactionRepositorySQLite) ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error) {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#ReverseTransaction: Beginning transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer tx.Rollback()
+
+	transaction, err := reverseTransactionSQLite(ctx, tx, transactionId)
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#ReverseTransaction: Appending events failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	entry, err := reversalEntry(ctx, *transaction)
+
+	if err == nil {
+		err = appendAuditSQLite(tx, entry)
+	}
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#ReverseTransaction: Appending to the audit log failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("TransactionRepositorySQLite#ReverseTransaction: Committing transaction failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	catchUpProjections(t.projections, "TransactionRepositorySQLite#ReverseTransaction")
+
+	return transaction, nil
+}
+
+func (t *TransactionRepositorySQLite) ListTransactions(filter repository.TransactionFilter, page repository.Page) ([]model.Transaction, error) {
+	query := `SELECT ` + transactionColumns + ` FROM transactions
+		WHERE transaction_id > ?1 AND (?2 = 0 OR account_id = ?2) AND (?4 = 0 OR operation_type_id = ?4)
+			AND (?5 IS NULL OR event_date >= ?5) AND (?6 IS NULL OR event_date < ?6)
+		ORDER BY transaction_id LIMIT ?3`
+
+	rows, err := t.db.Query(query, page.AfterId, filter.AccountId, page.EffectiveLimit(), filter.OperationTypeId, sqliteTime(filter.EventDateFrom), sqliteTime(filter.EventDateTo))
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#ListTransactions: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	transactions := []model.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransactionSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("TransactionRepositorySQLite#ListTransactions: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return transactions, nil
+}
+
+func (t *TransactionRepositorySQLite) ListTransactionsByAccounts(accountIds []uint64, page repository.Page) (map[uint64][]model.Transaction, error) {
+	if len(accountIds) == 0 {
+		return map[uint64][]model.Transaction{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(accountIds)), ", ")
+
+	query := `SELECT ` + transactionColumns + ` FROM (
+		SELECT ` + transactionColumns + `,
+			ROW_NUMBER() OVER (PARTITION BY account_id ORDER BY transaction_id) AS position
+		FROM transactions WHERE account_id IN (` + placeholders + `) AND transaction_id > ?
+	) ranked WHERE position <= ? ORDER BY account_id, transaction_id`
+
+	args := []interface{}{}
+
+	for _, accountId := range accountIds {
+		args = append(args, accountId)
+	}
+
+	args = append(args, page.AfterId, page.EffectiveLimit())
+
+	rows, err := t.db.Query(query, args...)
+
+	if err != nil {
+		log.Printf("TransactionRepositorySQLite#ListTransactionsByAccounts: Database query (%s) failed: %s", query, err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	defer rows.Close()
+
+	transactions := map[uint64][]model.Transaction{}
+
+	for rows.Next() {
+		transaction, err := scanTransactionSQLite(rows)
+		if err != nil {
+			return nil, translateSQLiteError(err)
+		}
+
+		transactions[transaction.AccountId] = append(transactions[transaction.AccountId], transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("TransactionRepositorySQLite#ListTransactionsByAccounts: Reading rows failed: %s", err)
+
+		return nil, translateSQLiteError(err)
+	}
+
+	return transactions, nil
+}
+
+// scanTransactionSQLite reads the event date of the transactions projected
+// before the column existed as their posting time.
+func scanTransactionSQLite(rows *sql.Rows) (model.Transaction, error) {
+	transaction := model.Transaction{}
+
+	conversion := transactionConversion{}
+	merchant := transactionMerchant{}
+
+	var cardId sql.NullInt64
+	var eventDate, createdAt sql.NullString
+
+	err := rows.Scan(&transaction.TransactionId, &transaction.AccountId, &cardId, &transaction.OperationTypeId, &transaction.Amount, &transaction.Currency,
+		&conversion.originalAmount, &conversion.originalCurrency, &conversion.rate, &conversion.rateId,
+		&merchant.name, &merchant.id, &merchant.mcc, &merchant.country, &merchant.category, &transaction.Reversed, &eventDate, &createdAt)
+	if err != nil {
+		return transaction, err
+	}
+
+	conversion.apply(&transaction)
+	merchant.apply(&transaction)
+	transaction.CardId = uint64(cardId.Int64)
+
+	if !eventDate.Valid {
+		eventDate = createdAt
+	}
+
+	postedAt, err := parseSQLiteTime(createdAt)
+	if err != nil {
+		return transaction, err
+	}
+
+	tookPlaceAt, err := parseSQLiteTime(eventDate)
+	if err != nil {
+		return transaction, err
+	}
+
+	transaction.CreatedAt = *postedAt
+	transaction.EventDate = *tookPlaceAt
+
+	return transaction, nil
+}
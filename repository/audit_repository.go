@@ -0,0 +1,17 @@
+package repository
+
+import "github.com/felipedsi/pismo-test/model"
+
+// AuditFilter narrows ListAuditEntries, zero fields are ignored.
+type AuditFilter struct {
+	EntityType string
+	EntityId   uint64
+	Actor      string
+	RequestId  string
+}
+
+// AuditRepository reads the audit log. Entries are only ever appended, by
+// the repositories making the changes they record.
+type AuditRepository interface {
+	ListAuditEntries(filter AuditFilter, page Page) ([]model.AuditEntry, error)
+}
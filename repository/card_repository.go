@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// CardRepository stores the cards of the accounts. Every change is recorded
+// in the audit log, and the spend controls of the cards are applied by the
+// transaction repository as it posts the transactions made with them.
+type CardRepository interface {
+	// CreateCard returns ErrForeignKeyViolation when the account or the
+	// holder does not exist.
+	CreateCard(ctx context.Context, card model.Card) (*model.Card, error)
+	FindCard(cardId uint64) (*model.Card, error)
+	ListCards(accountId uint64, page Page) ([]model.Card, error)
+	// UpdateCardStatus moves the card from one of from to status. It returns
+	// ErrNotFound when the card does not exist and ErrConflict when it is in
+	// none of from.
+	UpdateCardStatus(ctx context.Context, cardId uint64, from []string, status string) (*model.Card, error)
+	// UpdateCardLimits replaces the spend limits of the card, it returns
+	// ErrNotFound when the card does not exist.
+	UpdateCardLimits(ctx context.Context, cardId uint64, transactionLimit model.Amount, dailyLimit model.Amount) (*model.Card, error)
+	// ReplaceCard issues replacement, of the account, holder, type and
+	// limits of the card, and moves the card to CARD_STATUS_REPLACED. It
+	// returns ErrNotFound when the card does not exist and ErrConflict when
+	// it was replaced already.
+	ReplaceCard(ctx context.Context, cardId uint64, replacement model.Card) (*model.Card, error)
+}
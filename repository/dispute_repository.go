@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type DisputeFilter struct {
+	AccountId uint64
+	Status    string
+	// Escalated keeps the escalated disputes only.
+	Escalated bool
+}
+
+// DisputeRepository stores the disputes and the history of their changes,
+// recording every change in the audit log along with the transactions they
+// post.
+type DisputeRepository interface {
+	// OpenDispute opens dispute on its TransactionId, for its whole amount
+	// unless dispute has an Amount. It returns ErrForeignKeyViolation when
+	// the transaction does not exist, ErrNotDisputable when it is not a
+	// purchase or withdraw, was reversed or is for less than the amount,
+	// and ErrConflict when it is disputed already.
+	OpenDispute(ctx context.Context, dispute model.Dispute) (*model.Dispute, error)
+	// FindDispute returns ErrNotFound when the dispute does not exist.
+	FindDispute(disputeId uint64) (*model.Dispute, error)
+	ListDisputes(filter DisputeFilter, page Page) ([]model.Dispute, error)
+	ListDisputeChanges(disputeId uint64, page Page) ([]model.DisputeChange, error)
+	// TransitionDispute moves the dispute to status, posting its
+	// provisional credit when the status is DISPUTE_STATUS_CREDITED and
+	// reversing it when the status is DISPUTE_STATUS_LOST. It returns
+	// ErrNotFound when the dispute does not exist and ErrConflict when it
+	// cannot move to status, see model.ValidateDisputeTransition.
+	TransitionDispute(ctx context.Context, disputeId uint64, status string, note string) (*model.Dispute, error)
+	// ListOverdueDisputes returns up to limit disputes past their deadline
+	// at now that are not escalated yet.
+	ListOverdueDisputes(now time.Time, limit int) ([]model.Dispute, error)
+	// EscalateDispute escalates the dispute, when it is still past its
+	// deadline at now and not escalated yet, and returns ErrConflict
+	// otherwise.
+	EscalateDispute(ctx context.Context, disputeId uint64, now time.Time) (*model.Dispute, error)
+}
@@ -0,0 +1,23 @@
+package repository
+
+import "errors"
+
+// Adapters wrap the driver errors they get with one of these, so callers can
+// react to the kind of failure with errors.Is no matter the storage in use.
+var (
+	ErrNotFound            = errors.New("record not found")
+	ErrConflict            = errors.New("record conflicts with an existing one")
+	ErrForeignKeyViolation = errors.New("referenced record does not exist")
+	ErrUnavailable         = errors.New("storage is unavailable")
+	ErrTimeout             = errors.New("storage operation timed out")
+	ErrAccountBlocked      = errors.New("account is blocked")
+	ErrVersionConflict     = errors.New("stream is not at the expected version")
+	ErrFxRateNotFound      = errors.New("no exchange rate is effective for the currencies")
+	ErrInvalidAmount       = errors.New("amount has more decimals than its currency takes")
+	ErrCardInactive        = errors.New("card is blocked, replaced or expired")
+	ErrCardLimitExceeded   = errors.New("amount exceeds a spend limit of the card")
+	ErrMerchantDenied      = errors.New("merchant is denied for the account")
+	ErrMerchantNotAllowed  = errors.New("merchant is not among the ones allowed for the account")
+	ErrCategoryCapExceeded = errors.New("amount exceeds the spend cap of the category")
+	ErrNotDisputable       = errors.New("transaction cannot be disputed")
+)
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// EventFilter narrows ListEvents, zero fields are ignored.
+type EventFilter struct {
+	AccountId uint64
+}
+
+// EventRepository reads the event streams the accounts and their
+// transactions are rebuilt from. Events are only ever appended, by the
+// repositories handling the commands.
+type EventRepository interface {
+	// ListEvents pages through the events in the order they were appended.
+	ListEvents(filter EventFilter, page Page) ([]model.Event, error)
+}
+
+// ProjectionRepository maintains the read models, the balances and the
+// listing of the transactions, from the events. Each read model keeps the
+// position of the last event it applied, so events are applied once even
+// when many instances project at the same time.
+type ProjectionRepository interface {
+	// ProjectEvents applies up to limit events following the position of
+	// each read model, and returns the most any of them applied.
+	ProjectEvents(limit int) (int, error)
+	// ResetProjections empties the read models, so the events are projected
+	// again from the first one.
+	ResetProjections() error
+}
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion returns a copy of ctx making a command on a single
+// stream fail with ErrVersionConflict when the stream moved past version.
+func WithExpectedVersion(ctx context.Context, version uint64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// ExpectedVersion returns the version set with WithExpectedVersion.
+func ExpectedVersion(ctx context.Context) (uint64, bool) {
+	version, ok := ctx.Value(expectedVersionKey{}).(uint64)
+
+	return version, ok
+}
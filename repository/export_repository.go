@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// StatementFilter selects the transactions of an account posted from From,
+// included, to To, excluded. A zero time leaves that side of the period open.
+type StatementFilter struct {
+	AccountId uint64
+	From      time.Time
+	To        time.Time
+}
+
+type ExportRepository interface {
+	CountStatementLines(filter StatementFilter) (uint64, error)
+	// StreamStatementLines calls fn with every line in the order they were
+	// posted, without loading them all at once. An error returned by fn
+	// stops the stream and is returned as is.
+	StreamStatementLines(filter StatementFilter, fn func(model.StatementLine) error) error
+	// CreateExport is recorded in the audit log like the other repositories
+	// do.
+	CreateExport(ctx context.Context, export model.Export) (*model.Export, error)
+	FindExport(exportId uint64) (*model.Export, error)
+	ListPendingExports() ([]model.Export, error)
+	FinishExport(exportId uint64, status string, rows uint64, message string) (*model.Export, error)
+}
+
+// ExportFilter returns the statement filter of the export.
+func ExportFilter(export model.Export) StatementFilter {
+	filter := StatementFilter{AccountId: export.AccountId}
+
+	if export.From != nil {
+		filter.From = *export.From
+	}
+
+	if export.To != nil {
+		filter.To = *export.To
+	}
+
+	return filter
+}
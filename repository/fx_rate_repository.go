@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// FxRateFilter narrows ListFxRates, zero fields are ignored.
+type FxRateFilter struct {
+	BaseCurrency  string
+	QuoteCurrency string
+}
+
+// FxRateRepository stores the exchange rates the transactions are converted
+// at. Rates are never changed: a new rate of a pair takes effect from its
+// own time on, keeping the transactions converted before it as they were.
+type FxRateRepository interface {
+	// CreateFxRates stores every rate or, when any of them fails, none. A
+	// rate of a pair already taking effect at the same time fails with
+	// ErrConflict.
+	CreateFxRates(ctx context.Context, rates []model.FxRate) ([]model.FxRate, error)
+	ListFxRates(filter FxRateFilter, page Page) ([]model.FxRate, error)
+	// FindFxRate returns the rate of base in quote effective at at, or
+	// ErrFxRateNotFound when none had taken effect yet.
+	FindFxRate(base string, quote string, at time.Time) (*model.FxRate, error)
+}
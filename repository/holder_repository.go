@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// HolderRepository stores the holders, recording every change in the audit
+// log without their personal data, see model.Holder.Redacted. The database
+// adapters keep the personal data encrypted.
+type HolderRepository interface {
+	CreateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error)
+	FindHolder(holderId uint64) (*model.Holder, error)
+	ListHolders(page Page) ([]model.Holder, error)
+	// UpdateHolder replaces the personal data of the holder. It returns
+	// ErrNotFound when the holder does not exist.
+	UpdateHolder(ctx context.Context, holder model.Holder) (*model.Holder, error)
+	// DeleteHolder returns ErrNotFound when the holder does not exist and
+	// ErrConflict when it still owns accounts or holds cards.
+	DeleteHolder(ctx context.Context, holderId uint64) error
+}
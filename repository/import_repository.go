@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// ImportBatch is saved atomically: its transactions and rejections are only
+// stored along with the move of the import to ProcessedLines, so a batch
+// interrupted by a crash is saved again in full on resume.
+type ImportBatch struct {
+	Transactions   []model.Transaction
+	Rejections     []model.ImportRejection
+	RejectedRows   uint64
+	ProcessedLines uint64
+}
+
+type ImportRepository interface {
+	// CreateImport returns ErrConflict when the idempotency key is taken. It
+	// is recorded in the audit log like the other repositories do.
+	CreateImport(ctx context.Context, imp model.Import) (*model.Import, error)
+	FindImport(importId uint64) (*model.Import, error)
+	FindImportByIdempotencyKey(idempotencyKey string) (*model.Import, error)
+	ListUnfinishedImports() ([]model.Import, error)
+	// SaveImportBatch returns ErrConflict when the import already went past
+	// the lines of the batch, so two workers never save the same rows.
+	SaveImportBatch(importId uint64, batch ImportBatch) (*model.Import, error)
+	FinishImport(importId uint64, status string, message string) (*model.Import, error)
+	ListImportRejections(importId uint64, page Page) ([]model.ImportRejection, error)
+}
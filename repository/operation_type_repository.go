@@ -0,0 +1,7 @@
+package repository
+
+import "github.com/felipedsi/pismo-test/model"
+
+type OperationTypeRepository interface {
+	ListOperationTypes() ([]model.OperationType, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+// DefaultPageLimit is used when a Page has no limit.
+const DefaultPageLimit = 100
+
+// Page selects records ordered by ID, starting right after AfterId. List
+// methods use keyset pagination so results stay stable while records are
+// being inserted.
+type Page struct {
+	AfterId uint64
+	Limit   int
+}
+
+func (p Page) EffectiveLimit() int {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+
+	return p.Limit
+}
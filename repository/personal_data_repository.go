@@ -0,0 +1,13 @@
+package repository
+
+import "context"
+
+// PersonalDataRepository is implemented by the database adapters storing
+// personal data encrypted, for the values to move under the current master
+// key once it is rotated, see pii.Rotator.
+type PersonalDataRepository interface {
+	// ReencryptPersonalData re-encrypts up to limit rows not encrypted under
+	// the current master key, or not encrypted at all, and returns how many
+	// were. Fewer than limit means none are left.
+	ReencryptPersonalData(ctx context.Context, limit int) (int, error)
+}
@@ -0,0 +1,1956 @@
+// Package repositorytest holds the behavior every repository adapter must
+// share, so the memory and database adapters can be checked against the same
+// expectations.
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/audit"
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+type Repositories struct {
+	Accounts       repository.AccountRepository
+	Transactions   repository.TransactionRepository
+	OperationTypes repository.OperationTypeRepository
+	Imports        repository.ImportRepository
+	Exports        repository.ExportRepository
+	Audit          repository.AuditRepository
+	Events         repository.EventRepository
+	Projections    repository.ProjectionRepository
+	Schedules      repository.ScheduleRepository
+	FxRates        repository.FxRateRepository
+	Cards          repository.CardRepository
+	SpendRules     repository.SpendRuleRepository
+	Risk           repository.RiskRepository
+	Disputes       repository.DisputeRepository
+	Holders        repository.HolderRepository
+}
+
+// Factory must return repositories backed by empty storage whose ID
+// sequences start from 1.
+type Factory func(t *testing.T) Repositories
+
+func Run(t *testing.T, newRepositories Factory) {
+	t.Run("CreateAccountAssignsSequentialIds", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		first, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		second, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(1), first.AccountId)
+		assert.Equal(t, uint64(2), second.AccountId)
+		assert.Equal(t, uint64(111), first.DocumentNumber)
+	})
+
+	t.Run("FindAccountReturnsCreatedAccount", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		created, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 12345678})
+		require.NoError(t, err)
+
+		found, err := repos.Accounts.FindAccount(created.AccountId)
+		require.NoError(t, err)
+
+		assert.Equal(t, *created, *found)
+	})
+
+	t.Run("ListOperationTypesReturnsSeededTypes", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		operationTypes, err := repos.OperationTypes.ListOperationTypes()
+		require.NoError(t, err)
+
+		assert.Equal(t, []model.OperationType{
+			{OperationTypeId: model.CASH_PURCHASE, Description: "COMPRA A VISTA"},
+			{OperationTypeId: model.INSTALLMENT_PURCHASE, Description: "COMPRA PARCELADA"},
+			{OperationTypeId: model.WITHDRAW, Description: "SAQUE"},
+			{OperationTypeId: model.PAYMENT, Description: "PAGAMENTO"},
+			{OperationTypeId: model.INTEREST, Description: "JUROS"},
+			{OperationTypeId: model.LATE_FEE, Description: "MULTA POR ATRASO"},
+			{OperationTypeId: model.DISPUTE_CREDIT, Description: "CREDITO PROVISORIO"},
+		}, operationTypes)
+	})
+
+	t.Run("FindAccountFailsWhenAccountDoesNotExist", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.FindAccount(999)
+
+		assert.Nil(t, account)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("CreateTransactionAssignsSequentialIds", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		first, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+			AccountId:       account.AccountId,
+			OperationTypeId: model.CASH_PURCHASE,
+			Amount:          model.MustParseAmount("-50"),
+		})
+		require.NoError(t, err)
+
+		second, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+			AccountId:       account.AccountId,
+			OperationTypeId: model.PAYMENT,
+			Amount:          model.MustParseAmount("60"),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint64(1), first.TransactionId)
+		assert.Equal(t, uint64(2), second.TransactionId)
+		assert.Equal(t, account.AccountId, first.AccountId)
+		assert.Equal(t, uint32(model.PAYMENT), second.OperationTypeId)
+		assert.Equal(t, model.MustParseAmount("60"), second.Amount)
+	})
+
+	t.Run("CreateTransactionFailsWhenAccountDoesNotExist", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		transaction, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+			AccountId:       999,
+			OperationTypeId: model.CASH_PURCHASE,
+			Amount:          model.MustParseAmount("-50"),
+		})
+
+		assert.Nil(t, transaction)
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+	})
+
+	t.Run("CreateTransactionFailsWhenOperationTypeDoesNotExist", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		transaction, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+			AccountId:       account.AccountId,
+			OperationTypeId: 99,
+			Amount:          model.MustParseAmount("-50"),
+		})
+
+		assert.Nil(t, transaction)
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+	})
+
+	t.Run("CreateTransactionsKeepsTheOrderOfTheTransactions", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		first, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		second, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		transactions := []model.Transaction{}
+
+		// More rows than fit in a single SQLite statement.
+		for n := 0; n < 150; n++ {
+			accountId := first.AccountId
+
+			if n%2 == 1 {
+				accountId = second.AccountId
+			}
+
+			transactions = append(transactions, model.Transaction{AccountId: accountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount(strconv.Itoa(n + 1))})
+		}
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), transactions)
+		require.NoError(t, err)
+		require.Len(t, created, len(transactions))
+
+		for n, transaction := range created {
+			assert.Equal(t, uint64(n+1), transaction.TransactionId)
+			assert.Equal(t, transactions[n].AccountId, transaction.AccountId)
+			assert.Equal(t, transactions[n].Amount, transaction.Amount)
+		}
+
+		stored, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{Limit: 1000})
+		require.NoError(t, err)
+		assert.Equal(t, created, stored)
+	})
+
+	t.Run("CreateTransactionsStoresNothingWhenOneFails", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+			{AccountId: 999, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+		})
+		assert.Nil(t, created)
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		stored, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Empty(t, stored)
+	})
+
+	t.Run("ListAccountsPaginatesAndFilters", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		for _, documentNumber := range []uint64{111, 222, 111} {
+			_, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+			require.NoError(t, err)
+		}
+
+		firstPage, err := repos.Accounts.ListAccounts(repository.AccountFilter{}, repository.Page{Limit: 2})
+		require.NoError(t, err)
+
+		secondPage, err := repos.Accounts.ListAccounts(repository.AccountFilter{}, repository.Page{AfterId: 2, Limit: 2})
+		require.NoError(t, err)
+
+		filtered, err := repos.Accounts.ListAccounts(repository.AccountFilter{DocumentNumber: 111}, repository.Page{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []model.Account{{AccountId: 1, DocumentNumber: 111, Currency: "BRL"}, {AccountId: 2, DocumentNumber: 222, Currency: "BRL"}}, firstPage)
+		assert.Equal(t, []model.Account{{AccountId: 3, DocumentNumber: 111, Currency: "BRL"}}, secondPage)
+		assert.Equal(t, []model.Account{{AccountId: 1, DocumentNumber: 111, Currency: "BRL"}, {AccountId: 3, DocumentNumber: 111, Currency: "BRL"}}, filtered)
+	})
+
+	t.Run("ListAccountsReturnsEmptySliceWhenNothingMatches", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		accounts, err := repos.Accounts.ListAccounts(repository.AccountFilter{}, repository.Page{})
+		require.NoError(t, err)
+
+		assert.NotNil(t, accounts)
+		assert.Empty(t, accounts)
+	})
+
+	t.Run("ListTransactionsPaginatesAndFilters", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		first, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		second, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		created := []model.Transaction{}
+
+		for _, accountId := range []uint64{first.AccountId, second.AccountId, first.AccountId} {
+			transaction, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+				AccountId:       accountId,
+				OperationTypeId: model.WITHDRAW,
+				Amount:          model.MustParseAmount("-10.5"),
+			})
+			require.NoError(t, err)
+
+			created = append(created, *transaction)
+		}
+
+		all, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+
+		filtered, err := repos.Transactions.ListTransactions(repository.TransactionFilter{AccountId: first.AccountId}, repository.Page{AfterId: 1, Limit: 10})
+		require.NoError(t, err)
+
+		assert.Len(t, all, 3)
+		assert.Equal(t, []model.Transaction{created[2]}, filtered)
+		assert.Equal(t, uint64(3), filtered[0].TransactionId)
+	})
+
+	t.Run("ListTransactionsByAccountsPagesEachAccount", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		var accountIds []uint64
+
+		for _, documentNumber := range []uint64{111, 222, 333} {
+			account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+			require.NoError(t, err)
+
+			accountIds = append(accountIds, account.AccountId)
+		}
+
+		for _, accountId := range []uint64{accountIds[0], accountIds[1], accountIds[0], accountIds[0], accountIds[1]} {
+			_, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{
+				AccountId:       accountId,
+				OperationTypeId: model.PAYMENT,
+				Amount:          model.MustParseAmount("25"),
+			})
+			require.NoError(t, err)
+		}
+
+		transactions, err := repos.Transactions.ListTransactionsByAccounts(accountIds, repository.Page{Limit: 2})
+		require.NoError(t, err)
+
+		ids := map[uint64][]uint64{}
+
+		for accountId, accountTransactions := range transactions {
+			for _, transaction := range accountTransactions {
+				ids[accountId] = append(ids[accountId], transaction.TransactionId)
+			}
+		}
+
+		assert.Equal(t, map[uint64][]uint64{
+			accountIds[0]: {1, 3},
+			accountIds[1]: {2, 5},
+		}, ids)
+	})
+
+	t.Run("FindAccountsReturnsExistingAccounts", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		for _, documentNumber := range []uint64{111, 222} {
+			_, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+			require.NoError(t, err)
+		}
+
+		accounts, err := repos.Accounts.FindAccounts([]uint64{2, 999, 1, 2})
+		require.NoError(t, err)
+
+		assert.ElementsMatch(t, []model.Account{{AccountId: 1, DocumentNumber: 111, Currency: "BRL"}, {AccountId: 2, DocumentNumber: 222, Currency: "BRL"}}, accounts)
+
+		accounts, err = repos.Accounts.FindAccounts(nil)
+		require.NoError(t, err)
+		assert.Empty(t, accounts)
+	})
+
+	t.Run("CreateImportRejectsTakenIdempotencyKey", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		created, err := repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING, IdempotencyKey: "key-1"})
+		require.NoError(t, err)
+		assert.Equal(t, model.Import{ImportId: 1, Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING, IdempotencyKey: "key-1"}, *created)
+
+		_, err = repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING, IdempotencyKey: "key-1"})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		// Imports without a key never conflict with each other.
+		for i := 0; i < 2; i++ {
+			_, err = repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_JSONL, Status: model.IMPORT_PENDING})
+			require.NoError(t, err)
+		}
+
+		found, err := repos.Imports.FindImportByIdempotencyKey("key-1")
+		require.NoError(t, err)
+		assert.Equal(t, *created, *found)
+
+		_, err = repos.Imports.FindImportByIdempotencyKey("key-2")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repos.Imports.FindImport(999)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("SaveImportBatchStoresRowsAndMovesImportForward", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		imp, err := repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING})
+		require.NoError(t, err)
+
+		saved, err := repos.Imports.SaveImportBatch(imp.ImportId, repository.ImportBatch{
+			Transactions: []model.Transaction{
+				{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-10")},
+				{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+			},
+			Rejections: []model.ImportRejection{
+				{Line: 3, Field: "amount", Code: "invalid_decimal", Message: "The amount must be a valid decimal number."},
+				{Line: 3, Field: "account_id", Code: "required", Message: "The account_id is required."},
+			},
+			RejectedRows:   1,
+			ProcessedLines: 4,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, model.IMPORT_RUNNING, saved.Status)
+		assert.Equal(t, uint64(4), saved.ProcessedLines)
+		assert.Equal(t, uint64(2), saved.ImportedRows)
+		assert.Equal(t, uint64(1), saved.RejectedRows)
+
+		transactions, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 2)
+
+		rejections, err := repos.Imports.ListImportRejections(imp.ImportId, repository.Page{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, rejections, 1)
+		assert.Equal(t, "amount", rejections[0].Field)
+
+		rejections, err = repos.Imports.ListImportRejections(imp.ImportId, repository.Page{AfterId: rejections[0].ImportRejectionId})
+		require.NoError(t, err)
+		require.Len(t, rejections, 1)
+		assert.Equal(t, model.ImportRejection{ImportRejectionId: rejections[0].ImportRejectionId, Line: 3, Field: "account_id", Code: "required", Message: "The account_id is required."}, rejections[0])
+
+		unfinished, err := repos.Imports.ListUnfinishedImports()
+		require.NoError(t, err)
+		assert.Equal(t, []model.Import{*saved}, unfinished)
+
+		finished, err := repos.Imports.FinishImport(imp.ImportId, model.IMPORT_COMPLETED, "")
+		require.NoError(t, err)
+		assert.Equal(t, model.IMPORT_COMPLETED, finished.Status)
+
+		unfinished, err = repos.Imports.ListUnfinishedImports()
+		require.NoError(t, err)
+		assert.Empty(t, unfinished)
+	})
+
+	t.Run("SaveImportBatchFailsWhenLinesWereAlreadySaved", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		imp, err := repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING})
+		require.NoError(t, err)
+
+		batch := repository.ImportBatch{
+			Transactions:   []model.Transaction{{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")}},
+			ProcessedLines: 2,
+		}
+
+		_, err = repos.Imports.SaveImportBatch(imp.ImportId, batch)
+		require.NoError(t, err)
+
+		_, err = repos.Imports.SaveImportBatch(imp.ImportId, batch)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Imports.SaveImportBatch(999, batch)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		transactions, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, transactions, 1)
+	})
+
+	t.Run("SaveImportBatchIsAtomic", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		imp, err := repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING})
+		require.NoError(t, err)
+
+		_, err = repos.Imports.SaveImportBatch(imp.ImportId, repository.ImportBatch{
+			Transactions: []model.Transaction{
+				{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+				{AccountId: 999, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+			},
+			Rejections:     []model.ImportRejection{{Line: 2, Field: "amount", Code: "required", Message: "The amount is required."}},
+			RejectedRows:   1,
+			ProcessedLines: 4,
+		})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		found, err := repos.Imports.FindImport(imp.ImportId)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(0), found.ProcessedLines)
+		assert.Equal(t, model.IMPORT_PENDING, found.Status)
+
+		transactions, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Empty(t, transactions)
+
+		rejections, err := repos.Imports.ListImportRejections(imp.ImportId, repository.Page{})
+		require.NoError(t, err)
+		assert.Empty(t, rejections)
+	})
+
+	t.Run("StreamStatementLinesFiltersByAccountAndPeriod", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		other, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		for _, transaction := range []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50")},
+			{AccountId: other.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("60")},
+		} {
+			_, err := repos.Transactions.CreateTransaction(context.Background(), transaction)
+			require.NoError(t, err)
+		}
+
+		now := time.Now()
+
+		var lines []model.StatementLine
+
+		filter := repository.StatementFilter{AccountId: account.AccountId, From: now.Add(-time.Hour), To: now.Add(time.Hour)}
+
+		err = repos.Exports.StreamStatementLines(filter, func(line model.StatementLine) error {
+			lines = append(lines, line)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.Len(t, lines, 2)
+		assert.Equal(t, uint64(1), lines[0].TransactionId)
+		assert.Equal(t, "COMPRA A VISTA", lines[0].Description)
+		assert.Equal(t, uint64(3), lines[1].TransactionId)
+		assert.Equal(t, "PAGAMENTO", lines[1].Description)
+		assert.Equal(t, model.MustParseAmount("60"), lines[1].Amount)
+		assert.WithinDuration(t, now, lines[1].CreatedAt, time.Minute)
+
+		count, err := repos.Exports.CountStatementLines(filter)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(2), count)
+
+		for _, period := range []repository.StatementFilter{
+			{AccountId: account.AccountId, From: now.Add(time.Hour)},
+			{AccountId: account.AccountId, To: now.Add(-time.Hour)},
+		} {
+			count, err := repos.Exports.CountStatementLines(period)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(0), count)
+		}
+
+		failure := errors.New("client went away")
+		calls := 0
+
+		err = repos.Exports.StreamStatementLines(repository.StatementFilter{AccountId: account.AccountId}, func(line model.StatementLine) error {
+			calls++
+			return failure
+		})
+		assert.Equal(t, failure, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ExportMovesFromPendingToFinished", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		created, err := repos.Exports.CreateExport(context.Background(), model.Export{AccountId: account.AccountId, Format: model.EXPORT_FORMAT_PDF, From: &from, Status: model.EXPORT_PENDING})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), created.ExportId)
+		assert.Equal(t, &from, created.From)
+		assert.Nil(t, created.To)
+
+		pending, err := repos.Exports.ListPendingExports()
+		require.NoError(t, err)
+		assert.Equal(t, []model.Export{*created}, pending)
+
+		finished, err := repos.Exports.FinishExport(created.ExportId, model.EXPORT_COMPLETED, 42, "")
+		require.NoError(t, err)
+		assert.Equal(t, uint64(42), finished.Rows)
+
+		found, err := repos.Exports.FindExport(created.ExportId)
+		require.NoError(t, err)
+		assert.Equal(t, finished, found)
+
+		pending, err = repos.Exports.ListPendingExports()
+		require.NoError(t, err)
+		assert.Empty(t, pending)
+
+		_, err = repos.Exports.FindExport(99)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repos.Exports.CreateExport(context.Background(), model.Export{AccountId: 99, Format: model.EXPORT_FORMAT_CSV, Status: model.EXPORT_PENDING})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+	})
+
+	t.Run("ConcurrentCreatesDoNotReuseIds", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		const workers = 20
+
+		var wg sync.WaitGroup
+		ids := make(chan uint64, workers)
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+
+			go func(documentNumber uint64) {
+				defer wg.Done()
+
+				account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+				if assert.NoError(t, err) {
+					ids <- account.AccountId
+				}
+			}(uint64(i + 1))
+		}
+
+		wg.Wait()
+		close(ids)
+
+		seen := map[uint64]bool{}
+
+		for id := range ids {
+			assert.False(t, seen[id], "account ID %d was assigned twice", id)
+			seen[id] = true
+		}
+
+		assert.Len(t, seen, workers)
+
+		// Concurrent changes are chained one after the other.
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, entries, workers)
+
+		_, err = audit.Verify("", entries)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ChangesAreRecordedInTheAuditLog", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		ctx := audit.NewContext(context.Background(), audit.Metadata{Actor: "alice", ClientIP: "10.0.0.1", RequestId: "req-1"})
+
+		account, err := repos.Accounts.CreateAccount(ctx, model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		created, err := repos.Transactions.CreateTransactions(ctx, []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("20")},
+		})
+		require.NoError(t, err)
+
+		// A change that fails leaves no entry behind.
+		_, err = repos.Transactions.CreateTransaction(ctx, model.Transaction{AccountId: 99, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("30")})
+		require.Error(t, err)
+
+		_, err = repos.Imports.CreateImport(context.Background(), model.Import{Format: model.IMPORT_FORMAT_CSV, Status: model.IMPORT_PENDING})
+		require.NoError(t, err)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+
+		lastHash, err := audit.Verify("", entries)
+		require.NoError(t, err)
+		assert.Equal(t, entries[3].Hash, lastHash)
+
+		assert.Equal(t, uint64(1), entries[0].AuditEntryId)
+		assert.Equal(t, model.AUDIT_ACTION_CREATE, entries[0].Action)
+		assert.Equal(t, model.AUDIT_ENTITY_ACCOUNT, entries[0].EntityType)
+		assert.Equal(t, account.AccountId, entries[0].EntityId)
+		assert.Equal(t, "alice", entries[0].Actor)
+		assert.Equal(t, "10.0.0.1", entries[0].ClientIP)
+		assert.Equal(t, "req-1", entries[0].RequestId)
+		assert.JSONEq(t, "null", string(entries[0].Before))
+		assert.JSONEq(t, `{"account_id":1,"currency":"BRL"}`, string(entries[0].After))
+		assert.WithinDuration(t, time.Now(), entries[0].CreatedAt, time.Minute)
+
+		assert.Equal(t, model.AUDIT_ENTITY_TRANSACTION, entries[2].EntityType)
+		assert.Equal(t, uint64(2), entries[2].EntityId)
+		assert.JSONEq(t, `{"transaction_id":2,"account_id":1,"operation_type_id":4,"amount":20,"currency":"BRL","event_date":"`+formatTime(created[1].EventDate)+`","created_at":"`+formatTime(created[1].CreatedAt)+`"}`, string(entries[2].After))
+
+		assert.Equal(t, model.AUDIT_ENTITY_IMPORT, entries[3].EntityType)
+		assert.Equal(t, audit.SystemActor, entries[3].Actor)
+	})
+
+	t.Run("ListAuditEntriesPaginatesAndFilters", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		alice := audit.NewContext(context.Background(), audit.Metadata{Actor: "alice", RequestId: "req-1"})
+		bob := audit.NewContext(context.Background(), audit.Metadata{Actor: "bob", RequestId: "req-2"})
+
+		for _, ctx := range []context.Context{alice, bob, alice} {
+			_, err := repos.Accounts.CreateAccount(ctx, model.Account{DocumentNumber: 111})
+			require.NoError(t, err)
+		}
+
+		_, err := repos.Transactions.CreateTransaction(bob, model.Transaction{AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		require.NoError(t, err)
+
+		ids := func(filter repository.AuditFilter, page repository.Page) []uint64 {
+			entries, err := repos.Audit.ListAuditEntries(filter, page)
+			require.NoError(t, err)
+
+			ids := []uint64{}
+
+			for _, entry := range entries {
+				ids = append(ids, entry.AuditEntryId)
+			}
+
+			return ids
+		}
+
+		assert.Equal(t, []uint64{1, 3}, ids(repository.AuditFilter{Actor: "alice"}, repository.Page{}))
+		assert.Equal(t, []uint64{2, 4}, ids(repository.AuditFilter{RequestId: "req-2"}, repository.Page{}))
+		assert.Equal(t, []uint64{1, 4}, ids(repository.AuditFilter{EntityId: 1}, repository.Page{}))
+		assert.Equal(t, []uint64{4}, ids(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_TRANSACTION, EntityId: 1}, repository.Page{}))
+		assert.Equal(t, []uint64{2, 3}, ids(repository.AuditFilter{}, repository.Page{AfterId: 1, Limit: 2}))
+		assert.Equal(t, []uint64{}, ids(repository.AuditFilter{Actor: "carol"}, repository.Page{}))
+	})
+
+	t.Run("BalanceFollowsPostedAndReversedTransactions", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		balance, err := repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, model.Balance{AccountId: account.AccountId, Balance: 0, Version: 1}, *balance)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50")},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("80")},
+		})
+		require.NoError(t, err)
+
+		reversed, err := repos.Transactions.ReverseTransaction(context.Background(), created[0].TransactionId)
+		require.NoError(t, err)
+		assert.True(t, reversed.Reversed)
+		assert.Equal(t, model.MustParseAmount("-50"), reversed.Amount)
+
+		balance, err = repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, model.Balance{AccountId: account.AccountId, Balance: 80, Version: 4}, *balance)
+
+		transactions, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, transactions, 2)
+		assert.True(t, transactions[0].Reversed)
+		assert.False(t, transactions[1].Reversed)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), created[0].TransactionId)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), 99)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repos.Accounts.FindBalance(99, time.Time{})
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+	})
+
+	t.Run("BlockedAccountsTakeNoMoreTransactions", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		posted, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		require.NoError(t, err)
+
+		blocked, err := repos.Accounts.BlockAccount(context.Background(), account.AccountId)
+		require.NoError(t, err)
+		assert.Equal(t, model.Account{AccountId: account.AccountId, DocumentNumber: 111, Currency: "BRL", Blocked: true}, *blocked)
+
+		found, err := repos.Accounts.FindAccount(account.AccountId)
+		require.NoError(t, err)
+		assert.True(t, found.Blocked)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		assert.ErrorIs(t, err, repository.ErrAccountBlocked)
+
+		_, err = repos.Accounts.BlockAccount(context.Background(), account.AccountId)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Accounts.BlockAccount(context.Background(), 99)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// The transactions of a blocked account can still be reversed.
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), posted.TransactionId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("CommandsFailWhenTheExpectedVersionIsStale", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		_, err = repos.Transactions.CreateTransaction(repository.WithExpectedVersion(context.Background(), 1), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		require.NoError(t, err)
+
+		// The account is now at version 2.
+		_, err = repos.Transactions.CreateTransaction(repository.WithExpectedVersion(context.Background(), 1), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+		_, err = repos.Accounts.BlockAccount(repository.WithExpectedVersion(context.Background(), 1), account.AccountId)
+		assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+		_, err = repos.Accounts.BlockAccount(repository.WithExpectedVersion(context.Background(), 2), account.AccountId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ListEventsReturnsTheStreams", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		for _, documentNumber := range []uint64{111, 222} {
+			_, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: documentNumber})
+			require.NoError(t, err)
+		}
+
+		transaction, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: 1, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		require.NoError(t, err)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), 1)
+		require.NoError(t, err)
+
+		events, err := repos.Events.ListEvents(repository.EventFilter{AccountId: 1}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+
+		assert.Equal(t, []uint64{1, 3, 4}, []uint64{events[0].EventId, events[1].EventId, events[2].EventId})
+		assert.Equal(t, []uint64{1, 2, 3}, []uint64{events[0].Version, events[1].Version, events[2].Version})
+		assert.Equal(t, model.EVENT_ACCOUNT_OPENED, events[0].Type)
+
+		// The databases also record the document number sealed, for the
+		// accounts to be projected again.
+		opened := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(events[0].Data, &opened))
+		assert.Equal(t, float64(1), opened["account_id"])
+		assert.Equal(t, "BRL", opened["currency"])
+		assert.NotContains(t, opened, "document_number")
+
+		assert.Equal(t, model.EVENT_TRANSACTION_POSTED, events[1].Type)
+		assert.Equal(t, uint64(1), events[1].TransactionId)
+		assert.JSONEq(t, `{"transaction_id":1,"account_id":1,"operation_type_id":4,"amount":10,"currency":"BRL","event_date":"`+formatTime(transaction.EventDate)+`","created_at":"`+formatTime(transaction.CreatedAt)+`"}`, string(events[1].Data))
+		assert.Equal(t, transaction.CreatedAt, events[1].CreatedAt)
+		assert.Equal(t, model.EVENT_TRANSACTION_REVERSED, events[2].Type)
+		assert.JSONEq(t, `{"transaction_id":1,"amount":10}`, string(events[2].Data))
+		assert.WithinDuration(t, time.Now(), events[2].CreatedAt, time.Minute)
+
+		page, err := repos.Events.ListEvents(repository.EventFilter{}, repository.Page{AfterId: 1, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, uint64(2), page[0].AccountId)
+		assert.Equal(t, uint64(3), page[1].EventId)
+	})
+
+	t.Run("ReplayRebuildsTheProjections", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-20")},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("50")},
+		})
+		require.NoError(t, err)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), created[0].TransactionId)
+		require.NoError(t, err)
+
+		_, err = repos.Accounts.BlockAccount(context.Background(), account.AccountId)
+		require.NoError(t, err)
+
+		before, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+
+		require.NoError(t, repos.Projections.ResetProjections())
+
+		_, err = repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// Every event is applied, whatever the batch size.
+		for {
+			applied, err := repos.Projections.ProjectEvents(2)
+			require.NoError(t, err)
+
+			if applied == 0 {
+				break
+			}
+		}
+
+		after, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+
+		balance, err := repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, model.Balance{AccountId: account.AccountId, Balance: 50, Blocked: true, Version: 5}, *balance)
+
+		found, err := repos.Accounts.FindAccount(account.AccountId)
+		require.NoError(t, err)
+		assert.True(t, found.Blocked)
+		assert.Equal(t, account.DocumentNumber, found.DocumentNumber)
+	})
+
+	t.Run("FindBalanceAsOfFoldsTheEventsBeforeIt", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		beforeOpening := time.Now()
+		time.Sleep(5 * time.Millisecond)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		// Enough transactions for the stream to be snapshotted.
+		payments := make([]model.Transaction, repository.BalanceSnapshotInterval+20)
+
+		for n := range payments {
+			payments[n] = model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("1")}
+		}
+
+		_, err = repos.Transactions.CreateTransactions(context.Background(), payments)
+		require.NoError(t, err)
+
+		time.Sleep(5 * time.Millisecond)
+		asOf := time.Now()
+		time.Sleep(5 * time.Millisecond)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("5")})
+		require.NoError(t, err)
+
+		_, err = repos.Accounts.BlockAccount(context.Background(), account.AccountId)
+		require.NoError(t, err)
+
+		_, err = repos.Accounts.FindBalance(account.AccountId, beforeOpening)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		for _, replay := range []bool{false, true} {
+			if replay {
+				require.NoError(t, repos.Projections.ResetProjections())
+
+				_, err := repos.Projections.ProjectEvents(1000)
+				require.NoError(t, err)
+			}
+
+			past, err := repos.Accounts.FindBalance(account.AccountId, asOf)
+			require.NoError(t, err)
+			assert.Equal(t, float64(len(payments)), past.Balance)
+			assert.Equal(t, uint64(len(payments)+1), past.Version)
+			assert.False(t, past.Blocked)
+			require.NotNil(t, past.AsOf)
+			assert.True(t, past.AsOf.Equal(asOf))
+
+			now, err := repos.Accounts.FindBalance(account.AccountId, time.Now().Add(time.Minute))
+			require.NoError(t, err)
+			assert.Equal(t, float64(len(payments)+5), now.Balance)
+			assert.Equal(t, uint64(len(payments)+3), now.Version)
+			assert.True(t, now.Blocked)
+
+			latest, err := repos.Accounts.FindBalance(account.AccountId, time.Time{})
+			require.NoError(t, err)
+			assert.Equal(t, now.Balance, latest.Balance)
+			assert.Equal(t, now.Version, latest.Version)
+			assert.Nil(t, latest.AsOf)
+		}
+	})
+
+	t.Run("TransactionsKeepTheirEventDate", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		eventDate := time.Date(2024, 2, 28, 23, 30, 0, 0, time.UTC)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), EventDate: eventDate},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("20")},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, eventDate, created[0].EventDate)
+		assert.WithinDuration(t, time.Now(), created[0].CreatedAt, time.Minute)
+		assert.Equal(t, created[1].CreatedAt, created[1].EventDate, "the event date defaults to the time of posting")
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, created, listed)
+	})
+
+	t.Run("ListTransactionsFiltersByOperationTypeAndEventDate", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.INTEREST, Amount: model.MustParseAmount("-1.5"), EventDate: day.Add(-time.Millisecond)},
+			{AccountId: account.AccountId, OperationTypeId: model.INTEREST, Amount: model.MustParseAmount("-2.5"), EventDate: day},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), EventDate: day.Add(time.Hour)},
+			{AccountId: account.AccountId, OperationTypeId: model.INTEREST, Amount: model.MustParseAmount("-3.5"), EventDate: day.AddDate(0, 0, 1)},
+		})
+		require.NoError(t, err)
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{
+			AccountId:       account.AccountId,
+			OperationTypeId: model.INTEREST,
+			EventDateFrom:   day,
+			EventDateTo:     day.AddDate(0, 0, 1),
+		}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Transaction{created[1]}, listed)
+
+		listed, err = repos.Transactions.ListTransactions(repository.TransactionFilter{EventDateFrom: day}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, created[1:], listed)
+	})
+
+	t.Run("SchedulesAreCreatedUpdatedAndDeleted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+		end := start.AddDate(1, 0, 0)
+
+		created, err := repos.Schedules.CreateSchedule(context.Background(), model.Schedule{
+			AccountId:       account.AccountId,
+			OperationTypeId: model.PAYMENT,
+			Amount:          model.MustParseAmount("100"),
+			Recurrence:      "0 9 1 * *",
+			StartDate:       start,
+			EndDate:         &end,
+			NextRunAt:       &start,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), created.ScheduleId)
+
+		found, err := repos.Schedules.FindSchedule(created.ScheduleId)
+		require.NoError(t, err)
+		assert.Equal(t, created, found)
+
+		_, err = repos.Schedules.CreateSchedule(context.Background(), model.Schedule{AccountId: 99, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("1"), Recurrence: "0 9 1 * *", StartDate: start})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		changed := *created
+		changed.Amount = model.MustParseAmount("150")
+		changed.EndDate = nil
+
+		updated, err := repos.Schedules.UpdateSchedule(context.Background(), changed)
+		require.NoError(t, err)
+		assert.Equal(t, &changed, updated)
+
+		listed, err := repos.Schedules.ListSchedules(repository.ScheduleFilter{AccountId: account.AccountId}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Schedule{changed}, listed)
+
+		listed, err = repos.Schedules.ListSchedules(repository.ScheduleFilter{AccountId: 99}, repository.Page{})
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+
+		require.NoError(t, repos.Schedules.DeleteSchedule(context.Background(), created.ScheduleId))
+
+		_, err = repos.Schedules.FindSchedule(created.ScheduleId)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		assert.ErrorIs(t, repos.Schedules.DeleteSchedule(context.Background(), created.ScheduleId), repository.ErrNotFound)
+
+		_, err = repos.Schedules.UpdateSchedule(context.Background(), changed)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_SCHEDULE}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		assert.Equal(t, model.AUDIT_ACTION_CREATE, entries[0].Action)
+		assert.Equal(t, model.AUDIT_ACTION_UPDATE, entries[1].Action)
+		assert.JSONEq(t, `{"schedule_id":1,"account_id":1,"operation_type_id":4,"amount":150,"recurrence":"0 9 1 * *","start_date":"2024-03-01T09:00:00Z","next_run_at":"2024-03-01T09:00:00Z"}`, string(entries[1].After))
+		assert.Equal(t, model.AUDIT_ACTION_DELETE, entries[2].Action)
+		assert.Equal(t, entries[1].After, entries[2].Before)
+		assert.JSONEq(t, "null", string(entries[2].After))
+	})
+
+	t.Run("DueSchedulesAreAdvancedOnce", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		later := now.Add(time.Hour)
+		earlier := now.Add(-time.Hour)
+
+		schedules := []*model.Schedule{}
+
+		for _, nextRunAt := range []*time.Time{&now, &later, nil, &earlier} {
+			schedule, err := repos.Schedules.CreateSchedule(context.Background(), model.Schedule{
+				AccountId:       account.AccountId,
+				OperationTypeId: model.PAYMENT,
+				Amount:          model.MustParseAmount("10"),
+				Recurrence:      "0 * * * *",
+				StartDate:       earlier,
+				NextRunAt:       nextRunAt,
+			})
+			require.NoError(t, err)
+
+			schedules = append(schedules, schedule)
+		}
+
+		due, err := repos.Schedules.ListDueSchedules(now, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []model.Schedule{*schedules[3], *schedules[0]}, due)
+
+		due, err = repos.Schedules.ListDueSchedules(now, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []model.Schedule{*schedules[3]}, due)
+
+		require.NoError(t, repos.Schedules.AdvanceSchedule(schedules[3].ScheduleId, earlier, &later))
+		assert.ErrorIs(t, repos.Schedules.AdvanceSchedule(schedules[3].ScheduleId, earlier, &later), repository.ErrConflict)
+
+		require.NoError(t, repos.Schedules.AdvanceSchedule(schedules[0].ScheduleId, now, nil))
+		assert.ErrorIs(t, repos.Schedules.AdvanceSchedule(schedules[2].ScheduleId, now, nil), repository.ErrConflict)
+
+		due, err = repos.Schedules.ListDueSchedules(later, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{schedules[1].ScheduleId, schedules[3].ScheduleId}, []uint64{due[0].ScheduleId, due[1].ScheduleId})
+		assert.Len(t, due, 2)
+	})
+
+	t.Run("PostingsWithAUsedDedupKeyPostNothing", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		ctx := repository.WithDedupKey(context.Background(), "schedule:1:1709294400")
+
+		created, err := repos.Transactions.CreateTransaction(ctx, model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		require.NoError(t, err)
+
+		_, err = repos.Transactions.CreateTransaction(ctx, model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10")})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("20")})
+		require.NoError(t, err)
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, listed, 2)
+		assert.Equal(t, *created, listed[0])
+		assert.Equal(t, model.MustParseAmount("20"), listed[1].Amount)
+	})
+
+	t.Run("FxRatesAreCreatedListedAndFound", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+		april := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+		created, err := repos.FxRates.CreateFxRates(context.Background(), []model.FxRate{
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 4.9876, EffectiveAt: march},
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5.0123, EffectiveAt: april},
+			{BaseCurrency: "EUR", QuoteCurrency: "BRL", Rate: 5.4, EffectiveAt: march},
+		})
+		require.NoError(t, err)
+		require.Len(t, created, 3)
+		assert.Equal(t, model.FxRate{FxRateId: 2, BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5.0123, EffectiveAt: april}, created[1])
+
+		listed, err := repos.FxRates.ListFxRates(repository.FxRateFilter{BaseCurrency: "USD"}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, created[:2], listed)
+
+		found, err := repos.FxRates.FindFxRate("USD", "BRL", april.Add(-time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, created[0], *found)
+
+		found, err = repos.FxRates.FindFxRate("USD", "BRL", april)
+		require.NoError(t, err)
+		assert.Equal(t, created[1], *found)
+
+		_, err = repos.FxRates.FindFxRate("USD", "BRL", march.Add(-time.Second))
+		assert.ErrorIs(t, err, repository.ErrFxRateNotFound)
+
+		// A rate of a pair at a time already taken stores nothing of the file.
+		_, err = repos.FxRates.CreateFxRates(context.Background(), []model.FxRate{
+			{BaseCurrency: "JPY", QuoteCurrency: "BRL", Rate: 0.0332, EffectiveAt: march},
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5, EffectiveAt: march},
+		})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		listed, err = repos.FxRates.ListFxRates(repository.FxRateFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, listed, 3)
+	})
+
+	t.Run("TransactionsInAnotherCurrencyAreConverted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+		assert.Equal(t, model.DEFAULT_CURRENCY, account.Currency)
+
+		march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+		rates, err := repos.FxRates.CreateFxRates(context.Background(), []model.FxRate{
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 4.9876, EffectiveAt: march},
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 6, EffectiveAt: march.AddDate(0, 1, 0)},
+		})
+		require.NoError(t, err)
+
+		eventDate := march.Add(36 * time.Hour)
+
+		created, err := repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-10.15"), OriginalAmount: model.MustParseAmount("-10.15"), OriginalCurrency: "USD", EventDate: eventDate},
+			{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("20"), OriginalAmount: model.MustParseAmount("20"), OriginalCurrency: "BRL"},
+		})
+		require.NoError(t, err)
+
+		converted := created[0]
+		assert.Equal(t, "BRL", converted.Currency)
+		assert.Equal(t, model.MustParseAmount("-50.62"), converted.Amount)
+		assert.Equal(t, model.MustParseAmount("-10.15"), converted.OriginalAmount)
+		assert.Equal(t, "USD", converted.OriginalCurrency)
+		assert.Equal(t, 4.9876, converted.FxRate)
+		assert.Equal(t, rates[0].FxRateId, converted.FxRateId)
+
+		assert.Equal(t, model.Transaction{TransactionId: created[1].TransactionId, AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Currency: "BRL", Amount: model.MustParseAmount("20"), EventDate: created[1].EventDate, CreatedAt: created[1].CreatedAt}, created[1])
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, created, listed)
+
+		// The projections rebuilt from the events keep the rate used.
+		require.NoError(t, repos.Projections.ResetProjections())
+
+		_, err = repos.Projections.ProjectEvents(100)
+		require.NoError(t, err)
+
+		listed, err = repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, created, listed)
+	})
+
+	t.Run("TransactionsRespectTheMinorUnitsOfTheirCurrency", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		yen, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111, Currency: "JPY"})
+		require.NoError(t, err)
+
+		dinar, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222, Currency: "BHD"})
+		require.NoError(t, err)
+
+		found, err := repos.Accounts.FindAccount(yen.AccountId)
+		require.NoError(t, err)
+		assert.Equal(t, "JPY", found.Currency)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: yen.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10.5")})
+		assert.ErrorIs(t, err, repository.ErrInvalidAmount)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: dinar.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("1.2345")})
+		assert.ErrorIs(t, err, repository.ErrInvalidAmount)
+
+		created, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: dinar.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("1.234")})
+		require.NoError(t, err)
+		assert.Equal(t, model.MustParseAmount("1.234"), created.Amount)
+
+		_, err = repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: yen.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-10"), OriginalAmount: model.MustParseAmount("-10"), OriginalCurrency: "USD"})
+		assert.ErrorIs(t, err, repository.ErrFxRateNotFound)
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, listed, 1)
+	})
+
+	t.Run("CardsAreIssuedBlockedAndReplaced", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		_, err = repos.Cards.CreateCard(context.Background(), model.Card{AccountId: 99, PanToken: "tok_0", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		card, err := repos.Cards.CreateCard(context.Background(), model.Card{AccountId: account.AccountId, PanToken: "tok_1", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_PHYSICAL, TransactionLimit: model.MustParseAmount("100"), DailyLimit: model.MustParseAmount("250.5")})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), card.CardId)
+
+		found, err := repos.Cards.FindCard(card.CardId)
+		require.NoError(t, err)
+		assert.Equal(t, *card, *found)
+
+		blocked, err := repos.Cards.UpdateCardStatus(context.Background(), card.CardId, []string{model.CARD_STATUS_ACTIVE}, model.CARD_STATUS_BLOCKED)
+		require.NoError(t, err)
+		assert.Equal(t, model.CARD_STATUS_BLOCKED, blocked.Status)
+
+		_, err = repos.Cards.UpdateCardStatus(context.Background(), card.CardId, []string{model.CARD_STATUS_ACTIVE}, model.CARD_STATUS_BLOCKED)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Cards.UpdateCardStatus(context.Background(), 99, []string{model.CARD_STATUS_ACTIVE}, model.CARD_STATUS_BLOCKED)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		limited, err := repos.Cards.UpdateCardLimits(context.Background(), card.CardId, model.MustParseAmount("50"), model.Amount{})
+		require.NoError(t, err)
+		assert.Equal(t, model.MustParseAmount("50"), limited.TransactionLimit)
+		assert.Equal(t, model.MustParseAmount("0"), limited.DailyLimit)
+
+		replacement, err := repos.Cards.ReplaceCard(context.Background(), card.CardId, model.Card{PanToken: "tok_2", LastFour: "2222", ExpiryMonth: 6, ExpiryYear: 2100, Status: model.CARD_STATUS_ACTIVE})
+		require.NoError(t, err)
+		assert.Equal(t, model.Card{CardId: 2, AccountId: account.AccountId, PanToken: "tok_2", LastFour: "2222", ExpiryMonth: 6, ExpiryYear: 2100, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_PHYSICAL, TransactionLimit: model.MustParseAmount("50"), CreatedAt: replacement.CreatedAt}, *replacement)
+
+		_, err = repos.Cards.ReplaceCard(context.Background(), card.CardId, model.Card{PanToken: "tok_3", LastFour: "3333", ExpiryMonth: 6, ExpiryYear: 2100, Status: model.CARD_STATUS_ACTIVE})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		listed, err := repos.Cards.ListCards(account.AccountId, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, listed, 2)
+		assert.Equal(t, model.CARD_STATUS_REPLACED, listed[0].Status)
+		assert.Equal(t, replacement.CardId, listed[0].ReplacedBy)
+		assert.Equal(t, *replacement, listed[1])
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_CARD}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 5)
+	})
+
+	t.Run("TransactionsRespectTheSpendControlsOfTheirCard", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		other, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		card, err := repos.Cards.CreateCard(context.Background(), model.Card{AccountId: account.AccountId, PanToken: "tok_1", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL, TransactionLimit: model.MustParseAmount("100"), DailyLimit: model.MustParseAmount("150")})
+		require.NoError(t, err)
+
+		expired, err := repos.Cards.CreateCard(context.Background(), model.Card{AccountId: account.AccountId, PanToken: "tok_2", LastFour: "2222", ExpiryMonth: 1, ExpiryYear: 2020, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL})
+		require.NoError(t, err)
+
+		day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+		purchase := func(accountId uint64, cardId uint64, amount string, eventDate time.Time) (*model.Transaction, error) {
+			return repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: accountId, CardId: cardId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount), EventDate: eventDate})
+		}
+
+		_, err = purchase(other.AccountId, card.CardId, "-10", day)
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		_, err = purchase(account.AccountId, 99, "-10", day)
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		_, err = purchase(account.AccountId, expired.CardId, "-10", day)
+		assert.ErrorIs(t, err, repository.ErrCardInactive)
+
+		_, err = purchase(account.AccountId, card.CardId, "-100.01", day)
+		assert.ErrorIs(t, err, repository.ErrCardLimitExceeded)
+
+		first, err := purchase(account.AccountId, card.CardId, "-100", day)
+		require.NoError(t, err)
+		assert.Equal(t, card.CardId, first.CardId)
+
+		_, err = purchase(account.AccountId, card.CardId, "-50.01", day.Add(time.Hour))
+		assert.ErrorIs(t, err, repository.ErrCardLimitExceeded)
+
+		// Spend of a batch counts towards the daily limit along with the
+		// earlier one, and reversed purchases no longer count.
+		_, err = repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, CardId: card.CardId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-30"), EventDate: day},
+			{AccountId: account.AccountId, CardId: card.CardId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-30"), EventDate: day},
+		})
+		assert.ErrorIs(t, err, repository.ErrCardLimitExceeded)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), first.TransactionId)
+		require.NoError(t, err)
+
+		_, err = purchase(account.AccountId, card.CardId, "-100", day.Add(time.Hour))
+		require.NoError(t, err)
+
+		_, err = purchase(account.AccountId, card.CardId, "-100", day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+
+		// Payments are taken by blocked cards.
+		_, err = repos.Cards.UpdateCardStatus(context.Background(), card.CardId, []string{model.CARD_STATUS_ACTIVE}, model.CARD_STATUS_BLOCKED)
+		require.NoError(t, err)
+
+		_, err = purchase(account.AccountId, card.CardId, "-1", day.AddDate(0, 0, 2))
+		assert.ErrorIs(t, err, repository.ErrCardInactive)
+
+		payment, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, CardId: card.CardId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("500")})
+		require.NoError(t, err)
+
+		// The card is kept by the projections rebuilt from the events.
+		require.NoError(t, repos.Projections.ResetProjections())
+
+		_, err = repos.Projections.ProjectEvents(100)
+		require.NoError(t, err)
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, listed, 4)
+		assert.Equal(t, *payment, listed[3])
+	})
+
+	t.Run("SpendRulesAreCreatedListedAndDeleted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		_, err = repos.SpendRules.CreateSpendRule(context.Background(), model.SpendRule{AccountId: 99, Type: model.SPEND_RULE_DENY, Mcc: "7995"})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		deny, err := repos.SpendRules.CreateSpendRule(context.Background(), model.SpendRule{AccountId: account.AccountId, Type: model.SPEND_RULE_DENY, Mcc: "7995"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), deny.SpendRuleId)
+
+		_, err = repos.SpendRules.CreateSpendRule(context.Background(), model.SpendRule{AccountId: account.AccountId, Type: model.SPEND_RULE_DENY, Mcc: "7995"})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		capped, err := repos.SpendRules.CreateSpendRule(context.Background(), model.SpendRule{AccountId: account.AccountId, Type: model.SPEND_RULE_CAP, Category: model.MERCHANT_CATEGORY_RESTAURANTS, Limit: model.MustParseAmount("250.5"), Period: model.SPEND_PERIOD_MONTH})
+		require.NoError(t, err)
+
+		listed, err := repos.SpendRules.ListSpendRules(account.AccountId, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.SpendRule{*deny, *capped}, listed)
+
+		require.NoError(t, repos.SpendRules.DeleteSpendRule(context.Background(), deny.SpendRuleId))
+
+		assert.ErrorIs(t, repos.SpendRules.DeleteSpendRule(context.Background(), deny.SpendRuleId), repository.ErrNotFound)
+
+		listed, err = repos.SpendRules.ListSpendRules(account.AccountId, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.SpendRule{*capped}, listed)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_SPEND_RULE}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+
+	t.Run("TransactionsRespectTheSpendRulesOfTheirAccount", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+		purchase := func(mcc string, amount string, eventDate time.Time) (*model.Transaction, error) {
+			return repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount), EventDate: eventDate, MerchantName: "Cantina", MerchantId: "m-1", Mcc: mcc, MerchantCountry: "BR"})
+		}
+
+		for _, rule := range []model.SpendRule{
+			{AccountId: account.AccountId, Type: model.SPEND_RULE_DENY, Mcc: "5813"},
+			{AccountId: account.AccountId, Type: model.SPEND_RULE_ALLOW, Category: model.MERCHANT_CATEGORY_RESTAURANTS},
+			{AccountId: account.AccountId, Type: model.SPEND_RULE_ALLOW, Mcc: "5411"},
+			{AccountId: account.AccountId, Type: model.SPEND_RULE_CAP, Category: model.MERCHANT_CATEGORY_RESTAURANTS, Limit: model.MustParseAmount("100"), Period: model.SPEND_PERIOD_DAY},
+		} {
+			_, err = repos.SpendRules.CreateSpendRule(context.Background(), rule)
+			require.NoError(t, err)
+		}
+
+		_, err = purchase("5813", "-10", day)
+		assert.ErrorIs(t, err, repository.ErrMerchantDenied)
+
+		_, err = purchase("5541", "-10", day)
+		assert.ErrorIs(t, err, repository.ErrMerchantNotAllowed)
+
+		_, err = purchase("", "-10", day)
+		assert.ErrorIs(t, err, repository.ErrMerchantNotAllowed)
+
+		_, err = purchase("5812", "-100.01", day)
+		assert.ErrorIs(t, err, repository.ErrCategoryCapExceeded)
+
+		first, err := purchase("5812", "-60", day)
+		require.NoError(t, err)
+		assert.Equal(t, model.MERCHANT_CATEGORY_RESTAURANTS, first.Category)
+		assert.Equal(t, "Cantina", first.MerchantName)
+
+		groceries, err := purchase("5411", "-500", day)
+		require.NoError(t, err)
+		assert.Equal(t, model.MERCHANT_CATEGORY_GROCERIES, groceries.Category)
+
+		// Spend of a batch counts towards the cap along with the earlier
+		// one, and reversed purchases no longer count.
+		_, err = repos.Transactions.CreateTransactions(context.Background(), []model.Transaction{
+			{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-20"), EventDate: day, Mcc: "5814"},
+			{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-20.01"), EventDate: day, Mcc: "5812"},
+		})
+		assert.ErrorIs(t, err, repository.ErrCategoryCapExceeded)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), first.TransactionId)
+		require.NoError(t, err)
+
+		_, err = purchase("5812", "-100", day.Add(time.Hour))
+		require.NoError(t, err)
+
+		_, err = purchase("5812", "-100", day.AddDate(0, 0, 1))
+		require.NoError(t, err)
+
+		// Payments are not made at merchants, so no rule applies to them.
+		payment, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("1000")})
+		require.NoError(t, err)
+
+		// The merchant is kept by the projections rebuilt from the events.
+		require.NoError(t, repos.Projections.ResetProjections())
+
+		_, err = repos.Projections.ProjectEvents(100)
+		require.NoError(t, err)
+
+		listed, err := repos.Transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, listed, 5)
+		assert.Equal(t, *groceries, listed[1])
+		assert.Equal(t, *payment, listed[4])
+	})
+
+	t.Run("RiskRulesAreCreatedListedAndDeleted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		velocity, err := repos.Risk.CreateRiskRule(context.Background(), model.RiskRule{Name: "velocity", Expression: "transactions_this_minute > 5", Action: model.RISK_OUTCOME_DECLINE})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), velocity.RiskRuleId)
+		assert.False(t, velocity.CreatedAt.IsZero())
+
+		_, err = repos.Risk.CreateRiskRule(context.Background(), model.RiskRule{Name: "velocity", Expression: "amount > 1", Action: model.RISK_OUTCOME_REVIEW})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		country, err := repos.Risk.CreateRiskRule(context.Background(), model.RiskRule{Name: "country", Expression: `last_country != "" && country != last_country`, Action: model.RISK_OUTCOME_REVIEW})
+		require.NoError(t, err)
+
+		listed, err := repos.Risk.ListRiskRules(repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.RiskRule{*velocity, *country}, listed)
+
+		listed, err = repos.Risk.ListRiskRules(repository.Page{AfterId: velocity.RiskRuleId})
+		require.NoError(t, err)
+		assert.Equal(t, []model.RiskRule{*country}, listed)
+
+		require.NoError(t, repos.Risk.DeleteRiskRule(context.Background(), velocity.RiskRuleId))
+
+		assert.ErrorIs(t, repos.Risk.DeleteRiskRule(context.Background(), velocity.RiskRuleId), repository.ErrNotFound)
+
+		listed, err = repos.Risk.ListRiskRules(repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.RiskRule{*country}, listed)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_RISK_RULE}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+
+	t.Run("RiskDecisionsAreRecordedAndCounted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		other, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222})
+		require.NoError(t, err)
+
+		// decideAs records a decision with outcome, keeping the counters it
+		// was given and when it was made.
+		var counters model.RiskCounters
+		var madeAt time.Time
+
+		decideAs := func(outcome string, decision model.RiskDecision) *model.RiskDecision {
+			recorded, err := repos.Risk.DecideRisk(context.Background(), decision, func(decision model.RiskDecision, given model.RiskCounters) (string, []string, error) {
+				counters, madeAt = given, decision.CreatedAt
+
+				return outcome, []string{"rule-" + outcome}, nil
+			})
+			require.NoError(t, err)
+
+			return recorded
+		}
+
+		// post posts a transaction let through, which is what moves the
+		// counters.
+		post := func(ctx context.Context, transaction model.Transaction) error {
+			_, err := repos.Transactions.CreateTransaction(ctx, transaction)
+
+			return err
+		}
+
+		deduped := repository.WithDedupKey(context.Background(), "risk:1")
+
+		first := decideAs(model.RISK_OUTCOME_APPROVE, model.RiskDecision{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-40.5"), MerchantCountry: "BR"})
+		assert.Equal(t, uint64(1), first.RiskDecisionId)
+		assert.Equal(t, model.RISK_OUTCOME_APPROVE, first.Outcome)
+		assert.Equal(t, []string{"rule-approve"}, first.Rules)
+		assert.Equal(t, model.MustParseAmount("-40.5"), first.Amount)
+		assert.Equal(t, madeAt, first.CreatedAt)
+		assert.Equal(t, 1, counters.TransactionsMinute)
+		assert.Equal(t, 40.5, counters.SpentDay)
+		assert.Equal(t, "", counters.LastCountry)
+		assert.False(t, counters.OpenedAt.After(first.CreatedAt))
+		assert.False(t, counters.OpenedAt.IsZero())
+		require.NoError(t, post(deduped, model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-40.5"), MerchantCountry: "BR"}))
+
+		// Declined transactions are recorded, but not posted nor counted.
+		firstAt := madeAt
+		declined := decideAs(model.RISK_OUTCOME_DECLINE, model.RiskDecision{AccountId: account.AccountId, OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-1000"), MerchantCountry: "US"})
+		assert.Equal(t, "BR", counters.LastCountry)
+
+		// Payments are counted, but spend nothing.
+		decideAs(model.RISK_OUTCOME_REVIEW, model.RiskDecision{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("100")})
+		assert.Equal(t, "BR", counters.LastCountry)
+		require.NoError(t, post(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("100")}))
+
+		// A transaction that fails to post, here turned down by its dedup
+		// key, counts nothing.
+		assert.ErrorIs(t, post(deduped, model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-40.5")}), repository.ErrConflict)
+
+		// A transaction made in another currency is counted in the billing
+		// currency of the account.
+		_, err = repos.FxRates.CreateFxRates(context.Background(), []model.FxRate{
+			{BaseCurrency: "USD", QuoteCurrency: "BRL", Rate: 5, EffectiveAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		})
+		require.NoError(t, err)
+		require.NoError(t, post(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-10"), OriginalAmount: model.MustParseAmount("-10"), OriginalCurrency: "USD"}))
+
+		decideAs(model.RISK_OUTCOME_APPROVE, model.RiskDecision{AccountId: account.AccountId, OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-9.5"), MerchantCountry: "AR"})
+		require.NoError(t, post(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-9.5"), MerchantCountry: "AR"}))
+
+		// The counters restart with every minute and day, which the test
+		// may run across.
+		if risk.MinuteOf(madeAt).Equal(risk.MinuteOf(firstAt)) {
+			assert.Equal(t, 4, counters.TransactionsMinute)
+		}
+
+		if risk.DayOf(madeAt).Equal(risk.DayOf(firstAt)) {
+			assert.Equal(t, 100.0, counters.SpentDay)
+		}
+
+		decideAs(model.RISK_OUTCOME_APPROVE, model.RiskDecision{AccountId: other.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-1")})
+		assert.Equal(t, 1, counters.TransactionsMinute)
+		assert.Equal(t, 1.0, counters.SpentDay)
+		assert.Equal(t, "", counters.LastCountry)
+
+		decideAs(model.RISK_OUTCOME_APPROVE, model.RiskDecision{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-1")})
+		assert.Equal(t, "AR", counters.LastCountry)
+
+		_, err = repos.Risk.DecideRisk(context.Background(), model.RiskDecision{AccountId: 99, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-1")}, func(model.RiskDecision, model.RiskCounters) (string, []string, error) {
+			return model.RISK_OUTCOME_APPROVE, []string{}, nil
+		})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		listed, err := repos.Risk.ListRiskDecisions(repository.RiskDecisionFilter{}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, listed, 6)
+		assert.Equal(t, *first, listed[0])
+
+		listed, err = repos.Risk.ListRiskDecisions(repository.RiskDecisionFilter{AccountId: account.AccountId, Outcome: model.RISK_OUTCOME_DECLINE}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.RiskDecision{*declined}, listed)
+
+		listed, err = repos.Risk.ListRiskDecisions(repository.RiskDecisionFilter{AccountId: other.AccountId}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, uint64(5), listed[0].RiskDecisionId)
+
+		listed, err = repos.Risk.ListRiskDecisions(repository.RiskDecisionFilter{AccountId: account.AccountId}, repository.Page{AfterId: 4, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, listed, 1)
+		assert.Equal(t, uint64(6), listed[0].RiskDecisionId)
+	})
+
+	t.Run("DisputesFollowTheirWorkflow", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		purchase, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50")})
+		require.NoError(t, err)
+
+		payment, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("100")})
+		require.NoError(t, err)
+
+		_, err = repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: 99, Reason: "not received"})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		_, err = repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: payment.TransactionId, Reason: "not received"})
+		assert.ErrorIs(t, err, repository.ErrNotDisputable)
+
+		_, err = repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: purchase.TransactionId, Amount: model.MustParseAmount("60"), Reason: "not received"})
+		assert.ErrorIs(t, err, repository.ErrNotDisputable)
+
+		opened, err := repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: purchase.TransactionId, Amount: model.MustParseAmount("30"), Reason: "not received"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), opened.DisputeId)
+		assert.Equal(t, account.AccountId, opened.AccountId)
+		assert.Equal(t, model.MustParseAmount("30"), opened.Amount)
+		assert.Equal(t, model.DISPUTE_STATUS_OPENED, opened.Status)
+		assert.Equal(t, model.DisputeDeadline(model.DISPUTE_STATUS_OPENED, opened.CreatedAt), opened.DeadlineAt)
+
+		_, err = repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: purchase.TransactionId, Reason: "twice"})
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		found, err := repos.Disputes.FindDispute(opened.DisputeId)
+		require.NoError(t, err)
+		assert.Equal(t, *opened, *found)
+
+		_, err = repos.Disputes.FindDispute(99)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repos.Disputes.TransitionDispute(context.Background(), 99, model.DISPUTE_STATUS_CREDITED, "")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		_, err = repos.Disputes.TransitionDispute(context.Background(), opened.DisputeId, model.DISPUTE_STATUS_UNDER_REVIEW, "")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		credited, err := repos.Disputes.TransitionDispute(context.Background(), opened.DisputeId, model.DISPUTE_STATUS_CREDITED, "")
+		require.NoError(t, err)
+		assert.Equal(t, model.DISPUTE_STATUS_CREDITED, credited.Status)
+		assert.NotZero(t, credited.CreditTransactionId)
+		assert.Equal(t, model.DisputeDeadline(model.DISPUTE_STATUS_CREDITED, credited.UpdatedAt), credited.DeadlineAt)
+
+		credits, err := repos.Transactions.ListTransactions(repository.TransactionFilter{OperationTypeId: model.DISPUTE_CREDIT}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, credits, 1)
+		assert.Equal(t, credited.CreditTransactionId, credits[0].TransactionId)
+		assert.Equal(t, model.MustParseAmount("30"), credits[0].Amount)
+
+		balance, err := repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, 80.0, balance.Balance)
+
+		_, err = repos.Disputes.TransitionDispute(context.Background(), opened.DisputeId, model.DISPUTE_STATUS_UNDER_REVIEW, "sent to the network")
+		require.NoError(t, err)
+
+		lost, err := repos.Disputes.TransitionDispute(context.Background(), opened.DisputeId, model.DISPUTE_STATUS_LOST, "delivery proven")
+		require.NoError(t, err)
+		assert.Equal(t, model.DISPUTE_STATUS_LOST, lost.Status)
+		assert.Nil(t, lost.DeadlineAt)
+		assert.Equal(t, credited.CreditTransactionId, lost.CreditTransactionId)
+
+		_, err = repos.Disputes.TransitionDispute(context.Background(), opened.DisputeId, model.DISPUTE_STATUS_WON, "")
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		found, err = repos.Disputes.FindDispute(opened.DisputeId)
+		require.NoError(t, err)
+		assert.Equal(t, *lost, *found)
+
+		balance, err = repos.Accounts.FindBalance(account.AccountId, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, 50.0, balance.Balance)
+
+		changes, err := repos.Disputes.ListDisputeChanges(opened.DisputeId, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, changes, 4)
+
+		statuses := []string{}
+
+		for _, change := range changes {
+			statuses = append(statuses, change.Status)
+		}
+
+		assert.Equal(t, []string{model.DISPUTE_STATUS_OPENED, model.DISPUTE_STATUS_CREDITED, model.DISPUTE_STATUS_UNDER_REVIEW, model.DISPUTE_STATUS_LOST}, statuses)
+		assert.Equal(t, "delivery proven", changes[3].Note)
+		assert.Equal(t, lost.UpdatedAt, changes[3].CreatedAt)
+
+		changes, err = repos.Disputes.ListDisputeChanges(opened.DisputeId, repository.Page{AfterId: changes[2].DisputeChangeId})
+		require.NoError(t, err)
+		assert.Len(t, changes, 1)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_DISPUTE}, repository.Page{})
+		require.NoError(t, err)
+		assert.Len(t, entries, 4)
+
+		// Reversed transactions cannot be disputed.
+		reversed, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.WITHDRAW, Amount: model.MustParseAmount("-20")})
+		require.NoError(t, err)
+
+		_, err = repos.Transactions.ReverseTransaction(context.Background(), reversed.TransactionId)
+		require.NoError(t, err)
+
+		_, err = repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: reversed.TransactionId, Reason: "not received"})
+		assert.ErrorIs(t, err, repository.ErrNotDisputable)
+	})
+
+	t.Run("DisputesAreListedAndEscalated", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		account, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+		require.NoError(t, err)
+
+		disputes := []model.Dispute{}
+
+		for _, amount := range []string{"-10", "-20"} {
+			transaction, err := repos.Transactions.CreateTransaction(context.Background(), model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount(amount)})
+			require.NoError(t, err)
+
+			opened, err := repos.Disputes.OpenDispute(context.Background(), model.Dispute{TransactionId: transaction.TransactionId, Reason: "duplicate"})
+			require.NoError(t, err)
+			assert.Equal(t, model.MustParseAmount(amount).Neg(), opened.Amount)
+
+			disputes = append(disputes, *opened)
+		}
+
+		now := disputes[1].CreatedAt
+		later := now.Add(model.DisputeDeadlines[model.DISPUTE_STATUS_OPENED])
+
+		overdue, err := repos.Disputes.ListOverdueDisputes(now, 10)
+		require.NoError(t, err)
+		assert.Empty(t, overdue)
+
+		overdue, err = repos.Disputes.ListOverdueDisputes(later, 10)
+		require.NoError(t, err)
+		assert.Equal(t, disputes, overdue)
+
+		_, err = repos.Disputes.EscalateDispute(context.Background(), disputes[0].DisputeId, now)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		escalated, err := repos.Disputes.EscalateDispute(context.Background(), disputes[0].DisputeId, later)
+		require.NoError(t, err)
+		assert.True(t, escalated.Escalated)
+		assert.Equal(t, disputes[0].DeadlineAt, escalated.DeadlineAt)
+
+		_, err = repos.Disputes.EscalateDispute(context.Background(), disputes[0].DisputeId, later)
+		assert.ErrorIs(t, err, repository.ErrConflict)
+
+		_, err = repos.Disputes.EscalateDispute(context.Background(), 99, later)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		overdue, err = repos.Disputes.ListOverdueDisputes(later, 10)
+		require.NoError(t, err)
+		assert.Equal(t, disputes[1:], overdue)
+
+		listed, err := repos.Disputes.ListDisputes(repository.DisputeFilter{Escalated: true}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Dispute{*escalated}, listed)
+
+		// Moving on clears the escalation.
+		credited, err := repos.Disputes.TransitionDispute(context.Background(), disputes[0].DisputeId, model.DISPUTE_STATUS_CREDITED, "")
+		require.NoError(t, err)
+		assert.False(t, credited.Escalated)
+
+		listed, err = repos.Disputes.ListDisputes(repository.DisputeFilter{AccountId: account.AccountId, Status: model.DISPUTE_STATUS_CREDITED}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Dispute{*credited}, listed)
+
+		listed, err = repos.Disputes.ListDisputes(repository.DisputeFilter{AccountId: 99}, repository.Page{})
+		require.NoError(t, err)
+		assert.Empty(t, listed)
+
+		listed, err = repos.Disputes.ListDisputes(repository.DisputeFilter{}, repository.Page{AfterId: disputes[0].DisputeId})
+		require.NoError(t, err)
+		assert.Equal(t, disputes[1:], listed)
+
+		changes, err := repos.Disputes.ListDisputeChanges(disputes[0].DisputeId, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		assert.Equal(t, model.DISPUTE_STATUS_OPENED, changes[1].Status)
+		assert.True(t, changes[1].Escalated)
+		assert.False(t, changes[2].Escalated)
+	})
+
+	t.Run("HoldersAreCreatedUpdatedAndDeleted", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		alice := model.Holder{Name: "Alice", BirthDate: "1990-02-28", Email: "alice@example.com", Phone: "+5511987654321", Address: model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", State: "SP", PostalCode: "01310-100", Country: "BR"}}
+
+		created, err := repos.Holders.CreateHolder(context.Background(), alice)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), created.HolderId)
+		assert.False(t, created.CreatedAt.IsZero())
+		assert.Equal(t, created.CreatedAt, created.UpdatedAt)
+
+		found, err := repos.Holders.FindHolder(created.HolderId)
+		require.NoError(t, err)
+		assert.Equal(t, *created, *found)
+
+		_, err = repos.Holders.FindHolder(99)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		bob, err := repos.Holders.CreateHolder(context.Background(), model.Holder{Name: "Bob", BirthDate: "1985-07-01", Email: "bob@example.com", Phone: "+14155550100", Address: model.Address{Street: "Market St", Number: "1", City: "San Francisco", PostalCode: "94105", Country: "US"}})
+		require.NoError(t, err)
+
+		listed, err := repos.Holders.ListHolders(repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Holder{*created, *bob}, listed)
+
+		listed, err = repos.Holders.ListHolders(repository.Page{AfterId: created.HolderId})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Holder{*bob}, listed)
+
+		alice.HolderId = created.HolderId
+		alice.Email = "alice@example.org"
+
+		updated, err := repos.Holders.UpdateHolder(context.Background(), alice)
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.org", updated.Email)
+		assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+		found, err = repos.Holders.FindHolder(created.HolderId)
+		require.NoError(t, err)
+		assert.Equal(t, *updated, *found)
+
+		_, err = repos.Holders.UpdateHolder(context.Background(), model.Holder{HolderId: 99, Name: "Nobody"})
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		require.NoError(t, repos.Holders.DeleteHolder(context.Background(), bob.HolderId))
+
+		assert.ErrorIs(t, repos.Holders.DeleteHolder(context.Background(), bob.HolderId), repository.ErrNotFound)
+
+		entries, err := repos.Audit.ListAuditEntries(repository.AuditFilter{EntityType: model.AUDIT_ENTITY_HOLDER}, repository.Page{})
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+
+		// The audit log keeps no personal data.
+		for _, entry := range entries {
+			assert.NotContains(t, string(entry.Before), "alice")
+			assert.NotContains(t, string(entry.After), "alice")
+			assert.NotContains(t, string(entry.Before), "Bob")
+			assert.NotContains(t, string(entry.After), "Bob")
+		}
+	})
+
+	t.Run("HoldersOwnAccountsAndHoldCards", func(t *testing.T) {
+		repos := newRepositories(t)
+
+		owner, err := repos.Holders.CreateHolder(context.Background(), model.Holder{Name: "Alice", BirthDate: "1990-02-28", Email: "alice@example.com", Phone: "+5511987654321", Address: model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", PostalCode: "01310-100", Country: "BR"}})
+		require.NoError(t, err)
+
+		additional, err := repos.Holders.CreateHolder(context.Background(), model.Holder{Name: "Bob", BirthDate: "2005-07-01", Email: "bob@example.com", Phone: "+5511912345678", Address: model.Address{Street: "Av. Paulista", Number: "1000", City: "Sao Paulo", PostalCode: "01310-100", Country: "BR"}})
+		require.NoError(t, err)
+
+		_, err = repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111, HolderId: 99})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		first, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111, HolderId: owner.HolderId})
+		require.NoError(t, err)
+		assert.Equal(t, owner.HolderId, first.HolderId)
+
+		second, err := repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 222, HolderId: owner.HolderId})
+		require.NoError(t, err)
+
+		_, err = repos.Accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 333})
+		require.NoError(t, err)
+
+		found, err := repos.Accounts.FindAccount(first.AccountId)
+		require.NoError(t, err)
+		assert.Equal(t, *first, *found)
+
+		accounts, err := repos.Accounts.ListAccounts(repository.AccountFilter{HolderId: owner.HolderId}, repository.Page{})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Account{*first, *second}, accounts)
+
+		_, err = repos.Cards.CreateCard(context.Background(), model.Card{AccountId: first.AccountId, HolderId: 99, PanToken: "tok_0", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL})
+		assert.ErrorIs(t, err, repository.ErrForeignKeyViolation)
+
+		card, err := repos.Cards.CreateCard(context.Background(), model.Card{AccountId: first.AccountId, HolderId: additional.HolderId, PanToken: "tok_1", LastFour: "1111", ExpiryMonth: 12, ExpiryYear: 2099, Status: model.CARD_STATUS_ACTIVE, Type: model.CARD_TYPE_VIRTUAL})
+		require.NoError(t, err)
+		assert.Equal(t, additional.HolderId, card.HolderId)
+
+		replacement, err := repos.Cards.ReplaceCard(context.Background(), card.CardId, model.Card{PanToken: "tok_2", LastFour: "2222", ExpiryMonth: 6, ExpiryYear: 2100, Status: model.CARD_STATUS_ACTIVE})
+		require.NoError(t, err)
+		assert.Equal(t, additional.HolderId, replacement.HolderId)
+
+		assert.ErrorIs(t, repos.Holders.DeleteHolder(context.Background(), owner.HolderId), repository.ErrConflict)
+		assert.ErrorIs(t, repos.Holders.DeleteHolder(context.Background(), additional.HolderId), repository.ErrConflict)
+
+		_, err = repos.Holders.FindHolder(owner.HolderId)
+		assert.NoError(t, err)
+	})
+}
+
+// formatTime formats t the way encoding/json does.
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
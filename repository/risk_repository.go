@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type RiskDecisionFilter struct {
+	AccountId uint64
+	Outcome   string
+}
+
+// RiskDecider returns the outcome of the risk rules for decision, made at
+// its CreatedAt, and the names of the rules that fired, given the counters
+// of its account. The counters include the transaction of decision already.
+type RiskDecider func(decision model.RiskDecision, counters model.RiskCounters) (string, []string, error)
+
+// RiskRepository stores the risk rules, recording every change in the audit
+// log, and the decisions made with them along with the counters they are
+// evaluated against.
+type RiskRepository interface {
+	// CreateRiskRule returns ErrConflict when a rule has the same name.
+	CreateRiskRule(ctx context.Context, rule model.RiskRule) (*model.RiskRule, error)
+	ListRiskRules(page Page) ([]model.RiskRule, error)
+	// DeleteRiskRule returns ErrNotFound when the rule does not exist.
+	DeleteRiskRule(ctx context.Context, riskRuleId uint64) error
+	// DecideRisk reads the counters of the account of decision, decides on
+	// it with decide and records it, counting its transaction unless it is
+	// declined. Decisions on the same account are made one at a time. It
+	// returns ErrForeignKeyViolation when the account does not exist.
+	DecideRisk(ctx context.Context, decision model.RiskDecision, decide RiskDecider) (*model.RiskDecision, error)
+	ListRiskDecisions(filter RiskDecisionFilter, page Page) ([]model.RiskDecision, error)
+}
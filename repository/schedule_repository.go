@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// ScheduleFilter narrows ListSchedules, zero fields are ignored.
+type ScheduleFilter struct {
+	AccountId uint64
+}
+
+// ScheduleRepository records the changes made to the schedules in the audit
+// log like the other repositories do, but not their runs, which are
+// recorded by the transactions they post.
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error)
+	FindSchedule(scheduleId uint64) (*model.Schedule, error)
+	ListSchedules(filter ScheduleFilter, page Page) ([]model.Schedule, error)
+	// UpdateSchedule replaces every field of the schedule but its ID. It
+	// returns ErrNotFound when the schedule does not exist.
+	UpdateSchedule(ctx context.Context, schedule model.Schedule) (*model.Schedule, error)
+	// DeleteSchedule returns ErrNotFound when the schedule does not exist.
+	DeleteSchedule(ctx context.Context, scheduleId uint64) error
+	// ListDueSchedules returns up to limit schedules whose next run is not
+	// after now, the most overdue first.
+	ListDueSchedules(now time.Time, limit int) ([]model.Schedule, error)
+	// AdvanceSchedule moves the next run of the schedule from from to next,
+	// nil ending the schedule. It returns ErrConflict when the next run is
+	// no longer from, as the schedule was changed, deleted or advanced by
+	// another worker in the meantime.
+	AdvanceSchedule(scheduleId uint64, from time.Time, next *time.Time) error
+}
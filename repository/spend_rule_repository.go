@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// SpendRuleRepository stores the spend rules of the accounts. Every change
+// is recorded in the audit log, and the rules are applied by the transaction
+// repository as it posts the purchases and withdraws of the accounts.
+type SpendRuleRepository interface {
+	// CreateSpendRule returns ErrForeignKeyViolation when the account does
+	// not exist and ErrConflict when it has the same rule already.
+	CreateSpendRule(ctx context.Context, rule model.SpendRule) (*model.SpendRule, error)
+	ListSpendRules(accountId uint64, page Page) ([]model.SpendRule, error)
+	// DeleteSpendRule returns ErrNotFound when the rule does not exist.
+	DeleteSpendRule(ctx context.Context, spendRuleId uint64) error
+}
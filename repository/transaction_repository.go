@@ -1,7 +1,56 @@
 package repository
 
-import "github.com/felipedsi/pismo-test/model"
+import (
+	"context"
+	"time"
 
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// TransactionFilter narrows ListTransactions, zero fields are ignored. The
+// event dates select the transactions that took place from EventDateFrom,
+// included, to EventDateTo, excluded.
+type TransactionFilter struct {
+	AccountId       uint64
+	OperationTypeId uint32
+	EventDateFrom   time.Time
+	EventDateTo     time.Time
+}
+
+// TransactionRepository records every change in the audit log, with the
+// metadata of the request found in ctx. Transactions are posted to the
+// stream of their account, which must not be blocked, and listed from a
+// projection of the streams. Postings carrying a dedup key, see
+// WithDedupKey, fail with ErrConflict when the key was used before.
 type TransactionRepository interface {
-	CreateTransaction(model.Transaction) (*model.Transaction, error)
+	CreateTransaction(ctx context.Context, transaction model.Transaction) (*model.Transaction, error)
+	// CreateTransactions stores every transaction with multi-row statements
+	// and returns them with their IDs, in the same order. Nothing is stored
+	// when any of them fails.
+	CreateTransactions(ctx context.Context, transactions []model.Transaction) ([]model.Transaction, error)
+	ListTransactions(filter TransactionFilter, page Page) ([]model.Transaction, error)
+	// ListTransactionsByAccounts applies the page to the transactions of each
+	// account separately, loading the transactions of many accounts at once.
+	ListTransactionsByAccounts(accountIds []uint64, page Page) (map[uint64][]model.Transaction, error)
+	// ReverseTransaction appends TransactionReversed to the stream of the
+	// account of the transaction, cancelling its amount. It returns
+	// ErrConflict when the transaction is already reversed.
+	ReverseTransaction(ctx context.Context, transactionId uint64) (*model.Transaction, error)
+}
+
+type dedupKeyKey struct{}
+
+// WithDedupKey returns a copy of ctx making CreateTransaction and
+// CreateTransactions store key along with the transactions, and fail with
+// ErrConflict, posting nothing, when it was stored before. Workers posting
+// at least once use it so a retry never posts twice.
+func WithDedupKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, dedupKeyKey{}, key)
+}
+
+// DedupKey returns the key set with WithDedupKey.
+func DedupKey(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(dedupKeyKey{}).(string)
+
+	return key, ok
 }
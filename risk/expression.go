@@ -0,0 +1,460 @@
+package risk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// kind is the type of a value of an expression.
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindNumber:
+		return "number"
+	case kindString:
+		return "string"
+	}
+
+	return "boolean"
+}
+
+type value struct {
+	number float64
+	text   string
+	truth  bool
+}
+
+// node is an expression checked for types, evaluated against the facts of a
+// transaction.
+type node interface {
+	kind() kind
+	eval(facts Facts) value
+}
+
+type literal struct {
+	k kind
+	v value
+}
+
+func (l literal) kind() kind             { return l.k }
+func (l literal) eval(facts Facts) value { return l.v }
+
+type variable struct {
+	k    kind
+	read func(facts Facts) value
+}
+
+func (v variable) kind() kind             { return v.k }
+func (v variable) eval(facts Facts) value { return v.read(facts) }
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u unary) kind() kind { return u.operand.kind() }
+
+func (u unary) eval(facts Facts) value {
+	operand := u.operand.eval(facts)
+
+	if u.op == "!" {
+		return value{truth: !operand.truth}
+	}
+
+	return value{number: -operand.number}
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b binary) kind() kind {
+	switch b.op {
+	case "+", "-", "*", "/":
+		return kindNumber
+	}
+
+	return kindBool
+}
+
+func (b binary) eval(facts Facts) value {
+	left := b.left.eval(facts)
+
+	// && and || only evaluate their right side when it decides the result.
+	switch b.op {
+	case "&&":
+		return value{truth: left.truth && b.right.eval(facts).truth}
+	case "||":
+		return value{truth: left.truth || b.right.eval(facts).truth}
+	}
+
+	right := b.right.eval(facts)
+
+	switch b.op {
+	case "+":
+		return value{number: left.number + right.number}
+	case "-":
+		return value{number: left.number - right.number}
+	case "*":
+		return value{number: left.number * right.number}
+	case "/":
+		if right.number == 0 {
+			return value{}
+		}
+
+		return value{number: left.number / right.number}
+	case "==":
+		return value{truth: left == right}
+	case "!=":
+		return value{truth: left != right}
+	case "<":
+		return value{truth: left.number < right.number}
+	case "<=":
+		return value{truth: left.number <= right.number}
+	case ">":
+		return value{truth: left.number > right.number}
+	}
+
+	return value{truth: left.number >= right.number}
+}
+
+// Expression is a condition on a transaction and the counters of its
+// account, compiled by Compile.
+type Expression struct {
+	source string
+	root   node
+}
+
+// Holds reports whether the expression holds for facts.
+func (e *Expression) Holds(facts Facts) bool {
+	return e.root.eval(facts).truth
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Compile parses source, an expression made of the variables of Facts,
+// numbers, "double-quoted" strings, true and false, combined with
+//
+//	||  &&  !  ==  !=  <  <=  >  >=  +  -  *  /  ( )
+//
+// in the usual order of precedence, such as
+//
+//	account_age_days < 30 && amount > 500
+//
+// It must be a boolean. Strings are only compared for equality, and a
+// division by zero is zero.
+func Compile(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().text != "" {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.peek().text, p.peek().position+1)
+	}
+
+	if root.kind() != kindBool {
+		return nil, fmt.Errorf("the expression is a %s, it must be a boolean", root.kind())
+	}
+
+	return &Expression{source: source, root: root}, nil
+}
+
+type tokenType int
+
+const (
+	tokenEnd tokenType = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+type token struct {
+	typ      tokenType
+	text     string
+	position int
+}
+
+// operators are the operators and brackets, the two-character ones first.
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "(", ")"}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(source); {
+		c := rune(source[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+
+			tokens = append(tokens, token{tokenNumber, source[start:i], start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+
+			for i < len(source) && (source[i] == '_' || unicode.IsLetter(rune(source[i])) || unicode.IsDigit(rune(source[i]))) {
+				i++
+			}
+
+			tokens = append(tokens, token{tokenIdent, source[start:i], start})
+		case c == '"':
+			end := strings.IndexByte(source[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+
+			tokens = append(tokens, token{tokenString, source[i+1 : i+1+end], i})
+			i += end + 2
+		default:
+			matched := false
+
+			for _, op := range operators {
+				if strings.HasPrefix(source[i:], op) {
+					tokens = append(tokens, token{tokenOperator, op, i})
+					i += len(op)
+					matched = true
+
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("unexpected %q at position %d", c, i+1)
+			}
+		}
+	}
+
+	return append(tokens, token{tokenEnd, "", len(source)}), nil
+}
+
+// parser builds the nodes by recursive descent, one method per level of
+// precedence, checking the kinds of the operands as it goes.
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+// accept consumes the next token when it is one of the operators.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+
+	if t.typ != tokenOperator {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.next++
+			return op, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) or() (node, error) {
+	return p.logical(p.and, "||")
+}
+
+func (p *parser) and() (node, error) {
+	return p.logical(p.not, "&&")
+}
+
+func (p *parser) logical(operand func() (node, error), op string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept(op); !ok {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		if left.kind() != kindBool || right.kind() != kindBool {
+			return nil, fmt.Errorf("%s takes booleans, not a %s and a %s", op, left.kind(), right.kind())
+		}
+
+		left = binary{op, left, right}
+	}
+}
+
+func (p *parser) not() (node, error) {
+	if _, ok := p.accept("!"); !ok {
+		return p.comparison()
+	}
+
+	operand, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand.kind() != kindBool {
+		return nil, fmt.Errorf("! takes a boolean, not a %s", operand.kind())
+	}
+
+	return unary{"!", operand}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+
+	if left.kind() != right.kind() {
+		return nil, fmt.Errorf("%s compares values of the same type, not a %s and a %s", op, left.kind(), right.kind())
+	}
+
+	if op != "==" && op != "!=" && left.kind() != kindNumber {
+		return nil, fmt.Errorf("%s compares numbers, not %ss", op, left.kind())
+	}
+
+	return binary{op, left, right}, nil
+}
+
+func (p *parser) sum() (node, error) {
+	return p.arithmetic(p.product, "+", "-")
+}
+
+func (p *parser) product() (node, error) {
+	return p.arithmetic(p.negation, "*", "/")
+}
+
+func (p *parser) arithmetic(operand func() (node, error), ops ...string) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		if left.kind() != kindNumber || right.kind() != kindNumber {
+			return nil, fmt.Errorf("%s takes numbers, not a %s and a %s", op, left.kind(), right.kind())
+		}
+
+		left = binary{op, left, right}
+	}
+}
+
+func (p *parser) negation() (node, error) {
+	if _, ok := p.accept("-"); !ok {
+		return p.primary()
+	}
+
+	operand, err := p.negation()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand.kind() != kindNumber {
+		return nil, fmt.Errorf("- takes a number, not a %s", operand.kind())
+	}
+
+	return unary{"-", operand}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.peek()
+
+	switch t.typ {
+	case tokenEnd:
+		return nil, fmt.Errorf("unexpected end of the expression")
+	case tokenNumber:
+		number, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.text, t.position+1)
+		}
+
+		p.next++
+
+		return literal{kindNumber, value{number: number}}, nil
+	case tokenString:
+		p.next++
+
+		return literal{kindString, value{text: t.text}}, nil
+	case tokenIdent:
+		p.next++
+
+		switch t.text {
+		case "true", "false":
+			return literal{kindBool, value{truth: t.text == "true"}}, nil
+		}
+
+		v, ok := variables[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q at position %d", t.text, t.position+1)
+		}
+
+		return v, nil
+	}
+
+	if _, ok := p.accept("("); ok {
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing ) at position %d", p.peek().position+1)
+		}
+
+		return inner, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", t.text, t.position+1)
+}
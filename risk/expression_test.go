@@ -0,0 +1,70 @@
+package risk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileHolds(t *testing.T) {
+	facts := Facts{Amount: 600, OperationTypeId: 1, Mcc: "5812", Category: "restaurants", Country: "US", AccountAgeDays: 3.5, TransactionsMinute: 2, SpentDay: 900, LastCountry: "BR"}
+
+	for source, expected := range map[string]bool{
+		"true":                                                          true,
+		"!false":                                                        true,
+		"amount > 500":                                                  true,
+		"amount >= 600 && amount <= 600":                                true,
+		"account_age_days < 30 && amount > 1000":                        false,
+		"account_age_days < 3 || amount > 500":                          true,
+		`country != last_country`:                                       true,
+		`last_country != "" && country == "BR"`:                         false,
+		`category == "restaurants"`:                                     true,
+		`mcc == "5812" && operation_type_id == 1`:                       true,
+		"spent_today + amount > 1400":                                   true,
+		"spent_today - amount * 2 == -300":                              true,
+		"(spent_today - amount) * 2 == 600":                             true,
+		"-amount < 0":                                                   true,
+		"transactions_this_minute / 0 == 0":                             true,
+		"spent_today / transactions_this_minute == 450":                 true,
+		"!(amount > 500)":                                               false,
+		"false || true && false":                                        false,
+		"transactions_this_minute > 1 && !(account_age_days >= 0.5 )":   false,
+		"true && true && (false || transactions_this_minute == 2)":      true,
+		`country == "US" && last_country == "BR" && account_age_days<4`: true,
+	} {
+		expression, err := Compile(source)
+		require.NoError(t, err, source)
+
+		assert.Equal(t, expected, expression.Holds(facts), source)
+		assert.Equal(t, source, expression.String())
+	}
+}
+
+func TestCompileFails(t *testing.T) {
+	for source, expected := range map[string]string{
+		"":                          "unexpected end of the expression",
+		"amount":                    "the expression is a number, it must be a boolean",
+		"amount +":                  "unexpected end of the expression",
+		"amount > 1 == true":        "unexpected \"==\" at position 12",
+		"amount > 5 5":              `unexpected "5" at position 12`,
+		"balance > 5":               `unknown variable "balance" at position 1`,
+		"amount > 1.2.3":            `invalid number "1.2.3" at position 10`,
+		`country == "BR`:            "unterminated string at position 12",
+		"amount > 5 # comment":      `unexpected '#' at position 12`,
+		"(amount > 5":               "missing ) at position 12",
+		`country > "BR"`:            "> compares numbers, not strings",
+		`amount == "5"`:             "== compares values of the same type, not a number and a string",
+		"amount && true":            "&& takes booleans, not a number and a boolean",
+		"!amount":                   "! takes a boolean, not a number",
+		"-country == 1":             "- takes a number, not a string",
+		`country + "x" == "BRx"`:    "+ takes numbers, not a string and a string",
+		"amount > 5 || spent_today": "|| takes booleans, not a boolean and a number",
+	} {
+		_, err := Compile(source)
+
+		if assert.Error(t, err, source) {
+			assert.Equal(t, expected, err.Error(), source)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Package risk decides whether a transaction is let through before it is
+// posted. Risk rules, from the configuration or stored in the repository,
+// are expressions on the transaction and the counters of its account, see
+// Compile: the transaction is declined when a decline rule holds, held for
+// review when only review rules do, and approved otherwise. Every decision
+// is recorded with the rules that fired.
+package risk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// Facts are what the expressions of the rules are evaluated against.
+type Facts struct {
+	// Amount is the absolute amount of the transaction, as sent.
+	Amount          float64
+	OperationTypeId uint32
+	Mcc             string
+	Category        string
+	Country         string
+	// AccountAgeDays is how long the account has been opened, in days.
+	AccountAgeDays float64
+	// TransactionsMinute counts the transactions posted to the account in
+	// the minute, in UTC, and this one.
+	TransactionsMinute int
+	// SpentDay adds up the purchases and withdraws posted to the account in
+	// the day, in UTC, in its billing currency, and this one as sent.
+	SpentDay float64
+	// LastCountry is the merchant country of the latest transaction of the
+	// account made at one, "" when there is none.
+	LastCountry string
+}
+
+func number(read func(facts Facts) float64) variable {
+	return variable{kindNumber, func(facts Facts) value { return value{number: read(facts)} }}
+}
+
+func text(read func(facts Facts) string) variable {
+	return variable{kindString, func(facts Facts) value { return value{text: read(facts)} }}
+}
+
+// variables are the names the expressions read the facts with.
+var variables = map[string]variable{
+	"amount":            number(func(f Facts) float64 { return f.Amount }),
+	"operation_type_id": number(func(f Facts) float64 { return float64(f.OperationTypeId) }),
+	"mcc":               text(func(f Facts) string { return f.Mcc }),
+	"category":          text(func(f Facts) string { return f.Category }),
+	"country":           text(func(f Facts) string { return f.Country }),
+	"account_age_days":  number(func(f Facts) float64 { return f.AccountAgeDays }),
+	"transactions_this_minute": number(func(f Facts) float64 {
+		return float64(f.TransactionsMinute)
+	}),
+	"spent_today":  number(func(f Facts) float64 { return f.SpentDay }),
+	"last_country": text(func(f Facts) string { return f.LastCountry }),
+}
+
+// Rule is a risk rule with its expression compiled.
+type Rule struct {
+	model.RiskRule
+	expression *Expression
+}
+
+// CompileRule checks the action of rule and compiles its expression.
+func CompileRule(rule model.RiskRule) (Rule, error) {
+	if !model.ValidateRiskAction(rule.Action) {
+		return Rule{}, fmt.Errorf("the action of rule %q must be review or decline", rule.Name)
+	}
+
+	expression, err := Compile(rule.Expression)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+
+	return Rule{RiskRule: rule, expression: expression}, nil
+}
+
+// LoadRules reads the rules of the configuration file at path, a JSON array
+// of objects with a name, an expression and an action.
+func LoadRules(path string) ([]Rule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configured []model.RiskRule
+
+	if err := json.Unmarshal(content, &configured); err != nil {
+		return nil, fmt.Errorf("reading the risk rules of %s: %w", path, err)
+	}
+
+	rules := make([]Rule, 0, len(configured))
+	names := map[string]bool{}
+
+	for _, rule := range configured {
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("every risk rule of %s must have a name of its own", path)
+		}
+
+		names[rule.Name] = true
+
+		compiled, err := CompileRule(model.RiskRule{Name: rule.Name, Expression: rule.Expression, Action: rule.Action})
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, compiled)
+	}
+
+	return rules, nil
+}
+
+// Engine assesses the transactions with the rules of the configuration and
+// the ones of the repository.
+type Engine struct {
+	repository repository.RiskRepository
+	configured []Rule
+
+	mu sync.Mutex
+	// compiled keeps the rules of the repository, by ID, once compiled.
+	compiled map[uint64]Rule
+}
+
+func NewEngine(repository repository.RiskRepository, configured []Rule) *Engine {
+	return &Engine{
+		repository: repository,
+		configured: configured,
+		compiled:   map[uint64]Rule{},
+	}
+}
+
+// Assess decides on transaction before it is posted and records the
+// decision. It returns repository.ErrForeignKeyViolation when the account
+// does not exist.
+func (e *Engine) Assess(ctx context.Context, transaction model.Transaction) (*model.RiskDecision, error) {
+	rules, err := e.rules()
+	if err != nil {
+		return nil, err
+	}
+
+	decision := model.RiskDecision{
+		AccountId:       transaction.AccountId,
+		OperationTypeId: transaction.OperationTypeId,
+		Amount:          transaction.Amount,
+		MerchantCountry: transaction.MerchantCountry,
+	}
+
+	return e.repository.DecideRisk(ctx, decision, func(decision model.RiskDecision, counters model.RiskCounters) (string, []string, error) {
+		outcome, fired := Decide(rules, FactsOf(transaction, counters, decision.CreatedAt))
+
+		return outcome, fired, nil
+	})
+}
+
+// Assessor decides whether a transaction is let through before it is
+// posted, recording the decision, such as Engine.
+type Assessor interface {
+	Assess(ctx context.Context, transaction model.Transaction) (*model.RiskDecision, error)
+}
+
+// DeclinedError is returned by Check for a transaction the risk rules
+// declined.
+type DeclinedError struct {
+	Decision *model.RiskDecision
+}
+
+func (e *DeclinedError) Error() string {
+	return fmt.Sprintf("transaction was declined by the risk rules: %s", strings.Join(e.Decision.Rules, ", "))
+}
+
+// Check assesses transaction with assessor before it is posted, returning a
+// *DeclinedError when it is declined. Every entry point posting transactions
+// calls it, so none gets around the rules. Transactions held for review are
+// let through, the decision lists them, and a nil assessor lets every
+// transaction through.
+func Check(ctx context.Context, assessor Assessor, transaction model.Transaction) error {
+	if assessor == nil {
+		return nil
+	}
+
+	decision, err := assessor.Assess(ctx, transaction)
+	if err != nil {
+		return err
+	}
+
+	if decision.Outcome == model.RISK_OUTCOME_DECLINE {
+		return &DeclinedError{Decision: decision}
+	}
+
+	return nil
+}
+
+// rules returns the rules of the configuration followed by the ones of the
+// repository.
+func (e *Engine) rules() ([]Rule, error) {
+	rules := append([]Rule{}, e.configured...)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	page := repository.Page{}
+
+	for {
+		stored, err := e.repository.ListRiskRules(page)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range stored {
+			compiled, ok := e.compiled[rule.RiskRuleId]
+
+			if !ok {
+				if compiled, err = CompileRule(rule); err != nil {
+					return nil, err
+				}
+
+				e.compiled[rule.RiskRuleId] = compiled
+			}
+
+			rules = append(rules, compiled)
+		}
+
+		if len(stored) < page.EffectiveLimit() {
+			return rules, nil
+		}
+
+		page.AfterId = stored[len(stored)-1].RiskRuleId
+	}
+}
+
+// FactsOf returns the facts of transaction, assessed at now, given the
+// counters of its account.
+func FactsOf(transaction model.Transaction, counters model.RiskCounters, now time.Time) Facts {
+	return Facts{
+		Amount:             transaction.Amount.Abs().Float64(),
+		OperationTypeId:    transaction.OperationTypeId,
+		Mcc:                transaction.Mcc,
+		Category:           model.MerchantCategory(transaction.Mcc),
+		Country:            transaction.MerchantCountry,
+		AccountAgeDays:     now.Sub(counters.OpenedAt).Hours() / 24,
+		TransactionsMinute: counters.TransactionsMinute,
+		SpentDay:           counters.SpentDay,
+		LastCountry:        counters.LastCountry,
+	}
+}
+
+// Decide returns the outcome of the rules for facts and the names of the
+// ones that fired, in order.
+func Decide(rules []Rule, facts Facts) (string, []string) {
+	outcome := model.RISK_OUTCOME_APPROVE
+	fired := []string{}
+
+	for _, rule := range rules {
+		if !rule.expression.Holds(facts) {
+			continue
+		}
+
+		fired = append(fired, rule.Name)
+
+		if rule.Action == model.RISK_OUTCOME_DECLINE || outcome == model.RISK_OUTCOME_APPROVE {
+			outcome = rule.Action
+		}
+	}
+
+	return outcome, fired
+}
+
+// Spend returns how much a transaction of operationTypeId adds to the
+// spending of its account: the absolute amount of purchases and withdraws,
+// nothing for the others.
+func Spend(operationTypeId uint32, amount model.Amount) float64 {
+	if !model.IsMerchantOperationType(operationTypeId) {
+		return 0
+	}
+
+	return amount.Abs().Float64()
+}
+
+// MinuteOf and DayOf return the start of the periods of the counters
+// holding at, in UTC.
+func MinuteOf(at time.Time) time.Time {
+	return at.UTC().Truncate(time.Minute)
+}
+
+func DayOf(at time.Time) time.Time {
+	at = at.UTC()
+
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+}
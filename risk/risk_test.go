@@ -0,0 +1,187 @@
+package risk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// stubRepository decides with the counters it is given and keeps the
+// decisions, as the repositories do.
+type stubRepository struct {
+	repository.RiskRepository
+
+	rules     []model.RiskRule
+	counters  model.RiskCounters
+	decisions []model.RiskDecision
+}
+
+func (s *stubRepository) ListRiskRules(page repository.Page) ([]model.RiskRule, error) {
+	rules := []model.RiskRule{}
+
+	for _, rule := range s.rules {
+		if rule.RiskRuleId > page.AfterId && len(rules) < page.EffectiveLimit() {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func (s *stubRepository) DecideRisk(ctx context.Context, decision model.RiskDecision, decide repository.RiskDecider) (*model.RiskDecision, error) {
+	decision.CreatedAt = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	var err error
+
+	if decision.Outcome, decision.Rules, err = decide(decision, s.counters); err != nil {
+		return nil, err
+	}
+
+	s.decisions = append(s.decisions, decision)
+
+	return &decision, nil
+}
+
+func compileRule(t *testing.T, name string, expression string, action string) Rule {
+	rule, err := CompileRule(model.RiskRule{Name: name, Expression: expression, Action: action})
+	require.NoError(t, err)
+
+	return rule
+}
+
+func TestDecide(t *testing.T) {
+	rules := []Rule{
+		compileRule(t, "large", "amount > 500", model.RISK_OUTCOME_REVIEW),
+		compileRule(t, "velocity", "transactions_this_minute > 3", model.RISK_OUTCOME_DECLINE),
+		compileRule(t, "country", `last_country != "" && country != last_country`, model.RISK_OUTCOME_REVIEW),
+	}
+
+	for _, scenario := range []struct {
+		facts           Facts
+		expectedOutcome string
+		expectedRules   []string
+	}{
+		{Facts{Amount: 10, TransactionsMinute: 1}, model.RISK_OUTCOME_APPROVE, []string{}},
+		{Facts{Amount: 600, TransactionsMinute: 1}, model.RISK_OUTCOME_REVIEW, []string{"large"}},
+		{Facts{Amount: 600, TransactionsMinute: 4}, model.RISK_OUTCOME_DECLINE, []string{"large", "velocity"}},
+		{Facts{Amount: 10, TransactionsMinute: 4, Country: "US", LastCountry: "BR"}, model.RISK_OUTCOME_DECLINE, []string{"velocity", "country"}},
+		{Facts{Amount: 10, TransactionsMinute: 1, Country: "US"}, model.RISK_OUTCOME_APPROVE, []string{}},
+	} {
+		outcome, fired := Decide(rules, scenario.facts)
+
+		assert.Equal(t, scenario.expectedOutcome, outcome, scenario.facts)
+		assert.Equal(t, scenario.expectedRules, fired, scenario.facts)
+	}
+}
+
+func TestCompileRuleChecksTheAction(t *testing.T) {
+	_, err := CompileRule(model.RiskRule{Name: "large", Expression: "amount > 500", Action: model.RISK_OUTCOME_APPROVE})
+	assert.EqualError(t, err, `the action of rule "large" must be review or decline`)
+
+	_, err = CompileRule(model.RiskRule{Name: "large", Expression: "amount >", Action: model.RISK_OUTCOME_REVIEW})
+	assert.EqualError(t, err, `rule "large": unexpected end of the expression`)
+}
+
+func TestLoadRules(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(content string) string {
+		path := filepath.Join(dir, "rules.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+		return path
+	}
+
+	rules, err := LoadRules(write(`[
+		{"name": "velocity", "expression": "transactions_this_minute > 5", "action": "decline"},
+		{"name": "new-account", "expression": "account_age_days < 30 && amount > 500", "action": "review"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "velocity", rules[0].Name)
+	assert.Equal(t, model.RISK_OUTCOME_REVIEW, rules[1].Action)
+
+	_, err = LoadRules(write(`[{"name": "a", "expression": "true", "action": "review"}, {"name": "a", "expression": "true", "action": "review"}]`))
+	assert.ErrorContains(t, err, "must have a name of its own")
+
+	_, err = LoadRules(write(`[{"expression": "true", "action": "review"}]`))
+	assert.ErrorContains(t, err, "must have a name of its own")
+
+	_, err = LoadRules(write(`[{"name": "a", "expression": "amount", "action": "review"}]`))
+	assert.ErrorContains(t, err, "it must be a boolean")
+
+	_, err = LoadRules(write(`{}`))
+	assert.ErrorContains(t, err, "reading the risk rules of")
+
+	_, err = LoadRules(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFactsOf(t *testing.T) {
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+
+	facts := FactsOf(
+		model.Transaction{AccountId: 1, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-123.45"), Mcc: "5812", MerchantCountry: "US"},
+		model.RiskCounters{OpenedAt: now.Add(-36 * time.Hour), TransactionsMinute: 2, SpentDay: 200.5, LastCountry: "BR"},
+		now,
+	)
+
+	assert.Equal(t, Facts{Amount: 123.45, OperationTypeId: model.CASH_PURCHASE, Mcc: "5812", Category: model.MERCHANT_CATEGORY_RESTAURANTS, Country: "US", AccountAgeDays: 1.5, TransactionsMinute: 2, SpentDay: 200.5, LastCountry: "BR"}, facts)
+}
+
+func TestSpend(t *testing.T) {
+	assert.Equal(t, 19.99, Spend(model.WITHDRAW, model.MustParseAmount("-19.99")))
+	assert.Equal(t, 0.0, Spend(model.PAYMENT, model.MustParseAmount("100")))
+}
+
+func TestPeriods(t *testing.T) {
+	at := time.Date(2024, 3, 1, 23, 59, 30, 0, time.FixedZone("BRT", -3*60*60))
+
+	assert.Equal(t, time.Date(2024, 3, 2, 2, 59, 0, 0, time.UTC), MinuteOf(at))
+	assert.Equal(t, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), DayOf(at))
+}
+
+func TestEngineAssess(t *testing.T) {
+	stub := &stubRepository{
+		rules: []model.RiskRule{
+			{RiskRuleId: 1, Name: "new-account", Expression: "account_age_days < 30 && amount > 500", Action: model.RISK_OUTCOME_REVIEW},
+		},
+		counters: model.RiskCounters{OpenedAt: time.Date(2024, 2, 20, 0, 0, 0, 0, time.UTC), TransactionsMinute: 1, SpentDay: 900},
+	}
+
+	engine := NewEngine(stub, []Rule{compileRule(t, "daily", "spent_today > 1000", model.RISK_OUTCOME_DECLINE)})
+
+	decision, err := engine.Assess(context.Background(), model.Transaction{AccountId: 1, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-900"), MerchantCountry: "BR"})
+	require.NoError(t, err)
+
+	assert.Equal(t, model.RISK_OUTCOME_REVIEW, decision.Outcome)
+	assert.Equal(t, []string{"new-account"}, decision.Rules)
+	assert.Equal(t, "BR", decision.MerchantCountry)
+	assert.Equal(t, model.MustParseAmount("-900"), decision.Amount)
+
+	// Rules created later are picked up by the next assessment.
+	stub.rules = append(stub.rules, model.RiskRule{RiskRuleId: 2, Name: "large", Expression: "amount >= 900", Action: model.RISK_OUTCOME_REVIEW})
+	stub.counters.SpentDay = 1800
+
+	decision, err = engine.Assess(context.Background(), model.Transaction{AccountId: 1, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-900")})
+	require.NoError(t, err)
+
+	assert.Equal(t, model.RISK_OUTCOME_DECLINE, decision.Outcome)
+	assert.Equal(t, []string{"daily", "new-account", "large"}, decision.Rules)
+	assert.Len(t, stub.decisions, 2)
+
+	// A stored rule that no longer compiles fails the assessment rather
+	// than letting the transaction through.
+	stub.rules = append(stub.rules, model.RiskRule{RiskRuleId: 3, Name: "broken", Expression: "balance > 1", Action: model.RISK_OUTCOME_DECLINE})
+
+	_, err = engine.Assess(context.Background(), model.Transaction{AccountId: 1, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-1")})
+	assert.ErrorContains(t, err, `unknown variable "balance"`)
+}
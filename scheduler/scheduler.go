@@ -0,0 +1,174 @@
+// Package scheduler posts the transactions of the schedules as they fall
+// due. Each occurrence is posted through the transaction repository with a
+// dedup key naming the schedule and the occurrence before the schedule is
+// moved to its next one, so a worker stopping in between posts the
+// occurrence again on its next run and the repository turns it down: every
+// occurrence is posted at least once and stored once. The occurrences are
+// assessed with the risk rules like any other transaction, and the declined
+// ones skipped.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/recurrence"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+// pollInterval is how often the due schedules are looked for, occurrences
+// falling on whole minutes.
+const pollInterval = time.Minute
+
+// batchSize is how many due schedules are loaded at once.
+const batchSize = 100
+
+type Scheduler struct {
+	schedules    repository.ScheduleRepository
+	transactions repository.TransactionRepository
+	risk         risk.Assessor
+}
+
+// NewScheduler assesses the occurrences with risk, which may be nil to let
+// every one through.
+func NewScheduler(schedules repository.ScheduleRepository, transactions repository.TransactionRepository, risk risk.Assessor) *Scheduler {
+	return &Scheduler{
+		schedules:    schedules,
+		transactions: transactions,
+		risk:         risk,
+	}
+}
+
+// Start posts the due occurrences until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		posted, err := s.RunDue(ctx, time.Now())
+
+		if err != nil {
+			log.Printf("Scheduler#Start: Running the due schedules failed: %s", err)
+		}
+
+		if posted > 0 {
+			log.Printf("Scheduler#Start: Posted %d scheduled transactions", posted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DedupKey returns the dedup key of the transaction of an occurrence.
+func DedupKey(scheduleId uint64, occurrence time.Time) string {
+	return fmt.Sprintf("schedule:%d:%d", scheduleId, occurrence.Unix())
+}
+
+// RunDue posts every occurrence not after now, catching up on those missed,
+// and returns how many transactions were posted. A schedule failing is left
+// for the next run and the first failure is returned once the others ran.
+func (s *Scheduler) RunDue(ctx context.Context, now time.Time) (int, error) {
+	posted := 0
+
+	var failure error
+
+	for {
+		due, err := s.schedules.ListDueSchedules(now, batchSize)
+		if err != nil {
+			return posted, err
+		}
+
+		for _, schedule := range due {
+			count, err := s.run(ctx, schedule, now)
+			posted += count
+
+			if err != nil && failure == nil {
+				failure = fmt.Errorf("schedule %d: %w", schedule.ScheduleId, err)
+			}
+		}
+
+		// The schedules failing are still due, so they would be loaded
+		// again.
+		if len(due) < batchSize || failure != nil {
+			return posted, failure
+		}
+	}
+}
+
+// run posts the occurrences of schedule up to now, advancing it after each
+// one.
+func (s *Scheduler) run(ctx context.Context, schedule model.Schedule, now time.Time) (int, error) {
+	occurrence := *schedule.NextRunAt
+
+	rule, err := recurrence.Parse(schedule.Recurrence, schedule.StartDate)
+	if err != nil {
+		log.Printf("Scheduler#run: Ending schedule %d, whose recurrence is invalid: %s", schedule.ScheduleId, err)
+
+		err = s.schedules.AdvanceSchedule(schedule.ScheduleId, occurrence, nil)
+
+		if errors.Is(err, repository.ErrConflict) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	posted := 0
+
+	for !occurrence.After(now) {
+		transaction := model.Transaction{
+			AccountId:       schedule.AccountId,
+			OperationTypeId: schedule.OperationTypeId,
+			Amount:          schedule.Amount,
+			EventDate:       occurrence,
+		}
+
+		err := risk.Check(ctx, s.risk, transaction)
+
+		if err == nil {
+			_, err = s.transactions.CreateTransaction(repository.WithDedupKey(ctx, DedupKey(schedule.ScheduleId, occurrence)), transaction)
+		}
+
+		var declined *risk.DeclinedError
+
+		switch {
+		case err == nil:
+			posted++
+		case errors.Is(err, repository.ErrConflict):
+			log.Printf("Scheduler#run: Occurrence %s of schedule %d was already posted", occurrence.Format(time.RFC3339), schedule.ScheduleId)
+		case errors.As(err, &declined), errors.Is(err, repository.ErrAccountBlocked), errors.Is(err, repository.ErrForeignKeyViolation), errors.Is(err, repository.ErrInvalidAmount):
+			log.Printf("Scheduler#run: Skipping occurrence %s of schedule %d: %s", occurrence.Format(time.RFC3339), schedule.ScheduleId, err)
+		default:
+			return posted, err
+		}
+
+		next := recurrence.Next(rule, occurrence, schedule.EndDate)
+
+		err = s.schedules.AdvanceSchedule(schedule.ScheduleId, occurrence, next)
+
+		// The schedule changed in the meantime, its new next run is the one
+		// to post.
+		if errors.Is(err, repository.ErrConflict) {
+			log.Printf("Scheduler#run: Schedule %d changed while it ran", schedule.ScheduleId)
+
+			return posted, nil
+		}
+
+		if err != nil || next == nil {
+			return posted, err
+		}
+
+		occurrence = *next
+	}
+
+	return posted, nil
+}
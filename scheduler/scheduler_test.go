@@ -0,0 +1,160 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+	"github.com/felipedsi/pismo-test/risk"
+)
+
+var start = time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+type fixture struct {
+	accounts     repository.AccountRepository
+	transactions repository.TransactionRepository
+	schedules    repository.ScheduleRepository
+	risk         repository.RiskRepository
+	account      *model.Account
+}
+
+func newFixture(t *testing.T) fixture {
+	store := memory.NewStore()
+
+	f := fixture{
+		accounts:     memory.NewAccountRepositoryMemory(store),
+		transactions: memory.NewTransactionRepositoryMemory(store),
+		schedules:    memory.NewScheduleRepositoryMemory(store),
+		risk:         memory.NewRiskRepositoryMemory(store),
+	}
+
+	account, err := f.accounts.CreateAccount(context.Background(), model.Account{DocumentNumber: 111})
+	require.NoError(t, err)
+
+	f.account = account
+
+	return f
+}
+
+// schedule creates a daily payment of 10 starting at start.
+func (f fixture) schedule(t *testing.T, end *time.Time) *model.Schedule {
+	next := start
+
+	schedule, err := f.schedules.CreateSchedule(context.Background(), model.Schedule{
+		AccountId:       f.account.AccountId,
+		OperationTypeId: model.PAYMENT,
+		Amount:          model.MustParseAmount("10"),
+		Recurrence:      "FREQ=DAILY",
+		StartDate:       start,
+		EndDate:         end,
+		NextRunAt:       &next,
+	})
+	require.NoError(t, err)
+
+	return schedule
+}
+
+func (f fixture) eventDates(t *testing.T) []time.Time {
+	transactions, err := f.transactions.ListTransactions(repository.TransactionFilter{}, repository.Page{})
+	require.NoError(t, err)
+
+	dates := []time.Time{}
+
+	for _, transaction := range transactions {
+		dates = append(dates, transaction.EventDate)
+	}
+
+	return dates
+}
+
+func TestRunDueCatchesUpOnMissedOccurrences(t *testing.T) {
+	f := newFixture(t)
+	schedule := f.schedule(t, nil)
+
+	scheduler := NewScheduler(f.schedules, f.transactions, nil)
+
+	posted, err := scheduler.RunDue(context.Background(), start.AddDate(0, 0, 2).Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, posted)
+	assert.Equal(t, []time.Time{start, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)}, f.eventDates(t))
+
+	found, err := f.schedules.FindSchedule(schedule.ScheduleId)
+	require.NoError(t, err)
+	assert.Equal(t, start.AddDate(0, 0, 3), *found.NextRunAt)
+
+	posted, err = scheduler.RunDue(context.Background(), start.AddDate(0, 0, 2).Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, posted)
+}
+
+func TestRunDueEndsTheSchedule(t *testing.T) {
+	f := newFixture(t)
+	end := start.AddDate(0, 0, 1)
+	schedule := f.schedule(t, &end)
+
+	posted, err := NewScheduler(f.schedules, f.transactions, nil).RunDue(context.Background(), start.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 2, posted)
+
+	found, err := f.schedules.FindSchedule(schedule.ScheduleId)
+	require.NoError(t, err)
+	assert.Nil(t, found.NextRunAt)
+}
+
+func TestRunDueDoesNotPostAnOccurrenceTwice(t *testing.T) {
+	f := newFixture(t)
+	schedule := f.schedule(t, nil)
+
+	// A worker stopping after posting but before advancing the schedule.
+	ctx := repository.WithDedupKey(context.Background(), DedupKey(schedule.ScheduleId, start))
+	_, err := f.transactions.CreateTransaction(ctx, model.Transaction{AccountId: f.account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("10"), EventDate: start})
+	require.NoError(t, err)
+
+	posted, err := NewScheduler(f.schedules, f.transactions, nil).RunDue(context.Background(), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, posted)
+	assert.Equal(t, []time.Time{start}, f.eventDates(t))
+
+	found, err := f.schedules.FindSchedule(schedule.ScheduleId)
+	require.NoError(t, err)
+	assert.Equal(t, start.AddDate(0, 0, 1), *found.NextRunAt)
+}
+
+func TestRunDueSkipsBlockedAccounts(t *testing.T) {
+	f := newFixture(t)
+	schedule := f.schedule(t, nil)
+
+	_, err := f.accounts.BlockAccount(context.Background(), f.account.AccountId)
+	require.NoError(t, err)
+
+	posted, err := NewScheduler(f.schedules, f.transactions, nil).RunDue(context.Background(), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, posted)
+
+	found, err := f.schedules.FindSchedule(schedule.ScheduleId)
+	require.NoError(t, err)
+	assert.Equal(t, start.AddDate(0, 0, 1), *found.NextRunAt)
+}
+
+func TestRunDueSkipsDeclinedOccurrences(t *testing.T) {
+	f := newFixture(t)
+	schedule := f.schedule(t, nil)
+
+	rule, err := risk.CompileRule(model.RiskRule{Name: "payments", Expression: "operation_type_id == 4", Action: model.RISK_OUTCOME_DECLINE})
+	require.NoError(t, err)
+
+	posted, err := NewScheduler(f.schedules, f.transactions, risk.NewEngine(f.risk, []risk.Rule{rule})).RunDue(context.Background(), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, posted)
+	assert.Empty(t, f.eventDates(t))
+
+	found, err := f.schedules.FindSchedule(schedule.ScheduleId)
+	require.NoError(t, err)
+	assert.Equal(t, start.AddDate(0, 0, 1), *found.NextRunAt)
+}
@@ -0,0 +1,41 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+
+	err := writer.w.Write([]string{"transaction_id", "created_at", "operation_type_id", "description", "amount"})
+	if err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (c *csvWriter) WriteLine(line model.StatementLine) error {
+	return c.w.Write([]string{
+		strconv.FormatUint(line.TransactionId, 10),
+		line.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatUint(uint64(line.OperationTypeId), 10),
+		line.Description,
+		formatAmount(line.Amount),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+
+	return c.w.Error()
+}
@@ -0,0 +1,102 @@
+package statement
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// ofxCurrency is sent as the currency of every statement, as accounts have
+// no currency of their own.
+const ofxCurrency = "BRL"
+
+const ofxTimeLayout = "20060102150405"
+
+// ofxWriter renders an OFX 2.2 credit card statement.
+type ofxWriter struct {
+	w      *bufio.Writer
+	header Header
+	totals totals
+}
+
+func newOFXWriter(w io.Writer, header Header) (*ofxWriter, error) {
+	writer := &ofxWriter{w: bufio.NewWriter(w), header: header}
+
+	// A period open at the start begins with the first possible posting.
+	start := header.From
+
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+
+	end := header.To
+
+	if end.IsZero() {
+		end = header.GeneratedAt
+	}
+
+	fmt.Fprintf(writer.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<CREDITCARDMSGSRSV1><CCSTMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<CCSTMTRS><CURDEF>%s</CURDEF><CCACCTFROM><ACCTID>%d</ACCTID></CCACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, ofxTime(header.GeneratedAt), ofxCurrency, header.Account.AccountId, ofxTime(start), ofxTime(end))
+
+	return writer, nil
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format(ofxTimeLayout)
+}
+
+func ofxEscape(text string) string {
+	escaped := &strings.Builder{}
+	xml.EscapeText(escaped, []byte(text))
+
+	return escaped.String()
+}
+
+func (o *ofxWriter) WriteLine(line model.StatementLine) error {
+	o.totals.add(line.Amount)
+
+	transactionType := "CREDIT"
+
+	if line.Amount.Sign() < 0 {
+		transactionType = "DEBIT"
+	}
+
+	_, err := fmt.Fprintf(o.w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME></STMTTRN>\n",
+		transactionType,
+		ofxTime(line.CreatedAt),
+		formatAmount(line.Amount),
+		strconv.FormatUint(line.TransactionId, 10),
+		ofxEscape(line.Description))
+
+	return err
+}
+
+// Close reports the net of the period as the ledger balance, which is the
+// balance of the account when the period is open at the start.
+func (o *ofxWriter) Close() error {
+	asOf := o.header.To
+
+	if asOf.IsZero() {
+		asOf = o.header.GeneratedAt
+	}
+
+	fmt.Fprintf(o.w, `</BANKTRANLIST>
+<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>
+</CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>
+`, formatAmount(o.totals.net()), ofxTime(asOf))
+
+	return o.w.Flush()
+}
@@ -0,0 +1,235 @@
+package statement
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+)
+
+// Layout of the A4 pages, in points.
+const (
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+	pdfLineHeight   = 13
+	pdfTableSize    = 9
+	pdfFooterHeight = 30
+)
+
+// Objects written before the pages. The page tree and the catalog are only
+// written at the end, once every page is known, which lets the pages be
+// written as soon as they are filled.
+const (
+	pdfCatalogObject  = 1
+	pdfPagesObject    = 2
+	pdfBoldFontObject = 3
+	pdfMonoFontObject = 4
+	pdfFirstPage      = 5
+)
+
+const pdfDateLayout = "2006-01-02 15:04"
+
+// pdfWriter renders a statement as a PDF document. Only one page is kept in
+// memory at a time.
+type pdfWriter struct {
+	w       *bufio.Writer
+	written int64
+	err     error
+	header  Header
+	totals  totals
+
+	offsets map[int]int64
+	pages   []int
+	page    bytes.Buffer
+	y       float64
+}
+
+func newPDFWriter(w io.Writer, header Header) (*pdfWriter, error) {
+	writer := &pdfWriter{w: bufio.NewWriter(w), header: header, offsets: map[int]int64{}}
+
+	writer.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	writer.object(pdfBoldFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	writer.object(pdfMonoFontObject, "<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+
+	writer.startPage()
+
+	return writer, writer.err
+}
+
+// write keeps the first error of the underlying writer, which is reported
+// once the current page is done.
+func (p *pdfWriter) write(s string) {
+	n, err := p.w.WriteString(s)
+	p.written += int64(n)
+
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+func (p *pdfWriter) object(number int, body string) {
+	p.offsets[number] = p.written
+	p.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", number, body))
+}
+
+func (p *pdfWriter) text(font string, size float64, x float64, y float64, text string) {
+	fmt.Fprintf(&p.page, "BT /%s %g Tf %g %g Td (%s) Tj ET\n", font, size, x, y, pdfEscape(text))
+}
+
+// startPage begins a page with the header of the statement on the first
+// page and the column titles on every page.
+func (p *pdfWriter) startPage() {
+	p.page.Reset()
+	p.y = pdfPageHeight - pdfMargin
+
+	if len(p.pages) == 0 {
+		p.text("F1", 16, pdfMargin, p.y, "Account statement")
+		p.y -= 24
+
+		p.text("F2", 10, pdfMargin, p.y, fmt.Sprintf("Account %d, document %d", p.header.Account.AccountId, p.header.Account.DocumentNumber))
+		p.y -= pdfLineHeight
+
+		p.text("F2", 10, pdfMargin, p.y, describePeriod(p.header.From, p.header.To))
+		p.y -= pdfLineHeight
+
+		p.text("F2", 10, pdfMargin, p.y, "Generated on "+p.header.GeneratedAt.UTC().Format(pdfDateLayout)+" UTC")
+		p.y -= 2 * pdfLineHeight
+	}
+
+	p.text("F1", pdfTableSize, pdfMargin, p.y, tableRow("Date (UTC)", "ID", "Description", "Amount"))
+	p.y -= pdfLineHeight
+}
+
+func (p *pdfWriter) finishPage() {
+	p.text("F2", 8, pdfMargin, pdfMargin/2, fmt.Sprintf("Page %d", len(p.pages)+1))
+
+	content := pdfFirstPage + 2*len(p.pages)
+	page := content + 1
+
+	p.object(content, fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.page.Len(), p.page.String()))
+	p.object(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 %d 0 R /F2 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfPageWidth, pdfPageHeight, pdfBoldFontObject, pdfMonoFontObject, content))
+
+	p.pages = append(p.pages, page)
+}
+
+// row writes a line of the table, moving to a new page when the current one
+// is full.
+func (p *pdfWriter) row(font string, text string) error {
+	if p.y < pdfMargin+pdfFooterHeight {
+		p.finishPage()
+		p.startPage()
+
+		if p.err != nil {
+			return p.err
+		}
+	}
+
+	p.text(font, pdfTableSize, pdfMargin, p.y, text)
+	p.y -= pdfLineHeight
+
+	return nil
+}
+
+func (p *pdfWriter) WriteLine(line model.StatementLine) error {
+	p.totals.add(line.Amount)
+
+	return p.row("F2", tableRow(
+		line.CreatedAt.UTC().Format(pdfDateLayout),
+		strconv.FormatUint(line.TransactionId, 10),
+		line.Description,
+		formatAmount(line.Amount)))
+}
+
+func (p *pdfWriter) Close() error {
+	p.y -= pdfLineHeight / 2
+
+	for _, total := range [][2]string{
+		{"Total debits", formatAmount(p.totals.debits)},
+		{"Total credits", formatAmount(p.totals.credits)},
+		{"Net", formatAmount(p.totals.net())},
+	} {
+		if err := p.row("F1", tableRow("", "", total[0], total[1])); err != nil {
+			return err
+		}
+	}
+
+	p.finishPage()
+
+	kids := make([]string, len(p.pages))
+
+	for i, page := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+
+	p.object(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.object(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject))
+
+	size := pdfFirstPage + 2*len(p.pages)
+	xref := p.written
+
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", size))
+
+	for number := 1; number < size; number++ {
+		p.write(fmt.Sprintf("%010d 00000 n \n", p.offsets[number]))
+	}
+
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, pdfCatalogObject, xref))
+
+	if p.err != nil {
+		return p.err
+	}
+
+	return p.w.Flush()
+}
+
+// tableRow lays the columns out with the monospaced font, the description
+// being cut to fit.
+func tableRow(date string, id string, description string, amount string) string {
+	if len(description) > 30 {
+		description = description[:29] + "~"
+	}
+
+	return fmt.Sprintf("%-17s %10s  %-30s %16s", date, id, description, amount)
+}
+
+func describePeriod(from time.Time, to time.Time) string {
+	switch {
+	case from.IsZero() && to.IsZero():
+		return "Every transaction"
+	case to.IsZero():
+		return "From " + from.UTC().Format(pdfDateLayout) + " UTC"
+	case from.IsZero():
+		return "Until " + to.UTC().Format(pdfDateLayout) + " UTC"
+	default:
+		return "From " + from.UTC().Format(pdfDateLayout) + " to " + to.UTC().Format(pdfDateLayout) + " UTC"
+	}
+}
+
+// pdfEscape converts text to the WinAnsi encoding of the fonts, replacing
+// the characters it lacks, and escapes the string delimiters.
+func pdfEscape(text string) string {
+	escaped := &strings.Builder{}
+
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			escaped.WriteByte('\\')
+			escaped.WriteRune(r)
+		case r < 0x20:
+			escaped.WriteByte(' ')
+		case r < 0x7f || (r >= 0xa0 && r <= 0xff):
+			escaped.WriteByte(byte(r))
+		default:
+			escaped.WriteByte('?')
+		}
+	}
+
+	return escaped.String()
+}
@@ -0,0 +1,106 @@
+// Package statement renders the transactions of an account as downloadable
+// statements. Every format is written line by line as the transactions are
+// read, so a statement is never held in memory.
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+)
+
+// Header describes the statement being rendered.
+type Header struct {
+	Account     model.Account
+	From        time.Time
+	To          time.Time
+	GeneratedAt time.Time
+}
+
+// Writer renders the lines of a statement in one format. Close writes what
+// follows the lines, such as the totals, and must be called once they were
+// all written.
+type Writer interface {
+	WriteLine(line model.StatementLine) error
+	Close() error
+}
+
+var contentTypes = map[string]string{
+	model.EXPORT_FORMAT_CSV: "text/csv; charset=utf-8",
+	model.EXPORT_FORMAT_OFX: "application/x-ofx",
+	model.EXPORT_FORMAT_PDF: "application/pdf",
+}
+
+// ContentType returns the media type of format, or an empty string when the
+// format is unknown.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// FileName is the name statements in format are downloaded as.
+func FileName(header Header, format string) string {
+	return fmt.Sprintf("account-%d-statement.%s", header.Account.AccountId, format)
+}
+
+func NewWriter(format string, w io.Writer, header Header) (Writer, error) {
+	switch format {
+	case model.EXPORT_FORMAT_CSV:
+		return newCSVWriter(w)
+	case model.EXPORT_FORMAT_OFX:
+		return newOFXWriter(w, header)
+	case model.EXPORT_FORMAT_PDF:
+		return newPDFWriter(w, header)
+	default:
+		return nil, fmt.Errorf("unknown statement format %q", format)
+	}
+}
+
+// Export renders the statement of the lines matching filter to w and
+// returns how many lines it holds.
+func Export(w io.Writer, format string, header Header, filter repository.StatementFilter, exports repository.ExportRepository) (uint64, error) {
+	writer, err := NewWriter(format, w, header)
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint64
+
+	err = exports.StreamStatementLines(filter, func(line model.StatementLine) error {
+		count++
+
+		return writer.WriteLine(line)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, writer.Close()
+}
+
+// totals adds up the debits and credits of a statement.
+type totals struct {
+	debits  model.Amount
+	credits model.Amount
+}
+
+func (t *totals) add(amount model.Amount) {
+	if amount.Sign() < 0 {
+		t.debits = t.debits.Add(amount)
+	} else {
+		t.credits = t.credits.Add(amount)
+	}
+}
+
+func (t *totals) net() model.Amount {
+	return t.debits.Add(t.credits)
+}
+
+// formatAmount writes amount with two decimals, or with all of its own for
+// the currencies with three.
+func formatAmount(amount model.Amount) string {
+	return strconv.FormatFloat(amount.Float64(), 'f', max(2, amount.Decimals()), 64)
+}
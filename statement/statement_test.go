@@ -0,0 +1,125 @@
+package statement
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/felipedsi/pismo-test/model"
+	"github.com/felipedsi/pismo-test/repository"
+	"github.com/felipedsi/pismo-test/repository/adapter/memory"
+)
+
+var generatedAt = time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)
+
+type fixture struct {
+	account *model.Account
+	exports repository.ExportRepository
+}
+
+func newFixture(t *testing.T, transactions int) *fixture {
+	store := memory.NewStore()
+
+	account, err := memory.NewAccountRepositoryMemory(store).CreateAccount(context.Background(), model.Account{DocumentNumber: 12345678900})
+	require.NoError(t, err)
+
+	transactionRepository := memory.NewTransactionRepositoryMemory(store)
+
+	for i := 0; i < transactions; i++ {
+		transaction := model.Transaction{AccountId: account.AccountId, OperationTypeId: model.CASH_PURCHASE, Amount: model.MustParseAmount("-50")}
+
+		if i%2 == 1 {
+			transaction = model.Transaction{AccountId: account.AccountId, OperationTypeId: model.PAYMENT, Amount: model.MustParseAmount("60.5")}
+		}
+
+		_, err := transactionRepository.CreateTransaction(context.Background(), transaction)
+		require.NoError(t, err)
+	}
+
+	return &fixture{account: account, exports: memory.NewExportRepositoryMemory(store)}
+}
+
+func (f *fixture) export(t *testing.T, format string) string {
+	out := &bytes.Buffer{}
+	header := Header{Account: *f.account, GeneratedAt: generatedAt}
+
+	_, err := Export(out, format, header, repository.StatementFilter{AccountId: f.account.AccountId}, f.exports)
+	require.NoError(t, err)
+
+	return out.String()
+}
+
+func TestExportCSV(t *testing.T) {
+	f := newFixture(t, 2)
+
+	lines := strings.Split(strings.TrimSpace(f.export(t, model.EXPORT_FORMAT_CSV)), "\n")
+
+	require.Len(t, lines, 3)
+	assert.Equal(t, "transaction_id,created_at,operation_type_id,description,amount", lines[0])
+	assert.Regexp(t, `^1,\d{4}-\d\d-\d\dT\d\d:\d\d:\d\dZ,1,COMPRA A VISTA,-50.00$`, lines[1])
+	assert.Regexp(t, `^2,.*,4,PAGAMENTO,60.50$`, lines[2])
+}
+
+func TestExportOFX(t *testing.T) {
+	f := newFixture(t, 2)
+
+	ofx := f.export(t, model.EXPORT_FORMAT_OFX)
+
+	assert.Contains(t, ofx, "<ACCTID>1</ACCTID>")
+	assert.Contains(t, ofx, "<DTSTART>19700101000000</DTSTART><DTEND>20240201120000</DTEND>")
+	assert.Contains(t, ofx, "<TRNTYPE>DEBIT</TRNTYPE>")
+	assert.Contains(t, ofx, "<TRNAMT>-50.00</TRNAMT><FITID>1</FITID><NAME>COMPRA A VISTA</NAME>")
+	assert.Contains(t, ofx, "<TRNAMT>60.50</TRNAMT><FITID>2</FITID><NAME>PAGAMENTO</NAME>")
+	assert.Contains(t, ofx, "<LEDGERBAL><BALAMT>10.50</BALAMT>")
+}
+
+func TestExportPDF(t *testing.T) {
+	f := newFixture(t, 150)
+
+	pdf := f.export(t, model.EXPORT_FORMAT_PDF)
+
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "(Account 1, document 12345678900)")
+	assert.Contains(t, pdf, "COMPRA A VISTA")
+	assert.Contains(t, pdf, "Total debits")
+
+	// 150 lines do not fit in one page.
+	count := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`).FindStringSubmatch(pdf)
+	require.NotNil(t, count)
+	assert.Equal(t, "3", count[1])
+	assert.Contains(t, pdf, "(Page 3)")
+
+	// Every entry of the cross-reference table points at its object.
+	xref, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(pdf)[1])
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(pdf[xref:], "xref\n0 "))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(pdf[xref:], -1)
+	require.Len(t, entries, 4+2*3)
+
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(entry[1])
+		require.NoError(t, err)
+
+		assert.True(t, strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj\n", i+1)), "object %d", i+1)
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	assert.Equal(t, `Caf\351 \(1\) \\ ?`, strings.NewReplacer("\xe9", `\351`).Replace(pdfEscape(`Café (1) \ €`)))
+}
+
+func TestNewWriterRejectsUnknownFormat(t *testing.T) {
+	_, err := NewWriter("xlsx", &bytes.Buffer{}, Header{})
+
+	assert.Error(t, err)
+}